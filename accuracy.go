@@ -0,0 +1,196 @@
+package tokentracker
+
+import (
+	"context"
+	"math"
+	"sort"
+)
+
+// WithAccuracyTracking makes the tracker count the prompt of every tracked call whose
+// usage the provider reports, recording the local estimate in TokenCount.EstimatedInputTokens
+// for AccuracyReport. WithCalibration records it too. Providers counting with a
+// count_tokens API are not called for it, see ContextWithLocalCounting.
+func WithAccuracyTracking() TrackerOption {
+	return func(t *DefaultTokenTracker) {
+		t.accuracy = true
+	}
+}
+
+// localCountingKey marks a context whose token counts must not call a provider's API
+type localCountingKey struct{}
+
+// ContextWithLocalCounting returns a copy of ctx asking providers to count tokens
+// locally, without their count_tokens APIs. The tracker uses it to estimate calls whose
+// usage the response already reports.
+func ContextWithLocalCounting(ctx context.Context) context.Context {
+	return context.WithValue(ctx, localCountingKey{}, true)
+}
+
+// LocalCountingFromContext reports whether ctx asks for local token counting
+func LocalCountingFromContext(ctx context.Context) bool {
+	local, _ := ctx.Value(localCountingKey{}).(bool)
+	return local
+}
+
+// AccuracySummary summarizes how far the input token estimates of a provider or model
+// were from the usage reported for the same calls. Errors are relative to the reported
+// tokens; a positive mean error means the estimates were too high.
+type AccuracySummary struct {
+	Provider          string
+	Model             string // empty in the summary of a provider
+	Calls             int
+	EstimatedTokens   int
+	ActualTokens      int
+	MeanError         float64
+	MeanAbsoluteError float64
+	P50AbsoluteError  float64
+	P90AbsoluteError  float64
+	P99AbsoluteError  float64
+}
+
+// AccuracyReport compares estimated and reported input tokens of recorded usage
+type AccuracyReport struct {
+	AccuracySummary // over all calls, with empty Provider and Model
+
+	// Providers and Models are sorted by mean absolute error, worst first
+	Providers []AccuracySummary
+	Models    []AccuracySummary
+}
+
+// AccuracyReport summarizes the error of input token estimates against the usage
+// providers reported, per provider and model, from the recorded usage matching filter.
+// Only calls tracked with WithAccuracyTracking or WithCalibration carry an estimate.
+func (t *DefaultTokenTracker) AccuracyReport(filter UsageFilter) (AccuracyReport, error) {
+	records, err := t.GetUsage(filter)
+	if err != nil {
+		return AccuracyReport{}, err
+	}
+	return NewAccuracyReport(records), nil
+}
+
+// NewAccuracyReport builds an accuracy report from usage records, skipping records
+// without an estimate
+func NewAccuracyReport(records []UsageMetrics) AccuracyReport {
+	all := &accuracyErrors{}
+	providers := make(map[string]*accuracyErrors)
+	models := make(map[metricLabels]*accuracyErrors)
+
+	for _, record := range records {
+		estimated, actual := record.TokenCount.EstimatedInputTokens, record.TokenCount.InputTokens
+		if estimated <= 0 || actual <= 0 {
+			continue
+		}
+
+		labels := metricLabels{provider: record.Provider, model: record.Model}
+		if models[labels] == nil {
+			models[labels] = &accuracyErrors{}
+		}
+		if providers[record.Provider] == nil {
+			providers[record.Provider] = &accuracyErrors{}
+		}
+		for _, errs := range []*accuracyErrors{all, providers[record.Provider], models[labels]} {
+			errs.add(estimated, actual)
+		}
+	}
+
+	report := AccuracyReport{AccuracySummary: all.summary("", "")}
+	for provider, errs := range providers {
+		report.Providers = append(report.Providers, errs.summary(provider, ""))
+	}
+	for labels, errs := range models {
+		report.Models = append(report.Models, errs.summary(labels.provider, labels.model))
+	}
+	sortAccuracy(report.Providers)
+	sortAccuracy(report.Models)
+	return report
+}
+
+// sortAccuracy sorts summaries by mean absolute error, worst first
+func sortAccuracy(summaries []AccuracySummary) {
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].MeanAbsoluteError != summaries[j].MeanAbsoluteError {
+			return summaries[i].MeanAbsoluteError > summaries[j].MeanAbsoluteError
+		}
+		if summaries[i].Provider != summaries[j].Provider {
+			return summaries[i].Provider < summaries[j].Provider
+		}
+		return summaries[i].Model < summaries[j].Model
+	})
+}
+
+// accuracyErrors collects the relative errors of estimates
+type accuracyErrors struct {
+	estimated, actual int
+	errors            []float64
+}
+
+// add records an estimate and the reported tokens
+func (e *accuracyErrors) add(estimated, actual int) {
+	e.estimated += estimated
+	e.actual += actual
+	e.errors = append(e.errors, float64(estimated-actual)/float64(actual))
+}
+
+// summary summarizes the collected errors
+func (e *accuracyErrors) summary(provider, model string) AccuracySummary {
+	summary := AccuracySummary{
+		Provider:        provider,
+		Model:           model,
+		Calls:           len(e.errors),
+		EstimatedTokens: e.estimated,
+		ActualTokens:    e.actual,
+	}
+	if len(e.errors) == 0 {
+		return summary
+	}
+
+	absolute := make([]float64, len(e.errors))
+	var sum, sumAbsolute float64
+	for i, err := range e.errors {
+		absolute[i] = math.Abs(err)
+		sum += err
+		sumAbsolute += absolute[i]
+	}
+	sort.Float64s(absolute)
+
+	summary.MeanError = sum / float64(len(e.errors))
+	summary.MeanAbsoluteError = sumAbsolute / float64(len(e.errors))
+	summary.P50AbsoluteError = nearestRank(absolute, 0.50)
+	summary.P90AbsoluteError = nearestRank(absolute, 0.90)
+	summary.P99AbsoluteError = nearestRank(absolute, 0.99)
+	return summary
+}
+
+// nearestRank returns the p-th percentile of sorted values
+func nearestRank(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
+
+// estimateReported counts the prompt of a call whose usage the provider reported,
+// recording the estimate in count and observing it for calibration. The reported usage
+// makes a count_tokens request unnecessary, so the prompt is counted locally.
+func (t *DefaultTokenTracker) estimateReported(ctx context.Context, provider Provider, callParams CallParams, count TokenCount) TokenCount {
+	params := callParams.Params
+	if params.Text == nil && len(params.Messages) == 0 {
+		return count
+	}
+	params.Model = callParams.Model
+	params.CountResponseTokens = false
+
+	estimate, err := CountTokensWithContext(ContextWithLocalCounting(ctx), provider, params)
+	if err != nil {
+		t.logger().Debug("failed to estimate tokens of a reported call", "provider", provider.Name(), "model", callParams.Model, "error", err)
+		return count
+	}
+
+	count.EstimatedInputTokens = estimate.InputTokens
+	if t.calibration != nil {
+		count.EstimatedInputTokens = t.calibration.apply(callParams.Model, estimate).InputTokens
+		t.calibrate(provider, callParams.Model, estimate.InputTokens, count.InputTokens)
+	}
+	return count
+}
@@ -0,0 +1,75 @@
+package tokentracker
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDefaultTokenTracker_AccuracyReport(t *testing.T) {
+	tracker := newPricedTestTracker(t, WithAccuracyTracking(), WithUsageStore(NewMemoryUsageStore()))
+	tracker.Config().SetModelPricing("acme", "acme-1", ModelPricing{InputPricePerToken: 0.00001, Currency: "USD"})
+	tracker.RegisterProvider(&overestimatingProvider{estimatingProvider{
+		usagePricedProvider: newPricedProvider(tracker.Config(), "acme", "acme-1"),
+		count:               TokenCount{InputTokens: 1000},
+	}})
+
+	call := CallParams{Model: "acme-1", Params: TokenCountParams{Text: stringPtr("Summarize this document")}}
+	for _, actual := range []int{800, 1000, 1250} {
+		metrics, err := tracker.TrackUsage(call, TokenCount{InputTokens: actual, ResponseTokens: 10})
+		if err != nil {
+			t.Fatalf("TrackUsage() error = %v", err)
+		}
+		if metrics.TokenCount.EstimatedInputTokens != 1000 {
+			t.Errorf("EstimatedInputTokens = %d, want 1000", metrics.TokenCount.EstimatedInputTokens)
+		}
+	}
+	// Calls without a prompt carry no estimate
+	_, _ = tracker.TrackUsage(CallParams{Model: "acme-1"}, TokenCount{InputTokens: 500})
+
+	report, err := tracker.AccuracyReport(UsageFilter{})
+	if err != nil {
+		t.Fatalf("AccuracyReport() error = %v", err)
+	}
+	if report.Calls != 3 || report.EstimatedTokens != 3000 || report.ActualTokens != 3050 {
+		t.Errorf("report = %+v, want 3 calls", report.AccuracySummary)
+	}
+	if want := (0.25 + 0 - 0.2) / 3; math.Abs(report.MeanError-want) > 1e-9 {
+		t.Errorf("MeanError = %v, want %v", report.MeanError, want)
+	}
+	if want := (0.25 + 0 + 0.2) / 3; math.Abs(report.MeanAbsoluteError-want) > 1e-9 {
+		t.Errorf("MeanAbsoluteError = %v, want %v", report.MeanAbsoluteError, want)
+	}
+	if report.P50AbsoluteError != 0.2 || report.P99AbsoluteError != 0.25 {
+		t.Errorf("percentiles = %v, %v, want 0.2 and 0.25", report.P50AbsoluteError, report.P99AbsoluteError)
+	}
+	if len(report.Providers) != 1 || report.Providers[0].Provider != "acme" || report.Providers[0].Model != "" {
+		t.Errorf("Providers = %+v", report.Providers)
+	}
+	if len(report.Models) != 1 || report.Models[0].Model != "acme-1" || report.Models[0].Calls != 3 {
+		t.Errorf("Models = %+v", report.Models)
+	}
+}
+
+func TestNewAccuracyReport(t *testing.T) {
+	records := []UsageMetrics{
+		{Provider: "acme", Model: "acme-1", TokenCount: TokenCount{InputTokens: 100, EstimatedInputTokens: 118}},
+		{Provider: "acme", Model: "acme-2", TokenCount: TokenCount{InputTokens: 100, EstimatedInputTokens: 101}},
+		{Provider: "zeta", Model: "zeta-1", TokenCount: TokenCount{InputTokens: 100, EstimatedInputTokens: 150}},
+		{Provider: "zeta", Model: "zeta-1", TokenCount: TokenCount{InputTokens: 100}},
+	}
+
+	report := NewAccuracyReport(records)
+	if report.Calls != 3 {
+		t.Errorf("Calls = %d, want 3", report.Calls)
+	}
+	if len(report.Models) != 3 || report.Models[0].Model != "zeta-1" || report.Models[2].Model != "acme-2" {
+		t.Errorf("Models = %+v, want the worst first", report.Models)
+	}
+	if len(report.Providers) != 2 || report.Providers[0].Provider != "zeta" || math.Abs(report.Providers[1].MeanError-0.095) > 1e-9 {
+		t.Errorf("Providers = %+v", report.Providers)
+	}
+
+	if empty := NewAccuracyReport(nil); empty.Calls != 0 || len(empty.Models) != 0 {
+		t.Errorf("NewAccuracyReport(nil) = %+v, want an empty report", empty)
+	}
+}
@@ -0,0 +1,280 @@
+package tokentracker
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Default settings of an AnomalyDetector
+const (
+	DefaultAnomalyInterval  = time.Minute
+	DefaultAnomalyAlpha     = 0.1
+	DefaultAnomalyThreshold = 3.0
+	DefaultAnomalyWarmup    = 10
+)
+
+// maxAnomalyIdleIntervals bounds the empty intervals folded into a baseline after a pause
+const maxAnomalyIdleIntervals = 1000
+
+// AnomalyMetric is a usage rate watched for anomalies
+type AnomalyMetric string
+
+// Watched usage rates
+const (
+	AnomalyCost   AnomalyMetric = "cost"   // cost per interval
+	AnomalyTokens AnomalyMetric = "tokens" // tokens per interval
+)
+
+// AnomalyConfig configures an AnomalyDetector
+type AnomalyConfig struct {
+	// Interval is the period usage rates are measured over (0 uses DefaultAnomalyInterval)
+	Interval time.Duration
+
+	// GroupByTag keeps a baseline per value of this tag in addition to the model, e.g.
+	// "agent" (empty keeps one baseline per model)
+	GroupByTag string
+
+	// Alpha is the weight of the latest interval in the moving average and variance of
+	// a baseline (0 uses DefaultAnomalyAlpha)
+	Alpha float64
+
+	// Threshold is the z-score, in standard deviations above the moving average, beyond
+	// which a rate is anomalous (0 uses DefaultAnomalyThreshold)
+	Threshold float64
+
+	// Warmup is the number of intervals a baseline learns from before it reports
+	// anomalies (0 uses DefaultAnomalyWarmup)
+	Warmup int
+
+	// MinCost and MinTokens are the rates below which no anomaly is reported, so that
+	// quiet models do not alert on small spikes
+	MinCost   float64
+	MinTokens int
+}
+
+// AnomalyEvent is passed to anomaly callbacks when a usage rate spikes
+type AnomalyEvent struct {
+	Model  string
+	Tag    string // value of the GroupByTag tag ("" without grouping)
+	Metric AnomalyMetric
+
+	Value  float64 // usage in the current interval so far
+	Mean   float64 // moving average of the previous intervals
+	StdDev float64 // moving standard deviation of the previous intervals
+	ZScore float64 // standard deviations Value is above Mean (+Inf for a flat baseline)
+
+	Start   time.Time    // start of the current interval
+	Trigger UsageMetrics // the call that made the rate anomalous
+}
+
+// anomalyBaseline is the moving average and variance of one usage rate
+type anomalyBaseline struct {
+	mean     float64
+	variance float64
+	current  float64
+	reported bool
+}
+
+// add folds a completed interval into the baseline; the first interval starts it
+func (b *anomalyBaseline) add(value, alpha float64, first bool) {
+	if first {
+		b.mean = value
+		return
+	}
+	diff := value - b.mean
+	increment := alpha * diff
+	b.mean += increment
+	b.variance = (1 - alpha) * (b.variance + diff*increment)
+}
+
+// zScore returns the standard deviations the current interval is above the mean
+func (b *anomalyBaseline) zScore() float64 {
+	deviation := b.current - b.mean
+	if stdDev := math.Sqrt(b.variance); stdDev > 0 {
+		return deviation / stdDev
+	}
+	if deviation > 0 {
+		return math.Inf(1)
+	}
+	return 0
+}
+
+// anomalyGroup is the usage of one model and tag value
+type anomalyGroup struct {
+	model, tag string
+	start      time.Time
+	intervals  int
+	cost       anomalyBaseline
+	tokens     anomalyBaseline
+}
+
+// AnomalyDetector watches the cost and token rates of tracked usage per model, and
+// optionally per tag, for spikes such as runaway agents or prompt-injection loops. The
+// rates of each interval are compared with an exponentially weighted moving average
+// and variance of the previous intervals; a rate more than Threshold standard
+// deviations above the average fires the callbacks once per interval, while the
+// interval is still running. Register it with WithUsageObserver. It is safe for
+// concurrent use.
+type AnomalyDetector struct {
+	config    AnomalyConfig
+	clock     Clock
+	groups    map[[2]string]*anomalyGroup
+	listeners []func(AnomalyEvent)
+	mu        sync.Mutex
+}
+
+// NewAnomalyDetector creates an anomaly detector using the given clock for intervals
+// (nil uses the system clock)
+func NewAnomalyDetector(config AnomalyConfig, clock Clock) (*AnomalyDetector, error) {
+	if config.Interval < 0 || config.Alpha < 0 || config.Alpha > 1 || config.Threshold < 0 || config.Warmup < 0 || config.MinCost < 0 || config.MinTokens < 0 {
+		return nil, NewError(ErrInvalidParams, "anomaly settings must not be negative and alpha must not exceed 1", nil)
+	}
+	if config.Interval == 0 {
+		config.Interval = DefaultAnomalyInterval
+	}
+	if config.Alpha == 0 {
+		config.Alpha = DefaultAnomalyAlpha
+	}
+	if config.Threshold == 0 {
+		config.Threshold = DefaultAnomalyThreshold
+	}
+	if config.Warmup == 0 {
+		config.Warmup = DefaultAnomalyWarmup
+	}
+	if clock == nil {
+		clock = SystemClock
+	}
+	return &AnomalyDetector{
+		config: config,
+		clock:  clock,
+		groups: make(map[[2]string]*anomalyGroup),
+	}, nil
+}
+
+// OnAnomaly registers a callback invoked when a usage rate spikes. Callbacks run
+// synchronously on the goroutine that tracked the call.
+func (d *AnomalyDetector) OnAnomaly(callback func(AnomalyEvent)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.listeners = append(d.listeners, callback)
+}
+
+// ObserveUsage adds tracked usage to the rates of its model and tag
+func (d *AnomalyDetector) ObserveUsage(metrics UsageMetrics) {
+	now := d.clock.Now()
+	start := now.Truncate(d.config.Interval)
+
+	d.mu.Lock()
+	key := [2]string{metrics.Model, ""}
+	if d.config.GroupByTag != "" {
+		key[1] = metrics.Tags[d.config.GroupByTag]
+	}
+	group, exists := d.groups[key]
+	if !exists {
+		group = &anomalyGroup{model: key[0], tag: key[1], start: start}
+		d.groups[key] = group
+	}
+	d.roll(group, start)
+
+	group.cost.current += metrics.Price.TotalCost
+	group.tokens.current += float64(metrics.TokenCount.TotalTokens)
+
+	var events []AnomalyEvent
+	if group.intervals >= d.config.Warmup {
+		if event, ok := d.check(group, &group.cost, AnomalyCost, d.config.MinCost, metrics); ok {
+			events = append(events, event)
+		}
+		if event, ok := d.check(group, &group.tokens, AnomalyTokens, float64(d.config.MinTokens), metrics); ok {
+			events = append(events, event)
+		}
+	}
+	listeners := d.listeners
+	d.mu.Unlock()
+
+	for _, event := range events {
+		for _, listener := range listeners {
+			listener(event)
+		}
+	}
+}
+
+// roll folds the intervals that ended before start, including idle ones, into the
+// baselines of a group
+func (d *AnomalyDetector) roll(group *anomalyGroup, start time.Time) {
+	if !start.After(group.start) {
+		return
+	}
+	elapsed := int(start.Sub(group.start) / d.config.Interval)
+	for i := 0; i < elapsed && i < maxAnomalyIdleIntervals; i++ {
+		group.cost.add(group.cost.current, d.config.Alpha, group.intervals == 0)
+		group.tokens.add(group.tokens.current, d.config.Alpha, group.intervals == 0)
+		group.cost.current, group.tokens.current = 0, 0
+		group.intervals++
+	}
+	group.cost.reported, group.tokens.reported = false, false
+	group.start = start
+}
+
+// check returns the event of a rate that became anomalous
+func (d *AnomalyDetector) check(group *anomalyGroup, baseline *anomalyBaseline, metric AnomalyMetric, minimum float64, trigger UsageMetrics) (AnomalyEvent, bool) {
+	if baseline.reported || baseline.current < minimum {
+		return AnomalyEvent{}, false
+	}
+	z := baseline.zScore()
+	if z <= d.config.Threshold {
+		return AnomalyEvent{}, false
+	}
+	baseline.reported = true
+	return AnomalyEvent{
+		Model:   group.model,
+		Tag:     group.tag,
+		Metric:  metric,
+		Value:   baseline.current,
+		Mean:    baseline.mean,
+		StdDev:  math.Sqrt(baseline.variance),
+		ZScore:  z,
+		Start:   group.start,
+		Trigger: trigger,
+	}, true
+}
+
+// AnomalyBaseline is the learned usage rate of a model and tag
+type AnomalyBaseline struct {
+	Model     string
+	Tag       string
+	Intervals int // completed intervals learned from
+
+	CostMean, CostStdDev     float64
+	TokensMean, TokensStdDev float64
+}
+
+// Baselines returns the learned rates, ordered by model and tag
+func (d *AnomalyDetector) Baselines() []AnomalyBaseline {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	start := d.clock.Now().Truncate(d.config.Interval)
+	baselines := make([]AnomalyBaseline, 0, len(d.groups))
+	for _, group := range d.groups {
+		d.roll(group, start)
+		baselines = append(baselines, AnomalyBaseline{
+			Model:        group.model,
+			Tag:          group.tag,
+			Intervals:    group.intervals,
+			CostMean:     group.cost.mean,
+			CostStdDev:   math.Sqrt(group.cost.variance),
+			TokensMean:   group.tokens.mean,
+			TokensStdDev: math.Sqrt(group.tokens.variance),
+		})
+	}
+	sort.Slice(baselines, func(i, j int) bool {
+		if baselines[i].Model != baselines[j].Model {
+			return baselines[i].Model < baselines[j].Model
+		}
+		return baselines[i].Tag < baselines[j].Tag
+	})
+	return baselines
+}
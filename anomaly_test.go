@@ -0,0 +1,94 @@
+package tokentracker
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker/tokentrackertest"
+)
+
+func TestAnomalyDetector_DetectsSpikes(t *testing.T) {
+	clock := tokentrackertest.NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	detector, err := NewAnomalyDetector(AnomalyConfig{GroupByTag: "agent", Warmup: 5, MinTokens: 100}, clock)
+	if err != nil {
+		t.Fatalf("NewAnomalyDetector() error = %v", err)
+	}
+	var events []AnomalyEvent
+	detector.OnAnomaly(func(event AnomalyEvent) { events = append(events, event) })
+
+	call := func(agent string, tokens int) {
+		detector.ObserveUsage(UsageMetrics{
+			Model:      "gpt-4o",
+			Tags:       map[string]string{"agent": agent},
+			TokenCount: TokenCount{TotalTokens: tokens},
+			Price:      Price{TotalCost: float64(tokens) * 0.00001},
+		})
+	}
+
+	// A steady rate of 900 to 1100 tokens a minute
+	for i := 0; i < 10; i++ {
+		call("planner", 900+i%3*100)
+		clock.Advance(time.Minute)
+	}
+	if len(events) != 0 {
+		t.Fatalf("events = %+v, want none at a steady rate", events)
+	}
+
+	// A runaway loop is reported while its interval is still running, once per metric
+	for i := 0; i < 10; i++ {
+		call("planner", 1000)
+	}
+	if len(events) != 2 {
+		t.Fatalf("events = %+v, want a cost and a token anomaly", events)
+	}
+	event := events[1]
+	if event.Metric != AnomalyTokens || event.Tag != "planner" || event.Model != "gpt-4o" || event.ZScore <= DefaultAnomalyThreshold {
+		t.Errorf("event = %+v", event)
+	}
+	if math.Abs(event.Mean-1000) > 100 || event.Value < 2000 {
+		t.Errorf("event = %+v, want a mean near 1000 and the spiking value", event)
+	}
+
+	// Other tags have baselines of their own that are still warming up
+	call("writer", 50000)
+	if len(events) != 2 {
+		t.Errorf("events = %d, want none for a new tag", len(events))
+	}
+
+	baselines := detector.Baselines()
+	if len(baselines) != 2 || baselines[0].Tag != "planner" || baselines[0].Intervals != 10 {
+		t.Errorf("Baselines() = %+v", baselines)
+	}
+}
+
+func TestAnomalyDetector_IdleIntervalsAndConfig(t *testing.T) {
+	clock := tokentrackertest.NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	detector, _ := NewAnomalyDetector(AnomalyConfig{Interval: time.Hour, Warmup: 1}, clock)
+
+	detector.ObserveUsage(UsageMetrics{Model: "m", TokenCount: TokenCount{TotalTokens: 100}})
+	clock.Advance(5 * time.Hour)
+	baselines := detector.Baselines()
+	// One interval of 100 tokens followed by four idle ones: 100 * 0.9^4
+	if baselines[0].Intervals != 5 || math.Abs(baselines[0].TokensMean-65.61) > 1e-9 {
+		t.Errorf("Baselines() = %+v, want idle intervals folded in", baselines)
+	}
+
+	if _, err := NewAnomalyDetector(AnomalyConfig{Alpha: 2}, nil); err == nil {
+		t.Error("NewAnomalyDetector() with alpha above 1 should fail")
+	}
+}
+
+func TestAnomalyDetector_AsUsageObserver(t *testing.T) {
+	detector, _ := NewAnomalyDetector(AnomalyConfig{}, nil)
+	tracker := NewTokenTracker(NewConfig(), WithUsageObserver(detector))
+	tracker.RegisterProvider(&MockProvider{name: "openai", supportedModel: "gpt-4o", price: Price{TotalCost: 0.01}})
+
+	if _, err := tracker.TrackReportedUsage(context.Background(), CallParams{Model: "gpt-4o"}, TokenCount{InputTokens: 10}); err != nil {
+		t.Fatalf("TrackReportedUsage() error = %v", err)
+	}
+	if baselines := detector.Baselines(); len(baselines) != 1 || baselines[0].Model != "gpt-4o" {
+		t.Errorf("Baselines() = %+v, want the tracked call observed", baselines)
+	}
+}
@@ -0,0 +1,226 @@
+package tokentracker
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Default asynchronous tracking settings
+const (
+	DefaultAsyncWorkers   = 4
+	DefaultAsyncQueueSize = 1024
+)
+
+// AsyncOptions configures the worker pool that tracks usage queued by TrackUsageAsync
+type AsyncOptions struct {
+	// Workers is the number of calls tracked concurrently (0 uses the default)
+	Workers int
+
+	// QueueSize is the number of calls queued before TrackUsageAsync blocks (0 uses the default)
+	QueueSize int
+
+	// OnError is called with the calls that failed to be tracked (nil drops the errors)
+	OnError func(callParams CallParams, err error)
+}
+
+// WithAsyncTracking configures the worker pool of TrackUsageAsync. Without this
+// option the pool is started with the default settings on first use.
+func WithAsyncTracking(opts AsyncOptions) TrackerOption {
+	return func(t *DefaultTokenTracker) {
+		t.async = newAsyncTracker(t, opts)
+	}
+}
+
+// TrackUsageAsync queues an LLM call to be tracked by a background worker, keeping
+// token counting, pricing and recording out of the request path. It blocks only while
+// the queue is full. The response must not be modified after the call is queued.
+func (t *DefaultTokenTracker) TrackUsageAsync(callParams CallParams, response interface{}) error {
+	return t.TrackUsageAsyncCtx(context.Background(), callParams, response)
+}
+
+// TrackUsageAsyncCtx queues an LLM call for tracking, giving up with the error of ctx
+// when it is done while the queue is full. The call is tracked with the trace context
+// of ctx, but is not canceled with it.
+func (t *DefaultTokenTracker) TrackUsageAsyncCtx(ctx context.Context, callParams CallParams, response interface{}) error {
+	if callParams.Model == "" {
+		return NewError(ErrInvalidParams, "model is required", nil)
+	}
+
+	call := asyncCall{
+		ctx:      context.WithValue(context.WithoutCancel(ctx), callEndKey{}, t.clock().Now()),
+		params:   callParams,
+		response: response,
+	}
+	return t.asyncTracker().enqueue(ctx, call)
+}
+
+// Flush waits until all calls queued by TrackUsageAsync have been tracked, delivers
+// the usage queued in the sinks, flushes the usage store and writes the usage log to disk
+func (t *DefaultTokenTracker) Flush() error {
+	return t.FlushCtx(context.Background())
+}
+
+// FlushCtx flushes like Flush, returning the error of ctx when it is done first
+func (t *DefaultTokenTracker) FlushCtx(ctx context.Context) error {
+	t.mu.RLock()
+	async, logger := t.async, t.usageLog
+	t.mu.RUnlock()
+
+	if async != nil {
+		if err := waitCtx(ctx, async.wait); err != nil {
+			return err
+		}
+	}
+	sinkErr := t.flushSinks(ctx)
+	storeErr := t.flushStore(ctx)
+	if logger != nil {
+		if err := logger.Flush(); err != nil {
+			return err
+		}
+	}
+	if sinkErr != nil {
+		return sinkErr
+	}
+	return storeErr
+}
+
+// asyncTracker returns the worker pool of TrackUsageAsync, starting it on first use
+func (t *DefaultTokenTracker) asyncTracker() *asyncTracker {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.async == nil {
+		t.async = newAsyncTracker(t, AsyncOptions{})
+	}
+	return t.async
+}
+
+// callEndKey is the context key of the time a call tracked asynchronously completed
+type callEndKey struct{}
+
+// callEnd returns the time the tracked call completed: when it was queued for
+// asynchronous tracking, or now
+func callEnd(ctx context.Context, clock Clock) time.Time {
+	if end, ok := ctx.Value(callEndKey{}).(time.Time); ok {
+		return end
+	}
+	return clock.Now()
+}
+
+// asyncCall is a call queued for tracking
+type asyncCall struct {
+	ctx      context.Context
+	params   CallParams
+	response interface{}
+}
+
+// asyncTracker tracks queued calls with a fixed number of workers
+type asyncTracker struct {
+	tracker *DefaultTokenTracker
+	onError func(callParams CallParams, err error)
+	queue   chan asyncCall
+	workers sync.WaitGroup
+
+	// pending counts the calls queued or being queued and not yet tracked
+	pending int
+	idle    *sync.Cond
+	closed  bool
+	mu      sync.Mutex
+}
+
+// newAsyncTracker starts the workers of a pool
+func newAsyncTracker(tracker *DefaultTokenTracker, opts AsyncOptions) *asyncTracker {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = DefaultAsyncWorkers
+	}
+	queueSize := opts.QueueSize
+	if queueSize <= 0 {
+		queueSize = DefaultAsyncQueueSize
+	}
+
+	a := &asyncTracker{
+		tracker: tracker,
+		onError: opts.OnError,
+		queue:   make(chan asyncCall, queueSize),
+	}
+	a.idle = sync.NewCond(&a.mu)
+
+	a.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go a.run()
+	}
+	return a
+}
+
+// enqueue queues a call, blocking while the queue is full
+func (a *asyncTracker) enqueue(ctx context.Context, call asyncCall) error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return NewError(ErrTrackerClosed, "tracker is closed", nil)
+	}
+	a.pending++
+	a.mu.Unlock()
+
+	select {
+	case a.queue <- call:
+		return nil
+	case <-ctx.Done():
+		a.done()
+		return ctx.Err()
+	}
+}
+
+// run is the worker loop
+func (a *asyncTracker) run() {
+	defer a.workers.Done()
+
+	for call := range a.queue {
+		if _, err := a.tracker.TrackUsageCtx(call.ctx, call.params, call.response); err != nil && a.onError != nil {
+			a.onError(call.params, err)
+		}
+		a.done()
+	}
+}
+
+// done marks a pending call as finished
+func (a *asyncTracker) done() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.pending--
+	if a.pending == 0 {
+		a.idle.Broadcast()
+	}
+}
+
+// wait blocks until no calls are pending
+func (a *asyncTracker) wait() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for a.pending > 0 {
+		a.idle.Wait()
+	}
+}
+
+// close rejects new calls, tracks the queued ones and stops the workers
+func (a *asyncTracker) close() {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		a.workers.Wait()
+		return
+	}
+	a.closed = true
+	for a.pending > 0 {
+		a.idle.Wait()
+	}
+	a.mu.Unlock()
+
+	// No call can be queued any more
+	close(a.queue)
+	a.workers.Wait()
+}
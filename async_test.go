@@ -0,0 +1,125 @@
+package tokentracker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker/tokentrackertest"
+)
+
+// blockingProvider is a MockProvider whose counting waits until release is closed
+type blockingProvider struct {
+	MockProvider
+	release chan struct{}
+}
+
+func (p *blockingProvider) CountTokens(params TokenCountParams) (TokenCount, error) {
+	<-p.release
+	return p.MockProvider.CountTokens(params)
+}
+
+func newAsyncTestTracker(opts AsyncOptions, clock Clock) (*DefaultTokenTracker, *blockingProvider, *MemoryUsageStore) {
+	provider := &blockingProvider{
+		MockProvider: MockProvider{
+			name:           "mock",
+			supportedModel: "mock-model",
+			tokenCount:     TokenCount{InputTokens: 10, ResponseTokens: 5, TotalTokens: 15},
+			price:          Price{TotalCost: 0.01, Currency: "USD"},
+		},
+		release: make(chan struct{}),
+	}
+	store := NewMemoryUsageStore()
+	tracker := NewTokenTracker(NewConfig(), WithUsageStore(store), WithClock(clock), WithAsyncTracking(opts))
+	tracker.RegisterProvider(provider)
+	return tracker, provider, store
+}
+
+func TestDefaultTokenTracker_TrackUsageAsync(t *testing.T) {
+	clock := tokentrackertest.NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	tracker, provider, store := newAsyncTestTracker(AsyncOptions{Workers: 2}, clock)
+
+	start := clock.Now().Add(-2 * time.Second)
+	params := CallParams{Model: "mock-model", Params: TokenCountParams{Text: stringPtr("Hello")}, StartTime: start}
+	for i := 0; i < 10; i++ {
+		if err := tracker.TrackUsageAsync(params, nil); err != nil {
+			t.Fatalf("TrackUsageAsync() error = %v", err)
+		}
+	}
+
+	// The call is timed when it is queued, not when it is tracked
+	clock.Advance(time.Minute)
+	close(provider.release)
+
+	if err := tracker.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	records, _ := store.Query(UsageFilter{})
+	if len(records) != 10 {
+		t.Fatalf("tracked %d calls, want 10 after Flush", len(records))
+	}
+	if records[0].Duration != 2*time.Second || !records[0].Timestamp.Equal(start.Add(2*time.Second)) {
+		t.Errorf("Duration = %v at %v, want the time until the call was queued", records[0].Duration, records[0].Timestamp)
+	}
+
+	if err := tracker.TrackUsageAsync(CallParams{}, nil); err == nil {
+		t.Error("TrackUsageAsync() without a model should fail")
+	}
+}
+
+func TestDefaultTokenTracker_TrackUsageAsyncBackpressure(t *testing.T) {
+	var mu sync.Mutex
+	var failed []error
+	tracker, provider, store := newAsyncTestTracker(AsyncOptions{
+		Workers:   1,
+		QueueSize: 1,
+		OnError: func(callParams CallParams, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			failed = append(failed, err)
+		},
+	}, SystemClock)
+
+	params := CallParams{Model: "mock-model", Params: TokenCountParams{Text: stringPtr("Hello")}}
+
+	// One call is being tracked and one is queued, so the queue is full
+	_ = tracker.TrackUsageAsync(params, nil)
+	_ = tracker.TrackUsageAsync(params, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	for i := 0; i < 2; i++ {
+		if err := tracker.TrackUsageAsyncCtx(ctx, params, nil); err != nil {
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("TrackUsageAsyncCtx() error = %v, want the deadline", err)
+			}
+			break
+		}
+		if i == 1 {
+			t.Fatal("TrackUsageAsyncCtx() should block while the queue is full")
+		}
+	}
+
+	// A call to a model without a provider fails in the background
+	close(provider.release)
+	_ = tracker.TrackUsageAsync(CallParams{Model: "unknown"}, nil)
+
+	if err := tracker.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	records, _ := store.Query(UsageFilter{})
+	if len(records) < 2 || len(records) > 3 {
+		t.Errorf("tracked %d calls, want the queued calls drained by Close", len(records))
+	}
+	mu.Lock()
+	if len(failed) != 1 {
+		t.Errorf("OnError() called with %v, want the call without a provider", failed)
+	}
+	mu.Unlock()
+
+	var trackerErr *TokenTrackerError
+	if err := tracker.TrackUsageAsync(params, nil); !errors.As(err, &trackerErr) || trackerErr.Type != ErrTrackerClosed {
+		t.Errorf("TrackUsageAsync() after Close() = %v, want %s", err, ErrTrackerClosed)
+	}
+}
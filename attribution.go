@@ -0,0 +1,51 @@
+package tokentracker
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/TrustSight-io/tokentracker/common"
+)
+
+// Response headers naming the organization and project OpenAI billed a call to
+const (
+	OpenAIOrganizationHeader = common.OpenAIOrganizationHeader
+	OpenAIProjectHeader      = common.OpenAIProjectHeader
+)
+
+// APIKeyID returns a non-secret identifier of an API key in the redacted form provider
+// consoles list keys in, e.g. "sk-...abcd" (empty for keys too short to redact). Set it
+// as the APIKeyID of CallParams to reconcile usage per key with billing exports.
+func APIKeyID(apiKey string) string {
+	return common.APIKeyID(apiKey)
+}
+
+// apiKeyHeaders are the request headers Anthropic, Gemini and Azure OpenAI take API keys in
+var apiKeyHeaders = []string{"X-Api-Key", "X-Goog-Api-Key", "Api-Key"}
+
+// attributeCall fills in the API key of an HTTP request and the organization and
+// project of its response, keeping values already set
+func attributeCall(callParams *CallParams, request, response http.Header) {
+	if callParams.APIKeyID == "" {
+		callParams.APIKeyID = requestAPIKeyID(request)
+	}
+	if organization := response.Get(OpenAIOrganizationHeader); organization != "" && callParams.Organization == "" {
+		callParams.Organization = organization
+	}
+	if project := response.Get(OpenAIProjectHeader); project != "" && callParams.ProviderProjectID == "" {
+		callParams.ProviderProjectID = project
+	}
+}
+
+// requestAPIKeyID returns the identifier of the API key a request authenticates with
+func requestAPIKeyID(header http.Header) string {
+	if key, ok := strings.CutPrefix(header.Get("Authorization"), "Bearer "); ok {
+		return APIKeyID(key)
+	}
+	for _, name := range apiKeyHeaders {
+		if key := header.Get(name); key != "" {
+			return APIKeyID(key)
+		}
+	}
+	return ""
+}
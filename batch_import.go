@@ -0,0 +1,199 @@
+package tokentracker
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// BatchResultsFormat identifies the format of a batch API results file
+type BatchResultsFormat string
+
+// Supported batch results formats
+const (
+	// BatchResultsOpenAI is the output file of an OpenAI batch: a JSON line per request
+	// with the response body
+	BatchResultsOpenAI BatchResultsFormat = "openai_batch_output"
+
+	// BatchResultsAnthropic is the results file of an Anthropic message batch: a JSON
+	// line per request with its result
+	BatchResultsAnthropic BatchResultsFormat = "anthropic_batch_results"
+)
+
+// Tags of imported batch requests
+const (
+	// ImportTagBatchID is the tag holding the batch a request was part of
+	ImportTagBatchID = "batch_id"
+
+	// ImportTagCustomID is the tag holding the custom_id a request was submitted with
+	ImportTagCustomID = "custom_id"
+)
+
+// BatchImportOptions controls how the requests of a batch are recorded
+type BatchImportOptions struct {
+	// BatchID tags every request with its batch (empty adds no tag)
+	BatchID string
+
+	// CompletedAt timestamps requests whose results carry no time, like Anthropic's
+	// (zero uses the tracker's clock)
+	CompletedAt time.Time
+
+	// Tags are added to every request
+	Tags map[string]string
+
+	// UserID and ProjectID attribute every request
+	UserID    string
+	ProjectID string
+}
+
+// openAIBatchLine is a line of an OpenAI batch output file
+type openAIBatchLine struct {
+	CustomID string `json:"custom_id"`
+	Response *struct {
+		StatusCode int                    `json:"status_code"`
+		Body       map[string]interface{} `json:"body"`
+	} `json:"response"`
+}
+
+// anthropicBatchLine is a line of an Anthropic message batch results file
+type anthropicBatchLine struct {
+	CustomID string `json:"custom_id"`
+	Result   struct {
+		Type    string                 `json:"type"`
+		Message map[string]interface{} `json:"message"`
+	} `json:"result"`
+}
+
+// ReadBatchResults parses the results file of a batch into usage records. The usage of
+// every succeeded request is extracted by the provider of its model and priced with
+// the model's batch discount. Requests that failed, were canceled or expired are
+// skipped, as they are not billed.
+func (t *DefaultTokenTracker) ReadBatchResults(format BatchResultsFormat, r io.Reader, opts BatchImportOptions) ([]UsageMetrics, error) {
+	if format != BatchResultsOpenAI && format != BatchResultsAnthropic {
+		return nil, NewError(ErrInvalidParams, fmt.Sprintf("unknown batch results format: %s", format), nil)
+	}
+
+	var records []UsageMetrics
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		customID, response, err := decodeBatchLine(format, scanner.Bytes())
+		if err != nil {
+			return nil, NewError(ErrInvalidParams, fmt.Sprintf("invalid batch result on line %d", line), err)
+		}
+		if response == nil {
+			continue
+		}
+
+		metrics, err := t.batchUsage(format, customID, response, opts)
+		if err != nil {
+			return nil, NewError(ErrInvalidParams, fmt.Sprintf("invalid batch result on line %d", line), err)
+		}
+		records = append(records, metrics)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, NewError(ErrInvalidParams, "failed to read batch results", err)
+	}
+	return records, nil
+}
+
+// ImportBatchResults parses the results file of a batch and bulk-inserts the usage of
+// its succeeded requests into the usage store. It returns the number of imported
+// records.
+func (t *DefaultTokenTracker) ImportBatchResults(format BatchResultsFormat, r io.Reader, opts BatchImportOptions) (int, error) {
+	store := t.UsageStore()
+	if store == nil {
+		return 0, NewError(ErrStorageFailed, "no usage store configured", nil)
+	}
+
+	records, err := t.ReadBatchResults(format, r, opts)
+	if err != nil {
+		return 0, err
+	}
+	return recordAll(store, t.scrubAll(records))
+}
+
+// decodeBatchLine returns the custom ID and response of a batch result line, or a
+// nil response when the request did not succeed
+func decodeBatchLine(format BatchResultsFormat, data []byte) (string, map[string]interface{}, error) {
+	if format == BatchResultsOpenAI {
+		var line openAIBatchLine
+		if err := json.Unmarshal(data, &line); err != nil {
+			return "", nil, err
+		}
+		if line.Response == nil || line.Response.StatusCode != 200 {
+			return line.CustomID, nil, nil
+		}
+		return line.CustomID, line.Response.Body, nil
+	}
+
+	var line anthropicBatchLine
+	if err := json.Unmarshal(data, &line); err != nil {
+		return "", nil, err
+	}
+	if line.Result.Type != "succeeded" {
+		return line.CustomID, nil, nil
+	}
+	return line.CustomID, line.Result.Message, nil
+}
+
+// batchUsage builds the usage record of a succeeded batch request from its response
+func (t *DefaultTokenTracker) batchUsage(format BatchResultsFormat, customID string, response map[string]interface{}, opts BatchImportOptions) (UsageMetrics, error) {
+	model, _ := response["model"].(string)
+	if model == "" {
+		return UsageMetrics{}, NewError(ErrInvalidParams, "response has no model", nil)
+	}
+	provider, exists := t.providerForModel(model)
+	if !exists {
+		return UsageMetrics{}, NewError(ErrProviderNotFound, fmt.Sprintf("no provider found for model: %s", model), nil)
+	}
+
+	count, err := provider.ExtractTokenUsageFromResponse(response)
+	if err != nil {
+		return UsageMetrics{}, err
+	}
+
+	timestamp := opts.CompletedAt
+	if created, ok := response["created"].(float64); ok && created > 0 {
+		timestamp = time.Unix(int64(created), 0).UTC()
+	}
+	if timestamp.IsZero() {
+		timestamp = t.clock().Now()
+	}
+
+	price, err := t.CalculateUsagePriceAt(model, billableUsage(count, true), timestamp)
+	if err != nil {
+		return UsageMetrics{}, err
+	}
+
+	count.TotalTokens = count.InputTokens + count.ResponseTokens
+	metrics := UsageMetrics{
+		TokenCount: count,
+		Price:      price,
+		Timestamp:  timestamp,
+		Model:      model,
+		Provider:   provider.Name(),
+		Tags:       copyTags(opts.Tags),
+		UserID:     opts.UserID,
+		ProjectID:  opts.ProjectID,
+	}
+	if id := responseID(response); id != "" {
+		metrics.CompletionID = metrics.Provider + ":" + id
+	}
+	if metrics.Tags == nil {
+		metrics.Tags = make(map[string]string)
+	}
+	metrics.Tags[ImportTagSource] = string(format)
+	setTag(metrics.Tags, ImportTagBatchID, opts.BatchID)
+	setTag(metrics.Tags, ImportTagCustomID, customID)
+	if tier, exists := t.config.CostTier(metrics.Provider, model); exists {
+		metrics.CostTier = string(tier)
+	}
+	return metrics, nil
+}
@@ -0,0 +1,106 @@
+package tokentracker
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker/tokentrackertest"
+)
+
+// jsonUsageProvider extracts the input_tokens and output_tokens of a decoded response
+type jsonUsageProvider struct {
+	usagePricedProvider
+}
+
+func (p *jsonUsageProvider) ExtractTokenUsageFromResponse(response interface{}) (TokenCount, error) {
+	usage, _ := response.(map[string]interface{})["usage"].(map[string]interface{})
+	input, _ := usage["input_tokens"].(float64)
+	output, _ := usage["output_tokens"].(float64)
+	return TokenCount{InputTokens: int(input), ResponseTokens: int(output)}, nil
+}
+
+func newBatchTracker(t *testing.T) (*DefaultTokenTracker, *MemoryUsageStore) {
+	store := NewMemoryUsageStore()
+	tracker := newPricedTestTracker(t, WithClock(tokentrackertest.NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))), WithUsageStore(store))
+	tracker.Config().SetModelPricing("acme", "acme-1", ModelPricing{InputPricePerToken: 0.00001, OutputPricePerToken: 0.00002, BatchDiscount: 0.5, Currency: "USD"})
+	tracker.RegisterProvider(&jsonUsageProvider{newPricedProvider(tracker.Config(), "acme", "acme-1")})
+	return tracker, store
+}
+
+func TestImportBatchResults_OpenAI(t *testing.T) {
+	tracker, store := newBatchTracker(t)
+	output := `{"id":"batch_req_1","custom_id":"request-1","response":{"status_code":200,"body":{"model":"acme-1","created":1714567200,"usage":{"input_tokens":1000,"output_tokens":500}}},"error":null}
+
+{"id":"batch_req_2","custom_id":"request-2","response":null,"error":{"code":"batch_expired","message":"expired"}}
+{"id":"batch_req_3","custom_id":"request-3","response":{"status_code":400,"body":{"error":{"message":"bad request"}}},"error":null}
+`
+	n, err := tracker.ImportBatchResults(BatchResultsOpenAI, strings.NewReader(output), BatchImportOptions{BatchID: "batch_abc", ProjectID: "nightly"})
+	if err != nil {
+		t.Fatalf("ImportBatchResults() error = %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("ImportBatchResults() = %d, want only the succeeded request", n)
+	}
+
+	records, _ := store.Query(UsageFilter{})
+	record := records[0]
+	// 1000 * 0.00001 + 500 * 0.00002 = 0.02, halved by the batch discount
+	if math.Abs(record.Price.TotalCost-0.01) > 1e-9 {
+		t.Errorf("TotalCost = %v, want 0.01 with the batch discount", record.Price.TotalCost)
+	}
+	if record.TokenCount.TotalTokens != 1500 || record.Provider != "acme" || record.ProjectID != "nightly" {
+		t.Errorf("record = %+v", record)
+	}
+	if !record.Timestamp.Equal(time.Unix(1714567200, 0)) {
+		t.Errorf("Timestamp = %v, want the response's created time", record.Timestamp)
+	}
+	if record.Tags[ImportTagBatchID] != "batch_abc" || record.Tags[ImportTagCustomID] != "request-1" || record.Tags[ImportTagSource] != string(BatchResultsOpenAI) {
+		t.Errorf("Tags = %v", record.Tags)
+	}
+}
+
+func TestImportBatchResults_Anthropic(t *testing.T) {
+	tracker, store := newBatchTracker(t)
+	completed := time.Date(2024, 4, 30, 8, 0, 0, 0, time.UTC)
+	results := `{"custom_id":"a","result":{"type":"succeeded","message":{"id":"msg_1","model":"acme-1","usage":{"input_tokens":200,"output_tokens":100}}}}
+{"custom_id":"b","result":{"type":"errored","error":{"type":"invalid_request"}}}
+{"custom_id":"c","result":{"type":"succeeded","message":{"id":"msg_2","model":"acme-1","usage":{"input_tokens":300,"output_tokens":50}}}}
+{"custom_id":"d","result":{"type":"canceled"}}
+`
+	n, err := tracker.ImportBatchResults(BatchResultsAnthropic, strings.NewReader(results), BatchImportOptions{CompletedAt: completed, Tags: map[string]string{"job": "tagging"}})
+	if err != nil {
+		t.Fatalf("ImportBatchResults() error = %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("ImportBatchResults() = %d, want 2", n)
+	}
+
+	records, _ := store.Query(UsageFilter{})
+	for _, record := range records {
+		if !record.Timestamp.Equal(completed) || record.Tags["job"] != "tagging" {
+			t.Errorf("record = %+v, want the completion time and tags", record)
+		}
+	}
+	if records[1].Tags[ImportTagCustomID] != "c" || records[1].TokenCount.InputTokens != 300 {
+		t.Errorf("second record = %+v", records[1])
+	}
+}
+
+func TestReadBatchResults_Errors(t *testing.T) {
+	tracker, _ := newBatchTracker(t)
+	if _, err := tracker.ReadBatchResults("xml", strings.NewReader(""), BatchImportOptions{}); err == nil {
+		t.Error("ReadBatchResults() of an unknown format should fail")
+	}
+	if _, err := tracker.ReadBatchResults(BatchResultsAnthropic, strings.NewReader("{not json"), BatchImportOptions{}); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("ReadBatchResults() error = %v, want the invalid line", err)
+	}
+	unknown := `{"custom_id":"a","result":{"type":"succeeded","message":{"model":"other-1","usage":{}}}}`
+	if _, err := tracker.ReadBatchResults(BatchResultsAnthropic, strings.NewReader(unknown), BatchImportOptions{}); err == nil {
+		t.Error("ReadBatchResults() of an unknown model should fail")
+	}
+	if _, err := NewTokenTracker(NewConfig()).ImportBatchResults(BatchResultsOpenAI, strings.NewReader(""), BatchImportOptions{}); err == nil {
+		t.Error("ImportBatchResults() without a store should fail")
+	}
+}
@@ -0,0 +1,307 @@
+package tokentracker
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BatchTask is one unit of work of a batch job, e.g. re-pricing a record or
+// counting the tokens of a prompt through a provider API
+type BatchTask struct {
+	// Key identifies the task in checkpoints; it must be unique within the job
+	Key string
+
+	// Provider selects the QPS cap applied to the task ("" is not rate limited)
+	Provider string
+
+	// Run performs the work
+	Run func(ctx context.Context) error
+}
+
+// BatchProgress reports how far a batch job has come
+type BatchProgress struct {
+	Total     int
+	Completed int // tasks that succeeded in this run
+	Skipped   int // tasks already done according to the checkpoint
+	Failed    int
+	Elapsed   time.Duration
+}
+
+// Done returns the number of tasks that are finished, successfully or not
+func (p BatchProgress) Done() int {
+	return p.Completed + p.Skipped + p.Failed
+}
+
+// BatchResult is the outcome of a batch job
+type BatchResult struct {
+	BatchProgress
+	Errors map[string]error // by task key
+}
+
+// Checkpoint remembers which tasks of a job have completed so an interrupted
+// job can be resumed without redoing them
+type Checkpoint interface {
+	// Done reports whether the task completed in an earlier run
+	Done(key string) bool
+
+	// MarkDone records that the task completed
+	MarkDone(key string) error
+}
+
+// BatchOptions controls the pacing of a BatchScheduler
+type BatchOptions struct {
+	// Concurrency is the number of tasks run in parallel (0 means 1)
+	Concurrency int
+
+	// ProviderQPS caps the number of tasks started per second for each provider
+	ProviderQPS map[string]float64
+
+	// Checkpoint skips completed tasks and records new completions (nil disables resuming)
+	Checkpoint Checkpoint
+
+	// OnProgress is called after every task; calls are serialized
+	OnProgress func(BatchProgress)
+
+	// Clock paces rate-limited tasks (nil uses the system clock)
+	Clock Clock
+}
+
+// BatchScheduler runs large maintenance jobs with bounded concurrency, per-provider
+// rate limits and checkpointing, so they can run alongside production traffic
+type BatchScheduler struct {
+	opts    BatchOptions
+	pacers  map[string]*pacer
+	pacerMu sync.Mutex
+}
+
+// NewBatchScheduler creates a batch scheduler
+func NewBatchScheduler(opts BatchOptions) *BatchScheduler {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	if opts.Clock == nil {
+		opts.Clock = SystemClock
+	}
+	return &BatchScheduler{
+		opts:   opts,
+		pacers: make(map[string]*pacer),
+	}
+}
+
+// Run executes the tasks and returns once all of them have finished or ctx is done.
+// Failed tasks are reported in the result and are not checkpointed, so a resumed
+// run retries them. The returned error is ctx's error or a checkpoint failure.
+func (s *BatchScheduler) Run(ctx context.Context, tasks []BatchTask) (BatchResult, error) {
+	start := s.opts.Clock.Now()
+	result := BatchResult{
+		BatchProgress: BatchProgress{Total: len(tasks)},
+		Errors:        make(map[string]error),
+	}
+
+	var mu sync.Mutex
+	var runErr error
+	report := func(update func()) {
+		mu.Lock()
+		defer mu.Unlock()
+		update()
+		result.Elapsed = s.opts.Clock.Since(start)
+		if s.opts.OnProgress != nil {
+			s.opts.OnProgress(result.BatchProgress)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	queue := make(chan BatchTask)
+	var wg sync.WaitGroup
+	for i := 0; i < s.opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range queue {
+				if err := s.pacer(task.Provider).wait(ctx); err != nil {
+					continue
+				}
+
+				if err := task.Run(ctx); err != nil {
+					report(func() {
+						result.Failed++
+						result.Errors[task.Key] = err
+					})
+					continue
+				}
+
+				if s.opts.Checkpoint != nil {
+					if err := s.opts.Checkpoint.MarkDone(task.Key); err != nil {
+						mu.Lock()
+						if runErr == nil {
+							runErr = NewError(ErrStorageFailed, "failed to write batch checkpoint", err)
+						}
+						mu.Unlock()
+						cancel()
+					}
+				}
+				report(func() { result.Completed++ })
+			}
+		}()
+	}
+
+dispatch:
+	for _, task := range tasks {
+		if s.opts.Checkpoint != nil && s.opts.Checkpoint.Done(task.Key) {
+			report(func() { result.Skipped++ })
+			continue
+		}
+		select {
+		case queue <- task:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	result.Elapsed = s.opts.Clock.Since(start)
+	if runErr != nil {
+		return result, runErr
+	}
+	return result, ctx.Err()
+}
+
+// pacer returns the rate limiter of a provider
+func (s *BatchScheduler) pacer(provider string) *pacer {
+	s.pacerMu.Lock()
+	defer s.pacerMu.Unlock()
+
+	p, exists := s.pacers[provider]
+	if !exists {
+		p = &pacer{clock: s.opts.Clock}
+		if qps := s.opts.ProviderQPS[provider]; qps > 0 && provider != "" {
+			p.interval = time.Duration(float64(time.Second) / qps)
+		}
+		s.pacers[provider] = p
+	}
+	return p
+}
+
+// pacer spaces task starts at least interval apart
+type pacer struct {
+	clock    Clock
+	interval time.Duration
+	next     time.Time
+	mu       sync.Mutex
+}
+
+// wait blocks until the caller may start its task
+func (p *pacer) wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if p.interval == 0 {
+		return nil
+	}
+
+	p.mu.Lock()
+	now := p.clock.Now()
+	slot := p.next
+	if slot.Before(now) {
+		slot = now
+	}
+	p.next = slot.Add(p.interval)
+	p.mu.Unlock()
+
+	delay := slot.Sub(now)
+	if delay <= 0 {
+		return nil
+	}
+
+	ready := make(chan struct{})
+	timer := p.clock.AfterFunc(delay, func() { close(ready) })
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		timer.Stop()
+		return ctx.Err()
+	}
+}
+
+// FileCheckpoint is a Checkpoint that appends completed task keys to a file
+type FileCheckpoint struct {
+	path string
+	done map[string]bool
+	file *os.File
+	mu   sync.Mutex
+}
+
+// NewFileCheckpoint opens (or creates) the checkpoint file at path, loading the keys
+// completed by earlier runs
+func NewFileCheckpoint(path string) (*FileCheckpoint, error) {
+	done := make(map[string]bool)
+
+	if data, err := os.ReadFile(path); err == nil {
+		scanner := bufio.NewScanner(strings.NewReader(string(data)))
+		for scanner.Scan() {
+			if key := scanner.Text(); key != "" {
+				done[key] = true
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, NewError(ErrStorageFailed, fmt.Sprintf("failed to read checkpoint: %s", path), err)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, NewError(ErrStorageFailed, fmt.Sprintf("failed to open checkpoint: %s", path), err)
+	}
+
+	return &FileCheckpoint{path: path, done: done, file: file}, nil
+}
+
+// Done reports whether the task completed in an earlier run
+func (c *FileCheckpoint) Done(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.done[key]
+}
+
+// MarkDone records that the task completed
+func (c *FileCheckpoint) MarkDone(key string) error {
+	if strings.ContainsAny(key, "\r\n") {
+		return NewError(ErrInvalidParams, "checkpoint keys must not contain newlines", nil)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file == nil {
+		return NewError(ErrStorageFailed, "checkpoint is closed", nil)
+	}
+	if _, err := c.file.WriteString(key + "\n"); err != nil {
+		return NewError(ErrStorageFailed, "failed to write checkpoint", err)
+	}
+	c.done[key] = true
+	return nil
+}
+
+// Close closes the checkpoint file
+func (c *FileCheckpoint) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file == nil {
+		return nil
+	}
+	err := c.file.Close()
+	c.file = nil
+	return err
+}
@@ -0,0 +1,152 @@
+package tokentracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func countingTasks(n int, provider string, runs *int32, fail map[string]bool) []BatchTask {
+	tasks := make([]BatchTask, n)
+	for i := range tasks {
+		key := fmt.Sprintf("record-%d", i)
+		tasks[i] = BatchTask{
+			Key:      key,
+			Provider: provider,
+			Run: func(ctx context.Context) error {
+				atomic.AddInt32(runs, 1)
+				if fail[key] {
+					return errors.New("provider unavailable")
+				}
+				return nil
+			},
+		}
+	}
+	return tasks
+}
+
+func TestBatchScheduler_Concurrency(t *testing.T) {
+	var running, peak int32
+	tasks := make([]BatchTask, 20)
+	for i := range tasks {
+		tasks[i] = BatchTask{
+			Key: fmt.Sprint(i),
+			Run: func(ctx context.Context) error {
+				n := atomic.AddInt32(&running, 1)
+				for {
+					p := atomic.LoadInt32(&peak)
+					if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				atomic.AddInt32(&running, -1)
+				return nil
+			},
+		}
+	}
+
+	var progress []BatchProgress
+	scheduler := NewBatchScheduler(BatchOptions{
+		Concurrency: 3,
+		OnProgress:  func(p BatchProgress) { progress = append(progress, p) },
+	})
+
+	result, err := scheduler.Run(context.Background(), tasks)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.Completed != 20 || result.Total != 20 {
+		t.Errorf("result = %+v, want 20 completed tasks", result.BatchProgress)
+	}
+	if peak > 3 {
+		t.Errorf("%d tasks ran at once, want at most 3", peak)
+	}
+	if len(progress) != 20 || progress[19].Done() != 20 {
+		t.Errorf("got %d progress reports, want one per task", len(progress))
+	}
+}
+
+func TestBatchScheduler_ProviderQPS(t *testing.T) {
+	var runs int32
+	tasks := append(countingTasks(5, "anthropic", &runs, nil), countingTasks(5, "", &runs, nil)...)
+
+	scheduler := NewBatchScheduler(BatchOptions{
+		Concurrency: 4,
+		ProviderQPS: map[string]float64{"anthropic": 100},
+	})
+
+	start := time.Now()
+	if _, err := scheduler.Run(context.Background(), tasks); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	// Five starts at 100 QPS are spread over at least 40ms
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("rate-limited tasks finished in %v, want at least 40ms", elapsed)
+	}
+	if runs != 10 {
+		t.Errorf("ran %d tasks, want 10", runs)
+	}
+}
+
+func TestBatchScheduler_CheckpointResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reprice.checkpoint")
+
+	checkpoint, err := NewFileCheckpoint(path)
+	if err != nil {
+		t.Fatalf("NewFileCheckpoint() error = %v", err)
+	}
+
+	var runs int32
+	failing := map[string]bool{"record-3": true, "record-7": true}
+	result, err := NewBatchScheduler(BatchOptions{Concurrency: 2, Checkpoint: checkpoint}).
+		Run(context.Background(), countingTasks(10, "", &runs, failing))
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.Completed != 8 || result.Failed != 2 || result.Errors["record-3"] == nil {
+		t.Errorf("first run = %+v, want 8 completed and 2 failed", result)
+	}
+	checkpoint.Close()
+
+	// The resumed run only retries the failed tasks
+	checkpoint, _ = NewFileCheckpoint(path)
+	defer checkpoint.Close()
+
+	runs = 0
+	result, err = NewBatchScheduler(BatchOptions{Checkpoint: checkpoint}).
+		Run(context.Background(), countingTasks(10, "", &runs, nil))
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if runs != 2 || result.Skipped != 8 || result.Completed != 2 {
+		t.Errorf("resumed run = %+v after %d runs, want 8 skipped and 2 completed", result.BatchProgress, runs)
+	}
+}
+
+func TestBatchScheduler_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var once sync.Once
+	var runs int32
+	tasks := countingTasks(100, "openai", &runs, nil)
+	tasks[0].Run = func(context.Context) error {
+		atomic.AddInt32(&runs, 1)
+		once.Do(cancel)
+		return nil
+	}
+
+	scheduler := NewBatchScheduler(BatchOptions{ProviderQPS: map[string]float64{"openai": 50}})
+	result, err := scheduler.Run(ctx, tasks)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Run() error = %v, want context.Canceled", err)
+	}
+	if result.Completed >= 100 || runs > 2 {
+		t.Errorf("cancelled run executed %d tasks", runs)
+	}
+}
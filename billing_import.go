@@ -0,0 +1,410 @@
+package tokentracker
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BillingExportFormat identifies the format of a provider billing export
+type BillingExportFormat string
+
+// Supported billing export formats
+const (
+	// BillingExportOpenAI is the CSV export of the OpenAI usage dashboard
+	BillingExportOpenAI BillingExportFormat = "openai_usage_csv"
+
+	// BillingExportAnthropic is the CSV export of the Anthropic console usage page
+	BillingExportAnthropic BillingExportFormat = "anthropic_console_csv"
+
+	// BillingExportGCP is a newline-delimited JSON extract of the GCP billing export table in BigQuery
+	BillingExportGCP BillingExportFormat = "gcp_billing_json"
+)
+
+// ImportTagSource is the tag holding the export format of imported usage records
+const ImportTagSource = "import_source"
+
+// BillingImportOptions controls how billing export rows are normalized
+type BillingImportOptions struct {
+	// Pricer fills in costs missing from the export (nil leaves them zero); a Pricer
+	// with a CalculatePriceAt method, like the tracker, prices rows at their timestamps
+	Pricer PriceCalculator
+
+	// Location is the time zone of date-only columns (nil means UTC)
+	Location *time.Location
+}
+
+// ImportBillingExport parses a provider billing export into usage records.
+// Rows that do not describe model usage (e.g. other GCP services) are skipped.
+func ImportBillingExport(format BillingExportFormat, r io.Reader, opts BillingImportOptions) ([]UsageMetrics, error) {
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+
+	var records []UsageMetrics
+	var err error
+	switch format {
+	case BillingExportOpenAI:
+		records, err = importCSV(r, opts, openAIBillingRow)
+	case BillingExportAnthropic:
+		records, err = importCSV(r, opts, anthropicBillingRow)
+	case BillingExportGCP:
+		records, err = importGCPBilling(r, opts)
+	default:
+		return nil, NewError(ErrInvalidParams, fmt.Sprintf("unknown billing export format: %s", format), nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range records {
+		records[i].Tags[ImportTagSource] = string(format)
+		if records[i].Price.TotalCost == 0 && opts.Pricer != nil {
+			var price Price
+			var err error
+			if pricer, ok := opts.Pricer.(historicalPricer); ok {
+				price, err = pricer.CalculatePriceAt(records[i].Model, records[i].TokenCount.InputTokens, records[i].TokenCount.ResponseTokens, records[i].Timestamp)
+			} else {
+				price, err = opts.Pricer.CalculatePrice(records[i].Model, records[i].TokenCount.InputTokens, records[i].TokenCount.ResponseTokens)
+			}
+			if err == nil {
+				records[i].Price = price
+			}
+		}
+		if records[i].Price.Currency == "" {
+			records[i].Price.Currency = "USD"
+		}
+	}
+	return records, nil
+}
+
+// ImportBillingExport parses a billing export, prices rows without costs using the
+// tracker's pricing and writes the records into the usage store. It returns the
+// number of imported records.
+func (t *DefaultTokenTracker) ImportBillingExport(format BillingExportFormat, r io.Reader) (int, error) {
+	store := t.UsageStore()
+	if store == nil {
+		return 0, NewError(ErrStorageFailed, "no usage store configured", nil)
+	}
+
+	records, err := ImportBillingExport(format, r, BillingImportOptions{Pricer: t})
+	if err != nil {
+		return 0, err
+	}
+
+	return recordAll(store, t.scrubAll(records))
+}
+
+// csvRow gives access to a CSV record by column name
+type csvRow struct {
+	columns map[string]int
+	record  []string
+	line    int
+}
+
+// get returns the first non-empty value among the given column names
+func (r csvRow) get(names ...string) string {
+	for _, name := range names {
+		if i, ok := r.columns[name]; ok && i < len(r.record) {
+			if value := strings.TrimSpace(r.record[i]); value != "" {
+				return value
+			}
+		}
+	}
+	return ""
+}
+
+// int returns the integer value of a column (0 when missing)
+func (r csvRow) int(names ...string) (int, error) {
+	value := r.get(names...)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", ""), 64)
+	if err != nil {
+		return 0, r.errorf("invalid number %q", value)
+	}
+	return int(n), nil
+}
+
+// float returns the decimal value of a column (0 when missing)
+func (r csvRow) float(names ...string) (float64, error) {
+	value := strings.TrimPrefix(r.get(names...), "$")
+	if value == "" {
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", ""), 64)
+	if err != nil {
+		return 0, r.errorf("invalid amount %q", value)
+	}
+	return f, nil
+}
+
+// time parses a timestamp column holding RFC 3339, a date or unix seconds
+func (r csvRow) time(loc *time.Location, names ...string) (time.Time, error) {
+	value := r.get(names...)
+	if value == "" {
+		return time.Time{}, r.errorf("missing timestamp column %s", names[0])
+	}
+	ts, err := parseExportTime(value, loc)
+	if err != nil {
+		return time.Time{}, r.errorf("invalid timestamp %q", value)
+	}
+	return ts, nil
+}
+
+// errorf returns an ErrInvalidParams error pointing at the row
+func (r csvRow) errorf(format string, args ...interface{}) error {
+	return NewError(ErrInvalidParams, fmt.Sprintf("line %d: %s", r.line, fmt.Sprintf(format, args...)), nil)
+}
+
+// parseExportTime parses the timestamp formats found in billing exports
+func parseExportTime(value string, loc *time.Location) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05 MST", "2006-01-02 15:04:05", "2006-01-02"} {
+		if ts, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return ts, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized time: %s", value)
+}
+
+// importCSV reads a CSV export with a header row, converting each row with convert.
+// convert returns false for rows that are not model usage.
+func importCSV(r io.Reader, opts BillingImportOptions, convert func(csvRow, BillingImportOptions) (UsageMetrics, bool, error)) ([]UsageMetrics, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, NewError(ErrInvalidParams, "failed to read export header", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		columns[strings.ReplaceAll(name, " ", "_")] = i
+	}
+
+	var records []UsageMetrics
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, NewError(ErrInvalidParams, fmt.Sprintf("failed to read export line %d", line), err)
+		}
+
+		metrics, ok, err := convert(csvRow{columns: columns, record: record, line: line}, opts)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			records = append(records, metrics)
+		}
+	}
+	return records, nil
+}
+
+// openAIBillingRow converts a row of the OpenAI usage export. Both the current export
+// (input_tokens/output_tokens per bucket) and the legacy activity export
+// (n_context_tokens_total/n_generated_tokens_total) are understood.
+func openAIBillingRow(row csvRow, opts BillingImportOptions) (UsageMetrics, bool, error) {
+	model := row.get("model", "snapshot_id")
+	if model == "" {
+		return UsageMetrics{}, false, nil
+	}
+
+	timestamp, err := row.time(opts.Location, "start_time_iso", "start_time", "timestamp", "date")
+	if err != nil {
+		return UsageMetrics{}, false, err
+	}
+	input, err := row.int("input_tokens", "n_context_tokens_total", "context_tokens")
+	if err != nil {
+		return UsageMetrics{}, false, err
+	}
+	output, err := row.int("output_tokens", "n_generated_tokens_total", "generated_tokens")
+	if err != nil {
+		return UsageMetrics{}, false, err
+	}
+	cost, err := row.float("cost", "amount_value", "cost_usd")
+	if err != nil {
+		return UsageMetrics{}, false, err
+	}
+
+	metrics := importedUsage("openai", model, timestamp, input, output, cost, row.get("currency", "amount_currency"))
+	metrics.ProjectID = row.get("project_id")
+	metrics.ProviderProjectID = metrics.ProjectID
+	metrics.UserID = row.get("user_id")
+	metrics.APIKeyID = row.get("api_key_id")
+	setTag(metrics.Tags, "requests", row.get("num_model_requests", "n_requests"))
+	return metrics, true, nil
+}
+
+// anthropicBillingRow converts a row of the Anthropic console usage export.
+// Cache writes and reads are counted as input tokens.
+func anthropicBillingRow(row csvRow, opts BillingImportOptions) (UsageMetrics, bool, error) {
+	model := row.get("model_version", "model")
+	if model == "" {
+		return UsageMetrics{}, false, nil
+	}
+
+	timestamp, err := row.time(opts.Location, "usage_date_utc", "date", "timestamp")
+	if err != nil {
+		return UsageMetrics{}, false, err
+	}
+
+	input := 0
+	for _, column := range []string{"input_tokens", "input_tokens_no_cache", "input_tokens_cache_write", "input_tokens_cache_write_5m", "input_tokens_cache_write_1h", "input_tokens_cache_read"} {
+		n, err := row.int(column)
+		if err != nil {
+			return UsageMetrics{}, false, err
+		}
+		input += n
+	}
+	output, err := row.int("output_tokens")
+	if err != nil {
+		return UsageMetrics{}, false, err
+	}
+	cost, err := row.float("cost_usd", "cost", "total_cost")
+	if err != nil {
+		return UsageMetrics{}, false, err
+	}
+
+	metrics := importedUsage("anthropic", model, timestamp, input, output, cost, "USD")
+	setTag(metrics.Tags, "workspace", row.get("workspace"))
+	metrics.APIKeyID = row.get("api_key")
+	return metrics, true, nil
+}
+
+// gcpBillingRow is the subset of the GCP billing export schema needed for model usage
+type gcpBillingRow struct {
+	UsageStartTime string  `json:"usage_start_time"`
+	Cost           float64 `json:"cost"`
+	Currency       string  `json:"currency"`
+	Service        struct {
+		Description string `json:"description"`
+	} `json:"service"`
+	SKU struct {
+		Description string `json:"description"`
+	} `json:"sku"`
+	Project struct {
+		ID string `json:"id"`
+	} `json:"project"`
+	Usage struct {
+		Amount float64 `json:"amount"`
+		Unit   string  `json:"unit"`
+	} `json:"usage"`
+}
+
+// importGCPBilling reads newline-delimited JSON rows of the GCP billing export, keeping
+// the Vertex AI and Gemini API rows that bill input or output tokens
+func importGCPBilling(r io.Reader, opts BillingImportOptions) ([]UsageMetrics, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	var records []UsageMetrics
+	line := 0
+	for scanner.Scan() {
+		line++
+		data := strings.TrimSpace(scanner.Text())
+		if data == "" {
+			continue
+		}
+
+		var row gcpBillingRow
+		if err := json.Unmarshal([]byte(data), &row); err != nil {
+			return nil, NewError(ErrInvalidParams, fmt.Sprintf("invalid billing row at line %d", line), err)
+		}
+
+		model, output, ok := parseGCPSKU(row.Service.Description, row.SKU.Description)
+		if !ok || !strings.Contains(strings.ToLower(row.Usage.Unit), "token") && row.Usage.Unit != "count" {
+			continue
+		}
+
+		timestamp, err := parseExportTime(row.UsageStartTime, opts.Location)
+		if err != nil {
+			return nil, NewError(ErrInvalidParams, fmt.Sprintf("invalid usage_start_time at line %d", line), err)
+		}
+
+		var metrics UsageMetrics
+		if output {
+			metrics = importedUsage("gemini", model, timestamp, 0, int(row.Usage.Amount), row.Cost, row.Currency)
+		} else {
+			metrics = importedUsage("gemini", model, timestamp, int(row.Usage.Amount), 0, row.Cost, row.Currency)
+		}
+		setTag(metrics.Tags, "gcp_project", row.Project.ID)
+		setTag(metrics.Tags, "sku", row.SKU.Description)
+		records = append(records, metrics)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, NewError(ErrInvalidParams, "failed to read billing export", err)
+	}
+	return records, nil
+}
+
+// parseGCPSKU extracts the model from a SKU description such as
+// "Gemini 1.5 Pro Text Input - Predictions" and reports whether it bills output tokens
+func parseGCPSKU(service, sku string) (model string, output bool, ok bool) {
+	service = strings.ToLower(service)
+	if !strings.Contains(service, "vertex ai") && !strings.Contains(service, "gemini") && !strings.Contains(service, "generative language") {
+		return "", false, false
+	}
+
+	words := strings.Fields(strings.ToLower(strings.SplitN(sku, " - ", 2)[0]))
+	var name []string
+	for i, word := range words {
+		switch word {
+		case "input", "output":
+			if len(name) == 0 || !strings.HasPrefix(name[0], "gemini") {
+				return "", false, false
+			}
+			// Drop the modality qualifier preceding input/output, e.g. "text" or "image"
+			if last := name[len(name)-1]; last == "text" || last == "image" || last == "video" || last == "audio" || last == "character" {
+				name = name[:len(name)-1]
+			}
+			return strings.Join(name, "-"), word == "output", i > 0
+		default:
+			name = append(name, word)
+		}
+	}
+	return "", false, false
+}
+
+// importedUsage builds a usage record from the totals of a billing row
+func importedUsage(provider, model string, timestamp time.Time, input, output int, cost float64, currency string) UsageMetrics {
+	return UsageMetrics{
+		TokenCount: TokenCount{
+			InputTokens:    input,
+			ResponseTokens: output,
+			TotalTokens:    input + output,
+		},
+		Price: Price{
+			TotalCost: cost,
+			Currency:  strings.ToUpper(currency),
+		}.WithUnitPrices(input, output),
+		Timestamp: timestamp,
+		Model:     model,
+		Provider:  provider,
+		Tags:      make(map[string]string),
+	}
+}
+
+// setTag sets a tag when the value is present
+func setTag(tags map[string]string, key, value string) {
+	if value != "" {
+		tags[key] = value
+	}
+}
@@ -0,0 +1,124 @@
+package tokentracker
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImportBillingExport_OpenAI(t *testing.T) {
+	export := `start_time,end_time,start_time_iso,end_time_iso,project_id,num_model_requests,user_id,api_key_id,model,batch,input_tokens,output_tokens
+1709251200,1709337600,2024-03-01T00:00:00+00:00,2024-03-02T00:00:00+00:00,proj_abc,12,,key_1,gpt-4o-2024-05-13,false,1200,300
+1709337600,1709424000,2024-03-02T00:00:00+00:00,2024-03-03T00:00:00+00:00,proj_abc,3,,key_1,,false,0,0
+`
+	pricer := fixedPricer{"gpt-4o-2024-05-13": 0.00001}
+
+	records, err := ImportBillingExport(BillingExportOpenAI, strings.NewReader(export), BillingImportOptions{Pricer: pricer})
+	if err != nil {
+		t.Fatalf("ImportBillingExport() error = %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("got %d records, want 1 (rows without a model are skipped)", len(records))
+	}
+
+	record := records[0]
+	if record.Provider != "openai" || record.Model != "gpt-4o-2024-05-13" {
+		t.Errorf("record = %s/%s, want openai/gpt-4o-2024-05-13", record.Provider, record.Model)
+	}
+	if record.TokenCount.InputTokens != 1200 || record.TokenCount.ResponseTokens != 300 || record.TokenCount.TotalTokens != 1500 {
+		t.Errorf("TokenCount = %+v", record.TokenCount)
+	}
+	if !record.Timestamp.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Timestamp = %v, want 2024-03-01", record.Timestamp)
+	}
+	if got := record.Price.TotalCost; got < 0.0149 || got > 0.0151 {
+		t.Errorf("TotalCost = %v, want the pricer's 0.015", got)
+	}
+	if record.ProjectID != "proj_abc" || record.Tags["requests"] != "12" || record.Tags[ImportTagSource] != string(BillingExportOpenAI) {
+		t.Errorf("ProjectID = %q, Tags = %v", record.ProjectID, record.Tags)
+	}
+	if record.APIKeyID != "key_1" || record.ProviderProjectID != "proj_abc" {
+		t.Errorf("APIKeyID = %q, ProviderProjectID = %q, want the billed key and project", record.APIKeyID, record.ProviderProjectID)
+	}
+}
+
+func TestImportBillingExport_Anthropic(t *testing.T) {
+	export := "\ufeffusage_date_utc,model_version,api_key,workspace,input_tokens_no_cache,input_tokens_cache_write_5m,input_tokens_cache_read,output_tokens,cost_usd\n" +
+		"2024-06-10,claude-3-5-sonnet-20240620,ci-key,Default,1000,200,800,500,\"$1,234.50\"\n"
+
+	records, err := ImportBillingExport(BillingExportAnthropic, strings.NewReader(export), BillingImportOptions{})
+	if err != nil {
+		t.Fatalf("ImportBillingExport() error = %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("got %d records, want 1", len(records))
+	}
+
+	record := records[0]
+	if record.Provider != "anthropic" || record.TokenCount.InputTokens != 2000 || record.TokenCount.ResponseTokens != 500 {
+		t.Errorf("record = %+v, want cache reads and writes counted as input", record)
+	}
+	if record.Price.TotalCost != 1234.5 || record.Price.Currency != "USD" {
+		t.Errorf("Price = %+v, want the exported cost", record.Price)
+	}
+	if record.Tags["workspace"] != "Default" {
+		t.Errorf("Tags = %v", record.Tags)
+	}
+
+	_, err = ImportBillingExport(BillingExportAnthropic, strings.NewReader("usage_date_utc,model_version,output_tokens\nyesterday,claude-3-haiku,1\n"), BillingImportOptions{})
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("invalid date error = %v, want one pointing at line 2", err)
+	}
+}
+
+func TestImportBillingExport_GCP(t *testing.T) {
+	export := `{"usage_start_time":"2024-05-01 00:00:00 UTC","cost":0.35,"currency":"USD","service":{"description":"Vertex AI"},"sku":{"description":"Gemini 1.5 Pro Text Input - Predictions"},"project":{"id":"my-project"},"usage":{"amount":100000,"unit":"count"}}
+{"usage_start_time":"2024-05-01 00:00:00 UTC","cost":0.105,"currency":"USD","service":{"description":"Vertex AI"},"sku":{"description":"Gemini 1.5 Pro Text Output - Predictions"},"project":{"id":"my-project"},"usage":{"amount":10000,"unit":"count"}}
+{"usage_start_time":"2024-05-01 00:00:00 UTC","cost":12.0,"currency":"USD","service":{"description":"Compute Engine"},"sku":{"description":"N1 Predefined Instance Core"},"usage":{"amount":3600,"unit":"seconds"}}
+`
+
+	records, err := ImportBillingExport(BillingExportGCP, strings.NewReader(export), BillingImportOptions{})
+	if err != nil {
+		t.Fatalf("ImportBillingExport() error = %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2 (non-AI services are skipped)", len(records))
+	}
+
+	if records[0].Model != "gemini-1.5-pro" || records[0].Provider != "gemini" || records[0].TokenCount.InputTokens != 100000 {
+		t.Errorf("input record = %+v", records[0])
+	}
+	if records[1].TokenCount.ResponseTokens != 10000 || records[1].Price.TotalCost != 0.105 {
+		t.Errorf("output record = %+v", records[1])
+	}
+	if records[0].Tags["gcp_project"] != "my-project" {
+		t.Errorf("Tags = %v", records[0].Tags)
+	}
+}
+
+func TestDefaultTokenTracker_ImportBillingExport(t *testing.T) {
+	tracker := NewTokenTracker(NewConfig())
+	export := "date,model,input_tokens,output_tokens,cost\n2024-03-01,gpt-4,10,5,0.5\n2024-03-02,gpt-4,20,5,0.75\n"
+
+	if _, err := tracker.ImportBillingExport(BillingExportOpenAI, strings.NewReader(export)); err == nil {
+		t.Error("ImportBillingExport() without a usage store should fail")
+	}
+
+	tracker.SetUsageStore(NewMemoryUsageStore())
+	n, err := tracker.ImportBillingExport(BillingExportOpenAI, strings.NewReader(export))
+	if err != nil {
+		t.Fatalf("ImportBillingExport() error = %v", err)
+	}
+	if n != 2 {
+		t.Errorf("imported %d records, want 2", n)
+	}
+
+	summaries, _ := tracker.Summary(UsageFilter{}, GroupByModel)
+	if len(summaries) != 1 || summaries[0].Calls != 2 || summaries[0].TotalCost != 1.25 {
+		t.Errorf("Summary() = %+v, want the imported history", summaries)
+	}
+
+	if _, err := ImportBillingExport("excel", strings.NewReader(""), BillingImportOptions{}); err == nil {
+		t.Error("ImportBillingExport() with an unknown format should fail")
+	}
+}
@@ -0,0 +1,364 @@
+package tokentracker
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// BudgetWindow is the period after which a budget's usage resets
+type BudgetWindow string
+
+// Budget windows
+const (
+	BudgetWindowTotal   BudgetWindow = ""        // never resets
+	BudgetWindowDaily   BudgetWindow = "daily"   // resets at midnight
+	BudgetWindowMonthly BudgetWindow = "monthly" // resets on the first day of the month
+)
+
+// Budget is a spending and/or token limit for the calls matching its scope.
+// Empty scope fields match any value.
+type Budget struct {
+	Name     string
+	Provider string
+	Model    string
+	Tags     map[string]string // all tags must be present on the call with the same value
+
+	Window   BudgetWindow
+	Location *time.Location // time zone of daily/monthly windows (nil means UTC)
+
+	CostLimit  float64 // 0 means no cost limit
+	TokenLimit int     // 0 means no token limit
+
+	// Hard budgets reject usage once exceeded; soft budgets only notify
+	Hard bool
+}
+
+// Matches reports whether usage falls within the budget's scope
+func (b Budget) Matches(provider, model string, tags map[string]string) bool {
+	if b.Provider != "" && b.Provider != provider {
+		return false
+	}
+	if b.Model != "" && b.Model != model {
+		return false
+	}
+	for key, value := range b.Tags {
+		if tags[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// windowStart returns the start of the window containing now
+func (b Budget) windowStart(now time.Time) time.Time {
+	loc := b.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	now = now.In(loc)
+
+	switch b.Window {
+	case BudgetWindowDaily:
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	case BudgetWindowMonthly:
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+	default:
+		return time.Time{}
+	}
+}
+
+// BudgetStatus is the usage of a budget in its current window
+type BudgetStatus struct {
+	Budget          Budget
+	WindowStart     time.Time // zero for budgets without a window
+	SpentCost       float64
+	UsedTokens      int
+	RemainingCost   float64 // 0 when the budget has no cost limit
+	RemainingTokens int     // 0 when the budget has no token limit
+	Exceeded        bool
+}
+
+// budgetState tracks the usage of one budget
+type budgetState struct {
+	budget      Budget
+	windowStart time.Time
+	cost        float64
+	tokens      int
+	notified    bool
+}
+
+// status returns the budget status
+func (s *budgetState) status() BudgetStatus {
+	status := BudgetStatus{
+		Budget:      s.budget,
+		WindowStart: s.windowStart,
+		SpentCost:   s.cost,
+		UsedTokens:  s.tokens,
+	}
+	if s.budget.CostLimit > 0 {
+		status.RemainingCost = s.budget.CostLimit - s.cost
+		if status.RemainingCost <= 0 {
+			status.RemainingCost = 0
+			status.Exceeded = status.Exceeded || s.cost > s.budget.CostLimit
+		}
+	}
+	if s.budget.TokenLimit > 0 {
+		status.RemainingTokens = s.budget.TokenLimit - s.tokens
+		if status.RemainingTokens <= 0 {
+			status.RemainingTokens = 0
+			status.Exceeded = status.Exceeded || s.tokens > s.budget.TokenLimit
+		}
+	}
+	return status
+}
+
+// exhausted reports whether no further usage fits the budget
+func (s *budgetState) exhausted() bool {
+	return (s.budget.CostLimit > 0 && s.cost >= s.budget.CostLimit) ||
+		(s.budget.TokenLimit > 0 && s.tokens >= s.budget.TokenLimit)
+}
+
+// roll resets the usage when the window has moved on
+func (s *budgetState) roll(now time.Time) {
+	if start := s.budget.windowStart(now); !start.Equal(s.windowStart) {
+		s.windowStart = start
+		s.cost = 0
+		s.tokens = 0
+		s.notified = false
+	}
+}
+
+// BudgetManager enforces spending and token budgets on tracked usage.
+// It is safe for concurrent use.
+type BudgetManager struct {
+	budgets   map[string]*budgetState
+	listeners []func(BudgetStatus)
+	clock     Clock
+	mu        sync.Mutex
+}
+
+// NewBudgetManager creates a budget manager using the given clock for windows (nil uses the system clock)
+func NewBudgetManager(clock Clock) *BudgetManager {
+	if clock == nil {
+		clock = SystemClock
+	}
+	return &BudgetManager{
+		budgets: make(map[string]*budgetState),
+		clock:   clock,
+	}
+}
+
+// SetBudget adds or replaces a budget. Replacing a budget keeps its current usage.
+func (m *BudgetManager) SetBudget(budget Budget) error {
+	if budget.Name == "" {
+		return NewError(ErrInvalidParams, "budget name is required", nil)
+	}
+	if budget.CostLimit < 0 || budget.TokenLimit < 0 {
+		return NewError(ErrInvalidParams, fmt.Sprintf("budget %s has a negative limit", budget.Name), nil)
+	}
+	switch budget.Window {
+	case BudgetWindowTotal, BudgetWindowDaily, BudgetWindowMonthly:
+	default:
+		return NewError(ErrInvalidParams, fmt.Sprintf("unknown budget window: %s", budget.Window), nil)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, exists := m.budgets[budget.Name]
+	if !exists {
+		state = &budgetState{}
+		m.budgets[budget.Name] = state
+	}
+	state.budget = budget
+	state.roll(m.clock.Now())
+	return nil
+}
+
+// RemoveBudget removes a budget
+func (m *BudgetManager) RemoveBudget(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.budgets, name)
+}
+
+// OnExceeded registers a callback invoked once per window when a budget is exceeded
+func (m *BudgetManager) OnExceeded(callback func(BudgetStatus)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.listeners = append(m.listeners, callback)
+}
+
+// Remaining returns the status of a budget in its current window
+func (m *BudgetManager) Remaining(name string) (BudgetStatus, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, exists := m.budgets[name]
+	if !exists {
+		return BudgetStatus{}, NewError(ErrInvalidParams, fmt.Sprintf("unknown budget: %s", name), nil)
+	}
+	state.roll(m.clock.Now())
+	return state.status(), nil
+}
+
+// Statuses returns the status of all budgets, ordered by name
+func (m *BudgetManager) Statuses() []BudgetStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.clock.Now()
+	statuses := make([]BudgetStatus, 0, len(m.budgets))
+	for _, state := range m.budgets {
+		state.roll(now)
+		statuses = append(statuses, state.status())
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Budget.Name < statuses[j].Budget.Name
+	})
+	return statuses
+}
+
+// RemainingCostFor returns the smallest cost remaining in the budgets with a cost limit
+// matching a call, or false when no budget limits the cost of the call
+func (m *BudgetManager) RemainingCostFor(provider, model string, tags map[string]string) (float64, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.clock.Now()
+	remaining, limited := 0.0, false
+	for _, state := range m.budgets {
+		if state.budget.CostLimit <= 0 || !state.budget.Matches(provider, model, tags) {
+			continue
+		}
+		state.roll(now)
+		if left := state.status().RemainingCost; !limited || left < remaining {
+			remaining, limited = left, true
+		}
+	}
+	return remaining, limited
+}
+
+// Check returns an ErrBudgetExceeded error when a hard budget matching the call is
+// already exhausted, so callers can block the call before making it
+func (m *BudgetManager) Check(provider, model string, tags map[string]string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.clock.Now()
+	for _, name := range m.sortedNames() {
+		state := m.budgets[name]
+		if !state.budget.Hard || !state.budget.Matches(provider, model, tags) {
+			continue
+		}
+		state.roll(now)
+		if state.exhausted() {
+			return NewError(ErrBudgetExceeded, fmt.Sprintf("budget %s is exhausted", name), nil)
+		}
+	}
+	return nil
+}
+
+// CheckCost returns an ErrBudgetExceeded error when planned usage of cost and tokens,
+// e.g. the maximum of an EstimateCost range, would exceed a hard budget matching the call
+func (m *BudgetManager) CheckCost(provider, model string, tags map[string]string, cost float64, tokens int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.clock.Now()
+	for _, name := range m.sortedNames() {
+		state := m.budgets[name]
+		if !state.budget.Hard || !state.budget.Matches(provider, model, tags) {
+			continue
+		}
+		state.roll(now)
+		if state.budget.CostLimit > 0 && state.cost+cost > state.budget.CostLimit {
+			return NewError(ErrBudgetExceeded, fmt.Sprintf("planned cost of %g would exceed budget %s", cost, name), nil)
+		}
+		if state.budget.TokenLimit > 0 && state.tokens+tokens > state.budget.TokenLimit {
+			return NewError(ErrBudgetExceeded, fmt.Sprintf("planned %d tokens would exceed budget %s", tokens, name), nil)
+		}
+	}
+	return nil
+}
+
+// Record charges tracked usage to every matching budget, in the window of its
+// Timestamp (the current one when it is zero or in the future). Usage of a window that
+// has already closed is not charged to the current one. Budgets crossing their limit
+// notify the OnExceeded callbacks; an ErrBudgetExceeded error is returned when a
+// hard budget is exceeded. The usage is recorded either way.
+func (m *BudgetManager) Record(metrics UsageMetrics) error {
+	m.mu.Lock()
+
+	now := m.clock.Now()
+	at := metrics.Timestamp
+	if at.IsZero() || at.After(now) {
+		at = now
+	}
+	var exceeded []BudgetStatus
+	var hard []string
+	for _, name := range m.sortedNames() {
+		state := m.budgets[name]
+		if !state.budget.Matches(metrics.Provider, metrics.Model, metrics.Tags) {
+			continue
+		}
+
+		state.roll(now)
+		if state.budget.windowStart(at).Before(state.windowStart) {
+			continue
+		}
+		state.cost += metrics.Price.TotalCost
+		state.tokens += metrics.TokenCount.TotalTokens
+
+		status := state.status()
+		if !status.Exceeded {
+			continue
+		}
+		if state.budget.Hard {
+			hard = append(hard, name)
+		}
+		if !state.notified {
+			state.notified = true
+			exceeded = append(exceeded, status)
+		}
+	}
+	listeners := append([]func(BudgetStatus){}, m.listeners...)
+	m.mu.Unlock()
+
+	for _, status := range exceeded {
+		for _, listener := range listeners {
+			listener(status)
+		}
+	}
+
+	if len(hard) > 0 {
+		return NewError(ErrBudgetExceeded, fmt.Sprintf("hard budget exceeded: %v", hard), nil)
+	}
+	return nil
+}
+
+// sortedNames returns the budget names in a stable order; the caller must hold m.mu
+func (m *BudgetManager) sortedNames() []string {
+	names := make([]string, 0, len(m.budgets))
+	for name := range m.budgets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WithBudgetManager makes the tracker charge every tracked call to the manager's budgets
+func WithBudgetManager(manager *BudgetManager) TrackerOption {
+	return func(t *DefaultTokenTracker) {
+		t.budgets = manager
+	}
+}
+
+// Budgets returns the tracker's budget manager, or nil when none is configured
+func (t *DefaultTokenTracker) Budgets() *BudgetManager {
+	return t.budgets
+}
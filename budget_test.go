@@ -0,0 +1,182 @@
+package tokentracker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker/tokentrackertest"
+)
+
+func budgetUsage(provider, model string, cost float64, tokens int, tags map[string]string) UsageMetrics {
+	return UsageMetrics{
+		TokenCount: TokenCount{TotalTokens: tokens},
+		Price:      Price{TotalCost: cost, Currency: "USD"},
+		Provider:   provider,
+		Model:      model,
+		Tags:       tags,
+	}
+}
+
+func TestBudgetManager_Scopes(t *testing.T) {
+	manager := NewBudgetManager(nil)
+	_ = manager.SetBudget(Budget{Name: "openai", Provider: "openai", CostLimit: 10})
+	_ = manager.SetBudget(Budget{Name: "gpt-4", Model: "gpt-4", TokenLimit: 1000})
+	_ = manager.SetBudget(Budget{Name: "search", Tags: map[string]string{"feature": "search"}, CostLimit: 1})
+
+	_ = manager.Record(budgetUsage("openai", "gpt-4", 2, 300, map[string]string{"feature": "search"}))
+	_ = manager.Record(budgetUsage("openai", "gpt-3.5-turbo", 1, 100, nil))
+	_ = manager.Record(budgetUsage("anthropic", "claude-3-haiku", 5, 50, map[string]string{"feature": "chat"}))
+
+	openai, _ := manager.Remaining("openai")
+	if openai.SpentCost != 3 || openai.RemainingCost != 7 {
+		t.Errorf("openai budget = %+v, want 3 spent and 7 remaining", openai)
+	}
+
+	gpt4, _ := manager.Remaining("gpt-4")
+	if gpt4.UsedTokens != 300 || gpt4.RemainingTokens != 700 {
+		t.Errorf("gpt-4 budget = %+v, want 300 used and 700 remaining", gpt4)
+	}
+
+	search, _ := manager.Remaining("search")
+	if !search.Exceeded || search.RemainingCost != 0 {
+		t.Errorf("search budget = %+v, want exceeded", search)
+	}
+
+	if _, err := manager.Remaining("missing"); err == nil {
+		t.Error("Remaining() of an unknown budget should fail")
+	}
+}
+
+func TestBudgetManager_HardLimit(t *testing.T) {
+	manager := NewBudgetManager(nil)
+	_ = manager.SetBudget(Budget{Name: "soft", CostLimit: 1})
+	_ = manager.SetBudget(Budget{Name: "hard", Provider: "openai", CostLimit: 2, Hard: true})
+
+	var notified []string
+	manager.OnExceeded(func(status BudgetStatus) {
+		notified = append(notified, status.Budget.Name)
+	})
+
+	if err := manager.Record(budgetUsage("openai", "gpt-4", 1.5, 10, nil)); err != nil {
+		t.Fatalf("Record() over a soft budget error = %v, want nil", err)
+	}
+	if len(notified) != 1 || notified[0] != "soft" {
+		t.Errorf("notified = %v, want [soft]", notified)
+	}
+
+	err := manager.Record(budgetUsage("openai", "gpt-4", 1, 10, nil))
+	var tokenErr *TokenTrackerError
+	if !errors.As(err, &tokenErr) || tokenErr.Type != ErrBudgetExceeded {
+		t.Fatalf("Record() over a hard budget error = %v, want %s", err, ErrBudgetExceeded)
+	}
+	// Each budget notifies once per window
+	if len(notified) != 2 || notified[1] != "hard" {
+		t.Errorf("notified = %v, want [soft hard]", notified)
+	}
+
+	if err := manager.Check("openai", "gpt-4", nil); err == nil {
+		t.Error("Check() with an exhausted hard budget should fail")
+	}
+	if err := manager.Check("anthropic", "claude-3-haiku", nil); err != nil {
+		t.Errorf("Check() outside the hard budget's scope error = %v", err)
+	}
+}
+
+func TestBudgetManager_CheckCost(t *testing.T) {
+	manager := NewBudgetManager(nil)
+	_ = manager.SetBudget(Budget{Name: "cost", Provider: "openai", CostLimit: 2, Hard: true})
+	_ = manager.SetBudget(Budget{Name: "tokens", Model: "gpt-4", TokenLimit: 1000, Hard: true})
+	_ = manager.SetBudget(Budget{Name: "soft", CostLimit: 0.1})
+	_ = manager.Record(budgetUsage("openai", "gpt-4", 1.5, 600, nil))
+
+	if err := manager.CheckCost("openai", "gpt-4", nil, 0.5, 400); err != nil {
+		t.Errorf("CheckCost() of usage filling the budgets error = %v", err)
+	}
+	if err := manager.CheckCost("openai", "gpt-3.5-turbo", nil, 0.6, 0); err == nil {
+		t.Error("CheckCost() over the cost budget should fail")
+	}
+	if err := manager.CheckCost("openai", "gpt-4", nil, 0.1, 401); err == nil {
+		t.Error("CheckCost() over the token budget should fail")
+	}
+	if err := manager.CheckCost("anthropic", "claude-3-haiku", nil, 5, 5000); err != nil {
+		t.Errorf("CheckCost() outside the hard budgets' scope error = %v", err)
+	}
+}
+
+func TestBudgetManager_Windows(t *testing.T) {
+	clock := tokentrackertest.NewFakeClock(time.Date(2024, 3, 31, 22, 0, 0, 0, time.UTC))
+	manager := NewBudgetManager(clock)
+	_ = manager.SetBudget(Budget{Name: "daily", Window: BudgetWindowDaily, CostLimit: 10})
+	_ = manager.SetBudget(Budget{Name: "monthly", Window: BudgetWindowMonthly, CostLimit: 100})
+	_ = manager.SetBudget(Budget{Name: "total", CostLimit: 1000})
+
+	_ = manager.Record(budgetUsage("openai", "gpt-4", 4, 0, nil))
+	clock.Advance(4 * time.Hour) // April 1st, 02:00
+	_ = manager.Record(budgetUsage("openai", "gpt-4", 1, 0, nil))
+
+	want := map[string]float64{"daily": 1, "monthly": 1, "total": 5}
+	for _, status := range manager.Statuses() {
+		if status.SpentCost != want[status.Budget.Name] {
+			t.Errorf("%s spent = %v, want %v", status.Budget.Name, status.SpentCost, want[status.Budget.Name])
+		}
+	}
+
+	daily, _ := manager.Remaining("daily")
+	if !daily.WindowStart.Equal(time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("daily window start = %v, want 2024-04-01", daily.WindowStart)
+	}
+
+	// Usage recorded late is charged to the window of its timestamp
+	late := budgetUsage("openai", "gpt-4", 2, 0, nil)
+	late.Timestamp = time.Date(2024, 3, 31, 23, 30, 0, 0, time.UTC)
+	_ = manager.Record(late)
+	want = map[string]float64{"daily": 1, "monthly": 1, "total": 7}
+	for _, status := range manager.Statuses() {
+		if status.SpentCost != want[status.Budget.Name] {
+			t.Errorf("%s spent after late usage = %v, want %v", status.Budget.Name, status.SpentCost, want[status.Budget.Name])
+		}
+	}
+
+	if err := manager.SetBudget(Budget{Name: "weekly", Window: "weekly"}); err == nil {
+		t.Error("SetBudget() with an unknown window should fail")
+	}
+}
+
+func TestDefaultTokenTracker_Budgets(t *testing.T) {
+	manager := NewBudgetManager(nil)
+	_ = manager.SetBudget(Budget{Name: "team-a", Tags: map[string]string{"team": "a"}, CostLimit: 0.015, Hard: true})
+
+	tracker := NewTokenTracker(NewConfig(), WithBudgetManager(manager))
+	tracker.RegisterProvider(&MockProvider{
+		name:           "mock",
+		supportedModel: "mock-model",
+		tokenCount:     TokenCount{InputTokens: 10, TotalTokens: 10},
+		price:          Price{TotalCost: 0.01, Currency: "USD"},
+	})
+
+	track := func() (UsageMetrics, error) {
+		return tracker.TrackUsage(CallParams{
+			Model:     "mock-model",
+			Params:    TokenCountParams{Model: "mock-model", Text: stringPtr("Hello")},
+			StartTime: time.Now(),
+			Tags:      map[string]string{"team": "a"},
+		}, "response")
+	}
+
+	if _, err := track(); err != nil {
+		t.Fatalf("first TrackUsage() error = %v", err)
+	}
+	metrics, err := track()
+	if err == nil {
+		t.Fatal("TrackUsage() over the hard budget should fail")
+	}
+	if metrics.Tags["team"] != "a" || metrics.Price.TotalCost != 0.01 {
+		t.Errorf("metrics = %+v, want the tracked usage alongside the error", metrics)
+	}
+
+	status, _ := tracker.Budgets().Remaining("team-a")
+	if status.SpentCost != 0.02 {
+		t.Errorf("SpentCost = %v, want 0.02", status.SpentCost)
+	}
+}
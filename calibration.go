@@ -0,0 +1,198 @@
+package tokentracker
+
+import (
+	"math"
+	"sync"
+)
+
+// Defaults of CalibrationOptions
+const (
+	DefaultCalibrationSmoothing  = 0.1
+	DefaultCalibrationMinSamples = 5
+)
+
+// calibrationBounds are the smallest and largest ratio of actual to estimated tokens
+// recorded; calls outside them, e.g. with images the estimate skipped, are ignored
+var calibrationBounds = [2]float64{0.2, 5}
+
+// CalibrationOptions configures how estimates are calibrated against the usage
+// providers report
+type CalibrationOptions struct {
+	// Smoothing is the weight of a new observation in a model's moving average
+	// correction factor, between 0 and 1 (0 uses DefaultCalibrationSmoothing)
+	Smoothing float64
+
+	// MinSamples is the number of observations of a model before its factor is
+	// applied (0 uses DefaultCalibrationMinSamples)
+	MinSamples int
+
+	// Factors seeds the correction factors by model, e.g. with the CalibrationFactors
+	// saved by a previous run
+	Factors map[string]CalibrationFactor
+}
+
+// CalibrationFactor is the learned correction of a model's input token estimates
+type CalibrationFactor struct {
+	Factor  float64 `json:"factor"`  // actual input tokens per estimated token
+	Samples int     `json:"samples"` // number of calls the factor was learned from
+}
+
+// WithCalibration makes the tracker learn how far each model's input token estimates
+// are off and correct them. Whenever a tracked response reports its usage and the call
+// has a prompt, the prompt is also counted locally and the ratio of the reported to
+// the estimated input tokens is folded into the model's correction factor. Once a
+// model has enough observations, CountTokens multiplies its estimated input tokens by
+// the factor; counts made by a provider's count_tokens API are left as they are.
+func WithCalibration(opts CalibrationOptions) TrackerOption {
+	return func(t *DefaultTokenTracker) {
+		t.calibration = newCalibration(opts)
+	}
+}
+
+// calibration holds the correction factors of models
+type calibration struct {
+	smoothing  float64
+	minSamples int
+	factors    map[string]CalibrationFactor
+	mu         sync.RWMutex
+}
+
+// newCalibration creates a calibration with the given options
+func newCalibration(opts CalibrationOptions) *calibration {
+	if opts.Smoothing <= 0 || opts.Smoothing > 1 {
+		opts.Smoothing = DefaultCalibrationSmoothing
+	}
+	if opts.MinSamples <= 0 {
+		opts.MinSamples = DefaultCalibrationMinSamples
+	}
+
+	c := &calibration{
+		smoothing:  opts.Smoothing,
+		minSamples: opts.MinSamples,
+		factors:    make(map[string]CalibrationFactor, len(opts.Factors)),
+	}
+	for model, factor := range opts.Factors {
+		if factor.Factor > 0 {
+			c.factors[model] = factor
+		}
+	}
+	return c
+}
+
+// observe folds the ratio of actual to estimated input tokens of a call into the
+// model's factor
+func (c *calibration) observe(model string, estimated, actual int) bool {
+	if estimated <= 0 || actual <= 0 {
+		return false
+	}
+	ratio := float64(actual) / float64(estimated)
+	if ratio < calibrationBounds[0] || ratio > calibrationBounds[1] {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	factor, exists := c.factors[model]
+	if !exists {
+		factor.Factor = ratio
+	} else {
+		factor.Factor += c.smoothing * (ratio - factor.Factor)
+	}
+	factor.Samples++
+	c.factors[model] = factor
+	return true
+}
+
+// factor returns the correction factor of a model once it has enough observations
+func (c *calibration) factor(model string) (float64, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	factor, exists := c.factors[model]
+	if !exists || factor.Samples < c.minSamples {
+		return 0, false
+	}
+	return factor.Factor, true
+}
+
+// apply corrects the input tokens of an estimate with the model's factor. Counts made
+// by a count_tokens API are exact and returned unchanged.
+func (c *calibration) apply(model string, count TokenCount) TokenCount {
+	if count.CountedByAPI {
+		return count
+	}
+	factor, exists := c.factor(model)
+	if !exists {
+		return count
+	}
+
+	count.InputTokens = int(math.Round(float64(count.InputTokens) * factor))
+	count.TotalTokens = count.InputTokens + count.ResponseTokens
+	count.CalibrationFactor = factor
+	return count
+}
+
+// CalibrationFactors returns the correction factors learned by model, for saving them
+// and seeding CalibrationOptions.Factors on the next start. It is empty without
+// WithCalibration.
+func (t *DefaultTokenTracker) CalibrationFactors() map[string]CalibrationFactor {
+	factors := make(map[string]CalibrationFactor)
+	if t.calibration == nil {
+		return factors
+	}
+
+	t.calibration.mu.RLock()
+	defer t.calibration.mu.RUnlock()
+	for model, factor := range t.calibration.factors {
+		factors[model] = factor
+	}
+	return factors
+}
+
+// ResetCalibration forgets the correction factor of a model, or of every model when
+// model is empty
+func (t *DefaultTokenTracker) ResetCalibration(model string) {
+	if t.calibration == nil {
+		return
+	}
+
+	t.calibration.mu.Lock()
+	defer t.calibration.mu.Unlock()
+	if model == "" {
+		t.calibration.factors = make(map[string]CalibrationFactor)
+		return
+	}
+	delete(t.calibration.factors, model)
+}
+
+// RecordCalibration records the input tokens a provider reported for a call whose
+// input was estimated with CountTokens, for calls tracked with TrackReportedUsage.
+// A correction already applied to the estimate is taken into account; exact counts
+// made by a count_tokens API are not observed, since the factor corrects estimates.
+func (t *DefaultTokenTracker) RecordCalibration(model string, estimate TokenCount, actualInputTokens int) error {
+	if t.calibration == nil {
+		return NewError(ErrInvalidParams, "calibration is not enabled", nil)
+	}
+	if model == "" {
+		return NewError(ErrInvalidParams, "model is required", nil)
+	}
+	if estimate.CountedByAPI {
+		return nil
+	}
+
+	estimated := estimate.InputTokens
+	if estimate.CalibrationFactor > 0 {
+		estimated = int(math.Round(float64(estimated) / estimate.CalibrationFactor))
+	}
+	t.calibration.observe(model, estimated, actualInputTokens)
+	return nil
+}
+
+// calibrate observes the estimated and reported input tokens of a tracked call
+func (t *DefaultTokenTracker) calibrate(provider Provider, model string, estimated, actual int) {
+	if t.calibration.observe(model, estimated, actual) {
+		t.logger().Debug("calibration observed", "provider", provider.Name(), "model", model,
+			"estimated_tokens", estimated, "actual_tokens", actual)
+	}
+}
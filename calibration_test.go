@@ -0,0 +1,112 @@
+package tokentracker
+
+import (
+	"math"
+	"testing"
+)
+
+// overestimatingProvider estimates 1000 input tokens and reports the TokenCount passed
+// as the response
+type overestimatingProvider struct {
+	estimatingProvider
+}
+
+func (p *overestimatingProvider) ExtractTokenUsageFromResponse(response interface{}) (TokenCount, error) {
+	count, _ := response.(TokenCount)
+	return count, nil
+}
+
+// newCalibrationTracker creates a tracker calibrating the estimates of acme-1
+func newCalibrationTracker(t *testing.T, opts CalibrationOptions) *DefaultTokenTracker {
+	tracker := newPricedTestTracker(t, WithCalibration(opts))
+	tracker.RegisterProvider(&overestimatingProvider{estimatingProvider{
+		usagePricedProvider: newPricedProvider(tracker.Config(), "acme", "acme-1"),
+		count:               TokenCount{InputTokens: 1000, ResponseTokens: 100},
+	}})
+	return tracker
+}
+
+func TestDefaultTokenTracker_Calibration(t *testing.T) {
+	tracker := newCalibrationTracker(t, CalibrationOptions{MinSamples: 3})
+	params := TokenCountParams{Model: "acme-1", Text: stringPtr("Summarize this document")}
+	call := CallParams{Model: "acme-1", Params: params}
+
+	// The estimates are 18% high
+	for i := 0; i < 2; i++ {
+		if _, err := tracker.TrackUsage(call, TokenCount{InputTokens: 847, ResponseTokens: 50}); err != nil {
+			t.Fatalf("TrackUsage() error = %v", err)
+		}
+	}
+	if count, _ := tracker.CountTokens(params); count.InputTokens != 1000 || count.CalibrationFactor != 0 {
+		t.Errorf("CountTokens() before MinSamples = %+v, want the uncorrected estimate", count)
+	}
+
+	if _, err := tracker.TrackUsage(call, TokenCount{InputTokens: 847, ResponseTokens: 50}); err != nil {
+		t.Fatalf("TrackUsage() error = %v", err)
+	}
+	count, err := tracker.CountTokens(params)
+	if err != nil {
+		t.Fatalf("CountTokens() error = %v", err)
+	}
+	if count.InputTokens != 847 || count.TotalTokens != 847 || math.Abs(count.CalibrationFactor-0.847) > 1e-9 {
+		t.Errorf("CountTokens() = %+v, want the corrected estimate", count)
+	}
+
+	factors := tracker.CalibrationFactors()
+	if factor := factors["acme-1"]; factor.Samples != 3 || math.Abs(factor.Factor-0.847) > 1e-9 {
+		t.Errorf("CalibrationFactors() = %+v", factors)
+	}
+
+	// Calls without a prompt and outliers are not observed
+	_, _ = tracker.TrackUsage(CallParams{Model: "acme-1"}, TokenCount{InputTokens: 500})
+	_, _ = tracker.TrackUsage(call, TokenCount{InputTokens: 90000})
+	if factor := tracker.CalibrationFactors()["acme-1"]; factor.Samples != 3 {
+		t.Errorf("Samples = %d after calls without a usable ratio, want 3", factor.Samples)
+	}
+
+	tracker.ResetCalibration("acme-1")
+	if count, _ := tracker.CountTokens(params); count.InputTokens != 1000 {
+		t.Errorf("CountTokens() after ResetCalibration = %d, want 1000", count.InputTokens)
+	}
+}
+
+func TestDefaultTokenTracker_RecordCalibration(t *testing.T) {
+	tracker := newCalibrationTracker(t, CalibrationOptions{
+		MinSamples: 1,
+		Smoothing:  0.5,
+		Factors:    map[string]CalibrationFactor{"acme-1": {Factor: 0.8, Samples: 10}},
+	})
+	params := TokenCountParams{Model: "acme-1", Text: stringPtr("Hi")}
+
+	estimate, _ := tracker.CountTokens(params)
+	if estimate.InputTokens != 800 {
+		t.Fatalf("CountTokens() with a seeded factor = %d, want 800", estimate.InputTokens)
+	}
+
+	// The corrected estimate is compared as the raw estimate of 1000 tokens
+	if err := tracker.RecordCalibration("acme-1", estimate, 900); err != nil {
+		t.Fatalf("RecordCalibration() error = %v", err)
+	}
+	if factor := tracker.CalibrationFactors()["acme-1"]; math.Abs(factor.Factor-0.85) > 1e-9 || factor.Samples != 11 {
+		t.Errorf("factor = %+v, want 0.85 from 11 samples", factor)
+	}
+
+	// Counts made by a count_tokens API are exact: neither corrected nor observed
+	exact := TokenCount{InputTokens: 1000, TotalTokens: 1000, CountedByAPI: true}
+	if count := tracker.calibration.apply("acme-1", exact); count.InputTokens != 1000 || count.CalibrationFactor != 0 {
+		t.Errorf("apply() to an API count = %+v, want it unchanged", count)
+	}
+	if err := tracker.RecordCalibration("acme-1", exact, 500); err != nil {
+		t.Fatalf("RecordCalibration() error = %v", err)
+	}
+	if factor := tracker.CalibrationFactors()["acme-1"]; factor.Samples != 11 {
+		t.Errorf("Samples = %d after an API count, want 11", factor.Samples)
+	}
+
+	if err := NewTokenTracker(NewConfig()).RecordCalibration("acme-1", estimate, 900); err == nil {
+		t.Error("RecordCalibration() without WithCalibration should fail")
+	}
+	if len(NewTokenTracker(NewConfig()).CalibrationFactors()) != 0 {
+		t.Error("CalibrationFactors() without WithCalibration should be empty")
+	}
+}
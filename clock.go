@@ -0,0 +1,49 @@
+package tokentracker
+
+import (
+	"time"
+
+	"github.com/TrustSight-io/tokentracker/common"
+)
+
+// Clock is the source of time used by the tracker, see common.Clock
+type Clock = common.Clock
+
+// Ticker delivers ticks at intervals, see common.Ticker
+type Ticker = common.Ticker
+
+// Timer is a single scheduled event, see common.Timer
+type Timer = common.Timer
+
+// SystemClock is the Clock backed by the time package
+var SystemClock = common.SystemClock
+
+// WithClock makes the tracker and its configuration use the given clock for all
+// timestamps, durations, schedulers and window calculations
+func WithClock(clock Clock) TrackerOption {
+	return func(t *DefaultTokenTracker) {
+		t.config.SetClock(clock)
+	}
+}
+
+// clock returns the clock used by the tracker
+func (t *DefaultTokenTracker) clock() Clock {
+	return t.config.GetClock()
+}
+
+// configClock delegates to the clock of a configuration, so components created
+// before WithClock or Config.SetClock pick up the clock set later
+type configClock struct {
+	config *Config
+}
+
+func (c configClock) Now() time.Time                  { return c.config.GetClock().Now() }
+func (c configClock) Since(t time.Time) time.Duration { return c.config.GetClock().Since(t) }
+
+func (c configClock) NewTicker(d time.Duration) Ticker {
+	return c.config.GetClock().NewTicker(d)
+}
+
+func (c configClock) AfterFunc(d time.Duration, f func()) Timer {
+	return c.config.GetClock().AfterFunc(d, f)
+}
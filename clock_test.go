@@ -0,0 +1,91 @@
+package tokentracker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker/tokentrackertest"
+)
+
+func TestWithClock_TrackUsage(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := tokentrackertest.NewFakeClock(start)
+
+	tracker := NewTokenTracker(NewConfig(), WithClock(clock))
+	tracker.RegisterProvider(&MockProvider{
+		name:           "mock",
+		supportedModel: "mock-model",
+		tokenCount:     TokenCount{InputTokens: 10, TotalTokens: 10},
+		price:          Price{TotalCost: 0.01, Currency: "USD"},
+	})
+
+	clock.Advance(1500 * time.Millisecond)
+
+	metrics, err := tracker.TrackUsage(CallParams{
+		Model:     "mock-model",
+		Params:    TokenCountParams{Model: "mock-model", Text: stringPtr("Hello")},
+		StartTime: start,
+	}, "response")
+	if err != nil {
+		t.Fatalf("TrackUsage() error = %v", err)
+	}
+
+	if metrics.Duration != 1500*time.Millisecond {
+		t.Errorf("Duration = %v, want 1.5s", metrics.Duration)
+	}
+	if !metrics.Timestamp.Equal(start.Add(1500 * time.Millisecond)) {
+		t.Errorf("Timestamp = %v, want the fake clock's time", metrics.Timestamp)
+	}
+}
+
+func TestWithClock_TopPromptsWindow(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := tokentrackertest.NewFakeClock(start)
+
+	// The clock option applies even when given after the top prompts option
+	tracker := NewTokenTracker(NewConfig(), WithTopPrompts(TopPromptsOptions{Window: time.Hour}), WithClock(clock))
+	tracker.RegisterProvider(&MockProvider{
+		name:           "mock",
+		supportedModel: "mock-model",
+		tokenCount:     TokenCount{InputTokens: 10, TotalTokens: 10},
+		price:          Price{TotalCost: 0.01, Currency: "USD"},
+	})
+
+	_, _ = tracker.TrackUsage(CallParams{
+		Model:     "mock-model",
+		Params:    TokenCountParams{Model: "mock-model", Text: stringPtr("Hello")},
+		StartTime: start,
+	}, "response")
+
+	if len(tracker.TopPrompts()) != 1 {
+		t.Fatal("TopPrompts() is empty right after tracking")
+	}
+
+	clock.Advance(2 * time.Hour)
+	if got := tracker.TopPrompts(); len(got) != 0 {
+		t.Errorf("TopPrompts() after the window = %+v, want empty", got)
+	}
+}
+
+func TestConfig_PricingTimerUsesClock(t *testing.T) {
+	clock := tokentrackertest.NewFakeClock(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+
+	config := NewConfig()
+	config.SetClock(clock)
+	config.EnableAutomaticPricingUpdates(time.Hour)
+
+	if clock.Waiters() != 1 {
+		t.Fatalf("Waiters() = %d, want the pricing timer", clock.Waiters())
+	}
+
+	// The timer re-arms itself each interval
+	clock.Advance(3 * time.Hour)
+	if clock.Waiters() != 1 {
+		t.Errorf("Waiters() after firing = %d, want 1", clock.Waiters())
+	}
+
+	config.DisableAutomaticPricingUpdates()
+	if clock.Waiters() != 0 {
+		t.Errorf("Waiters() after disabling = %d, want 0", clock.Waiters())
+	}
+}
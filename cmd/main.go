@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"time"
@@ -10,10 +11,19 @@ import (
 	"github.com/TrustSight-io/tokentracker/sdkwrappers"
 )
 
+// format holds the number formatting options used for all CLI output
+var format = tokentracker.DefaultFormatOptions()
+
 func main() {
 	// Create a new configuration
 	config := tokentracker.NewConfig()
 
+	// Use locale-aware number formatting if requested
+	if locale := os.Getenv("TOKENTRACKER_LOCALE"); locale != "" {
+		config.SetFormatOptions(tokentracker.LocaleFormatOptions(locale))
+	}
+	format = config.GetFormatOptions()
+
 	// Create a new token tracker
 	tracker := tokentracker.NewTokenTracker(config)
 
@@ -27,7 +37,10 @@ func main() {
 	tracker.RegisterProvider(geminiProvider)
 
 	// Enable automatic pricing updates (every 24 hours)
-	config.EnableAutomaticPricingUpdates(24 * time.Hour)
+	if err := tracker.StartPricingUpdater(context.Background(), 24*time.Hour); err != nil {
+		fmt.Printf("Warning: Unable to start pricing updates: %v\n", err)
+	}
+	defer tracker.Close()
 
 	// Enable usage logging
 	logPath := "token_usage.log"
@@ -68,8 +81,8 @@ func demoBasicTokenCounting(tracker *tokentracker.DefaultTokenTracker) {
 
 	fmt.Printf("Text: %s\n", text)
 	fmt.Printf("Model: %s\n", params.Model)
-	fmt.Printf("Input tokens: %d\n", tokenCount.InputTokens)
-	fmt.Printf("Total tokens: %d\n\n", tokenCount.TotalTokens)
+	fmt.Printf("Input tokens: %s\n", format.FormatTokens(tokenCount.InputTokens))
+	fmt.Printf("Total tokens: %s\n\n", format.FormatTokens(tokenCount.TotalTokens))
 }
 
 func demoChatTokenCounting(tracker *tokentracker.DefaultTokenTracker) {
@@ -106,9 +119,9 @@ func demoChatTokenCounting(tracker *tokentracker.DefaultTokenTracker) {
 		}
 
 		fmt.Printf("Model: %s\n", model)
-		fmt.Printf("Input tokens: %d\n", tokenCount.InputTokens)
-		fmt.Printf("Response tokens (estimated): %d\n", tokenCount.ResponseTokens)
-		fmt.Printf("Total tokens: %d\n\n", tokenCount.TotalTokens)
+		fmt.Printf("Input tokens: %s\n", format.FormatTokens(tokenCount.InputTokens))
+		fmt.Printf("Response tokens (estimated): %s\n", format.FormatTokens(tokenCount.ResponseTokens))
+		fmt.Printf("Total tokens: %s\n\n", format.FormatTokens(tokenCount.TotalTokens))
 	}
 }
 
@@ -137,10 +150,10 @@ func demoPriceCalculation(tracker *tokentracker.DefaultTokenTracker) {
 		}
 
 		fmt.Printf("Model: %s\n", mp.model)
-		fmt.Printf("Input tokens: %d, Output tokens: %d\n", mp.inputTokens, mp.outputTokens)
-		fmt.Printf("Input cost: $%.6f\n", price.InputCost)
-		fmt.Printf("Output cost: $%.6f\n", price.OutputCost)
-		fmt.Printf("Total cost: $%.6f %s\n\n", price.TotalCost, price.Currency)
+		fmt.Printf("Input tokens: %s, Output tokens: %s\n", format.FormatTokens(mp.inputTokens), format.FormatTokens(mp.outputTokens))
+		fmt.Printf("Input cost: $%s\n", format.FormatCost(price.InputCost, price.Currency))
+		fmt.Printf("Output cost: $%s\n", format.FormatCost(price.OutputCost, price.Currency))
+		fmt.Printf("Total cost: $%s %s\n\n", format.FormatCost(price.TotalCost, price.Currency), price.Currency)
 	}
 }
 
@@ -182,10 +195,10 @@ func demoUsageTracking(tracker *tokentracker.DefaultTokenTracker) {
 	}
 
 	fmt.Printf("Model: %s\n", callParams.Model)
-	fmt.Printf("Input tokens: %d\n", usage.TokenCount.InputTokens)
-	fmt.Printf("Response tokens: %d\n", usage.TokenCount.ResponseTokens)
-	fmt.Printf("Total tokens: %d\n", usage.TokenCount.TotalTokens)
-	fmt.Printf("Total cost: $%.6f %s\n", usage.Price.TotalCost, usage.Price.Currency)
+	fmt.Printf("Input tokens: %s\n", format.FormatTokens(usage.TokenCount.InputTokens))
+	fmt.Printf("Response tokens: %s\n", format.FormatTokens(usage.TokenCount.ResponseTokens))
+	fmt.Printf("Total tokens: %s\n", format.FormatTokens(usage.TokenCount.TotalTokens))
+	fmt.Printf("Total cost: $%s %s\n", format.FormatCost(usage.Price.TotalCost, usage.Price.Currency), usage.Price.Currency)
 	fmt.Printf("Duration: %v\n", usage.Duration)
 	fmt.Printf("Timestamp: %v\n", usage.Timestamp)
 	fmt.Printf("Provider: %s\n\n", usage.Provider)
@@ -0,0 +1,15 @@
+package common
+
+// AnthropicCountTokensRequest is the body of a /v1/messages/count_tokens request
+type AnthropicCountTokensRequest struct {
+	Model    string                   `json:"model"`
+	System   string                   `json:"system,omitempty"`
+	Messages []AnthropicMessage       `json:"messages"`
+	Tools    []map[string]interface{} `json:"tools,omitempty"`
+}
+
+// AnthropicMessage is a message in Anthropic's Messages API format
+type AnthropicMessage struct {
+	Role    string      `json:"role"`
+	Content interface{} `json:"content"`
+}
@@ -0,0 +1,32 @@
+package common
+
+import "net/http"
+
+// Response headers naming the organization and project OpenAI billed a call to
+const (
+	OpenAIOrganizationHeader = "Openai-Organization"
+	OpenAIProjectHeader      = "Openai-Project"
+)
+
+// minAPIKeyIDLength is the shortest API key APIKeyID reveals the ends of
+const minAPIKeyIDLength = 16
+
+// APIKeyID returns a non-secret identifier of an API key in the redacted form provider
+// consoles list keys in, e.g. "sk-...abcd" (empty for keys too short to redact)
+func APIKeyID(apiKey string) string {
+	if len(apiKey) < minAPIKeyIDLength {
+		return ""
+	}
+	return apiKey[:3] + "..." + apiKey[len(apiKey)-4:]
+}
+
+// ApplyOpenAIHeaders copies the organization and project of OpenAI response headers
+// onto the usage metrics, keeping the current values when a header is missing
+func (m *UsageMetrics) ApplyOpenAIHeaders(header http.Header) {
+	if organization := header.Get(OpenAIOrganizationHeader); organization != "" {
+		m.Organization = organization
+	}
+	if project := header.Get(OpenAIProjectHeader); project != "" {
+		m.ProviderProjectID = project
+	}
+}
@@ -0,0 +1,61 @@
+package common
+
+import "time"
+
+// Clock is the source of time for timestamps, durations, timers and tickers.
+// Injecting a fake clock makes time-dependent behavior deterministic in tests.
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+
+	// Since returns the time elapsed since t
+	Since(t time.Time) time.Duration
+
+	// NewTicker returns a ticker delivering ticks every d
+	NewTicker(d time.Duration) Ticker
+
+	// AfterFunc calls f in its own goroutine once d has elapsed
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Ticker delivers ticks at intervals
+type Ticker interface {
+	// Chan returns the channel on which ticks are delivered
+	Chan() <-chan time.Time
+
+	// Stop turns off the ticker
+	Stop()
+}
+
+// Timer is a single event scheduled by Clock.AfterFunc
+type Timer interface {
+	// Stop prevents the timer from firing
+	Stop() bool
+
+	// Reset changes the timer to fire after d
+	Reset(d time.Duration) bool
+}
+
+// SystemClock is the Clock backed by the time package
+var SystemClock Clock = systemClock{}
+
+// systemClock implements Clock with the time package
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                  { return time.Now() }
+func (systemClock) Since(t time.Time) time.Duration { return time.Since(t) }
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// systemTicker adapts time.Ticker to Ticker
+type systemTicker struct {
+	*time.Ticker
+}
+
+func (t systemTicker) Chan() <-chan time.Time { return t.C }
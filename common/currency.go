@@ -0,0 +1,41 @@
+package common
+
+import "strings"
+
+// CurrencyConverter converts amounts between ISO 4217 currencies. Implementations
+// must be linear, i.e. convert every amount at the same rate.
+type CurrencyConverter interface {
+	// Convert converts amount from one currency to another
+	Convert(amount float64, from, to string) (float64, error)
+}
+
+// Convert returns the price in another currency, including its unit prices
+func (p Price) Convert(converter CurrencyConverter, to string) (Price, error) {
+	if to == "" || normalizeCurrency(p.Currency) == normalizeCurrency(to) {
+		return p, nil
+	}
+
+	rate, err := converter.Convert(1, normalizeCurrency(p.Currency), normalizeCurrency(to))
+	if err != nil {
+		return Price{}, err
+	}
+
+	p.InputCost *= rate
+	p.OutputCost *= rate
+	p.TotalCost *= rate
+	p.ReasoningCost *= rate
+	p.TrainingCost *= rate
+	p.EffectiveInputPricePer1K *= rate
+	p.EffectiveOutputPricePer1K *= rate
+	p.BlendedPricePer1K *= rate
+	p.Currency = normalizeCurrency(to)
+	return p, nil
+}
+
+// normalizeCurrency upper-cases a currency code; prices without a currency are in USD
+func normalizeCurrency(currency string) string {
+	if currency == "" {
+		return "USD"
+	}
+	return strings.ToUpper(currency)
+}
@@ -0,0 +1,19 @@
+package common
+
+import "encoding/json"
+
+// GeminiCountRequest is a Gemini request counted as a whole, with the system
+// instruction and tool declarations in fields of their own as Gemini accounts for them
+type GeminiCountRequest struct {
+	SystemInstruction string                      `json:"systemInstruction,omitempty"`
+	Contents          []string                    `json:"contents"` // text parts of the text and messages
+	Tools             []GeminiFunctionDeclaration `json:"tools,omitempty"`
+}
+
+// GeminiFunctionDeclaration is a function declared to Gemini, with its parameters as a
+// JSON schema
+type GeminiFunctionDeclaration struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
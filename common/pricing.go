@@ -0,0 +1,91 @@
+package common
+
+import "sort"
+
+// BillableUsage are the token counts a call is billed for
+type BillableUsage struct {
+	// InputTokens are all input tokens, including cached and cache write tokens
+	InputTokens  int
+	OutputTokens int
+
+	// CachedInputTokens are the input tokens read from the prompt cache
+	CachedInputTokens int
+
+	// CacheWriteTokens are the input tokens written to the prompt cache
+	CacheWriteTokens int
+
+	// AudioInputTokens are the input tokens of audio content
+	AudioInputTokens int
+
+	// ReasoningTokens are the output tokens spent on reasoning, billed as output
+	ReasoningTokens int
+
+	// Batch marks calls made through a batch API
+	Batch bool
+}
+
+// Cost calculates the price of a call: input tokens read from or written to the
+// prompt cache and audio input tokens are billed at their own prices, calls with long
+// inputs at the rates of their tier, and batch calls get the batch discount. Reasoning
+// tokens are billed as output; their share of the output cost is reported separately.
+func (p ModelPricing) Cost(usage BillableUsage) Price {
+	rates := p.tier(usage.InputTokens)
+
+	cachedPrice := rates.CachedInputPricePerToken
+	if cachedPrice == 0 {
+		cachedPrice = rates.InputPricePerToken
+	}
+	writePrice := p.CacheWritePricePerToken
+	if writePrice == 0 {
+		writePrice = rates.InputPricePerToken
+	}
+
+	audioPrice := p.AudioInputPricePerToken
+	if audioPrice == 0 {
+		audioPrice = rates.InputPricePerToken
+	}
+
+	uncached := usage.InputTokens - usage.CachedInputTokens - usage.CacheWriteTokens - usage.AudioInputTokens
+	if uncached < 0 {
+		uncached = 0
+	}
+
+	inputCost := float64(uncached)*rates.InputPricePerToken +
+		float64(usage.CachedInputTokens)*cachedPrice +
+		float64(usage.CacheWriteTokens)*writePrice +
+		float64(usage.AudioInputTokens)*audioPrice
+	outputCost := float64(usage.OutputTokens) * rates.OutputPricePerToken
+	reasoningCost := float64(usage.ReasoningTokens) * rates.OutputPricePerToken
+
+	if usage.Batch && p.BatchDiscount > 0 {
+		inputCost *= 1 - p.BatchDiscount
+		outputCost *= 1 - p.BatchDiscount
+		reasoningCost *= 1 - p.BatchDiscount
+	}
+
+	return Price{
+		InputCost:     inputCost,
+		OutputCost:    outputCost,
+		TotalCost:     inputCost + outputCost,
+		Currency:      p.Currency,
+		ReasoningCost: reasoningCost,
+	}.WithUnitPrices(usage.InputTokens, usage.OutputTokens)
+}
+
+// tier returns the rates that apply to a call with the given number of input tokens
+func (p ModelPricing) tier(inputTokens int) PricingTier {
+	rates := PricingTier{
+		InputPricePerToken:       p.InputPricePerToken,
+		OutputPricePerToken:      p.OutputPricePerToken,
+		CachedInputPricePerToken: p.CachedInputPricePerToken,
+	}
+
+	tiers := append([]PricingTier(nil), p.Tiers...)
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].AboveInputTokens < tiers[j].AboveInputTokens })
+	for _, tier := range tiers {
+		if inputTokens > tier.AboveInputTokens {
+			rates = tier
+		}
+	}
+	return rates
+}
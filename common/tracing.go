@@ -0,0 +1,55 @@
+package common
+
+import (
+	"context"
+	"fmt"
+)
+
+// TraceContext contains the identifiers from a W3C traceparent header
+type TraceContext struct {
+	TraceID string
+	SpanID  string
+	Sampled bool
+}
+
+// traceContextKey is the context key used to store a TraceContext
+type traceContextKey struct{}
+
+// Traceparent formats the trace context as a W3C traceparent header value
+func (tc TraceContext) Traceparent() string {
+	flags := "00"
+	if tc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", tc.TraceID, tc.SpanID, flags)
+}
+
+// IsValid reports whether the trace context carries a trace and span ID
+func (tc TraceContext) IsValid() bool {
+	return tc.TraceID != "" && tc.SpanID != ""
+}
+
+// ContextWithTraceContext returns a copy of ctx carrying the given trace context
+func ContextWithTraceContext(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, tc)
+}
+
+// TraceContextFromContext returns the trace context stored in ctx, if any
+func TraceContextFromContext(ctx context.Context) (TraceContext, bool) {
+	if ctx == nil {
+		return TraceContext{}, false
+	}
+	tc, ok := ctx.Value(traceContextKey{}).(TraceContext)
+	if !ok || !tc.IsValid() {
+		return TraceContext{}, false
+	}
+	return tc, true
+}
+
+// ApplyTraceContext copies the trace and span IDs found in ctx onto the usage metrics
+func (m *UsageMetrics) ApplyTraceContext(ctx context.Context) {
+	if tc, ok := TraceContextFromContext(ctx); ok {
+		m.TraceID = tc.TraceID
+		m.SpanID = tc.SpanID
+	}
+}
@@ -1,4 +1,5 @@
-// Package common contains shared types used across the tokentracker project
+// Package common contains the types shared by the tokentracker packages. The root
+// package re-exports them as type aliases, so values need no conversion between them.
 package common
 
 import "time"
@@ -8,31 +9,140 @@ type ModelPricing struct {
 	InputPricePerToken  float64
 	OutputPricePerToken float64
 	Currency            string
+
+	// CachedInputPricePerToken is the price of input tokens read from the prompt
+	// cache (0 bills them at the input price)
+	CachedInputPricePerToken float64 `json:",omitempty"`
+
+	// CacheWritePricePerToken is the price of input tokens written to the prompt
+	// cache (0 bills them at the input price)
+	CacheWritePricePerToken float64 `json:",omitempty"`
+
+	// AudioInputPricePerToken is the price of audio input tokens (0 bills them at
+	// the input price)
+	AudioInputPricePerToken float64 `json:",omitempty"`
+
+	// Tiers are the rates of calls with long inputs, see PricingTier
+	Tiers []PricingTier `json:",omitempty"`
+
+	// BatchDiscount is the fraction taken off the cost of batch calls, e.g. 0.5
+	BatchDiscount float64 `json:",omitempty"`
+
+	// TrainingPricePerToken is the price of the tokens a fine-tuning job trains on,
+	// set on the fine-tuned pricing of a base model
+	TrainingPricePerToken float64 `json:",omitempty"`
+}
+
+// PricingTier replaces the rates of a model for calls whose input exceeds
+// AboveInputTokens, e.g. Gemini 1.5 prompts longer than 128K tokens
+type PricingTier struct {
+	AboveInputTokens         int
+	InputPricePerToken       float64
+	OutputPricePerToken      float64
+	CachedInputPricePerToken float64 `json:",omitempty"`
 }
 
 // TokenCount contains token counting results
 type TokenCount struct {
-	InputTokens    int
-	ResponseTokens int
-	TotalTokens    int
+	InputTokens    int `json:"input_tokens"`
+	ResponseTokens int `json:"response_tokens"`
+	TotalTokens    int `json:"total_tokens"`
+	// CachedInputTokens are the input tokens read from the prompt cache, included in InputTokens
+	CachedInputTokens int `json:"cached_input_tokens,omitempty"`
+	// CacheWriteTokens are the input tokens written to the prompt cache, included in InputTokens
+	CacheWriteTokens int `json:"cache_write_tokens,omitempty"`
+	// ToolCallTokens are the output tokens of the tool calls in a response, included in ResponseTokens.
+	// Providers do not report them; they are counted from the calls' names and arguments.
+	ToolCallTokens int `json:"tool_call_tokens,omitempty"`
+	// ReasoningTokens are the output tokens o-series models spend on hidden reasoning, included in ResponseTokens
+	ReasoningTokens int `json:"reasoning_tokens,omitempty"`
+	// AudioTokens and VideoTokens are the input tokens of audio and video content, included in InputTokens
+	AudioTokens int `json:"audio_tokens,omitempty"`
+	VideoTokens int `json:"video_tokens,omitempty"`
+	// Normalization is the normalization profile applied to the input before counting (empty when none)
+	Normalization string `json:"normalization,omitempty"`
+	// Algorithm is the counting algorithm that produced the count (empty when the provider has only one)
+	Algorithm string `json:"algorithm,omitempty"`
+	// CountedByAPI reports that InputTokens were counted by the provider's count_tokens API
+	// rather than estimated locally; such counts are exact and never calibrated
+	CountedByAPI bool `json:"counted_by_api,omitempty"`
+	// CalibrationFactor is the correction applied to the estimated InputTokens (0 when none)
+	CalibrationFactor float64 `json:"calibration_factor,omitempty"`
+	// EstimatedInputTokens is the local estimate of InputTokens reported by the provider (0 when not estimated)
+	EstimatedInputTokens int `json:"estimated_input_tokens,omitempty"`
+	// TrainingTokens are the tokens a fine-tuning job trained on, billed apart from InputTokens
+	TrainingTokens int `json:"training_tokens,omitempty"`
 }
 
 // Price contains pricing information
 type Price struct {
-	InputCost  float64
-	OutputCost float64
-	TotalCost  float64
-	Currency   string
+	InputCost  float64 `json:"input_cost"`
+	OutputCost float64 `json:"output_cost"`
+	TotalCost  float64 `json:"total_cost"`
+	Currency   string  `json:"currency"`
+
+	// ReasoningCost is the part of OutputCost spent on reasoning tokens
+	ReasoningCost float64 `json:"reasoning_cost,omitempty"`
+
+	// TrainingCost is the part of TotalCost spent on fine-tuning training tokens
+	TrainingCost float64 `json:"training_cost,omitempty"`
+
+	// Unit prices derived from the costs and token counts (0 when there were no such tokens)
+	EffectiveInputPricePer1K  float64 `json:"effective_input_price_per_1k,omitempty"`
+	EffectiveOutputPricePer1K float64 `json:"effective_output_price_per_1k,omitempty"`
+	BlendedPricePer1K         float64 `json:"blended_price_per_1k,omitempty"`
+}
+
+// WithUnitPrices returns the price with the per-1K unit prices derived from the token counts
+func (p Price) WithUnitPrices(inputTokens, outputTokens int) Price {
+	p.EffectiveInputPricePer1K = per1K(p.InputCost, inputTokens)
+	p.EffectiveOutputPricePer1K = per1K(p.OutputCost, outputTokens)
+	p.BlendedPricePer1K = per1K(p.TotalCost, inputTokens+outputTokens)
+	return p
+}
+
+// per1K returns the cost of 1000 tokens
+func per1K(cost float64, tokens int) float64 {
+	if tokens <= 0 {
+		return 0
+	}
+	return cost / float64(tokens) * 1000
 }
 
 // UsageMetrics contains complete usage information
 type UsageMetrics struct {
-	TokenCount TokenCount
-	Price      Price
-	Duration   time.Duration
-	Timestamp  time.Time
-	Model      string
-	Provider   string
+	TokenCount TokenCount        `json:"token_count"`
+	Price      Price             `json:"price"`
+	Duration   time.Duration     `json:"duration"`
+	Timestamp  time.Time         `json:"timestamp"`
+	Model      string            `json:"model"`
+	Provider   string            `json:"provider"`
+	TraceID    string            `json:"trace_id,omitempty"`   // W3C trace ID of the request that produced this usage
+	SpanID     string            `json:"span_id,omitempty"`    // W3C span ID of the request that produced this usage
+	Tags       map[string]string `json:"tags,omitempty"`       // caller-defined metadata, e.g. feature or team
+	UserID     string            `json:"user_id,omitempty"`    // end user the call was made for
+	ProjectID  string            `json:"project_id,omitempty"` // project or tenant the call is attributed to
+	CostTier   string            `json:"cost_tier,omitempty"`  // cost tier of the model when it was tracked
+
+	// APIKeyID identifies the provider API key the call used, never the key itself
+	APIKeyID string `json:"api_key_id,omitempty"`
+	// Organization is the provider organization the call was billed to
+	Organization string `json:"organization,omitempty"`
+	// ProviderProjectID is the provider project the call was billed to, e.g. an OpenAI proj_ ID
+	ProviderProjectID string `json:"provider_project_id,omitempty"`
+
+	// CompletionID identifies the call across trackers: its idempotency key, or the
+	// provider-scoped completion or request ID of its response
+	CompletionID string `json:"completion_id,omitempty"`
+
+	// SampleRate is the number of calls a record stored by a sampling tracker stands
+	// for (0 and 1 mean the record stands for itself)
+	SampleRate int `json:"sample_rate,omitempty"`
+}
+
+// SampleWeight returns the number of calls the record stands for
+func (m UsageMetrics) SampleWeight() int {
+	return max(m.SampleRate, 1)
 }
 
 // TokenUsage represents token usage information extracted from API responses
@@ -46,4 +156,9 @@ type TokenUsage struct {
 	PromptTokens   int    // Some APIs use "prompt" instead of "input"
 	ResponseTokens int    // Some APIs use "response" instead of "output"
 	RequestID      string // Some APIs provide a request ID
+
+	CachedInputTokens int // Input tokens read from the prompt cache, included in InputTokens
+	CacheWriteTokens  int // Input tokens written to the prompt cache, included in InputTokens
+	AudioInputTokens  int // Input tokens of audio content, included in InputTokens
+	ReasoningTokens   int // Output tokens spent on reasoning, included in OutputTokens
 }
@@ -2,36 +2,93 @@ package tokentracker
 
 import (
 	"encoding/json"
+	"fmt"
+	"log/slog"
 	"os"
 	"sync"
 	"time"
+
+	"github.com/TrustSight-io/tokentracker/common"
 )
 
-// ModelPricing contains pricing information for a specific model
-type ModelPricing struct {
-	InputPricePerToken  float64
-	OutputPricePerToken float64
-	Currency            string
-}
+// ModelPricing contains pricing information for a specific model, see common.ModelPricing
+type ModelPricing = common.ModelPricing
+
+// ConfigSchemaVersion is the version of the configuration file format written by
+// SaveToFile. Files without a version use the format of version 1, keyed by Go field
+// names; files of newer versions are rejected.
+const ConfigSchemaVersion = 2
+
+// DefaultPricingUpdateInterval is the interval of automatic pricing updates loaded
+// from a configuration file without one
+const DefaultPricingUpdateInterval = 24 * time.Hour
 
 // ProviderConfig contains configuration for a specific provider
 type ProviderConfig struct {
-	Models map[string]ModelPricing
+	Models map[string]ModelPricing `json:"models"`
+
+	// Factory names the registered ProviderFactory that RegisterConfiguredProviders
+	// uses for this provider; empty means the factory registered under the provider's name
+	Factory string `json:"factory,omitempty"`
+
+	// History holds the effective-dated pricing of models for pricing past calls
+	History map[string][]PricePeriod `json:"history,omitempty"`
+
+	// Tokenizer names the registered Tokenizer the provider counts text with offline;
+	// empty means the provider's default
+	Tokenizer string `json:"tokenizer,omitempty"`
 }
 
 // Config contains the configuration for the token tracker
 type Config struct {
-	Providers          map[string]ProviderConfig
-	AutoUpdatePricing  bool
-	UsageLogEnabled    bool
-	usageLogPath       string
-	pricingUpdateTimer *time.Timer
-	mu                 sync.RWMutex
+	SchemaVersion         int                       `json:"schema_version"`
+	Providers             map[string]ProviderConfig `json:"providers"`
+	AutoUpdatePricing     bool                      `json:"auto_update_pricing"`
+	PricingUpdateInterval time.Duration             `json:"pricing_update_interval,omitempty"`
+	UsageLogEnabled       bool                      `json:"usage_log_enabled"`
+	UsageLogPath          string                    `json:"usage_log_path,omitempty"`
+	UsageLog              UsageLogOptions           `json:"usage_log"`
+	Format                FormatOptions             `json:"format"`
+	Normalization         NormalizationOptions      `json:"normalization"`
+	CountingFlags         map[string]CountingFlag   `json:"counting_flags,omitempty"`
+	CostTiers             CostTierThresholds        `json:"cost_tiers"`
+	Models                []ModelEntry              `json:"models,omitempty"`
+	DefaultCurrency       string                    `json:"default_currency,omitempty"`
+	catalog               *ModelCatalog
+	currencyConverter     CurrencyConverter
+	tokenCache            TokenCountCache
+	resilience            *Resilience
+	pricingUpdateTimer    Timer
+	pricingUpdater        func()
+	clock                 Clock
+	logger                *slog.Logger
+	responseEstimator     ResponseEstimator
+	shadowReporter        func(ShadowCount)
+	reloadListeners       []func(ConfigReload)
+	env                   []string // overrides applied by ApplyEnv, again after each reload
+	envOverrides          []envOverride
+	mu                    sync.RWMutex
+}
+
+// configV1 is the configuration file format before schema versions, keyed by Go
+// field names
+type configV1 struct {
+	Providers         map[string]ProviderConfig
+	AutoUpdatePricing bool
+	UsageLogEnabled   bool
+	UsageLog          UsageLogOptions
+	Format            FormatOptions
+	Normalization     NormalizationOptions
+	CountingFlags     map[string]CountingFlag
+	CostTiers         CostTierThresholds
+	Models            []ModelEntry
+	DefaultCurrency   string
 }
 
 // NewConfig creates a new configuration with default values
 func NewConfig() *Config {
 	return &Config{
+		SchemaVersion: ConfigSchemaVersion,
 		Providers: map[string]ProviderConfig{
 			"openai": {
 				Models: map[string]ModelPricing{
@@ -45,24 +102,42 @@ func NewConfig() *Config {
 						OutputPricePerToken: 0.00006,
 						Currency:            "USD",
 					},
+					"text-embedding-3-small": {
+						InputPricePerToken: 0.00000002,
+						Currency:           "USD",
+					},
+					"text-embedding-3-large": {
+						InputPricePerToken: 0.00000013,
+						Currency:           "USD",
+					},
+					"text-embedding-ada-002": {
+						InputPricePerToken: 0.0000001,
+						Currency:           "USD",
+					},
 				},
 			},
 			"anthropic": {
 				Models: map[string]ModelPricing{
 					"claude-3-haiku": {
-						InputPricePerToken:  0.00000025,
-						OutputPricePerToken: 0.00000125,
-						Currency:            "USD",
+						InputPricePerToken:       0.00000025,
+						OutputPricePerToken:      0.00000125,
+						CachedInputPricePerToken: 0.00000003,
+						CacheWritePricePerToken:  0.0000003,
+						Currency:                 "USD",
 					},
 					"claude-3-sonnet": {
-						InputPricePerToken:  0.000003,
-						OutputPricePerToken: 0.000015,
-						Currency:            "USD",
+						InputPricePerToken:       0.000003,
+						OutputPricePerToken:      0.000015,
+						CachedInputPricePerToken: 0.0000003,
+						CacheWritePricePerToken:  0.00000375,
+						Currency:                 "USD",
 					},
 					"claude-3-opus": {
-						InputPricePerToken:  0.00001,
-						OutputPricePerToken: 0.00003,
-						Currency:            "USD",
+						InputPricePerToken:       0.00001,
+						OutputPricePerToken:      0.00003,
+						CachedInputPricePerToken: 0.000001,
+						CacheWritePricePerToken:  0.0000125,
+						Currency:                 "USD",
 					},
 				},
 			},
@@ -78,46 +153,189 @@ func NewConfig() *Config {
 						OutputPricePerToken: 0.00003,
 						Currency:            "USD",
 					},
+					"text-embedding-004": {
+						InputPricePerToken: 0.0000001,
+						Currency:           "USD",
+					},
+				},
+			},
+			"mistral": {
+				Models: map[string]ModelPricing{
+					"mistral-small": {
+						InputPricePerToken:  0.000002,
+						OutputPricePerToken: 0.000006,
+						Currency:            "USD",
+					},
+					"mistral-medium": {
+						InputPricePerToken:  0.0000027,
+						OutputPricePerToken: 0.0000081,
+						Currency:            "USD",
+					},
+					"mistral-large": {
+						InputPricePerToken:  0.000008,
+						OutputPricePerToken: 0.000024,
+						Currency:            "USD",
+					},
+					"open-mistral-7b": {
+						InputPricePerToken:  0.00000025,
+						OutputPricePerToken: 0.00000025,
+						Currency:            "USD",
+					},
+					"open-mixtral-8x7b": {
+						InputPricePerToken:  0.0000007,
+						OutputPricePerToken: 0.0000007,
+						Currency:            "USD",
+					},
+					"open-mixtral-8x22b": {
+						InputPricePerToken:  0.000002,
+						OutputPricePerToken: 0.000006,
+						Currency:            "USD",
+					},
 				},
 			},
 		},
+		Format: DefaultFormatOptions(),
+		// OpenAI messages are counted with OpenAI's documented algorithm; set the flag
+		// to legacy to count their JSON encoding
+		CountingFlags: map[string]CountingFlag{
+			"openai": {Algorithm: AlgorithmV2},
+		},
 	}
 }
 
-// LoadFromFile loads configuration from a JSON file
+// LoadFromFile loads configuration from a JSON file, or a YAML file when the file
+// name ends in .yaml or .yml
 func (c *Config) LoadFromFile(filename string) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
 	data, err := os.ReadFile(filename)
 	if err != nil {
 		return err
 	}
 
-	var config Config
-	if err := json.Unmarshal(data, &config); err != nil {
+	return c.loadFile(filename, data)
+}
+
+// load replaces the file-configurable settings with those of a JSON configuration
+// and starts or stops automatic pricing updates accordingly. Nothing is replaced when
+// the configuration is invalid.
+func (c *Config) load(data []byte) error {
+	config, err := decodeConfig(data)
+	if err != nil {
 		return err
 	}
+	if errs := config.validate(); len(errs) > 0 {
+		return NewError(ErrInvalidParams, "invalid configuration", errs)
+	}
 
+	c.mu.Lock()
+	c.SchemaVersion = ConfigSchemaVersion
 	c.Providers = config.Providers
+	if config.Format != (FormatOptions{}) {
+		c.Format = config.Format
+	}
+	c.Normalization = config.Normalization
+	c.UsageLogEnabled = config.UsageLogEnabled
+	c.UsageLogPath = config.UsageLogPath
+	c.UsageLog = config.UsageLog
+	if config.CountingFlags != nil {
+		c.CountingFlags = config.CountingFlags
+	}
+	c.CostTiers = config.CostTiers
+	c.Models = config.Models
+	c.catalog = nil
+	c.DefaultCurrency = config.DefaultCurrency
+
+	interval := config.PricingUpdateInterval
+	if interval <= 0 {
+		interval = DefaultPricingUpdateInterval
+	}
+	restart := config.AutoUpdatePricing && (!c.AutoUpdatePricing || c.PricingUpdateInterval != interval)
+	stop := !config.AutoUpdatePricing && c.AutoUpdatePricing
+	c.mu.Unlock()
+
+	if restart {
+		c.EnableAutomaticPricingUpdates(interval)
+	} else if stop {
+		c.DisableAutomaticPricingUpdates()
+	}
 	return nil
 }
 
-// SaveToFile saves configuration to a JSON file
+// decodeConfig decodes a JSON configuration of any supported schema version
+func decodeConfig(data []byte) (*Config, error) {
+	var header struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, NewError(ErrInvalidParams, "failed to decode configuration", err)
+	}
+	if header.SchemaVersion > ConfigSchemaVersion {
+		return nil, NewError(ErrInvalidParams, fmt.Sprintf("configuration schema version %d is newer than the supported version %d", header.SchemaVersion, ConfigSchemaVersion), nil)
+	}
+
+	config := &Config{}
+	if header.SchemaVersion >= 2 {
+		if err := json.Unmarshal(data, config); err != nil {
+			return nil, NewError(ErrInvalidParams, "failed to decode configuration", err)
+		}
+		return config, nil
+	}
+
+	var legacy configV1
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return nil, NewError(ErrInvalidParams, "failed to decode configuration", err)
+	}
+	config.Providers = legacy.Providers
+	config.AutoUpdatePricing = legacy.AutoUpdatePricing
+	config.UsageLogEnabled = legacy.UsageLogEnabled
+	config.UsageLog = legacy.UsageLog
+	config.Format = legacy.Format
+	config.Normalization = legacy.Normalization
+	config.CountingFlags = legacy.CountingFlags
+	config.CostTiers = legacy.CostTiers
+	config.Models = legacy.Models
+	config.DefaultCurrency = legacy.DefaultCurrency
+	return config, nil
+}
+
+// SaveToFile saves configuration to a JSON file, or a YAML file when the file name
+// ends in .yaml or .yml
 func (c *Config) SaveToFile(filename string) error {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
+	c.SchemaVersion = ConfigSchemaVersion
 	data, err := json.MarshalIndent(c, "", "  ")
 	if err != nil {
 		return err
 	}
+	if isYAMLFile(filename) {
+		if data, err = jsonToYAML(data); err != nil {
+			return err
+		}
+	}
 
 	return os.WriteFile(filename, data, 0644)
 }
 
-// GetModelPricing returns pricing information for a specific model
+// GetModelPricing returns pricing information for a specific model. Models without
+// pricing of their own use the pricing of their model catalog entry's PricingModel,
+// and fine-tuned models the fine-tuned pricing of their base model.
 func (c *Config) GetModelPricing(provider, model string) (ModelPricing, bool) {
+	if pricing, exists := c.modelPricing(provider, model); exists {
+		return pricing, true
+	}
+
+	if pricingModel, ok := c.catalogPricingModel(provider, model); ok {
+		return c.modelPricing(provider, pricingModel)
+	}
+	if fineTuned, ok := ParseFineTunedModel(model); ok {
+		return c.fineTunedModelPricing(provider, fineTuned.BaseModel)
+	}
+	return ModelPricing{}, false
+}
+
+// modelPricing returns the pricing set for a specific model
+func (c *Config) modelPricing(provider, model string) (ModelPricing, bool) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
@@ -130,7 +348,8 @@ func (c *Config) GetModelPricing(provider, model string) (ModelPricing, bool) {
 	return pricing, exists
 }
 
-// SetModelPricing sets pricing information for a specific model
+// SetModelPricing sets pricing information for a specific model. Fields pinned by
+// environment variables, see ApplyEnv, keep their values.
 func (c *Config) SetModelPricing(provider, model string, pricing ModelPricing) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -143,15 +362,20 @@ func (c *Config) SetModelPricing(provider, model string, pricing ModelPricing) {
 		c.Providers[provider] = providerConfig
 	}
 
-	providerConfig.Models[model] = pricing
+	providerConfig.Models[model] = c.applyEnvOverrides(provider, model, pricing)
 }
 
-// EnableAutomaticPricingUpdates enables automatic pricing updates at the specified interval
+// EnableAutomaticPricingUpdates enables automatic pricing updates at the specified interval.
+// The updates only run for a tracker created WithPricingSource.
+//
+// Deprecated: Use DefaultTokenTracker.StartPricingUpdater, which updates the pricing of
+// every tracker, with jitter, cancellation and error reporting.
 func (c *Config) EnableAutomaticPricingUpdates(interval time.Duration) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	c.AutoUpdatePricing = true
+	c.PricingUpdateInterval = interval
 
 	// Stop existing timer if any
 	if c.pricingUpdateTimer != nil {
@@ -159,19 +383,34 @@ func (c *Config) EnableAutomaticPricingUpdates(interval time.Duration) {
 	}
 
 	// Create a new timer that will trigger pricing updates
-	c.pricingUpdateTimer = time.AfterFunc(interval, func() {
-		// This function will be called when the timer expires
-		// It should trigger a pricing update and then reset the timer
-
-		// Note: In a real implementation, this would call a method on TokenTracker
-		// to update all pricing. Since we don't have direct access to TokenTracker here,
-		// this is just a placeholder.
+	c.pricingUpdateTimer = c.getClock().AfterFunc(interval, func() {
+		// Trigger the pricing update of the tracker that registered one, e.g.
+		// with WithPricingSource
+		c.mu.RLock()
+		update := c.pricingUpdater
+		c.mu.RUnlock()
+		if update != nil {
+			update()
+		}
 
 		// Reset the timer for the next interval
-		c.pricingUpdateTimer.Reset(interval)
+		c.mu.RLock()
+		timer := c.pricingUpdateTimer
+		c.mu.RUnlock()
+		if timer != nil {
+			timer.Reset(interval)
+		}
 	})
 }
 
+// setPricingUpdater sets the function automatic pricing updates call
+func (c *Config) setPricingUpdater(update func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pricingUpdater = update
+}
+
 // DisableAutomaticPricingUpdates disables automatic pricing updates
 func (c *Config) DisableAutomaticPricingUpdates() {
 	c.mu.Lock()
@@ -199,7 +438,7 @@ func (c *Config) EnableUsageLogging(path string) error {
 	file.Close()
 
 	c.UsageLogEnabled = true
-	c.usageLogPath = path
+	c.UsageLogPath = path
 	return nil
 }
 
@@ -216,5 +455,88 @@ func (c *Config) GetUsageLogPath() string {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	return c.usageLogPath
+	return c.UsageLogPath
+}
+
+// SetClock sets the clock used for timestamps, durations and timers (nil restores the system clock)
+func (c *Config) SetClock(clock Clock) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.clock = clock
+}
+
+// GetClock returns the clock used for timestamps, durations and timers
+func (c *Config) GetClock() Clock {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.getClock()
+}
+
+// getClock returns the configured clock or the system clock; the caller must hold c.mu
+func (c *Config) getClock() Clock {
+	if c.clock == nil {
+		return SystemClock
+	}
+	return c.clock
+}
+
+// SetUsageLogOptions sets the buffering and rotation options of the usage log
+func (c *Config) SetUsageLogOptions(opts UsageLogOptions) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.UsageLog = opts
+}
+
+// GetUsageLogOptions returns the buffering and rotation options of the usage log
+func (c *Config) GetUsageLogOptions() UsageLogOptions {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.UsageLog
+}
+
+// usageLogTarget returns the usage log path and whether usage logging is enabled
+func (c *Config) usageLogTarget() (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.UsageLogPath, c.UsageLogEnabled && c.UsageLogPath != ""
+}
+
+// SetFormatOptions sets the number formatting options used by reports, exports and CLI output
+func (c *Config) SetFormatOptions(opts FormatOptions) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Format = opts
+}
+
+// GetFormatOptions returns the number formatting options, falling back to the defaults when unset
+func (c *Config) GetFormatOptions() FormatOptions {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.Format == (FormatOptions{}) {
+		return DefaultFormatOptions()
+	}
+	return c.Format
+}
+
+// SetNormalization sets the input normalization applied before tokenization
+func (c *Config) SetNormalization(opts NormalizationOptions) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Normalization = opts
+}
+
+// GetNormalization returns the input normalization applied before tokenization
+func (c *Config) GetNormalization() NormalizationOptions {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.Normalization
 }
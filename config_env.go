@@ -0,0 +1,218 @@
+package tokentracker
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Environment variables overriding the configuration, see ApplyEnv
+const (
+	EnvPrefix          = "TOKENTRACKER_"
+	EnvPricingPrefix   = EnvPrefix + "PRICING_"
+	EnvDefaultCurrency = EnvPrefix + "DEFAULT_CURRENCY"
+)
+
+// envPricingFields sets the ModelPricing fields of pricing variables, by suffix
+var envPricingFields = []struct {
+	suffix string
+	set    func(*ModelPricing, string) error
+}{
+	// Longer suffixes first, so _CACHED_INPUT is not read as _INPUT
+	{"_CACHED_INPUT", envFloat(func(p *ModelPricing, v float64) { p.CachedInputPricePerToken = v })},
+	{"_AUDIO_INPUT", envFloat(func(p *ModelPricing, v float64) { p.AudioInputPricePerToken = v })},
+	{"_CACHE_WRITE", envFloat(func(p *ModelPricing, v float64) { p.CacheWritePricePerToken = v })},
+	{"_BATCH_DISCOUNT", envFloat(func(p *ModelPricing, v float64) { p.BatchDiscount = v })},
+	{"_TRAINING", envFloat(func(p *ModelPricing, v float64) { p.TrainingPricePerToken = v })},
+	{"_INPUT", envFloat(func(p *ModelPricing, v float64) { p.InputPricePerToken = v })},
+	{"_OUTPUT", envFloat(func(p *ModelPricing, v float64) { p.OutputPricePerToken = v })},
+	{"_CURRENCY", func(p *ModelPricing, v string) error {
+		if len(v) != 3 {
+			return fmt.Errorf("currency %q is not an ISO 4217 code", v)
+		}
+		p.Currency = strings.ToUpper(v)
+		return nil
+	}},
+}
+
+// envOverride is a pricing field set by an environment variable
+type envOverride struct {
+	provider, model string
+	key, value      string
+	set             func(*ModelPricing, string) error
+}
+
+// applyEnvOverrides sets the fields of pricing pinned by environment variables for the
+// model. It is called with c.mu held.
+func (c *Config) applyEnvOverrides(provider, model string, pricing ModelPricing) ModelPricing {
+	for _, o := range c.envOverrides {
+		if o.provider == provider && o.model == model {
+			_ = o.set(&pricing, o.value) // validated by ApplyEnv
+		}
+	}
+	return pricing
+}
+
+// envFloat parses a non-negative number for a pricing field
+func envFloat(set func(*ModelPricing, float64)) func(*ModelPricing, string) error {
+	return func(p *ModelPricing, value string) error {
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		if v < 0 {
+			return fmt.Errorf("%q is negative", value)
+		}
+		set(p, v)
+		return nil
+	}
+}
+
+// LoadFromEnv applies the overrides of the process environment, see ApplyEnv
+func (c *Config) LoadFromEnv() error {
+	return c.ApplyEnv(os.Environ())
+}
+
+// ApplyEnv applies configuration overrides from environment variables given as
+// KEY=value pairs. Overrides take precedence over defaults and files, so load files
+// first. Supported variables are
+//
+//	TOKENTRACKER_DEFAULT_CURRENCY=EUR
+//	TOKENTRACKER_PRICING_<PROVIDER>_<MODEL>_<FIELD>=value
+//
+// where FIELD is INPUT, OUTPUT, CACHED_INPUT, CACHE_WRITE, AUDIO_INPUT or TRAINING
+// (prices per token), BATCH_DISCOUNT or CURRENCY. Provider and model names are matched ignoring
+// case and punctuation, so TOKENTRACKER_PRICING_OPENAI_GPT4_INPUT sets the input
+// price of openai/gpt-4. Since names are matched without punctuation, a variable cannot
+// name a new model: the model must already be configured, e.g. by a file. Nothing is
+// applied when a variable is invalid, and the returned error wraps ConfigErrors listing
+// all of them. The overridden fields stay pinned: SetModelPricing, and with it every
+// pricing update, keeps them, and they are applied again whenever a watched file is
+// reloaded, see WatchFile.
+func (c *Config) ApplyEnv(environ []string) error {
+	var overrides []envOverride
+	var errs ConfigErrors
+	var applied []string
+	currency := ""
+
+	for _, entry := range environ {
+		key, value, _ := strings.Cut(entry, "=")
+		if strings.HasPrefix(key, EnvPrefix) {
+			applied = append(applied, entry)
+		}
+		switch {
+		case key == EnvDefaultCurrency:
+			if len(value) != 3 {
+				errs = append(errs, ConfigFieldError{Key: key, Message: fmt.Sprintf("currency %q is not an ISO 4217 code", value)})
+				continue
+			}
+			currency = strings.ToUpper(value)
+		case strings.HasPrefix(key, EnvPricingPrefix):
+			name := strings.TrimPrefix(key, EnvPricingPrefix)
+			var set func(*ModelPricing, string) error
+			for _, field := range envPricingFields {
+				if strings.HasSuffix(name, field.suffix) {
+					name, set = strings.TrimSuffix(name, field.suffix), field.set
+					break
+				}
+			}
+			if set == nil {
+				errs = append(errs, ConfigFieldError{Key: key, Message: "unknown pricing field"})
+				continue
+			}
+
+			provider, model, err := c.envModel(name)
+			if err != nil {
+				errs = append(errs, ConfigFieldError{Key: key, Message: err.Error()})
+				continue
+			}
+			if err := set(&ModelPricing{}, value); err != nil {
+				errs = append(errs, ConfigFieldError{Key: key, Message: err.Error()})
+				continue
+			}
+			overrides = append(overrides, envOverride{provider: provider, model: model, key: key, value: value, set: set})
+		}
+	}
+
+	if len(errs) > 0 {
+		sort.Slice(errs, func(i, j int) bool { return errs[i].Key < errs[j].Key })
+		return NewError(ErrInvalidParams, "invalid configuration environment", errs)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.envOverrides = overrides
+	for _, o := range overrides {
+		providerConfig := c.Providers[o.provider]
+		providerConfig.Models[o.model] = c.applyEnvOverrides(o.provider, o.model, providerConfig.Models[o.model])
+	}
+	if currency != "" {
+		c.DefaultCurrency = currency
+	}
+	c.env = applied
+	return nil
+}
+
+// envModel resolves the PROVIDER_MODEL part of a pricing variable to a configured
+// provider and model, trying every underscore as the boundary between the two
+func (c *Config) envModel(name string) (string, string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	parts := strings.Split(name, "_")
+	var matches []string
+	for i := 1; i < len(parts); i++ {
+		envProvider, envModel := envName(strings.Join(parts[:i], "")), envName(strings.Join(parts[i:], ""))
+		for provider, providerConfig := range c.Providers {
+			if envName(provider) != envProvider {
+				continue
+			}
+			for model := range providerConfig.Models {
+				if envName(model) == envModel {
+					matches = append(matches, provider+"/"+model)
+				}
+			}
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", "", fmt.Errorf("no configured provider and model match %s", name)
+	case 1:
+		provider, model, _ := strings.Cut(matches[0], "/")
+		return provider, model, nil
+	}
+	sort.Strings(matches)
+	return "", "", fmt.Errorf("%s matches %s", name, strings.Join(matches, " and "))
+}
+
+// envName returns a name as it is matched in environment variables: upper case
+// letters and digits only
+func envName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(unicode.ToUpper(r))
+		}
+	}
+	return b.String()
+}
+
+// LoadConfig creates a configuration with the defaults, overridden by the JSON or
+// YAML file at path (if not empty), overridden in turn by the environment
+func LoadConfig(path string) (*Config, error) {
+	config := NewConfig()
+	if path != "" {
+		if err := config.LoadFromFile(path); err != nil {
+			return nil, NewError(ErrInvalidParams, fmt.Sprintf("failed to load configuration file: %s", path), err)
+		}
+	}
+	if err := config.LoadFromEnv(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
@@ -0,0 +1,86 @@
+package tokentracker
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestConfig_ApplyEnv(t *testing.T) {
+	config := NewConfig()
+	err := config.ApplyEnv([]string{
+		"TOKENTRACKER_PRICING_OPENAI_GPT4_INPUT=0.00002",
+		"TOKENTRACKER_PRICING_OPENAI_GPT_4_OUTPUT=0.00004",
+		"TOKENTRACKER_PRICING_OPENAI_GPT4_CACHED_INPUT=0.00001",
+		"TOKENTRACKER_DEFAULT_CURRENCY=eur",
+		"TOKENTRACKER_LOCALE=de-DE",
+		"HOME=/root",
+	})
+	if err != nil {
+		t.Fatalf("ApplyEnv() error = %v", err)
+	}
+
+	pricing, _ := config.GetModelPricing("openai", "gpt-4")
+	if pricing.InputPricePerToken != 0.00002 || pricing.OutputPricePerToken != 0.00004 || pricing.CachedInputPricePerToken != 0.00001 {
+		t.Errorf("gpt-4 pricing = %+v, want the overrides", pricing)
+	}
+	if pricing.Currency != "USD" {
+		t.Errorf("gpt-4 currency = %q, want the configured one kept", pricing.Currency)
+	}
+	if config.DefaultCurrency != "EUR" {
+		t.Errorf("DefaultCurrency = %q, want EUR", config.DefaultCurrency)
+	}
+}
+
+func TestConfig_ApplyEnvPinsPricing(t *testing.T) {
+	config := NewConfig()
+	if err := config.ApplyEnv([]string{"TOKENTRACKER_PRICING_OPENAI_GPT4_INPUT=0.00002"}); err != nil {
+		t.Fatalf("ApplyEnv() error = %v", err)
+	}
+
+	// Pricing updates set the other fields but keep the overridden one
+	config.SetModelPricing("openai", "gpt-4", ModelPricing{InputPricePerToken: 0.00003, OutputPricePerToken: 0.00005, Currency: "USD"})
+	pricing, _ := config.GetModelPricing("openai", "gpt-4")
+	if pricing.InputPricePerToken != 0.00002 || pricing.OutputPricePerToken != 0.00005 {
+		t.Errorf("gpt-4 pricing after an update = %+v, want the input price pinned by the environment", pricing)
+	}
+
+	config.SetModelPricing("openai", "gpt-3.5-turbo", ModelPricing{InputPricePerToken: 0.000001, Currency: "USD"})
+	if pricing, _ := config.GetModelPricing("openai", "gpt-3.5-turbo"); pricing.InputPricePerToken != 0.000001 {
+		t.Errorf("gpt-3.5-turbo pricing = %+v, want the update applied", pricing)
+	}
+}
+
+func TestConfig_ApplyEnvErrors(t *testing.T) {
+	config := NewConfig()
+	err := config.ApplyEnv([]string{
+		"TOKENTRACKER_PRICING_OPENAI_GPT4_INPUT=0.00002",
+		"TOKENTRACKER_PRICING_OPENAI_GPT4_OUTPUT=cheap",
+		"TOKENTRACKER_PRICING_OPENAI_GPT4_INPUTS=0.1",
+		"TOKENTRACKER_PRICING_ACME_MODEL_INPUT=0.1",
+		"TOKENTRACKER_PRICING_OPENAI_GPT4_BATCH_DISCOUNT=-0.5",
+	})
+
+	var errs ConfigErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("ApplyEnv() error = %v, want ConfigErrors", err)
+	}
+	want := []string{
+		"TOKENTRACKER_PRICING_ACME_MODEL_INPUT",
+		"TOKENTRACKER_PRICING_OPENAI_GPT4_BATCH_DISCOUNT",
+		"TOKENTRACKER_PRICING_OPENAI_GPT4_INPUTS",
+		"TOKENTRACKER_PRICING_OPENAI_GPT4_OUTPUT",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("ApplyEnv() errors = %v, want %d", errs, len(want))
+	}
+	for i, key := range want {
+		if errs[i].Key != key {
+			t.Errorf("errors[%d].Key = %q, want %q", i, errs[i].Key, key)
+		}
+	}
+
+	// Nothing is applied when a variable is invalid
+	if pricing, _ := config.GetModelPricing("openai", "gpt-4"); pricing.InputPricePerToken == 0.00002 {
+		t.Error("valid overrides should not be applied alongside invalid ones")
+	}
+}
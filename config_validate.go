@@ -0,0 +1,145 @@
+package tokentracker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ConfigFieldError is an invalid configuration setting
+type ConfigFieldError struct {
+	Key     string `json:"key"` // e.g. the environment variable or the path of the field
+	Message string `json:"message"`
+}
+
+// Error returns the key and the problem
+func (e ConfigFieldError) Error() string {
+	return e.Key + ": " + e.Message
+}
+
+// ConfigErrors lists every invalid setting of a configuration
+type ConfigErrors []ConfigFieldError
+
+// Error returns all problems
+func (e ConfigErrors) Error() string {
+	problems := make([]string, len(e))
+	for i, err := range e {
+		problems[i] = err.Error()
+	}
+	return fmt.Sprintf("%d invalid settings: %s", len(e), strings.Join(problems, "; "))
+}
+
+// Validate checks the configuration and returns an error wrapping ConfigErrors that
+// lists every invalid setting, e.g. negative prices or pricing without a currency.
+// LoadFromFile rejects files that do not validate.
+func (c *Config) Validate() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if errs := c.validate(); len(errs) > 0 {
+		return NewError(ErrInvalidParams, "invalid configuration", errs)
+	}
+	return nil
+}
+
+// validate returns the invalid settings sorted by key; the caller must hold c.mu or
+// own the configuration
+func (c *Config) validate() ConfigErrors {
+	var errs ConfigErrors
+	add := func(key, format string, args ...interface{}) {
+		errs = append(errs, ConfigFieldError{Key: key, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for provider, providerConfig := range c.Providers {
+		if name := providerConfig.Tokenizer; name != "" {
+			if _, exists := LookupTokenizer(name); !exists {
+				add(fmt.Sprintf("providers.%s.tokenizer", provider), "no tokenizer registered with name %q", name)
+			}
+		}
+		for model, pricing := range providerConfig.Models {
+			validatePricing(fmt.Sprintf("providers.%s.models.%s", provider, model), pricing, add)
+		}
+		for model, periods := range providerConfig.History {
+			key := fmt.Sprintf("providers.%s.history.%s", provider, model)
+			if _, err := validatePricePeriods(provider, model, periods); err != nil {
+				add(key, "%s", err.(*TokenTrackerError).Message)
+			}
+			for i, period := range periods {
+				validatePricing(fmt.Sprintf("%s[%d].pricing", key, i), period.Pricing, add)
+			}
+		}
+	}
+
+	if c.PricingUpdateInterval < 0 {
+		add("pricing_update_interval", "must not be negative")
+	}
+	if c.UsageLogEnabled && c.UsageLogPath == "" {
+		add("usage_log_path", "is required when usage logging is enabled")
+	}
+	if c.UsageLog.MaxSizeBytes < 0 || c.UsageLog.MaxAge < 0 || c.UsageLog.MaxBackups < 0 || c.UsageLog.BufferSize < 0 {
+		add("usage_log", "limits must not be negative")
+	}
+	for name, flag := range c.CountingFlags {
+		if flag.RolloutPercent < 0 || flag.RolloutPercent > 100 {
+			add("counting_flags."+name+".rollout_percent", "%v is not between 0 and 100", flag.RolloutPercent)
+		}
+	}
+	if c.CostTiers.EconomyMaxPer1K < 0 || c.CostTiers.StandardMaxPer1K < 0 {
+		add("cost_tiers", "thresholds must not be negative")
+	} else if c.CostTiers.StandardMaxPer1K > 0 && c.CostTiers.EconomyMaxPer1K > c.CostTiers.StandardMaxPer1K {
+		add("cost_tiers", "economy threshold %v is above the standard threshold %v", c.CostTiers.EconomyMaxPer1K, c.CostTiers.StandardMaxPer1K)
+	}
+	for i, entry := range c.Models {
+		if _, err := NewModelCatalog(entry); err != nil {
+			add(fmt.Sprintf("models[%d]", i), "%v", err)
+		}
+	}
+	if c.DefaultCurrency != "" && !validCurrency(c.DefaultCurrency) {
+		add("default_currency", "%q is not an ISO 4217 code", c.DefaultCurrency)
+	}
+
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Key < errs[j].Key })
+	return errs
+}
+
+// validatePricing reports the invalid fields of a model's pricing
+func validatePricing(key string, pricing ModelPricing, add func(key, format string, args ...interface{})) {
+	prices := map[string]float64{
+		"InputPricePerToken":       pricing.InputPricePerToken,
+		"OutputPricePerToken":      pricing.OutputPricePerToken,
+		"CachedInputPricePerToken": pricing.CachedInputPricePerToken,
+		"CacheWritePricePerToken":  pricing.CacheWritePricePerToken,
+		"AudioInputPricePerToken":  pricing.AudioInputPricePerToken,
+		"TrainingPricePerToken":    pricing.TrainingPricePerToken,
+	}
+	for field, price := range prices {
+		if price < 0 {
+			add(key+"."+field, "price %v is negative", price)
+		}
+	}
+	if pricing.Currency == "" {
+		add(key+".Currency", "is required")
+	} else if !validCurrency(pricing.Currency) {
+		add(key+".Currency", "%q is not an ISO 4217 code", pricing.Currency)
+	}
+	if pricing.BatchDiscount < 0 || pricing.BatchDiscount > 1 {
+		add(key+".BatchDiscount", "%v is not between 0 and 1", pricing.BatchDiscount)
+	}
+	for i, tier := range pricing.Tiers {
+		tierKey := fmt.Sprintf("%s.Tiers[%d]", key, i)
+		if tier.AboveInputTokens <= 0 {
+			add(tierKey+".AboveInputTokens", "must be positive")
+		}
+		if tier.InputPricePerToken < 0 || tier.OutputPricePerToken < 0 || tier.CachedInputPricePerToken < 0 {
+			add(tierKey, "prices must not be negative")
+		}
+	}
+}
+
+// validCurrency reports whether a currency looks like an ISO 4217 code
+func validCurrency(currency string) bool {
+	if len(currency) != 3 {
+		return false
+	}
+	return strings.ToUpper(currency) == currency && strings.Trim(currency, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") == ""
+}
@@ -0,0 +1,118 @@
+package tokentracker
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	if err := NewConfig().Validate(); err != nil {
+		t.Fatalf("Validate() of the default configuration error = %v", err)
+	}
+
+	config := NewConfig()
+	config.SetModelPricing("acme", "acme-1", ModelPricing{InputPricePerToken: -0.1, OutputPricePerToken: 0.2})
+	config.SetModelPricing("acme", "acme-2", ModelPricing{InputPricePerToken: 0.1, Currency: "usd", BatchDiscount: 1.5})
+	config.UsageLogEnabled = true
+	config.DefaultCurrency = "EURO"
+
+	var errs ConfigErrors
+	if err := config.Validate(); !errors.As(err, &errs) {
+		t.Fatalf("Validate() error = %v, want ConfigErrors", err)
+	}
+	want := []string{
+		"default_currency",
+		"providers.acme.models.acme-1.Currency",
+		"providers.acme.models.acme-1.InputPricePerToken",
+		"providers.acme.models.acme-2.BatchDiscount",
+		"providers.acme.models.acme-2.Currency",
+		"usage_log_path",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("Validate() errors = %v, want %d", errs, len(want))
+	}
+	for i, key := range want {
+		if errs[i].Key != key {
+			t.Errorf("errors[%d].Key = %q, want %q", i, errs[i].Key, key)
+		}
+	}
+}
+
+func TestConfig_LoadRejectsInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{"schema_version": 2, "providers": {"acme": {"models": {"acme-1": {"InputPricePerToken": -1, "Currency": "USD"}}}}}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := NewConfig()
+	if err := config.LoadFromFile(path); err == nil {
+		t.Fatal("LoadFromFile() of a file with a negative price should fail")
+	}
+	if _, exists := config.GetModelPricing("openai", "gpt-4"); !exists {
+		t.Error("a rejected file should leave the configuration unchanged")
+	}
+
+	if err := os.WriteFile(path, []byte(`{"schema_version": 99}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.LoadFromFile(path); err == nil {
+		t.Error("LoadFromFile() of a newer schema version should fail")
+	}
+}
+
+func TestConfig_SaveAndLoadRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "usage.log")
+	path := filepath.Join(dir, "config.json")
+
+	config := NewConfig()
+	if err := config.EnableUsageLogging(logPath); err != nil {
+		t.Fatalf("EnableUsageLogging() error = %v", err)
+	}
+	config.EnableAutomaticPricingUpdates(6 * time.Hour)
+	defer config.DisableAutomaticPricingUpdates()
+	config.DefaultCurrency = "EUR"
+	if err := config.SaveToFile(path); err != nil {
+		t.Fatalf("SaveToFile() error = %v", err)
+	}
+
+	loaded := NewConfig()
+	if err := loaded.LoadFromFile(path); err != nil {
+		t.Fatalf("LoadFromFile() error = %v", err)
+	}
+	defer loaded.DisableAutomaticPricingUpdates()
+
+	if !loaded.AutoUpdatePricing || loaded.PricingUpdateInterval != 6*time.Hour {
+		t.Errorf("automatic pricing updates = %v every %v, want every 6h", loaded.AutoUpdatePricing, loaded.PricingUpdateInterval)
+	}
+	if !loaded.UsageLogEnabled || loaded.GetUsageLogPath() != logPath {
+		t.Errorf("usage log = %v at %q, want enabled at %q", loaded.UsageLogEnabled, loaded.GetUsageLogPath(), logPath)
+	}
+	if loaded.DefaultCurrency != "EUR" || loaded.SchemaVersion != ConfigSchemaVersion {
+		t.Errorf("DefaultCurrency = %q, SchemaVersion = %d", loaded.DefaultCurrency, loaded.SchemaVersion)
+	}
+}
+
+func TestConfig_LoadLegacyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{"Providers": {"acme": {"Models": {"acme-1": {"InputPricePerToken": 0.1, "OutputPricePerToken": 0.2, "Currency": "USD"}}}},
+		"UsageLogEnabled": false, "DefaultCurrency": "GBP", "CostTiers": {"economy_max_per_1k": 0.001, "standard_max_per_1k": 0.01}}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := NewConfig()
+	if err := config.LoadFromFile(path); err != nil {
+		t.Fatalf("LoadFromFile() error = %v", err)
+	}
+	if pricing, _ := config.GetModelPricing("acme", "acme-1"); pricing.OutputPricePerToken != 0.2 {
+		t.Errorf("acme-1 pricing = %+v, want the file's", pricing)
+	}
+	if config.DefaultCurrency != "GBP" || config.CostTiers.EconomyMaxPer1K != 0.001 {
+		t.Errorf("DefaultCurrency = %q, CostTiers = %+v, want the file's", config.DefaultCurrency, config.CostTiers)
+	}
+}
@@ -0,0 +1,160 @@
+package tokentracker
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultConfigWatchInterval is how often a watched configuration file is checked
+const DefaultConfigWatchInterval = 5 * time.Second
+
+// ConfigWatchOptions configures Config.WatchFile
+type ConfigWatchOptions struct {
+	// Interval is how often the file is checked for changes (0 uses the default)
+	Interval time.Duration
+
+	// OnError is called when a changed file cannot be loaded; the current settings are
+	// kept and the file is loaded again once it changes (nil logs the error)
+	OnError func(error)
+}
+
+// ConfigReload describes a configuration reloaded from a watched file
+type ConfigReload struct {
+	Path       string
+	ReloadedAt time.Time
+}
+
+// ConfigWatcher reloads a configuration whenever its file changes
+type ConfigWatcher struct {
+	config *Config
+	path   string
+	opts   ConfigWatchOptions
+	sum    [sha256.Size]byte // of the contents last read
+	read   bool
+	stop   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+	mu     sync.Mutex
+}
+
+// WatchFile loads the configuration from a JSON or YAML file and reloads it whenever the
+// file changes, so pricing, provider and other file settings can be adjusted without
+// restarting. The file is polled, which also works for files replaced by a rename, e.g.
+// mounted Kubernetes ConfigMaps. Each reload replaces the settings at once and applies
+// the environment overrides of ApplyEnv again; a file that fails to load leaves them
+// unchanged. Close the watcher to stop watching.
+func (c *Config) WatchFile(path string, opts ConfigWatchOptions) (*ConfigWatcher, error) {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultConfigWatchInterval
+	}
+
+	w := &ConfigWatcher{
+		config: c,
+		path:   path,
+		opts:   opts,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if _, err := w.reload(); err != nil {
+		return nil, err
+	}
+
+	go w.run()
+	return w, nil
+}
+
+// OnReload registers a callback invoked after the configuration was reloaded from a
+// watched file, e.g. to apply budgets kept elsewhere
+func (c *Config) OnReload(callback func(ConfigReload)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.reloadListeners = append(c.reloadListeners, callback)
+}
+
+// run checks the file at every interval until the watcher is closed
+func (w *ConfigWatcher) run() {
+	defer close(w.done)
+
+	ticker := w.config.GetClock().NewTicker(w.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.Chan():
+			if err := w.Check(); err != nil {
+				if w.opts.OnError != nil {
+					w.opts.OnError(err)
+				} else {
+					w.config.GetLogger().Warn("configuration reload failed", "path", w.path, "error", err)
+				}
+			}
+		}
+	}
+}
+
+// Check reloads the configuration now if the file has changed since it was last loaded
+func (w *ConfigWatcher) Check() error {
+	reloaded, err := w.reload()
+	if !reloaded {
+		return err
+	}
+
+	event := ConfigReload{Path: w.path, ReloadedAt: w.config.GetClock().Now()}
+	w.config.mu.RLock()
+	listeners := append([]func(ConfigReload){}, w.config.reloadListeners...)
+	w.config.mu.RUnlock()
+	for _, listener := range listeners {
+		listener(event)
+	}
+	return err
+}
+
+// reload loads the file when its contents changed and reports whether it did, with
+// an error if the environment overrides no longer apply to it. The
+// file is small, so comparing a hash of its contents is cheaper than a missed change
+// when a rewrite keeps the modification time and size.
+func (w *ConfigWatcher) reload() (bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		return false, NewError(ErrInvalidParams, fmt.Sprintf("failed to read configuration file: %s", w.path), err)
+	}
+	sum := sha256.Sum256(data)
+	if w.read && sum == w.sum {
+		return false, nil
+	}
+
+	// Contents that fail to load are reported once, not at every check
+	w.sum, w.read = sum, true
+	if err := w.config.loadFile(w.path, data); err != nil {
+		return false, NewError(ErrInvalidParams, fmt.Sprintf("failed to load configuration file: %s", w.path), err)
+	}
+
+	// Environment overrides take precedence over the file, as when it was loaded first
+	w.config.mu.RLock()
+	env := w.config.env
+	w.config.mu.RUnlock()
+	if len(env) > 0 {
+		if err := w.config.ApplyEnv(env); err != nil {
+			return true, NewError(ErrInvalidParams, fmt.Sprintf("failed to apply environment overrides to configuration file: %s", w.path), err)
+		}
+	}
+	return true, nil
+}
+
+// Close stops watching the file
+func (w *ConfigWatcher) Close() error {
+	w.once.Do(func() {
+		close(w.stop)
+	})
+	<-w.done
+	return nil
+}
@@ -0,0 +1,123 @@
+package tokentracker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, path string, inputPrice float64) {
+	t.Helper()
+	config := NewConfig()
+	config.SetModelPricing("acme", "acme-1", ModelPricing{InputPricePerToken: inputPrice, OutputPricePerToken: 0.00002, Currency: "USD"})
+	if err := config.SaveToFile(path); err != nil {
+		t.Fatalf("SaveToFile() error = %v", err)
+	}
+}
+
+func TestConfig_WatchFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeConfigFile(t, path, 0.00001)
+
+	config := NewConfig()
+	var reloads []ConfigReload
+	config.OnReload(func(reload ConfigReload) { reloads = append(reloads, reload) })
+
+	watcher, err := config.WatchFile(path, ConfigWatchOptions{Interval: time.Hour})
+	if err != nil {
+		t.Fatalf("WatchFile() error = %v", err)
+	}
+	defer watcher.Close()
+
+	if pricing, _ := config.GetModelPricing("acme", "acme-1"); pricing.InputPricePerToken != 0.00001 {
+		t.Errorf("pricing = %+v, want the file's", pricing)
+	}
+
+	// An unchanged file is not reloaded
+	if err := watcher.Check(); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if len(reloads) != 0 {
+		t.Errorf("got %d reloads of an unchanged file", len(reloads))
+	}
+
+	writeConfigFile(t, path, 0.000005)
+	if err := watcher.Check(); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if pricing, _ := config.GetModelPricing("acme", "acme-1"); pricing.InputPricePerToken != 0.000005 {
+		t.Errorf("pricing = %+v, want the changed file's", pricing)
+	}
+	if len(reloads) != 1 || reloads[0].Path != path {
+		t.Errorf("reloads = %+v, want one of %s", reloads, path)
+	}
+
+	// A broken file keeps the current settings and is reported once
+	if err := os.WriteFile(path, []byte(`{"Providers": `), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := watcher.Check(); err == nil {
+		t.Error("Check() of a broken file should fail")
+	}
+	if err := watcher.Check(); err != nil {
+		t.Errorf("Check() of the same broken file error = %v, want it reported once", err)
+	}
+	if pricing, _ := config.GetModelPricing("acme", "acme-1"); pricing.InputPricePerToken != 0.000005 {
+		t.Errorf("pricing = %+v, want the last good file's", pricing)
+	}
+}
+
+func TestConfig_WatchFilePolls(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeConfigFile(t, path, 0.00001)
+
+	config := NewConfig()
+	reloaded := make(chan ConfigReload, 1)
+	config.OnReload(func(reload ConfigReload) { reloaded <- reload })
+
+	watcher, err := config.WatchFile(path, ConfigWatchOptions{Interval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("WatchFile() error = %v", err)
+	}
+
+	writeConfigFile(t, path, 0.000005)
+	select {
+	case <-reloaded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the changed file was not reloaded")
+	}
+	if err := watcher.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+
+	if _, err := config.WatchFile(filepath.Join(t.TempDir(), "missing.json"), ConfigWatchOptions{}); err == nil {
+		t.Error("WatchFile() of a missing file should fail")
+	}
+}
+
+func TestConfig_WatchFileKeepsEnvOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeConfigFile(t, path, 0.00001)
+
+	config := NewConfig()
+	watcher, err := config.WatchFile(path, ConfigWatchOptions{Interval: time.Hour})
+	if err != nil {
+		t.Fatalf("WatchFile() error = %v", err)
+	}
+	defer watcher.Close()
+
+	if err := config.ApplyEnv([]string{"TOKENTRACKER_PRICING_ACME_ACME1_INPUT=0.00003", "HOME=/root"}); err != nil {
+		t.Fatalf("ApplyEnv() error = %v", err)
+	}
+
+	// The override outlives a change of the file it overrides
+	writeConfigFile(t, path, 0.000005)
+	if err := watcher.Check(); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	pricing, _ := config.GetModelPricing("acme", "acme-1")
+	if pricing.InputPricePerToken != 0.00003 || pricing.OutputPricePerToken != 0.00002 {
+		t.Errorf("pricing = %+v, want the environment's input price over the file's", pricing)
+	}
+}
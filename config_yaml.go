@@ -0,0 +1,75 @@
+package tokentracker
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// isYAMLFile reports whether a configuration file is YAML by its extension
+func isYAMLFile(filename string) bool {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// loadFile loads a JSON or YAML configuration file's contents
+func (c *Config) loadFile(filename string, data []byte) error {
+	if isYAMLFile(filename) {
+		converted, err := yamlToJSON(data)
+		if err != nil {
+			return err
+		}
+		data = converted
+	}
+	return c.load(data)
+}
+
+// yamlToJSON converts a YAML configuration to JSON, so YAML files use the same keys
+// as JSON ones, matched case-insensitively: "providers" sets Providers
+func yamlToJSON(data []byte) ([]byte, error) {
+	var document interface{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, NewError(ErrInvalidParams, "failed to decode YAML configuration", err)
+	}
+	if document == nil {
+		document = map[string]interface{}{}
+	}
+
+	return json.Marshal(jsonCompatible(document))
+}
+
+// jsonCompatible replaces the non-string-keyed maps YAML can produce with string-keyed ones
+func jsonCompatible(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = jsonCompatible(item)
+		}
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[fmt.Sprint(key)] = jsonCompatible(item)
+		}
+		return result
+	case []interface{}:
+		for i, item := range v {
+			v[i] = jsonCompatible(item)
+		}
+	}
+	return value
+}
+
+// jsonToYAML converts a JSON configuration to YAML
+func jsonToYAML(data []byte) ([]byte, error) {
+	var document interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(document)
+}
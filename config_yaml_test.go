@@ -0,0 +1,77 @@
+package tokentracker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfig_LoadFromYAMLFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := `schema_version: 2
+providers:
+  acme:
+    models:
+      acme-1:
+        InputPricePerToken: 0.00001
+        OutputPricePerToken: 0.00002
+        Currency: USD
+        Tiers:
+          - AboveInputTokens: 100000
+            InputPricePerToken: 0.00002
+            OutputPricePerToken: 0.00004
+default_currency: EUR
+`
+	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := NewConfig()
+	if err := config.LoadFromFile(path); err != nil {
+		t.Fatalf("LoadFromFile() error = %v", err)
+	}
+	pricing, exists := config.GetModelPricing("acme", "acme-1")
+	if !exists || pricing.OutputPricePerToken != 0.00002 || len(pricing.Tiers) != 1 || pricing.Tiers[0].AboveInputTokens != 100000 {
+		t.Errorf("acme-1 pricing = %+v, want the YAML file's", pricing)
+	}
+	if config.DefaultCurrency != "EUR" {
+		t.Errorf("DefaultCurrency = %q, want EUR", config.DefaultCurrency)
+	}
+
+	// YAML files round-trip
+	saved := filepath.Join(t.TempDir(), "saved.yml")
+	if err := config.SaveToFile(saved); err != nil {
+		t.Fatalf("SaveToFile() error = %v", err)
+	}
+	reloaded := NewConfig()
+	if err := reloaded.LoadFromFile(saved); err != nil {
+		t.Fatalf("LoadFromFile() error = %v", err)
+	}
+	if pricing, _ := reloaded.GetModelPricing("acme", "acme-1"); pricing.InputPricePerToken != 0.00001 {
+		t.Errorf("reloaded pricing = %+v, want the saved pricing", pricing)
+	}
+
+	if err := os.WriteFile(path, []byte("providers: [unclosed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.LoadFromFile(path); err == nil {
+		t.Error("LoadFromFile() of invalid YAML should fail")
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := "providers:\n  acme:\n    models:\n      acme-1: {InputPricePerToken: 0.00001, OutputPricePerToken: 0.00002, Currency: USD}\n"
+	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TOKENTRACKER_PRICING_ACME_ACME1_INPUT", "0.000005")
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if pricing, _ := config.GetModelPricing("acme", "acme-1"); pricing.InputPricePerToken != 0.000005 || pricing.OutputPricePerToken != 0.00002 {
+		t.Errorf("acme-1 pricing = %+v, want the file's pricing with the environment override", pricing)
+	}
+}
@@ -0,0 +1,119 @@
+package tokentracker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// MessageTokenCounter is implemented by providers whose count of a conversation is the
+// sum of the counts of its messages plus an overhead of the request as a whole, such
+// as tools and reply priming. A ConversationCounter then tokenizes each message once.
+type MessageTokenCounter interface {
+	// CountMessageTokens counts one message of a conversation, including its per
+	// message overhead. An error means the message cannot be counted on its own, and
+	// the conversation is counted as a whole.
+	CountMessageTokens(model string, message Message) (int, error)
+}
+
+// ConversationCounter counts the tokens of chat conversations, memoizing the count of
+// every message by model, role and content. Conversations re-send the same history
+// every turn, so counting a long conversation only tokenizes its new messages.
+// Conversations of providers that do not implement MessageTokenCounter are counted as
+// a whole.
+type ConversationCounter struct {
+	tracker  *DefaultTokenTracker
+	messages *TokenCache
+}
+
+// NewConversationCounter creates a conversation counter of this tracker, keeping
+// message counts in a cache configured by opts
+func (t *DefaultTokenTracker) NewConversationCounter(opts TokenCacheOptions) *ConversationCounter {
+	return &ConversationCounter{
+		tracker:  t,
+		messages: NewTokenCache(opts),
+	}
+}
+
+// CountTokens counts the tokens of a conversation like the tracker's CountTokens
+func (c *ConversationCounter) CountTokens(params TokenCountParams) (TokenCount, error) {
+	return c.CountTokensCtx(context.Background(), params)
+}
+
+// CountTokensCtx counts the tokens of a conversation, honoring cancellation of ctx.
+// The request overhead is counted with the last message alone; every other message
+// comes from the cache once counted.
+func (c *ConversationCounter) CountTokensCtx(ctx context.Context, params TokenCountParams) (TokenCount, error) {
+	if params.Model == "" {
+		return TokenCount{}, NewError(ErrInvalidParams, "model is required", nil)
+	}
+	provider, exists := c.tracker.providerForModel(params.Model)
+	if !exists {
+		return TokenCount{}, NewError(ErrProviderNotFound, fmt.Sprintf("no provider found for model: %s", params.Model), nil)
+	}
+	counter, ok := provider.(MessageTokenCounter)
+	if !ok || params.Text != nil || len(params.Messages) == 0 {
+		return c.tracker.CountTokensCtx(ctx, params)
+	}
+
+	inputTokens := 0
+	var lastTokens int
+	for _, message := range params.Messages {
+		tokens, err := c.messageTokens(counter, provider.Name(), params.Model, message)
+		if err != nil {
+			return c.tracker.CountTokensCtx(ctx, params)
+		}
+		inputTokens += tokens
+		lastTokens = tokens
+	}
+
+	// Count the request overhead with the last message alone
+	last := params
+	last.Messages = params.Messages[len(params.Messages)-1:]
+	last.CountResponseTokens = false
+	count, err := CountTokensWithContext(ctx, provider, last)
+	if err != nil {
+		return TokenCount{}, err
+	}
+	count.InputTokens += inputTokens - lastTokens
+
+	if params.CountResponseTokens {
+		count.ResponseTokens = c.tracker.config.GetResponseEstimator().EstimateResponseTokens(params, count.InputTokens)
+	}
+	count.TotalTokens = count.InputTokens + count.ResponseTokens
+	if c.tracker.calibration != nil {
+		count = c.tracker.calibration.apply(params.Model, count)
+	}
+	return count, nil
+}
+
+// Stats returns the counters of the message cache
+func (c *ConversationCounter) Stats() TokenCacheStats {
+	return c.messages.Stats()
+}
+
+// Purge forgets every counted message
+func (c *ConversationCounter) Purge() {
+	c.messages.Purge()
+}
+
+// messageTokens returns the count of a message, counting it on a cache miss. Messages
+// are keyed by their encoding, so any change to the role, content or tool calls
+// counts them again.
+func (c *ConversationCounter) messageTokens(counter MessageTokenCounter, provider, model string, message Message) (int, error) {
+	key, err := json.Marshal(message)
+	if err != nil {
+		return 0, err
+	}
+	scope := CacheScope(model, c.tracker.config.GetNormalization())
+	if tokens, exists := c.messages.Get(provider, scope, string(key)); exists {
+		return tokens, nil
+	}
+
+	tokens, err := counter.CountMessageTokens(model, message)
+	if err != nil {
+		return 0, err
+	}
+	c.messages.Set(provider, scope, string(key), tokens)
+	return tokens, nil
+}
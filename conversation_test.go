@@ -0,0 +1,98 @@
+package tokentracker
+
+import (
+	"errors"
+	"testing"
+)
+
+// messageCountingProvider counts a conversation as 10 tokens plus the length of each
+// message's content, and records the messages it counts on their own
+type messageCountingProvider struct {
+	usagePricedProvider
+	counted []string
+	refuse  bool
+}
+
+func (p *messageCountingProvider) CountTokens(params TokenCountParams) (TokenCount, error) {
+	tokens := 10
+	for _, message := range params.Messages {
+		tokens += len(message.Content.(string))
+	}
+	return TokenCount{InputTokens: tokens, TotalTokens: tokens}, nil
+}
+
+func (p *messageCountingProvider) CountMessageTokens(model string, message Message) (int, error) {
+	if p.refuse {
+		return 0, errors.New("not additive")
+	}
+	p.counted = append(p.counted, message.Content.(string))
+	return len(message.Content.(string)), nil
+}
+
+func newConversationTracker(t *testing.T) (*DefaultTokenTracker, *messageCountingProvider) {
+	tracker := newPricedTestTracker(t)
+	provider := &messageCountingProvider{usagePricedProvider: newPricedProvider(tracker.Config(), "acme", "acme-1")}
+	tracker.RegisterProvider(provider)
+	return tracker, provider
+}
+
+func TestConversationCounter_CountsNewMessagesOnly(t *testing.T) {
+	tracker, provider := newConversationTracker(t)
+	counter := tracker.NewConversationCounter(TokenCacheOptions{})
+
+	history := []Message{
+		{Role: "system", Content: "Be brief."},
+		{Role: "user", Content: "Hello"},
+	}
+	count, err := counter.CountTokens(TokenCountParams{Model: "acme-1", Messages: history})
+	if err != nil {
+		t.Fatalf("CountTokens() error = %v", err)
+	}
+	if count.InputTokens != 24 || count.TotalTokens != 24 {
+		t.Errorf("CountTokens() = %+v, want 24 input tokens", count)
+	}
+
+	history = append(history, Message{Role: "assistant", Content: "Hi!"}, Message{Role: "user", Content: "Bye"})
+	count, err = counter.CountTokens(TokenCountParams{Model: "acme-1", Messages: history, CountResponseTokens: true})
+	if err != nil {
+		t.Fatalf("CountTokens() error = %v", err)
+	}
+	want, _ := tracker.CountTokens(TokenCountParams{Model: "acme-1", Messages: history})
+	if count.InputTokens != want.InputTokens {
+		t.Errorf("InputTokens = %d, want %d as counted whole", count.InputTokens, want.InputTokens)
+	}
+	if count.ResponseTokens == 0 || count.TotalTokens != count.InputTokens+count.ResponseTokens {
+		t.Errorf("CountTokens() = %+v, want a response estimate", count)
+	}
+
+	// Only the new messages were tokenized
+	if len(provider.counted) != 4 || provider.counted[2] != "Hi!" || provider.counted[3] != "Bye" {
+		t.Errorf("counted messages = %q, want each message once", provider.counted)
+	}
+	if stats := counter.Stats(); stats.Hits != 2 || stats.Entries != 4 {
+		t.Errorf("Stats() = %+v, want 2 hits and 4 entries", stats)
+	}
+
+	counter.Purge()
+	if stats := counter.Stats(); stats.Entries != 0 {
+		t.Errorf("Stats() after Purge() = %+v, want no entries", stats)
+	}
+}
+
+func TestConversationCounter_FallsBackToWholeCount(t *testing.T) {
+	tracker, provider := newConversationTracker(t)
+	provider.refuse = true
+	counter := tracker.NewConversationCounter(TokenCacheOptions{})
+
+	count, err := counter.CountTokens(TokenCountParams{Model: "acme-1", Messages: []Message{{Role: "user", Content: "Hello"}}})
+	if err != nil {
+		t.Fatalf("CountTokens() error = %v", err)
+	}
+	if count.InputTokens != 15 {
+		t.Errorf("InputTokens = %d, want 15 as counted whole", count.InputTokens)
+	}
+
+	if _, err := counter.CountTokens(TokenCountParams{Model: "unknown", Messages: []Message{{Role: "user", Content: "Hello"}}}); err == nil {
+		t.Error("CountTokens() of an unknown model should fail")
+	}
+}
@@ -0,0 +1,117 @@
+package tokentracker
+
+import (
+	"context"
+	"fmt"
+)
+
+// PriceRange is the cost range of a planned call: from no output at all, through the
+// expected response, up to the output ceiling (e.g. the request's max_tokens)
+type PriceRange struct {
+	Min      Price
+	Expected Price
+	Max      Price
+
+	InputTokens          int
+	ExpectedOutputTokens int // the provider's response estimate, capped at the ceiling
+	MaxOutputTokens      int
+}
+
+// EstimateCost estimates the cost of a call before it is made, for pre-flight budget
+// checks. The input of params is counted and the output bounded by maxOutputTokens.
+func (t *DefaultTokenTracker) EstimateCost(params TokenCountParams, maxOutputTokens int) (PriceRange, error) {
+	return t.EstimateCostCtx(context.Background(), params, maxOutputTokens)
+}
+
+// EstimateCostCtx estimates the cost of a call, propagating cancellation and deadlines to the provider
+func (t *DefaultTokenTracker) EstimateCostCtx(ctx context.Context, params TokenCountParams, maxOutputTokens int) (PriceRange, error) {
+	if maxOutputTokens <= 0 {
+		return PriceRange{}, NewError(ErrInvalidParams, fmt.Sprintf("max output tokens must be positive, got %d", maxOutputTokens), nil)
+	}
+
+	params.CountResponseTokens = true
+	count, err := t.CountTokensCtx(ctx, params)
+	if err != nil {
+		return PriceRange{}, err
+	}
+
+	estimate := PriceRange{
+		InputTokens:          count.InputTokens,
+		ExpectedOutputTokens: min(count.ResponseTokens, maxOutputTokens),
+		MaxOutputTokens:      maxOutputTokens,
+	}
+	if estimate.Min, err = t.CalculatePrice(params.Model, count.InputTokens, 0); err != nil {
+		return PriceRange{}, err
+	}
+	if estimate.Expected, err = t.CalculatePrice(params.Model, count.InputTokens, estimate.ExpectedOutputTokens); err != nil {
+		return PriceRange{}, err
+	}
+	if estimate.Max, err = t.CalculatePrice(params.Model, count.InputTokens, maxOutputTokens); err != nil {
+		return PriceRange{}, err
+	}
+	return estimate, nil
+}
+
+// maxAffordableOutputLimit bounds MaxAffordableOutputTokens for models without a known
+// output limit
+const maxAffordableOutputLimit = 1 << 24
+
+// affordableEpsilon absorbs floating point error when comparing prices with a budget
+const affordableEpsilon = 1e-12
+
+// MaxAffordableOutputTokens returns the largest max_tokens for a call with inputTokens
+// of input whose cost stays within budget, e.g. the RemainingCost of a budget or a
+// per-request cost ceiling, in the currency CalculatePrice reports. The result is
+// capped at the model's output limit and the room left in its context window when
+// they are known. It fails with ErrBudgetExceeded when the input alone costs more.
+func (t *DefaultTokenTracker) MaxAffordableOutputTokens(model string, inputTokens int, budget float64) (int, error) {
+	if inputTokens < 0 {
+		return 0, NewError(ErrInvalidParams, fmt.Sprintf("input tokens must not be negative, got %d", inputTokens), nil)
+	}
+	if budget < 0 {
+		return 0, NewError(ErrInvalidParams, fmt.Sprintf("budget must not be negative, got %g", budget), nil)
+	}
+
+	affordable := func(outputTokens int) (bool, error) {
+		price, err := t.CalculatePrice(model, inputTokens, outputTokens)
+		if err != nil {
+			return false, err
+		}
+		return price.TotalCost <= budget+affordableEpsilon, nil
+	}
+	if ok, err := affordable(0); err != nil || !ok {
+		if err == nil {
+			err = NewError(ErrBudgetExceeded, fmt.Sprintf("input of %d tokens costs more than %g on %s", inputTokens, budget, model), nil)
+		}
+		return 0, err
+	}
+
+	limit := maxAffordableOutputLimit
+	if info, err := t.ModelInfo(model); err == nil {
+		if info.MaxOutputTokens > 0 {
+			limit = min(limit, info.MaxOutputTokens)
+		}
+		if info.ContextWindow > 0 {
+			limit = min(limit, max(info.ContextWindow-inputTokens, 0))
+		}
+	}
+
+	// Prices grow with the output, so the affordable output is found by bisection
+	if ok, err := affordable(limit); err != nil || ok {
+		return limit, err
+	}
+	low, high := 0, limit // low is affordable, high is not
+	for high-low > 1 {
+		mid := low + (high-low)/2
+		ok, err := affordable(mid)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+	return low, nil
+}
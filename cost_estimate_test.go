@@ -0,0 +1,101 @@
+package tokentracker
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// estimatingProvider counts a fixed prompt and response estimate and prices from the configuration
+type estimatingProvider struct {
+	usagePricedProvider
+	count TokenCount
+}
+
+func (p *estimatingProvider) CountTokens(params TokenCountParams) (TokenCount, error) {
+	count := p.count
+	if !params.CountResponseTokens {
+		count.ResponseTokens = 0
+	}
+	count.TotalTokens = count.InputTokens + count.ResponseTokens
+	return count, nil
+}
+
+func TestDefaultTokenTracker_EstimateCost(t *testing.T) {
+	tracker := newPricedTestTracker(t)
+	tracker.Config().SetModelPricing("acme", "acme-1", ModelPricing{
+		InputPricePerToken:  0.00001,
+		OutputPricePerToken: 0.00003,
+		Currency:            "USD",
+	})
+	tracker.RegisterProvider(&estimatingProvider{
+		usagePricedProvider: newPricedProvider(tracker.Config(), "acme", "acme-1"),
+		count:               TokenCount{InputTokens: 1000, ResponseTokens: 300},
+	})
+
+	params := TokenCountParams{Model: "acme-1", Text: stringPtr("Plan a trip")}
+	estimate, err := tracker.EstimateCost(params, 500)
+	if err != nil {
+		t.Fatalf("EstimateCost() error = %v", err)
+	}
+	if estimate.InputTokens != 1000 || estimate.ExpectedOutputTokens != 300 || estimate.MaxOutputTokens != 500 {
+		t.Errorf("EstimateCost() tokens = %+v", estimate)
+	}
+
+	costs := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"Min", estimate.Min.TotalCost, 1000 * 0.00001},
+		{"Expected", estimate.Expected.TotalCost, 1000*0.00001 + 300*0.00003},
+		{"Max", estimate.Max.TotalCost, 1000*0.00001 + 500*0.00003},
+	}
+	for _, c := range costs {
+		if math.Abs(c.got-c.want) > 1e-12 {
+			t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+		}
+	}
+
+	// The response estimate is capped at the ceiling
+	estimate, _ = tracker.EstimateCost(params, 100)
+	if estimate.ExpectedOutputTokens != 100 || estimate.Expected.TotalCost != estimate.Max.TotalCost {
+		t.Errorf("EstimateCost() below the response estimate = %+v", estimate)
+	}
+
+	if _, err := tracker.EstimateCost(params, 0); err == nil {
+		t.Error("EstimateCost() without an output ceiling should fail")
+	}
+	if _, err := tracker.EstimateCost(TokenCountParams{Model: "unknown", Text: stringPtr("hi")}, 100); err == nil {
+		t.Error("EstimateCost() of an unknown model should fail")
+	}
+}
+
+func TestDefaultTokenTracker_MaxAffordableOutputTokens(t *testing.T) {
+	tracker := newRecommendationTracker(t)
+
+	// 1000 input tokens cost 0.01, leaving 0.03 for output at 0.00003 a token
+	if tokens, err := tracker.MaxAffordableOutputTokens("acme-large", 1000, 0.04); err != nil || tokens != 1000 {
+		t.Errorf("MaxAffordableOutputTokens() = %d, %v, want 1000", tokens, err)
+	}
+	if tokens, _ := tracker.MaxAffordableOutputTokens("acme-large", 1000, 0.0400299); tokens != 1000 {
+		t.Errorf("MaxAffordableOutputTokens() = %d, want 1000 when the next token is not affordable", tokens)
+	}
+
+	// Large budgets are capped at the model's output limit, and free output at the context window
+	if tokens, _ := tracker.MaxAffordableOutputTokens("acme-large", 1000, 100); tokens != 8192 {
+		t.Errorf("MaxAffordableOutputTokens() = %d, want the output limit", tokens)
+	}
+	if tokens, _ := tracker.MaxAffordableOutputTokens("acme-embed", 1000, 100); tokens != 7000 {
+		t.Errorf("MaxAffordableOutputTokens() = %d, want the room left in the context window", tokens)
+	}
+
+	_, err := tracker.MaxAffordableOutputTokens("acme-large", 1000, 0.005)
+	var tokenErr *TokenTrackerError
+	if !errors.As(err, &tokenErr) || tokenErr.Type != ErrBudgetExceeded {
+		t.Errorf("MaxAffordableOutputTokens() error = %v, want %s when the input is not affordable", err, ErrBudgetExceeded)
+	}
+	if _, err := tracker.MaxAffordableOutputTokens("acme-large", 1000, -1); err == nil {
+		t.Error("MaxAffordableOutputTokens() with a negative budget should fail")
+	}
+}
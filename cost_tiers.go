@@ -0,0 +1,131 @@
+package tokentracker
+
+import (
+	"fmt"
+	"sort"
+)
+
+// CostTier is a coarse classification of models by price
+type CostTier string
+
+// Cost tiers, cheapest first
+const (
+	CostTierEconomy  CostTier = "economy"
+	CostTierStandard CostTier = "standard"
+	CostTierPremium  CostTier = "premium"
+)
+
+// costTierRank orders the tiers from cheapest to most expensive
+var costTierRank = map[CostTier]int{
+	CostTierEconomy:  1,
+	CostTierStandard: 2,
+	CostTierPremium:  3,
+}
+
+// AtMost reports whether the tier is no more expensive than max, e.g. for policies
+// like "interactive traffic must use economy or standard models"
+func (t CostTier) AtMost(max CostTier) bool {
+	rank, known := costTierRank[t]
+	return known && rank <= costTierRank[max]
+}
+
+// CostTierThresholds are the upper bounds of the cheaper tiers, as the average of
+// the input and output price per 1K tokens. More expensive models are premium.
+type CostTierThresholds struct {
+	EconomyMaxPer1K  float64 `json:"economy_max_per_1k"`
+	StandardMaxPer1K float64 `json:"standard_max_per_1k"`
+}
+
+// DefaultCostTierThresholds classify models such as gpt-3.5-turbo and claude-3-haiku
+// as economy, claude-3-sonnet as standard and gpt-4 and claude-3-opus as premium
+var DefaultCostTierThresholds = CostTierThresholds{
+	EconomyMaxPer1K:  0.002,
+	StandardMaxPer1K: 0.01,
+}
+
+// Classify returns the tier of a model with the given pricing
+func (c CostTierThresholds) Classify(pricing ModelPricing) CostTier {
+	per1K := (pricing.InputPricePerToken + pricing.OutputPricePerToken) / 2 * 1000
+	switch {
+	case per1K <= c.EconomyMaxPer1K:
+		return CostTierEconomy
+	case per1K <= c.StandardMaxPer1K:
+		return CostTierStandard
+	default:
+		return CostTierPremium
+	}
+}
+
+// SetCostTierThresholds sets the thresholds used to classify models into cost tiers
+func (c *Config) SetCostTierThresholds(thresholds CostTierThresholds) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.CostTiers = thresholds
+}
+
+// GetCostTierThresholds returns the cost tier thresholds, falling back to the defaults when unset
+func (c *Config) GetCostTierThresholds() CostTierThresholds {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.CostTiers == (CostTierThresholds{}) {
+		return DefaultCostTierThresholds
+	}
+	return c.CostTiers
+}
+
+// CostTier classifies a model of a provider by its configured pricing
+func (c *Config) CostTier(provider, model string) (CostTier, bool) {
+	pricing, exists := c.GetModelPricing(provider, model)
+	if !exists {
+		return "", false
+	}
+	return c.GetCostTierThresholds().Classify(pricing), true
+}
+
+// pricedModels returns the models of a provider that have pricing
+func (c *Config) pricedModels(provider string) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	models := make([]string, 0, len(c.Providers[provider].Models))
+	for model := range c.Providers[provider].Models {
+		models = append(models, model)
+	}
+	return models
+}
+
+// CostTier returns the cost tier of a model
+func (t *DefaultTokenTracker) CostTier(model string) (CostTier, error) {
+	provider, exists := t.providerForModel(model)
+	if !exists {
+		return "", NewError(ErrProviderNotFound, fmt.Sprintf("no provider found for model: %s", model), nil)
+	}
+
+	tier, exists := t.config.CostTier(provider.Name(), model)
+	if !exists {
+		return "", NewError(ErrPricingNotFound, fmt.Sprintf("pricing not found for model: %s", model), nil)
+	}
+	return tier, nil
+}
+
+// ModelsByTier returns the priced models of the registered providers grouped by cost tier
+func (t *DefaultTokenTracker) ModelsByTier() map[CostTier][]string {
+	tiers := make(map[CostTier][]string)
+	thresholds := t.config.GetCostTierThresholds()
+
+	for _, provider := range t.registry.All() {
+		for _, model := range t.config.pricedModels(provider.Name()) {
+			if pricing, exists := t.config.GetModelPricing(provider.Name(), model); exists {
+				tier := thresholds.Classify(pricing)
+				tiers[tier] = append(tiers[tier], model)
+			}
+		}
+	}
+
+	for _, models := range tiers {
+		sort.Strings(models)
+	}
+	return tiers
+}
@@ -0,0 +1,84 @@
+package tokentracker
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCostTierThresholds_Classify(t *testing.T) {
+	config := NewConfig()
+
+	tests := []struct {
+		provider string
+		model    string
+		want     CostTier
+	}{
+		{"openai", "gpt-3.5-turbo", CostTierEconomy},
+		{"anthropic", "claude-3-haiku", CostTierEconomy},
+		{"gemini", "gemini-pro", CostTierEconomy},
+		{"anthropic", "claude-3-sonnet", CostTierStandard},
+		{"openai", "gpt-4", CostTierPremium},
+		{"anthropic", "claude-3-opus", CostTierPremium},
+	}
+	for _, tt := range tests {
+		if got, _ := config.CostTier(tt.provider, tt.model); got != tt.want {
+			t.Errorf("CostTier(%s, %s) = %s, want %s", tt.provider, tt.model, got, tt.want)
+		}
+	}
+
+	if _, exists := config.CostTier("openai", "unpriced"); exists {
+		t.Error("CostTier() of an unpriced model should not exist")
+	}
+
+	// Thresholds are configurable
+	config.SetCostTierThresholds(CostTierThresholds{EconomyMaxPer1K: 0.0001, StandardMaxPer1K: 0.05})
+	if got, _ := config.CostTier("openai", "gpt-4"); got != CostTierStandard {
+		t.Errorf("with custom thresholds gpt-4 is %s, want standard", got)
+	}
+}
+
+func TestCostTier_AtMost(t *testing.T) {
+	if !CostTierEconomy.AtMost(CostTierStandard) || !CostTierStandard.AtMost(CostTierStandard) {
+		t.Error("economy and standard should be at most standard")
+	}
+	if CostTierPremium.AtMost(CostTierStandard) || CostTier("").AtMost(CostTierPremium) {
+		t.Error("premium and unknown tiers should not be at most standard")
+	}
+}
+
+func TestDefaultTokenTracker_CostTiers(t *testing.T) {
+	config := NewConfig()
+	config.SetModelPricing("mock", "mock-model", ModelPricing{InputPricePerToken: 0.00001, OutputPricePerToken: 0.00003})
+
+	tracker := NewTokenTracker(config)
+	tracker.RegisterProvider(&MockProvider{
+		name:           "mock",
+		supportedModel: "mock-model",
+		tokenCount:     TokenCount{InputTokens: 10, TotalTokens: 10},
+		price:          Price{TotalCost: 0.01, Currency: "USD"},
+	})
+
+	if tier, err := tracker.CostTier("mock-model"); err != nil || tier != CostTierPremium {
+		t.Errorf("CostTier() = %s, %v; want premium", tier, err)
+	}
+	if _, err := tracker.CostTier("unknown-model"); err == nil {
+		t.Error("CostTier() of an unknown model should fail")
+	}
+
+	if got := tracker.ModelsByTier(); !reflect.DeepEqual(got, map[CostTier][]string{CostTierPremium: {"mock-model"}}) {
+		t.Errorf("ModelsByTier() = %v", got)
+	}
+
+	metrics, err := tracker.TrackUsage(CallParams{
+		Model:     "mock-model",
+		Params:    TokenCountParams{Model: "mock-model", Text: stringPtr("Hello")},
+		StartTime: time.Now(),
+	}, "response")
+	if err != nil {
+		t.Fatalf("TrackUsage() error = %v", err)
+	}
+	if metrics.CostTier != string(CostTierPremium) {
+		t.Errorf("CostTier = %q, want premium stamped on the metrics", metrics.CostTier)
+	}
+}
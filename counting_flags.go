@@ -0,0 +1,150 @@
+package tokentracker
+
+import (
+	"hash/fnv"
+)
+
+// CountingAlgorithm selects the implementation a provider uses to count tokens
+type CountingAlgorithm string
+
+// Counting algorithms
+const (
+	// AlgorithmLegacy is the original counting logic of each provider
+	AlgorithmLegacy CountingAlgorithm = "legacy"
+
+	// AlgorithmV2 is the next generation counting logic (e.g. OpenAI's documented message
+	// counting, the default for OpenAI)
+	AlgorithmV2 CountingAlgorithm = "v2"
+)
+
+// CountingFlag selects the counting algorithm of one provider
+type CountingFlag struct {
+	// Algorithm is the algorithm being rolled out (empty means legacy)
+	Algorithm CountingAlgorithm `json:"algorithm,omitempty"`
+
+	// RolloutPercent is the share of requests (0-100) counted with Algorithm; the rest
+	// use the legacy algorithm. 0 means all requests.
+	RolloutPercent float64 `json:"rollout_percent,omitempty"`
+
+	// Shadow also counts every request with the algorithm that was not selected and
+	// reports the delta, without affecting the returned count
+	Shadow bool `json:"shadow,omitempty"`
+}
+
+// Select returns the algorithm for a request. Requests are bucketed by hashing key,
+// so the same input always gets the same algorithm at a given rollout percentage.
+func (f CountingFlag) Select(key string) CountingAlgorithm {
+	if f.Algorithm == "" || f.Algorithm == AlgorithmLegacy {
+		return AlgorithmLegacy
+	}
+	if f.RolloutPercent <= 0 || f.RolloutPercent >= 100 {
+		return f.Algorithm
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	if float64(hash.Sum32()%10000) < f.RolloutPercent*100 {
+		return f.Algorithm
+	}
+	return AlgorithmLegacy
+}
+
+// ShadowCount is the outcome of counting a request with both algorithms
+type ShadowCount struct {
+	Provider string
+	Model    string
+	Primary  CountingAlgorithm // the algorithm whose count was returned
+	Shadow   CountingAlgorithm
+	Count    TokenCount
+	Shadowed TokenCount
+	Err      error // error of the shadow count, if any
+}
+
+// Delta returns the input token difference of the shadow count relative to the returned count
+func (s ShadowCount) Delta() int {
+	return s.Shadowed.InputTokens - s.Count.InputTokens
+}
+
+// SetCountingFlag sets the counting algorithm flag of a provider
+func (c *Config) SetCountingFlag(provider string, flag CountingFlag) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.CountingFlags == nil {
+		c.CountingFlags = make(map[string]CountingFlag)
+	}
+	c.CountingFlags[provider] = flag
+}
+
+// GetCountingFlag returns the counting algorithm flag of a provider
+func (c *Config) GetCountingFlag(provider string) CountingFlag {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.CountingFlags[provider]
+}
+
+// SetShadowReporter sets the function receiving shadow count results
+// (nil logs deltas to the configuration's logger)
+func (c *Config) SetShadowReporter(reporter func(ShadowCount)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.shadowReporter = reporter
+}
+
+// CountWithAlgorithm counts with the algorithm selected by the provider's flag for key,
+// running the other algorithm as well when shadow counting is enabled. Providers
+// implement count for each algorithm they support.
+func (c *Config) CountWithAlgorithm(provider, model, key string, count func(CountingAlgorithm) (TokenCount, error)) (TokenCount, error) {
+	flag := c.GetCountingFlag(provider)
+	primary := flag.Select(key)
+
+	result, err := count(primary)
+	if err != nil {
+		return result, err
+	}
+	result.Algorithm = string(primary)
+
+	if !flag.Shadow || flag.Algorithm == "" || flag.Algorithm == AlgorithmLegacy {
+		return result, nil
+	}
+
+	shadow := flag.Algorithm
+	if primary != AlgorithmLegacy {
+		shadow = AlgorithmLegacy
+	}
+	shadowed, shadowErr := count(shadow)
+	shadowed.Algorithm = string(shadow)
+
+	c.reportShadow(ShadowCount{
+		Provider: provider,
+		Model:    model,
+		Primary:  primary,
+		Shadow:   shadow,
+		Count:    result,
+		Shadowed: shadowed,
+		Err:      shadowErr,
+	})
+	return result, nil
+}
+
+// reportShadow passes a shadow count to the reporter or logs it
+func (c *Config) reportShadow(result ShadowCount) {
+	c.mu.RLock()
+	reporter := c.shadowReporter
+	c.mu.RUnlock()
+
+	if reporter != nil {
+		reporter(result)
+		return
+	}
+
+	if result.Err != nil {
+		c.GetLogger().Warn("shadow count failed", "provider", result.Provider, "model", result.Model, "shadow", result.Shadow, "error", result.Err)
+	} else if delta := result.Delta(); delta != 0 {
+		c.GetLogger().Info("shadow count differs", "provider", result.Provider, "model", result.Model,
+			"primary", result.Primary, "primary_tokens", result.Count.InputTokens,
+			"shadow", result.Shadow, "shadow_tokens", result.Shadowed.InputTokens, "delta", delta)
+	}
+}
@@ -0,0 +1,93 @@
+package tokentracker
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// algorithmCounter returns a fixed count per algorithm
+func algorithmCounter(calls *[]CountingAlgorithm) func(CountingAlgorithm) (TokenCount, error) {
+	return func(alg CountingAlgorithm) (TokenCount, error) {
+		*calls = append(*calls, alg)
+		if alg == AlgorithmV2 {
+			return TokenCount{InputTokens: 12}, nil
+		}
+		return TokenCount{InputTokens: 10}, nil
+	}
+}
+
+func TestCountingFlag_Select(t *testing.T) {
+	if got := (CountingFlag{}).Select("key"); got != AlgorithmLegacy {
+		t.Errorf("zero flag selects %s, want legacy", got)
+	}
+	if got := (CountingFlag{Algorithm: AlgorithmV2}).Select("key"); got != AlgorithmV2 {
+		t.Errorf("full rollout selects %s, want v2", got)
+	}
+
+	flag := CountingFlag{Algorithm: AlgorithmV2, RolloutPercent: 25}
+	v2 := 0
+	for i := 0; i < 4000; i++ {
+		key := fmt.Sprintf("prompt-%d", i)
+		if flag.Select(key) == AlgorithmV2 {
+			v2++
+		}
+		if flag.Select(key) != flag.Select(key) {
+			t.Fatalf("Select(%q) is not stable", key)
+		}
+	}
+	if v2 < 800 || v2 > 1200 {
+		t.Errorf("25%% rollout selected v2 for %d of 4000 keys", v2)
+	}
+}
+
+func TestConfig_CountWithAlgorithm(t *testing.T) {
+	config := NewConfig()
+
+	var calls []CountingAlgorithm
+	count, err := config.CountWithAlgorithm("acme", "acme-1", "key", algorithmCounter(&calls))
+	if err != nil {
+		t.Fatalf("CountWithAlgorithm() error = %v", err)
+	}
+	if count.InputTokens != 10 || count.Algorithm != "legacy" || len(calls) != 1 {
+		t.Errorf("without a flag got %+v after %v, want a single legacy count", count, calls)
+	}
+	if flag := config.GetCountingFlag("openai"); flag.Algorithm != AlgorithmV2 {
+		t.Errorf("default OpenAI flag = %+v, want v2", flag)
+	}
+
+	var shadows []ShadowCount
+	config.SetShadowReporter(func(s ShadowCount) { shadows = append(shadows, s) })
+	config.SetCountingFlag("openai", CountingFlag{Algorithm: AlgorithmV2, Shadow: true})
+
+	calls = nil
+	count, _ = config.CountWithAlgorithm("openai", "gpt-4", "key", algorithmCounter(&calls))
+	if count.InputTokens != 12 || count.Algorithm != "v2" {
+		t.Errorf("count = %+v, want the v2 count", count)
+	}
+	if len(shadows) != 1 || shadows[0].Shadow != AlgorithmLegacy || shadows[0].Delta() != -2 || shadows[0].Model != "gpt-4" {
+		t.Errorf("shadows = %+v, want one legacy shadow count with delta -2", shadows)
+	}
+
+	// Other providers are unaffected
+	calls = nil
+	count, _ = config.CountWithAlgorithm("anthropic", "claude-3-haiku", "key", algorithmCounter(&calls))
+	if count.Algorithm != "legacy" || len(calls) != 1 {
+		t.Errorf("anthropic count = %+v after %v, want legacy only", count, calls)
+	}
+
+	// A failing shadow count does not fail the request
+	config.SetCountingFlag("openai", CountingFlag{Algorithm: AlgorithmV2, RolloutPercent: 0.01, Shadow: true})
+	count, err = config.CountWithAlgorithm("openai", "gpt-4", "key", func(alg CountingAlgorithm) (TokenCount, error) {
+		if alg == AlgorithmV2 {
+			return TokenCount{}, errors.New("v2 failed")
+		}
+		return TokenCount{InputTokens: 10}, nil
+	})
+	if err != nil || count.Algorithm != "legacy" {
+		t.Errorf("count = %+v, %v; want the legacy count", count, err)
+	}
+	if last := shadows[len(shadows)-1]; last.Err == nil || last.Shadow != AlgorithmV2 {
+		t.Errorf("last shadow = %+v, want the v2 failure", last)
+	}
+}
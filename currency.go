@@ -0,0 +1,159 @@
+package tokentracker
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/TrustSight-io/tokentracker/common"
+)
+
+// CurrencyConverter converts amounts between ISO 4217 currencies, see common.CurrencyConverter
+type CurrencyConverter = common.CurrencyConverter
+
+// CurrencyConverterFunc adapts a function to the CurrencyConverter interface, e.g.
+// to plug in live exchange rates
+type CurrencyConverterFunc func(amount float64, from, to string) (float64, error)
+
+// Convert calls f(amount, from, to)
+func (f CurrencyConverterFunc) Convert(amount float64, from, to string) (float64, error) {
+	return f(amount, from, to)
+}
+
+// DefaultExchangeRates are the units of each currency per US dollar (as of March 2024)
+var DefaultExchangeRates = map[string]float64{
+	"USD": 1,
+	"EUR": 0.92,
+	"GBP": 0.79,
+	"JPY": 151.35,
+	"CHF": 0.90,
+	"CAD": 1.36,
+	"AUD": 1.53,
+	"INR": 83.40,
+}
+
+// StaticRates converts currencies at fixed rates, given as units of each currency
+// per unit of the base currency
+type StaticRates struct {
+	base  string
+	rates map[string]float64
+	mu    sync.RWMutex
+}
+
+// NewStaticRates creates a converter with rates relative to base
+func NewStaticRates(base string, rates map[string]float64) *StaticRates {
+	converter := &StaticRates{
+		base:  strings.ToUpper(base),
+		rates: map[string]float64{strings.ToUpper(base): 1},
+	}
+	for currency, rate := range rates {
+		converter.SetRate(currency, rate)
+	}
+	return converter
+}
+
+// NewDefaultStaticRates creates a converter with the DefaultExchangeRates
+func NewDefaultStaticRates() *StaticRates {
+	return NewStaticRates("USD", DefaultExchangeRates)
+}
+
+// SetRate sets the units of a currency per unit of the base currency
+func (r *StaticRates) SetRate(currency string, rate float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.rates[strings.ToUpper(currency)] = rate
+}
+
+// Convert converts amount from one currency to another through the base currency
+func (r *StaticRates) Convert(amount float64, from, to string) (float64, error) {
+	from, to = normalizeCurrency(from), normalizeCurrency(to)
+	if from == to {
+		return amount, nil
+	}
+
+	r.mu.RLock()
+	fromRate, fromOK := r.rates[from]
+	toRate, toOK := r.rates[to]
+	r.mu.RUnlock()
+
+	if !fromOK || fromRate <= 0 {
+		return 0, NewError(ErrCurrencyConversion, fmt.Sprintf("no exchange rate for %s", from), nil)
+	}
+	if !toOK || toRate <= 0 {
+		return 0, NewError(ErrCurrencyConversion, fmt.Sprintf("no exchange rate for %s", to), nil)
+	}
+	return amount / fromRate * toRate, nil
+}
+
+// normalizeCurrency upper-cases a currency code; prices without a currency are in USD
+func normalizeCurrency(currency string) string {
+	if currency == "" {
+		return "USD"
+	}
+	return strings.ToUpper(currency)
+}
+
+// SetDefaultCurrency sets the currency prices and usage summaries are reported in
+// (empty reports prices in the currency of their pricing)
+func (c *Config) SetDefaultCurrency(currency string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.DefaultCurrency = strings.ToUpper(currency)
+}
+
+// GetDefaultCurrency returns the currency prices are reported in
+func (c *Config) GetDefaultCurrency() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.DefaultCurrency
+}
+
+// SetCurrencyConverter sets the converter used to report prices in the default currency
+func (c *Config) SetCurrencyConverter(converter CurrencyConverter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.currencyConverter = converter
+}
+
+// GetCurrencyConverter returns the currency converter, by default one with the
+// DefaultExchangeRates
+func (c *Config) GetCurrencyConverter() CurrencyConverter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.currencyConverter == nil {
+		c.currencyConverter = NewDefaultStaticRates()
+	}
+	return c.currencyConverter
+}
+
+// convertPrice converts a price to the default currency, if one is set
+func (c *Config) convertPrice(price Price) (Price, error) {
+	currency := c.GetDefaultCurrency()
+	if currency == "" {
+		return price, nil
+	}
+	return price.Convert(c.GetCurrencyConverter(), currency)
+}
+
+// convertUsage converts the prices of usage records to the default currency
+func (c *Config) convertUsage(records []UsageMetrics) ([]UsageMetrics, error) {
+	if c.GetDefaultCurrency() == "" {
+		return records, nil
+	}
+
+	converted := make([]UsageMetrics, len(records))
+	for i, record := range records {
+		price, err := c.convertPrice(record.Price)
+		if err != nil {
+			return nil, err
+		}
+		record.Price = price
+		converted[i] = record
+	}
+	return converted, nil
+}
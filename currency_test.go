@@ -0,0 +1,102 @@
+package tokentracker
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestStaticRates_Convert(t *testing.T) {
+	rates := NewDefaultStaticRates()
+
+	tests := []struct {
+		amount   float64
+		from, to string
+		want     float64
+	}{
+		{10, "USD", "EUR", 9.2},
+		{10, "usd", "gbp", 7.9},
+		{9.2, "EUR", "USD", 10},
+		{9.2, "EUR", "JPY", 1513.5},
+		{10, "", "EUR", 9.2},
+		{10, "XYZ", "XYZ", 10},
+	}
+	for _, tt := range tests {
+		got, err := rates.Convert(tt.amount, tt.from, tt.to)
+		if err != nil {
+			t.Fatalf("Convert(%v, %s, %s) error = %v", tt.amount, tt.from, tt.to, err)
+		}
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Convert(%v, %s, %s) = %v, want %v", tt.amount, tt.from, tt.to, got, tt.want)
+		}
+	}
+
+	if _, err := rates.Convert(1, "USD", "XYZ"); err == nil {
+		t.Error("Convert() to a currency without a rate should fail")
+	}
+
+	rates.SetRate("XYZ", 2)
+	if got, _ := rates.Convert(1, "USD", "XYZ"); got != 2 {
+		t.Errorf("Convert() after SetRate() = %v, want 2", got)
+	}
+}
+
+func TestPrice_Convert(t *testing.T) {
+	price := Price{InputCost: 1, OutputCost: 2, TotalCost: 3, Currency: "USD"}.WithUnitPrices(1000, 1000)
+
+	converted, err := price.Convert(NewDefaultStaticRates(), "eur")
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	if converted.Currency != "EUR" || math.Abs(converted.TotalCost-3*0.92) > 1e-9 || math.Abs(converted.EffectiveOutputPricePer1K-2*0.92) > 1e-9 {
+		t.Errorf("Convert() = %+v", converted)
+	}
+
+	failing := CurrencyConverterFunc(func(amount float64, from, to string) (float64, error) {
+		return 0, errors.New("rates unavailable")
+	})
+	if _, err := price.Convert(failing, "EUR"); err == nil {
+		t.Error("Convert() should fail when the converter fails")
+	}
+	if same, err := price.Convert(failing, "USD"); err != nil || same != price {
+		t.Errorf("Convert() to the same currency = %+v, %v, want the price unchanged", same, err)
+	}
+}
+
+func TestDefaultTokenTracker_DefaultCurrency(t *testing.T) {
+	config := NewConfig()
+	config.SetDefaultCurrency("gbp")
+	config.SetCurrencyConverter(NewStaticRates("USD", map[string]float64{"GBP": 0.5}))
+
+	tracker := NewTokenTracker(config)
+	tracker.RegisterProvider(&MockProvider{
+		name:           "mock",
+		supportedModel: "mock-model",
+		price:          Price{InputCost: 1, OutputCost: 1, TotalCost: 2, Currency: "USD"},
+	})
+
+	price, err := tracker.CalculatePrice("mock-model", 10, 10)
+	if err != nil {
+		t.Fatalf("CalculatePrice() error = %v", err)
+	}
+	if price.Currency != "GBP" || price.TotalCost != 1 {
+		t.Errorf("CalculatePrice() = %+v, want 1 GBP", price)
+	}
+
+	store := NewMemoryUsageStore()
+	base := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	_ = store.Record(sampleUsage("gpt-4", "openai", base, 4))
+	gbp := sampleUsage("gpt-4", "openai", base, 1)
+	gbp.Price.Currency = "GBP"
+	_ = store.Record(gbp)
+
+	tracker = NewTokenTracker(config, WithUsageStore(store))
+	summaries, err := tracker.Summary(UsageFilter{}, GroupByModel)
+	if err != nil {
+		t.Fatalf("Summary() error = %v", err)
+	}
+	if len(summaries) != 1 || summaries[0].Currency != "GBP" || summaries[0].TotalCost != 3 {
+		t.Errorf("Summary() = %+v, want 3 GBP", summaries)
+	}
+}
@@ -0,0 +1,79 @@
+package tokentracker
+
+import "context"
+
+// EmbeddingParams contains the input of an embedding call
+type EmbeddingParams struct {
+	Model string
+	Input []string
+}
+
+// CountEmbeddingTokens counts the input tokens of an embedding call. Each input is
+// counted as plain text; embedding calls have no response tokens.
+func (t *DefaultTokenTracker) CountEmbeddingTokens(params EmbeddingParams) (TokenCount, error) {
+	return t.CountEmbeddingTokensCtx(context.Background(), params)
+}
+
+// CountEmbeddingTokensCtx counts the input tokens of an embedding call, honoring
+// cancellation of ctx
+func (t *DefaultTokenTracker) CountEmbeddingTokensCtx(ctx context.Context, params EmbeddingParams) (TokenCount, error) {
+	if params.Model == "" {
+		return TokenCount{}, NewError(ErrInvalidParams, "model is required", nil)
+	}
+	if len(params.Input) == 0 {
+		return TokenCount{}, NewError(ErrInvalidParams, "embedding input is required", nil)
+	}
+
+	provider, exists := t.providerForModel(params.Model)
+	if !exists {
+		return TokenCount{}, NewError(ErrProviderNotFound, "no provider found for model: "+params.Model, nil)
+	}
+
+	var total TokenCount
+	for i := range params.Input {
+		count, err := CountTokensWithContext(ctx, provider, TokenCountParams{Model: params.Model, Text: &params.Input[i]})
+		if err != nil {
+			return TokenCount{}, err
+		}
+		total.InputTokens += count.InputTokens
+		total.Normalization = count.Normalization
+	}
+	total.TotalTokens = total.InputTokens
+	return total, nil
+}
+
+// TrackEmbeddingUsage tracks an embedding call. The usage reported in response is
+// used when the provider can extract it; otherwise the inputs are counted locally.
+// The model of params is used when callParams has none.
+func (t *DefaultTokenTracker) TrackEmbeddingUsage(ctx context.Context, callParams CallParams, params EmbeddingParams, response interface{}) (UsageMetrics, error) {
+	if callParams.Model == "" {
+		callParams.Model = params.Model
+	}
+	if params.Model == "" {
+		params.Model = callParams.Model
+	}
+	if callParams.Model == "" {
+		return UsageMetrics{}, NewError(ErrInvalidParams, "model is required", nil)
+	}
+
+	provider, exists := t.providerForModel(callParams.Model)
+	if !exists {
+		return UsageMetrics{}, NewError(ErrProviderNotFound, "no provider found for model: "+callParams.Model, nil)
+	}
+
+	var count TokenCount
+	reported, err := provider.ExtractTokenUsageFromResponse(response)
+	if response != nil && err == nil && reported.InputTokens > 0 {
+		count = reported
+	} else {
+		count, err = t.CountEmbeddingTokensCtx(ctx, params)
+		if err != nil {
+			return UsageMetrics{}, err
+		}
+	}
+
+	// Embedding calls are billed for their input only
+	count.ResponseTokens = 0
+	count.TotalTokens = count.InputTokens
+	return t.trackTokens(ctx, callParams, count)
+}
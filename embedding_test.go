@@ -0,0 +1,66 @@
+package tokentracker
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+)
+
+// embeddingProvider counts one token per word and reports usage in {"tokens": n} responses
+type embeddingProvider struct {
+	usagePricedProvider
+}
+
+func (p *embeddingProvider) CountTokens(params TokenCountParams) (TokenCount, error) {
+	tokens := len(strings.Fields(*params.Text))
+	return TokenCount{InputTokens: tokens, TotalTokens: tokens}, nil
+}
+
+func (p *embeddingProvider) ExtractTokenUsageFromResponse(response interface{}) (TokenCount, error) {
+	resp, ok := response.(map[string]int)
+	if !ok {
+		return TokenCount{}, NewError(ErrInvalidParams, "usage information not found in response", nil)
+	}
+	return TokenCount{InputTokens: resp["tokens"], TotalTokens: resp["tokens"]}, nil
+}
+
+func TestDefaultTokenTracker_EmbeddingUsage(t *testing.T) {
+	tracker := newPricedTestTracker(t)
+	tracker.Config().SetModelPricing("acme", "acme-embed", ModelPricing{InputPricePerToken: 0.0000001, Currency: "USD"})
+	tracker.RegisterProvider(&embeddingProvider{newPricedProvider(tracker.Config(), "acme", "acme-embed")})
+
+	params := EmbeddingParams{Model: "acme-embed", Input: []string{"the quick brown fox", "jumps over the lazy dog"}}
+	count, err := tracker.CountEmbeddingTokens(params)
+	if err != nil {
+		t.Fatalf("CountEmbeddingTokens() error = %v", err)
+	}
+	if count.InputTokens != 9 || count.TotalTokens != 9 || count.ResponseTokens != 0 {
+		t.Errorf("CountEmbeddingTokens() = %+v, want 9 input tokens", count)
+	}
+
+	// Without reported usage the inputs are counted
+	metrics, err := tracker.TrackEmbeddingUsage(context.Background(), CallParams{}, params, nil)
+	if err != nil {
+		t.Fatalf("TrackEmbeddingUsage() error = %v", err)
+	}
+	if metrics.Model != "acme-embed" || metrics.TokenCount.InputTokens != 9 || math.Abs(metrics.Price.TotalCost-9*0.0000001) > 1e-15 {
+		t.Errorf("TrackEmbeddingUsage() = %+v", metrics)
+	}
+
+	// Reported usage takes precedence
+	metrics, err = tracker.TrackEmbeddingUsage(context.Background(), CallParams{Model: "acme-embed"}, params, map[string]int{"tokens": 12})
+	if err != nil {
+		t.Fatalf("TrackEmbeddingUsage() error = %v", err)
+	}
+	if metrics.TokenCount.InputTokens != 12 || metrics.TokenCount.TotalTokens != 12 {
+		t.Errorf("TrackEmbeddingUsage() TokenCount = %+v, want the reported usage", metrics.TokenCount)
+	}
+
+	if _, err := tracker.CountEmbeddingTokens(EmbeddingParams{Model: "acme-embed"}); err == nil {
+		t.Error("CountEmbeddingTokens() without input should fail")
+	}
+	if _, err := tracker.CountEmbeddingTokens(EmbeddingParams{Model: "unknown", Input: []string{"x"}}); err == nil {
+		t.Error("CountEmbeddingTokens() of an unknown model should fail")
+	}
+}
@@ -0,0 +1,295 @@
+package tokentracker
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// encryptedRecordPrefix marks an encrypted record: enc:v1:<key id>:<base64(nonce|ciphertext)>
+const encryptedRecordPrefix = "enc:v1:"
+
+// KeyProvider supplies data encryption keys, e.g. backed by a KMS or secret manager.
+// Keys must be 16, 24 or 32 bytes long (AES-128, AES-192 or AES-256).
+type KeyProvider interface {
+	// CurrentKey returns the ID and value of the key new records are encrypted with
+	CurrentKey() (string, []byte, error)
+
+	// Key returns the key with the given ID, used to decrypt existing records
+	Key(id string) ([]byte, error)
+}
+
+// StaticKeyProvider is a KeyProvider holding keys in memory.
+// Adding a key and making it current rotates the key while older keys stay available for decryption.
+type StaticKeyProvider struct {
+	keys    map[string][]byte
+	current string
+	mu      sync.RWMutex
+}
+
+// NewStaticKeyProvider creates a key provider with a single current key
+func NewStaticKeyProvider(id string, key []byte) (*StaticKeyProvider, error) {
+	p := &StaticKeyProvider{keys: make(map[string][]byte)}
+	if err := p.AddKey(id, key, true); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// AddKey adds a key, optionally making it the current encryption key
+func (p *StaticKeyProvider) AddKey(id string, key []byte, current bool) error {
+	if id == "" || strings.Contains(id, ":") {
+		return NewError(ErrEncryptionFailed, fmt.Sprintf("invalid key id: %q", id), nil)
+	}
+	if err := validateKeyLength(key); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.keys[id] = append([]byte(nil), key...)
+	if current {
+		p.current = id
+	}
+	return nil
+}
+
+// CurrentKey returns the ID and value of the current key
+func (p *StaticKeyProvider) CurrentKey() (string, []byte, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	key, exists := p.keys[p.current]
+	if !exists {
+		return "", nil, NewError(ErrEncryptionFailed, "no current encryption key", nil)
+	}
+	return p.current, key, nil
+}
+
+// Key returns the key with the given ID
+func (p *StaticKeyProvider) Key(id string) ([]byte, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	key, exists := p.keys[id]
+	if !exists {
+		return nil, NewError(ErrEncryptionFailed, fmt.Sprintf("unknown encryption key: %s", id), nil)
+	}
+	return key, nil
+}
+
+// DefaultEncryptionKeysEnv is the environment variable NewEnvKeyProvider reads by default
+const DefaultEncryptionKeysEnv = "TOKENTRACKER_ENCRYPTION_KEYS"
+
+// NewEnvKeyProvider creates a key provider from an environment variable (empty uses
+// DefaultEncryptionKeysEnv) holding comma-separated "id:base64-key" pairs. The first
+// key is current; the others stay available to decrypt records written before a rotation.
+func NewEnvKeyProvider(name string) (*StaticKeyProvider, error) {
+	if name == "" {
+		name = DefaultEncryptionKeysEnv
+	}
+	value := os.Getenv(name)
+	if value == "" {
+		return nil, NewError(ErrEncryptionFailed, fmt.Sprintf("environment variable %s is not set", name), nil)
+	}
+
+	p := &StaticKeyProvider{keys: make(map[string][]byte)}
+	for i, pair := range strings.Split(value, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok {
+			return nil, NewError(ErrEncryptionFailed, fmt.Sprintf("invalid key in %s: want id:base64-key", name), nil)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, NewError(ErrEncryptionFailed, fmt.Sprintf("invalid key %q in %s", id, name), err)
+		}
+		if err := p.AddKey(id, key, i == 0); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// CallbackKeyProvider is a KeyProvider loading keys with a callback, e.g. one
+// decrypting data keys with a KMS. Loaded keys are cached.
+type CallbackKeyProvider struct {
+	current string
+	load    func(id string) ([]byte, error)
+	keys    map[string][]byte
+	mu      sync.Mutex
+}
+
+// NewCallbackKeyProvider creates a key provider encrypting with the key currentID and
+// loading keys with load
+func NewCallbackKeyProvider(currentID string, load func(id string) ([]byte, error)) *CallbackKeyProvider {
+	return &CallbackKeyProvider{
+		current: currentID,
+		load:    load,
+		keys:    make(map[string][]byte),
+	}
+}
+
+// SetCurrentKey makes the key with the given ID the one new records are encrypted with
+func (p *CallbackKeyProvider) SetCurrentKey(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.current = id
+}
+
+// CurrentKey returns the ID and value of the current key
+func (p *CallbackKeyProvider) CurrentKey() (string, []byte, error) {
+	p.mu.Lock()
+	id := p.current
+	p.mu.Unlock()
+
+	key, err := p.Key(id)
+	if err != nil {
+		return "", nil, err
+	}
+	return id, key, nil
+}
+
+// Key returns the key with the given ID, loading it on first use
+func (p *CallbackKeyProvider) Key(id string) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, exists := p.keys[id]; exists {
+		return key, nil
+	}
+	if id == "" || strings.Contains(id, ":") {
+		return nil, NewError(ErrEncryptionFailed, fmt.Sprintf("invalid key id: %q", id), nil)
+	}
+	key, err := p.load(id)
+	if err != nil {
+		return nil, NewError(ErrEncryptionFailed, fmt.Sprintf("failed to load encryption key: %s", id), err)
+	}
+	if err := validateKeyLength(key); err != nil {
+		return nil, err
+	}
+	p.keys[id] = append([]byte(nil), key...)
+	return key, nil
+}
+
+// Encryptor seals and opens records with AES-GCM using keys from a KeyProvider.
+// The key ID is bound to each record as additional authenticated data, so any
+// modification of the record or its key reference fails integrity verification.
+type Encryptor struct {
+	keys KeyProvider
+}
+
+// NewEncryptor creates an encryptor backed by the given key provider
+func NewEncryptor(keys KeyProvider) *Encryptor {
+	return &Encryptor{keys: keys}
+}
+
+// Seal encrypts plaintext with the current key and returns a printable record
+func (e *Encryptor) Seal(plaintext []byte) (string, error) {
+	id, key, err := e.keys.CurrentKey()
+	if err != nil {
+		return "", NewError(ErrEncryptionFailed, "failed to get current encryption key", err)
+	}
+
+	aead, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", NewError(ErrEncryptionFailed, "failed to generate nonce", err)
+	}
+
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(id))
+	return encryptedRecordPrefix + id + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts and verifies a record produced by Seal
+func (e *Encryptor) Open(record string) ([]byte, error) {
+	id, payload, ok := splitEncryptedRecord(record)
+	if !ok {
+		return nil, NewError(ErrEncryptionFailed, "record is not encrypted", nil)
+	}
+
+	key, err := e.keys.Key(id)
+	if err != nil {
+		return nil, NewError(ErrEncryptionFailed, fmt.Sprintf("failed to get encryption key: %s", id), err)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, NewError(ErrEncryptionFailed, "malformed encrypted record", err)
+	}
+
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, NewError(ErrEncryptionFailed, "encrypted record too short", nil)
+	}
+
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(id))
+	if err != nil {
+		return nil, NewError(ErrEncryptionFailed, "integrity check failed", err)
+	}
+
+	return plaintext, nil
+}
+
+// KeyID returns the ID of the key a record was encrypted with
+func (e *Encryptor) KeyID(record string) (string, bool) {
+	id, _, ok := splitEncryptedRecord(record)
+	return id, ok
+}
+
+// IsEncryptedRecord reports whether a record was produced by an Encryptor
+func IsEncryptedRecord(record string) bool {
+	return strings.HasPrefix(record, encryptedRecordPrefix)
+}
+
+// splitEncryptedRecord splits an encrypted record into key ID and payload
+func splitEncryptedRecord(record string) (string, string, bool) {
+	if !IsEncryptedRecord(record) {
+		return "", "", false
+	}
+	rest := strings.TrimPrefix(record, encryptedRecordPrefix)
+	idx := strings.IndexByte(rest, ':')
+	if idx <= 0 {
+		return "", "", false
+	}
+	return rest[:idx], rest[idx+1:], true
+}
+
+// newGCM creates an AES-GCM AEAD for the key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if err := validateKeyLength(key); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, NewError(ErrEncryptionFailed, "failed to create cipher", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, NewError(ErrEncryptionFailed, "failed to create GCM", err)
+	}
+	return aead, nil
+}
+
+// validateKeyLength checks that the key is a valid AES key
+func validateKeyLength(key []byte) error {
+	switch len(key) {
+	case 16, 24, 32:
+		return nil
+	default:
+		return NewError(ErrEncryptionFailed, fmt.Sprintf("invalid key length: %d bytes (want 16, 24 or 32)", len(key)), nil)
+	}
+}
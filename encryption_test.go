@@ -0,0 +1,207 @@
+package tokentracker
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func TestEncryptor_SealOpen(t *testing.T) {
+	keys, err := NewStaticKeyProvider("k1", testKey(1))
+	if err != nil {
+		t.Fatalf("NewStaticKeyProvider() error = %v", err)
+	}
+	encryptor := NewEncryptor(keys)
+
+	record, err := encryptor.Seal([]byte(`{"model":"gpt-4"}`))
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	if !IsEncryptedRecord(record) {
+		t.Fatalf("Seal() output %q is not an encrypted record", record)
+	}
+	if id, _ := encryptor.KeyID(record); id != "k1" {
+		t.Errorf("KeyID() = %q, want k1", id)
+	}
+
+	plaintext, err := encryptor.Open(record)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if string(plaintext) != `{"model":"gpt-4"}` {
+		t.Errorf("Open() = %q", plaintext)
+	}
+
+	// Tampering with the ciphertext or the key reference fails verification
+	tampered := record[:len(record)-4] + "AAA="
+	if _, err := encryptor.Open(tampered); err == nil {
+		t.Error("Open() of tampered record should fail")
+	}
+	_ = keys.AddKey("k2", testKey(2), false)
+	relabeled := strings.Replace(record, ":k1:", ":k2:", 1)
+	if _, err := encryptor.Open(relabeled); err == nil {
+		t.Error("Open() of record with swapped key id should fail")
+	}
+}
+
+func TestStaticKeyProvider_Validation(t *testing.T) {
+	if _, err := NewStaticKeyProvider("k1", []byte("short")); err == nil {
+		t.Error("NewStaticKeyProvider() with short key should fail")
+	}
+	if _, err := NewStaticKeyProvider("bad:id", testKey(1)); err == nil {
+		t.Error("NewStaticKeyProvider() with ':' in id should fail")
+	}
+
+	keys, _ := NewStaticKeyProvider("k1", testKey(1))
+	if _, err := keys.Key("missing"); err == nil {
+		t.Error("Key() for unknown id should fail")
+	}
+}
+
+func TestEncryptedFileUsageStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	keys, _ := NewStaticKeyProvider("k1", testKey(1))
+
+	store, err := NewEncryptedFileUsageStore(path, keys)
+	if err != nil {
+		t.Fatalf("NewEncryptedFileUsageStore() error = %v", err)
+	}
+	_ = store.Record(sampleUsage("gpt-4", "openai", time.Now(), 1))
+
+	data, _ := os.ReadFile(path)
+	if bytes.Contains(data, []byte("gpt-4")) {
+		t.Error("usage file contains plaintext model name")
+	}
+
+	// Rotate to a new key and re-encrypt existing records
+	_ = keys.AddKey("k2", testKey(2), true)
+	if err := store.RotateKeys(); err != nil {
+		t.Fatalf("RotateKeys() error = %v", err)
+	}
+	_ = store.Record(sampleUsage("gpt-4", "openai", time.Now(), 2))
+	store.Close()
+
+	data, _ = os.ReadFile(path)
+	if bytes.Contains(data, []byte(":k1:")) {
+		t.Error("records still encrypted with the retired key after rotation")
+	}
+
+	// Only the new key is required to read the store after rotation
+	rotated, _ := NewStaticKeyProvider("k2", testKey(2))
+	reopened, err := NewEncryptedFileUsageStore(path, rotated)
+	if err != nil {
+		t.Fatalf("reopen error = %v", err)
+	}
+	defer reopened.Close()
+
+	records, _ := reopened.Query(UsageFilter{})
+	if len(records) != 2 {
+		t.Errorf("Query() returned %d records, want 2", len(records))
+	}
+
+	// A plain store cannot read encrypted records
+	if _, err := NewFileUsageStore(path); err == nil {
+		t.Error("NewFileUsageStore() on encrypted file should fail")
+	}
+}
+
+func TestNewEnvKeyProvider(t *testing.T) {
+	t.Setenv("TEST_USAGE_KEYS", "k2:"+base64.StdEncoding.EncodeToString(testKey(2))+", k1:"+base64.StdEncoding.EncodeToString(testKey(1)))
+	keys, err := NewEnvKeyProvider("TEST_USAGE_KEYS")
+	if err != nil {
+		t.Fatalf("NewEnvKeyProvider() error = %v", err)
+	}
+	if id, key, _ := keys.CurrentKey(); id != "k2" || !bytes.Equal(key, testKey(2)) {
+		t.Errorf("CurrentKey() = %q, want the first key current", id)
+	}
+	if key, err := keys.Key("k1"); err != nil || !bytes.Equal(key, testKey(1)) {
+		t.Errorf("Key(k1) = %v, %v, want the older key available", key, err)
+	}
+
+	t.Setenv("TEST_USAGE_KEYS", "not-a-key")
+	if _, err := NewEnvKeyProvider("TEST_USAGE_KEYS"); err == nil {
+		t.Error("NewEnvKeyProvider() of a malformed value should fail")
+	}
+	if _, err := NewEnvKeyProvider("TEST_USAGE_KEYS_UNSET"); err == nil {
+		t.Error("NewEnvKeyProvider() of an unset variable should fail")
+	}
+}
+
+func TestCallbackKeyProvider_CachesKeys(t *testing.T) {
+	loads := 0
+	keys := NewCallbackKeyProvider("kms-1", func(id string) ([]byte, error) {
+		loads++
+		return testKey(byte(len(id))), nil
+	})
+	encryptor := NewEncryptor(keys)
+
+	record, err := encryptor.Seal([]byte("usage"))
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	if _, err := encryptor.Open(record); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if loads != 1 {
+		t.Errorf("loaded %d times, want the key cached", loads)
+	}
+
+	keys.SetCurrentKey("kms-22")
+	if id, _, _ := keys.CurrentKey(); id != "kms-22" || loads != 2 {
+		t.Errorf("CurrentKey() = %q after %d loads", id, loads)
+	}
+}
+
+func TestUsageLogger_Encrypted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	keys, _ := NewStaticKeyProvider("k1", testKey(1))
+
+	config := NewConfig()
+	if err := config.EnableUsageLogging(path); err != nil {
+		t.Fatalf("EnableUsageLogging() error = %v", err)
+	}
+	config.SetUsageLogOptions(UsageLogOptions{Keys: keys})
+	tracker := NewTokenTracker(config)
+	tracker.RegisterProvider(&MockProvider{name: "openai", supportedModel: "gpt-4o", price: Price{TotalCost: 0.01}})
+	params := CallParams{Model: "gpt-4o", Tags: map[string]string{"feature": "secret-search"}}
+	if _, err := tracker.TrackReportedUsage(context.Background(), params, TokenCount{InputTokens: 10}); err != nil {
+		t.Fatalf("TrackReportedUsage() error = %v", err)
+	}
+	if err := tracker.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	raw, _ := os.ReadFile(path)
+	if bytes.Contains(raw, []byte("secret-search")) || !IsEncryptedRecord(string(raw)) {
+		t.Fatalf("usage log = %q, want encrypted records", raw)
+	}
+
+	file, _ := os.Open(path)
+	defer file.Close()
+	if _, err := ReadUsage(UsageImportJSONL, file); err == nil {
+		t.Error("ReadUsage() without keys should fail")
+	}
+	file.Seek(0, 0)
+	records, err := ReadEncryptedUsage(UsageImportJSONL, file, keys)
+	if err != nil || len(records) != 1 || records[0].Tags["feature"] != "secret-search" {
+		t.Errorf("ReadEncryptedUsage() = %+v, %v", records, err)
+	}
+
+	// Importers use the keys of the usage log options
+	store := NewMemoryUsageStore()
+	importConfig := NewConfig()
+	importConfig.SetUsageLogOptions(UsageLogOptions{Keys: keys})
+	importer := NewTokenTracker(importConfig, WithUsageStore(store))
+	if n, err := importer.ImportUsageLog(path); err != nil || n != 1 {
+		t.Errorf("ImportUsageLog() = %d, %v, want the record decrypted", n, err)
+	}
+}
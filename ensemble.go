@@ -0,0 +1,219 @@
+package tokentracker
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// EnsembleCall contains the usage of a single call within an ensemble
+type EnsembleCall struct {
+	ID       string
+	Metrics  UsageMetrics
+	Selected bool
+}
+
+// EnsembleResult summarizes a finished ensemble
+type EnsembleResult struct {
+	Feature      string
+	Calls        []EnsembleCall
+	SelectedID   string
+	TotalCost    float64
+	SelectedCost float64
+	WastedCost   float64 // cost of the responses that were not selected
+	Currency     string
+}
+
+// EnsembleFeatureStats aggregates ensemble overhead for a feature
+type EnsembleFeatureStats struct {
+	Feature      string
+	Ensembles    int
+	Calls        int
+	TotalCost    float64
+	SelectedCost float64
+	WastedCost   float64
+	Currency     string
+}
+
+// WastedRatio returns the share of the feature's ensemble cost spent on unselected responses
+func (s EnsembleFeatureStats) WastedRatio() float64 {
+	if s.TotalCost == 0 {
+		return 0
+	}
+	return s.WastedCost / s.TotalCost
+}
+
+// Ensemble groups parallel fan-out calls to several models whose responses
+// compete for a single answer. It is safe for concurrent use.
+type Ensemble struct {
+	tracker  *DefaultTokenTracker
+	feature  string
+	calls    []EnsembleCall
+	index    map[string]int
+	selected string
+	finished bool
+	mu       sync.Mutex
+}
+
+// ensembleStats stores per-feature ensemble aggregates for a tracker
+type ensembleStats struct {
+	features map[string]*EnsembleFeatureStats
+	mu       sync.RWMutex
+}
+
+// newEnsembleStats creates an empty ensemble aggregate
+func newEnsembleStats() *ensembleStats {
+	return &ensembleStats{
+		features: make(map[string]*EnsembleFeatureStats),
+	}
+}
+
+// NewEnsemble starts a new ensemble for the given feature
+func (t *DefaultTokenTracker) NewEnsemble(feature string) *Ensemble {
+	return &Ensemble{
+		tracker: t,
+		feature: feature,
+		index:   make(map[string]int),
+	}
+}
+
+// TrackCall tracks one of the ensemble's parallel calls under the given ID
+func (e *Ensemble) TrackCall(id string, callParams CallParams, response interface{}) (UsageMetrics, error) {
+	if id == "" {
+		return UsageMetrics{}, NewError(ErrInvalidParams, "ensemble call id is required", nil)
+	}
+
+	metrics, err := e.tracker.TrackUsage(callParams, response)
+	if err != nil {
+		return UsageMetrics{}, err
+	}
+
+	if err := e.AddCall(id, metrics); err != nil {
+		return UsageMetrics{}, err
+	}
+
+	return metrics, nil
+}
+
+// AddCall records already tracked usage as one of the ensemble's calls
+func (e *Ensemble) AddCall(id string, metrics UsageMetrics) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.finished {
+		return NewError(ErrInvalidParams, "ensemble already finished", nil)
+	}
+	if _, exists := e.index[id]; exists {
+		return NewError(ErrInvalidParams, fmt.Sprintf("duplicate ensemble call id: %s", id), nil)
+	}
+
+	e.index[id] = len(e.calls)
+	e.calls = append(e.calls, EnsembleCall{ID: id, Metrics: metrics})
+	return nil
+}
+
+// Select marks the call whose response was chosen
+func (e *Ensemble) Select(id string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.finished {
+		return NewError(ErrInvalidParams, "ensemble already finished", nil)
+	}
+	if _, exists := e.index[id]; !exists {
+		return NewError(ErrInvalidParams, fmt.Sprintf("unknown ensemble call id: %s", id), nil)
+	}
+
+	e.selected = id
+	return nil
+}
+
+// Result returns the current state of the ensemble without finishing it
+func (e *Ensemble) Result() EnsembleResult {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.resultLocked()
+}
+
+// Finish closes the ensemble and folds its result into the tracker's per-feature statistics.
+// When no call was selected, all calls count as wasted.
+func (e *Ensemble) Finish() (EnsembleResult, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.finished {
+		return EnsembleResult{}, NewError(ErrInvalidParams, "ensemble already finished", nil)
+	}
+	e.finished = true
+
+	result := e.resultLocked()
+	e.tracker.ensembles.add(result)
+
+	return result, nil
+}
+
+// resultLocked builds the ensemble result; the caller must hold e.mu
+func (e *Ensemble) resultLocked() EnsembleResult {
+	result := EnsembleResult{
+		Feature:    e.feature,
+		Calls:      make([]EnsembleCall, len(e.calls)),
+		SelectedID: e.selected,
+	}
+
+	for i, call := range e.calls {
+		call.Selected = call.ID == e.selected
+		result.Calls[i] = call
+
+		cost := call.Metrics.Price.TotalCost
+		result.TotalCost += cost
+		if call.Selected {
+			result.SelectedCost = cost
+		} else {
+			result.WastedCost += cost
+		}
+		if result.Currency == "" {
+			result.Currency = call.Metrics.Price.Currency
+		}
+	}
+
+	return result
+}
+
+// add folds an ensemble result into the per-feature aggregates
+func (s *ensembleStats) add(result EnsembleResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats, exists := s.features[result.Feature]
+	if !exists {
+		stats = &EnsembleFeatureStats{Feature: result.Feature, Currency: result.Currency}
+		s.features[result.Feature] = stats
+	}
+
+	stats.Ensembles++
+	stats.Calls += len(result.Calls)
+	stats.TotalCost += result.TotalCost
+	stats.SelectedCost += result.SelectedCost
+	stats.WastedCost += result.WastedCost
+}
+
+// EnsembleOverhead returns the per-feature ensemble statistics, sorted by wasted cost (highest first)
+func (t *DefaultTokenTracker) EnsembleOverhead() []EnsembleFeatureStats {
+	t.ensembles.mu.RLock()
+	defer t.ensembles.mu.RUnlock()
+
+	stats := make([]EnsembleFeatureStats, 0, len(t.ensembles.features))
+	for _, s := range t.ensembles.features {
+		stats = append(stats, *s)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].WastedCost != stats[j].WastedCost {
+			return stats[i].WastedCost > stats[j].WastedCost
+		}
+		return stats[i].Feature < stats[j].Feature
+	})
+
+	return stats
+}
@@ -0,0 +1,133 @@
+package tokentracker
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newEnsembleTestTracker() *DefaultTokenTracker {
+	tracker := NewTokenTracker(NewConfig())
+
+	for i, model := range []string{"model-a", "model-b", "model-c"} {
+		cost := float64(i+1) * 0.01
+		tracker.RegisterProvider(&MockProvider{
+			name:           "mock-" + model,
+			supportedModel: model,
+			tokenCount:     TokenCount{InputTokens: 100, ResponseTokens: 50, TotalTokens: 150},
+			price:          Price{TotalCost: cost, Currency: "USD"},
+		})
+	}
+
+	return tracker
+}
+
+func TestEnsemble_WastedCost(t *testing.T) {
+	tracker := newEnsembleTestTracker()
+	ensemble := tracker.NewEnsemble("answer-ranking")
+
+	var wg sync.WaitGroup
+	for _, model := range []string{"model-a", "model-b", "model-c"} {
+		wg.Add(1)
+		go func(model string) {
+			defer wg.Done()
+			_, err := ensemble.TrackCall(model, CallParams{
+				Model:     model,
+				Params:    TokenCountParams{Model: model, Text: stringPtr("Question")},
+				StartTime: time.Now(),
+			}, "response")
+			if err != nil {
+				t.Errorf("TrackCall(%s) error = %v", model, err)
+			}
+		}(model)
+	}
+	wg.Wait()
+
+	if err := ensemble.Select("model-b"); err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+
+	result, err := ensemble.Finish()
+	if err != nil {
+		t.Fatalf("Finish() error = %v", err)
+	}
+
+	if len(result.Calls) != 3 {
+		t.Fatalf("len(Calls) = %d, want 3", len(result.Calls))
+	}
+	if math.Abs(result.TotalCost-0.06) > 1e-9 {
+		t.Errorf("TotalCost = %v, want 0.06", result.TotalCost)
+	}
+	if math.Abs(result.SelectedCost-0.02) > 1e-9 {
+		t.Errorf("SelectedCost = %v, want 0.02", result.SelectedCost)
+	}
+	if math.Abs(result.WastedCost-0.04) > 1e-9 {
+		t.Errorf("WastedCost = %v, want 0.04", result.WastedCost)
+	}
+
+	// A finished ensemble cannot be modified
+	if err := ensemble.Select("model-a"); err == nil {
+		t.Error("Select() after Finish() should fail")
+	}
+	if _, err := ensemble.Finish(); err == nil {
+		t.Error("Finish() twice should fail")
+	}
+}
+
+func TestEnsemble_Errors(t *testing.T) {
+	tracker := newEnsembleTestTracker()
+	ensemble := tracker.NewEnsemble("feature")
+
+	if err := ensemble.Select("missing"); err == nil {
+		t.Error("Select() of unknown id should fail")
+	}
+	if err := ensemble.AddCall("a", UsageMetrics{}); err != nil {
+		t.Fatalf("AddCall() error = %v", err)
+	}
+	if err := ensemble.AddCall("a", UsageMetrics{}); err == nil {
+		t.Error("AddCall() with duplicate id should fail")
+	}
+	if _, err := ensemble.TrackCall("", CallParams{}, nil); err == nil {
+		t.Error("TrackCall() with empty id should fail")
+	}
+}
+
+func TestDefaultTokenTracker_EnsembleOverhead(t *testing.T) {
+	tracker := newEnsembleTestTracker()
+
+	for i := 0; i < 2; i++ {
+		ensemble := tracker.NewEnsemble("search")
+		_ = ensemble.AddCall("a", UsageMetrics{Price: Price{TotalCost: 1, Currency: "USD"}})
+		_ = ensemble.AddCall("b", UsageMetrics{Price: Price{TotalCost: 3, Currency: "USD"}})
+		_ = ensemble.Select("a")
+		if _, err := ensemble.Finish(); err != nil {
+			t.Fatalf("Finish() error = %v", err)
+		}
+	}
+
+	// No selection: everything is wasted
+	ensemble := tracker.NewEnsemble("summaries")
+	_ = ensemble.AddCall("a", UsageMetrics{Price: Price{TotalCost: 2, Currency: "USD"}})
+	_, _ = ensemble.Finish()
+
+	stats := tracker.EnsembleOverhead()
+	if len(stats) != 2 {
+		t.Fatalf("len(EnsembleOverhead()) = %d, want 2", len(stats))
+	}
+
+	search := stats[0]
+	if search.Feature != "search" || search.Ensembles != 2 || search.Calls != 4 {
+		t.Errorf("unexpected search stats: %+v", search)
+	}
+	if search.WastedCost != 6 || search.TotalCost != 8 {
+		t.Errorf("search costs = %v wasted / %v total, want 6 / 8", search.WastedCost, search.TotalCost)
+	}
+	if got := search.WastedRatio(); got != 0.75 {
+		t.Errorf("WastedRatio() = %v, want 0.75", got)
+	}
+
+	if stats[1].Feature != "summaries" || stats[1].WastedCost != 2 {
+		t.Errorf("unexpected summaries stats: %+v", stats[1])
+	}
+}
@@ -9,6 +9,17 @@ const (
 	ErrProviderNotFound   = "provider_not_found"
 	ErrTokenizationFailed = "tokenization_failed"
 	ErrPricingNotFound    = "pricing_not_found"
+	ErrStorageFailed      = "storage_failed"
+	ErrEncryptionFailed   = "encryption_failed"
+	ErrBudgetExceeded     = "budget_exceeded"
+	ErrQuotaExceeded      = "quota_exceeded"
+	ErrCurrencyConversion = "currency_conversion_failed"
+	ErrTrackerClosed      = "tracker_closed"
+	ErrCircuitOpen        = "circuit_open"
+	ErrSinkFailed         = "sink_failed"
+	ErrCloseFailed        = "close_failed"
+	ErrNoMatchingModel    = "no_matching_model"
+	ErrRateLimited        = "rate_limited"
 )
 
 // TokenTrackerError represents an error in the token tracker
@@ -89,6 +89,9 @@ func TestErrorConstants(t *testing.T) {
 		"ErrProviderNotFound":   ErrProviderNotFound,
 		"ErrTokenizationFailed": ErrTokenizationFailed,
 		"ErrPricingNotFound":    ErrPricingNotFound,
+		"ErrStorageFailed":      ErrStorageFailed,
+		"ErrEncryptionFailed":   ErrEncryptionFailed,
+		"ErrBudgetExceeded":     ErrBudgetExceeded,
 	}
 
 	for name, errType := range errorTypes {
@@ -0,0 +1,129 @@
+package events
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"sort"
+)
+
+// AvroSchema is the Avro schema of UsageEvent, for registering with a schema registry.
+// Timestamps are microseconds since the epoch in UTC.
+const AvroSchema = `{
+  "type": "record",
+  "name": "UsageEvent",
+  "namespace": "io.trustsight.tokentracker",
+  "fields": [
+    {"name": "schema_version", "type": "int"},
+    {"name": "event_id", "type": "string"},
+    {"name": "timestamp", "type": {"type": "long", "logicalType": "timestamp-micros"}},
+    {"name": "provider", "type": "string"},
+    {"name": "model", "type": "string"},
+    {"name": "input_tokens", "type": "long"},
+    {"name": "response_tokens", "type": "long"},
+    {"name": "total_tokens", "type": "long"},
+    {"name": "cached_input_tokens", "type": "long"},
+    {"name": "cache_write_tokens", "type": "long"},
+    {"name": "reasoning_tokens", "type": "long"},
+    {"name": "input_cost", "type": "double"},
+    {"name": "output_cost", "type": "double"},
+    {"name": "total_cost", "type": "double"},
+    {"name": "currency", "type": "string"},
+    {"name": "duration_ms", "type": "long"},
+    {"name": "user_id", "type": "string", "default": ""},
+    {"name": "project_id", "type": "string", "default": ""},
+    {"name": "trace_id", "type": "string", "default": ""},
+    {"name": "cost_tier", "type": "string", "default": ""},
+    {"name": "tags", "type": {"type": "map", "values": "string"}, "default": {}}
+  ]
+}`
+
+// avroMagic starts a message in the schema registry wire format
+const avroMagic = 0
+
+// AvroEncoder encodes events in the Avro binary encoding of AvroSchema. With a
+// SchemaID, payloads are framed in the Confluent schema registry wire format: a zero
+// byte and the big-endian schema ID precede the encoded event.
+type AvroEncoder struct {
+	SchemaID uint32
+}
+
+// Encode returns the Avro encoding of event
+func (e AvroEncoder) Encode(event UsageEvent) ([]byte, error) {
+	var buf bytes.Buffer
+	if e.SchemaID != 0 {
+		buf.WriteByte(avroMagic)
+		binary.Write(&buf, binary.BigEndian, e.SchemaID)
+	}
+
+	writeAvroLong(&buf, int64(event.SchemaVersion))
+	writeAvroString(&buf, event.EventID)
+	writeAvroLong(&buf, event.Timestamp.UnixMicro())
+	writeAvroString(&buf, event.Provider)
+	writeAvroString(&buf, event.Model)
+	for _, tokens := range []int{
+		event.InputTokens,
+		event.ResponseTokens,
+		event.TotalTokens,
+		event.CachedInputTokens,
+		event.CacheWriteTokens,
+		event.ReasoningTokens,
+	} {
+		writeAvroLong(&buf, int64(tokens))
+	}
+	writeAvroDouble(&buf, event.InputCost)
+	writeAvroDouble(&buf, event.OutputCost)
+	writeAvroDouble(&buf, event.TotalCost)
+	writeAvroString(&buf, event.Currency)
+	writeAvroLong(&buf, event.DurationMs)
+	writeAvroString(&buf, event.UserID)
+	writeAvroString(&buf, event.ProjectID)
+	writeAvroString(&buf, event.TraceID)
+	writeAvroString(&buf, event.CostTier)
+	writeAvroMap(&buf, event.Tags)
+
+	return buf.Bytes(), nil
+}
+
+// ContentType returns "application/avro"
+func (AvroEncoder) ContentType() string {
+	return "application/avro"
+}
+
+// writeAvroLong writes an int or long as a zig-zag varint
+func writeAvroLong(buf *bytes.Buffer, v int64) {
+	var scratch [binary.MaxVarintLen64]byte
+	buf.Write(scratch[:binary.PutVarint(scratch[:], v)])
+}
+
+// writeAvroString writes a string as its length followed by its UTF-8 bytes
+func writeAvroString(buf *bytes.Buffer, s string) {
+	writeAvroLong(buf, int64(len(s)))
+	buf.WriteString(s)
+}
+
+// writeAvroDouble writes a double as 8 little-endian bytes
+func writeAvroDouble(buf *bytes.Buffer, v float64) {
+	var scratch [8]byte
+	binary.LittleEndian.PutUint64(scratch[:], math.Float64bits(v))
+	buf.Write(scratch[:])
+}
+
+// writeAvroMap writes a map of strings as one block of sorted entries and the
+// terminating empty block
+func writeAvroMap(buf *bytes.Buffer, m map[string]string) {
+	if len(m) > 0 {
+		keys := make([]string, 0, len(m))
+		for key := range m {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		writeAvroLong(buf, int64(len(keys)))
+		for _, key := range keys {
+			writeAvroString(buf, key)
+			writeAvroString(buf, m[key])
+		}
+	}
+	writeAvroLong(buf, 0)
+}
@@ -0,0 +1,97 @@
+package events
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+// avroReader decodes the Avro binary encoding
+type avroReader struct {
+	*bytes.Reader
+	t *testing.T
+}
+
+func (r avroReader) long() int64 {
+	v, err := binary.ReadVarint(r)
+	if err != nil {
+		r.t.Fatalf("ReadVarint() error = %v", err)
+	}
+	return v
+}
+
+func (r avroReader) string() string {
+	data := make([]byte, r.long())
+	r.Read(data)
+	return string(data)
+}
+
+func (r avroReader) double() float64 {
+	var bits uint64
+	binary.Read(r, binary.LittleEndian, &bits)
+	return math.Float64frombits(bits)
+}
+
+func TestAvroEncoder(t *testing.T) {
+	event := NewUsageEvent(testMetrics())
+	data, err := AvroEncoder{}.Encode(event)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	r := avroReader{bytes.NewReader(data), t}
+	if v := r.long(); v != SchemaVersion {
+		t.Errorf("schema_version = %d", v)
+	}
+	if id := r.string(); id != event.EventID {
+		t.Errorf("event_id = %q, want %q", id, event.EventID)
+	}
+	if ts := r.long(); ts != event.Timestamp.UnixMicro() {
+		t.Errorf("timestamp = %d, want microseconds", ts)
+	}
+	if provider, model := r.string(), r.string(); provider != "openai" || model != "gpt-4o" {
+		t.Errorf("provider, model = %q, %q", provider, model)
+	}
+	tokens := []int64{r.long(), r.long(), r.long(), r.long(), r.long(), r.long()}
+	if tokens[0] != 1200 || tokens[1] != 300 || tokens[2] != 1500 || tokens[3] != 1000 || tokens[4] != 0 || tokens[5] != 100 {
+		t.Errorf("tokens = %v", tokens)
+	}
+	if input, output, total := r.double(), r.double(), r.double(); input != 0.002 || output != 0.003 || total != 0.005 {
+		t.Errorf("costs = %v, %v, %v", input, output, total)
+	}
+	if currency, duration := r.string(), r.long(); currency != "USD" || duration != 1500 {
+		t.Errorf("currency, duration_ms = %q, %d", currency, duration)
+	}
+	if user, project, trace, tier := r.string(), r.string(), r.string(), r.string(); user != "user-1" || project != "project-1" || trace != "" || tier != "" {
+		t.Errorf("user_id, project_id, trace_id, cost_tier = %q, %q, %q, %q", user, project, trace, tier)
+	}
+	if entries, key, value, end := r.long(), r.string(), r.string(), r.long(); entries != 1 || key != "feature" || value != "search" || end != 0 {
+		t.Errorf("tags = %d, %q: %q, %d", entries, key, value, end)
+	}
+	if r.Len() != 0 {
+		t.Errorf("%d trailing bytes", r.Len())
+	}
+
+	// The schema lists the fields in the encoded order
+	var schema struct {
+		Fields []struct{ Name string } `json:"fields"`
+	}
+	if err := json.Unmarshal([]byte(AvroSchema), &schema); err != nil {
+		t.Fatalf("AvroSchema is invalid JSON: %v", err)
+	}
+	if len(schema.Fields) != 21 || schema.Fields[2].Name != "timestamp" || schema.Fields[20].Name != "tags" {
+		t.Errorf("AvroSchema fields = %+v", schema.Fields)
+	}
+}
+
+func TestAvroEncoder_SchemaRegistryFraming(t *testing.T) {
+	event := NewUsageEvent(testMetrics())
+	plain, _ := AvroEncoder{}.Encode(event)
+	framed, _ := AvroEncoder{SchemaID: 42}.Encode(event)
+
+	if !bytes.Equal(framed[:5], []byte{0, 0, 0, 0, 42}) || !bytes.Equal(framed[5:], plain) {
+		t.Errorf("framed payload starts with %v, want the magic byte and schema ID", framed[:5])
+	}
+}
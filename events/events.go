@@ -0,0 +1,110 @@
+// Package events publishes tracked usage to message buses, so usage can be streamed
+// into a data warehouse instead of polled from a store. Every tracked call becomes a
+// versioned UsageEvent, encoded as JSON or Avro and published to a Kafka topic or a
+// NATS subject by a Sink registered with tracker.AddSink.
+//
+// The package has no client dependencies: a *nats.Conn is used as a NATSPublisher
+// directly, and a Kafka client is adapted with a KafkaProducerFunc.
+package events
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker"
+)
+
+// SchemaVersion is the version of the UsageEvent schema. It is raised whenever a
+// field is added, so consumers can tell old events from new ones.
+const SchemaVersion = 1
+
+// UsageEvent is the message published for a tracked call
+type UsageEvent struct {
+	SchemaVersion     int               `json:"schema_version"`
+	EventID           string            `json:"event_id"`
+	Timestamp         time.Time         `json:"timestamp"`
+	Provider          string            `json:"provider"`
+	Model             string            `json:"model"`
+	InputTokens       int               `json:"input_tokens"`
+	ResponseTokens    int               `json:"response_tokens"`
+	TotalTokens       int               `json:"total_tokens"`
+	CachedInputTokens int               `json:"cached_input_tokens"`
+	CacheWriteTokens  int               `json:"cache_write_tokens"`
+	ReasoningTokens   int               `json:"reasoning_tokens"`
+	InputCost         float64           `json:"input_cost"`
+	OutputCost        float64           `json:"output_cost"`
+	TotalCost         float64           `json:"total_cost"`
+	Currency          string            `json:"currency"`
+	DurationMs        int64             `json:"duration_ms"`
+	UserID            string            `json:"user_id"`
+	ProjectID         string            `json:"project_id"`
+	TraceID           string            `json:"trace_id"`
+	CostTier          string            `json:"cost_tier"`
+	Tags              map[string]string `json:"tags"`
+}
+
+// NewUsageEvent creates the event of a tracked call with a new random event ID
+func NewUsageEvent(metrics tokentracker.UsageMetrics) UsageEvent {
+	tags := make(map[string]string, len(metrics.Tags))
+	for key, value := range metrics.Tags {
+		tags[key] = value
+	}
+
+	return UsageEvent{
+		SchemaVersion:     SchemaVersion,
+		EventID:           newEventID(),
+		Timestamp:         metrics.Timestamp.UTC(),
+		Provider:          metrics.Provider,
+		Model:             metrics.Model,
+		InputTokens:       metrics.TokenCount.InputTokens,
+		ResponseTokens:    metrics.TokenCount.ResponseTokens,
+		TotalTokens:       metrics.TokenCount.TotalTokens,
+		CachedInputTokens: metrics.TokenCount.CachedInputTokens,
+		CacheWriteTokens:  metrics.TokenCount.CacheWriteTokens,
+		ReasoningTokens:   metrics.TokenCount.ReasoningTokens,
+		InputCost:         metrics.Price.InputCost,
+		OutputCost:        metrics.Price.OutputCost,
+		TotalCost:         metrics.Price.TotalCost,
+		Currency:          metrics.Price.Currency,
+		DurationMs:        metrics.Duration.Milliseconds(),
+		UserID:            metrics.UserID,
+		ProjectID:         metrics.ProjectID,
+		TraceID:           metrics.TraceID,
+		CostTier:          metrics.CostTier,
+		Tags:              tags,
+	}
+}
+
+// newEventID returns a random event ID
+func newEventID() string {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		// The system random source does not fail in practice; fall back to the time
+		return time.Now().UTC().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(id[:])
+}
+
+// Encoder serializes usage events into message payloads
+type Encoder interface {
+	// Encode returns the payload of an event
+	Encode(event UsageEvent) ([]byte, error)
+
+	// ContentType returns the MIME type of the payloads
+	ContentType() string
+}
+
+// JSONEncoder encodes events as JSON objects
+type JSONEncoder struct{}
+
+// Encode returns the JSON encoding of event
+func (JSONEncoder) Encode(event UsageEvent) ([]byte, error) {
+	return json.Marshal(event)
+}
+
+// ContentType returns "application/json"
+func (JSONEncoder) ContentType() string {
+	return "application/json"
+}
@@ -0,0 +1,61 @@
+package events
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker"
+)
+
+func testMetrics() tokentracker.UsageMetrics {
+	return tokentracker.UsageMetrics{
+		TokenCount: tokentracker.TokenCount{InputTokens: 1200, ResponseTokens: 300, TotalTokens: 1500, CachedInputTokens: 1000, ReasoningTokens: 100},
+		Price:      tokentracker.Price{InputCost: 0.002, OutputCost: 0.003, TotalCost: 0.005, Currency: "USD"},
+		Duration:   1500 * time.Millisecond,
+		Timestamp:  time.Date(2024, 5, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*3600)),
+		Model:      "gpt-4o",
+		Provider:   "openai",
+		Tags:       map[string]string{"feature": "search"},
+		UserID:     "user-1",
+		ProjectID:  "project-1",
+	}
+}
+
+func TestNewUsageEvent(t *testing.T) {
+	metrics := testMetrics()
+	event := NewUsageEvent(metrics)
+
+	if event.SchemaVersion != SchemaVersion || len(event.EventID) != 32 {
+		t.Errorf("event = %+v, want the schema version and an event ID", event)
+	}
+	if !event.Timestamp.Equal(metrics.Timestamp) || event.Timestamp.Location() != time.UTC {
+		t.Errorf("Timestamp = %v, want the call time in UTC", event.Timestamp)
+	}
+	if event.InputTokens != 1200 || event.CachedInputTokens != 1000 || event.ReasoningTokens != 100 || event.TotalCost != 0.005 || event.DurationMs != 1500 {
+		t.Errorf("event = %+v, want the tokens, cost and duration of the call", event)
+	}
+	if other := NewUsageEvent(metrics); other.EventID == event.EventID {
+		t.Error("NewUsageEvent() reused an event ID")
+	}
+
+	metrics.Tags["feature"] = "changed"
+	if event.Tags["feature"] != "search" {
+		t.Error("NewUsageEvent() shares the tags of the call")
+	}
+}
+
+func TestJSONEncoder(t *testing.T) {
+	data, err := JSONEncoder{}.Encode(NewUsageEvent(testMetrics()))
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if decoded["schema_version"] != float64(SchemaVersion) || decoded["model"] != "gpt-4o" || decoded["timestamp"] != "2024-05-01T10:00:00Z" {
+		t.Errorf("JSON event = %s", data)
+	}
+}
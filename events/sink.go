@@ -0,0 +1,170 @@
+package events
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/TrustSight-io/tokentracker"
+)
+
+// Headers set on every Kafka message
+const (
+	ContentTypeHeader   = "content-type"
+	SchemaVersionHeader = "schema-version"
+)
+
+// Message is a usage event encoded for publishing
+type Message struct {
+	Topic   string // Kafka topic or NATS subject
+	Key     []byte // partition key; nil lets the producer choose the partition
+	Value   []byte
+	Headers map[string]string
+}
+
+// KafkaProducer publishes messages to Kafka. Adapt a client with KafkaProducerFunc;
+// producers should batch messages rather than wait for every acknowledgement. A
+// producer with a Flush(ctx) error method is flushed with the sink.
+type KafkaProducer interface {
+	Produce(ctx context.Context, msg Message) error
+}
+
+// KafkaProducerFunc adapts a function to the KafkaProducer interface
+type KafkaProducerFunc func(ctx context.Context, msg Message) error
+
+// Produce calls f(ctx, msg)
+func (f KafkaProducerFunc) Produce(ctx context.Context, msg Message) error {
+	return f(ctx, msg)
+}
+
+// NATSPublisher publishes messages to NATS; *nats.Conn implements it. A publisher
+// with a FlushWithContext(ctx) error method is flushed with the sink.
+type NATSPublisher interface {
+	Publish(subject string, data []byte) error
+}
+
+// Options configures a Sink
+type Options struct {
+	// Encoder serializes the events (nil uses JSONEncoder)
+	Encoder Encoder
+
+	// Key returns the partition key of an event (nil keys events by project ID)
+	Key func(event UsageEvent) []byte
+}
+
+// Sink is a tokentracker.UsageSink publishing a UsageEvent per tracked call. Closing
+// the sink flushes it but leaves the client open, as the caller owns the connection.
+type Sink struct {
+	topic   string
+	opts    Options
+	publish func(ctx context.Context, msg Message) error
+	flush   func(ctx context.Context) error
+	closed  bool
+	mu      sync.RWMutex
+}
+
+// NewKafkaSink creates a sink producing events to a Kafka topic
+func NewKafkaSink(producer KafkaProducer, topic string, opts Options) (*Sink, error) {
+	if producer == nil || topic == "" {
+		return nil, tokentracker.NewError(tokentracker.ErrInvalidParams, "Kafka producer and topic are required", nil)
+	}
+
+	s := newSink(topic, opts, producer.Produce)
+	if flusher, ok := producer.(interface{ Flush(context.Context) error }); ok {
+		s.flush = flusher.Flush
+	}
+	return s, nil
+}
+
+// NewNATSSink creates a sink publishing events to a NATS subject. NATS messages carry
+// no headers; the schema version is part of every event.
+func NewNATSSink(conn NATSPublisher, subject string, opts Options) (*Sink, error) {
+	if conn == nil || subject == "" {
+		return nil, tokentracker.NewError(tokentracker.ErrInvalidParams, "NATS connection and subject are required", nil)
+	}
+
+	s := newSink(subject, opts, func(ctx context.Context, msg Message) error {
+		return conn.Publish(msg.Topic, msg.Value)
+	})
+	if flusher, ok := conn.(interface{ FlushWithContext(context.Context) error }); ok {
+		s.flush = flusher.FlushWithContext
+	}
+	return s, nil
+}
+
+// newSink creates a sink with the defaults applied to opts
+func newSink(topic string, opts Options, publish func(ctx context.Context, msg Message) error) *Sink {
+	if opts.Encoder == nil {
+		opts.Encoder = JSONEncoder{}
+	}
+	if opts.Key == nil {
+		opts.Key = projectKey
+	}
+	return &Sink{
+		topic:   topic,
+		opts:    opts,
+		publish: publish,
+	}
+}
+
+// projectKey keys an event by its project ID, keeping a project's events in order
+func projectKey(event UsageEvent) []byte {
+	if event.ProjectID == "" {
+		return nil
+	}
+	return []byte(event.ProjectID)
+}
+
+// Send encodes the event of a tracked call and publishes it
+func (s *Sink) Send(metrics tokentracker.UsageMetrics) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return tokentracker.NewError(tokentracker.ErrSinkFailed, "event sink is closed", nil)
+	}
+
+	event := NewUsageEvent(metrics)
+	value, err := s.opts.Encoder.Encode(event)
+	if err != nil {
+		return tokentracker.NewError(tokentracker.ErrSinkFailed, "failed to encode usage event", err)
+	}
+
+	msg := Message{
+		Topic: s.topic,
+		Key:   s.opts.Key(event),
+		Value: value,
+		Headers: map[string]string{
+			ContentTypeHeader:   s.opts.Encoder.ContentType(),
+			SchemaVersionHeader: strconv.Itoa(event.SchemaVersion),
+		},
+	}
+	if err := s.publish(context.Background(), msg); err != nil {
+		return tokentracker.NewError(tokentracker.ErrSinkFailed, "failed to publish usage event to "+s.topic, err)
+	}
+	return nil
+}
+
+// Flush flushes the client, when it can be flushed
+func (s *Sink) Flush(ctx context.Context) error {
+	if s.flush == nil {
+		return nil
+	}
+	if err := s.flush(ctx); err != nil {
+		return tokentracker.NewError(tokentracker.ErrSinkFailed, "failed to flush usage events to "+s.topic, err)
+	}
+	return nil
+}
+
+// Close flushes the client and stops publishing
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	return s.Flush(context.Background())
+}
@@ -0,0 +1,114 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker"
+	"github.com/TrustSight-io/tokentracker/providers"
+)
+
+// fakeNATSConn records published messages like a *nats.Conn
+type fakeNATSConn struct {
+	subjects []string
+	data     [][]byte
+	flushes  int
+	mu       sync.Mutex
+}
+
+func (c *fakeNATSConn) Publish(subject string, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subjects = append(c.subjects, subject)
+	c.data = append(c.data, data)
+	return nil
+}
+
+func (c *fakeNATSConn) FlushWithContext(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flushes++
+	return nil
+}
+
+func TestKafkaSink(t *testing.T) {
+	var messages []Message
+	producer := KafkaProducerFunc(func(ctx context.Context, msg Message) error {
+		messages = append(messages, msg)
+		return nil
+	})
+
+	sink, err := NewKafkaSink(producer, "llm-usage", Options{Encoder: AvroEncoder{SchemaID: 7}})
+	if err != nil {
+		t.Fatalf("NewKafkaSink() error = %v", err)
+	}
+	if err := sink.Send(testMetrics()); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	if len(messages) != 1 {
+		t.Fatalf("produced %d messages, want 1", len(messages))
+	}
+	msg := messages[0]
+	if msg.Topic != "llm-usage" || string(msg.Key) != "project-1" {
+		t.Errorf("topic, key = %q, %q, want the topic keyed by project", msg.Topic, msg.Key)
+	}
+	if msg.Headers[ContentTypeHeader] != "application/avro" || msg.Headers[SchemaVersionHeader] != "1" || msg.Value[0] != 0 {
+		t.Errorf("message = %+v, want an Avro event", msg)
+	}
+
+	// Producer errors fail the send
+	failing, _ := NewKafkaSink(KafkaProducerFunc(func(ctx context.Context, msg Message) error {
+		return errors.New("broker unavailable")
+	}), "llm-usage", Options{})
+	err = failing.Send(testMetrics())
+	var trackerErr *tokentracker.TokenTrackerError
+	if !errors.As(err, &trackerErr) || trackerErr.Type != tokentracker.ErrSinkFailed {
+		t.Errorf("Send() error = %v, want %s", err, tokentracker.ErrSinkFailed)
+	}
+
+	if _, err := NewKafkaSink(producer, "", Options{}); err == nil {
+		t.Error("NewKafkaSink() without topic should fail")
+	}
+}
+
+func TestNATSSink_WithTracker(t *testing.T) {
+	conn := &fakeNATSConn{}
+	sink, err := NewNATSSink(conn, "usage.llm", Options{})
+	if err != nil {
+		t.Fatalf("NewNATSSink() error = %v", err)
+	}
+
+	config := tokentracker.NewConfig()
+	tracker := tokentracker.NewTokenTracker(config, tokentracker.WithSink(sink))
+	tracker.RegisterProvider(providers.NewMistralProvider(config))
+
+	response := map[string]interface{}{
+		"usage": map[string]interface{}{"prompt_tokens": float64(100), "completion_tokens": float64(20), "total_tokens": float64(120)},
+	}
+	if _, err := tracker.TrackUsage(tokentracker.CallParams{Model: "mistral-small", StartTime: time.Now(), ProjectID: "project-1"}, response); err != nil {
+		t.Fatalf("TrackUsage() error = %v", err)
+	}
+	if err := tracker.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if len(conn.data) != 1 || conn.subjects[0] != "usage.llm" || conn.flushes != 1 {
+		t.Fatalf("published %d messages to %v with %d flushes, want one event flushed on Close", len(conn.data), conn.subjects, conn.flushes)
+	}
+	var event UsageEvent
+	if err := json.Unmarshal(conn.data[0], &event); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if event.SchemaVersion != SchemaVersion || event.Model != "mistral-small" || event.InputTokens != 100 || event.ProjectID != "project-1" {
+		t.Errorf("event = %+v", event)
+	}
+
+	if err := sink.Send(testMetrics()); err == nil {
+		t.Error("Send() after Close() should fail")
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"time"
@@ -21,8 +22,14 @@ func main() {
 	claudeProvider := providers.NewClaudeProvider(config)
 	tracker.RegisterProvider(claudeProvider)
 
-	// Enable automatic pricing updates every 24 hours
-	config.EnableAutomaticPricingUpdates(24 * time.Hour)
+	// Update pricing automatically every 24 hours
+	tracker.OnPricingUpdateError(func(err error) {
+		log.Printf("Pricing update failed: %v", err)
+	})
+	if err := tracker.StartPricingUpdater(context.Background(), 24*time.Hour); err != nil {
+		log.Fatalf("Failed to start pricing updates: %v", err)
+	}
+	defer tracker.Close()
 	fmt.Println("Automatic pricing updates enabled (every 24 hours)")
 
 	// Enable usage logging
@@ -0,0 +1,255 @@
+package tokentracker
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SnapshotTagSource is the tag holding the source of usage merged from snapshots
+const SnapshotTagSource = "snapshot_source"
+
+// DefaultDedupWindow is how long a UsageAggregator remembers merged records by default
+const DefaultDedupWindow = 7 * 24 * time.Hour
+
+// UsageSnapshot is a compact export of the usage tracked by one process, which a
+// central service merges with the snapshots of other processes
+type UsageSnapshot struct {
+	Source     string         `json:"source"` // service or process that tracked the usage
+	ExportedAt time.Time      `json:"exported_at"`
+	Start      time.Time      `json:"start,omitempty"` // start of the exported period (inclusive)
+	End        time.Time      `json:"end,omitempty"`   // end of the exported period (exclusive)
+	Records    []UsageMetrics `json:"records"`
+}
+
+// UsageSnapshot exports the stored usage matching filter, e.g. the usage since the
+// previous snapshot, as a snapshot of source
+func (t *DefaultTokenTracker) UsageSnapshot(source string, filter UsageFilter) (UsageSnapshot, error) {
+	records, err := t.GetUsage(filter)
+	if err != nil {
+		return UsageSnapshot{}, err
+	}
+	return UsageSnapshot{
+		Source:     source,
+		ExportedAt: t.clock().Now(),
+		Start:      filter.Start,
+		End:        filter.End,
+		Records:    records,
+	}, nil
+}
+
+// WriteUsageSnapshot writes a snapshot as gzipped JSON
+func WriteUsageSnapshot(w io.Writer, snapshot UsageSnapshot) error {
+	zw := gzip.NewWriter(w)
+	if err := json.NewEncoder(zw).Encode(snapshot); err != nil {
+		return NewError(ErrStorageFailed, "failed to write usage snapshot", err)
+	}
+	if err := zw.Close(); err != nil {
+		return NewError(ErrStorageFailed, "failed to write usage snapshot", err)
+	}
+	return nil
+}
+
+// ReadUsageSnapshot reads a snapshot written by WriteUsageSnapshot
+func ReadUsageSnapshot(r io.Reader) (UsageSnapshot, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return UsageSnapshot{}, NewError(ErrInvalidParams, "invalid usage snapshot", err)
+	}
+	defer zr.Close()
+
+	var snapshot UsageSnapshot
+	if err := json.NewDecoder(zr).Decode(&snapshot); err != nil {
+		return UsageSnapshot{}, NewError(ErrInvalidParams, "invalid usage snapshot", err)
+	}
+	return snapshot, nil
+}
+
+// snapshotRecordKey identifies a record across snapshots: by its completion ID, or else
+// by its source, time, model and tokens, so that a snapshot merged twice counts once
+func snapshotRecordKey(source string, metrics UsageMetrics) string {
+	if metrics.CompletionID != "" {
+		return "id:" + metrics.CompletionID
+	}
+	return fmt.Sprintf("record:%s|%d|%s|%s|%d|%d", source, metrics.Timestamp.UnixNano(), metrics.Provider,
+		metrics.Model, metrics.TokenCount.InputTokens, metrics.TokenCount.ResponseTokens)
+}
+
+// mergedRecordKey identifies a merged record by the source it is tagged with, which is
+// the same whether the record was just merged or read back from a store
+func mergedRecordKey(metrics UsageMetrics) string {
+	return snapshotRecordKey(metrics.Tags[SnapshotTagSource], metrics)
+}
+
+// snapshotRecord returns a merged record tagged with its snapshot's source
+func snapshotRecord(source string, metrics UsageMetrics) UsageMetrics {
+	if source != "" {
+		metrics.Tags = copyTags(metrics.Tags)
+		if metrics.Tags == nil {
+			metrics.Tags = make(map[string]string)
+		}
+		if _, exists := metrics.Tags[SnapshotTagSource]; !exists {
+			metrics.Tags[SnapshotTagSource] = source
+		}
+	}
+	return metrics
+}
+
+// MergeSnapshots combines the usage of snapshots, ordered by timestamp. A call tracked by
+// several processes, or exported in several snapshots, is counted once: records are
+// deduplicated by CompletionID, and records without one by their source, time, model
+// and tokens. Merged records are tagged with their source (SnapshotTagSource). It
+// returns the merged records and the number of duplicates dropped.
+func MergeSnapshots(snapshots ...UsageSnapshot) ([]UsageMetrics, int) {
+	seen := make(map[string]bool)
+	var merged []UsageMetrics
+	duplicates := 0
+	for _, snapshot := range snapshots {
+		for _, record := range snapshot.Records {
+			record = snapshotRecord(snapshot.Source, record)
+			key := mergedRecordKey(record)
+			if seen[key] {
+				duplicates++
+				continue
+			}
+			seen[key] = true
+			merged = append(merged, record)
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Timestamp.Before(merged[j].Timestamp)
+	})
+	return merged, duplicates
+}
+
+// UsageAggregatorOptions configures a UsageAggregator
+type UsageAggregatorOptions struct {
+	// DedupWindow is how long merged records are remembered, by their timestamps
+	// (0 uses DefaultDedupWindow). Snapshots resent later than this are counted again.
+	DedupWindow time.Duration
+
+	// Clock times the window (nil uses the system clock)
+	Clock Clock
+}
+
+// UsageAggregator merges the snapshots of many trackers into a usage store as they
+// arrive, counting every call once, for a central billing service. It remembers the
+// keys of the records merged within the dedup window, starting with those of the
+// records of the window already in the store, so snapshots merged before a restart
+// are not counted again. It is safe for concurrent use.
+type UsageAggregator struct {
+	store    UsageStore
+	opts     UsageAggregatorOptions
+	seen     map[string]time.Time // timestamps of the remembered records by key
+	seeded   bool                 // whether the keys of the stored records were loaded
+	prunedAt time.Time
+	mu       sync.Mutex
+}
+
+// NewUsageAggregator creates an aggregator merging into store (nil uses a new
+// MemoryUsageStore)
+func NewUsageAggregator(store UsageStore, opts UsageAggregatorOptions) *UsageAggregator {
+	if store == nil {
+		store = NewMemoryUsageStore()
+	}
+	if opts.DedupWindow <= 0 {
+		opts.DedupWindow = DefaultDedupWindow
+	}
+	if opts.Clock == nil {
+		opts.Clock = SystemClock
+	}
+	return &UsageAggregator{
+		store: store,
+		opts:  opts,
+		seen:  make(map[string]time.Time),
+	}
+}
+
+// Merge records the usage of a snapshot not merged before, see MergeSnapshots. It
+// returns the number of records added and of duplicates dropped.
+func (a *UsageAggregator) Merge(snapshot UsageSnapshot) (int, int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.seed(); err != nil {
+		return 0, 0, err
+	}
+	a.prune()
+
+	var records []UsageMetrics
+	keys := make([]string, 0, len(snapshot.Records))
+	batch := make(map[string]bool)
+	duplicates := 0
+	for _, record := range snapshot.Records {
+		record = snapshotRecord(snapshot.Source, record)
+		key := mergedRecordKey(record)
+		if _, seen := a.seen[key]; seen || batch[key] {
+			duplicates++
+			continue
+		}
+		batch[key] = true
+		keys = append(keys, key)
+		records = append(records, record)
+	}
+	if len(records) == 0 {
+		return 0, duplicates, nil
+	}
+
+	added, err := recordAll(a.store, records)
+	for i, key := range keys[:added] {
+		a.seen[key] = records[i].Timestamp
+	}
+	return added, duplicates, err
+}
+
+// seed loads the keys of the records of the dedup window in the store on first use
+func (a *UsageAggregator) seed() error {
+	if a.seeded {
+		return nil
+	}
+	records, err := a.store.Query(UsageFilter{Start: a.opts.Clock.Now().Add(-a.opts.DedupWindow)})
+	if err != nil {
+		return NewError(ErrStorageFailed, "failed to query usage store", err)
+	}
+	for _, record := range records {
+		a.seen[mergedRecordKey(record)] = record.Timestamp
+	}
+	a.seeded = true
+	return nil
+}
+
+// prune forgets the records older than the dedup window, scanning the keys at most
+// every hundredth of the window
+func (a *UsageAggregator) prune() {
+	now := a.opts.Clock.Now()
+	if now.Sub(a.prunedAt) < a.opts.DedupWindow/100 {
+		return
+	}
+	cutoff := now.Add(-a.opts.DedupWindow)
+	for key, timestamp := range a.seen {
+		if timestamp.Before(cutoff) {
+			delete(a.seen, key)
+		}
+	}
+	a.prunedAt = now
+}
+
+// Store returns the store holding the merged usage
+func (a *UsageAggregator) Store() UsageStore {
+	return a.store
+}
+
+// Summary aggregates the merged usage matching the filter grouped by the given dimensions
+func (a *UsageAggregator) Summary(filter UsageFilter, groupBy ...GroupBy) ([]UsageSummary, error) {
+	filter.Limit = 0
+	records, err := a.store.Query(filter)
+	if err != nil {
+		return nil, NewError(ErrStorageFailed, "failed to query usage store", err)
+	}
+	return SummarizeUsage(records, groupBy...), nil
+}
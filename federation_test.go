@@ -0,0 +1,136 @@
+package tokentracker
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker/tokentrackertest"
+)
+
+func TestUsageSnapshot_Merge(t *testing.T) {
+	clock := tokentrackertest.NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	gateway, _ := newIdempotentTracker(t, clock)
+	worker, _ := newIdempotentTracker(t, clock)
+
+	// The gateway and the worker both track the shared completion
+	shared := map[string]interface{}{"id": "chatcmpl-1", "usage": TokenCount{InputTokens: 100, ResponseTokens: 20}}
+	for _, tracker := range []*DefaultTokenTracker{gateway, worker} {
+		metrics, err := tracker.TrackUsage(CallParams{Model: "acme-1", StartTime: clock.Now()}, shared)
+		if err != nil || metrics.CompletionID != "acme:chatcmpl-1" {
+			t.Fatalf("TrackUsage() = %+v, %v, want the completion ID", metrics, err)
+		}
+	}
+	if _, err := worker.TrackReportedUsage(context.Background(), CallParams{Model: "acme-1"}, TokenCount{InputTokens: 5}); err != nil {
+		t.Fatalf("TrackReportedUsage() error = %v", err)
+	}
+
+	snapshots := make([]UsageSnapshot, 0, 2)
+	for source, tracker := range map[string]*DefaultTokenTracker{"gateway": gateway, "worker": worker} {
+		snapshot, err := tracker.UsageSnapshot(source, UsageFilter{})
+		if err != nil {
+			t.Fatalf("UsageSnapshot() error = %v", err)
+		}
+		var buf bytes.Buffer
+		if err := WriteUsageSnapshot(&buf, snapshot); err != nil {
+			t.Fatalf("WriteUsageSnapshot() error = %v", err)
+		}
+		decoded, err := ReadUsageSnapshot(&buf)
+		if err != nil || decoded.Source != source || len(decoded.Records) != len(snapshot.Records) {
+			t.Fatalf("ReadUsageSnapshot() = %+v, %v", decoded, err)
+		}
+		snapshots = append(snapshots, decoded)
+	}
+
+	merged, duplicates := MergeSnapshots(snapshots...)
+	if len(merged) != 2 || duplicates != 1 {
+		t.Errorf("MergeSnapshots() = %d records, %d duplicates, want the shared call counted once", len(merged), duplicates)
+	}
+
+	// Snapshots merged again add nothing, including records without a completion ID
+	aggregator := NewUsageAggregator(nil, UsageAggregatorOptions{Clock: clock})
+	for i := 0; i < 2; i++ {
+		for _, snapshot := range snapshots {
+			if _, _, err := aggregator.Merge(snapshot); err != nil {
+				t.Fatalf("Merge() error = %v", err)
+			}
+		}
+	}
+	summaries, err := aggregator.Summary(UsageFilter{}, GroupByTag(SnapshotTagSource))
+	if err != nil {
+		t.Fatalf("Summary() error = %v", err)
+	}
+	calls := 0
+	for _, summary := range summaries {
+		calls += summary.Calls
+	}
+	if calls != 2 {
+		t.Errorf("Summary() = %+v, want 2 calls", summaries)
+	}
+	if added, duplicates, _ := aggregator.Merge(snapshots[0]); added != 0 || duplicates != len(snapshots[0].Records) {
+		t.Errorf("Merge() = %d added, %d duplicates, want all duplicates", added, duplicates)
+	}
+
+	// An aggregator restarted over the same store does not count them again
+	restarted := NewUsageAggregator(aggregator.Store(), UsageAggregatorOptions{Clock: clock})
+	for _, snapshot := range snapshots {
+		if added, _, err := restarted.Merge(snapshot); err != nil || added != 0 {
+			t.Errorf("Merge() after a restart = %d added, %v, want all duplicates", added, err)
+		}
+	}
+
+	if _, err := ReadUsageSnapshot(bytes.NewReader([]byte("not a snapshot"))); err == nil {
+		t.Error("ReadUsageSnapshot() of invalid data should fail")
+	}
+}
+
+func TestUsageAggregator_RelayedSnapshots(t *testing.T) {
+	clock := tokentrackertest.NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+
+	// A relay forwards records that already carry the source of their first snapshot
+	relayed := UsageSnapshot{Source: "relay", Records: []UsageMetrics{{
+		Timestamp:  clock.Now(),
+		Provider:   "acme",
+		Model:      "acme-1",
+		TokenCount: TokenCount{InputTokens: 100, ResponseTokens: 20},
+		Tags:       map[string]string{SnapshotTagSource: "worker"},
+	}}}
+
+	aggregator := NewUsageAggregator(nil, UsageAggregatorOptions{Clock: clock})
+	if added, _, err := aggregator.Merge(relayed); err != nil || added != 1 {
+		t.Fatalf("Merge() = %d added, %v, want the record added", added, err)
+	}
+	restarted := NewUsageAggregator(aggregator.Store(), UsageAggregatorOptions{Clock: clock})
+	if added, duplicates, err := restarted.Merge(relayed); err != nil || added != 0 || duplicates != 1 {
+		t.Errorf("Merge() after a restart = %d added, %d duplicates, %v, want the relayed record counted once", added, duplicates, err)
+	}
+}
+
+func TestUsageAggregator_DedupWindow(t *testing.T) {
+	clock := tokentrackertest.NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	snapshot := func(at time.Time, id string) UsageSnapshot {
+		return UsageSnapshot{Source: "worker", Records: []UsageMetrics{{Timestamp: at, Model: "acme-1", CompletionID: id}}}
+	}
+
+	store := NewMemoryUsageStore()
+	aggregator := NewUsageAggregator(store, UsageAggregatorOptions{DedupWindow: 24 * time.Hour, Clock: clock})
+	old, recent := snapshot(clock.Now().Add(-2*time.Hour), "acme:old"), snapshot(clock.Now(), "acme:recent")
+	for _, s := range []UsageSnapshot{old, recent} {
+		if _, _, err := aggregator.Merge(s); err != nil {
+			t.Fatalf("Merge() error = %v", err)
+		}
+	}
+
+	// Records older than the window are forgotten
+	clock.Advance(23 * time.Hour)
+	if added, _, _ := aggregator.Merge(snapshot(clock.Now(), "acme:next")); added != 1 || len(aggregator.seen) != 2 {
+		t.Errorf("Merge() = %d added remembering %d records, want the record older than the window forgotten", added, len(aggregator.seen))
+	}
+
+	// A restarted aggregator only loads the records of the window
+	restarted := NewUsageAggregator(store, UsageAggregatorOptions{DedupWindow: 24 * time.Hour, Clock: clock})
+	if added, duplicates, _ := restarted.Merge(recent); added != 0 || duplicates != 1 || len(restarted.seen) != 2 {
+		t.Errorf("Merge() after a restart = %d added, %d duplicates, remembering %d records, want the window's 2 records loaded", added, duplicates, len(restarted.seen))
+	}
+}
@@ -0,0 +1,303 @@
+package tokentracker
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// FileUsageStore is a UsageStore backed by a JSON lines file.
+// Existing records are loaded when the store is opened and new records are appended.
+type FileUsageStore struct {
+	path      string
+	file      *os.File
+	records   []UsageMetrics
+	encryptor *Encryptor
+	mu        sync.RWMutex
+}
+
+// NewFileUsageStore opens (or creates) a file-backed usage store at the given path
+func NewFileUsageStore(path string) (*FileUsageStore, error) {
+	return newFileUsageStore(path, nil)
+}
+
+// NewEncryptedFileUsageStore opens (or creates) a file-backed usage store whose records
+// are encrypted at rest with AES-GCM using keys from the given provider.
+// Plaintext records written before encryption was enabled remain readable.
+func NewEncryptedFileUsageStore(path string, keys KeyProvider) (*FileUsageStore, error) {
+	if keys == nil {
+		return nil, NewError(ErrInvalidParams, "key provider is required", nil)
+	}
+	return newFileUsageStore(path, NewEncryptor(keys))
+}
+
+// newFileUsageStore opens a file-backed usage store with an optional encryptor
+func newFileUsageStore(path string, encryptor *Encryptor) (*FileUsageStore, error) {
+	records, err := readUsageFile(path, encryptor)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, NewError(ErrStorageFailed, fmt.Sprintf("failed to open usage store: %s", path), err)
+	}
+
+	return &FileUsageStore{
+		path:      path,
+		file:      file,
+		records:   records,
+		encryptor: encryptor,
+	}, nil
+}
+
+// Record appends the usage of a single tracked call to the file
+func (s *FileUsageStore) Record(metrics UsageMetrics) error {
+	line, err := encodeUsageLine(metrics, s.encryptor)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return NewError(ErrStorageFailed, "usage store is closed", nil)
+	}
+
+	if _, err := s.file.Write(line); err != nil {
+		return NewError(ErrStorageFailed, "failed to write usage record", err)
+	}
+
+	s.records = append(s.records, metrics)
+	return nil
+}
+
+// RecordBatch appends the usage of many calls to the file in a single write
+func (s *FileUsageStore) RecordBatch(records []UsageMetrics) error {
+	var data []byte
+	for _, metrics := range records {
+		line, err := encodeUsageLine(metrics, s.encryptor)
+		if err != nil {
+			return err
+		}
+		data = append(data, line...)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return NewError(ErrStorageFailed, "usage store is closed", nil)
+	}
+
+	if _, err := s.file.Write(data); err != nil {
+		return NewError(ErrStorageFailed, "failed to write usage records", err)
+	}
+
+	s.records = append(s.records, records...)
+	return nil
+}
+
+// Query returns the stored usage matching the filter, ordered by timestamp
+func (s *FileUsageStore) Query(filter UsageFilter) ([]UsageMetrics, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return filterUsage(s.records, filter), nil
+}
+
+// RotateKeys rewrites the backing file so every record is encrypted with the
+// key provider's current key. Older keys can be retired afterwards.
+func (s *FileUsageStore) RotateKeys() error {
+	if s.encryptor == nil {
+		return NewError(ErrInvalidParams, "usage store is not encrypted", nil)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return NewError(ErrStorageFailed, "usage store is closed", nil)
+	}
+	return s.rewrite(s.records)
+}
+
+// Prune deletes the usage recorded before a time by rewriting the backing file. It
+// returns the number of records deleted.
+func (s *FileUsageStore) Prune(before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return 0, NewError(ErrStorageFailed, "usage store is closed", nil)
+	}
+
+	kept := make([]UsageMetrics, 0, len(s.records))
+	for _, metrics := range s.records {
+		if !metrics.Timestamp.Before(before) {
+			kept = append(kept, metrics)
+		}
+	}
+	deleted := int64(len(s.records) - len(kept))
+	if deleted == 0 {
+		return 0, nil
+	}
+	if err := s.rewrite(kept); err != nil {
+		return 0, err
+	}
+	s.records = kept
+	return deleted, nil
+}
+
+// rewrite atomically replaces the backing file with records, encrypted with the
+// current key. The caller must hold the lock.
+func (s *FileUsageStore) rewrite(records []UsageMetrics) error {
+	tmpPath := s.path + ".rotate"
+	tmp, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return NewError(ErrStorageFailed, "failed to create rotation file", err)
+	}
+
+	writer := bufio.NewWriter(tmp)
+	for _, metrics := range records {
+		line, err := encodeUsageLine(metrics, s.encryptor)
+		if err == nil {
+			_, err = writer.Write(line)
+		}
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmpPath)
+			return NewError(ErrStorageFailed, "failed to rewrite usage records", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return NewError(ErrStorageFailed, "failed to write rotation file", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return NewError(ErrStorageFailed, "failed to sync rotation file", err)
+	}
+	tmp.Close()
+
+	s.file.Close()
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		os.Remove(tmpPath)
+		s.file, _ = os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		return NewError(ErrStorageFailed, "failed to replace usage store", err)
+	}
+
+	s.file, err = os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return NewError(ErrStorageFailed, "failed to reopen usage store", err)
+	}
+
+	return nil
+}
+
+// Path returns the path of the backing file
+func (s *FileUsageStore) Path() string {
+	return s.path
+}
+
+// Close flushes and closes the backing file
+func (s *FileUsageStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+
+	err := s.file.Sync()
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	s.file = nil
+
+	if err != nil {
+		return NewError(ErrStorageFailed, "failed to close usage store", err)
+	}
+	return nil
+}
+
+// encodeUsageLine serializes a usage record as a single (optionally encrypted) line
+func encodeUsageLine(metrics UsageMetrics, encryptor *Encryptor) ([]byte, error) {
+	data, err := json.Marshal(metrics)
+	if err != nil {
+		return nil, NewError(ErrStorageFailed, "failed to encode usage record", err)
+	}
+
+	if encryptor != nil {
+		sealed, err := encryptor.Seal(data)
+		if err != nil {
+			return nil, err
+		}
+		data = []byte(sealed)
+	}
+
+	return append(data, '\n'), nil
+}
+
+// decodeUsageLine parses a single usage line, decrypting it when necessary
+func decodeUsageLine(line []byte, encryptor *Encryptor) (UsageMetrics, error) {
+	if IsEncryptedRecord(string(line)) {
+		if encryptor == nil {
+			return UsageMetrics{}, NewError(ErrEncryptionFailed, "encrypted record found but no key provider configured", nil)
+		}
+		plaintext, err := encryptor.Open(string(line))
+		if err != nil {
+			return UsageMetrics{}, err
+		}
+		line = plaintext
+	}
+
+	var metrics UsageMetrics
+	if err := json.Unmarshal(line, &metrics); err != nil {
+		return UsageMetrics{}, err
+	}
+	return metrics, nil
+}
+
+// readUsageFile reads all usage records from a JSON lines file, decrypting encrypted lines.
+// A missing file yields no records.
+func readUsageFile(path string, encryptor *Encryptor) ([]UsageMetrics, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, NewError(ErrStorageFailed, fmt.Sprintf("failed to open usage store: %s", path), err)
+	}
+	defer file.Close()
+
+	var records []UsageMetrics
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		metrics, err := decodeUsageLine(scanner.Bytes(), encryptor)
+		if err != nil {
+			return nil, NewError(ErrStorageFailed, fmt.Sprintf("invalid usage record at %s:%d", path, line), err)
+		}
+		records = append(records, metrics)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, NewError(ErrStorageFailed, fmt.Sprintf("failed to read usage store: %s", path), err)
+	}
+
+	return records, nil
+}
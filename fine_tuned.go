@@ -0,0 +1,166 @@
+package tokentracker
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// FineTunedModel is a fine-tuned model identifier split into its parts
+type FineTunedModel struct {
+	// BaseModel is the model that was fine-tuned, e.g. "gpt-4o-mini-2024-07-18"
+	BaseModel string
+
+	// Organization is the organization owning the model
+	Organization string
+
+	// Suffix is the name given to the model when it was trained (may be empty)
+	Suffix string
+
+	// ID is the identifier of the fine-tuning job's model
+	ID string
+}
+
+// legacyFineTunedModel matches the fine-tuned models of OpenAI's legacy API, e.g.
+// "curie:ft-acme-2023-03-01-12-00-00" or "davinci:ft-acme:support-2023-03-01-12-00-00"
+var legacyFineTunedModel = regexp.MustCompile(`^([a-z0-9-]+):ft-([^:]+?)(?::(.+))?-(\d{4}-\d{2}-\d{2}-\d{2}-\d{2}-\d{2})$`)
+
+// ParseFineTunedModel recognizes fine-tuned model identifiers, such as OpenAI's
+// "ft:gpt-4o-mini-2024-07-18:acme:support:9abcDEF" and their legacy
+// "curie:ft-acme-2023-03-01-12-00-00", and returns their parts
+func ParseFineTunedModel(model string) (FineTunedModel, bool) {
+	if rest, ok := strings.CutPrefix(model, "ft:"); ok {
+		parts := strings.Split(rest, ":")
+		if len(parts) < 2 || len(parts) > 4 || parts[0] == "" {
+			return FineTunedModel{}, false
+		}
+		fineTuned := FineTunedModel{BaseModel: parts[0], Organization: parts[1]}
+		if len(parts) > 2 {
+			fineTuned.Suffix = parts[2]
+		}
+		if len(parts) > 3 {
+			fineTuned.ID = parts[3]
+		}
+		return fineTuned, true
+	}
+
+	if match := legacyFineTunedModel.FindStringSubmatch(model); match != nil {
+		return FineTunedModel{BaseModel: match[1], Organization: match[2], Suffix: match[3], ID: match[4]}, true
+	}
+	return FineTunedModel{}, false
+}
+
+// FineTunedPricingKey is the model name fine-tuned pricing of a base model is stored
+// under in the configuration, e.g. "ft:gpt-4o-mini"
+func FineTunedPricingKey(baseModel string) string {
+	return "ft:" + baseModel
+}
+
+// SetFineTunedPricing sets the pricing of the models fine-tuned from a base model or
+// its family, e.g. "gpt-4o-mini" for every "ft:gpt-4o-mini-2024-07-18:..." model.
+// Its TrainingPricePerToken prices the fine-tuning jobs of the base model. Fine-tuned
+// models without such pricing inherit the pricing of their base model.
+func (c *Config) SetFineTunedPricing(provider, baseModel string, pricing ModelPricing) {
+	c.SetModelPricing(provider, FineTunedPricingKey(baseModel), pricing)
+}
+
+// GetFineTunedPricing returns the fine-tuned pricing of a base model, or of the model
+// its catalog entry is priced like
+func (c *Config) GetFineTunedPricing(provider, baseModel string) (ModelPricing, bool) {
+	if pricing, exists := c.modelPricing(provider, FineTunedPricingKey(baseModel)); exists {
+		return pricing, true
+	}
+	if pricingModel, ok := c.catalogPricingModel(provider, baseModel); ok {
+		return c.modelPricing(provider, FineTunedPricingKey(pricingModel))
+	}
+	return ModelPricing{}, false
+}
+
+// fineTunedModelPricing returns the pricing of a model fine-tuned from baseModel: the
+// fine-tuned pricing of the base model, or else the pricing of the base model itself
+func (c *Config) fineTunedModelPricing(provider, baseModel string) (ModelPricing, bool) {
+	if pricing, exists := c.GetFineTunedPricing(provider, baseModel); exists {
+		return pricing, true
+	}
+	return c.GetModelPricing(provider, baseModel)
+}
+
+// TrainingUsage is the usage of a fine-tuning job
+type TrainingUsage struct {
+	// BaseModel is the model that was fine-tuned
+	BaseModel string
+
+	// FineTunedModel is the resulting model, when known
+	FineTunedModel string
+
+	// JobID identifies the fine-tuning job
+	JobID string
+
+	// TrainedTokens are the billed training tokens: the tokens of the training file
+	// times the number of epochs, as reported in a job's trained_tokens
+	TrainedTokens int
+
+	UserID    string
+	ProjectID string
+	Tags      map[string]string
+}
+
+// Tags of tracked training usage
+const (
+	// TagFineTuningJob is the tag holding the fine-tuning job of training usage
+	TagFineTuningJob = "fine_tuning_job"
+
+	// TagFineTunedModel is the tag holding the model a fine-tuning job produced
+	TagFineTunedModel = "fine_tuned_model"
+)
+
+// TrackTrainingUsage tracks the training tokens of a fine-tuning job, priced with
+// the TrainingPricePerToken of the base model's fine-tuned pricing. The usage is
+// recorded like tracked calls, with the tokens in TokenCount.TrainingTokens and the
+// cost in Price.TrainingCost.
+func (t *DefaultTokenTracker) TrackTrainingUsage(ctx context.Context, usage TrainingUsage) (UsageMetrics, error) {
+	if usage.BaseModel == "" {
+		return UsageMetrics{}, NewError(ErrInvalidParams, "base model is required", nil)
+	}
+	if usage.TrainedTokens < 0 {
+		return UsageMetrics{}, NewError(ErrInvalidParams, fmt.Sprintf("trained tokens must not be negative, got %d", usage.TrainedTokens), nil)
+	}
+
+	provider, exists := t.providerForModel(usage.BaseModel)
+	if !exists {
+		return UsageMetrics{}, NewError(ErrProviderNotFound, fmt.Sprintf("no provider found for model: %s", usage.BaseModel), nil)
+	}
+	pricing, exists := t.config.GetFineTunedPricing(provider.Name(), usage.BaseModel)
+	if !exists || pricing.TrainingPricePerToken == 0 {
+		return UsageMetrics{}, NewError(ErrPricingNotFound, fmt.Sprintf("training pricing not found for model: %s", usage.BaseModel), nil)
+	}
+
+	cost := float64(usage.TrainedTokens) * pricing.TrainingPricePerToken
+	price, err := t.config.convertPrice(Price{TrainingCost: cost, TotalCost: cost, Currency: pricing.Currency})
+	if err != nil {
+		return UsageMetrics{}, err
+	}
+
+	metrics := UsageMetrics{
+		TokenCount: TokenCount{TrainingTokens: usage.TrainedTokens},
+		Price:      price,
+		Timestamp:  t.clock().Now(),
+		Model:      usage.BaseModel,
+		Provider:   provider.Name(),
+		Tags:       copyTags(usage.Tags),
+		UserID:     usage.UserID,
+		ProjectID:  usage.ProjectID,
+	}
+	if metrics.Tags == nil {
+		metrics.Tags = make(map[string]string)
+	}
+	setTag(metrics.Tags, TagFineTuningJob, usage.JobID)
+	setTag(metrics.Tags, TagFineTunedModel, usage.FineTunedModel)
+	metrics.ApplyTraceContext(ctx)
+
+	if err := t.recordUsage(metrics); err != nil {
+		return metrics, err
+	}
+	return metrics, nil
+}
@@ -0,0 +1,96 @@
+package tokentracker
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestParseFineTunedModel(t *testing.T) {
+	tests := []struct {
+		model string
+		want  FineTunedModel
+		ok    bool
+	}{
+		{"ft:gpt-4o-mini-2024-07-18:acme:support:9abcDEF", FineTunedModel{BaseModel: "gpt-4o-mini-2024-07-18", Organization: "acme", Suffix: "support", ID: "9abcDEF"}, true},
+		{"ft:gpt-3.5-turbo-0613:acme::7p4lURel", FineTunedModel{BaseModel: "gpt-3.5-turbo-0613", Organization: "acme", ID: "7p4lURel"}, true},
+		{"ft:open-mistral-7b:587a6b29:20240514:7e773925", FineTunedModel{BaseModel: "open-mistral-7b", Organization: "587a6b29", Suffix: "20240514", ID: "7e773925"}, true},
+		{"curie:ft-acme-2023-03-01-12-00-00", FineTunedModel{BaseModel: "curie", Organization: "acme", ID: "2023-03-01-12-00-00"}, true},
+		{"davinci:ft-acme:support-2023-03-01-12-00-00", FineTunedModel{BaseModel: "davinci", Organization: "acme", Suffix: "support", ID: "2023-03-01-12-00-00"}, true},
+		{"ft:gpt-4o-mini", FineTunedModel{}, false},
+		{"gpt-4o-mini", FineTunedModel{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseFineTunedModel(tt.model)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("ParseFineTunedModel(%q) = %+v, %v, want %+v, %v", tt.model, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestConfig_FineTunedPricing(t *testing.T) {
+	config := NewConfig()
+	config.SetModelPricing("openai", "gpt-4o-mini", ModelPricing{InputPricePerToken: 0.00000015, OutputPricePerToken: 0.0000006, Currency: "USD"})
+	model := "ft:gpt-4o-mini-2024-07-18:acme::abc123"
+
+	if !config.ModelCatalog().Supports("openai", model) {
+		t.Errorf("ModelCatalog().Supports(%q) = false, want the provider of the base model", model)
+	}
+
+	// Without fine-tuned pricing the base model's pricing is inherited
+	pricing, exists := config.GetModelPricing("openai", model)
+	if !exists || pricing.InputPricePerToken != 0.00000015 {
+		t.Errorf("GetModelPricing() = %+v, %v, want the base model's pricing", pricing, exists)
+	}
+
+	// Fine-tuned pricing of the base model's family applies to every fine-tune of it
+	config.SetFineTunedPricing("openai", "gpt-4o-mini", ModelPricing{InputPricePerToken: 0.0000003, OutputPricePerToken: 0.0000012, TrainingPricePerToken: 0.000003, Currency: "USD"})
+	if pricing, _ := config.GetModelPricing("openai", model); pricing.InputPricePerToken != 0.0000003 {
+		t.Errorf("GetModelPricing() = %+v, want the fine-tuned pricing", pricing)
+	}
+	if pricing, exists := config.GetFineTunedPricing("openai", "gpt-4o-mini-2024-07-18"); !exists || pricing.TrainingPricePerToken != 0.000003 {
+		t.Errorf("GetFineTunedPricing() = %+v, %v", pricing, exists)
+	}
+
+	// A model's own pricing still wins
+	config.SetModelPricing("openai", model, ModelPricing{InputPricePerToken: 0.000001, Currency: "USD"})
+	if pricing, _ := config.GetModelPricing("openai", model); pricing.InputPricePerToken != 0.000001 {
+		t.Errorf("GetModelPricing() = %+v, want the model's own pricing", pricing)
+	}
+}
+
+func TestDefaultTokenTracker_TrackTrainingUsage(t *testing.T) {
+	store := NewMemoryUsageStore()
+	tracker := newPricedTestTracker(t, WithUsageStore(store))
+	config := tracker.Config()
+	config.SetFineTunedPricing("acme", "acme-1", ModelPricing{TrainingPricePerToken: 0.000008, Currency: "USD"})
+	provider := newPricedProvider(config, "acme", "acme-1", "acme-2")
+	tracker.RegisterProvider(&provider)
+
+	metrics, err := tracker.TrackTrainingUsage(context.Background(), TrainingUsage{
+		BaseModel:      "acme-1",
+		FineTunedModel: "ft:acme-1:org::xyz",
+		JobID:          "ftjob-1",
+		TrainedTokens:  250000,
+		ProjectID:      "support-bot",
+	})
+	if err != nil {
+		t.Fatalf("TrackTrainingUsage() error = %v", err)
+	}
+	if metrics.TokenCount.TrainingTokens != 250000 || math.Abs(metrics.Price.TrainingCost-2) > 1e-9 || metrics.Price.TotalCost != metrics.Price.TrainingCost {
+		t.Errorf("TrackTrainingUsage() = %+v, want $2 of training", metrics)
+	}
+	if metrics.Tags[TagFineTuningJob] != "ftjob-1" || metrics.Tags[TagFineTunedModel] != "ft:acme-1:org::xyz" {
+		t.Errorf("Tags = %v", metrics.Tags)
+	}
+	if records, _ := store.Query(UsageFilter{ProjectID: "support-bot"}); len(records) != 1 {
+		t.Errorf("stored records = %d, want 1", len(records))
+	}
+
+	if _, err := tracker.TrackTrainingUsage(context.Background(), TrainingUsage{BaseModel: "acme-2", TrainedTokens: 10}); err == nil {
+		t.Error("TrackTrainingUsage() without training pricing should fail")
+	}
+	if _, err := tracker.TrackTrainingUsage(context.Background(), TrainingUsage{TrainedTokens: 10}); err == nil {
+		t.Error("TrackTrainingUsage() without a base model should fail")
+	}
+}
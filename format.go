@@ -0,0 +1,230 @@
+package tokentracker
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// TokenUnit controls how token counts are rendered
+type TokenUnit string
+
+// Token units
+const (
+	TokenUnitRaw       TokenUnit = "raw"  // 1234567
+	TokenUnitThousands TokenUnit = "k"    // 1234.57K
+	TokenUnitMillions  TokenUnit = "m"    // 1.23M
+	TokenUnitAuto      TokenUnit = "auto" // picks raw, K or M based on magnitude
+)
+
+// CostUnit controls how monetary amounts are rendered
+type CostUnit string
+
+// Cost units
+const (
+	CostUnitMajor  CostUnit = "major"  // 1.234567 (dollars, euros, ...)
+	CostUnitMinor  CostUnit = "minor"  // 123 (cents, pence, ... rounded to the currency's minor unit)
+	CostUnitMicros CostUnit = "micros" // 1234567 (millionths of the major unit)
+)
+
+// FormatOptions controls how numbers are rendered in reports, exports and CLI output.
+// The same options should be used across all surfaces so every consumer sees identical numbers.
+type FormatOptions struct {
+	TokenUnit TokenUnit
+	CostUnit  CostUnit
+	// SignificantDigits limits the number of significant digits for costs and scaled token counts.
+	// Zero means use Precision instead.
+	SignificantDigits int
+	// Precision is the number of decimal places for costs and scaled token counts
+	Precision          int
+	ThousandsSeparator string
+	DecimalSeparator   string
+	// ShowCurrency appends the currency code to formatted costs
+	ShowCurrency bool
+}
+
+// DefaultFormatOptions returns the default formatting options (raw tokens, major-unit costs with 6 decimals)
+func DefaultFormatOptions() FormatOptions {
+	return FormatOptions{
+		TokenUnit:          TokenUnitRaw,
+		CostUnit:           CostUnitMajor,
+		Precision:          6,
+		ThousandsSeparator: "",
+		DecimalSeparator:   ".",
+		ShowCurrency:       false,
+	}
+}
+
+// LocaleFormatOptions returns formatting options with locale-aware separators.
+// Unknown locales fall back to the en-US separators.
+func LocaleFormatOptions(locale string) FormatOptions {
+	opts := DefaultFormatOptions()
+
+	switch strings.ToLower(strings.ReplaceAll(locale, "_", "-")) {
+	case "de-de", "de", "es-es", "es", "it-it", "it", "nl-nl", "nl", "pt-br", "id-id":
+		opts.ThousandsSeparator = "."
+		opts.DecimalSeparator = ","
+	case "fr-fr", "fr", "sv-se", "sv", "nb-no", "pl-pl", "ru-ru", "cs-cz":
+		opts.ThousandsSeparator = " "
+		opts.DecimalSeparator = ","
+	case "de-ch", "fr-ch":
+		opts.ThousandsSeparator = "'"
+		opts.DecimalSeparator = "."
+	case "en-in", "hi-in":
+		// Indian digit grouping is not supported; use plain comma grouping
+		opts.ThousandsSeparator = ","
+		opts.DecimalSeparator = "."
+	default:
+		opts.ThousandsSeparator = ","
+		opts.DecimalSeparator = "."
+	}
+
+	return opts
+}
+
+// currencyMinorUnits lists the number of decimal places for the minor unit of each currency.
+// Currencies not listed use two decimal places.
+var currencyMinorUnits = map[string]int{
+	"JPY": 0,
+	"KRW": 0,
+	"VND": 0,
+	"CLP": 0,
+	"ISK": 0,
+	"BHD": 3,
+	"KWD": 3,
+	"OMR": 3,
+	"TND": 3,
+}
+
+// CurrencyMinorUnits returns the number of decimal places of the currency's minor unit
+func CurrencyMinorUnits(currency string) int {
+	if digits, ok := currencyMinorUnits[strings.ToUpper(currency)]; ok {
+		return digits
+	}
+	return 2
+}
+
+// FormatTokens renders a token count according to the options
+func (o FormatOptions) FormatTokens(tokens int) string {
+	unit := o.TokenUnit
+	if unit == TokenUnitAuto {
+		abs := tokens
+		if abs < 0 {
+			abs = -abs
+		}
+		switch {
+		case abs >= 1_000_000:
+			unit = TokenUnitMillions
+		case abs >= 1_000:
+			unit = TokenUnitThousands
+		default:
+			unit = TokenUnitRaw
+		}
+	}
+
+	switch unit {
+	case TokenUnitThousands:
+		return o.formatDecimal(float64(tokens)/1e3) + "K"
+	case TokenUnitMillions:
+		return o.formatDecimal(float64(tokens)/1e6) + "M"
+	default:
+		return o.group(strconv.Itoa(tokens), "")
+	}
+}
+
+// FormatCost renders a monetary amount (expressed in the major unit) according to the options
+func (o FormatOptions) FormatCost(amount float64, currency string) string {
+	var formatted string
+
+	switch o.CostUnit {
+	case CostUnitMinor:
+		digits := CurrencyMinorUnits(currency)
+		formatted = o.group(strconv.FormatFloat(math.Round(amount*math.Pow10(digits)), 'f', 0, 64), "")
+	case CostUnitMicros:
+		formatted = o.group(strconv.FormatFloat(math.Round(amount*1e6), 'f', 0, 64), "")
+	default:
+		formatted = o.formatDecimal(amount)
+	}
+
+	if o.ShowCurrency && currency != "" {
+		formatted += " " + currency
+		if o.CostUnit == CostUnitMicros {
+			formatted += " micros"
+		} else if o.CostUnit == CostUnitMinor {
+			formatted += " minor"
+		}
+	}
+
+	return formatted
+}
+
+// FormatPrice renders the total cost of a price according to the options
+func (o FormatOptions) FormatPrice(price Price) string {
+	return o.FormatCost(price.TotalCost, price.Currency)
+}
+
+// formatDecimal renders a floating point number honoring significant digits or precision
+func (o FormatOptions) formatDecimal(value float64) string {
+	var s string
+	if o.SignificantDigits > 0 {
+		s = strconv.FormatFloat(roundSignificant(value, o.SignificantDigits), 'f', -1, 64)
+	} else {
+		precision := o.Precision
+		if precision < 0 {
+			precision = 0
+		}
+		// Round half away from zero so all surfaces agree regardless of binary representation
+		scale := math.Pow10(precision)
+		s = strconv.FormatFloat(math.Round(value*scale)/scale, 'f', precision, 64)
+	}
+
+	intPart, fracPart := s, ""
+	if idx := strings.IndexByte(s, '.'); idx >= 0 {
+		intPart, fracPart = s[:idx], s[idx+1:]
+	}
+
+	return o.group(intPart, fracPart)
+}
+
+// group applies the thousands and decimal separators to an integer and fractional part
+func (o FormatOptions) group(intPart, fracPart string) string {
+	sign := ""
+	if strings.HasPrefix(intPart, "-") {
+		sign, intPart = "-", intPart[1:]
+	}
+
+	if o.ThousandsSeparator != "" && len(intPart) > 3 {
+		var builder strings.Builder
+		lead := len(intPart) % 3
+		if lead > 0 {
+			builder.WriteString(intPart[:lead])
+		}
+		for i := lead; i < len(intPart); i += 3 {
+			if builder.Len() > 0 {
+				builder.WriteString(o.ThousandsSeparator)
+			}
+			builder.WriteString(intPart[i : i+3])
+		}
+		intPart = builder.String()
+	}
+
+	if fracPart == "" {
+		return sign + intPart
+	}
+
+	decimal := o.DecimalSeparator
+	if decimal == "" {
+		decimal = "."
+	}
+	return sign + intPart + decimal + fracPart
+}
+
+// roundSignificant rounds value to the given number of significant digits
+func roundSignificant(value float64, digits int) float64 {
+	if value == 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+		return value
+	}
+	magnitude := math.Ceil(math.Log10(math.Abs(value)))
+	scale := math.Pow10(digits - int(magnitude))
+	return math.Round(value*scale) / scale
+}
@@ -0,0 +1,77 @@
+package tokentracker
+
+import "testing"
+
+func TestFormatOptions_FormatTokens(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   FormatOptions
+		tokens int
+		want   string
+	}{
+		{"Raw default", DefaultFormatOptions(), 1234567, "1234567"},
+		{"Raw en-US", LocaleFormatOptions("en-US"), 1234567, "1,234,567"},
+		{"Raw de-DE", LocaleFormatOptions("de-DE"), 1234567, "1.234.567"},
+		{"Thousands", FormatOptions{TokenUnit: TokenUnitThousands, Precision: 1}, 1250, "1.3K"},
+		{"Millions sig digits", FormatOptions{TokenUnit: TokenUnitMillions, SignificantDigits: 3}, 1234567, "1.23M"},
+		{"Auto small", FormatOptions{TokenUnit: TokenUnitAuto, Precision: 2}, 999, "999"},
+		{"Auto thousands", FormatOptions{TokenUnit: TokenUnitAuto, Precision: 2}, 12345, "12.35K"},
+		{"Auto millions", FormatOptions{TokenUnit: TokenUnitAuto, Precision: 2}, 2500000, "2.50M"},
+		{"Negative grouped", LocaleFormatOptions("en-US"), -1234, "-1,234"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.FormatTokens(tt.tokens); got != tt.want {
+				t.Errorf("FormatTokens(%d) = %q, want %q", tt.tokens, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatOptions_FormatCost(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     FormatOptions
+		amount   float64
+		currency string
+		want     string
+	}{
+		{"Major default", DefaultFormatOptions(), 0.0035, "USD", "0.003500"},
+		{"Major de-DE", LocaleFormatOptions("de-DE"), 1234.5, "EUR", "1.234,500000"},
+		{"Minor USD", FormatOptions{CostUnit: CostUnitMinor}, 12.345, "USD", "1235"},
+		{"Minor JPY", FormatOptions{CostUnit: CostUnitMinor}, 150.4, "JPY", "150"},
+		{"Micros", FormatOptions{CostUnit: CostUnitMicros, ThousandsSeparator: ","}, 1.5, "USD", "1,500,000"},
+		{"Significant digits", FormatOptions{CostUnit: CostUnitMajor, SignificantDigits: 2}, 0.0012345, "USD", "0.0012"},
+		{"Show currency", FormatOptions{CostUnit: CostUnitMajor, Precision: 2, ShowCurrency: true}, 3, "USD", "3.00 USD"},
+		{"Show currency micros", FormatOptions{CostUnit: CostUnitMicros, ShowCurrency: true}, 0.25, "USD", "250000 USD micros"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.FormatCost(tt.amount, tt.currency); got != tt.want {
+				t.Errorf("FormatCost(%v, %s) = %q, want %q", tt.amount, tt.currency, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_FormatOptions(t *testing.T) {
+	config := NewConfig()
+
+	if got := config.GetFormatOptions(); got != DefaultFormatOptions() {
+		t.Errorf("GetFormatOptions() = %+v, want defaults", got)
+	}
+
+	opts := LocaleFormatOptions("fr-FR")
+	config.SetFormatOptions(opts)
+	if got := config.GetFormatOptions(); got != opts {
+		t.Errorf("GetFormatOptions() = %+v, want %+v", got, opts)
+	}
+
+	// A zero-value config falls back to the defaults
+	empty := &Config{}
+	if got := empty.GetFormatOptions(); got != DefaultFormatOptions() {
+		t.Errorf("GetFormatOptions() on empty config = %+v, want defaults", got)
+	}
+}
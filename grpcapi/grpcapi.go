@@ -0,0 +1,241 @@
+// Package grpcapi serves token counting, pricing and usage tracking over gRPC, so
+// services written in other languages count tokens the same way as Go services. The
+// service is defined in tokentrackerpb/tokentracker.proto.
+package grpcapi
+
+//go:generate protoc -I tokentrackerpb --go_out=tokentrackerpb --go_opt=paths=source_relative --go-grpc_out=tokentrackerpb --go-grpc_opt=paths=source_relative tokentrackerpb/tokentracker.proto
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/TrustSight-io/tokentracker"
+	"github.com/TrustSight-io/tokentracker/grpcapi/tokentrackerpb"
+)
+
+// Server implements the TokenTracker gRPC service with a tracker
+type Server struct {
+	tokentrackerpb.UnimplementedTokenTrackerServer
+
+	tracker *tokentracker.DefaultTokenTracker
+}
+
+// NewServer creates a service backed by the tracker
+func NewServer(tracker *tokentracker.DefaultTokenTracker) *Server {
+	return &Server{tracker: tracker}
+}
+
+// Register registers a service backed by the tracker with a gRPC server
+func Register(registrar grpc.ServiceRegistrar, tracker *tokentracker.DefaultTokenTracker) {
+	tokentrackerpb.RegisterTokenTrackerServer(registrar, NewServer(tracker))
+}
+
+// CountTokens counts the tokens of a text or chat messages
+func (s *Server) CountTokens(ctx context.Context, req *tokentrackerpb.CountTokensRequest) (*tokentrackerpb.TokenCount, error) {
+	count, err := s.tracker.CountTokensCtx(ctx, countParams(req.GetModel(), req))
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return tokenCountToProto(count), nil
+}
+
+// CalculatePrice prices token usage of a model
+func (s *Server) CalculatePrice(ctx context.Context, req *tokentrackerpb.CalculatePriceRequest) (*tokentrackerpb.Price, error) {
+	price, err := s.tracker.CalculatePrice(req.GetModel(), int(req.GetInputTokens()), int(req.GetOutputTokens()))
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return priceToProto(price), nil
+}
+
+// TrackUsage records the usage of an LLM call. Reported usage is recorded as is;
+// without it the prompt is counted.
+func (s *Server) TrackUsage(ctx context.Context, req *tokentrackerpb.TrackUsageRequest) (*tokentrackerpb.UsageMetrics, error) {
+	callParams := tokentracker.CallParams{
+		Model:     req.GetModel(),
+		Params:    countParams(req.GetModel(), req.GetPrompt()),
+		Tags:      req.GetTags(),
+		UserID:    req.GetUserId(),
+		ProjectID: req.GetProjectId(),
+		Batch:     req.GetBatch(),
+	}
+	if req.GetStartTime() != nil {
+		callParams.StartTime = req.GetStartTime().AsTime()
+	}
+
+	var metrics tokentracker.UsageMetrics
+	var err error
+	if usage := req.GetUsage(); usage.GetInputTokens()+usage.GetResponseTokens() > 0 {
+		metrics, err = s.tracker.TrackReportedUsage(ctx, callParams, tokenCountFromProto(usage))
+	} else {
+		metrics, err = s.tracker.TrackUsageCtx(ctx, callParams, nil)
+	}
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return usageMetricsToProto(metrics), nil
+}
+
+// GetSummary aggregates stored usage
+func (s *Server) GetSummary(ctx context.Context, req *tokentrackerpb.GetSummaryRequest) (*tokentrackerpb.GetSummaryResponse, error) {
+	groupBy, err := tokentracker.ParseGroupBy(strings.Join(req.GetGroupBy(), ","))
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	summaries, err := s.tracker.Summary(filterFromProto(req.GetFilter()), groupBy...)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	resp := &tokentrackerpb.GetSummaryResponse{Summaries: make([]*tokentrackerpb.UsageSummary, 0, len(summaries))}
+	for _, summary := range summaries {
+		resp.Summaries = append(resp.Summaries, summaryToProto(summary))
+	}
+	return resp, nil
+}
+
+// countParams converts a count request for model; a nil request has no prompt
+func countParams(model string, req *tokentrackerpb.CountTokensRequest) tokentracker.TokenCountParams {
+	params := tokentracker.TokenCountParams{
+		Model:               model,
+		CountResponseTokens: req.GetCountResponseTokens(),
+	}
+	if text := req.GetText(); text != "" {
+		params.Text = &text
+	}
+	for _, message := range req.GetMessages() {
+		params.Messages = append(params.Messages, tokentracker.Message{
+			Role:    message.GetRole(),
+			Content: message.GetContent(),
+			Name:    message.GetName(),
+		})
+	}
+	return params
+}
+
+// filterFromProto converts a usage filter; a nil filter matches all usage
+func filterFromProto(filter *tokentrackerpb.UsageFilter) tokentracker.UsageFilter {
+	converted := tokentracker.UsageFilter{
+		Model:     filter.GetModel(),
+		Provider:  filter.GetProvider(),
+		UserID:    filter.GetUserId(),
+		ProjectID: filter.GetProjectId(),
+		Tags:      filter.GetTags(),
+	}
+	if filter.GetStart() != nil {
+		converted.Start = filter.GetStart().AsTime()
+	}
+	if filter.GetEnd() != nil {
+		converted.End = filter.GetEnd().AsTime()
+	}
+	return converted
+}
+
+// tokenCountFromProto converts a token count
+func tokenCountFromProto(count *tokentrackerpb.TokenCount) tokentracker.TokenCount {
+	return tokentracker.TokenCount{
+		InputTokens:       int(count.GetInputTokens()),
+		ResponseTokens:    int(count.GetResponseTokens()),
+		TotalTokens:       int(count.GetTotalTokens()),
+		CachedInputTokens: int(count.GetCachedInputTokens()),
+		AudioTokens:       int(count.GetAudioTokens()),
+		VideoTokens:       int(count.GetVideoTokens()),
+	}
+}
+
+// tokenCountToProto converts a token count
+func tokenCountToProto(count tokentracker.TokenCount) *tokentrackerpb.TokenCount {
+	return &tokentrackerpb.TokenCount{
+		InputTokens:       int64(count.InputTokens),
+		ResponseTokens:    int64(count.ResponseTokens),
+		TotalTokens:       int64(count.TotalTokens),
+		CachedInputTokens: int64(count.CachedInputTokens),
+		AudioTokens:       int64(count.AudioTokens),
+		VideoTokens:       int64(count.VideoTokens),
+	}
+}
+
+// priceToProto converts a price
+func priceToProto(price tokentracker.Price) *tokentrackerpb.Price {
+	return &tokentrackerpb.Price{
+		InputCost:  price.InputCost,
+		OutputCost: price.OutputCost,
+		TotalCost:  price.TotalCost,
+		Currency:   price.Currency,
+	}
+}
+
+// usageMetricsToProto converts tracked usage
+func usageMetricsToProto(metrics tokentracker.UsageMetrics) *tokentrackerpb.UsageMetrics {
+	return &tokentrackerpb.UsageMetrics{
+		TokenCount: tokenCountToProto(metrics.TokenCount),
+		Price:      priceToProto(metrics.Price),
+		Duration:   durationpb.New(metrics.Duration),
+		Timestamp:  timestamppb.New(metrics.Timestamp),
+		Model:      metrics.Model,
+		Provider:   metrics.Provider,
+		TraceId:    metrics.TraceID,
+		SpanId:     metrics.SpanID,
+		Tags:       metrics.Tags,
+		UserId:     metrics.UserID,
+		ProjectId:  metrics.ProjectID,
+		CostTier:   metrics.CostTier,
+	}
+}
+
+// summaryToProto converts a usage summary
+func summaryToProto(summary tokentracker.UsageSummary) *tokentrackerpb.UsageSummary {
+	converted := &tokentrackerpb.UsageSummary{
+		Provider:       summary.Provider,
+		Model:          summary.Model,
+		UserId:         summary.UserID,
+		ProjectId:      summary.ProjectID,
+		Tags:           summary.Tags,
+		Calls:          int64(summary.Calls),
+		InputTokens:    int64(summary.InputTokens),
+		ResponseTokens: int64(summary.ResponseTokens),
+		TotalTokens:    int64(summary.TotalTokens),
+		TotalCost:      summary.TotalCost,
+		Currency:       summary.Currency,
+		TotalDuration:  durationpb.New(summary.TotalDuration),
+		AvgDuration:    durationpb.New(summary.AvgDuration),
+	}
+	if !summary.Bucket.IsZero() {
+		converted.Bucket = timestamppb.New(summary.Bucket)
+	}
+	return converted
+}
+
+// statusError converts a tracker error into a gRPC status error
+func statusError(err error) error {
+	if errors.Is(err, context.Canceled) {
+		return status.Error(codes.Canceled, err.Error())
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+
+	code := codes.Internal
+	var trackerErr *tokentracker.TokenTrackerError
+	if errors.As(err, &trackerErr) {
+		switch trackerErr.Type {
+		case tokentracker.ErrInvalidParams, tokentracker.ErrInvalidModel, tokentracker.ErrProviderNotFound:
+			code = codes.InvalidArgument
+		case tokentracker.ErrPricingNotFound:
+			code = codes.NotFound
+		case tokentracker.ErrStorageFailed:
+			code = codes.FailedPrecondition
+		case tokentracker.ErrBudgetExceeded, tokentracker.ErrQuotaExceeded:
+			code = codes.ResourceExhausted
+		}
+	}
+	return status.Error(code, err.Error())
+}
@@ -0,0 +1,148 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/TrustSight-io/tokentracker"
+	"github.com/TrustSight-io/tokentracker/grpcapi/tokentrackerpb"
+	"github.com/TrustSight-io/tokentracker/providers"
+)
+
+func newTestClient(t *testing.T, store tokentracker.UsageStore) tokentrackerpb.TokenTrackerClient {
+	t.Helper()
+
+	config := tokentracker.NewConfig()
+	tracker := tokentracker.NewTokenTracker(config)
+	tracker.RegisterProvider(providers.NewMistralProvider(config))
+	if store != nil {
+		tracker.SetUsageStore(store)
+	}
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	Register(server, tracker)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return tokentrackerpb.NewTokenTrackerClient(conn)
+}
+
+func wantCode(t *testing.T, err error, code codes.Code) {
+	t.Helper()
+	if status.Code(err) != code {
+		t.Errorf("error = %v, want code %s", err, code)
+	}
+}
+
+func TestServer_CountTokens(t *testing.T) {
+	client := newTestClient(t, nil)
+	ctx := context.Background()
+
+	text, err := client.CountTokens(ctx, &tokentrackerpb.CountTokensRequest{Model: "mistral-small", Text: "Hello, world!"})
+	if err != nil {
+		t.Fatalf("CountTokens() error = %v", err)
+	}
+	if text.InputTokens == 0 || text.TotalTokens != text.InputTokens {
+		t.Errorf("CountTokens() = %+v", text)
+	}
+
+	chat, err := client.CountTokens(ctx, &tokentrackerpb.CountTokensRequest{
+		Model:               "mistral-small",
+		Messages:            []*tokentrackerpb.Message{{Role: "user", Content: "Hello, world!"}},
+		CountResponseTokens: true,
+	})
+	if err != nil {
+		t.Fatalf("CountTokens() of messages error = %v", err)
+	}
+	if chat.InputTokens <= text.InputTokens || chat.ResponseTokens == 0 {
+		t.Errorf("CountTokens() of messages = %+v", chat)
+	}
+
+	_, err = client.CountTokens(ctx, &tokentrackerpb.CountTokensRequest{Model: "unknown-model", Text: "hi"})
+	wantCode(t, err, codes.InvalidArgument)
+}
+
+func TestServer_CalculatePrice(t *testing.T) {
+	client := newTestClient(t, nil)
+
+	price, err := client.CalculatePrice(context.Background(), &tokentrackerpb.CalculatePriceRequest{Model: "mistral-large", InputTokens: 1000, OutputTokens: 500})
+	if err != nil {
+		t.Fatalf("CalculatePrice() error = %v", err)
+	}
+	if want := 1000*0.000008 + 500*0.000024; price.TotalCost < want-1e-12 || price.TotalCost > want+1e-12 || price.Currency != "USD" {
+		t.Errorf("CalculatePrice() = %+v, want %v USD", price, want)
+	}
+}
+
+func TestServer_TrackUsageAndGetSummary(t *testing.T) {
+	client := newTestClient(t, tokentracker.NewMemoryUsageStore())
+	ctx := context.Background()
+
+	reported, err := client.TrackUsage(ctx, &tokentrackerpb.TrackUsageRequest{
+		Model:  "mistral-large",
+		Usage:  &tokentrackerpb.TokenCount{InputTokens: 1000, ResponseTokens: 500},
+		Tags:   map[string]string{"feature": "search"},
+		UserId: "user-1",
+	})
+	if err != nil {
+		t.Fatalf("TrackUsage() error = %v", err)
+	}
+	if reported.TokenCount.TotalTokens != 1500 || reported.Provider != "mistral" || reported.Tags["feature"] != "search" {
+		t.Errorf("TrackUsage() = %+v, want the reported usage", reported)
+	}
+
+	counted, err := client.TrackUsage(ctx, &tokentrackerpb.TrackUsageRequest{
+		Model:  "mistral-small",
+		Prompt: &tokentrackerpb.CountTokensRequest{Text: "Summarize this document."},
+	})
+	if err != nil {
+		t.Fatalf("TrackUsage() of a prompt error = %v", err)
+	}
+	if counted.TokenCount.InputTokens == 0 || counted.Timestamp == nil {
+		t.Errorf("TrackUsage() of a prompt = %+v, want the prompt counted", counted)
+	}
+
+	resp, err := client.GetSummary(ctx, &tokentrackerpb.GetSummaryRequest{GroupBy: []string{"model"}})
+	if err != nil {
+		t.Fatalf("GetSummary() error = %v", err)
+	}
+	if len(resp.Summaries) != 2 {
+		t.Errorf("GetSummary() = %+v, want one summary per model", resp.Summaries)
+	}
+
+	resp, err = client.GetSummary(ctx, &tokentrackerpb.GetSummaryRequest{
+		Filter: &tokentrackerpb.UsageFilter{UserId: "user-1"},
+	})
+	if err != nil {
+		t.Fatalf("GetSummary() with a filter error = %v", err)
+	}
+	if len(resp.Summaries) != 1 || resp.Summaries[0].Calls != 1 || resp.Summaries[0].TotalTokens != 1500 {
+		t.Errorf("GetSummary() for user-1 = %+v", resp.Summaries)
+	}
+
+	_, err = client.GetSummary(ctx, &tokentrackerpb.GetSummaryRequest{GroupBy: []string{"week"}})
+	wantCode(t, err, codes.InvalidArgument)
+}
+
+func TestServer_GetSummaryWithoutStore(t *testing.T) {
+	client := newTestClient(t, nil)
+
+	_, err := client.GetSummary(context.Background(), &tokentrackerpb.GetSummaryRequest{})
+	wantCode(t, err, codes.FailedPrecondition)
+}
@@ -0,0 +1,247 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: tokentracker.proto
+
+package tokentrackerpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	TokenTracker_CountTokens_FullMethodName    = "/tokentracker.v1.TokenTracker/CountTokens"
+	TokenTracker_CalculatePrice_FullMethodName = "/tokentracker.v1.TokenTracker/CalculatePrice"
+	TokenTracker_TrackUsage_FullMethodName     = "/tokentracker.v1.TokenTracker/TrackUsage"
+	TokenTracker_GetSummary_FullMethodName     = "/tokentracker.v1.TokenTracker/GetSummary"
+)
+
+// TokenTrackerClient is the client API for TokenTracker service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// TokenTracker counts tokens, prices calls and records their usage with a tracker
+type TokenTrackerClient interface {
+	// CountTokens counts the tokens of a text or chat messages
+	CountTokens(ctx context.Context, in *CountTokensRequest, opts ...grpc.CallOption) (*TokenCount, error)
+	// CalculatePrice prices token usage of a model
+	CalculatePrice(ctx context.Context, in *CalculatePriceRequest, opts ...grpc.CallOption) (*Price, error)
+	// TrackUsage records the usage of an LLM call
+	TrackUsage(ctx context.Context, in *TrackUsageRequest, opts ...grpc.CallOption) (*UsageMetrics, error)
+	// GetSummary aggregates stored usage
+	GetSummary(ctx context.Context, in *GetSummaryRequest, opts ...grpc.CallOption) (*GetSummaryResponse, error)
+}
+
+type tokenTrackerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTokenTrackerClient(cc grpc.ClientConnInterface) TokenTrackerClient {
+	return &tokenTrackerClient{cc}
+}
+
+func (c *tokenTrackerClient) CountTokens(ctx context.Context, in *CountTokensRequest, opts ...grpc.CallOption) (*TokenCount, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TokenCount)
+	err := c.cc.Invoke(ctx, TokenTracker_CountTokens_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tokenTrackerClient) CalculatePrice(ctx context.Context, in *CalculatePriceRequest, opts ...grpc.CallOption) (*Price, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Price)
+	err := c.cc.Invoke(ctx, TokenTracker_CalculatePrice_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tokenTrackerClient) TrackUsage(ctx context.Context, in *TrackUsageRequest, opts ...grpc.CallOption) (*UsageMetrics, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UsageMetrics)
+	err := c.cc.Invoke(ctx, TokenTracker_TrackUsage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tokenTrackerClient) GetSummary(ctx context.Context, in *GetSummaryRequest, opts ...grpc.CallOption) (*GetSummaryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetSummaryResponse)
+	err := c.cc.Invoke(ctx, TokenTracker_GetSummary_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// TokenTrackerServer is the server API for TokenTracker service.
+// All implementations must embed UnimplementedTokenTrackerServer
+// for forward compatibility.
+//
+// TokenTracker counts tokens, prices calls and records their usage with a tracker
+type TokenTrackerServer interface {
+	// CountTokens counts the tokens of a text or chat messages
+	CountTokens(context.Context, *CountTokensRequest) (*TokenCount, error)
+	// CalculatePrice prices token usage of a model
+	CalculatePrice(context.Context, *CalculatePriceRequest) (*Price, error)
+	// TrackUsage records the usage of an LLM call
+	TrackUsage(context.Context, *TrackUsageRequest) (*UsageMetrics, error)
+	// GetSummary aggregates stored usage
+	GetSummary(context.Context, *GetSummaryRequest) (*GetSummaryResponse, error)
+	mustEmbedUnimplementedTokenTrackerServer()
+}
+
+// UnimplementedTokenTrackerServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedTokenTrackerServer struct{}
+
+func (UnimplementedTokenTrackerServer) CountTokens(context.Context, *CountTokensRequest) (*TokenCount, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CountTokens not implemented")
+}
+func (UnimplementedTokenTrackerServer) CalculatePrice(context.Context, *CalculatePriceRequest) (*Price, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CalculatePrice not implemented")
+}
+func (UnimplementedTokenTrackerServer) TrackUsage(context.Context, *TrackUsageRequest) (*UsageMetrics, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TrackUsage not implemented")
+}
+func (UnimplementedTokenTrackerServer) GetSummary(context.Context, *GetSummaryRequest) (*GetSummaryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSummary not implemented")
+}
+func (UnimplementedTokenTrackerServer) mustEmbedUnimplementedTokenTrackerServer() {}
+func (UnimplementedTokenTrackerServer) testEmbeddedByValue()                      {}
+
+// UnsafeTokenTrackerServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TokenTrackerServer will
+// result in compilation errors.
+type UnsafeTokenTrackerServer interface {
+	mustEmbedUnimplementedTokenTrackerServer()
+}
+
+func RegisterTokenTrackerServer(s grpc.ServiceRegistrar, srv TokenTrackerServer) {
+	// If the following call pancis, it indicates UnimplementedTokenTrackerServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&TokenTracker_ServiceDesc, srv)
+}
+
+func _TokenTracker_CountTokens_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CountTokensRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TokenTrackerServer).CountTokens(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TokenTracker_CountTokens_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TokenTrackerServer).CountTokens(ctx, req.(*CountTokensRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TokenTracker_CalculatePrice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CalculatePriceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TokenTrackerServer).CalculatePrice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TokenTracker_CalculatePrice_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TokenTrackerServer).CalculatePrice(ctx, req.(*CalculatePriceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TokenTracker_TrackUsage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TrackUsageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TokenTrackerServer).TrackUsage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TokenTracker_TrackUsage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TokenTrackerServer).TrackUsage(ctx, req.(*TrackUsageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TokenTracker_GetSummary_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSummaryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TokenTrackerServer).GetSummary(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TokenTracker_GetSummary_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TokenTrackerServer).GetSummary(ctx, req.(*GetSummaryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// TokenTracker_ServiceDesc is the grpc.ServiceDesc for TokenTracker service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var TokenTracker_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "tokentracker.v1.TokenTracker",
+	HandlerType: (*TokenTrackerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CountTokens",
+			Handler:    _TokenTracker_CountTokens_Handler,
+		},
+		{
+			MethodName: "CalculatePrice",
+			Handler:    _TokenTracker_CalculatePrice_Handler,
+		},
+		{
+			MethodName: "TrackUsage",
+			Handler:    _TokenTracker_TrackUsage_Handler,
+		},
+		{
+			MethodName: "GetSummary",
+			Handler:    _TokenTracker_GetSummary_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "tokentracker.proto",
+}
@@ -0,0 +1,43 @@
+package tokentracker
+
+import "testing"
+
+// testPricing is the pricing of acme-1 in trackers created by newPricedTestTracker
+var testPricing = ModelPricing{InputPricePerToken: 0.00001, OutputPricePerToken: 0.00002, Currency: "USD"}
+
+// usagePricedProvider prices calls from the configuration, including cached tokens
+type usagePricedProvider struct {
+	MockSimpleProvider
+	config *Config
+}
+
+func (p *usagePricedProvider) CalculatePrice(model string, inputTokens, outputTokens int) (Price, error) {
+	return p.CalculateUsagePrice(model, BillableUsage{InputTokens: inputTokens, OutputTokens: outputTokens})
+}
+
+func (p *usagePricedProvider) CalculateUsagePrice(model string, usage BillableUsage) (Price, error) {
+	pricing, _ := p.config.GetModelPricing(p.name, model)
+	return pricing.Cost(usage), nil
+}
+
+// newPricedProvider creates a provider named name supporting models, priced from config
+func newPricedProvider(config *Config, name string, models ...string) usagePricedProvider {
+	supported := make(map[string]bool, len(models))
+	for _, model := range models {
+		supported[model] = true
+	}
+	return usagePricedProvider{MockSimpleProvider: MockSimpleProvider{name: name, supportedModels: supported}, config: config}
+}
+
+// newPricedTestTracker creates a tracker whose acme provider prices acme-1 with testPricing.
+// Tests that count or extract usage differently register their own acme provider over it,
+// wrapping newPricedProvider(tracker.Config(), "acme", ...).
+func newPricedTestTracker(t testing.TB, opts ...TrackerOption) *DefaultTokenTracker {
+	t.Helper()
+	config := NewConfig()
+	config.SetModelPricing("acme", "acme-1", testPricing)
+	tracker := NewTokenTracker(config, opts...)
+	provider := newPricedProvider(config, "acme", "acme-1")
+	tracker.RegisterProvider(&provider)
+	return tracker
+}
@@ -0,0 +1,273 @@
+// Package httpapi serves a tracker's usage, pricing and token counts over HTTP, so a
+// sidecar deployment can be queried by other services. The Handler can be mounted on
+// an existing mux, e.g. under a prefix with http.StripPrefix.
+//
+// Endpoints:
+//
+//	GET  /usage          stored usage as JSON, or CSV/Parquet with format=csv|parquet
+//	GET  /usage/summary  aggregated usage, grouped by the group_by dimensions
+//	GET  /pricing        configured pricing, optionally for one provider or model
+//	POST /count          token count of a JSON {"model", "text" | "messages"} request
+//
+// The usage endpoints filter with the start, end, model, provider, user_id, project_id,
+// api_key_id, tag (key=value, repeatable) and limit query parameters.
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker"
+)
+
+// MaxRequestBytes is the largest request body accepted by POST /count
+const MaxRequestBytes = 10 << 20
+
+// Handler serves the reporting endpoints of a tracker
+type Handler struct {
+	tracker *tokentracker.DefaultTokenTracker
+	mux     *http.ServeMux
+}
+
+// NewHandler creates a handler for the tracker
+func NewHandler(tracker *tokentracker.DefaultTokenTracker) *Handler {
+	h := &Handler{
+		tracker: tracker,
+		mux:     http.NewServeMux(),
+	}
+	h.mux.HandleFunc("GET /usage", h.usage)
+	h.mux.HandleFunc("GET /usage/summary", h.summary)
+	h.mux.HandleFunc("GET /pricing", h.pricing)
+	h.mux.HandleFunc("POST /count", h.count)
+	return h
+}
+
+// NewServer creates an HTTP server for the handler listening on addr
+func NewServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      time.Minute,
+	}
+}
+
+// ServeHTTP dispatches the request to its endpoint
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// CountRequest is the body of POST /count
+type CountRequest struct {
+	Model               string                 `json:"model"`
+	Text                *string                `json:"text,omitempty"`
+	Messages            []tokentracker.Message `json:"messages,omitempty"`
+	Tools               []tokentracker.Tool    `json:"tools,omitempty"`
+	CountResponseTokens bool                   `json:"count_response_tokens,omitempty"`
+}
+
+// errorResponse is the body of failed requests
+type errorResponse struct {
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// usage serves GET /usage
+func (h *Handler) usage(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseFilter(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != string(tokentracker.ExportCSV) && format != string(tokentracker.ExportParquet) {
+		writeError(w, tokentracker.NewError(tokentracker.ErrInvalidParams, fmt.Sprintf("unknown format: %s", format), nil))
+		return
+	}
+
+	records, err := h.tracker.GetUsage(filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	switch format {
+	case "", "json":
+		if records == nil {
+			records = []tokentracker.UsageMetrics{}
+		}
+		writeJSON(w, http.StatusOK, records)
+	default:
+		contentType := "text/csv; charset=utf-8"
+		if format == string(tokentracker.ExportParquet) {
+			contentType = "application/vnd.apache.parquet"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=usage.%s", format))
+		_ = tokentracker.WriteUsage(w, tokentracker.ExportFormat(format), records)
+	}
+}
+
+// summary serves GET /usage/summary
+func (h *Handler) summary(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseFilter(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	groupBy, err := tokentracker.ParseGroupBy(r.URL.Query().Get("group_by"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	summaries, err := h.tracker.Summary(filter, groupBy...)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if summaries == nil {
+		summaries = []tokentracker.UsageSummary{}
+	}
+	writeJSON(w, http.StatusOK, summaries)
+}
+
+// pricing serves GET /pricing
+func (h *Handler) pricing(w http.ResponseWriter, r *http.Request) {
+	provider, model := r.URL.Query().Get("provider"), r.URL.Query().Get("model")
+
+	pricing := make(map[string]map[string]tokentracker.ModelPricing)
+	for name, providerConfig := range h.tracker.Config().PricingSnapshot(time.Time{}).Providers {
+		if provider != "" && name != provider {
+			continue
+		}
+		for modelName, modelPricing := range providerConfig.Models {
+			if model != "" && modelName != model {
+				continue
+			}
+			if pricing[name] == nil {
+				pricing[name] = make(map[string]tokentracker.ModelPricing)
+			}
+			pricing[name][modelName] = modelPricing
+		}
+	}
+
+	if len(pricing) == 0 && (provider != "" || model != "") {
+		writeError(w, tokentracker.NewError(tokentracker.ErrPricingNotFound, "no pricing matches the request", nil))
+		return
+	}
+	writeJSON(w, http.StatusOK, pricing)
+}
+
+// count serves POST /count
+func (h *Handler) count(w http.ResponseWriter, r *http.Request) {
+	var req CountRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxRequestBytes))
+	if err := decoder.Decode(&req); err != nil {
+		writeError(w, tokentracker.NewError(tokentracker.ErrInvalidParams, "invalid count request", err))
+		return
+	}
+
+	count, err := h.tracker.CountTokensCtx(r.Context(), tokentracker.TokenCountParams{
+		Model:               req.Model,
+		Text:                req.Text,
+		Messages:            req.Messages,
+		Tools:               req.Tools,
+		CountResponseTokens: req.CountResponseTokens,
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, count)
+}
+
+// parseFilter reads a usage filter from the query parameters
+func parseFilter(r *http.Request) (tokentracker.UsageFilter, error) {
+	query := r.URL.Query()
+	filter := tokentracker.UsageFilter{
+		Model:     query.Get("model"),
+		Provider:  query.Get("provider"),
+		UserID:    query.Get("user_id"),
+		ProjectID: query.Get("project_id"),
+		APIKeyID:  query.Get("api_key_id"),
+	}
+
+	var err error
+	if filter.Start, err = parseTime(query.Get("start")); err != nil {
+		return filter, err
+	}
+	if filter.End, err = parseTime(query.Get("end")); err != nil {
+		return filter, err
+	}
+
+	for _, tag := range query["tag"] {
+		key, value, ok := strings.Cut(tag, "=")
+		if !ok || key == "" {
+			return filter, tokentracker.NewError(tokentracker.ErrInvalidParams, fmt.Sprintf("invalid tag %q, want key=value", tag), nil)
+		}
+		if filter.Tags == nil {
+			filter.Tags = make(map[string]string)
+		}
+		filter.Tags[key] = value
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			return filter, tokentracker.NewError(tokentracker.ErrInvalidParams, fmt.Sprintf("invalid limit %q", limit), nil)
+		}
+		filter.Limit = n
+	}
+	return filter, nil
+}
+
+// parseTime parses an RFC 3339 timestamp or a date; empty values are zero
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, tokentracker.NewError(tokentracker.ErrInvalidParams, fmt.Sprintf("invalid time %q, want RFC 3339 or YYYY-MM-DD", value), nil)
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+// writeError writes an error response, with a status derived from the error type
+func writeError(w http.ResponseWriter, err error) {
+	var response errorResponse
+	response.Error.Type, response.Error.Message = "internal", err.Error()
+
+	status := http.StatusInternalServerError
+	var trackerErr *tokentracker.TokenTrackerError
+	if errors.As(err, &trackerErr) {
+		response.Error.Type, response.Error.Message = trackerErr.Type, trackerErr.Message
+		switch trackerErr.Type {
+		case tokentracker.ErrInvalidParams, tokentracker.ErrInvalidModel, tokentracker.ErrProviderNotFound:
+			status = http.StatusBadRequest
+		case tokentracker.ErrPricingNotFound:
+			status = http.StatusNotFound
+		case tokentracker.ErrStorageFailed:
+			status = http.StatusServiceUnavailable
+		}
+	}
+	writeJSON(w, status, response)
+}
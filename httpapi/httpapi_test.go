@@ -0,0 +1,185 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker"
+	"github.com/TrustSight-io/tokentracker/providers"
+)
+
+func newTestServer(t *testing.T, store tokentracker.UsageStore) *httptest.Server {
+	t.Helper()
+
+	config := tokentracker.NewConfig()
+	tracker := tokentracker.NewTokenTracker(config)
+	tracker.RegisterProvider(providers.NewMistralProvider(config))
+	if store != nil {
+		tracker.SetUsageStore(store)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/tracker/", http.StripPrefix("/tracker", NewHandler(tracker)))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func getJSON(t *testing.T, url string, want int, value interface{}) {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s error = %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != want {
+		t.Fatalf("GET %s status = %d, want %d", url, resp.StatusCode, want)
+	}
+	if value != nil {
+		if err := json.NewDecoder(resp.Body).Decode(value); err != nil {
+			t.Fatalf("GET %s decode error = %v", url, err)
+		}
+	}
+}
+
+func sampleStore() tokentracker.UsageStore {
+	store := tokentracker.NewMemoryUsageStore()
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i, model := range []string{"mistral-small", "mistral-large", "mistral-small"} {
+		_ = store.Record(tokentracker.UsageMetrics{
+			TokenCount: tokentracker.TokenCount{InputTokens: 10, ResponseTokens: 5, TotalTokens: 15},
+			Price:      tokentracker.Price{TotalCost: 0.5, Currency: "USD"},
+			Timestamp:  base.AddDate(0, 0, i),
+			Model:      model,
+			Provider:   "mistral",
+			Tags:       map[string]string{"feature": "search"},
+		})
+	}
+	return store
+}
+
+func TestHandler_Usage(t *testing.T) {
+	server := newTestServer(t, sampleStore())
+
+	var records []tokentracker.UsageMetrics
+	getJSON(t, server.URL+"/tracker/usage?model=mistral-small&tag=feature=search", http.StatusOK, &records)
+	if len(records) != 2 {
+		t.Errorf("GET /usage returned %d records, want 2", len(records))
+	}
+
+	var ranged []tokentracker.UsageMetrics
+	getJSON(t, server.URL+"/tracker/usage?start=2024-03-02&end=2024-03-03", http.StatusOK, &ranged)
+	if len(ranged) != 1 || ranged[0].Model != "mistral-large" {
+		t.Errorf("GET /usage in a range = %+v", ranged)
+	}
+
+	resp, err := http.Get(server.URL + "/tracker/usage?format=csv")
+	if err != nil {
+		t.Fatalf("GET /usage?format=csv error = %v", err)
+	}
+	imported, err := tokentracker.ReadUsage(tokentracker.UsageImportCSV, resp.Body)
+	resp.Body.Close()
+	if err != nil || len(imported) != 3 || resp.Header.Get("Content-Type") != "text/csv; charset=utf-8" {
+		t.Errorf("CSV export = %d records, %v, %s", len(imported), err, resp.Header.Get("Content-Type"))
+	}
+
+	getJSON(t, server.URL+"/tracker/usage?start=yesterday", http.StatusBadRequest, nil)
+	getJSON(t, server.URL+"/tracker/usage?format=xlsx", http.StatusBadRequest, nil)
+	getJSON(t, server.URL+"/tracker/usage?limit=-1", http.StatusBadRequest, nil)
+}
+
+func TestHandler_UsageWithoutStore(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	var response errorResponse
+	getJSON(t, server.URL+"/tracker/usage", http.StatusServiceUnavailable, &response)
+	if response.Error.Type != tokentracker.ErrStorageFailed {
+		t.Errorf("error = %+v", response.Error)
+	}
+}
+
+func TestHandler_Summary(t *testing.T) {
+	server := newTestServer(t, sampleStore())
+
+	var summaries []tokentracker.UsageSummary
+	getJSON(t, server.URL+"/tracker/usage/summary?group_by=model,tag:feature", http.StatusOK, &summaries)
+	if len(summaries) != 2 {
+		t.Fatalf("GET /usage/summary = %+v, want one group per model", summaries)
+	}
+	for _, summary := range summaries {
+		if summary.Tags["feature"] != "search" {
+			t.Errorf("summary tags = %v", summary.Tags)
+		}
+		if summary.Model == "mistral-small" && (summary.Calls != 2 || summary.TotalCost != 1) {
+			t.Errorf("mistral-small summary = %+v", summary)
+		}
+	}
+
+	var total []tokentracker.UsageSummary
+	getJSON(t, server.URL+"/tracker/usage/summary", http.StatusOK, &total)
+	if len(total) != 1 || total[0].Calls != 3 {
+		t.Errorf("GET /usage/summary without group_by = %+v", total)
+	}
+
+	getJSON(t, server.URL+"/tracker/usage/summary?group_by=week", http.StatusBadRequest, nil)
+}
+
+func TestHandler_Pricing(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	var pricing map[string]map[string]tokentracker.ModelPricing
+	getJSON(t, server.URL+"/tracker/pricing", http.StatusOK, &pricing)
+	if len(pricing) < 2 {
+		t.Errorf("GET /pricing = %v, want every configured provider", pricing)
+	}
+
+	var gpt4 map[string]map[string]tokentracker.ModelPricing
+	getJSON(t, server.URL+"/tracker/pricing?provider=openai&model=gpt-4", http.StatusOK, &gpt4)
+	if len(gpt4) != 1 || len(gpt4["openai"]) != 1 || gpt4["openai"]["gpt-4"].InputPricePerToken == 0 {
+		t.Errorf("GET /pricing for gpt-4 = %v", gpt4)
+	}
+
+	getJSON(t, server.URL+"/tracker/pricing?model=unknown", http.StatusNotFound, nil)
+}
+
+func TestHandler_Count(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	post := func(body string) *http.Response {
+		resp, err := http.Post(server.URL+"/tracker/count", "application/json", bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatalf("POST /count error = %v", err)
+		}
+		return resp
+	}
+
+	resp := post(`{"model":"mistral-small","messages":[{"role":"user","content":"Hello, world!"}],"count_response_tokens":true}`)
+	var count tokentracker.TokenCount
+	err := json.NewDecoder(resp.Body).Decode(&count)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusOK || count.InputTokens == 0 || count.ResponseTokens == 0 {
+		t.Errorf("POST /count = %d, %+v, %v", resp.StatusCode, count, err)
+	}
+
+	for _, body := range []string{`{"model":"unknown-model","text":"hi"}`, `{"model":`, `{"model":"mistral-small"}`} {
+		resp := post(body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("POST /count %s status = %d, want 400", body, resp.StatusCode)
+		}
+	}
+
+	resp, err = http.Get(server.URL + "/tracker/count")
+	if err != nil {
+		t.Fatalf("GET /count error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET /count status = %d, want 405", resp.StatusCode)
+	}
+}
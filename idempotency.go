@@ -0,0 +1,173 @@
+package tokentracker
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultIdempotencyWindow is how long a tracked call is remembered for deduplication
+const DefaultIdempotencyWindow = 24 * time.Hour
+
+// WithIdempotency makes the tracker track every call only once: a call tracked again
+// within window, e.g. because a retry delivered the same completion twice, returns
+// the metrics of the first tracking instead of recording the usage again (window 0
+// uses the default). Calls are identified by CallParams.IdempotencyKey, or else by the
+// completion ID or request ID of the response.
+func WithIdempotency(window time.Duration) TrackerOption {
+	return func(t *DefaultTokenTracker) {
+		if window <= 0 {
+			window = DefaultIdempotencyWindow
+		}
+		t.idempotency = newIdempotencyCache(window)
+	}
+}
+
+// idempotencyCache remembers the metrics of tracked calls by their idempotency key
+type idempotencyCache struct {
+	window    time.Duration
+	entries   map[string]*idempotencyEntry
+	lastSweep time.Time
+	mu        sync.Mutex
+}
+
+// idempotencyEntry is a call being tracked or tracked within the window
+type idempotencyEntry struct {
+	metrics UsageMetrics
+	expires time.Time
+	done    chan struct{} // closed once the call has been tracked
+	ok      bool          // the call was recorded
+}
+
+// newIdempotencyCache creates a cache remembering calls for window
+func newIdempotencyCache(window time.Duration) *idempotencyCache {
+	return &idempotencyCache{
+		window:  window,
+		entries: make(map[string]*idempotencyEntry),
+	}
+}
+
+// begin returns the metrics of the call tracked with key, waiting for a call still
+// being tracked. When there is none, the caller tracks the call and passes the result
+// to the returned finish function.
+func (c *idempotencyCache) begin(key string, clock Clock) (UsageMetrics, bool, func(UsageMetrics, bool)) {
+	for {
+		c.mu.Lock()
+		now := clock.Now()
+		c.sweep(now)
+
+		entry, exists := c.entries[key]
+		if exists && !entry.expires.IsZero() && !now.Before(entry.expires) {
+			exists = false
+		}
+		if !exists {
+			entry = &idempotencyEntry{done: make(chan struct{})}
+			c.entries[key] = entry
+			c.mu.Unlock()
+			return UsageMetrics{}, false, func(metrics UsageMetrics, ok bool) {
+				c.finish(key, entry, metrics, ok, clock)
+			}
+		}
+		c.mu.Unlock()
+
+		<-entry.done
+		if entry.ok {
+			return entry.metrics, true, nil
+		}
+		// The first attempt was not recorded; track this one instead
+	}
+}
+
+// finish remembers the metrics of a recorded call, or forgets a call that failed
+func (c *idempotencyCache) finish(key string, entry *idempotencyEntry, metrics UsageMetrics, ok bool, clock Clock) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry.metrics = metrics
+	entry.ok = ok
+	entry.expires = clock.Now().Add(c.window)
+	if !ok {
+		delete(c.entries, key)
+	}
+	close(entry.done)
+}
+
+// sweep removes expired entries once per window; callers must hold c.mu
+func (c *idempotencyCache) sweep(now time.Time) {
+	if now.Sub(c.lastSweep) < c.window {
+		return
+	}
+	c.lastSweep = now
+
+	for key, entry := range c.entries {
+		if entry.expires.IsZero() {
+			continue // still being tracked
+		}
+		if !now.Before(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// trackOnce tracks a call with track unless the call with the same key was tracked
+// within the idempotency window, in which case its metrics are returned
+func (t *DefaultTokenTracker) trackOnce(key string, track func() (UsageMetrics, error)) (UsageMetrics, error) {
+	if t.idempotency == nil || key == "" {
+		return track()
+	}
+
+	metrics, found, finish := t.idempotency.begin(key, t.clock())
+	if found {
+		return metrics, nil
+	}
+
+	metrics, err := track()
+	// Usage that failed after being built, e.g. over budget, was still recorded
+	finish(metrics, !metrics.Timestamp.IsZero())
+	return metrics, err
+}
+
+// idempotencyKey returns the key identifying a call: the caller's key, or the
+// completion or request ID of the response, scoped to the provider
+func (t *DefaultTokenTracker) idempotencyKey(providerName string, callParams CallParams, response interface{}) string {
+	if callParams.IdempotencyKey != "" {
+		return callParams.IdempotencyKey
+	}
+	if response == nil {
+		return ""
+	}
+
+	id := ""
+	t.mu.RLock()
+	client := t.sdkClients[providerName]
+	t.mu.RUnlock()
+	if client != nil {
+		if usage, err := client.ExtractTokenUsageFromResponse(response); err == nil {
+			id = usage.CompletionID
+			if id == "" {
+				id = usage.RequestID
+			}
+		}
+	}
+	if id == "" {
+		id = responseID(response)
+	}
+	if id == "" {
+		return ""
+	}
+	return providerName + ":" + id
+}
+
+// responseID returns the ID of a decoded JSON response: "id" for OpenAI, Azure,
+// Anthropic and Mistral, "responseId" for Gemini
+func responseID(response interface{}) string {
+	body, ok := response.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	for _, field := range []string{"id", "responseId", "request_id"} {
+		if id, ok := body[field].(string); ok && id != "" {
+			return id
+		}
+	}
+	return ""
+}
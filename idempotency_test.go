@@ -0,0 +1,137 @@
+package tokentracker
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker/tokentrackertest"
+)
+
+// responseMapProvider reports the TokenCount in the "usage" field of a map response
+type responseMapProvider struct {
+	usagePricedProvider
+}
+
+func (p *responseMapProvider) ExtractTokenUsageFromResponse(response interface{}) (TokenCount, error) {
+	body, _ := response.(map[string]interface{})
+	count, _ := body["usage"].(TokenCount)
+	return count, nil
+}
+
+func newIdempotentTracker(t *testing.T, clock Clock, opts ...TrackerOption) (*DefaultTokenTracker, *MemoryUsageStore) {
+	store := NewMemoryUsageStore()
+	tracker := newPricedTestTracker(t, append([]TrackerOption{WithClock(clock), WithUsageStore(store)}, opts...)...)
+	tracker.RegisterProvider(&responseMapProvider{newPricedProvider(tracker.Config(), "acme", "acme-1")})
+	return tracker, store
+}
+
+func storedCalls(t *testing.T, store *MemoryUsageStore) int {
+	t.Helper()
+	records, err := store.Query(UsageFilter{})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	return len(records)
+}
+
+func TestDefaultTokenTracker_Idempotency(t *testing.T) {
+	clock := tokentrackertest.NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	tracker, store := newIdempotentTracker(t, clock, WithIdempotency(time.Hour))
+
+	response := map[string]interface{}{"id": "chatcmpl-123", "usage": TokenCount{InputTokens: 100, ResponseTokens: 20}}
+	params := CallParams{Model: "acme-1", StartTime: clock.Now()}
+
+	first, err := tracker.TrackUsage(params, response)
+	if err != nil {
+		t.Fatalf("TrackUsage() error = %v", err)
+	}
+
+	// A retry delivering the same completion returns the original metrics
+	clock.Advance(time.Minute)
+	retried, err := tracker.TrackUsage(params, response)
+	if err != nil {
+		t.Fatalf("TrackUsage() error = %v", err)
+	}
+	if !retried.Timestamp.Equal(first.Timestamp) || retried.Price.TotalCost != first.Price.TotalCost {
+		t.Errorf("TrackUsage() = %+v, want the original metrics %+v", retried, first)
+	}
+	if calls := storedCalls(t, store); calls != 1 {
+		t.Errorf("stored %d calls, want the duplicate recorded once", calls)
+	}
+
+	// Another completion is tracked
+	other := map[string]interface{}{"id": "chatcmpl-456", "usage": TokenCount{InputTokens: 100, ResponseTokens: 20}}
+	if _, err := tracker.TrackUsage(params, other); err != nil {
+		t.Fatalf("TrackUsage() error = %v", err)
+	}
+	if calls := storedCalls(t, store); calls != 2 {
+		t.Errorf("stored %d calls, want 2", calls)
+	}
+
+	// Once the window has passed the completion is tracked again
+	clock.Advance(time.Hour)
+	if _, err := tracker.TrackUsage(params, response); err != nil {
+		t.Fatalf("TrackUsage() error = %v", err)
+	}
+	if calls := storedCalls(t, store); calls != 3 {
+		t.Errorf("stored %d calls, want the completion tracked again after the window", calls)
+	}
+}
+
+func TestDefaultTokenTracker_IdempotencyKey(t *testing.T) {
+	clock := tokentrackertest.NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	tracker, store := newIdempotentTracker(t, clock, WithIdempotency(0))
+
+	params := CallParams{Model: "acme-1", StartTime: clock.Now(), IdempotencyKey: "job-42/item-7"}
+	for i := 0; i < 3; i++ {
+		if _, err := tracker.TrackReportedUsage(context.Background(), params, TokenCount{InputTokens: 10, ResponseTokens: 5}); err != nil {
+			t.Fatalf("TrackReportedUsage() error = %v", err)
+		}
+	}
+	if calls := storedCalls(t, store); calls != 1 {
+		t.Errorf("stored %d calls, want the keyed call recorded once", calls)
+	}
+
+	// Responses without an ID are always tracked
+	anonymous := map[string]interface{}{"usage": TokenCount{InputTokens: 10, ResponseTokens: 5}}
+	tracker.TrackUsage(CallParams{Model: "acme-1", StartTime: clock.Now()}, anonymous)
+	tracker.TrackUsage(CallParams{Model: "acme-1", StartTime: clock.Now()}, anonymous)
+	if calls := storedCalls(t, store); calls != 3 {
+		t.Errorf("stored %d calls, want 3", calls)
+	}
+}
+
+func TestDefaultTokenTracker_IdempotencyConcurrent(t *testing.T) {
+	tracker, store := newIdempotentTracker(t, SystemClock, WithIdempotency(time.Hour))
+	response := map[string]interface{}{"id": "msg_01", "usage": TokenCount{InputTokens: 100, ResponseTokens: 20}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := tracker.TrackUsage(CallParams{Model: "acme-1", StartTime: time.Now()}, response); err != nil {
+				t.Errorf("TrackUsage() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls := storedCalls(t, store); calls != 1 {
+		t.Errorf("stored %d calls, want concurrent duplicates recorded once", calls)
+	}
+}
+
+func TestDefaultTokenTracker_WithoutIdempotency(t *testing.T) {
+	clock := tokentrackertest.NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	tracker, store := newIdempotentTracker(t, clock)
+
+	response := map[string]interface{}{"id": "chatcmpl-123", "usage": TokenCount{InputTokens: 100, ResponseTokens: 20}}
+	tracker.TrackUsage(CallParams{Model: "acme-1", StartTime: clock.Now()}, response)
+	tracker.TrackUsage(CallParams{Model: "acme-1", StartTime: clock.Now()}, response)
+	if calls := storedCalls(t, store); calls != 2 {
+		t.Errorf("stored %d calls, want every call recorded without WithIdempotency", calls)
+	}
+}
@@ -0,0 +1,19 @@
+module github.com/TrustSight-io/tokentracker/langchaingo
+
+go 1.22.2
+
+require (
+	github.com/TrustSight-io/tokentracker v0.1.0
+	github.com/tmc/langchaingo v0.1.13
+)
+
+require (
+	github.com/dlclark/regexp2 v1.10.0 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/pkoukk/tiktoken-go v0.1.7 // indirect
+	golang.org/x/text v0.21.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+// The tracker is built from this repository, so the adapter always matches it
+replace github.com/TrustSight-io/tokentracker => ../
@@ -0,0 +1,200 @@
+// Package langchaingo tracks the token usage and cost of the LLM calls made by
+// langchaingo chains and agents. Attach a Handler as the callback of an LLM:
+//
+//	handler := langchaingo.NewHandler(tracker, "gpt-4o")
+//	llm, err := openai.New(openai.WithModel("gpt-4o"), openai.WithCallback(handler))
+//
+// The usage langchaingo reports in a response's generation info is tracked as is;
+// for LLMs that report none, the messages and the generated content are counted.
+//
+// The package is a module of its own so that the tracker does not depend on
+// langchaingo.
+package langchaingo
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker"
+	"github.com/tmc/langchaingo/callbacks"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// Handler is a langchaingo callbacks.Handler tracking every LLM call it is notified of
+type Handler struct {
+	callbacks.SimpleHandler
+
+	// Tracker receives the usage of every tracked call
+	Tracker *tokentracker.DefaultTokenTracker
+
+	// Model prices the calls of the LLM the handler is attached to
+	Model string
+
+	// Tags, UserID and ProjectID are attached to every tracked call
+	Tags      map[string]string
+	UserID    string
+	ProjectID string
+
+	// OnError receives tracking failures; they never fail the chain (nil ignores them)
+	OnError func(error)
+
+	// pending holds the started calls of each context, innermost last: langchaingo
+	// callbacks carry no call ID, so calls sharing a context end in reverse order
+	mu      sync.Mutex
+	pending map[context.Context][]pendingCall
+}
+
+// pendingCall is an LLM call that started and has not ended yet
+type pendingCall struct {
+	start    time.Time
+	messages []tokentracker.Message
+}
+
+// Handler implements callbacks.Handler
+var _ callbacks.Handler = (*Handler)(nil)
+
+// NewHandler creates a handler tracking the calls of an LLM using model
+func NewHandler(tracker *tokentracker.DefaultTokenTracker, model string) *Handler {
+	return &Handler{
+		Tracker: tracker,
+		Model:   model,
+	}
+}
+
+// HandleLLMGenerateContentStart remembers the start time and messages of a call.
+// Concurrent calls sharing a context cannot be told apart; give each its own context,
+// e.g. with context.WithValue, to attribute them correctly.
+func (h *Handler) HandleLLMGenerateContentStart(ctx context.Context, ms []llms.MessageContent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.pending == nil {
+		h.pending = make(map[context.Context][]pendingCall)
+	}
+	h.pending[ctx] = append(h.pending[ctx], pendingCall{start: h.Tracker.Config().GetClock().Now(), messages: convertMessages(ms)})
+}
+
+// HandleLLMGenerateContentEnd tracks the usage of a call
+func (h *Handler) HandleLLMGenerateContentEnd(ctx context.Context, res *llms.ContentResponse) {
+	call := h.takePending(ctx)
+	callParams := tokentracker.CallParams{
+		Model:     h.Model,
+		Params:    tokentracker.TokenCountParams{Model: h.Model, Messages: call.messages},
+		StartTime: call.start,
+		Tags:      h.Tags,
+		UserID:    h.UserID,
+		ProjectID: h.ProjectID,
+	}
+	if callParams.StartTime.IsZero() {
+		callParams.StartTime = h.Tracker.Config().GetClock().Now()
+	}
+
+	count, reported := reportedUsage(res)
+	if !reported {
+		var err error
+		if count, err = h.countUsage(ctx, callParams.Params, res); err != nil {
+			h.reportError(err)
+			return
+		}
+	}
+
+	if _, err := h.Tracker.TrackReportedUsage(ctx, callParams, count); err != nil {
+		h.reportError(err)
+	}
+}
+
+// HandleLLMError forgets a call that failed
+func (h *Handler) HandleLLMError(ctx context.Context, err error) {
+	h.takePending(ctx)
+}
+
+// takePending removes and returns the innermost pending call of ctx
+func (h *Handler) takePending(ctx context.Context) pendingCall {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	calls := h.pending[ctx]
+	if len(calls) == 0 {
+		return pendingCall{}
+	}
+	call := calls[len(calls)-1]
+	if len(calls) == 1 {
+		delete(h.pending, ctx)
+	} else {
+		h.pending[ctx] = calls[:len(calls)-1]
+	}
+	return call
+}
+
+// countUsage counts the messages and generated content of a call whose LLM reported
+// no usage
+func (h *Handler) countUsage(ctx context.Context, params tokentracker.TokenCountParams, res *llms.ContentResponse) (tokentracker.TokenCount, error) {
+	var count tokentracker.TokenCount
+	if len(params.Messages) > 0 {
+		input, err := h.Tracker.CountTokensCtx(ctx, params)
+		if err != nil {
+			return tokentracker.TokenCount{}, err
+		}
+		count.InputTokens = input.InputTokens
+	}
+
+	if content := responseContent(res); content != "" {
+		output, err := h.Tracker.CountTokensCtx(ctx, tokentracker.TokenCountParams{Model: h.Model, Text: &content})
+		if err != nil {
+			return tokentracker.TokenCount{}, err
+		}
+		count.ResponseTokens = output.InputTokens
+	}
+	count.TotalTokens = count.InputTokens + count.ResponseTokens
+	return count, nil
+}
+
+// reportError passes a tracking failure to OnError
+func (h *Handler) reportError(err error) {
+	if h.OnError != nil {
+		h.OnError(err)
+	}
+}
+
+// convertMessages converts langchaingo messages to tracker messages, keeping their text
+func convertMessages(ms []llms.MessageContent) []tokentracker.Message {
+	messages := make([]tokentracker.Message, 0, len(ms))
+	for _, m := range ms {
+		var text strings.Builder
+		for _, part := range m.Parts {
+			if content, ok := part.(llms.TextContent); ok {
+				text.WriteString(content.Text)
+			}
+		}
+		messages = append(messages, tokentracker.Message{Role: messageRole(m.Role), Content: text.String()})
+	}
+	return messages
+}
+
+// messageRole returns the chat role of a langchaingo message type
+func messageRole(role llms.ChatMessageType) string {
+	switch role {
+	case llms.ChatMessageTypeHuman, llms.ChatMessageTypeGeneric:
+		return "user"
+	case llms.ChatMessageTypeAI:
+		return "assistant"
+	default:
+		return string(role)
+	}
+}
+
+// responseContent returns the generated content of every choice of a response
+func responseContent(res *llms.ContentResponse) string {
+	if res == nil {
+		return ""
+	}
+	var content strings.Builder
+	for _, choice := range res.Choices {
+		if choice != nil {
+			content.WriteString(choice.Content)
+		}
+	}
+	return content.String()
+}
@@ -0,0 +1,141 @@
+package langchaingo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker"
+	"github.com/TrustSight-io/tokentracker/providers"
+	"github.com/TrustSight-io/tokentracker/tokentrackertest"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func newTestHandler() (*Handler, *tokentracker.MemoryUsageStore, *tokentrackertest.FakeClock) {
+	clock := tokentrackertest.NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	config := tokentracker.NewConfig()
+	config.SetClock(clock)
+	store := tokentracker.NewMemoryUsageStore()
+	tracker := tokentracker.NewTokenTracker(config, tokentracker.WithUsageStore(store))
+	tracker.RegisterProvider(providers.NewClaudeProvider(config))
+
+	handler := NewHandler(tracker, "claude-3-haiku")
+	handler.Tags = map[string]string{"chain": "qa"}
+	handler.OnError = func(err error) { panic(err) }
+	return handler, store, clock
+}
+
+func TestHandler_ReportedUsage(t *testing.T) {
+	handler, store, clock := newTestHandler()
+	ctx := context.Background()
+
+	handler.HandleLLMGenerateContentStart(ctx, []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "Hello")})
+	clock.Advance(2 * time.Second)
+	handler.HandleLLMGenerateContentEnd(ctx, &llms.ContentResponse{Choices: []*llms.ContentChoice{{
+		Content:        "Hi there!",
+		GenerationInfo: map[string]any{"InputTokens": 100, "OutputTokens": 20, "CacheReadInputTokens": 50},
+	}}})
+
+	records, _ := store.Query(tokentracker.UsageFilter{})
+	if len(records) != 1 {
+		t.Fatalf("stored records = %d, want 1", len(records))
+	}
+	record := records[0]
+	if record.TokenCount.InputTokens != 150 || record.TokenCount.CachedInputTokens != 50 || record.TokenCount.ResponseTokens != 20 {
+		t.Errorf("TokenCount = %+v, want the reported usage with cache reads as input", record.TokenCount)
+	}
+	if record.Duration != 2*time.Second || record.Tags["chain"] != "qa" || record.Price.TotalCost == 0 {
+		t.Errorf("record = %+v", record)
+	}
+}
+
+func TestHandler_CountsUnreportedUsage(t *testing.T) {
+	handler, store, _ := newTestHandler()
+	ctx := context.Background()
+
+	handler.HandleLLMGenerateContentStart(ctx, []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeSystem, "Be brief."),
+		llms.TextParts(llms.ChatMessageTypeHuman, "What is the capital of France?"),
+	})
+	handler.HandleLLMGenerateContentEnd(ctx, &llms.ContentResponse{Choices: []*llms.ContentChoice{{Content: "Paris."}}})
+
+	records, _ := store.Query(tokentracker.UsageFilter{})
+	if len(records) != 1 || records[0].TokenCount.InputTokens == 0 || records[0].TokenCount.ResponseTokens == 0 {
+		t.Fatalf("records = %+v, want the counted messages and content", records)
+	}
+}
+
+func TestHandler_NestedCallsSharingContext(t *testing.T) {
+	handler, store, clock := newTestHandler()
+	ctx := context.Background()
+
+	handler.HandleLLMGenerateContentStart(ctx, []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "Outer")})
+	clock.Advance(time.Second)
+	handler.HandleLLMGenerateContentStart(ctx, []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "Inner")})
+	clock.Advance(time.Second)
+	handler.HandleLLMGenerateContentEnd(ctx, &llms.ContentResponse{Choices: []*llms.ContentChoice{{
+		GenerationInfo: map[string]any{"InputTokens": 10, "OutputTokens": 1},
+	}}})
+	clock.Advance(time.Second)
+	handler.HandleLLMGenerateContentEnd(ctx, &llms.ContentResponse{Choices: []*llms.ContentChoice{{
+		GenerationInfo: map[string]any{"InputTokens": 20, "OutputTokens": 2},
+	}}})
+
+	records, _ := store.Query(tokentracker.UsageFilter{})
+	if len(records) != 2 {
+		t.Fatalf("stored records = %d, want 2", len(records))
+	}
+	durations := map[int]time.Duration{}
+	for _, record := range records {
+		durations[record.TokenCount.InputTokens] = record.Duration
+	}
+	// The inner call ends first and keeps its own start time
+	if durations[10] != time.Second || durations[20] != 3*time.Second {
+		t.Errorf("durations by input tokens = %v, want the inner call 1s and the outer 3s", durations)
+	}
+	if len(handler.pending) != 0 {
+		t.Errorf("pending contexts = %d, want none", len(handler.pending))
+	}
+}
+
+func TestHandler_ErrorsAndFailedCalls(t *testing.T) {
+	handler, store, _ := newTestHandler()
+	var reported error
+	handler.OnError = func(err error) { reported = err }
+	ctx := context.Background()
+
+	handler.HandleLLMGenerateContentStart(ctx, []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "Hello")})
+	handler.HandleLLMError(ctx, errors.New("rate limited"))
+	if len(handler.pending) != 0 {
+		t.Errorf("pending calls = %d, want the failed call forgotten", len(handler.pending))
+	}
+
+	handler.Model = "unknown-model"
+	handler.HandleLLMGenerateContentEnd(ctx, &llms.ContentResponse{Choices: []*llms.ContentChoice{{GenerationInfo: map[string]any{"PromptTokens": 10, "CompletionTokens": 5}}}})
+	if reported == nil {
+		t.Error("tracking a call of an unknown model should report an error")
+	}
+	if records, _ := store.Query(tokentracker.UsageFilter{}); len(records) != 0 {
+		t.Errorf("stored records = %d, want none", len(records))
+	}
+}
+
+func TestGenerationInfoUsage(t *testing.T) {
+	tests := []struct {
+		name string
+		info map[string]any
+		want tokentracker.TokenCount
+		ok   bool
+	}{
+		{"openai", map[string]any{"PromptTokens": 30, "CompletionTokens": 12, "TotalTokens": 42, "ReasoningTokens": 4}, tokentracker.TokenCount{InputTokens: 30, ResponseTokens: 12, TotalTokens: 42, ReasoningTokens: 4}, true},
+		{"googleai", map[string]any{"input_tokens": int32(8), "output_tokens": int32(3), "total_tokens": int32(11)}, tokentracker.TokenCount{InputTokens: 8, ResponseTokens: 3, TotalTokens: 11}, true},
+		{"none", map[string]any{"StopReason": "stop"}, tokentracker.TokenCount{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := generationInfoUsage(tt.info)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("%s: generationInfoUsage() = %+v, %v, want %+v, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
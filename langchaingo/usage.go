@@ -0,0 +1,78 @@
+package langchaingo
+
+import (
+	"strings"
+
+	"github.com/TrustSight-io/tokentracker"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// reportedUsage returns the usage an LLM reported in the generation info of a
+// response's first choice carrying usage. The LLMs of langchaingo name the counts
+// differently, e.g. OpenAI's "PromptTokens" and "CompletionTokens", Anthropic's
+// "InputTokens" and "OutputTokens" and Google AI's "input_tokens" and "output_tokens".
+func reportedUsage(res *llms.ContentResponse) (tokentracker.TokenCount, bool) {
+	if res == nil {
+		return tokentracker.TokenCount{}, false
+	}
+	for _, choice := range res.Choices {
+		if choice == nil {
+			continue
+		}
+		if count, ok := generationInfoUsage(choice.GenerationInfo); ok {
+			return count, true
+		}
+	}
+	return tokentracker.TokenCount{}, false
+}
+
+// generationInfoUsage reads the token counts of a choice's generation info
+func generationInfoUsage(info map[string]any) (tokentracker.TokenCount, bool) {
+	var count tokentracker.TokenCount
+	var found, cacheExcluded bool
+	for key, value := range info {
+		tokens, ok := intValue(value)
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(strings.ReplaceAll(key, "_", "")) {
+		case "prompttokens", "inputtokens":
+			count.InputTokens, found = tokens, true
+		case "completiontokens", "outputtokens":
+			count.ResponseTokens, found = tokens, true
+		case "reasoningtokens", "completionreasoningtokens":
+			count.ReasoningTokens = tokens
+		case "cachedtokens", "promptcachedtokens":
+			count.CachedInputTokens = tokens
+		case "cachereadinputtokens":
+			// Anthropic reports cache reads and writes apart from the input tokens
+			count.CachedInputTokens, cacheExcluded = tokens, true
+		case "cachecreationinputtokens":
+			count.CacheWriteTokens, cacheExcluded = tokens, true
+		}
+	}
+	if !found {
+		return tokentracker.TokenCount{}, false
+	}
+	if cacheExcluded {
+		count.InputTokens += count.CachedInputTokens + count.CacheWriteTokens
+	}
+	count.TotalTokens = count.InputTokens + count.ResponseTokens
+	return count, true
+}
+
+// intValue converts a token count of generation info to an int
+func intValue(value any) (int, bool) {
+	switch v := value.(type) {
+	case int:
+		return v, true
+	case int32:
+		return int(v), true
+	case int64:
+		return int(v), true
+	case float64:
+		return int(v), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,51 @@
+package tokentracker
+
+import "log/slog"
+
+// SetLogger sets the structured logger of the tracker and the providers using this
+// configuration (nil restores slog.Default)
+func (c *Config) SetLogger(logger *slog.Logger) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.logger = logger
+}
+
+// GetLogger returns the structured logger of the tracker and the providers using this
+// configuration
+func (c *Config) GetLogger() *slog.Logger {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.logger == nil {
+		return slog.Default()
+	}
+	return c.logger
+}
+
+// WithLogger makes the tracker and its providers log to logger. Failures the tracker
+// recovers from, like usage that cannot be extracted from a response, failed pricing
+// updates and fallbacks to estimates, are logged at warn level; routine events, like
+// pricing changes and estimated response tokens, at debug level. Token caches log
+// evictions to their own TokenCacheOptions.Logger, as they can be shared.
+func WithLogger(logger *slog.Logger) TrackerOption {
+	return func(t *DefaultTokenTracker) {
+		t.config.SetLogger(logger)
+	}
+}
+
+// logger returns the structured logger of the tracker
+func (t *DefaultTokenTracker) logger() *slog.Logger {
+	return t.config.GetLogger()
+}
+
+// ReportFallback reports that a fallback replaced a failed call: to the OnFallback
+// callback of the resilience layer when there is one, or else to the logger
+func (c *Config) ReportFallback(warning FallbackWarning) {
+	if resilience := c.GetResilience(); resilience != nil && resilience.opts.OnFallback != nil {
+		resilience.opts.OnFallback(warning)
+		return
+	}
+	c.GetLogger().Warn("call failed, using fallback",
+		"provider", warning.Provider, "operation", warning.Operation, "fallback", warning.Fallback, "error", warning.Err)
+}
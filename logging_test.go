@@ -0,0 +1,136 @@
+package tokentracker
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testLogger returns a logger recording JSON records at all levels, and a function
+// returning the records logged so far
+func testLogger(t *testing.T) (*slog.Logger, func() []map[string]interface{}) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	return logger, func() []map[string]interface{} {
+		var records []map[string]interface{}
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			if line == "" {
+				continue
+			}
+			var record map[string]interface{}
+			if err := json.Unmarshal([]byte(line), &record); err != nil {
+				t.Fatalf("invalid log record %q: %v", line, err)
+			}
+			records = append(records, record)
+		}
+		return records
+	}
+}
+
+// findRecord returns the first record with the given message
+func findRecord(records []map[string]interface{}, msg string) (map[string]interface{}, bool) {
+	for _, record := range records {
+		if record["msg"] == msg {
+			return record, true
+		}
+	}
+	return nil, false
+}
+
+// unreadableProvider is a provider that cannot extract usage from responses
+type unreadableProvider struct {
+	MockProvider
+}
+
+func (p *unreadableProvider) ExtractTokenUsageFromResponse(response interface{}) (TokenCount, error) {
+	return TokenCount{}, NewError(ErrInvalidParams, "unsupported response type", nil)
+}
+
+func TestWithLogger_ExtractionFailure(t *testing.T) {
+	logger, records := testLogger(t)
+	tracker := NewTokenTracker(NewConfig(), WithLogger(logger))
+	tracker.RegisterProvider(&unreadableProvider{MockProvider{
+		name:           "mock",
+		supportedModel: "mock-model",
+		tokenCount:     TokenCount{InputTokens: 10, ResponseTokens: 5, TotalTokens: 15},
+	}})
+
+	_, err := tracker.TrackUsage(CallParams{
+		Model:     "mock-model",
+		Params:    TokenCountParams{Model: "mock-model", Text: stringPtr("Hello")},
+		StartTime: time.Now(),
+	}, "response")
+	if err != nil {
+		t.Fatalf("TrackUsage() error = %v", err)
+	}
+
+	record, ok := findRecord(records(), "failed to extract usage from response, counting tokens")
+	if !ok {
+		t.Fatalf("extraction failure not logged, got %v", records())
+	}
+	if record["level"] != "WARN" || record["provider"] != "mock" || record["response_type"] != "string" {
+		t.Errorf("extraction failure record = %v", record)
+	}
+	if _, ok := findRecord(records(), "estimated response tokens"); !ok {
+		t.Errorf("response token estimate not logged, got %v", records())
+	}
+}
+
+func TestConfig_ReportFallback(t *testing.T) {
+	logger, records := testLogger(t)
+	config := NewConfig()
+	config.SetLogger(logger)
+
+	warning := FallbackWarning{Provider: "anthropic", Operation: "count_tokens", Fallback: FallbackApproximation, Err: errors.New("unavailable")}
+	config.ReportFallback(warning)
+	record, ok := findRecord(records(), "call failed, using fallback")
+	if !ok || record["level"] != "WARN" || record["operation"] != "count_tokens" || record["error"] != "unavailable" {
+		t.Fatalf("fallback record = %v, want a warning", record)
+	}
+
+	// A fallback callback receives the warnings instead of the logger
+	var reported []FallbackWarning
+	config.SetResilience(NewResilience(ResilienceOptions{OnFallback: func(w FallbackWarning) {
+		reported = append(reported, w)
+	}}))
+	config.ReportFallback(warning)
+	if len(reported) != 1 || len(records()) != 1 {
+		t.Errorf("reported %d warnings and logged %d records, want the warning reported only", len(reported), len(records()))
+	}
+}
+
+func TestWithLogger_PricingChanges(t *testing.T) {
+	logger, records := testLogger(t)
+	tracker := NewTokenTracker(NewConfig(), WithLogger(logger))
+
+	err := tracker.auditPricing(PricingChangeSourceProvider, func() error {
+		tracker.config.SetModelPricing("acme", "acme-1", ModelPricing{InputPricePerToken: 0.001, Currency: "USD"})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("auditPricing() error = %v", err)
+	}
+
+	record, ok := findRecord(records(), "pricing changed")
+	if !ok || record["level"] != "DEBUG" || record["model"] != "acme-1" || record["added"] != true {
+		t.Errorf("pricing change record = %v", record)
+	}
+}
+
+func TestTokenCache_LogsEvictions(t *testing.T) {
+	logger, records := testLogger(t)
+	cache := NewTokenCache(TokenCacheOptions{MaxEntries: 1, Logger: logger})
+
+	cache.Set("openai", "gpt-4", "first", 1)
+	cache.Set("openai", "gpt-4", "second", 1)
+
+	record, ok := findRecord(records(), "token cache entry evicted")
+	if !ok || record["evictions"] != float64(1) {
+		t.Errorf("eviction record = %v", record)
+	}
+}
@@ -0,0 +1,34 @@
+package tokentracker
+
+import (
+	"math"
+	"time"
+)
+
+// MediaDurations returns the total duration of the audio and video parts of messages
+func MediaDurations(messages []Message) (audio, video time.Duration) {
+	for _, message := range messages {
+		parts, ok := message.Content.([]ContentPart)
+		if !ok {
+			continue
+		}
+		for _, part := range parts {
+			switch part.Type {
+			case ContentTypeAudio:
+				audio += part.Duration
+			case ContentTypeVideo:
+				video += part.Duration
+			}
+		}
+	}
+	return audio, video
+}
+
+// MediaTokens converts the duration of audio or video content to tokens at a rate
+// of tokens per second, rounding up
+func MediaTokens(duration time.Duration, tokensPerSecond float64) int {
+	if duration <= 0 {
+		return 0
+	}
+	return int(math.Ceil(duration.Seconds() * tokensPerSecond))
+}
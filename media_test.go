@@ -0,0 +1,42 @@
+package tokentracker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMediaDurations(t *testing.T) {
+	messages := []Message{
+		{Role: "system", Content: "You transcribe audio."},
+		{Role: "user", Content: []ContentPart{
+			{Type: ContentTypeText, Text: "Transcribe these."},
+			{Type: ContentTypeAudio, Duration: 1500 * time.Millisecond},
+			{Type: ContentTypeAudio, Duration: 2 * time.Second},
+			{Type: ContentTypeVideo, Duration: time.Minute},
+		}},
+	}
+
+	audio, video := MediaDurations(messages)
+	if audio != 3500*time.Millisecond || video != time.Minute {
+		t.Errorf("MediaDurations() = %v, %v, want 3.5s, 1m0s", audio, video)
+	}
+}
+
+func TestMediaTokens(t *testing.T) {
+	tests := []struct {
+		duration        time.Duration
+		tokensPerSecond float64
+		want            int
+	}{
+		{0, 32, 0},
+		{time.Second, 32, 32},
+		{1500 * time.Millisecond, 10, 15},
+		{1510 * time.Millisecond, 10, 16},
+		{time.Minute, 263, 15780},
+	}
+	for _, tt := range tests {
+		if got := MediaTokens(tt.duration, tt.tokensPerSecond); got != tt.want {
+			t.Errorf("MediaTokens(%v, %v) = %d, want %d", tt.duration, tt.tokensPerSecond, got, tt.want)
+		}
+	}
+}
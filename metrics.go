@@ -0,0 +1,296 @@
+package tokentracker
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// UsageObserver receives the usage of every tracked call. Metrics exporters implement
+// it; adapting another metrics library (e.g. a prometheus.Registerer with CounterVecs)
+// only takes implementing this method.
+type UsageObserver interface {
+	ObserveUsage(metrics UsageMetrics)
+}
+
+// WithUsageObserver makes the tracker report every tracked call to the observer
+func WithUsageObserver(observer UsageObserver) TrackerOption {
+	return func(t *DefaultTokenTracker) {
+		t.observers = append(t.observers, observer)
+	}
+}
+
+// AddUsageObserver registers an observer on a running tracker
+func (t *DefaultTokenTracker) AddUsageObserver(observer UsageObserver) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.observers = append(t.observers, observer)
+}
+
+// RemoveUsageObserver unregisters an observer added with WithUsageObserver or
+// AddUsageObserver
+func (t *DefaultTokenTracker) RemoveUsageObserver(observer UsageObserver) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Calls being notified keep the slice they read, so a new one is built
+	observers := make([]UsageObserver, 0, len(t.observers))
+	for _, registered := range t.observers {
+		if registered != observer {
+			observers = append(observers, registered)
+		}
+	}
+	t.observers = observers
+}
+
+// notifyObservers reports tracked usage to the registered observers
+func (t *DefaultTokenTracker) notifyObservers(metrics UsageMetrics) {
+	t.mu.RLock()
+	observers := t.observers
+	t.mu.RUnlock()
+
+	for _, observer := range observers {
+		observer.ObserveUsage(metrics)
+	}
+}
+
+// DefaultDurationBuckets are the call duration histogram buckets in seconds
+var DefaultDurationBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+// PrometheusOptions configures a PrometheusExporter
+type PrometheusOptions struct {
+	// Namespace prefixes all metric names (empty uses "tokentracker")
+	Namespace string
+
+	// DurationBuckets are the upper bounds of the call duration histogram in seconds
+	// (nil uses DefaultDurationBuckets)
+	DurationBuckets []float64
+}
+
+// metricLabels identifies a time series
+type metricLabels struct {
+	provider string
+	model    string
+}
+
+// durationHistogram is a cumulative histogram of call durations
+type durationHistogram struct {
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// PrometheusExporter is a UsageObserver exposing token usage in the Prometheus
+// text format. It serves the tokens_in, tokens_out, cost_usd and calls counters
+// and the call_duration_seconds histogram, labeled by provider and model. Calls are
+// added to one of several shards, so concurrent calls rarely wait on each other, and
+// the shards are summed when the metrics are written.
+type PrometheusExporter struct {
+	namespace string
+	buckets   []float64
+	shards    []*metricSeries
+}
+
+// metricSeries holds the series of all counters and histograms, of one shard or
+// summed over the shards
+type metricSeries struct {
+	tokensIn  map[metricLabels]float64
+	tokensOut map[metricLabels]float64
+	costUSD   map[metricLabels]float64
+	calls     map[metricLabels]float64
+	durations map[metricLabels]*durationHistogram
+	mu        sync.Mutex
+}
+
+// NewPrometheusExporter creates a Prometheus exporter
+func NewPrometheusExporter(opts PrometheusOptions) *PrometheusExporter {
+	if opts.Namespace == "" {
+		opts.Namespace = "tokentracker"
+	}
+	if opts.DurationBuckets == nil {
+		opts.DurationBuckets = DefaultDurationBuckets
+	}
+	buckets := append([]float64(nil), opts.DurationBuckets...)
+	sort.Float64s(buckets)
+
+	exporter := &PrometheusExporter{
+		namespace: opts.Namespace,
+		buckets:   buckets,
+		shards:    make([]*metricSeries, counterShards),
+	}
+	for i := range exporter.shards {
+		exporter.shards[i] = newMetricSeries()
+	}
+	return exporter
+}
+
+// newMetricSeries creates empty series
+func newMetricSeries() *metricSeries {
+	return &metricSeries{
+		tokensIn:  make(map[metricLabels]float64),
+		tokensOut: make(map[metricLabels]float64),
+		costUSD:   make(map[metricLabels]float64),
+		calls:     make(map[metricLabels]float64),
+		durations: make(map[metricLabels]*durationHistogram),
+	}
+}
+
+// ObserveUsage adds a tracked call to the metrics. Costs in currencies other than
+// USD are not added to cost_usd.
+func (e *PrometheusExporter) ObserveUsage(metrics UsageMetrics) {
+	labels := metricLabels{provider: metrics.Provider, model: metrics.Model}
+	seconds := metrics.Duration.Seconds()
+
+	shard := e.shards[randomShard(len(e.shards))]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	shard.tokensIn[labels] += float64(metrics.TokenCount.InputTokens)
+	shard.tokensOut[labels] += float64(metrics.TokenCount.ResponseTokens)
+	if metrics.Price.Currency == "" || strings.EqualFold(metrics.Price.Currency, "USD") {
+		shard.costUSD[labels] += metrics.Price.TotalCost
+	}
+	shard.calls[labels]++
+
+	histogram := shard.histogram(labels, len(e.buckets))
+	for i, bound := range e.buckets {
+		if seconds <= bound {
+			histogram.counts[i]++
+			break
+		}
+	}
+	histogram.count++
+	histogram.sum += seconds
+}
+
+// histogram returns the duration histogram of a series, creating it when missing
+func (s *metricSeries) histogram(labels metricLabels, buckets int) *durationHistogram {
+	histogram, exists := s.durations[labels]
+	if !exists {
+		histogram = &durationHistogram{counts: make([]uint64, buckets)}
+		s.durations[labels] = histogram
+	}
+	return histogram
+}
+
+// snapshot sums the series of all shards
+func (e *PrometheusExporter) snapshot() *metricSeries {
+	total := newMetricSeries()
+	for _, shard := range e.shards {
+		shard.mu.Lock()
+		for _, counter := range [][2]map[metricLabels]float64{
+			{total.tokensIn, shard.tokensIn},
+			{total.tokensOut, shard.tokensOut},
+			{total.costUSD, shard.costUSD},
+			{total.calls, shard.calls},
+		} {
+			for labels, value := range counter[1] {
+				counter[0][labels] += value
+			}
+		}
+		for labels, histogram := range shard.durations {
+			sum := total.histogram(labels, len(e.buckets))
+			for i, count := range histogram.counts {
+				sum.counts[i] += count
+			}
+			sum.count += histogram.count
+			sum.sum += histogram.sum
+		}
+		shard.mu.Unlock()
+	}
+	return total
+}
+
+// ServeHTTP serves the metrics in the Prometheus text exposition format
+func (e *PrometheusExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = e.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format
+func (e *PrometheusExporter) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+
+	series := e.snapshot()
+	e.writeCounter(&b, "tokens_in_total", "Input tokens of tracked calls.", series.tokensIn)
+	e.writeCounter(&b, "tokens_out_total", "Output tokens of tracked calls.", series.tokensOut)
+	e.writeCounter(&b, "cost_usd_total", "Cost of tracked calls in USD.", series.costUSD)
+	e.writeCounter(&b, "calls_total", "Number of tracked calls.", series.calls)
+	e.writeHistogram(&b, series.durations)
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// writeCounter writes one counter family
+func (e *PrometheusExporter) writeCounter(b *strings.Builder, name, help string, values map[metricLabels]float64) {
+	name = e.namespace + "_" + name
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	for _, labels := range sortedLabels(values) {
+		fmt.Fprintf(b, "%s{%s} %s\n", name, labels.format(), formatFloat(values[labels]))
+	}
+}
+
+// writeHistogram writes the call duration histogram
+func (e *PrometheusExporter) writeHistogram(b *strings.Builder, durations map[metricLabels]*durationHistogram) {
+	name := e.namespace + "_call_duration_seconds"
+	fmt.Fprintf(b, "# HELP %s Duration of tracked calls.\n# TYPE %s histogram\n", name, name)
+
+	keys := make([]metricLabels, 0, len(durations))
+	for labels := range durations {
+		keys = append(keys, labels)
+	}
+	sortLabels(keys)
+
+	for _, labels := range keys {
+		histogram := durations[labels]
+		var cumulative uint64
+		for i, bound := range e.buckets {
+			cumulative += histogram.counts[i]
+			fmt.Fprintf(b, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels.format(), formatFloat(bound), cumulative)
+		}
+		fmt.Fprintf(b, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels.format(), histogram.count)
+		fmt.Fprintf(b, "%s_sum{%s} %s\n", name, labels.format(), formatFloat(histogram.sum))
+		fmt.Fprintf(b, "%s_count{%s} %d\n", name, labels.format(), histogram.count)
+	}
+}
+
+// format renders the labels of a series
+func (l metricLabels) format() string {
+	return fmt.Sprintf(`provider="%s",model="%s"`, escapeLabel(l.provider), escapeLabel(l.model))
+}
+
+// sortedLabels returns the series of a counter in a stable order
+func sortedLabels(values map[metricLabels]float64) []metricLabels {
+	keys := make([]metricLabels, 0, len(values))
+	for labels := range values {
+		keys = append(keys, labels)
+	}
+	sortLabels(keys)
+	return keys
+}
+
+// sortLabels orders series by provider and model
+func sortLabels(keys []metricLabels) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].provider != keys[j].provider {
+			return keys[i].provider < keys[j].provider
+		}
+		return keys[i].model < keys[j].model
+	})
+}
+
+// escapeLabel escapes a label value for the text format
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// formatFloat formats a sample value
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
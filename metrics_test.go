@@ -0,0 +1,117 @@
+package tokentracker
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheusExporter(t *testing.T) {
+	exporter := NewPrometheusExporter(PrometheusOptions{DurationBuckets: []float64{1, 0.5}})
+
+	exporter.ObserveUsage(UsageMetrics{
+		TokenCount: TokenCount{InputTokens: 100, ResponseTokens: 20},
+		Price:      Price{TotalCost: 0.25, Currency: "USD"},
+		Duration:   300 * time.Millisecond,
+		Provider:   "openai",
+		Model:      "gpt-4",
+	})
+	exporter.ObserveUsage(UsageMetrics{
+		TokenCount: TokenCount{InputTokens: 50, ResponseTokens: 5},
+		Price:      Price{TotalCost: 0.5, Currency: "USD"},
+		Duration:   2 * time.Second,
+		Provider:   "openai",
+		Model:      "gpt-4",
+	})
+	exporter.ObserveUsage(UsageMetrics{
+		TokenCount: TokenCount{InputTokens: 7},
+		Price:      Price{TotalCost: 3, Currency: "EUR"},
+		Provider:   "anthropic",
+		Model:      `claude "3"`,
+	})
+
+	recorder := httptest.NewRecorder()
+	exporter.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+
+	if !strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", recorder.Header().Get("Content-Type"))
+	}
+
+	for _, want := range []string{
+		"# TYPE tokentracker_tokens_in_total counter",
+		`tokentracker_tokens_in_total{provider="openai",model="gpt-4"} 150`,
+		`tokentracker_tokens_out_total{provider="openai",model="gpt-4"} 25`,
+		`tokentracker_cost_usd_total{provider="openai",model="gpt-4"} 0.75`,
+		`tokentracker_calls_total{provider="anthropic",model="claude \"3\""} 1`,
+		"# TYPE tokentracker_call_duration_seconds histogram",
+		`tokentracker_call_duration_seconds_bucket{provider="openai",model="gpt-4",le="0.5"} 1`,
+		`tokentracker_call_duration_seconds_bucket{provider="openai",model="gpt-4",le="1"} 1`,
+		`tokentracker_call_duration_seconds_bucket{provider="openai",model="gpt-4",le="+Inf"} 2`,
+		`tokentracker_call_duration_seconds_sum{provider="openai",model="gpt-4"} 2.3`,
+		`tokentracker_call_duration_seconds_count{provider="openai",model="gpt-4"} 2`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics output is missing %q:\n%s", want, body)
+		}
+	}
+
+	// Non-USD costs stay out of cost_usd
+	if strings.Contains(body, `tokentracker_cost_usd_total{provider="anthropic"`) {
+		t.Errorf("EUR cost was added to cost_usd:\n%s", body)
+	}
+}
+
+type recordingObserver struct {
+	observed []UsageMetrics
+}
+
+func (o *recordingObserver) ObserveUsage(metrics UsageMetrics) {
+	o.observed = append(o.observed, metrics)
+}
+
+func TestDefaultTokenTracker_UsageObservers(t *testing.T) {
+	observer := &recordingObserver{}
+	exporter := NewPrometheusExporter(PrometheusOptions{Namespace: "svc"})
+
+	tracker := NewTokenTracker(NewConfig(), WithUsageObserver(observer))
+	tracker.AddUsageObserver(exporter)
+	tracker.RegisterProvider(&MockProvider{
+		name:           "mock",
+		supportedModel: "mock-model",
+		tokenCount:     TokenCount{InputTokens: 10, TotalTokens: 10},
+		price:          Price{TotalCost: 0.01, Currency: "USD"},
+	})
+
+	_, err := tracker.TrackUsage(CallParams{
+		Model:     "mock-model",
+		Params:    TokenCountParams{Model: "mock-model", Text: stringPtr("Hello")},
+		StartTime: time.Now(),
+	}, "response")
+	if err != nil {
+		t.Fatalf("TrackUsage() error = %v", err)
+	}
+
+	if len(observer.observed) != 1 || observer.observed[0].Provider != "mock" {
+		t.Errorf("observed = %+v, want the tracked call", observer.observed)
+	}
+
+	var out strings.Builder
+	_, _ = exporter.WriteTo(&out)
+	if !strings.Contains(out.String(), `svc_calls_total{provider="mock",model="mock-model"} 1`) {
+		t.Errorf("exporter output:\n%s", out.String())
+	}
+}
+
+func BenchmarkPrometheusExporter_ObserveUsageParallel(b *testing.B) {
+	exporter := NewPrometheusExporter(PrometheusOptions{})
+	metrics := UsageMetrics{Provider: "openai", Model: "gpt-4", TokenCount: TokenCount{InputTokens: 100, ResponseTokens: 10}, Price: Price{TotalCost: 0.001, Currency: "USD"}, Duration: 300 * time.Millisecond}
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			exporter.ObserveUsage(metrics)
+		}
+	})
+}
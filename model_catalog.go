@@ -0,0 +1,287 @@
+package tokentracker
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ModelEntry maps a model, or a family of models, to its provider. Exactly one of
+// Model, Prefix and Pattern identifies the models the entry matches.
+type ModelEntry struct {
+	Provider string
+
+	// Model matches one model identifier exactly
+	Model string `json:",omitempty"`
+
+	// Prefix matches every model starting with it, e.g. "gpt-4o-" for dated versions
+	Prefix string `json:",omitempty"`
+
+	// Pattern matches every model matching the regular expression
+	Pattern string `json:",omitempty"`
+
+	// PricingModel is the model whose pricing applies to matched models that have
+	// no pricing of their own
+	PricingModel string `json:",omitempty"`
+}
+
+// ModelCatalog resolves model identifiers to providers. Exact entries take precedence,
+// then the longest matching prefix, then patterns, most recently added first.
+type ModelCatalog struct {
+	exact    map[string]ModelEntry
+	prefixes map[string]ModelEntry
+	patterns []catalogPattern
+	mu       sync.RWMutex
+}
+
+// catalogPattern is a pattern entry with its compiled expression
+type catalogPattern struct {
+	entry ModelEntry
+	re    *regexp.Regexp
+}
+
+// NewModelCatalog creates a catalog with the given entries
+func NewModelCatalog(entries ...ModelEntry) (*ModelCatalog, error) {
+	catalog := &ModelCatalog{
+		exact:    make(map[string]ModelEntry),
+		prefixes: make(map[string]ModelEntry),
+	}
+	for _, entry := range entries {
+		if err := catalog.Add(entry); err != nil {
+			return nil, err
+		}
+	}
+	return catalog, nil
+}
+
+// DefaultModelCatalog creates a catalog with the built-in model families
+func DefaultModelCatalog() *ModelCatalog {
+	catalog, err := NewModelCatalog(DefaultModelEntries()...)
+	if err != nil {
+		panic(err)
+	}
+	return catalog
+}
+
+// DefaultModelEntries returns the built-in model families. Dated and point releases
+// of a model are priced like the model unless they have pricing of their own.
+func DefaultModelEntries() []ModelEntry {
+	return []ModelEntry{
+		// OpenAI
+		{Provider: "openai", Prefix: "gpt-3.5-turbo", PricingModel: "gpt-3.5-turbo"},
+		{Provider: "openai", Model: "gpt-4"},
+		{Provider: "openai", Prefix: "gpt-4-", PricingModel: "gpt-4"},
+		{Provider: "openai", Prefix: "gpt-4-32k", PricingModel: "gpt-4-32k"},
+		{Provider: "openai", Prefix: "gpt-4-turbo", PricingModel: "gpt-4-turbo"},
+		{Provider: "openai", Prefix: "gpt-4o", PricingModel: "gpt-4o"},
+		{Provider: "openai", Prefix: "gpt-4o-mini", PricingModel: "gpt-4o-mini"},
+		{Provider: "openai", Prefix: "gpt-4o-audio-preview", PricingModel: "gpt-4o-audio-preview"},
+		{Provider: "openai", Pattern: `^o\d+(-mini|-preview)?(-\d{4}-\d{2}-\d{2})?$`},
+		{Provider: "openai", Prefix: "o1-", PricingModel: "o1"},
+		{Provider: "openai", Prefix: "o1-mini", PricingModel: "o1-mini"},
+		{Provider: "openai", Prefix: "o3-mini", PricingModel: "o3-mini"},
+		{Provider: "openai", Prefix: "text-embedding-"},
+
+		// Anthropic
+		{Provider: "anthropic", Prefix: "claude-3-haiku", PricingModel: "claude-3-haiku"},
+		{Provider: "anthropic", Prefix: "claude-3-sonnet", PricingModel: "claude-3-sonnet"},
+		{Provider: "anthropic", Prefix: "claude-3-opus", PricingModel: "claude-3-opus"},
+		{Provider: "anthropic", Prefix: "claude-3-5-sonnet", PricingModel: "claude-3-sonnet"},
+		{Provider: "anthropic", Prefix: "claude-"},
+
+		// Google
+		{Provider: "gemini", Prefix: "gemini-pro", PricingModel: "gemini-pro"},
+		{Provider: "gemini", Prefix: "gemini-ultra", PricingModel: "gemini-ultra"},
+		{Provider: "gemini", Pattern: `^gemini-\d+(\.\d+)?-(pro|flash)`},
+		{Provider: "gemini", Model: "text-embedding-004"},
+		{Provider: "gemini", Model: "embedding-001"},
+
+		// Mistral
+		{Provider: "mistral", Pattern: `^(mistral-(small|medium|large)|open-mistral-7b|open-mixtral-8x(7|22)b)(-latest|-\d{4})?$`},
+	}
+}
+
+// LoadModelCatalog loads catalog entries from a JSON file holding an array of entries
+func LoadModelCatalog(path string) (*ModelCatalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []ModelEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, NewError(ErrInvalidParams, fmt.Sprintf("invalid model catalog %s", path), err)
+	}
+	return NewModelCatalog(entries...)
+}
+
+// Add adds an entry, replacing an entry for the same model or prefix
+func (c *ModelCatalog) Add(entry ModelEntry) error {
+	set := 0
+	for _, field := range []string{entry.Model, entry.Prefix, entry.Pattern} {
+		if field != "" {
+			set++
+		}
+	}
+	if entry.Provider == "" || set != 1 {
+		return NewError(ErrInvalidParams, "model entry requires a provider and exactly one of model, prefix and pattern", nil)
+	}
+
+	var re *regexp.Regexp
+	if entry.Pattern != "" {
+		compiled, err := regexp.Compile(entry.Pattern)
+		if err != nil {
+			return NewError(ErrInvalidParams, fmt.Sprintf("invalid model pattern %q", entry.Pattern), err)
+		}
+		re = compiled
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch {
+	case entry.Model != "":
+		c.exact[entry.Model] = entry
+	case entry.Prefix != "":
+		c.prefixes[entry.Prefix] = entry
+	default:
+		c.patterns = append(c.patterns, catalogPattern{entry: entry, re: re})
+	}
+	return nil
+}
+
+// AddModels adds exact entries for models of a provider, e.g. from a list-models API
+func (c *ModelCatalog) AddModels(provider string, models []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, model := range models {
+		if model == "" {
+			continue
+		}
+		if _, exists := c.exact[model]; !exists {
+			c.exact[model] = ModelEntry{Provider: provider, Model: model}
+		}
+	}
+}
+
+// Lookup returns the entry matching a model. Fine-tuned models without an entry of
+// their own match the entry of their base model.
+func (c *ModelCatalog) Lookup(model string) (ModelEntry, bool) {
+	if model == "" {
+		return ModelEntry{}, false
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if entry, exists := c.lookup(model); exists {
+		return entry, true
+	}
+	if fineTuned, ok := ParseFineTunedModel(model); ok {
+		return c.lookup(fineTuned.BaseModel)
+	}
+	return ModelEntry{}, false
+}
+
+// lookupOwn returns the entry matching a model itself, without falling back to the
+// entry of a fine-tuned model's base model
+func (c *ModelCatalog) lookupOwn(model string) (ModelEntry, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.lookup(model)
+}
+
+// lookup returns the entry matching a model, with the catalog locked
+func (c *ModelCatalog) lookup(model string) (ModelEntry, bool) {
+	if entry, exists := c.exact[model]; exists {
+		return entry, true
+	}
+
+	var best ModelEntry
+	for prefix, entry := range c.prefixes {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(best.Prefix) {
+			best = entry
+		}
+	}
+	if best.Prefix != "" {
+		return best, true
+	}
+
+	for i := len(c.patterns) - 1; i >= 0; i-- {
+		if c.patterns[i].re.MatchString(model) {
+			return c.patterns[i].entry, true
+		}
+	}
+
+	return ModelEntry{}, false
+}
+
+// Supports reports whether the catalog maps the model to the provider
+func (c *ModelCatalog) Supports(provider, model string) bool {
+	entry, exists := c.Lookup(model)
+	return exists && entry.Provider == provider
+}
+
+// Entries returns all entries: exact models and prefixes sorted, then patterns in
+// the order they were added
+func (c *ModelCatalog) Entries() []ModelEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entries := make([]ModelEntry, 0, len(c.exact)+len(c.prefixes)+len(c.patterns))
+	for _, entry := range c.exact {
+		entries = append(entries, entry)
+	}
+	for _, entry := range c.prefixes {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Model+entries[i].Prefix < entries[j].Model+entries[j].Prefix
+	})
+	for _, pattern := range c.patterns {
+		entries = append(entries, pattern.entry)
+	}
+	return entries
+}
+
+// ModelCatalog returns the catalog providers consult to recognize models. It holds the
+// built-in model families plus the entries of the Models field.
+func (c *Config) ModelCatalog() *ModelCatalog {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.catalog == nil {
+		catalog := DefaultModelCatalog()
+		for _, entry := range c.Models {
+			// Entries are validated when the configuration is loaded
+			_ = catalog.Add(entry)
+		}
+		c.catalog = catalog
+	}
+	return c.catalog
+}
+
+// SetModelCatalog replaces the catalog providers consult to recognize models
+func (c *Config) SetModelCatalog(catalog *ModelCatalog) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.catalog = catalog
+}
+
+// catalogPricingModel returns the model whose pricing applies to a model of the
+// provider. Fine-tuned models matching only their base model's entry are priced by
+// their fine-tuned pricing instead, see Config.SetFineTunedPricing.
+func (c *Config) catalogPricingModel(provider, model string) (string, bool) {
+	entry, exists := c.ModelCatalog().lookupOwn(model)
+	if !exists || entry.Provider != provider || entry.PricingModel == "" || entry.PricingModel == model {
+		return "", false
+	}
+	return entry.PricingModel, true
+}
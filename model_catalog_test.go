@@ -0,0 +1,161 @@
+package tokentracker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestModelCatalog_Lookup(t *testing.T) {
+	catalog := DefaultModelCatalog()
+
+	tests := []struct {
+		model        string
+		provider     string
+		pricingModel string
+	}{
+		{"gpt-4", "openai", ""},
+		{"gpt-4-0613", "openai", "gpt-4"},
+		{"gpt-4-turbo-2024-04-09", "openai", "gpt-4-turbo"},
+		{"gpt-4o-mini", "openai", "gpt-4o-mini"},
+		{"gpt-4o-2024-08-06", "openai", "gpt-4o"},
+		{"o1-mini", "openai", "o1-mini"},
+		{"o1-2024-12-17", "openai", "o1"},
+		{"o3", "openai", ""},
+		{"claude-3-5-sonnet-20240620", "anthropic", "claude-3-sonnet"},
+		{"claude-3-haiku-20240307", "anthropic", "claude-3-haiku"},
+		{"gemini-1.5-pro", "gemini", ""},
+		{"gemini-1.5-flash-001", "gemini", ""},
+		{"mistral-large-latest", "mistral", ""},
+	}
+	for _, tt := range tests {
+		entry, exists := catalog.Lookup(tt.model)
+		if !exists {
+			t.Errorf("Lookup(%s) found no entry", tt.model)
+			continue
+		}
+		if entry.Provider != tt.provider || entry.PricingModel != tt.pricingModel {
+			t.Errorf("Lookup(%s) = %+v, want %s priced as %q", tt.model, entry, tt.provider, tt.pricingModel)
+		}
+	}
+
+	for _, model := range []string{"", "llama-3-70b", "my-gpt-4"} {
+		if _, exists := catalog.Lookup(model); exists {
+			t.Errorf("Lookup(%q) should find no entry", model)
+		}
+	}
+}
+
+func TestModelCatalog_Add(t *testing.T) {
+	catalog, err := NewModelCatalog(
+		ModelEntry{Provider: "acme", Pattern: `^acme-\d+$`},
+		ModelEntry{Provider: "acme", Model: "special"},
+	)
+	if err != nil {
+		t.Fatalf("NewModelCatalog() error = %v", err)
+	}
+
+	if !catalog.Supports("acme", "acme-42") || catalog.Supports("other", "acme-42") {
+		t.Error("Supports() should match the pattern for its provider only")
+	}
+
+	// Patterns added later take precedence
+	_ = catalog.Add(ModelEntry{Provider: "acme-eu", Pattern: `^acme-4`})
+	if entry, _ := catalog.Lookup("acme-42"); entry.Provider != "acme-eu" {
+		t.Errorf("Lookup() = %+v, want the most recent pattern", entry)
+	}
+
+	catalog.AddModels("acme", []string{"special", "listed", ""})
+	if !catalog.Supports("acme", "listed") {
+		t.Error("AddModels() should add the listed models")
+	}
+	if got := len(catalog.Entries()); got != 4 {
+		t.Errorf("Entries() has %d entries, want 4", got)
+	}
+
+	invalid := []ModelEntry{
+		{Model: "no-provider"},
+		{Provider: "acme"},
+		{Provider: "acme", Model: "a", Prefix: "b"},
+		{Provider: "acme", Pattern: "("},
+	}
+	for _, entry := range invalid {
+		if err := catalog.Add(entry); err == nil {
+			t.Errorf("Add(%+v) should fail", entry)
+		}
+	}
+}
+
+func TestLoadModelCatalog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "models.json")
+	data := `[{"Provider": "acme", "Prefix": "acme-", "PricingModel": "acme-1"}]`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	catalog, err := LoadModelCatalog(path)
+	if err != nil {
+		t.Fatalf("LoadModelCatalog() error = %v", err)
+	}
+	if entry, _ := catalog.Lookup("acme-2"); entry.PricingModel != "acme-1" {
+		t.Errorf("Lookup() = %+v", entry)
+	}
+
+	if err := os.WriteFile(path, []byte(`{`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadModelCatalog(path); err == nil {
+		t.Error("LoadModelCatalog() of invalid JSON should fail")
+	}
+}
+
+func TestConfig_ModelCatalogPricing(t *testing.T) {
+	config := NewConfig()
+
+	pricing, exists := config.GetModelPricing("openai", "gpt-4-0613")
+	if !exists || pricing.InputPricePerToken != 0.00003 {
+		t.Errorf("GetModelPricing() of a dated version = %+v, %v, want gpt-4's pricing", pricing, exists)
+	}
+	if _, exists := config.GetModelPricing("anthropic", "gpt-4-0613"); exists {
+		t.Error("GetModelPricing() should not use the catalog entry of another provider")
+	}
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	config.Models = []ModelEntry{{Provider: "openai", Prefix: "ft:gpt-4", PricingModel: "gpt-4"}}
+	if err := config.SaveToFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := NewConfig()
+	if err := loaded.LoadFromFile(path); err != nil {
+		t.Fatalf("LoadFromFile() error = %v", err)
+	}
+	if !loaded.ModelCatalog().Supports("openai", "ft:gpt-4:acme::abc123") {
+		t.Error("the loaded configuration's catalog should include its entries")
+	}
+	if !loaded.ModelCatalog().Supports("openai", "gpt-4o") {
+		t.Error("the loaded configuration's catalog should keep the built-in entries")
+	}
+
+	config.Models = []ModelEntry{{Provider: "openai", Pattern: "("}}
+	if err := config.SaveToFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.LoadFromFile(path); err == nil {
+		t.Error("LoadFromFile() with an invalid catalog entry should fail")
+	}
+}
+
+func TestDefaultTokenTracker_ProviderFromCatalog(t *testing.T) {
+	config := NewConfig()
+	tracker := NewTokenTracker(config)
+	tracker.RegisterProvider(&MockSimpleProvider{name: "openai"})
+
+	provider, exists := tracker.providerForModel("gpt-4o-mini")
+	if !exists || provider.Name() != "openai" {
+		t.Errorf("providerForModel() = %v, %v, want the catalog's provider", provider, exists)
+	}
+	if _, exists := tracker.providerForModel("claude-3-5-sonnet"); exists {
+		t.Error("providerForModel() should not resolve models of unregistered providers")
+	}
+}
@@ -0,0 +1,152 @@
+package tokentracker
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ModelInfo describes a model, its token limits and its lifecycle
+type ModelInfo struct {
+	Name     string
+	Provider string
+
+	// ContextWindow is the number of tokens of prompt and response combined (0 when unknown)
+	ContextWindow int
+
+	// MaxOutputTokens is the largest response the model generates (0 when unknown)
+	MaxOutputTokens int
+
+	// Modalities are the kinds of input the model accepts, e.g. "text", "image" or "audio"
+	Modalities []string
+
+	// Capabilities are the features the model supports, e.g. "chat" or "function-calling"
+	Capabilities []string
+
+	// TrainingCutoff is the month the model's training data ends (zero when unknown)
+	TrainingCutoff time.Time
+
+	// Deprecated reports whether the provider has announced the model's retirement
+	Deprecated bool
+
+	// PricingRef is the model whose pricing applies, when it is not the model itself,
+	// e.g. the base model of a dated version or an Azure deployment
+	PricingRef string
+
+	Description string
+}
+
+// Map returns the information as the map Provider.GetModelInfo used to return, with
+// name, provider, contextWindow, maxOutputTokens, capabilities and description keys.
+//
+// Deprecated: read the fields of the ModelInfo instead.
+func (i ModelInfo) Map() map[string]interface{} {
+	return map[string]interface{}{
+		"name":            i.Name,
+		"provider":        i.Provider,
+		"contextWindow":   i.ContextWindow,
+		"maxOutputTokens": i.MaxOutputTokens,
+		"capabilities":    i.Capabilities,
+		"description":     i.Description,
+	}
+}
+
+// ParseModelInfo converts model information, either a ModelInfo or a map with name,
+// provider, contextWindow, maxOutputTokens, capabilities and description keys.
+//
+// Deprecated: Provider.GetModelInfo returns a ModelInfo.
+func ParseModelInfo(info interface{}) (ModelInfo, error) {
+	switch v := info.(type) {
+	case ModelInfo:
+		return v, nil
+	case *ModelInfo:
+		if v != nil {
+			return *v, nil
+		}
+	case map[string]interface{}:
+		parsed := ModelInfo{
+			ContextWindow:   intValue(v["contextWindow"]),
+			MaxOutputTokens: intValue(v["maxOutputTokens"]),
+		}
+		parsed.Name, _ = v["name"].(string)
+		parsed.Provider, _ = v["provider"].(string)
+		parsed.Description, _ = v["description"].(string)
+		switch capabilities := v["capabilities"].(type) {
+		case []string:
+			parsed.Capabilities = capabilities
+		case []interface{}:
+			for _, capability := range capabilities {
+				if s, ok := capability.(string); ok {
+					parsed.Capabilities = append(parsed.Capabilities, s)
+				}
+			}
+		}
+		return parsed, nil
+	}
+	return ModelInfo{}, NewError(ErrInvalidParams, fmt.Sprintf("unsupported model info type %T", info), nil)
+}
+
+// intValue returns the integer held by an int or a JSON number, or 0
+func intValue(value interface{}) int {
+	switch v := value.(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	}
+	return 0
+}
+
+// ModelInfo returns the information the model's provider has about it
+func (t *DefaultTokenTracker) ModelInfo(model string) (ModelInfo, error) {
+	if model == "" {
+		return ModelInfo{}, NewError(ErrInvalidParams, "model is required", nil)
+	}
+
+	provider, exists := t.providerForModel(model)
+	if !exists {
+		return ModelInfo{}, NewError(ErrProviderNotFound, fmt.Sprintf("no provider found for model: %s", model), nil)
+	}
+
+	info, err := provider.GetModelInfo(model)
+	if err != nil {
+		return ModelInfo{}, err
+	}
+	if info.Name == "" {
+		info.Name = model
+	}
+	if info.Provider == "" {
+		info.Provider = provider.Name()
+	}
+	return info, nil
+}
+
+// FitsContextWindow reports whether the prompt of params fits the model's context
+// window with room for a response, and how many tokens remain for the response.
+// remaining is negative by the number of tokens the prompt is over the window.
+func (t *DefaultTokenTracker) FitsContextWindow(params TokenCountParams) (fits bool, remaining int, err error) {
+	return t.FitsContextWindowCtx(context.Background(), params)
+}
+
+// FitsContextWindowCtx checks the prompt against the context window, propagating
+// cancellation and deadlines to the provider
+func (t *DefaultTokenTracker) FitsContextWindowCtx(ctx context.Context, params TokenCountParams) (fits bool, remaining int, err error) {
+	info, err := t.ModelInfo(params.Model)
+	if err != nil {
+		return false, 0, err
+	}
+	if info.ContextWindow <= 0 {
+		return false, 0, NewError(ErrInvalidModel, fmt.Sprintf("context window of %s is unknown", params.Model), nil)
+	}
+
+	params.CountResponseTokens = false
+	count, err := t.CountTokensCtx(ctx, params)
+	if err != nil {
+		return false, 0, err
+	}
+
+	remaining = info.ContextWindow - count.InputTokens
+	return remaining > 0, remaining, nil
+}
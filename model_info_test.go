@@ -0,0 +1,82 @@
+package tokentracker
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// windowedProvider is an estimatingProvider reporting a context window
+type windowedProvider struct {
+	estimatingProvider
+	window int
+}
+
+func (p *windowedProvider) GetModelInfo(model string) (ModelInfo, error) {
+	return ModelInfo{ContextWindow: p.window, MaxOutputTokens: 100}, nil
+}
+
+func TestParseModelInfo(t *testing.T) {
+	var decoded map[string]interface{}
+	_ = json.Unmarshal([]byte(`{"name":"m","provider":"p","contextWindow":8192,"capabilities":["text","chat"]}`), &decoded)
+
+	info, err := ParseModelInfo(decoded)
+	if err != nil {
+		t.Fatalf("ParseModelInfo() error = %v", err)
+	}
+	if info.Name != "m" || info.Provider != "p" || info.ContextWindow != 8192 || len(info.Capabilities) != 2 {
+		t.Errorf("ParseModelInfo() = %+v", info)
+	}
+
+	if info, _ := ParseModelInfo(&ModelInfo{ContextWindow: 10}); info.ContextWindow != 10 {
+		t.Errorf("ParseModelInfo() of a ModelInfo = %+v", info)
+	}
+	if _, err := ParseModelInfo("gpt-4"); err == nil {
+		t.Error("ParseModelInfo() of a string should fail")
+	}
+
+	if parsed, _ := ParseModelInfo(info.Map()); parsed.Name != "m" || parsed.ContextWindow != 8192 || len(parsed.Capabilities) != 2 {
+		t.Errorf("ParseModelInfo(Map()) = %+v, want the information back", parsed)
+	}
+}
+
+func TestDefaultTokenTracker_FitsContextWindow(t *testing.T) {
+	tracker := newPricedTestTracker(t)
+	provider := &windowedProvider{
+		estimatingProvider: estimatingProvider{
+			usagePricedProvider: newPricedProvider(tracker.Config(), "acme", "acme-1"),
+			count:               TokenCount{InputTokens: 1000, ResponseTokens: 300},
+		},
+		window: 4096,
+	}
+	tracker.RegisterProvider(provider)
+
+	info, err := tracker.ModelInfo("acme-1")
+	if err != nil {
+		t.Fatalf("ModelInfo() error = %v", err)
+	}
+	if info.Name != "acme-1" || info.Provider != "acme" || info.ContextWindow != 4096 || info.MaxOutputTokens != 100 {
+		t.Errorf("ModelInfo() = %+v, want the name and provider filled in", info)
+	}
+
+	params := TokenCountParams{Model: "acme-1", Text: stringPtr("a long prompt")}
+	fits, remaining, err := tracker.FitsContextWindow(params)
+	if err != nil {
+		t.Fatalf("FitsContextWindow() error = %v", err)
+	}
+	if !fits || remaining != 3096 {
+		t.Errorf("FitsContextWindow() = %v, %d, want true, 3096", fits, remaining)
+	}
+
+	provider.window = 800
+	if fits, remaining, _ := tracker.FitsContextWindow(params); fits || remaining != -200 {
+		t.Errorf("FitsContextWindow() of an oversized prompt = %v, %d, want false, -200", fits, remaining)
+	}
+
+	provider.window = 0
+	if _, _, err := tracker.FitsContextWindow(params); err == nil {
+		t.Error("FitsContextWindow() with an unknown context window should fail")
+	}
+	if _, _, err := tracker.FitsContextWindow(TokenCountParams{Model: "unknown"}); err == nil {
+		t.Error("FitsContextWindow() of an unknown model should fail")
+	}
+}
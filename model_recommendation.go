@@ -0,0 +1,139 @@
+package tokentracker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ModelRequirements describes the calls a model is wanted for, see CheapestModelFor
+type ModelRequirements struct {
+	// InputTokens and OutputTokens are the token profile of a call: the model must fit
+	// both in its context window and is ranked by the price of such a call
+	InputTokens  int
+	OutputTokens int
+
+	// CachedInputTokens are the input tokens expected to be read from the prompt cache
+	CachedInputTokens int
+
+	// Modalities are the kinds of input the model must accept, e.g. "image"
+	Modalities []string
+
+	// Capabilities are the features the model must support, e.g. "function-calling"
+	Capabilities []string
+
+	// Providers restricts the models to these providers (empty allows all)
+	Providers []string
+
+	// IncludeDeprecated allows models whose retirement has been announced
+	IncludeDeprecated bool
+}
+
+// ModelRecommendation is a model meeting requirements, with the price of a call
+type ModelRecommendation struct {
+	Model    string
+	Provider string
+	Info     ModelInfo
+	Price    Price
+}
+
+// CheapestModelFor returns the cheapest priced model of the registered providers that
+// meets the requirements, e.g. fits a 50k token prompt and supports function calling
+func (t *DefaultTokenTracker) CheapestModelFor(requirements ModelRequirements) (ModelRecommendation, error) {
+	recommendations, err := t.RankModelsFor(requirements)
+	if err != nil {
+		return ModelRecommendation{}, err
+	}
+	if len(recommendations) == 0 {
+		return ModelRecommendation{}, NewError(ErrNoMatchingModel, "no model meets the requirements", nil)
+	}
+	return recommendations[0], nil
+}
+
+// RankModelsFor returns the priced models of the registered providers that meet the
+// requirements, cheapest first. Models with an unknown context window only qualify when
+// no token counts are required, and models generating no output, i.e. embedding models
+// and models without a maximum output, when no output tokens are required. Prices must be in one currency: set a default currency
+// when models are priced in different ones.
+func (t *DefaultTokenTracker) RankModelsFor(requirements ModelRequirements) ([]ModelRecommendation, error) {
+	if requirements.InputTokens < 0 || requirements.OutputTokens < 0 || requirements.CachedInputTokens < 0 {
+		return nil, NewError(ErrInvalidParams, "token counts must not be negative", nil)
+	}
+	if requirements.CachedInputTokens > requirements.InputTokens {
+		return nil, NewError(ErrInvalidParams, "cached input tokens must not exceed input tokens", nil)
+	}
+
+	usage := BillableUsage{
+		InputTokens:       requirements.InputTokens,
+		OutputTokens:      requirements.OutputTokens,
+		CachedInputTokens: requirements.CachedInputTokens,
+	}
+
+	var recommendations []ModelRecommendation
+	currency := ""
+	for _, provider := range t.registry.All() {
+		if len(requirements.Providers) > 0 && !containsFold(requirements.Providers, provider.Name()) {
+			continue
+		}
+
+		for _, model := range t.config.pricedModels(provider.Name()) {
+			info, err := provider.GetModelInfo(model)
+			if err != nil || !requirements.matches(info) {
+				continue
+			}
+			price, err := t.CalculateUsagePrice(model, usage)
+			if err != nil {
+				continue
+			}
+
+			if currency == "" {
+				currency = price.Currency
+			} else if price.Currency != currency {
+				return nil, NewError(ErrCurrencyConversion, fmt.Sprintf("prices in %s and %s cannot be compared; set a default currency", currency, price.Currency), nil)
+			}
+			recommendations = append(recommendations, ModelRecommendation{Model: model, Provider: provider.Name(), Info: info, Price: price})
+		}
+	}
+
+	sort.Slice(recommendations, func(i, j int) bool {
+		if recommendations[i].Price.TotalCost != recommendations[j].Price.TotalCost {
+			return recommendations[i].Price.TotalCost < recommendations[j].Price.TotalCost
+		}
+		return recommendations[i].Model < recommendations[j].Model
+	})
+	return recommendations, nil
+}
+
+// matches reports whether a model meets the requirements
+func (r ModelRequirements) matches(info ModelInfo) bool {
+	if info.Deprecated && !r.IncludeDeprecated {
+		return false
+	}
+	if tokens := r.InputTokens + r.OutputTokens; tokens > 0 && info.ContextWindow < tokens {
+		return false
+	}
+	if r.OutputTokens > 0 && (info.MaxOutputTokens < r.OutputTokens || containsFold(info.Capabilities, "embeddings")) {
+		return false
+	}
+	for _, modality := range r.Modalities {
+		if !containsFold(info.Modalities, modality) {
+			return false
+		}
+	}
+	for _, capability := range r.Capabilities {
+		if !containsFold(info.Capabilities, capability) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsFold reports whether values contains value, ignoring case
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package tokentracker
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// describedProvider is a usagePricedProvider with model information
+type describedProvider struct {
+	usagePricedProvider
+	info map[string]ModelInfo
+}
+
+func (p *describedProvider) GetModelInfo(model string) (ModelInfo, error) {
+	info, exists := p.info[model]
+	if !exists {
+		return ModelInfo{}, NewError(ErrInvalidModel, "unknown model", nil)
+	}
+	return info, nil
+}
+
+// newRecommendationTracker creates a tracker with described models of two providers
+func newRecommendationTracker(t *testing.T) *DefaultTokenTracker {
+	tracker := newPricedTestTracker(t)
+	config := tracker.Config()
+	config.SetModelPricing("acme", "acme-large", ModelPricing{InputPricePerToken: 0.00001, OutputPricePerToken: 0.00003, CachedInputPricePerToken: 0.000001, Currency: "USD"})
+	config.SetModelPricing("acme", "acme-small", ModelPricing{InputPricePerToken: 0.000001, OutputPricePerToken: 0.000002, Currency: "USD"})
+	config.SetModelPricing("acme", "acme-legacy", ModelPricing{InputPricePerToken: 0.0000001, OutputPricePerToken: 0.0000001, Currency: "USD"})
+	config.SetModelPricing("acme", "acme-embed", ModelPricing{InputPricePerToken: 0.00000001, Currency: "USD"})
+	config.SetModelPricing("zeta", "zeta-1", ModelPricing{InputPricePerToken: 0.000003, OutputPricePerToken: 0.000006, Currency: "USD"})
+
+	tracker.RegisterProvider(&describedProvider{
+		usagePricedProvider: newPricedProvider(config, "acme", "acme-large", "acme-small", "acme-legacy", "acme-embed"),
+		info: map[string]ModelInfo{
+			"acme-large":  {ContextWindow: 200000, MaxOutputTokens: 8192, Modalities: []string{"text", "image"}, Capabilities: []string{"chat", "function-calling"}},
+			"acme-small":  {ContextWindow: 16000, MaxOutputTokens: 4096, Modalities: []string{"text"}, Capabilities: []string{"chat", "function-calling"}},
+			"acme-legacy": {ContextWindow: 100000, MaxOutputTokens: 4096, Modalities: []string{"text"}, Capabilities: []string{"chat", "function-calling"}, Deprecated: true},
+			"acme-embed":  {ContextWindow: 8000, Modalities: []string{"text"}, Capabilities: []string{"embeddings"}},
+		},
+	})
+	tracker.RegisterProvider(&describedProvider{
+		usagePricedProvider: newPricedProvider(config, "zeta", "zeta-1"),
+		info: map[string]ModelInfo{
+			"zeta-1": {ContextWindow: 128000, MaxOutputTokens: 4096, Modalities: []string{"text"}, Capabilities: []string{"chat", "function-calling"}},
+		},
+	})
+	return tracker
+}
+
+func TestDefaultTokenTracker_CheapestModelFor(t *testing.T) {
+	tracker := newRecommendationTracker(t)
+
+	tests := []struct {
+		name         string
+		requirements ModelRequirements
+		want         string
+	}{
+		{"small prompt", ModelRequirements{InputTokens: 1000, OutputTokens: 500, Capabilities: []string{"chat"}}, "acme-small"},
+		{"large prompt with tools", ModelRequirements{InputTokens: 50000, OutputTokens: 1000, Capabilities: []string{"function-calling"}}, "zeta-1"},
+		{"images", ModelRequirements{InputTokens: 1000, Modalities: []string{"image"}}, "acme-large"},
+		{"deprecated allowed", ModelRequirements{InputTokens: 50000, Capabilities: []string{"chat"}, IncludeDeprecated: true}, "acme-legacy"},
+		{"provider restricted", ModelRequirements{InputTokens: 50000, Capabilities: []string{"chat"}, Providers: []string{"ACME"}}, "acme-large"},
+		{"any model", ModelRequirements{}, "acme-embed"},
+		{"any model generating output", ModelRequirements{InputTokens: 1000, OutputTokens: 500}, "acme-small"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recommendation, err := tracker.CheapestModelFor(tt.requirements)
+			if err != nil {
+				t.Fatalf("CheapestModelFor() error = %v", err)
+			}
+			if recommendation.Model != tt.want {
+				t.Errorf("CheapestModelFor() = %s, want %s", recommendation.Model, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultTokenTracker_RankModelsFor(t *testing.T) {
+	tracker := newRecommendationTracker(t)
+
+	ranked, err := tracker.RankModelsFor(ModelRequirements{InputTokens: 20000, OutputTokens: 1000, CachedInputTokens: 10000, Capabilities: []string{"function-calling"}})
+	if err != nil {
+		t.Fatalf("RankModelsFor() error = %v", err)
+	}
+	if len(ranked) != 2 || ranked[0].Model != "zeta-1" || ranked[1].Model != "acme-large" {
+		t.Fatalf("RankModelsFor() = %+v, want zeta-1 and acme-large", ranked)
+	}
+	if want := 10000*0.00001 + 10000*0.000001 + 1000*0.00003; math.Abs(ranked[1].Price.TotalCost-want) > 1e-12 || ranked[1].Provider != "acme" {
+		t.Errorf("acme-large price = %v, want %v with cached input", ranked[1].Price.TotalCost, want)
+	}
+
+	_, err = tracker.CheapestModelFor(ModelRequirements{InputTokens: 500000})
+	var trackerErr *TokenTrackerError
+	if !errors.As(err, &trackerErr) || trackerErr.Type != ErrNoMatchingModel {
+		t.Errorf("CheapestModelFor() error = %v, want %s", err, ErrNoMatchingModel)
+	}
+	if _, err := tracker.RankModelsFor(ModelRequirements{InputTokens: 10, CachedInputTokens: 20}); err == nil {
+		t.Error("RankModelsFor() with more cached than input tokens should fail")
+	}
+}
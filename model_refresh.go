@@ -0,0 +1,222 @@
+package tokentracker
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ModelLister is implemented by SDK clients that can list the models available
+// to the account (e.g. OpenAI /v1/models, Gemini ListModels)
+type ModelLister interface {
+	// ListModels returns the identifiers of the models currently available
+	ListModels(ctx context.Context) ([]string, error)
+}
+
+// DefaultModelsProvider is implemented by providers that expose their static list of supported models
+type DefaultModelsProvider interface {
+	// DefaultModels returns the models the provider supports out of the box
+	DefaultModels() []string
+}
+
+// ModelEventType identifies the kind of model change
+type ModelEventType string
+
+// Model event types
+const (
+	ModelAdded ModelEventType = "model_added"
+)
+
+// ModelEvent describes a change in the set of known models
+type ModelEvent struct {
+	Type      ModelEventType
+	Provider  string
+	Model     string
+	Timestamp time.Time
+}
+
+// modelIndex tracks the known models per provider
+type modelIndex struct {
+	static     map[string]map[string]bool
+	discovered map[string]map[string]bool
+	listeners  []func(ModelEvent)
+	mu         sync.RWMutex
+}
+
+// newModelIndex creates an empty model index
+func newModelIndex() *modelIndex {
+	return &modelIndex{
+		static:     make(map[string]map[string]bool),
+		discovered: make(map[string]map[string]bool),
+	}
+}
+
+// addStatic adds statically known models for a provider
+func (m *modelIndex) addStatic(provider string, models []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	set, exists := m.static[provider]
+	if !exists {
+		set = make(map[string]bool)
+		m.static[provider] = set
+	}
+	for _, model := range models {
+		set[model] = true
+	}
+}
+
+// merge adds discovered models and returns the events for models that were not known before
+func (m *modelIndex) merge(provider string, models []string, now time.Time) []ModelEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	set, exists := m.discovered[provider]
+	if !exists {
+		set = make(map[string]bool)
+		m.discovered[provider] = set
+	}
+
+	var events []ModelEvent
+	for _, model := range models {
+		if model == "" || set[model] || m.static[provider][model] {
+			continue
+		}
+		set[model] = true
+		events = append(events, ModelEvent{
+			Type:      ModelAdded,
+			Provider:  provider,
+			Model:     model,
+			Timestamp: now,
+		})
+	}
+
+	return events
+}
+
+// models returns the sorted union of static and discovered models for a provider
+func (m *modelIndex) models(provider string) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	union := make(map[string]bool)
+	for model := range m.static[provider] {
+		union[model] = true
+	}
+	for model := range m.discovered[provider] {
+		union[model] = true
+	}
+
+	models := make([]string, 0, len(union))
+	for model := range union {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+	return models
+}
+
+// providerFor returns the provider that lists the model, if any
+func (m *modelIndex) providerFor(model string) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for provider, set := range m.discovered {
+		if set[model] {
+			return provider, true
+		}
+	}
+	for provider, set := range m.static {
+		if set[model] {
+			return provider, true
+		}
+	}
+	return "", false
+}
+
+// emit invokes the registered listeners for each event
+func (m *modelIndex) emit(events []ModelEvent) {
+	m.mu.RLock()
+	listeners := append([]func(ModelEvent){}, m.listeners...)
+	m.mu.RUnlock()
+
+	for _, event := range events {
+		for _, listener := range listeners {
+			listener(event)
+		}
+	}
+}
+
+// OnModelAdded registers a callback invoked when a refresh discovers a new model
+func (t *DefaultTokenTracker) OnModelAdded(callback func(ModelEvent)) {
+	t.models.mu.Lock()
+	defer t.models.mu.Unlock()
+
+	t.models.listeners = append(t.models.listeners, callback)
+}
+
+// KnownModels returns the default and discovered models for a provider
+func (t *DefaultTokenTracker) KnownModels(providerName string) []string {
+	if provider, exists := t.registry.Get(providerName); exists {
+		if defaults, ok := provider.(DefaultModelsProvider); ok {
+			t.models.addStatic(providerName, defaults.DefaultModels())
+		}
+	}
+
+	return t.models.models(providerName)
+}
+
+// RefreshModels asks every registered SDK client that implements ModelLister for its
+// current model list, merges the result into the known models and the model catalog
+// and emits ModelAdded events
+func (t *DefaultTokenTracker) RefreshModels(ctx context.Context) error {
+	t.mu.RLock()
+	clients := make([]SDKClient, 0, len(t.sdkClients))
+	for _, client := range t.sdkClients {
+		clients = append(clients, client)
+	}
+	t.mu.RUnlock()
+
+	var lastErr error
+	for _, client := range clients {
+		lister, ok := client.(ModelLister)
+		if !ok {
+			continue
+		}
+
+		models, err := lister.ListModels(ctx)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		t.config.ModelCatalog().AddModels(client.GetProviderName(), models)
+		t.models.emit(t.models.merge(client.GetProviderName(), models, t.clock().Now()))
+	}
+
+	if lastErr != nil {
+		return NewError(ErrModelRefreshFailed, "failed to refresh models for one or more providers", lastErr)
+	}
+	return nil
+}
+
+// StartModelRefresh refreshes the known models immediately and then at the given interval
+// until ctx is cancelled. Refresh errors are passed to onError when it is not nil.
+func (t *DefaultTokenTracker) StartModelRefresh(ctx context.Context, interval time.Duration, onError func(error)) {
+	go func() {
+		ticker := t.clock().NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := t.RefreshModels(ctx); err != nil && onError != nil {
+				onError(err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.Chan():
+			}
+		}
+	}()
+}
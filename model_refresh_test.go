@@ -0,0 +1,128 @@
+package tokentracker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockListerClient is an SDKClient that can list models
+type mockListerClient struct {
+	provider string
+	static   []string
+	listed   []string
+	err      error
+	mu       sync.Mutex
+}
+
+func (c *mockListerClient) GetProviderName() string { return c.provider }
+func (c *mockListerClient) GetClient() interface{}  { return nil }
+func (c *mockListerClient) GetSupportedModels() ([]string, error) {
+	return c.static, nil
+}
+func (c *mockListerClient) ExtractTokenUsageFromResponse(response interface{}) (TokenUsage, error) {
+	return TokenUsage{}, nil
+}
+func (c *mockListerClient) FetchCurrentPricing() (map[string]ModelPricing, error) {
+	return nil, nil
+}
+func (c *mockListerClient) UpdateProviderPricing() error { return nil }
+func (c *mockListerClient) TrackAPICall(model string, response interface{}) (UsageMetrics, error) {
+	return UsageMetrics{}, nil
+}
+
+func (c *mockListerClient) ListModels(ctx context.Context) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.listed, c.err
+}
+
+func (c *mockListerClient) setListed(models []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.listed = models
+}
+
+func TestDefaultTokenTracker_RefreshModels(t *testing.T) {
+	tracker := NewTokenTracker(NewConfig())
+	tracker.RegisterProvider(&MockProvider{name: "mock", supportedModel: "mock-1"})
+
+	client := &mockListerClient{provider: "mock", static: []string{"mock-1"}}
+	if err := tracker.RegisterSDKClient(client); err != nil {
+		t.Fatalf("RegisterSDKClient() error = %v", err)
+	}
+
+	var events []ModelEvent
+	tracker.OnModelAdded(func(event ModelEvent) {
+		events = append(events, event)
+	})
+
+	client.setListed([]string{"mock-1", "mock-2"})
+	if err := tracker.RefreshModels(context.Background()); err != nil {
+		t.Fatalf("RefreshModels() error = %v", err)
+	}
+
+	// Only the model missing from the static list is reported
+	if len(events) != 1 || events[0].Model != "mock-2" || events[0].Type != ModelAdded || events[0].Provider != "mock" {
+		t.Fatalf("events = %+v, want a single ModelAdded for mock-2", events)
+	}
+
+	got := tracker.KnownModels("mock")
+	if len(got) != 2 || got[0] != "mock-1" || got[1] != "mock-2" {
+		t.Errorf("KnownModels() = %v, want [mock-1 mock-2]", got)
+	}
+
+	// Discovered models resolve to the provider that listed them
+	if provider, ok := tracker.providerForModel("mock-2"); !ok || provider.Name() != "mock" {
+		t.Errorf("providerForModel(mock-2) = %v, %v", provider, ok)
+	}
+
+	// Refreshing again does not re-emit known models
+	client.setListed([]string{"mock-1", "mock-2", "mock-3"})
+	_ = tracker.RefreshModels(context.Background())
+	if len(events) != 2 || events[1].Model != "mock-3" {
+		t.Errorf("events after second refresh = %+v", events)
+	}
+}
+
+func TestDefaultTokenTracker_RefreshModelsError(t *testing.T) {
+	tracker := NewTokenTracker(NewConfig())
+	tracker.RegisterProvider(&MockProvider{name: "mock", supportedModel: "mock-1"})
+
+	client := &mockListerClient{provider: "mock", err: errors.New("unavailable")}
+	_ = tracker.RegisterSDKClient(client)
+
+	err := tracker.RefreshModels(context.Background())
+	var tokenErr *TokenTrackerError
+	if !errors.As(err, &tokenErr) || tokenErr.Type != ErrModelRefreshFailed {
+		t.Errorf("RefreshModels() error = %v, want %s", err, ErrModelRefreshFailed)
+	}
+}
+
+func TestDefaultTokenTracker_StartModelRefresh(t *testing.T) {
+	tracker := NewTokenTracker(NewConfig())
+	tracker.RegisterProvider(&MockProvider{name: "mock", supportedModel: "mock-1"})
+
+	client := &mockListerClient{provider: "mock", listed: []string{"mock-2"}}
+	_ = tracker.RegisterSDKClient(client)
+
+	added := make(chan ModelEvent, 1)
+	tracker.OnModelAdded(func(event ModelEvent) {
+		added <- event
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tracker.StartModelRefresh(ctx, time.Hour, nil)
+
+	select {
+	case event := <-added:
+		if event.Model != "mock-2" {
+			t.Errorf("event.Model = %q, want mock-2", event.Model)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("StartModelRefresh() did not refresh immediately")
+	}
+}
@@ -2,19 +2,42 @@
 // for API calls to various LLM providers (Gemini, Claude, OpenAI).
 package tokentracker
 
-import "time"
+import (
+	"time"
+
+	"github.com/TrustSight-io/tokentracker/common"
+)
 
 // Message represents a chat message
 type Message struct {
 	Role    string      `json:"role"`
-	Content interface{} `json:"content"` // string or ContentPart array
+	Content interface{} `json:"content"`        // string or ContentPart array
+	Name    string      `json:"name,omitempty"` // participant name, counted by OpenAI
+
+	// ToolCalls are the tool calls an assistant message made
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+
+	// ToolCallID is the call a tool message returns the result of; the result is the Content
+	ToolCallID string `json:"tool_call_id,omitempty"`
 }
 
-// ContentPart represents a part of a message content (text or image)
+// Content part types
+const (
+	ContentTypeText  = "text"
+	ContentTypeImage = "image"
+	ContentTypeAudio = "audio"
+	ContentTypeVideo = "video"
+)
+
+// ContentPart represents a part of a message content (text, image, audio or video)
 type ContentPart struct {
 	Type  string      `json:"type"`
 	Text  string      `json:"text,omitempty"`
 	Image interface{} `json:"image,omitempty"`
+	Audio interface{} `json:"audio,omitempty"`
+	Video interface{} `json:"video,omitempty"`
+	// Duration is the length of audio and video content, which providers bill by
+	Duration time.Duration `json:"duration,omitempty"`
 }
 
 // Tool represents a function or tool definition
@@ -23,6 +46,13 @@ type Tool struct {
 	Function interface{} `json:"function,omitempty"`
 }
 
+// ToolCall is a tool or function call made by the model
+type ToolCall struct {
+	ID        string `json:"id,omitempty"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"` // JSON-encoded arguments
+}
+
 // ToolChoice represents a tool choice specification
 type ToolChoice struct {
 	Type     string      `json:"type,omitempty"`
@@ -37,36 +67,70 @@ type TokenCountParams struct {
 	Tools               []Tool
 	ToolChoice          *ToolChoice
 	CountResponseTokens bool
-}
 
-// TokenCount contains token counting results
-type TokenCount struct {
-	InputTokens    int
-	ResponseTokens int
-	TotalTokens    int
-}
+	// MaxTokens is the request's limit on response tokens (0 when unset), capping
+	// response estimates
+	MaxTokens int
 
-// Price contains pricing information
-type Price struct {
-	InputCost  float64
-	OutputCost float64
-	TotalCost  float64
-	Currency   string
+	// StopSequences are the request's stop sequences, which response estimates take
+	// into account
+	StopSequences []string
+
+	// SystemInstruction is a system prompt sent apart from Messages, as Gemini's
+	// systemInstruction. Providers that take system prompts as messages count it as a
+	// leading system message.
+	SystemInstruction string
 }
 
-// UsageMetrics contains complete usage information
-type UsageMetrics struct {
-	TokenCount TokenCount
-	Price      Price
-	Duration   time.Duration
-	Timestamp  time.Time
-	Model      string
-	Provider   string
+// WithSystemMessage returns params with SystemInstruction moved into a leading system
+// message, for providers that take system prompts as messages. A text prompt becomes
+// the user message following it. The caller's messages are not modified.
+func (p TokenCountParams) WithSystemMessage() TokenCountParams {
+	if p.SystemInstruction == "" {
+		return p
+	}
+	system := Message{Role: "system", Content: p.SystemInstruction}
+	if p.Text != nil {
+		p.Messages = []Message{system, {Role: "user", Content: *p.Text}}
+		p.Text = nil
+	} else {
+		messages := make([]Message, 0, len(p.Messages)+1)
+		messages = append(messages, system)
+		p.Messages = append(messages, p.Messages...)
+	}
+	p.SystemInstruction = ""
+	return p
 }
 
+// TokenCount contains token counting results, see common.TokenCount
+type TokenCount = common.TokenCount
+
+// Price contains pricing information, see common.Price
+type Price = common.Price
+
+// UsageMetrics contains complete usage information, see common.UsageMetrics
+type UsageMetrics = common.UsageMetrics
+
+// TokenUsage is the token usage an SDKClient extracts from API responses, see common.TokenUsage
+type TokenUsage = common.TokenUsage
+
 // CallParams contains parameters for an LLM call
 type CallParams struct {
 	Model     string
 	Params    TokenCountParams
 	StartTime time.Time
+	Tags      map[string]string // copied into the resulting UsageMetrics
+	UserID    string            // copied into the resulting UsageMetrics
+	ProjectID string            // copied into the resulting UsageMetrics
+	Batch     bool              // the call was made through a batch API and gets its discount
+
+	// APIKeyID, Organization and ProviderProjectID identify the provider account the
+	// call was billed to; they are copied into the resulting UsageMetrics
+	APIKeyID          string
+	Organization      string
+	ProviderProjectID string
+
+	// IdempotencyKey identifies the call for deduplication, see WithIdempotency
+	// (empty uses the completion or request ID of the response)
+	IdempotencyKey string
 }
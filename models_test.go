@@ -0,0 +1,29 @@
+package tokentracker
+
+import "testing"
+
+func TestTokenCountParams_WithSystemMessage(t *testing.T) {
+	messages := []Message{{Role: "user", Content: "Hello"}}
+	params := TokenCountParams{Model: "gpt-4o", SystemInstruction: "Answer briefly.", Messages: messages}
+
+	inlined := params.WithSystemMessage()
+	if inlined.SystemInstruction != "" || len(inlined.Messages) != 2 {
+		t.Fatalf("WithSystemMessage() = %+v, want the system instruction as a message", inlined)
+	}
+	if inlined.Messages[0].Role != "system" || inlined.Messages[0].Content != "Answer briefly." || inlined.Messages[1].Content != "Hello" {
+		t.Errorf("messages = %+v, want a leading system message", inlined.Messages)
+	}
+	if len(messages) != 1 || len(params.Messages) != 1 {
+		t.Error("WithSystemMessage() modified the caller's messages")
+	}
+
+	if got := (TokenCountParams{Messages: messages}).WithSystemMessage(); len(got.Messages) != 1 {
+		t.Errorf("WithSystemMessage() without a system instruction = %+v", got)
+	}
+
+	text := "Summarize this."
+	inlined = TokenCountParams{Model: "gpt-4o", SystemInstruction: "Answer briefly.", Text: &text}.WithSystemMessage()
+	if inlined.Text != nil || len(inlined.Messages) != 2 || inlined.Messages[0].Role != "system" || inlined.Messages[1].Role != "user" || inlined.Messages[1].Content != text {
+		t.Errorf("WithSystemMessage() of a text prompt = %+v, want a system and a user message", inlined)
+	}
+}
@@ -0,0 +1,172 @@
+package tokentracker
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizationOptions controls how input text is normalized before tokenization.
+// All options are off by default so counts match the raw input.
+type NormalizationOptions struct {
+	// UnicodeNFC converts text to Unicode Normalization Form C
+	UnicodeNFC bool `json:"unicode_nfc,omitempty"`
+	// NormalizeLineEndings converts CRLF and CR line endings to LF
+	NormalizeLineEndings bool `json:"normalize_line_endings,omitempty"`
+	// CollapseWhitespace replaces runs of spaces and tabs with a single space and trims trailing spaces on each line
+	CollapseWhitespace bool `json:"collapse_whitespace,omitempty"`
+	// TrimTrailingNewlines removes newlines at the end of the text
+	TrimTrailingNewlines bool `json:"trim_trailing_newlines,omitempty"`
+}
+
+// IsZero reports whether no normalization is enabled
+func (o NormalizationOptions) IsZero() bool {
+	return o == NormalizationOptions{}
+}
+
+// Profile returns a stable identifier of the enabled normalization steps (empty when disabled).
+// It is recorded on TokenCount results and used to scope cache keys.
+func (o NormalizationOptions) Profile() string {
+	var steps []string
+	if o.UnicodeNFC {
+		steps = append(steps, "nfc")
+	}
+	if o.NormalizeLineEndings {
+		steps = append(steps, "lf")
+	}
+	if o.CollapseWhitespace {
+		steps = append(steps, "ws")
+	}
+	if o.TrimTrailingNewlines {
+		steps = append(steps, "trim-nl")
+	}
+	return strings.Join(steps, "+")
+}
+
+// Apply normalizes a single text according to the options
+func (o NormalizationOptions) Apply(text string) string {
+	if o.IsZero() {
+		return text
+	}
+
+	if o.UnicodeNFC {
+		text = norm.NFC.String(text)
+	}
+
+	if o.NormalizeLineEndings {
+		text = strings.ReplaceAll(text, "\r\n", "\n")
+		text = strings.ReplaceAll(text, "\r", "\n")
+	}
+
+	if o.CollapseWhitespace {
+		text = collapseWhitespace(text)
+	}
+
+	if o.TrimTrailingNewlines {
+		text = strings.TrimRight(text, "\r\n")
+	}
+
+	return text
+}
+
+// ApplyToParams returns a copy of params with the text and message contents normalized.
+// The caller's messages are not modified.
+func (o NormalizationOptions) ApplyToParams(params TokenCountParams) TokenCountParams {
+	if o.IsZero() {
+		return params
+	}
+
+	if params.Text != nil {
+		text := o.Apply(*params.Text)
+		params.Text = &text
+	}
+
+	if params.SystemInstruction != "" {
+		params.SystemInstruction = o.Apply(params.SystemInstruction)
+	}
+
+	if len(params.Messages) > 0 {
+		messages := make([]Message, len(params.Messages))
+		for i, message := range params.Messages {
+			message.Content = o.applyToContent(message.Content)
+			messages[i] = message
+		}
+		params.Messages = messages
+	}
+
+	return params
+}
+
+// applyToContent normalizes the supported message content representations
+func (o NormalizationOptions) applyToContent(content interface{}) interface{} {
+	switch c := content.(type) {
+	case string:
+		return o.Apply(c)
+	case []ContentPart:
+		parts := make([]ContentPart, len(c))
+		for i, part := range c {
+			if part.Type == "text" {
+				part.Text = o.Apply(part.Text)
+			}
+			parts[i] = part
+		}
+		return parts
+	case []interface{}:
+		parts := make([]interface{}, len(c))
+		for i, partInterface := range c {
+			part, ok := partInterface.(map[string]interface{})
+			if !ok {
+				parts[i] = partInterface
+				continue
+			}
+			copied := make(map[string]interface{}, len(part))
+			for k, v := range part {
+				copied[k] = v
+			}
+			if text, ok := copied["text"].(string); ok {
+				copied["text"] = o.Apply(text)
+			}
+			parts[i] = copied
+		}
+		return parts
+	default:
+		return content
+	}
+}
+
+// collapseWhitespace replaces runs of horizontal whitespace with a single space
+// and strips trailing horizontal whitespace from every line
+func collapseWhitespace(text string) string {
+	var builder strings.Builder
+	builder.Grow(len(text))
+
+	pendingSpace := false
+	for _, r := range text {
+		switch {
+		case r == '\n':
+			pendingSpace = false
+			builder.WriteRune(r)
+		case unicode.IsSpace(r):
+			pendingSpace = true
+		default:
+			if pendingSpace {
+				builder.WriteByte(' ')
+				pendingSpace = false
+			}
+			builder.WriteRune(r)
+		}
+	}
+
+	return builder.String()
+}
+
+// CacheScope returns the cache key scope for a model under the given normalization,
+// so counts computed with different normalization profiles never share cache entries
+func CacheScope(model string, opts NormalizationOptions) string {
+	profile := opts.Profile()
+	if profile == "" {
+		return model
+	}
+	return model + "#" + profile
+}
@@ -0,0 +1,84 @@
+package tokentracker
+
+import "testing"
+
+func TestNormalizationOptions_Apply(t *testing.T) {
+	tests := []struct {
+		name string
+		opts NormalizationOptions
+		in   string
+		want string
+	}{
+		{"Disabled", NormalizationOptions{}, "a  b\r\n\n", "a  b\r\n\n"},
+		{"NFC", NormalizationOptions{UnicodeNFC: true}, "é", "é"},
+		{"Line endings", NormalizationOptions{NormalizeLineEndings: true}, "a\r\nb\rc", "a\nb\nc"},
+		{"Collapse whitespace", NormalizationOptions{CollapseWhitespace: true}, "a \t b   \nc  ", "a b\nc"},
+		{"Trim trailing newlines", NormalizationOptions{TrimTrailingNewlines: true}, "text\n\n", "text"},
+		{
+			"All",
+			NormalizationOptions{UnicodeNFC: true, NormalizeLineEndings: true, CollapseWhitespace: true, TrimTrailingNewlines: true},
+			"café  au\tlait\r\n\r\n",
+			"café au lait",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.Apply(tt.in); got != tt.want {
+				t.Errorf("Apply(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizationOptions_Profile(t *testing.T) {
+	if got := (NormalizationOptions{}).Profile(); got != "" {
+		t.Errorf("Profile() for disabled normalization = %q, want empty", got)
+	}
+
+	opts := NormalizationOptions{UnicodeNFC: true, TrimTrailingNewlines: true}
+	if got := opts.Profile(); got != "nfc+trim-nl" {
+		t.Errorf("Profile() = %q, want %q", got, "nfc+trim-nl")
+	}
+
+	if got := CacheScope("gpt-4", opts); got != "gpt-4#nfc+trim-nl" {
+		t.Errorf("CacheScope() = %q, want %q", got, "gpt-4#nfc+trim-nl")
+	}
+	if got := CacheScope("gpt-4", NormalizationOptions{}); got != "gpt-4" {
+		t.Errorf("CacheScope() without normalization = %q, want %q", got, "gpt-4")
+	}
+}
+
+func TestNormalizationOptions_ApplyToParams(t *testing.T) {
+	opts := NormalizationOptions{TrimTrailingNewlines: true}
+
+	original := []Message{
+		{Role: "user", Content: "hello\n"},
+		{Role: "user", Content: []ContentPart{{Type: "text", Text: "parts\n"}}},
+		{Role: "user", Content: []interface{}{map[string]interface{}{"type": "text", "text": "json\n"}}},
+	}
+	text := "plain\n"
+
+	params := opts.ApplyToParams(TokenCountParams{Model: "m", Text: &text, Messages: original})
+
+	if *params.Text != "plain" {
+		t.Errorf("Text = %q, want %q", *params.Text, "plain")
+	}
+	if params.Messages[0].Content != "hello" {
+		t.Errorf("string content = %q, want %q", params.Messages[0].Content, "hello")
+	}
+	if parts := params.Messages[1].Content.([]ContentPart); parts[0].Text != "parts" {
+		t.Errorf("content part = %q, want %q", parts[0].Text, "parts")
+	}
+	if parts := params.Messages[2].Content.([]interface{}); parts[0].(map[string]interface{})["text"] != "json" {
+		t.Errorf("json content part = %v, want %q", parts[0], "json")
+	}
+
+	// The caller's input is left untouched
+	if text != "plain\n" || original[0].Content != "hello\n" {
+		t.Error("ApplyToParams() modified the caller's input")
+	}
+	if original[1].Content.([]ContentPart)[0].Text != "parts\n" {
+		t.Error("ApplyToParams() modified the caller's content parts")
+	}
+}
@@ -0,0 +1,238 @@
+package tokentracker
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// openAIUsage is the usage block of OpenAI API responses
+type openAIUsage struct {
+	PromptTokens        int `json:"prompt_tokens"`
+	CompletionTokens    int `json:"completion_tokens"`
+	TotalTokens         int `json:"total_tokens"`
+	PromptTokensDetails struct {
+		CachedTokens int `json:"cached_tokens"`
+		AudioTokens  int `json:"audio_tokens"`
+	} `json:"prompt_tokens_details"`
+	CompletionTokensDetails struct {
+		ReasoningTokens int `json:"reasoning_tokens"`
+	} `json:"completion_tokens_details"`
+}
+
+// openAIResponse is the part of an OpenAI response (or streamed chunk) needed for tracking
+type openAIResponse struct {
+	Model string       `json:"model"`
+	Usage *openAIUsage `json:"usage"`
+}
+
+// openAIRequest is the part of an OpenAI request needed for tracking
+type openAIRequest struct {
+	Model    string    `json:"model"`
+	Messages []Message `json:"messages"`
+	Stream   bool      `json:"stream"`
+	User     string    `json:"user"`
+}
+
+// OpenAITransport is an http.RoundTripper that tracks the usage reported by OpenAI
+// chat completion, completion and embedding responses. Install it as the transport
+// of the HTTP client passed to the OpenAI SDK to track every call automatically.
+//
+// Streamed responses are tracked when the stream ends, provided the request sets
+// stream_options.include_usage so that OpenAI sends a usage chunk.
+type OpenAITransport struct {
+	// Base performs the requests (nil uses http.DefaultTransport)
+	Base http.RoundTripper
+
+	// Tracker receives the usage of every tracked call
+	Tracker *DefaultTokenTracker
+
+	// Tags are attached to every tracked call. The user field of requests becomes the
+	// UserID of the tracked usage, and the API key and the OpenAI-Organization and
+	// OpenAI-Project response headers attribute it to the billed account.
+	Tags map[string]string
+
+	// OnError receives tracking failures; they never fail the HTTP call (nil ignores them)
+	OnError func(error)
+}
+
+// NewOpenAITransport creates a transport tracking OpenAI calls made through base
+func NewOpenAITransport(tracker *DefaultTokenTracker, base http.RoundTripper) *OpenAITransport {
+	return &OpenAITransport{
+		Base:    base,
+		Tracker: tracker,
+	}
+}
+
+// RoundTrip performs the request and tracks the usage reported in the response
+func (t *OpenAITransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	if req.Method != http.MethodPost || !isTrackedOpenAIPath(req.URL.Path) || req.Body == nil {
+		return base.RoundTrip(req)
+	}
+
+	// Read the request body for the model, leaving it intact for the base transport
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	outgoing := req.Clone(req.Context())
+	outgoing.Body = io.NopCloser(bytes.NewReader(body))
+	outgoing.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+
+	var request openAIRequest
+	_ = json.Unmarshal(body, &request)
+
+	callParams := CallParams{
+		Model:     request.Model,
+		Params:    TokenCountParams{Model: request.Model, Messages: request.Messages},
+		StartTime: t.Tracker.clock().Now(),
+		Tags:      t.Tags,
+		UserID:    request.User,
+	}
+
+	resp, err := base.RoundTrip(outgoing)
+	if err != nil || resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp, err
+	}
+	attributeCall(&callParams, req.Header, resp.Header)
+
+	if request.Stream || strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		resp.Body = &openAIStreamBody{body: resp.Body, transport: t, req: req, params: callParams}
+		return resp, nil
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return resp, err
+	}
+
+	var parsed openAIResponse
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.reportError(NewError(ErrInvalidParams, "failed to parse OpenAI response", err))
+		return resp, nil
+	}
+	t.track(req, callParams, parsed)
+	return resp, nil
+}
+
+// track records the usage of a parsed response
+func (t *OpenAITransport) track(req *http.Request, callParams CallParams, resp openAIResponse) {
+	if resp.Usage == nil {
+		t.reportError(NewError(ErrInvalidParams, "usage information not found in response", nil))
+		return
+	}
+
+	// Price by the requested model, falling back to the snapshot named in the response
+	if _, known := t.Tracker.providerForModel(callParams.Model); !known && resp.Model != "" {
+		callParams.Model = resp.Model
+	}
+
+	_, err := t.Tracker.TrackReportedUsage(req.Context(), callParams, TokenCount{
+		InputTokens:       resp.Usage.PromptTokens,
+		ResponseTokens:    resp.Usage.CompletionTokens,
+		TotalTokens:       resp.Usage.TotalTokens,
+		CachedInputTokens: resp.Usage.PromptTokensDetails.CachedTokens,
+		AudioTokens:       resp.Usage.PromptTokensDetails.AudioTokens,
+		ReasoningTokens:   resp.Usage.CompletionTokensDetails.ReasoningTokens,
+	})
+	t.reportError(err)
+}
+
+// reportError passes a tracking failure to OnError
+func (t *OpenAITransport) reportError(err error) {
+	if err != nil && t.OnError != nil {
+		t.OnError(err)
+	}
+}
+
+// isTrackedOpenAIPath reports whether the API endpoint returns a usage block
+func isTrackedOpenAIPath(path string) bool {
+	for _, suffix := range []string{"/chat/completions", "/completions", "/embeddings"} {
+		if strings.HasSuffix(path, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// openAIStreamBody passes a server-sent event stream through, tracking the usage
+// chunk once the stream has been read to the end or closed
+type openAIStreamBody struct {
+	body      io.ReadCloser
+	transport *OpenAITransport
+	req       *http.Request
+	params    CallParams
+	pending   []byte
+	last      openAIResponse
+	done      bool
+}
+
+// Read reads from the stream, scanning complete events for usage
+func (b *openAIStreamBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	b.scan(p[:n])
+	if err == io.EOF {
+		b.finish()
+	}
+	return n, err
+}
+
+// Close closes the stream, tracking the usage seen so far
+func (b *openAIStreamBody) Close() error {
+	b.finish()
+	return b.body.Close()
+}
+
+// scan parses the complete "data:" lines of the stream
+func (b *openAIStreamBody) scan(data []byte) {
+	b.pending = append(b.pending, data...)
+	for {
+		i := bytes.IndexByte(b.pending, '\n')
+		if i < 0 {
+			return
+		}
+		line := bytes.TrimSpace(b.pending[:i])
+		b.pending = b.pending[i+1:]
+
+		payload, ok := bytes.CutPrefix(line, []byte("data:"))
+		if !ok {
+			continue
+		}
+		payload = bytes.TrimSpace(payload)
+		if bytes.Equal(payload, []byte("[DONE]")) {
+			continue
+		}
+
+		var chunk openAIResponse
+		if json.Unmarshal(payload, &chunk) != nil {
+			continue
+		}
+		if chunk.Model != "" {
+			b.last.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			b.last.Usage = chunk.Usage
+		}
+	}
+}
+
+// finish tracks the stream's usage once
+func (b *openAIStreamBody) finish() {
+	if b.done {
+		return
+	}
+	b.done = true
+	b.transport.track(b.req, b.params, b.last)
+}
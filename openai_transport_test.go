@@ -0,0 +1,128 @@
+package tokentracker
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTransportTracker() (*DefaultTokenTracker, *MemoryUsageStore) {
+	store := NewMemoryUsageStore()
+	tracker := NewTokenTracker(NewConfig(), WithUsageStore(store))
+	tracker.RegisterProvider(&MockProvider{
+		name:           "openai",
+		supportedModel: "gpt-4o",
+		price:          Price{TotalCost: 0.02, Currency: "USD"},
+	})
+	return tracker, store
+}
+
+func TestOpenAITransport_TracksResponses(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"chatcmpl-1","model":"gpt-4o-2024-08-06","choices":[],"usage":{"prompt_tokens":25,"completion_tokens":8,"total_tokens":33,"completion_tokens_details":{"reasoning_tokens":5}}}`)
+	}))
+	defer server.Close()
+
+	tracker, store := newTransportTracker()
+	transport := NewOpenAITransport(tracker, nil)
+	transport.Tags = map[string]string{"service": "search"}
+	client := &http.Client{Transport: transport}
+
+	request := `{"model":"gpt-4o","messages":[{"role":"user","content":"Hi"}],"user":"user-1"}`
+	resp, err := client.Post(server.URL+"/v1/chat/completions", "application/json", strings.NewReader(request))
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if received != request {
+		t.Errorf("server received %q, want the original request body", received)
+	}
+	if !strings.Contains(string(body), `"chatcmpl-1"`) {
+		t.Errorf("client received %q, want the original response body", body)
+	}
+
+	records, _ := store.Query(UsageFilter{})
+	if len(records) != 1 {
+		t.Fatalf("tracked %d calls, want 1", len(records))
+	}
+	record := records[0]
+	if record.Model != "gpt-4o" || record.Provider != "openai" {
+		t.Errorf("record = %s/%s, want openai/gpt-4o", record.Provider, record.Model)
+	}
+	if record.TokenCount.InputTokens != 25 || record.TokenCount.ResponseTokens != 8 || record.TokenCount.TotalTokens != 33 || record.TokenCount.ReasoningTokens != 5 {
+		t.Errorf("TokenCount = %+v, want the reported usage", record.TokenCount)
+	}
+	if record.Price.TotalCost != 0.02 || record.Tags["service"] != "search" || record.UserID != "user-1" {
+		t.Errorf("record = %+v", record)
+	}
+
+	// Other endpoints pass through untracked
+	resp, _ = client.Post(server.URL+"/v1/files", "application/json", strings.NewReader("{}"))
+	resp.Body.Close()
+	if records, _ := store.Query(UsageFilter{}); len(records) != 1 {
+		t.Errorf("tracked %d calls after a files request, want 1", len(records))
+	}
+}
+
+func TestOpenAITransport_TracksStreams(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"model\":\"gpt-4o\",\"choices\":[{\"delta\":{\"content\":\"Hel\"}}],\"usage\":null}\n\n")
+		fmt.Fprint(w, "data: {\"model\":\"gpt-4o\",\"choices\":[{\"delta\":{\"content\":\"lo\"}}],\"usage\":null}\n\n")
+		fmt.Fprint(w, "data: {\"model\":\"gpt-4o\",\"choices\":[],\"usage\":{\"prompt_tokens\":12,\"completion_tokens\":2,\"total_tokens\":14}}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	tracker, store := newTransportTracker()
+	client := &http.Client{Transport: NewOpenAITransport(tracker, nil)}
+
+	resp, err := client.Post(server.URL+"/v1/chat/completions", "application/json",
+		strings.NewReader(`{"model":"gpt-4o","stream":true,"stream_options":{"include_usage":true}}`))
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+
+	if records, _ := store.Query(UsageFilter{}); len(records) != 0 {
+		t.Fatal("stream was tracked before it was read")
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	records, _ := store.Query(UsageFilter{})
+	if len(records) != 1 || records[0].TokenCount.InputTokens != 12 || records[0].TokenCount.ResponseTokens != 2 {
+		t.Errorf("records = %+v, want the streamed usage tracked once", records)
+	}
+}
+
+func TestOpenAITransport_ReportsErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"model":"unknown-model","usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`)
+	}))
+	defer server.Close()
+
+	tracker, _ := newTransportTracker()
+	var errs []error
+	transport := NewOpenAITransport(tracker, nil)
+	transport.OnError = func(err error) { errs = append(errs, err) }
+	client := &http.Client{Transport: transport}
+
+	resp, err := client.Post(server.URL+"/v1/chat/completions", "application/json", strings.NewReader(`{"model":"unknown-model"}`))
+	if err != nil {
+		t.Fatalf("Post() error = %v, want tracking failures to leave the call intact", err)
+	}
+	resp.Body.Close()
+
+	if len(errs) != 1 {
+		t.Errorf("OnError received %v, want one pricing error", errs)
+	}
+}
@@ -0,0 +1,271 @@
+package tokentracker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"time"
+)
+
+// Parquet physical types, converted types and encodings used by the writer
+const (
+	parquetInt64     int32 = 2
+	parquetDouble    int32 = 5
+	parquetByteArray int32 = 6
+
+	parquetUTF8            int32 = 0
+	parquetTimestampMillis int32 = 9
+
+	parquetPlain int32 = 0
+	parquetRLE   int32 = 3
+)
+
+// parquetMagic starts and ends every Parquet file
+const parquetMagic = "PAR1"
+
+// parquetColumn is a required column of a flat Parquet table
+type parquetColumn struct {
+	name          string
+	physicalType  int32
+	convertedType int32 // -1 for none
+	data          bytes.Buffer
+}
+
+// writeUsageParquet writes records as an uncompressed Parquet file with one row group
+// and one PLAIN encoded page per column. Timestamps are milliseconds since the epoch in
+// UTC and strings are UTF-8; every column is required, so empty values are written as
+// empty strings and zeros.
+func writeUsageParquet(w io.Writer, records []UsageMetrics) error {
+	columns := make([]*parquetColumn, len(UsageCSVHeader))
+	for i, value := range usageRow(UsageMetrics{}) {
+		column := &parquetColumn{name: UsageCSVHeader[i], convertedType: -1}
+		switch value.(type) {
+		case time.Time:
+			column.physicalType, column.convertedType = parquetInt64, parquetTimestampMillis
+		case int64:
+			column.physicalType = parquetInt64
+		case float64:
+			column.physicalType = parquetDouble
+		case string:
+			column.physicalType, column.convertedType = parquetByteArray, parquetUTF8
+		}
+		columns[i] = column
+	}
+
+	var scratch [8]byte
+	for _, record := range records {
+		for i, value := range usageRow(record) {
+			data := &columns[i].data
+			switch v := value.(type) {
+			case time.Time:
+				binary.LittleEndian.PutUint64(scratch[:], uint64(v.UnixMilli()))
+				data.Write(scratch[:])
+			case int64:
+				binary.LittleEndian.PutUint64(scratch[:], uint64(v))
+				data.Write(scratch[:])
+			case float64:
+				binary.LittleEndian.PutUint64(scratch[:], math.Float64bits(v))
+				data.Write(scratch[:])
+			case string:
+				binary.LittleEndian.PutUint32(scratch[:4], uint32(len(v)))
+				data.Write(scratch[:4])
+				data.WriteString(v)
+			}
+		}
+	}
+
+	var file bytes.Buffer
+	file.WriteString(parquetMagic)
+
+	rows := int64(len(records))
+	offsets := make([]int64, len(columns))
+	sizes := make([]int64, len(columns))
+	if rows > 0 {
+		for i, column := range columns {
+			header := newThriftCompact()
+			header.i32Field(1, 0) // DATA_PAGE
+			header.i32Field(2, int32(column.data.Len()))
+			header.i32Field(3, int32(column.data.Len()))
+			header.structField(5)
+			header.i32Field(1, int32(rows))
+			header.i32Field(2, parquetPlain)
+			header.i32Field(3, parquetRLE)
+			header.i32Field(4, parquetRLE)
+			header.endStruct()
+			header.endStruct()
+
+			offsets[i] = int64(file.Len())
+			file.Write(header.buf.Bytes())
+			file.Write(column.data.Bytes())
+			sizes[i] = int64(file.Len()) - offsets[i]
+		}
+	}
+
+	meta := newThriftCompact()
+	meta.i32Field(1, 1)
+	meta.listField(2, thriftStruct, len(columns)+1)
+	meta.beginStruct()
+	meta.stringField(4, "schema")
+	meta.i32Field(5, int32(len(columns)))
+	meta.endStruct()
+	for _, column := range columns {
+		meta.beginStruct()
+		meta.i32Field(1, column.physicalType)
+		meta.i32Field(3, 0) // REQUIRED
+		meta.stringField(4, column.name)
+		if column.convertedType >= 0 {
+			meta.i32Field(6, column.convertedType)
+		}
+		meta.endStruct()
+	}
+	meta.i64Field(3, rows)
+
+	if rows == 0 {
+		meta.listField(4, thriftStruct, 0)
+	} else {
+		var total int64
+		for _, size := range sizes {
+			total += size
+		}
+
+		meta.listField(4, thriftStruct, 1)
+		meta.beginStruct()
+		meta.listField(1, thriftStruct, len(columns))
+		for i, column := range columns {
+			meta.beginStruct()
+			meta.i64Field(2, offsets[i])
+			meta.structField(3)
+			meta.i32Field(1, column.physicalType)
+			meta.listField(2, thriftI32, 2)
+			meta.i32Elem(parquetPlain)
+			meta.i32Elem(parquetRLE)
+			meta.listField(3, thriftBinary, 1)
+			meta.stringElem(column.name)
+			meta.i32Field(4, 0) // UNCOMPRESSED
+			meta.i64Field(5, rows)
+			meta.i64Field(6, sizes[i])
+			meta.i64Field(7, sizes[i])
+			meta.i64Field(9, offsets[i])
+			meta.endStruct()
+			meta.endStruct()
+		}
+		meta.i64Field(2, total)
+		meta.i64Field(3, rows)
+		meta.endStruct()
+	}
+	meta.stringField(6, "tokentracker")
+	meta.endStruct()
+
+	file.Write(meta.buf.Bytes())
+	binary.LittleEndian.PutUint32(scratch[:4], uint32(meta.buf.Len()))
+	file.Write(scratch[:4])
+	file.WriteString(parquetMagic)
+
+	if _, err := w.Write(file.Bytes()); err != nil {
+		return NewError(ErrStorageFailed, "failed to write usage export", err)
+	}
+	return nil
+}
+
+// Thrift compact protocol types used by Parquet metadata
+const (
+	thriftI32    byte = 5
+	thriftI64    byte = 6
+	thriftBinary byte = 8
+	thriftList   byte = 9
+	thriftStruct byte = 12
+)
+
+// thriftCompact encodes structs with the Thrift compact protocol
+type thriftCompact struct {
+	buf bytes.Buffer
+
+	// last holds the last field id written in each open struct
+	last []int16
+}
+
+// newThriftCompact starts encoding a top-level struct
+func newThriftCompact() *thriftCompact {
+	return &thriftCompact{last: []int16{0}}
+}
+
+// field writes a field header, as a delta from the previous field when it fits
+func (c *thriftCompact) field(id int16, typ byte) {
+	last := &c.last[len(c.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		c.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		c.buf.WriteByte(typ)
+		c.varint(int64(id))
+	}
+	*last = id
+}
+
+// varint writes a zigzag encoded integer
+func (c *thriftCompact) varint(v int64) {
+	c.uvarint(uint64(v<<1) ^ uint64(v>>63))
+}
+
+// uvarint writes an unsigned integer
+func (c *thriftCompact) uvarint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	c.buf.Write(buf[:binary.PutUvarint(buf[:], v)])
+}
+
+// i32Field writes an i32 field
+func (c *thriftCompact) i32Field(id int16, v int32) {
+	c.field(id, thriftI32)
+	c.varint(int64(v))
+}
+
+// i64Field writes an i64 field
+func (c *thriftCompact) i64Field(id int16, v int64) {
+	c.field(id, thriftI64)
+	c.varint(v)
+}
+
+// stringField writes a string field
+func (c *thriftCompact) stringField(id int16, s string) {
+	c.field(id, thriftBinary)
+	c.stringElem(s)
+}
+
+// structField starts a struct field, closed with endStruct
+func (c *thriftCompact) structField(id int16) {
+	c.field(id, thriftStruct)
+	c.beginStruct()
+}
+
+// listField writes the header of a list field of n elements
+func (c *thriftCompact) listField(id int16, elem byte, n int) {
+	c.field(id, thriftList)
+	if n < 15 {
+		c.buf.WriteByte(byte(n)<<4 | elem)
+		return
+	}
+	c.buf.WriteByte(0xf0 | elem)
+	c.uvarint(uint64(n))
+}
+
+// i32Elem writes an i32 list element
+func (c *thriftCompact) i32Elem(v int32) {
+	c.varint(int64(v))
+}
+
+// stringElem writes a string list element
+func (c *thriftCompact) stringElem(s string) {
+	c.uvarint(uint64(len(s)))
+	c.buf.WriteString(s)
+}
+
+// beginStruct starts a struct list element
+func (c *thriftCompact) beginStruct() {
+	c.last = append(c.last, 0)
+}
+
+// endStruct closes the innermost struct
+func (c *thriftCompact) endStruct() {
+	c.buf.WriteByte(0)
+	c.last = c.last[:len(c.last)-1]
+}
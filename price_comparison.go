@@ -0,0 +1,85 @@
+package tokentracker
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// PriceComparison is the token count and price of a prompt with one model
+type PriceComparison struct {
+	Model      string
+	Provider   string
+	TokenCount TokenCount
+	Price      Price
+	Err        error // why the model could not be priced; the other fields are zero
+}
+
+// ComparePrices counts the prompt of params with the tokenizer of each model's provider
+// and prices it, for routing a prompt to the cheapest model. With CountResponseTokens
+// set, the providers' response estimates are priced too. The comparisons are sorted
+// cheapest first, followed by the models that could not be priced, with the reason.
+func (t *DefaultTokenTracker) ComparePrices(params TokenCountParams, models []string) ([]PriceComparison, error) {
+	return t.ComparePricesCtx(context.Background(), params, models)
+}
+
+// ComparePricesCtx compares the prices of a prompt, propagating cancellation and
+// deadlines to the providers
+func (t *DefaultTokenTracker) ComparePricesCtx(ctx context.Context, params TokenCountParams, models []string) ([]PriceComparison, error) {
+	if len(models) == 0 {
+		return nil, NewError(ErrInvalidParams, "at least one model is required", nil)
+	}
+
+	comparisons := make([]PriceComparison, 0, len(models))
+	currency := ""
+	for _, model := range models {
+		comparison := t.comparePrice(ctx, params, model)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if comparison.Err == nil {
+			if currency == "" {
+				currency = comparison.Price.Currency
+			} else if comparison.Price.Currency != currency {
+				return nil, NewError(ErrCurrencyConversion, fmt.Sprintf("prices in %s and %s cannot be compared; set a default currency", currency, comparison.Price.Currency), nil)
+			}
+		}
+		comparisons = append(comparisons, comparison)
+	}
+
+	sort.SliceStable(comparisons, func(i, j int) bool {
+		if (comparisons[i].Err == nil) != (comparisons[j].Err == nil) {
+			return comparisons[i].Err == nil
+		}
+		return comparisons[i].Price.TotalCost < comparisons[j].Price.TotalCost
+	})
+	return comparisons, nil
+}
+
+// comparePrice counts and prices a prompt with one model
+func (t *DefaultTokenTracker) comparePrice(ctx context.Context, params TokenCountParams, model string) PriceComparison {
+	comparison := PriceComparison{Model: model}
+
+	provider, exists := t.providerForModel(model)
+	if !exists {
+		comparison.Err = NewError(ErrProviderNotFound, fmt.Sprintf("no provider found for model: %s", model), nil)
+		return comparison
+	}
+
+	params.Model = model
+	count, err := t.CountTokensCtx(ctx, params)
+	if err != nil {
+		comparison.Err = err
+		return comparison
+	}
+	price, err := t.CalculateUsagePrice(model, billableUsage(count, false))
+	if err != nil {
+		comparison.Err = err
+		return comparison
+	}
+
+	comparison.Provider = provider.Name()
+	comparison.TokenCount = count
+	comparison.Price = price
+	return comparison
+}
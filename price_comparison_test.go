@@ -0,0 +1,82 @@
+package tokentracker
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+)
+
+// newComparisonTracker creates a tracker with two providers counting the same prompt differently
+func newComparisonTracker(t *testing.T) *DefaultTokenTracker {
+	tracker := newPricedTestTracker(t)
+	config := tracker.Config()
+	config.SetModelPricing("acme", "acme-1", ModelPricing{InputPricePerToken: 0.00001, OutputPricePerToken: 0.00003, Currency: "USD"})
+	config.SetModelPricing("zeta", "zeta-1", ModelPricing{InputPricePerToken: 0.000008, OutputPricePerToken: 0.00002, Currency: "USD"})
+
+	tracker.RegisterProvider(&estimatingProvider{
+		usagePricedProvider: newPricedProvider(config, "acme", "acme-1"),
+		count:               TokenCount{InputTokens: 1000, ResponseTokens: 200},
+	})
+	tracker.RegisterProvider(&estimatingProvider{
+		usagePricedProvider: newPricedProvider(config, "zeta", "zeta-1"),
+		count:               TokenCount{InputTokens: 1500, ResponseTokens: 300},
+	})
+	return tracker
+}
+
+func TestDefaultTokenTracker_ComparePrices(t *testing.T) {
+	tracker := newComparisonTracker(t)
+	params := TokenCountParams{Text: stringPtr("Summarize this document"), CountResponseTokens: true}
+
+	comparisons, err := tracker.ComparePrices(params, []string{"zeta-1", "unknown", "acme-1"})
+	if err != nil {
+		t.Fatalf("ComparePrices() error = %v", err)
+	}
+	if len(comparisons) != 3 {
+		t.Fatalf("ComparePrices() = %d comparisons, want 3", len(comparisons))
+	}
+
+	acme, zeta, unknown := comparisons[0], comparisons[1], comparisons[2]
+	if acme.Model != "acme-1" || acme.Provider != "acme" || acme.TokenCount.InputTokens != 1000 {
+		t.Errorf("cheapest = %+v, want acme-1 with its own token count", acme)
+	}
+	if want := 1000*0.00001 + 200*0.00003; math.Abs(acme.Price.TotalCost-want) > 1e-12 {
+		t.Errorf("acme-1 TotalCost = %v, want %v", acme.Price.TotalCost, want)
+	}
+	if want := 1500*0.000008 + 300*0.00002; zeta.Model != "zeta-1" || math.Abs(zeta.Price.TotalCost-want) > 1e-12 {
+		t.Errorf("second = %+v, want zeta-1 at %v", zeta, want)
+	}
+
+	var trackerErr *TokenTrackerError
+	if unknown.Model != "unknown" || !errors.As(unknown.Err, &trackerErr) || trackerErr.Type != ErrProviderNotFound {
+		t.Errorf("last = %+v, want unknown with %s", unknown, ErrProviderNotFound)
+	}
+
+	// Without response estimates only the prompt is priced
+	comparisons, _ = tracker.ComparePrices(TokenCountParams{Text: stringPtr("Hi")}, []string{"acme-1"})
+	if comparisons[0].TokenCount.ResponseTokens != 0 || math.Abs(comparisons[0].Price.TotalCost-0.01) > 1e-12 {
+		t.Errorf("prompt-only comparison = %+v", comparisons[0])
+	}
+}
+
+func TestDefaultTokenTracker_ComparePricesErrors(t *testing.T) {
+	tracker := newComparisonTracker(t)
+	params := TokenCountParams{Text: stringPtr("Hi")}
+
+	if _, err := tracker.ComparePrices(params, nil); err == nil {
+		t.Error("ComparePrices() without models should fail")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := tracker.ComparePricesCtx(ctx, params, []string{"acme-1"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("ComparePricesCtx() error = %v, want context.Canceled", err)
+	}
+
+	tracker.config.SetModelPricing("zeta", "zeta-1", ModelPricing{InputPricePerToken: 0.000008, Currency: "EUR"})
+	var trackerErr *TokenTrackerError
+	if _, err := tracker.ComparePrices(params, []string{"acme-1", "zeta-1"}); !errors.As(err, &trackerErr) || trackerErr.Type != ErrCurrencyConversion {
+		t.Errorf("ComparePrices() error = %v, want %s", err, ErrCurrencyConversion)
+	}
+}
@@ -0,0 +1,120 @@
+package tokentracker
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Sources of pricing changes
+const (
+	PricingChangeSourceProvider = "provider"       // the providers' UpdatePricing
+	PricingChangeSourceFeed     = "pricing_source" // the tracker's PricingSource
+)
+
+// PricingChange is a change of a model's rates made by a pricing update
+type PricingChange struct {
+	Provider  string       `json:"provider"`
+	Model     string       `json:"model"`
+	Old       ModelPricing `json:"old"` // zero when the model was added
+	New       ModelPricing `json:"new"`
+	Added     bool         `json:"added,omitempty"`
+	ChangedAt time.Time    `json:"changed_at"`
+	Source    string       `json:"source"`
+}
+
+// pricingAudit records the pricing changes made by pricing updates
+type pricingAudit struct {
+	changes   map[metricLabels][]PricingChange
+	listeners []func(PricingChange)
+	updating  sync.Mutex // serializes updates, so changes are attributed to one source
+	mu        sync.Mutex
+}
+
+// newPricingAudit creates an empty audit trail
+func newPricingAudit() *pricingAudit {
+	return &pricingAudit{changes: make(map[metricLabels][]PricingChange)}
+}
+
+// auditPricing runs a pricing update and records the rates it changed, including
+// those changed before a partial failure, then invokes the pricing change callbacks
+func (t *DefaultTokenTracker) auditPricing(source string, update func() error) error {
+	t.pricingLog.updating.Lock()
+	defer t.pricingLog.updating.Unlock()
+
+	before := t.config.PricingSnapshot(time.Time{})
+	err := update()
+	after := t.config.PricingSnapshot(time.Time{})
+
+	changes := diffPricing(before, after, source, t.clock().Now())
+	t.logger().Debug("pricing updated", "source", source, "changes", len(changes))
+	if len(changes) == 0 {
+		return err
+	}
+
+	t.pricingLog.mu.Lock()
+	for _, change := range changes {
+		key := metricLabels{provider: change.Provider, model: change.Model}
+		t.pricingLog.changes[key] = append(t.pricingLog.changes[key], change)
+	}
+	listeners := append([]func(PricingChange){}, t.pricingLog.listeners...)
+	t.pricingLog.mu.Unlock()
+
+	logger := t.logger()
+	for _, change := range changes {
+		logger.Debug("pricing changed", "provider", change.Provider, "model", change.Model, "source", change.Source,
+			"added", change.Added, "input_price", change.New.InputPricePerToken, "output_price", change.New.OutputPricePerToken)
+		for _, listener := range listeners {
+			listener(change)
+		}
+	}
+	return err
+}
+
+// diffPricing returns the changes between two snapshots, sorted by provider and model
+func diffPricing(before, after PricingSnapshot, source string, now time.Time) []PricingChange {
+	var changes []PricingChange
+	for provider, providerConfig := range after.Providers {
+		for model, pricing := range providerConfig.Models {
+			old, existed := before.Providers[provider].Models[model]
+			if existed && reflect.DeepEqual(old, pricing) {
+				continue
+			}
+			changes = append(changes, PricingChange{
+				Provider:  provider,
+				Model:     model,
+				Old:       old,
+				New:       pricing,
+				Added:     !existed,
+				ChangedAt: now,
+				Source:    source,
+			})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Provider != changes[j].Provider {
+			return changes[i].Provider < changes[j].Provider
+		}
+		return changes[i].Model < changes[j].Model
+	})
+	return changes
+}
+
+// GetPricingHistory returns the changes pricing updates made to the rates of a model,
+// oldest first
+func (t *DefaultTokenTracker) GetPricingHistory(provider, model string) []PricingChange {
+	t.pricingLog.mu.Lock()
+	defer t.pricingLog.mu.Unlock()
+
+	return append([]PricingChange(nil), t.pricingLog.changes[metricLabels{provider: provider, model: model}]...)
+}
+
+// OnPricingChange registers a callback invoked for every rate a pricing update changes
+func (t *DefaultTokenTracker) OnPricingChange(callback func(PricingChange)) {
+	t.pricingLog.mu.Lock()
+	defer t.pricingLog.mu.Unlock()
+
+	t.pricingLog.listeners = append(t.pricingLog.listeners, callback)
+}
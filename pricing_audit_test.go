@@ -0,0 +1,87 @@
+package tokentracker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker/tokentrackertest"
+)
+
+// repricingProvider sets the pricing of its model on every pricing update
+type repricingProvider struct {
+	MockSimpleProvider
+	config  *Config
+	pricing ModelPricing
+}
+
+func (p *repricingProvider) UpdatePricing() error {
+	p.config.SetModelPricing(p.name, "acme-1", p.pricing)
+	return nil
+}
+
+func TestDefaultTokenTracker_PricingHistory(t *testing.T) {
+	clock := tokentrackertest.NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	config := NewConfig()
+	config.SetClock(clock)
+	config.SetModelPricing("acme", "acme-1", ModelPricing{InputPricePerToken: 0.00001, OutputPricePerToken: 0.00002, Currency: "USD"})
+
+	provider := &repricingProvider{
+		MockSimpleProvider: MockSimpleProvider{name: "acme", supportedModels: map[string]bool{"acme-1": true}},
+		config:             config,
+		pricing:            ModelPricing{InputPricePerToken: 0.00001, OutputPricePerToken: 0.00002, Currency: "USD"},
+	}
+	tracker := NewTokenTracker(config)
+	tracker.RegisterProvider(provider)
+
+	var notified []PricingChange
+	tracker.OnPricingChange(func(change PricingChange) { notified = append(notified, change) })
+
+	// Unchanged rates are not recorded
+	if err := tracker.UpdateAllPricing(); err != nil {
+		t.Fatalf("UpdateAllPricing() error = %v", err)
+	}
+	if history := tracker.GetPricingHistory("acme", "acme-1"); len(history) != 0 {
+		t.Errorf("GetPricingHistory() = %+v, want no changes", history)
+	}
+
+	clock.Advance(time.Hour)
+	provider.pricing = ModelPricing{InputPricePerToken: 0.000005, OutputPricePerToken: 0.00002, Currency: "USD"}
+	if err := tracker.UpdateAllPricing(); err != nil {
+		t.Fatalf("UpdateAllPricing() error = %v", err)
+	}
+
+	history := tracker.GetPricingHistory("acme", "acme-1")
+	if len(history) != 1 {
+		t.Fatalf("GetPricingHistory() returned %d changes, want 1", len(history))
+	}
+	change := history[0]
+	if change.Old.InputPricePerToken != 0.00001 || change.New.InputPricePerToken != 0.000005 {
+		t.Errorf("change = %+v, want the old and new input price", change)
+	}
+	if change.Added || change.Source != PricingChangeSourceProvider || !change.ChangedAt.Equal(clock.Now()) {
+		t.Errorf("change = %+v, want a provider change at %v", change, clock.Now())
+	}
+	if len(notified) != 1 || notified[0].Model != "acme-1" {
+		t.Errorf("OnPricingChange callbacks = %+v, want the change", notified)
+	}
+}
+
+func TestDefaultTokenTracker_PricingHistoryFromSource(t *testing.T) {
+	config := NewConfig()
+	manifest := PricingManifest{Providers: map[string]ProviderConfig{
+		"acme": {Models: map[string]ModelPricing{"acme-2": {InputPricePerToken: 0.00001, OutputPricePerToken: 0.00002, Currency: "USD"}}},
+	}}
+	tracker := NewTokenTracker(config, WithPricingSource(PricingSourceFunc(func(context.Context) (PricingManifest, error) {
+		return manifest, nil
+	})))
+
+	if err := tracker.UpdateAllPricing(); err != nil {
+		t.Fatalf("UpdateAllPricing() error = %v", err)
+	}
+
+	history := tracker.GetPricingHistory("acme", "acme-2")
+	if len(history) != 1 || !history[0].Added || history[0].Source != PricingChangeSourceFeed {
+		t.Errorf("GetPricingHistory() = %+v, want the added model from the pricing source", history)
+	}
+}
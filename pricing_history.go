@@ -0,0 +1,197 @@
+package tokentracker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// PricePeriod is the pricing of a model during a date range
+type PricePeriod struct {
+	EffectiveFrom  time.Time    `json:"effective_from"`            // inclusive
+	EffectiveUntil time.Time    `json:"effective_until,omitempty"` // exclusive; zero for no end
+	Pricing        ModelPricing `json:"pricing"`
+}
+
+// Contains reports whether the pricing was in effect at the given time
+func (p PricePeriod) Contains(at time.Time) bool {
+	if at.Before(p.EffectiveFrom) {
+		return false
+	}
+	return p.EffectiveUntil.IsZero() || at.Before(p.EffectiveUntil)
+}
+
+// PricingHistory holds the price periods of models by provider and model
+type PricingHistory map[string]map[string][]PricePeriod
+
+// validatePricePeriods sorts periods by start and checks that they do not overlap
+func validatePricePeriods(provider, model string, periods []PricePeriod) ([]PricePeriod, error) {
+	sorted := append([]PricePeriod(nil), periods...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].EffectiveFrom.Before(sorted[j].EffectiveFrom)
+	})
+
+	for i, period := range sorted {
+		if period.EffectiveFrom.IsZero() {
+			return nil, NewError(ErrInvalidParams, fmt.Sprintf("price period of %s/%s has no start date", provider, model), nil)
+		}
+		if !period.EffectiveUntil.IsZero() && !period.EffectiveUntil.After(period.EffectiveFrom) {
+			return nil, NewError(ErrInvalidParams, fmt.Sprintf("price period of %s/%s ends before it starts", provider, model), nil)
+		}
+		if period.Pricing.InputPricePerToken < 0 || period.Pricing.OutputPricePerToken < 0 {
+			return nil, NewError(ErrInvalidParams, fmt.Sprintf("negative price in price period of %s/%s", provider, model), nil)
+		}
+		if i > 0 {
+			previous := sorted[i-1]
+			if previous.EffectiveUntil.IsZero() || previous.EffectiveUntil.After(period.EffectiveFrom) {
+				return nil, NewError(ErrInvalidParams, fmt.Sprintf("price periods of %s/%s overlap at %s", provider, model, period.EffectiveFrom.Format(time.RFC3339)), nil)
+			}
+		}
+	}
+	return sorted, nil
+}
+
+// SetPricingHistory replaces the price periods of a model. Periods must have a start
+// date and must not overlap; the last one may be open-ended.
+func (c *Config) SetPricingHistory(provider, model string, periods []PricePeriod) error {
+	sorted, err := validatePricePeriods(provider, model, periods)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	providerConfig, exists := c.Providers[provider]
+	if !exists {
+		providerConfig = ProviderConfig{Models: make(map[string]ModelPricing)}
+	}
+	if providerConfig.History == nil {
+		providerConfig.History = make(map[string][]PricePeriod)
+	}
+	providerConfig.History[model] = sorted
+	c.Providers[provider] = providerConfig
+	return nil
+}
+
+// AddPricePeriod adds a price period to the history of a model, e.g. an announced
+// price change taking effect on a future date
+func (c *Config) AddPricePeriod(provider, model string, period PricePeriod) error {
+	return c.SetPricingHistory(provider, model, append(c.GetPricingHistory(provider, model), period))
+}
+
+// GetPricingHistory returns the price periods of a model sorted by start date
+func (c *Config) GetPricingHistory(provider, model string) []PricePeriod {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return append([]PricePeriod(nil), c.Providers[provider].History[model]...)
+}
+
+// GetModelPricingAt returns the pricing of a model in effect at the given time: the
+// price period containing it, or else the current pricing. Models without pricing of
+// their own use the pricing of their model catalog entry's PricingModel.
+func (c *Config) GetModelPricingAt(provider, model string, at time.Time) (ModelPricing, bool) {
+	if pricing, exists := c.historicalPricing(provider, model, at); exists {
+		return pricing, true
+	}
+	if pricingModel, ok := c.catalogPricingModel(provider, model); ok {
+		if pricing, exists := c.historicalPricing(provider, pricingModel, at); exists {
+			return pricing, true
+		}
+	}
+	return c.GetModelPricing(provider, model)
+}
+
+// historicalPricing returns the pricing of the price period of a model containing at
+func (c *Config) historicalPricing(provider, model string, at time.Time) (ModelPricing, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, period := range c.Providers[provider].History[model] {
+		if period.Contains(at) {
+			return period.Pricing, true
+		}
+	}
+	return ModelPricing{}, false
+}
+
+// LoadPricingHistory reads a JSON PricingHistory, e.g.
+//
+//	{"openai": {"gpt-4o": [{"effective_from": "2024-05-13T00:00:00Z", "effective_until": "2024-08-06T00:00:00Z",
+//	    "pricing": {"InputPricePerToken": 0.000005, "OutputPricePerToken": 0.000015, "Currency": "USD"}}]}}
+//
+// and sets the price periods of every model in it. Nothing is set when a model's
+// periods are invalid.
+func (c *Config) LoadPricingHistory(r io.Reader) error {
+	var history PricingHistory
+	if err := json.NewDecoder(r).Decode(&history); err != nil {
+		return NewError(ErrInvalidParams, "failed to decode pricing history", err)
+	}
+
+	for provider, models := range history {
+		for model, periods := range models {
+			if _, err := validatePricePeriods(provider, model, periods); err != nil {
+				return err
+			}
+		}
+	}
+	for provider, models := range history {
+		for model, periods := range models {
+			if err := c.SetPricingHistory(provider, model, periods); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// LoadPricingHistoryFile reads a JSON PricingHistory from a file, see LoadPricingHistory
+func (c *Config) LoadPricingHistoryFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return NewError(ErrInvalidParams, fmt.Sprintf("failed to open pricing history: %s", path), err)
+	}
+	defer file.Close()
+
+	return c.LoadPricingHistory(file)
+}
+
+// historicalPricer prices calls with the pricing in effect when they were made
+type historicalPricer interface {
+	CalculatePriceAt(model string, inputTokens, outputTokens int, at time.Time) (Price, error)
+}
+
+// CalculatePriceAt calculates the price of a call made at the given time, with the
+// pricing in effect then
+func (t *DefaultTokenTracker) CalculatePriceAt(model string, inputTokens, outputTokens int, at time.Time) (Price, error) {
+	return t.CalculateUsagePriceAt(model, BillableUsage{InputTokens: inputTokens, OutputTokens: outputTokens}, at)
+}
+
+// CalculateUsagePriceAt calculates the price of a call made at the given time from its
+// billable usage. Calls outside the recorded price periods of their model are priced
+// like CalculateUsagePrice.
+func (t *DefaultTokenTracker) CalculateUsagePriceAt(model string, usage BillableUsage, at time.Time) (Price, error) {
+	if model == "" {
+		return Price{}, NewError(ErrInvalidParams, "model is required", nil)
+	}
+
+	provider, exists := t.providerForModel(model)
+	if !exists {
+		return Price{}, NewError(ErrProviderNotFound, "no provider found for model: "+model, nil)
+	}
+
+	pricing, exists := t.config.historicalPricing(provider.Name(), model, at)
+	if !exists {
+		if pricingModel, ok := t.config.catalogPricingModel(provider.Name(), model); ok {
+			pricing, exists = t.config.historicalPricing(provider.Name(), pricingModel, at)
+		}
+	}
+	if !exists {
+		return t.CalculateUsagePrice(model, usage)
+	}
+	return t.config.convertPrice(pricing.Cost(usage))
+}
@@ -0,0 +1,151 @@
+package tokentracker
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker/tokentrackertest"
+)
+
+func TestConfig_SetPricingHistory(t *testing.T) {
+	may := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	june := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	july := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	pricing := ModelPricing{InputPricePerToken: 0.00001, OutputPricePerToken: 0.00002, Currency: "USD"}
+
+	tests := []struct {
+		name    string
+		periods []PricePeriod
+		wantErr bool
+	}{
+		{"consecutive", []PricePeriod{{EffectiveFrom: june, Pricing: pricing}, {EffectiveFrom: may, EffectiveUntil: june, Pricing: pricing}}, false},
+		{"gap", []PricePeriod{{EffectiveFrom: may, EffectiveUntil: june, Pricing: pricing}, {EffectiveFrom: july, Pricing: pricing}}, false},
+		{"no start", []PricePeriod{{EffectiveUntil: june, Pricing: pricing}}, true},
+		{"ends before start", []PricePeriod{{EffectiveFrom: june, EffectiveUntil: may, Pricing: pricing}}, true},
+		{"overlap", []PricePeriod{{EffectiveFrom: may, EffectiveUntil: july, Pricing: pricing}, {EffectiveFrom: june, Pricing: pricing}}, true},
+		{"open-ended overlap", []PricePeriod{{EffectiveFrom: may, Pricing: pricing}, {EffectiveFrom: june, Pricing: pricing}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewConfig()
+			err := config.SetPricingHistory("acme", "acme-1", tt.periods)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetPricingHistory() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			history := config.GetPricingHistory("acme", "acme-1")
+			if len(history) != len(tt.periods) || !history[0].EffectiveFrom.Equal(may) {
+				t.Errorf("GetPricingHistory() = %+v, want the periods sorted by start", history)
+			}
+		})
+	}
+}
+
+func TestDefaultTokenTracker_CalculatePriceAt(t *testing.T) {
+	clock := tokentrackertest.NewFakeClock(time.Date(2024, 8, 1, 12, 0, 0, 0, time.UTC))
+	tracker, _ := newIdempotentTracker(t, clock)
+
+	launch := ModelPricing{InputPricePerToken: 0.00003, OutputPricePerToken: 0.00006, Currency: "USD"}
+	err := tracker.config.SetPricingHistory("acme", "acme-1", []PricePeriod{{
+		EffectiveFrom:  time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		EffectiveUntil: time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC),
+		Pricing:        launch,
+	}})
+	if err != nil {
+		t.Fatalf("SetPricingHistory() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want float64
+	}{
+		{"in period", time.Date(2024, 6, 15, 0, 0, 0, 0, time.UTC), 1000*0.00003 + 500*0.00006},
+		{"period start", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), 1000*0.00003 + 500*0.00006},
+		{"period end", time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC), 1000*0.00001 + 500*0.00002},
+		{"before history", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), 1000*0.00001 + 500*0.00002},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			price, err := tracker.CalculatePriceAt("acme-1", 1000, 500, tt.at)
+			if err != nil {
+				t.Fatalf("CalculatePriceAt() error = %v", err)
+			}
+			if math.Abs(price.TotalCost-tt.want) > 1e-12 {
+				t.Errorf("CalculatePriceAt() total = %v, want %v", price.TotalCost, tt.want)
+			}
+		})
+	}
+
+	if _, err := tracker.CalculatePriceAt("unknown-model", 1000, 500, clock.Now()); err == nil {
+		t.Error("CalculatePriceAt() with an unknown model should fail")
+	}
+}
+
+func TestDefaultTokenTracker_TrackUsageScheduledPriceChange(t *testing.T) {
+	clock := tokentrackertest.NewFakeClock(time.Date(2024, 8, 31, 23, 0, 0, 0, time.UTC))
+	tracker, _ := newIdempotentTracker(t, clock)
+
+	// An announced price cut taking effect on September 1st
+	err := tracker.config.AddPricePeriod("acme", "acme-1", PricePeriod{
+		EffectiveFrom: time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC),
+		Pricing:       ModelPricing{InputPricePerToken: 0.000005, OutputPricePerToken: 0.00001, Currency: "USD"},
+	})
+	if err != nil {
+		t.Fatalf("AddPricePeriod() error = %v", err)
+	}
+
+	response := map[string]interface{}{"usage": TokenCount{InputTokens: 1000, ResponseTokens: 500}}
+	before, err := tracker.TrackUsage(CallParams{Model: "acme-1", StartTime: clock.Now()}, response)
+	if err != nil {
+		t.Fatalf("TrackUsage() error = %v", err)
+	}
+	clock.Advance(2 * time.Hour)
+	after, err := tracker.TrackUsage(CallParams{Model: "acme-1", StartTime: clock.Now()}, response)
+	if err != nil {
+		t.Fatalf("TrackUsage() error = %v", err)
+	}
+
+	if want := 1000*0.00001 + 500*0.00002; math.Abs(before.Price.TotalCost-want) > 1e-12 {
+		t.Errorf("cost before the change = %v, want %v", before.Price.TotalCost, want)
+	}
+	if want := 1000*0.000005 + 500*0.00001; math.Abs(after.Price.TotalCost-want) > 1e-12 {
+		t.Errorf("cost after the change = %v, want %v", after.Price.TotalCost, want)
+	}
+}
+
+func TestConfig_LoadPricingHistory(t *testing.T) {
+	config := NewConfig()
+	err := config.LoadPricingHistory(strings.NewReader(`{"acme": {"acme-1": [
+		{"effective_from": "2024-05-01T00:00:00Z", "effective_until": "2024-07-01T00:00:00Z",
+		 "pricing": {"InputPricePerToken": 0.00003, "OutputPricePerToken": 0.00006, "Currency": "USD"}}]}}`))
+	if err != nil {
+		t.Fatalf("LoadPricingHistory() error = %v", err)
+	}
+
+	pricing, ok := config.GetModelPricingAt("acme", "acme-1", time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+	if !ok || pricing.InputPricePerToken != 0.00003 {
+		t.Errorf("GetModelPricingAt() = %+v, %v, want the loaded pricing", pricing, ok)
+	}
+
+	// Invalid history is rejected without setting any of it
+	err = config.LoadPricingHistory(strings.NewReader(`{"acme": {"acme-2": [
+		{"effective_from": "2024-05-01T00:00:00Z", "pricing": {"InputPricePerToken": 0.00001}},
+		{"effective_from": "2024-06-01T00:00:00Z", "pricing": {"InputPricePerToken": 0.00001}}]}}`))
+	if err == nil {
+		t.Fatal("LoadPricingHistory() with overlapping periods should fail")
+	}
+	if history := config.GetPricingHistory("acme", "acme-2"); len(history) != 0 {
+		t.Errorf("GetPricingHistory() = %+v, want nothing set", history)
+	}
+
+	if err := config.LoadPricingHistory(strings.NewReader("not json")); err == nil {
+		t.Error("LoadPricingHistory() with invalid JSON should fail")
+	}
+}
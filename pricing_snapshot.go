@@ -0,0 +1,260 @@
+package tokentracker
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultPricingMaxAge is the age after which pricing is considered stale
+const DefaultPricingMaxAge = 7 * 24 * time.Hour
+
+// Pricing sources reported by Stats
+const (
+	PricingSourceDefaults = "defaults" // built-in or configured prices of unknown age
+	PricingSourceSnapshot = "snapshot" // last-known-good prices loaded from a snapshot
+	PricingSourceLive     = "live"     // prices fetched by UpdateAllPricing
+)
+
+// PricingSnapshot is a persisted copy of the last-known-good pricing
+type PricingSnapshot struct {
+	SavedAt   time.Time                 `json:"saved_at"`
+	Providers map[string]ProviderConfig `json:"providers"`
+}
+
+// PricingSnapshotOptions configures the last-known-good pricing snapshot of a tracker
+type PricingSnapshotOptions struct {
+	// Path of the JSON snapshot file
+	Path string
+
+	// MaxAge is the age after which pricing is stale (0 uses DefaultPricingMaxAge)
+	MaxAge time.Duration
+
+	// OnStale receives a warning when tracking with stale pricing (nil logs it)
+	OnStale func(StalePricingWarning)
+}
+
+// StalePricingWarning reports that calls are priced with stale or unverified prices
+type StalePricingWarning struct {
+	Source    string
+	UpdatedAt time.Time // zero when the age of the prices is unknown
+	Age       time.Duration
+	MaxAge    time.Duration
+}
+
+// TrackerStats reports the operational state of a tracker
+type TrackerStats struct {
+	PricingSource    string
+	PricingUpdatedAt time.Time // zero for prices of unknown age
+	PricingAge       time.Duration
+	PricingStale     bool
+	PricingError     error    // last failure loading or saving the snapshot or fetching prices
+	OpenCircuits     []string // providers not called because their circuit is open, see Resilience
+}
+
+// SavePricingSnapshot writes a snapshot to path, replacing the previous one atomically
+func SavePricingSnapshot(path string, snapshot PricingSnapshot) error {
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return NewError(ErrStorageFailed, "failed to encode pricing snapshot", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return NewError(ErrStorageFailed, "failed to write pricing snapshot", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return NewError(ErrStorageFailed, "failed to write pricing snapshot", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return NewError(ErrStorageFailed, "failed to write pricing snapshot", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return NewError(ErrStorageFailed, "failed to write pricing snapshot", err)
+	}
+	return nil
+}
+
+// LoadPricingSnapshot reads a snapshot written by SavePricingSnapshot
+func LoadPricingSnapshot(path string) (PricingSnapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return PricingSnapshot{}, err
+	}
+
+	var snapshot PricingSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return PricingSnapshot{}, NewError(ErrStorageFailed, "failed to decode pricing snapshot", err)
+	}
+	return snapshot, nil
+}
+
+// PricingSnapshot returns a copy of the configured pricing
+func (c *Config) PricingSnapshot(savedAt time.Time) PricingSnapshot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	providers := make(map[string]ProviderConfig, len(c.Providers))
+	for name, providerConfig := range c.Providers {
+		models := make(map[string]ModelPricing, len(providerConfig.Models))
+		for model, pricing := range providerConfig.Models {
+			models[model] = pricing
+		}
+		var history map[string][]PricePeriod
+		if len(providerConfig.History) > 0 {
+			history = make(map[string][]PricePeriod, len(providerConfig.History))
+			for model, periods := range providerConfig.History {
+				history[model] = append([]PricePeriod(nil), periods...)
+			}
+		}
+		providers[name] = ProviderConfig{Models: models, History: history}
+	}
+	return PricingSnapshot{SavedAt: savedAt, Providers: providers}
+}
+
+// ApplyPricingSnapshot sets the pricing of every model in the snapshot, keeping
+// the pricing of models the snapshot does not cover
+func (c *Config) ApplyPricingSnapshot(snapshot PricingSnapshot) {
+	for provider, providerConfig := range snapshot.Providers {
+		for model, pricing := range providerConfig.Models {
+			c.SetModelPricing(provider, model, pricing)
+		}
+		for model, periods := range providerConfig.History {
+			// Invalid history in a snapshot leaves the model's history unchanged
+			_ = c.SetPricingHistory(provider, model, periods)
+		}
+	}
+}
+
+// pricingFreshness tracks where the tracker's prices came from and how old they are
+type pricingFreshness struct {
+	opts      PricingSnapshotOptions
+	feed      PricingSource
+	source    string
+	updatedAt time.Time
+	warned    bool
+	err       error
+	mu        sync.Mutex
+}
+
+// newPricingFreshness creates the freshness state of prices of unknown age
+func newPricingFreshness() *pricingFreshness {
+	return &pricingFreshness{source: PricingSourceDefaults}
+}
+
+// maxAge returns the configured staleness threshold
+func (p *pricingFreshness) maxAge() time.Duration {
+	if p.opts.MaxAge > 0 {
+		return p.opts.MaxAge
+	}
+	return DefaultPricingMaxAge
+}
+
+// stale reports whether the prices are stale at now. Prices of unknown age are
+// only stale when a snapshot is configured, i.e. last-known-good prices are expected.
+func (p *pricingFreshness) stale(now time.Time) bool {
+	if p.updatedAt.IsZero() {
+		return p.opts.Path != ""
+	}
+	return now.Sub(p.updatedAt) > p.maxAge()
+}
+
+// WithPricingSnapshot loads the last-known-good pricing snapshot at startup and
+// saves a new one after every successful UpdateAllPricing. A missing snapshot is
+// not an error; the tracker then starts with its configured prices.
+func WithPricingSnapshot(opts PricingSnapshotOptions) TrackerOption {
+	return func(t *DefaultTokenTracker) {
+		t.pricing.mu.Lock()
+		defer t.pricing.mu.Unlock()
+
+		t.pricing.opts = opts
+		snapshot, err := LoadPricingSnapshot(opts.Path)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				t.pricing.err = err
+			}
+			return
+		}
+
+		t.config.ApplyPricingSnapshot(snapshot)
+		t.pricing.source = PricingSourceSnapshot
+		t.pricing.updatedAt = snapshot.SavedAt
+	}
+}
+
+// Stats returns the operational state of the tracker, such as the age of its prices
+func (t *DefaultTokenTracker) Stats() TrackerStats {
+	now := t.clock().Now()
+
+	t.pricing.mu.Lock()
+	defer t.pricing.mu.Unlock()
+
+	stats := TrackerStats{
+		PricingSource:    t.pricing.source,
+		PricingUpdatedAt: t.pricing.updatedAt,
+		PricingStale:     t.pricing.stale(now),
+		PricingError:     t.pricing.err,
+		OpenCircuits:     t.config.GetResilience().OpenCircuits(),
+	}
+	if !t.pricing.updatedAt.IsZero() {
+		stats.PricingAge = now.Sub(t.pricing.updatedAt)
+	}
+	return stats
+}
+
+// pricingUpdated records a successful pricing update and saves the snapshot
+func (t *DefaultTokenTracker) pricingUpdated() error {
+	now := t.clock().Now()
+
+	t.pricing.mu.Lock()
+	defer t.pricing.mu.Unlock()
+
+	t.pricing.source = PricingSourceLive
+	t.pricing.updatedAt = now
+	t.pricing.warned = false
+
+	if t.pricing.opts.Path == "" {
+		return nil
+	}
+	t.pricing.err = SavePricingSnapshot(t.pricing.opts.Path, t.config.PricingSnapshot(now))
+	return t.pricing.err
+}
+
+// checkPricingFreshness warns once when calls are priced with stale prices; the
+// warning is repeated after the prices were refreshed and went stale again
+func (t *DefaultTokenTracker) checkPricingFreshness() {
+	now := t.clock().Now()
+
+	t.pricing.mu.Lock()
+	if t.pricing.warned || !t.pricing.stale(now) {
+		t.pricing.mu.Unlock()
+		return
+	}
+	t.pricing.warned = true
+	warning := StalePricingWarning{
+		Source:    t.pricing.source,
+		UpdatedAt: t.pricing.updatedAt,
+		MaxAge:    t.pricing.maxAge(),
+	}
+	if !warning.UpdatedAt.IsZero() {
+		warning.Age = now.Sub(warning.UpdatedAt)
+	}
+	onStale := t.pricing.opts.OnStale
+	t.pricing.mu.Unlock()
+
+	if onStale != nil {
+		onStale(warning)
+		return
+	}
+	if warning.UpdatedAt.IsZero() {
+		t.logger().Warn("no pricing snapshot available, pricing with prices of unknown age", "source", warning.Source)
+	} else {
+		t.logger().Warn("pricing is stale", "source", warning.Source, "age", warning.Age.Round(time.Minute), "max_age", warning.MaxAge)
+	}
+}
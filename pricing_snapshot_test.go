@@ -0,0 +1,143 @@
+package tokentracker
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker/tokentrackertest"
+)
+
+// pricingUpdateProvider is a mock provider whose pricing updates fail with err
+type pricingUpdateProvider struct {
+	*MockProvider
+	err error
+}
+
+func (p *pricingUpdateProvider) UpdatePricing() error {
+	return p.err
+}
+
+func TestPricingSnapshot_SaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pricing.json")
+	savedAt := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	config := NewConfig()
+	config.SetModelPricing("openai", "gpt-4", ModelPricing{InputPricePerToken: 0.5, OutputPricePerToken: 1, Currency: "USD"})
+	if err := SavePricingSnapshot(path, config.PricingSnapshot(savedAt)); err != nil {
+		t.Fatalf("SavePricingSnapshot() error = %v", err)
+	}
+
+	snapshot, err := LoadPricingSnapshot(path)
+	if err != nil {
+		t.Fatalf("LoadPricingSnapshot() error = %v", err)
+	}
+	if !snapshot.SavedAt.Equal(savedAt) {
+		t.Errorf("SavedAt = %v, want %v", snapshot.SavedAt, savedAt)
+	}
+
+	fresh := NewConfig()
+	fresh.SetModelPricing("openai", "gpt-custom", ModelPricing{InputPricePerToken: 2})
+	fresh.ApplyPricingSnapshot(snapshot)
+	if pricing, _ := fresh.GetModelPricing("openai", "gpt-4"); pricing.InputPricePerToken != 0.5 {
+		t.Errorf("gpt-4 pricing = %+v, want the snapshot's", pricing)
+	}
+	if _, exists := fresh.GetModelPricing("openai", "gpt-custom"); !exists {
+		t.Error("models missing from the snapshot should keep their pricing")
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPricingSnapshot(path); err == nil {
+		t.Error("LoadPricingSnapshot() of a corrupt file should fail")
+	}
+}
+
+func TestDefaultTokenTracker_PricingSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pricing.json")
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	clock := tokentrackertest.NewFakeClock(start)
+
+	config := NewConfig()
+	config.SetClock(clock)
+	provider := &pricingUpdateProvider{MockProvider: &MockProvider{
+		name:           "openai",
+		supportedModel: "gpt-4",
+		price:          Price{TotalCost: 0.01, Currency: "USD"},
+	}}
+
+	var warnings []StalePricingWarning
+	opts := PricingSnapshotOptions{
+		Path:    path,
+		MaxAge:  24 * time.Hour,
+		OnStale: func(w StalePricingWarning) { warnings = append(warnings, w) },
+	}
+	tracker := NewTokenTracker(config, WithPricingSnapshot(opts))
+	tracker.RegisterProvider(provider)
+
+	// Without a snapshot the prices are of unknown age
+	stats := tracker.Stats()
+	if stats.PricingSource != PricingSourceDefaults || !stats.PricingStale || stats.PricingError != nil {
+		t.Errorf("Stats() = %+v, want stale defaults", stats)
+	}
+
+	if err := tracker.UpdateAllPricing(); err != nil {
+		t.Fatalf("UpdateAllPricing() error = %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("snapshot was not saved: %v", err)
+	}
+	if stats := tracker.Stats(); stats.PricingSource != PricingSourceLive || stats.PricingStale {
+		t.Errorf("Stats() = %+v, want fresh live prices", stats)
+	}
+
+	// After a restart with the pricing API down, the snapshot is used
+	clock.Advance(36 * time.Hour)
+	restarted := NewTokenTracker(config, WithPricingSnapshot(opts))
+	restarted.RegisterProvider(provider)
+	provider.err = errors.New("pricing API unavailable")
+	if err := restarted.UpdateAllPricing(); err == nil {
+		t.Fatal("UpdateAllPricing() should fail while the pricing API is down")
+	}
+
+	stats = restarted.Stats()
+	if stats.PricingSource != PricingSourceSnapshot || !stats.PricingUpdatedAt.Equal(start) {
+		t.Errorf("Stats() = %+v, want the snapshot saved at %v", stats, start)
+	}
+	if stats.PricingAge != 36*time.Hour || !stats.PricingStale {
+		t.Errorf("PricingAge = %v, stale = %v; want 36h and stale", stats.PricingAge, stats.PricingStale)
+	}
+
+	// Tracking with stale prices warns once
+	params := CallParams{Model: "gpt-4", StartTime: clock.Now()}
+	for i := 0; i < 2; i++ {
+		if _, err := restarted.TrackReportedUsage(context.Background(), params, TokenCount{InputTokens: 10}); err != nil {
+			t.Fatalf("TrackReportedUsage() error = %v", err)
+		}
+	}
+	if len(warnings) != 1 || warnings[0].Age != 36*time.Hour || warnings[0].Source != PricingSourceSnapshot {
+		t.Fatalf("warnings = %+v, want one for the 36h old snapshot", warnings)
+	}
+
+	// Refreshed prices stop the warnings
+	provider.err = nil
+	if err := restarted.UpdateAllPricing(); err != nil {
+		t.Fatalf("UpdateAllPricing() error = %v", err)
+	}
+	_, _ = restarted.TrackReportedUsage(context.Background(), params, TokenCount{InputTokens: 10})
+	if len(warnings) != 1 {
+		t.Errorf("warnings = %+v, want no warning for fresh prices", warnings)
+	}
+}
+
+func TestDefaultTokenTracker_StatsWithoutSnapshot(t *testing.T) {
+	tracker := NewTokenTracker(NewConfig())
+
+	if stats := tracker.Stats(); stats.PricingSource != PricingSourceDefaults || stats.PricingStale {
+		t.Errorf("Stats() = %+v, want defaults that are not stale without a snapshot", stats)
+	}
+}
@@ -0,0 +1,237 @@
+package tokentracker
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultPricingSignatureHeader is the response header carrying the manifest signature
+const DefaultPricingSignatureHeader = "X-Pricing-Signature"
+
+// DefaultPricingManifestMaxBytes is the largest pricing manifest HTTPPricingSource downloads
+const DefaultPricingManifestMaxBytes = 5 << 20
+
+// PricingSourceCircuit names the circuit breaker of the pricing source, see Resilience
+const PricingSourceCircuit = "pricing_source"
+
+// PricingManifest is a pricing table published by a pricing source
+type PricingManifest struct {
+	Version   int                       `json:"version"`
+	UpdatedAt time.Time                 `json:"updated_at"`
+	Providers map[string]ProviderConfig `json:"providers"`
+}
+
+// newerThan reports whether the manifest supersedes other, by version and then by update time
+func (m PricingManifest) newerThan(other PricingManifest) bool {
+	if m.Version != other.Version {
+		return m.Version > other.Version
+	}
+	return m.UpdatedAt.After(other.UpdatedAt)
+}
+
+// Validate checks that the manifest prices at least one model and has no negative prices
+func (m PricingManifest) Validate() error {
+	models := 0
+	for provider, providerConfig := range m.Providers {
+		for model, pricing := range providerConfig.Models {
+			if pricing.InputPricePerToken < 0 || pricing.OutputPricePerToken < 0 {
+				return NewError(ErrPricingUpdateFailed, fmt.Sprintf("negative price for %s/%s in pricing manifest", provider, model), nil)
+			}
+			models++
+		}
+	}
+	if models == 0 {
+		return NewError(ErrPricingUpdateFailed, "pricing manifest prices no models", nil)
+	}
+	return nil
+}
+
+// PricingSource supplies current pricing, e.g. from a remote feed
+type PricingSource interface {
+	// FetchPricing returns the current pricing manifest
+	FetchPricing(ctx context.Context) (PricingManifest, error)
+}
+
+// PricingSourceFunc adapts a function to the PricingSource interface
+type PricingSourceFunc func(ctx context.Context) (PricingManifest, error)
+
+// FetchPricing calls f(ctx)
+func (f PricingSourceFunc) FetchPricing(ctx context.Context) (PricingManifest, error) {
+	return f(ctx)
+}
+
+// HTTPPricingSource fetches a JSON pricing manifest from a URL. Responses are cached
+// by ETag, so an unchanged manifest is not downloaded again. When PublicKey is set,
+// the manifest must carry a base64 Ed25519 signature of the response body.
+//
+// Once a manifest is accepted, only newer ones are: a manifest with a lower version, or
+// the same version and an earlier update time, is rejected, so an old signed manifest
+// cannot be replayed to roll prices back. The accepted version is kept
+// in memory for the lifetime of the source.
+type HTTPPricingSource struct {
+	URL string
+
+	// PublicKey verifies the manifest signature; nil accepts unsigned manifests
+	PublicKey ed25519.PublicKey
+
+	// SignatureHeader is the header carrying the signature (empty uses DefaultPricingSignatureHeader)
+	SignatureHeader string
+
+	// Client sends the requests (nil uses a client with a 30 second timeout)
+	Client *http.Client
+
+	// MaxBodyBytes bounds the manifest downloaded (0 uses DefaultPricingManifestMaxBytes)
+	MaxBodyBytes int64
+
+	etag     string
+	manifest PricingManifest
+	accepted bool
+	mu       sync.Mutex
+}
+
+// NewHTTPPricingSource creates a source for the manifest at url, verified with publicKey
+func NewHTTPPricingSource(url string, publicKey ed25519.PublicKey) *HTTPPricingSource {
+	return &HTTPPricingSource{
+		URL:       url,
+		PublicKey: publicKey,
+	}
+}
+
+// FetchPricing downloads the manifest, or returns the cached one when it is unchanged
+func (s *HTTPPricingSource) FetchPricing(ctx context.Context) (PricingManifest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return PricingManifest{}, NewError(ErrPricingUpdateFailed, "invalid pricing source URL", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if s.etag != "" {
+		req.Header.Set("If-None-Match", s.etag)
+	}
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return PricingManifest{}, NewError(ErrPricingUpdateFailed, "failed to fetch pricing manifest", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && s.etag != "" {
+		return s.manifest, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return PricingManifest{}, NewError(ErrPricingUpdateFailed, fmt.Sprintf("pricing source returned %s", resp.Status), nil)
+	}
+
+	maxBytes := s.MaxBodyBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultPricingManifestMaxBytes
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return PricingManifest{}, NewError(ErrPricingUpdateFailed, "failed to read pricing manifest", err)
+	}
+	if int64(len(body)) > maxBytes {
+		return PricingManifest{}, NewError(ErrPricingUpdateFailed, fmt.Sprintf("pricing manifest exceeds %d bytes", maxBytes), nil)
+	}
+	if err := s.verify(body, resp.Header); err != nil {
+		return PricingManifest{}, err
+	}
+
+	var manifest PricingManifest
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return PricingManifest{}, NewError(ErrPricingUpdateFailed, "failed to decode pricing manifest", err)
+	}
+	if err := manifest.Validate(); err != nil {
+		return PricingManifest{}, err
+	}
+	if s.accepted && !manifest.newerThan(s.manifest) {
+		// The accepted manifest served again, e.g. without an ETag
+		if manifest.Version == s.manifest.Version && manifest.UpdatedAt.Equal(s.manifest.UpdatedAt) {
+			return s.manifest, nil
+		}
+		return PricingManifest{}, NewError(ErrPricingUpdateFailed, fmt.Sprintf("pricing manifest version %d (%s) is older than the accepted version %d (%s)",
+			manifest.Version, manifest.UpdatedAt.Format(time.RFC3339), s.manifest.Version, s.manifest.UpdatedAt.Format(time.RFC3339)), nil)
+	}
+
+	s.etag = resp.Header.Get("ETag")
+	s.manifest = manifest
+	s.accepted = true
+	return manifest, nil
+}
+
+// verify checks the signature of a manifest body
+func (s *HTTPPricingSource) verify(body []byte, header http.Header) error {
+	if s.PublicKey == nil {
+		return nil
+	}
+
+	name := s.SignatureHeader
+	if name == "" {
+		name = DefaultPricingSignatureHeader
+	}
+	signature, err := base64.StdEncoding.DecodeString(header.Get(name))
+	if err != nil || len(signature) == 0 {
+		return NewError(ErrPricingUpdateFailed, "pricing manifest is not signed", err)
+	}
+	if !ed25519.Verify(s.PublicKey, body, signature) {
+		return NewError(ErrPricingUpdateFailed, "pricing manifest signature is invalid", nil)
+	}
+	return nil
+}
+
+// WithPricingSource makes UpdateAllPricing fetch prices from source instead of the
+// providers' built-in tables, and makes the configuration's automatic pricing updates
+// call UpdateAllPricing. When a fetch fails the tracker keeps its last-known-good
+// prices and reports the failure in Stats.
+func WithPricingSource(source PricingSource) TrackerOption {
+	return func(t *DefaultTokenTracker) {
+		t.pricing.mu.Lock()
+		t.pricing.feed = source
+		t.pricing.mu.Unlock()
+
+		t.config.setPricingUpdater(func() {
+			_ = t.UpdateAllPricing()
+		})
+	}
+}
+
+// updatePricingFromSource applies the manifest fetched from source. On failure the
+// current prices are kept and the error is recorded for Stats.
+func (t *DefaultTokenTracker) updatePricingFromSource(ctx context.Context, source PricingSource) error {
+	resilience := t.config.GetResilience()
+	var manifest PricingManifest
+	err := resilience.Do(ctx, PricingSourceCircuit, func(ctx context.Context) error {
+		fetched, err := source.FetchPricing(ctx)
+		if err == nil {
+			err = fetched.Validate()
+		}
+		manifest = fetched
+		return err
+	})
+	if err != nil {
+		t.config.ReportFallback(FallbackWarning{Provider: PricingSourceCircuit, Operation: "fetch_pricing", Fallback: FallbackCachedPricing, Err: err})
+		t.pricing.mu.Lock()
+		t.pricing.err = err
+		t.pricing.mu.Unlock()
+		return NewError(ErrPricingUpdateFailed, "failed to update pricing from pricing source", err)
+	}
+
+	t.config.ApplyPricingSnapshot(PricingSnapshot{SavedAt: manifest.UpdatedAt, Providers: manifest.Providers})
+	t.pricing.mu.Lock()
+	t.pricing.err = nil
+	t.pricing.mu.Unlock()
+	return t.pricingUpdated()
+}
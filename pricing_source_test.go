@@ -0,0 +1,189 @@
+package tokentracker
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker/tokentrackertest"
+)
+
+const testManifest = `{"version": 1, "updated_at": "2024-06-01T00:00:00Z", "providers": {"openai": {"Models": {"gpt-4o": {"InputPricePerToken": 0.000005, "OutputPricePerToken": 0.000015, "Currency": "USD"}}}}}`
+
+func TestHTTPPricingSource_FetchPricing(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, []byte(testManifest)))
+
+	downloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads++
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set(DefaultPricingSignatureHeader, signature)
+		_, _ = w.Write([]byte(testManifest))
+	}))
+	defer server.Close()
+
+	source := NewHTTPPricingSource(server.URL, publicKey)
+	manifest, err := source.FetchPricing(context.Background())
+	if err != nil {
+		t.Fatalf("FetchPricing() error = %v", err)
+	}
+	if manifest.Providers["openai"].Models["gpt-4o"].InputPricePerToken != 0.000005 {
+		t.Errorf("manifest = %+v", manifest)
+	}
+
+	// The unchanged manifest is served from the cache
+	if again, err := source.FetchPricing(context.Background()); err != nil || again.Version != 1 {
+		t.Errorf("cached FetchPricing() = %+v, %v", again, err)
+	}
+	if downloads != 1 {
+		t.Errorf("downloads = %d, want 1", downloads)
+	}
+
+	otherKey, _, _ := ed25519.GenerateKey(nil)
+	if _, err := NewHTTPPricingSource(server.URL, otherKey).FetchPricing(context.Background()); err == nil {
+		t.Error("FetchPricing() should reject a manifest signed with another key")
+	}
+}
+
+func TestHTTPPricingSource_Errors(t *testing.T) {
+	responses := map[string]string{
+		"/empty":    `{"version": 1, "providers": {}}`,
+		"/negative": `{"providers": {"openai": {"Models": {"gpt-4": {"InputPricePerToken": -1}}}}}`,
+		"/invalid":  `{`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	for _, path := range []string{"/empty", "/negative", "/invalid", "/missing"} {
+		if _, err := NewHTTPPricingSource(server.URL+path, nil).FetchPricing(context.Background()); err == nil {
+			t.Errorf("FetchPricing(%s) should fail", path)
+		}
+	}
+
+	limited := NewHTTPPricingSource(server.URL+"/negative", nil)
+	limited.MaxBodyBytes = 16
+	if _, err := limited.FetchPricing(context.Background()); err == nil {
+		t.Error("FetchPricing() of a manifest over MaxBodyBytes should fail")
+	}
+
+	signed := NewHTTPPricingSource(server.URL+"/empty", ed25519.PublicKey(make([]byte, ed25519.PublicKeySize)))
+	if _, err := signed.FetchPricing(context.Background()); err == nil {
+		t.Error("FetchPricing() of an unsigned manifest should fail when a key is set")
+	}
+}
+
+func TestHTTPPricingSource_RejectsReplays(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifests := map[string]string{
+		"v1":       testManifest,
+		"v2":       `{"version": 2, "updated_at": "2024-07-01T00:00:00Z", "providers": {"openai": {"Models": {"gpt-4o": {"InputPricePerToken": 0.0000025, "Currency": "USD"}}}}}`,
+		"v2-later": `{"version": 2, "updated_at": "2024-07-02T00:00:00Z", "providers": {"openai": {"Models": {"gpt-4o": {"InputPricePerToken": 0.000002, "Currency": "USD"}}}}}`,
+	}
+	serve := "v1"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := []byte(manifests[serve])
+		w.Header().Set(DefaultPricingSignatureHeader, base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, body)))
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	source := NewHTTPPricingSource(server.URL, publicKey)
+	for _, step := range []struct {
+		serve       string
+		wantVersion int
+		wantErr     bool
+	}{
+		{"v1", 1, false},
+		{"v2", 2, false},
+		{"v2", 2, false},       // the accepted manifest served again
+		{"v1", 0, true},        // an older signed manifest replayed
+		{"v2-later", 2, false}, // the same version updated later
+		{"v2", 0, true},
+	} {
+		serve = step.serve
+		manifest, err := source.FetchPricing(context.Background())
+		if (err != nil) != step.wantErr || manifest.Version != step.wantVersion {
+			t.Errorf("FetchPricing() of %s = version %d, %v, want version %d and error %v", step.serve, manifest.Version, err, step.wantVersion, step.wantErr)
+		}
+	}
+	serve = "v2-later"
+	if manifest, _ := source.FetchPricing(context.Background()); !manifest.UpdatedAt.Equal(time.Date(2024, 7, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("FetchPricing() after a rejected replay = %+v, want the last accepted manifest kept", manifest)
+	}
+}
+
+func TestDefaultTokenTracker_WithPricingSource(t *testing.T) {
+	clock := tokentrackertest.NewFakeClock(time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC))
+	config := NewConfig()
+	config.SetClock(clock)
+
+	fail := false
+	fetches := 0
+	source := PricingSourceFunc(func(ctx context.Context) (PricingManifest, error) {
+		fetches++
+		if fail {
+			return PricingManifest{}, errors.New("feed unavailable")
+		}
+		return PricingManifest{Providers: map[string]ProviderConfig{
+			"openai": {Models: map[string]ModelPricing{"gpt-4": {InputPricePerToken: 0.00004, OutputPricePerToken: 0.00008, Currency: "USD"}}},
+		}}, nil
+	})
+	tracker := NewTokenTracker(config, WithPricingSource(source))
+
+	if err := tracker.UpdateAllPricing(); err != nil {
+		t.Fatalf("UpdateAllPricing() error = %v", err)
+	}
+	if pricing, _ := config.GetModelPricing("openai", "gpt-4"); pricing.InputPricePerToken != 0.00004 {
+		t.Errorf("gpt-4 pricing = %+v, want the source's", pricing)
+	}
+	if stats := tracker.Stats(); stats.PricingSource != PricingSourceLive || stats.PricingError != nil {
+		t.Errorf("Stats() = %+v", stats)
+	}
+
+	// A failed fetch keeps the last-known-good prices
+	fail = true
+	if err := tracker.UpdateAllPricing(); err == nil {
+		t.Error("UpdateAllPricing() should report the failed fetch")
+	}
+	if pricing, _ := config.GetModelPricing("openai", "gpt-4"); pricing.InputPricePerToken != 0.00004 {
+		t.Errorf("gpt-4 pricing after a failed fetch = %+v", pricing)
+	}
+	if tracker.Stats().PricingError == nil {
+		t.Error("Stats() should report the failed fetch")
+	}
+
+	// Automatic pricing updates fetch from the source
+	fail = false
+	config.EnableAutomaticPricingUpdates(time.Hour)
+	defer config.DisableAutomaticPricingUpdates()
+	clock.Advance(2 * time.Hour)
+	if fetches != 4 {
+		t.Errorf("fetches = %d, want one per interval", fetches)
+	}
+	if tracker.Stats().PricingError != nil {
+		t.Error("a successful automatic update should clear the error")
+	}
+}
@@ -0,0 +1,48 @@
+package tokentracker
+
+import "github.com/TrustSight-io/tokentracker/common"
+
+// PricingTier replaces the rates of a model for calls with long inputs, see common.PricingTier
+type PricingTier = common.PricingTier
+
+// BillableUsage are the token counts a call is billed for, see common.BillableUsage
+type BillableUsage = common.BillableUsage
+
+// billableUsage returns the billable usage of a token count
+func billableUsage(count TokenCount, batch bool) BillableUsage {
+	return BillableUsage{
+		InputTokens:       count.InputTokens,
+		OutputTokens:      count.ResponseTokens,
+		CachedInputTokens: count.CachedInputTokens,
+		CacheWriteTokens:  count.CacheWriteTokens,
+		AudioInputTokens:  count.AudioTokens,
+		ReasoningTokens:   count.ReasoningTokens,
+		Batch:             batch,
+	}
+}
+
+// CalculateUsagePrice calculates the price of a call from its billable usage. Providers
+// that do not implement UsagePricer bill every input token at the input price. The
+// price is reported in the default currency if one is set.
+func (t *DefaultTokenTracker) CalculateUsagePrice(model string, usage BillableUsage) (Price, error) {
+	if model == "" {
+		return Price{}, NewError(ErrInvalidParams, "model is required", nil)
+	}
+
+	provider, exists := t.providerForModel(model)
+	if !exists {
+		return Price{}, NewError(ErrProviderNotFound, "no provider found for model: "+model, nil)
+	}
+
+	var price Price
+	var err error
+	if pricer, ok := provider.(UsagePricer); ok {
+		price, err = pricer.CalculateUsagePrice(model, usage)
+	} else {
+		price, err = provider.CalculatePrice(model, usage.InputTokens, usage.OutputTokens)
+	}
+	if err != nil {
+		return Price{}, err
+	}
+	return t.config.convertPrice(price)
+}
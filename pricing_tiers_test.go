@@ -0,0 +1,103 @@
+package tokentracker
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestModelPricing_Cost(t *testing.T) {
+	pricing := ModelPricing{
+		InputPricePerToken:       0.000002,
+		OutputPricePerToken:      0.000008,
+		Currency:                 "USD",
+		CachedInputPricePerToken: 0.0000005,
+		CacheWritePricePerToken:  0.0000025,
+		AudioInputPricePerToken:  0.00004,
+		Tiers: []PricingTier{
+			{AboveInputTokens: 200000, InputPricePerToken: 0.000006, OutputPricePerToken: 0.000016},
+			{AboveInputTokens: 100000, InputPricePerToken: 0.000004, OutputPricePerToken: 0.000012, CachedInputPricePerToken: 0.000001},
+		},
+		BatchDiscount: 0.5,
+	}
+
+	tests := []struct {
+		name       string
+		usage      BillableUsage
+		wantInput  float64
+		wantOutput float64
+	}{
+		{"flat", BillableUsage{InputTokens: 1000, OutputTokens: 100}, 1000 * 0.000002, 100 * 0.000008},
+		{"cache hits and writes", BillableUsage{InputTokens: 1000, OutputTokens: 100, CachedInputTokens: 600, CacheWriteTokens: 100}, 300*0.000002 + 600*0.0000005 + 100*0.0000025, 100 * 0.000008},
+		{"tier", BillableUsage{InputTokens: 150000, OutputTokens: 100, CachedInputTokens: 50000}, 100000*0.000004 + 50000*0.000001, 100 * 0.000012},
+		{"tier without cache price", BillableUsage{InputTokens: 250000, CachedInputTokens: 50000}, 250000 * 0.000006, 0},
+		{"audio", BillableUsage{InputTokens: 1000, OutputTokens: 100, AudioInputTokens: 400}, 600*0.000002 + 400*0.00004, 100 * 0.000008},
+		{"batch", BillableUsage{InputTokens: 1000, OutputTokens: 100, Batch: true}, 1000 * 0.000002 / 2, 100 * 0.000008 / 2},
+		{"reasoning", BillableUsage{InputTokens: 1000, OutputTokens: 100, ReasoningTokens: 80}, 1000 * 0.000002, 100 * 0.000008},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			price := pricing.Cost(tt.usage)
+			if math.Abs(price.InputCost-tt.wantInput) > 1e-12 || math.Abs(price.OutputCost-tt.wantOutput) > 1e-12 {
+				t.Errorf("Cost() = %v/%v, want %v/%v", price.InputCost, price.OutputCost, tt.wantInput, tt.wantOutput)
+			}
+			if math.Abs(price.TotalCost-(tt.wantInput+tt.wantOutput)) > 1e-12 || price.Currency != "USD" {
+				t.Errorf("Cost() = %+v", price)
+			}
+			if want := tt.wantOutput * float64(tt.usage.ReasoningTokens) / float64(tt.usage.OutputTokens); math.Abs(price.ReasoningCost-want) > 1e-12 {
+				t.Errorf("ReasoningCost = %v, want %v", price.ReasoningCost, want)
+			}
+		})
+	}
+
+	// Without cache prices cached tokens are billed as regular input
+	flat := ModelPricing{InputPricePerToken: 0.001, OutputPricePerToken: 0.002}
+	if price := flat.Cost(BillableUsage{InputTokens: 10, CachedInputTokens: 5, Batch: true}); math.Abs(price.TotalCost-0.01) > 1e-12 {
+		t.Errorf("Cost() = %v, want the full input price", price.TotalCost)
+	}
+}
+
+func TestDefaultTokenTracker_TrackCachedUsage(t *testing.T) {
+	tracker := newPricedTestTracker(t)
+	tracker.Config().SetModelPricing("acme", "acme-1", ModelPricing{
+		InputPricePerToken:       0.00001,
+		OutputPricePerToken:      0.00002,
+		CachedInputPricePerToken: 0.000001,
+		CacheWritePricePerToken:  0.0000125,
+		BatchDiscount:            0.5,
+		Currency:                 "USD",
+	})
+
+	metrics, err := tracker.TrackReportedUsage(context.Background(), CallParams{Model: "acme-1"}, TokenCount{InputTokens: 1000, ResponseTokens: 10, CachedInputTokens: 800})
+	if err != nil {
+		t.Fatalf("TrackReportedUsage() error = %v", err)
+	}
+	want := 200*0.00001 + 800*0.000001 + 10*0.00002
+	if math.Abs(metrics.Price.TotalCost-want) > 1e-12 {
+		t.Errorf("TotalCost = %v, want %v", metrics.Price.TotalCost, want)
+	}
+	if metrics.TokenCount.CachedInputTokens != 800 {
+		t.Errorf("CachedInputTokens = %d, want 800", metrics.TokenCount.CachedInputTokens)
+	}
+
+	written, _ := tracker.TrackReportedUsage(context.Background(), CallParams{Model: "acme-1"}, TokenCount{InputTokens: 1000, CacheWriteTokens: 900})
+	if want := 100*0.00001 + 900*0.0000125; math.Abs(written.Price.TotalCost-want) > 1e-12 || written.TokenCount.CacheWriteTokens != 900 {
+		t.Errorf("cache write TotalCost = %v with %d write tokens, want %v", written.Price.TotalCost, written.TokenCount.CacheWriteTokens, want)
+	}
+
+	audio, _ := tracker.TrackReportedUsage(context.Background(), CallParams{Model: "acme-1"}, TokenCount{InputTokens: 100, AudioTokens: 60, VideoTokens: 30})
+	if audio.TokenCount.AudioTokens != 60 || audio.TokenCount.VideoTokens != 30 {
+		t.Errorf("TokenCount = %+v, want the audio and video tokens", audio.TokenCount)
+	}
+
+	batch, _ := tracker.TrackReportedUsage(context.Background(), CallParams{Model: "acme-1", Batch: true}, TokenCount{InputTokens: 1000, ResponseTokens: 10})
+	if math.Abs(batch.Price.TotalCost-(1000*0.00001+10*0.00002)/2) > 1e-12 {
+		t.Errorf("batch TotalCost = %v, want the discounted price", batch.Price.TotalCost)
+	}
+
+	// Providers without UsagePricer bill every input token at the input price
+	tracker.RegisterProvider(&MockProvider{name: "plain", supportedModel: "plain-1", price: Price{TotalCost: 1}})
+	if price, err := tracker.CalculateUsagePrice("plain-1", BillableUsage{InputTokens: 10, CachedInputTokens: 5}); err != nil || price.TotalCost != 1 {
+		t.Errorf("CalculateUsagePrice() = %+v, %v", price, err)
+	}
+}
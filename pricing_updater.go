@@ -0,0 +1,112 @@
+package tokentracker
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// PricingUpdateJitter is the fraction of the interval by which StartPricingUpdater
+// moves each update at random, so a fleet of trackers does not update at once
+const PricingUpdateJitter = 0.1
+
+// pricingUpdater is the state of the tracker's pricing update loop
+type pricingUpdater struct {
+	cancel  context.CancelFunc
+	done    chan struct{}
+	onError []func(error)
+	mu      sync.Mutex
+}
+
+// StartPricingUpdater calls UpdateAllPricingCtx every interval, give or take
+// PricingUpdateJitter, until ctx is done, StopPricingUpdater is called or the tracker
+// is closed. Failed updates keep the last-known-good prices and are reported to the
+// OnPricingUpdateError callbacks. It replaces Config.EnableAutomaticPricingUpdates.
+func (t *DefaultTokenTracker) StartPricingUpdater(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		return NewError(ErrInvalidParams, "pricing update interval must be positive", nil)
+	}
+
+	t.updater.mu.Lock()
+	defer t.updater.mu.Unlock()
+
+	if t.updater.cancel != nil {
+		return NewError(ErrInvalidParams, "pricing updater is already running", nil)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	t.updater.cancel, t.updater.done = cancel, done
+
+	go t.runPricingUpdater(ctx, interval, done)
+	return nil
+}
+
+// StopPricingUpdater stops the pricing update loop and waits for an update in progress
+func (t *DefaultTokenTracker) StopPricingUpdater() {
+	t.updater.mu.Lock()
+	cancel, done := t.updater.cancel, t.updater.done
+	t.updater.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// OnPricingUpdateError registers a callback invoked when an update of the pricing
+// updater fails; without callbacks failures are logged
+func (t *DefaultTokenTracker) OnPricingUpdateError(callback func(error)) {
+	t.updater.mu.Lock()
+	defer t.updater.mu.Unlock()
+
+	t.updater.onError = append(t.updater.onError, callback)
+}
+
+// runPricingUpdater updates the pricing after every jittered interval until ctx is done
+func (t *DefaultTokenTracker) runPricingUpdater(ctx context.Context, interval time.Duration, done chan struct{}) {
+	defer func() {
+		t.updater.mu.Lock()
+		if t.updater.done == done {
+			t.updater.cancel, t.updater.done = nil, nil
+		}
+		t.updater.mu.Unlock()
+		close(done)
+	}()
+
+	clock := t.clock()
+	for {
+		fired := make(chan struct{})
+		timer := clock.AfterFunc(jitter(interval), func() { close(fired) })
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-fired:
+		}
+
+		if err := t.UpdateAllPricingCtx(ctx); err != nil && ctx.Err() == nil {
+			t.reportPricingUpdateError(err)
+		}
+	}
+}
+
+// reportPricingUpdateError passes a failed update to the error callbacks
+func (t *DefaultTokenTracker) reportPricingUpdateError(err error) {
+	t.updater.mu.Lock()
+	callbacks := append([]func(error){}, t.updater.onError...)
+	t.updater.mu.Unlock()
+
+	if len(callbacks) == 0 {
+		t.logger().Warn("pricing update failed", "error", err)
+		return
+	}
+	for _, callback := range callbacks {
+		callback(err)
+	}
+}
+
+// jitter moves an interval by up to PricingUpdateJitter of it in either direction
+func jitter(interval time.Duration) time.Duration {
+	return interval + time.Duration((rand.Float64()*2-1)*PricingUpdateJitter*float64(interval))
+}
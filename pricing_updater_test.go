@@ -0,0 +1,127 @@
+package tokentracker
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker/tokentrackertest"
+)
+
+// signalingPricingProvider reports every pricing update on a channel
+type signalingPricingProvider struct {
+	*MockProvider
+	updates chan struct{}
+	failing atomic.Bool
+}
+
+var errPricingUnavailable = errors.New("pricing page unavailable")
+
+func (p *signalingPricingProvider) UpdatePricing() error {
+	p.updates <- struct{}{}
+	if p.failing.Load() {
+		return errPricingUnavailable
+	}
+	return nil
+}
+
+// waitForWaiters waits until the fake clock has n active timers
+func waitForWaiters(t *testing.T, clock *tokentrackertest.FakeClock, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for clock.Waiters() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("clock has %d waiters, want %d", clock.Waiters(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestDefaultTokenTracker_StartPricingUpdater(t *testing.T) {
+	clock := tokentrackertest.NewFakeClock(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+	config := NewConfig()
+	config.SetClock(clock)
+	tracker := NewTokenTracker(config)
+	provider := &signalingPricingProvider{MockProvider: &MockProvider{name: "acme", supportedModel: "acme-1"}, updates: make(chan struct{}, 1)}
+	tracker.RegisterProvider(provider)
+
+	errs := make(chan error, 1)
+	tracker.OnPricingUpdateError(func(err error) { errs <- err })
+
+	if err := tracker.StartPricingUpdater(context.Background(), time.Hour); err != nil {
+		t.Fatalf("StartPricingUpdater() error = %v", err)
+	}
+	if err := tracker.StartPricingUpdater(context.Background(), time.Hour); err == nil {
+		t.Error("StartPricingUpdater() of a running updater should fail")
+	}
+
+	// The first update runs after the jittered interval
+	waitForWaiters(t, clock, 1)
+	clock.Advance(time.Hour - time.Duration(PricingUpdateJitter*float64(time.Hour)) - time.Minute)
+	select {
+	case <-provider.updates:
+		t.Fatal("pricing updated before the interval")
+	default:
+	}
+	clock.Advance(time.Duration(2*PricingUpdateJitter*float64(time.Hour)) + time.Minute)
+	select {
+	case <-provider.updates:
+	case <-time.After(5 * time.Second):
+		t.Fatal("pricing was not updated after the interval")
+	}
+
+	// Failed updates are reported
+	provider.failing.Store(true)
+	waitForWaiters(t, clock, 1)
+	clock.Advance(2 * time.Hour)
+	<-provider.updates
+	select {
+	case err := <-errs:
+		if !errors.Is(err, errPricingUnavailable) {
+			t.Errorf("reported error = %v, want %v", err, errPricingUnavailable)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the failed update was not reported")
+	}
+
+	tracker.StopPricingUpdater()
+	if clock.Waiters() != 0 {
+		t.Errorf("clock has %d waiters after StopPricingUpdater, want 0", clock.Waiters())
+	}
+}
+
+func TestDefaultTokenTracker_StartPricingUpdaterCancel(t *testing.T) {
+	tracker := NewTokenTracker(NewConfig())
+	if err := tracker.StartPricingUpdater(context.Background(), 0); err == nil {
+		t.Error("StartPricingUpdater() with a zero interval should fail")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := tracker.StartPricingUpdater(ctx, time.Hour); err != nil {
+		t.Fatalf("StartPricingUpdater() error = %v", err)
+	}
+	cancel()
+
+	// Once the context is done the updater can be started again
+	deadline := time.Now().Add(5 * time.Second)
+	for tracker.StartPricingUpdater(context.Background(), time.Hour) != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("the updater did not stop when its context was cancelled")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := tracker.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		wait := jitter(time.Hour)
+		if wait < 54*time.Minute || wait > 66*time.Minute {
+			t.Fatalf("jitter(1h) = %v, want within 10%%", wait)
+		}
+	}
+}
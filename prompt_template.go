@@ -0,0 +1,249 @@
+package tokentracker
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// templatePlaceholder matches the {{name}} placeholders of prompt templates
+var templatePlaceholder = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_.-]*)\s*\}\}`)
+
+// PromptTemplate is a prompt rendered many times with different values, with
+// placeholders written as {{name}}, e.g. "Summarize for {{audience}}:\n{{document}}"
+type PromptTemplate struct {
+	Name  string
+	Model string // model the rendered prompts are counted for
+	Text  string
+}
+
+// Variables returns the names of the template's placeholders in order of first use
+func (p PromptTemplate) Variables() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, match := range templatePlaceholder.FindAllStringSubmatch(p.Text, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// Render returns the template with its placeholders replaced by vars. Every
+// placeholder needs a value.
+func (p PromptTemplate) Render(vars map[string]string) (string, error) {
+	var missing []string
+	rendered := templatePlaceholder.ReplaceAllStringFunc(p.Text, func(placeholder string) string {
+		name := templatePlaceholder.FindStringSubmatch(placeholder)[1]
+		value, exists := vars[name]
+		if !exists {
+			missing = append(missing, name)
+		}
+		return value
+	})
+	if len(missing) > 0 {
+		return "", NewError(ErrInvalidParams, fmt.Sprintf("missing values for prompt template %s: %s", p.Name, strings.Join(missing, ", ")), nil)
+	}
+	return rendered, nil
+}
+
+// compiledTemplate is a registered template with the cached count of its static text
+type compiledTemplate struct {
+	template  PromptTemplate
+	static    []string       // the text between the template's placeholders
+	variables map[string]int // number of uses of each placeholder
+
+	mu           sync.Mutex
+	staticTokens int // tokens of the static text, without the prompt overhead
+	overhead     int // tokens the provider adds to a text prompt
+	staticCached bool
+}
+
+// promptTemplates is the template registry of a tracker
+type promptTemplates struct {
+	templates map[string]*compiledTemplate
+	mu        sync.RWMutex
+}
+
+// newPromptTemplates creates an empty template registry
+func newPromptTemplates() *promptTemplates {
+	return &promptTemplates{templates: make(map[string]*compiledTemplate)}
+}
+
+// RegisterPromptTemplate registers a template by name, replacing a template of the
+// same name and its cached counts
+func (t *DefaultTokenTracker) RegisterPromptTemplate(template PromptTemplate) error {
+	if template.Name == "" {
+		return NewError(ErrInvalidParams, "prompt template name is required", nil)
+	}
+	if template.Model == "" {
+		return NewError(ErrInvalidParams, "model is required", nil)
+	}
+
+	compiled := &compiledTemplate{
+		template:  template,
+		static:    templatePlaceholder.Split(template.Text, -1),
+		variables: make(map[string]int),
+	}
+	for _, match := range templatePlaceholder.FindAllStringSubmatch(template.Text, -1) {
+		compiled.variables[match[1]]++
+	}
+
+	t.templates.mu.Lock()
+	defer t.templates.mu.Unlock()
+
+	t.templates.templates[template.Name] = compiled
+	return nil
+}
+
+// UnregisterPromptTemplate removes a template
+func (t *DefaultTokenTracker) UnregisterPromptTemplate(name string) {
+	t.templates.mu.Lock()
+	defer t.templates.mu.Unlock()
+
+	delete(t.templates.templates, name)
+}
+
+// PromptTemplate returns a registered template
+func (t *DefaultTokenTracker) PromptTemplate(name string) (PromptTemplate, bool) {
+	compiled, exists := t.promptTemplate(name)
+	if !exists {
+		return PromptTemplate{}, false
+	}
+	return compiled.template, true
+}
+
+// promptTemplate returns a compiled registered template
+func (t *DefaultTokenTracker) promptTemplate(name string) (*compiledTemplate, bool) {
+	t.templates.mu.RLock()
+	defer t.templates.mu.RUnlock()
+
+	compiled, exists := t.templates.templates[name]
+	return compiled, exists
+}
+
+// RenderPromptTemplate renders a registered template with vars
+func (t *DefaultTokenTracker) RenderPromptTemplate(name string, vars map[string]string) (string, error) {
+	compiled, exists := t.promptTemplate(name)
+	if !exists {
+		return "", NewError(ErrInvalidParams, fmt.Sprintf("unknown prompt template: %s", name), nil)
+	}
+	return compiled.template.Render(vars)
+}
+
+// CountTokensForTemplate counts the tokens of a registered template rendered with vars
+func (t *DefaultTokenTracker) CountTokensForTemplate(name string, vars map[string]string) (TokenCount, error) {
+	return t.CountTokensForTemplateCtx(context.Background(), name, vars)
+}
+
+// CountTokensForTemplateCtx counts the tokens of a registered template rendered with
+// vars without tokenizing the template's static text again: it is counted once per
+// registration and only the values are counted on every call. The parts are counted
+// as bare text, so the provider's per-prompt overhead is added once, and providers
+// implementing TextTokenCounter count them without a request each. Counting the parts
+// separately can differ from counting the rendered prompt by a token at each
+// placeholder boundary.
+func (t *DefaultTokenTracker) CountTokensForTemplateCtx(ctx context.Context, name string, vars map[string]string) (TokenCount, error) {
+	compiled, exists := t.promptTemplate(name)
+	if !exists {
+		return TokenCount{}, NewError(ErrInvalidParams, fmt.Sprintf("unknown prompt template: %s", name), nil)
+	}
+	for variable := range compiled.variables {
+		if _, exists := vars[variable]; !exists {
+			// Render reports every missing value in template order
+			_, err := compiled.template.Render(vars)
+			return TokenCount{}, err
+		}
+	}
+
+	model := compiled.template.Model
+	provider, exists := t.providerForModel(model)
+	if !exists {
+		return TokenCount{}, NewError(ErrProviderNotFound, fmt.Sprintf("no provider found for model: %s", model), nil)
+	}
+
+	static, overhead, err := t.templateStaticCount(ctx, provider, compiled)
+	if err != nil {
+		return TokenCount{}, err
+	}
+	inputTokens := overhead + static
+	for variable, uses := range compiled.variables {
+		value := vars[variable]
+		if value == "" {
+			continue
+		}
+		valueTokens, err := countTemplateText(ctx, provider, model, value, overhead)
+		if err != nil {
+			return TokenCount{}, err
+		}
+		inputTokens += uses * valueTokens
+	}
+
+	count := TokenCount{InputTokens: inputTokens, TotalTokens: inputTokens}
+	if t.calibration != nil {
+		count = t.calibration.apply(model, count)
+	}
+	return count, nil
+}
+
+// templateStaticCount returns the tokens of a template's static text and the
+// provider's per-prompt overhead, counting them on first use
+func (t *DefaultTokenTracker) templateStaticCount(ctx context.Context, provider Provider, compiled *compiledTemplate) (int, int, error) {
+	compiled.mu.Lock()
+	defer compiled.mu.Unlock()
+
+	if compiled.staticCached {
+		return compiled.staticTokens, compiled.overhead, nil
+	}
+
+	model := compiled.template.Model
+	overhead, err := textPromptOverhead(ctx, provider, model)
+	if err != nil {
+		return 0, 0, err
+	}
+	// The pieces are counted apart, as each ends at a value in rendered prompts
+	static := 0
+	for _, piece := range compiled.static {
+		tokens, err := countTemplateText(ctx, provider, model, piece, overhead)
+		if err != nil {
+			return 0, 0, err
+		}
+		static += tokens
+	}
+	compiled.staticTokens, compiled.overhead = static, overhead
+	compiled.staticCached = true
+	return static, overhead, nil
+}
+
+// textPromptOverhead returns the tokens a provider adds to the count of a text
+// prompt. Providers not implementing TextTokenCounter are asked for the count of an
+// empty prompt.
+func textPromptOverhead(ctx context.Context, provider Provider, model string) (int, error) {
+	if counter, ok := provider.(TextTokenCounter); ok {
+		return counter.TextPromptOverhead(model), nil
+	}
+	empty := ""
+	count, err := CountTokensWithContext(ctx, provider, TokenCountParams{Model: model, Text: &empty})
+	if err != nil {
+		return 0, err
+	}
+	return count.InputTokens, nil
+}
+
+// countTemplateText counts a part of a template without the per-prompt overhead
+func countTemplateText(ctx context.Context, provider Provider, model, text string, overhead int) (int, error) {
+	if text == "" {
+		return 0, nil
+	}
+	if counter, ok := provider.(TextTokenCounter); ok {
+		return counter.CountTextTokens(model, text)
+	}
+	count, err := CountTokensWithContext(ctx, provider, TokenCountParams{Model: model, Text: &text})
+	if err != nil {
+		return 0, err
+	}
+	return max(count.InputTokens-overhead, 0), nil
+}
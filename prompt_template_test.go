@@ -0,0 +1,140 @@
+package tokentracker
+
+import (
+	"strings"
+	"testing"
+)
+
+// wordCountingProvider counts one token per word and records the texts it counted
+type wordCountingProvider struct {
+	MockSimpleProvider
+	counted []string
+}
+
+func (p *wordCountingProvider) CountTokens(params TokenCountParams) (TokenCount, error) {
+	p.counted = append(p.counted, *params.Text)
+	tokens := len(strings.Fields(*params.Text))
+	return TokenCount{InputTokens: tokens, TotalTokens: tokens}, nil
+}
+
+// overheadCountingProvider counts one token per word plus a fixed overhead per
+// prompt, without counting text on its own
+type overheadCountingProvider struct {
+	wordCountingProvider
+	overhead int
+}
+
+func (p *overheadCountingProvider) CountTokens(params TokenCountParams) (TokenCount, error) {
+	tokens := len(strings.Fields(*params.Text)) + p.overhead
+	return TokenCount{InputTokens: tokens, TotalTokens: tokens}, nil
+}
+
+// overheadProvider is an overheadCountingProvider counting text on its own as a
+// TextTokenCounter
+type overheadProvider struct {
+	overheadCountingProvider
+}
+
+func (p *overheadProvider) CountTextTokens(_, text string) (int, error) {
+	p.counted = append(p.counted, text)
+	return len(strings.Fields(text)), nil
+}
+
+func (p *overheadProvider) TextPromptOverhead(string) int {
+	return p.overhead
+}
+
+func TestPromptTemplate_Render(t *testing.T) {
+	template := PromptTemplate{Name: "summary", Text: "Summarize for {{ audience }}: {{document}} ({{audience}})"}
+	if vars := template.Variables(); len(vars) != 2 || vars[0] != "audience" || vars[1] != "document" {
+		t.Errorf("Variables() = %v", vars)
+	}
+
+	rendered, err := template.Render(map[string]string{"audience": "lawyers", "document": "the contract"})
+	if err != nil || rendered != "Summarize for lawyers: the contract (lawyers)" {
+		t.Errorf("Render() = %q, %v", rendered, err)
+	}
+	if _, err := template.Render(map[string]string{"audience": "lawyers"}); err == nil || !strings.Contains(err.Error(), "document") {
+		t.Errorf("Render() error = %v, want the missing value named", err)
+	}
+}
+
+func TestDefaultTokenTracker_CountTokensForTemplate(t *testing.T) {
+	provider := &wordCountingProvider{MockSimpleProvider: MockSimpleProvider{name: "acme", supportedModels: map[string]bool{"acme-1": true}}}
+	tracker := NewTokenTracker(NewConfig())
+	tracker.RegisterProvider(provider)
+
+	template := PromptTemplate{
+		Name:  "support",
+		Model: "acme-1",
+		Text:  "You are a helpful support agent for our product. Answer politely. Customer {{name}} asks: {{question}} Reply to {{name}} kindly.",
+	}
+	if err := tracker.RegisterPromptTemplate(template); err != nil {
+		t.Fatalf("RegisterPromptTemplate() error = %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		vars := map[string]string{"name": "Ada", "question": "how do I reset my password?"}
+		count, err := tracker.CountTokensForTemplate("support", vars)
+		if err != nil {
+			t.Fatalf("CountTokensForTemplate() error = %v", err)
+		}
+		rendered, _ := tracker.RenderPromptTemplate("support", vars)
+		if want := len(strings.Fields(rendered)); count.InputTokens != want || count.TotalTokens != want {
+			t.Errorf("CountTokensForTemplate() = %+v, want %d tokens", count, want)
+		}
+	}
+
+	// The empty prompt and the four pieces of boilerplate are counted once; only the
+	// values are counted on every call
+	static := 0
+	for _, text := range provider.counted {
+		if strings.Contains(text, "helpful support agent") {
+			static++
+		}
+	}
+	if static != 1 || len(provider.counted) != 11 {
+		t.Errorf("counted %q, want the empty prompt and the static text once and two values per call", provider.counted)
+	}
+
+	if _, err := tracker.CountTokensForTemplate("support", map[string]string{"name": "Ada"}); err == nil {
+		t.Error("CountTokensForTemplate() with a missing value should fail")
+	}
+	if _, err := tracker.CountTokensForTemplate("unknown", nil); err == nil {
+		t.Error("CountTokensForTemplate() of an unknown template should fail")
+	}
+	if err := tracker.RegisterPromptTemplate(PromptTemplate{Name: "no-model", Text: "hi"}); err == nil {
+		t.Error("RegisterPromptTemplate() without a model should fail")
+	}
+}
+
+func TestDefaultTokenTracker_CountTokensForTemplateAddsOverheadOnce(t *testing.T) {
+	vars := map[string]string{"name": "Ada", "question": "how do I reset my password?"}
+	template := PromptTemplate{Name: "support", Model: "acme-1", Text: "Customer {{name}} asks: {{question}} Reply to {{name}} kindly."}
+
+	for _, tc := range []struct {
+		name     string
+		provider Provider
+	}{
+		{"counted per prompt", &overheadCountingProvider{wordCountingProvider{MockSimpleProvider: MockSimpleProvider{name: "acme", supportedModels: map[string]bool{"acme-1": true}}}, 5}},
+		{"text token counter", &overheadProvider{overheadCountingProvider{wordCountingProvider{MockSimpleProvider: MockSimpleProvider{name: "acme", supportedModels: map[string]bool{"acme-1": true}}}, 5}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tracker := NewTokenTracker(NewConfig())
+			tracker.RegisterProvider(tc.provider)
+			if err := tracker.RegisterPromptTemplate(template); err != nil {
+				t.Fatalf("RegisterPromptTemplate() error = %v", err)
+			}
+
+			count, err := tracker.CountTokensForTemplate("support", vars)
+			if err != nil {
+				t.Fatalf("CountTokensForTemplate() error = %v", err)
+			}
+			rendered, _ := tracker.RenderPromptTemplate("support", vars)
+			want, _ := tracker.CountTokens(TokenCountParams{Model: "acme-1", Text: &rendered})
+			if count.InputTokens != want.InputTokens {
+				t.Errorf("CountTokensForTemplate() = %d tokens, want %d as for the rendered prompt", count.InputTokens, want.InputTokens)
+			}
+		})
+	}
+}
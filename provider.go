@@ -1,6 +1,9 @@
 package tokentracker
 
-import "sync"
+import (
+	"context"
+	"sync"
+)
 
 // Provider defines the interface for provider-specific implementations
 type Provider interface {
@@ -16,11 +19,12 @@ type Provider interface {
 	// SupportsModel checks if the provider supports a specific model
 	SupportsModel(model string) bool
 
-	// SetSDKClient sets the provider-specific SDK client
+	// SetSDKClient sets the provider-specific SDK client. RegisterSDKClient passes the
+	// registered SDKClient wrapper.
 	SetSDKClient(client interface{})
 
 	// GetModelInfo returns information about a specific model
-	GetModelInfo(model string) (interface{}, error)
+	GetModelInfo(model string) (ModelInfo, error)
 
 	// ExtractTokenUsageFromResponse extracts token usage from a provider response
 	ExtractTokenUsageFromResponse(response interface{}) (TokenCount, error)
@@ -29,6 +33,46 @@ type Provider interface {
 	UpdatePricing() error
 }
 
+// ContextProvider is implemented by providers whose token counting honors a context,
+// e.g. because it calls a remote counting API
+type ContextProvider interface {
+	Provider
+
+	// CountTokensCtx counts tokens for the given parameters, honoring cancellation of ctx
+	CountTokensCtx(ctx context.Context, params TokenCountParams) (TokenCount, error)
+}
+
+// TextTokenCounter is implemented by providers that count text locally. The counts of
+// the parts of a text add up to the count of the whole, up to a token at each boundary,
+// so prompts can be counted in parts without a request per part.
+type TextTokenCounter interface {
+	// CountTextTokens counts text on its own, without the overhead CountTokens adds to
+	// a text prompt
+	CountTextTokens(model, text string) (int, error)
+
+	// TextPromptOverhead returns the tokens CountTokens adds to the count of a text prompt
+	TextPromptOverhead(model string) int
+}
+
+// UsagePricer is implemented by providers that price cached input tokens, pricing
+// tiers and batch discounts
+type UsagePricer interface {
+	// CalculateUsagePrice calculates the price of a call from its billable usage
+	CalculateUsagePrice(model string, usage BillableUsage) (Price, error)
+}
+
+// CountTokensWithContext counts tokens with the provider, using its context-aware
+// variant when available and checking ctx before falling back to CountTokens
+func CountTokensWithContext(ctx context.Context, provider Provider, params TokenCountParams) (TokenCount, error) {
+	if cp, ok := provider.(ContextProvider); ok {
+		return cp.CountTokensCtx(ctx, params)
+	}
+	if err := ctx.Err(); err != nil {
+		return TokenCount{}, err
+	}
+	return provider.CountTokens(params)
+}
+
 // ProviderRegistry manages available providers
 type ProviderRegistry struct {
 	providers map[string]Provider
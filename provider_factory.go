@@ -0,0 +1,105 @@
+package tokentracker
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ProviderFactory creates a provider that reads its pricing from config
+type ProviderFactory func(config *Config) Provider
+
+// providerFactories holds the registered provider factories by name
+var providerFactories = struct {
+	factories map[string]ProviderFactory
+	mu        sync.RWMutex
+}{factories: make(map[string]ProviderFactory)}
+
+// RegisterProviderFactory registers a factory under name, replacing any factory
+// registered before. Provider packages typically call it from an init function, so
+// importing them makes their providers available to NewProvider and
+// RegisterConfiguredProviders.
+func RegisterProviderFactory(name string, factory ProviderFactory) {
+	if name == "" || factory == nil {
+		panic("tokentracker: RegisterProviderFactory requires a name and a factory")
+	}
+
+	providerFactories.mu.Lock()
+	defer providerFactories.mu.Unlock()
+	providerFactories.factories[name] = factory
+}
+
+// ProviderFactories returns the names of the registered provider factories, sorted
+func ProviderFactories() []string {
+	providerFactories.mu.RLock()
+	defer providerFactories.mu.RUnlock()
+
+	names := make([]string, 0, len(providerFactories.factories))
+	for name := range providerFactories.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewProvider creates a provider with the factory registered under name
+func NewProvider(name string, config *Config) (Provider, error) {
+	providerFactories.mu.RLock()
+	factory, exists := providerFactories.factories[name]
+	providerFactories.mu.RUnlock()
+
+	if !exists {
+		return nil, NewError(ErrProviderNotFound, fmt.Sprintf("no provider factory registered with name: %s", name), nil)
+	}
+
+	provider := factory(config)
+	if provider == nil {
+		return nil, NewError(ErrProviderNotFound, fmt.Sprintf("provider factory %s returned no provider", name), nil)
+	}
+	return provider, nil
+}
+
+// RegisterConfiguredProviders instantiates and registers a provider for each provider
+// in the configuration, using the factory named by its Factory field or, by default,
+// the factory registered under the provider's name. Providers that are already
+// registered are left alone, as are configured providers without a factory unless
+// they name one explicitly.
+func (t *DefaultTokenTracker) RegisterConfiguredProviders() error {
+	t.config.mu.RLock()
+	configured := make(map[string]string, len(t.config.Providers))
+	for name, providerConfig := range t.config.Providers {
+		configured[name] = providerConfig.Factory
+	}
+	t.config.mu.RUnlock()
+
+	names := make([]string, 0, len(configured))
+	for name := range configured {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, exists := t.registry.Get(name); exists {
+			continue
+		}
+
+		factory := configured[name]
+		if factory == "" {
+			providerFactories.mu.RLock()
+			_, exists := providerFactories.factories[name]
+			providerFactories.mu.RUnlock()
+			if !exists {
+				continue
+			}
+			factory = name
+		}
+
+		provider, err := NewProvider(factory, t.config)
+		if err != nil {
+			return err
+		}
+		t.RegisterProvider(provider)
+	}
+
+	return nil
+}
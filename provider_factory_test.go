@@ -0,0 +1,72 @@
+package tokentracker
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRegisterProviderFactory(t *testing.T) {
+	RegisterProviderFactory("acme", func(config *Config) Provider {
+		return &MockSimpleProvider{name: "acme", supportedModels: map[string]bool{"acme-1": true}}
+	})
+
+	found := false
+	for _, name := range ProviderFactories() {
+		if name == "acme" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("ProviderFactories() = %v, want acme", ProviderFactories())
+	}
+
+	provider, err := NewProvider("acme", NewConfig())
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+	if provider.Name() != "acme" {
+		t.Errorf("Name() = %s, want acme", provider.Name())
+	}
+
+	_, err = NewProvider("missing", NewConfig())
+	var tokenErr *TokenTrackerError
+	if !errors.As(err, &tokenErr) || tokenErr.Type != ErrProviderNotFound {
+		t.Errorf("NewProvider() of an unknown factory error = %v, want %s", err, ErrProviderNotFound)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("RegisterProviderFactory() without a factory should panic")
+		}
+	}()
+	RegisterProviderFactory("broken", nil)
+}
+
+func TestDefaultTokenTracker_RegisterConfiguredProviders(t *testing.T) {
+	RegisterProviderFactory("acme", func(config *Config) Provider {
+		return &MockSimpleProvider{name: "acme", supportedModels: map[string]bool{"acme-1": true}}
+	})
+
+	config := NewConfig()
+	config.Providers["acme"] = ProviderConfig{Models: map[string]ModelPricing{"acme-1": {InputPricePerToken: 0.001}}}
+	config.Providers["acme-eu"] = ProviderConfig{Factory: "acme"}
+	tracker := NewTokenTracker(config)
+
+	existing := &MockSimpleProvider{name: "openai"}
+	tracker.RegisterProvider(existing)
+
+	if err := tracker.RegisterConfiguredProviders(); err != nil {
+		t.Fatalf("RegisterConfiguredProviders() error = %v", err)
+	}
+	if _, exists := tracker.registry.Get("acme"); !exists {
+		t.Error("the configured acme provider should be registered")
+	}
+	if provider, _ := tracker.registry.Get("openai"); provider != existing {
+		t.Error("RegisterConfiguredProviders() should keep registered providers")
+	}
+
+	config.Providers["other"] = ProviderConfig{Factory: "missing"}
+	if err := tracker.RegisterConfiguredProviders(); err == nil {
+		t.Error("RegisterConfiguredProviders() with an unknown factory should fail")
+	}
+}
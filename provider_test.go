@@ -32,8 +32,8 @@ func (p *MockSimpleProvider) SetSDKClient(client interface{}) {
 	// No-op for mock
 }
 
-func (p *MockSimpleProvider) GetModelInfo(model string) (interface{}, error) {
-	return nil, nil
+func (p *MockSimpleProvider) GetModelInfo(model string) (ModelInfo, error) {
+	return ModelInfo{}, nil
 }
 
 func (p *MockSimpleProvider) ExtractTokenUsageFromResponse(response interface{}) (TokenCount, error) {
@@ -0,0 +1,203 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/TrustSight-io/tokentracker"
+	"github.com/TrustSight-io/tokentracker/common"
+)
+
+// Anthropic count_tokens API defaults
+const (
+	DefaultAnthropicBaseURL    = "https://api.anthropic.com"
+	DefaultAnthropicAPIVersion = "2023-06-01"
+)
+
+// anthropicAPIModels maps the short model names used by the tracker to API model IDs
+var anthropicAPIModels = map[string]string{
+	"claude-3-haiku":  "claude-3-haiku-20240307",
+	"claude-3-sonnet": "claude-3-sonnet-20240229",
+	"claude-3-opus":   "claude-3-opus-20240229",
+}
+
+// AnthropicCountTokensRequest is the body of a /v1/messages/count_tokens request, see
+// common.AnthropicCountTokensRequest
+type AnthropicCountTokensRequest = common.AnthropicCountTokensRequest
+
+// AnthropicMessage is a message in Anthropic's Messages API format, see
+// common.AnthropicMessage
+type AnthropicMessage = common.AnthropicMessage
+
+// AnthropicTokenCounter counts input tokens with Anthropic's tokenizer.
+// Setting a client implementing it via ClaudeProvider.SetSDKClient enables exact
+// counting; sdkwrappers.AnthropicSDKWrapper implements it.
+type AnthropicTokenCounter interface {
+	CountTokens(ctx context.Context, req AnthropicCountTokensRequest) (int, error)
+}
+
+// AnthropicCountTokensClient calls Anthropic's /v1/messages/count_tokens endpoint
+type AnthropicCountTokensClient struct {
+	apiKey     string
+	baseURL    string
+	version    string
+	httpClient *http.Client
+}
+
+// NewAnthropicCountTokensClient creates a count_tokens client for the given API key
+func NewAnthropicCountTokensClient(apiKey string) *AnthropicCountTokensClient {
+	return &AnthropicCountTokensClient{
+		apiKey:     apiKey,
+		baseURL:    DefaultAnthropicBaseURL,
+		version:    DefaultAnthropicAPIVersion,
+		httpClient: http.DefaultClient,
+	}
+}
+
+// WithBaseURL sets the API base URL, e.g. for a proxy
+func (c *AnthropicCountTokensClient) WithBaseURL(baseURL string) *AnthropicCountTokensClient {
+	c.baseURL = strings.TrimSuffix(baseURL, "/")
+	return c
+}
+
+// WithHTTPClient sets the HTTP client used for requests
+func (c *AnthropicCountTokensClient) WithHTTPClient(client *http.Client) *AnthropicCountTokensClient {
+	c.httpClient = client
+	return c
+}
+
+// CountTokens returns the number of input tokens Anthropic counts for the request
+func (c *AnthropicCountTokensClient) CountTokens(ctx context.Context, req AnthropicCountTokensRequest) (int, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode count_tokens request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/v1/messages/count_tokens", bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create count_tokens request: %w", err)
+	}
+	httpReq.Header.Set("content-type", "application/json")
+	httpReq.Header.Set("x-api-key", c.apiKey)
+	httpReq.Header.Set("anthropic-version", c.version)
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return 0, fmt.Errorf("count_tokens request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read count_tokens response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("count_tokens returned %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	var result struct {
+		InputTokens int `json:"input_tokens"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return 0, fmt.Errorf("failed to decode count_tokens response: %w", err)
+	}
+
+	return result.InputTokens, nil
+}
+
+// newAnthropicCountTokensRequest converts token count params into a count_tokens request.
+// System messages are moved to the system prompt and OpenAI-style function tools are
+// converted to Anthropic's tool format.
+func newAnthropicCountTokensRequest(params tokentracker.TokenCountParams) AnthropicCountTokensRequest {
+	req := AnthropicCountTokensRequest{Model: params.Model}
+	if apiModel, exists := anthropicAPIModels[params.Model]; exists {
+		req.Model = apiModel
+	}
+
+	if params.Text != nil {
+		req.Messages = []AnthropicMessage{{Role: "user", Content: *params.Text}}
+	}
+
+	var system []string
+	for _, message := range params.Messages {
+		if message.Role == "system" {
+			system = append(system, strings.TrimSpace(tokentracker.ExtractTextFromMessages([]tokentracker.Message{message})))
+			continue
+		}
+		req.Messages = append(req.Messages, anthropicMessage(message))
+	}
+	req.System = strings.Join(system, "\n")
+
+	for _, tool := range params.Tools {
+		function, ok := tool.Function.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		converted := map[string]interface{}{"name": function["name"]}
+		if description, ok := function["description"]; ok {
+			converted["description"] = description
+		}
+		if schema, ok := function["parameters"]; ok {
+			converted["input_schema"] = schema
+		} else {
+			converted["input_schema"] = map[string]interface{}{"type": "object"}
+		}
+		req.Tools = append(req.Tools, converted)
+	}
+
+	return req
+}
+
+// anthropicMessage converts a message into Anthropic's format: tool calls become
+// tool_use blocks and tool results user messages with a tool_result block
+func anthropicMessage(message tokentracker.Message) AnthropicMessage {
+	if message.Role == "tool" {
+		return AnthropicMessage{Role: "user", Content: []map[string]interface{}{{
+			"type":        "tool_result",
+			"tool_use_id": message.ToolCallID,
+			"content":     anthropicContent(message.Content),
+		}}}
+	}
+	if len(message.ToolCalls) == 0 {
+		return AnthropicMessage{Role: message.Role, Content: anthropicContent(message.Content)}
+	}
+
+	var blocks []map[string]interface{}
+	switch content := anthropicContent(message.Content).(type) {
+	case string:
+		if content != "" {
+			blocks = append(blocks, map[string]interface{}{"type": "text", "text": content})
+		}
+	case []map[string]interface{}:
+		blocks = append(blocks, content...)
+	}
+	for _, call := range message.ToolCalls {
+		input := json.RawMessage(call.Arguments)
+		if !json.Valid(input) {
+			input = json.RawMessage("{}")
+		}
+		blocks = append(blocks, map[string]interface{}{"type": "tool_use", "id": call.ID, "name": call.Name, "input": input})
+	}
+	return AnthropicMessage{Role: message.Role, Content: blocks}
+}
+
+// anthropicContent converts message content into Anthropic content blocks
+func anthropicContent(content interface{}) interface{} {
+	parts, ok := content.([]tokentracker.ContentPart)
+	if !ok {
+		return content
+	}
+
+	blocks := make([]map[string]interface{}, 0, len(parts))
+	for _, part := range parts {
+		if part.Type == "text" {
+			blocks = append(blocks, map[string]interface{}{"type": "text", "text": part.Text})
+		}
+	}
+	return blocks
+}
@@ -0,0 +1,210 @@
+package providers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker"
+)
+
+func newCountTokensServer(t *testing.T, status int, calls *int32) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+
+		if r.URL.Path != "/v1/messages/count_tokens" {
+			t.Errorf("path = %s, want /v1/messages/count_tokens", r.URL.Path)
+		}
+		if r.Header.Get("x-api-key") != "test-key" || r.Header.Get("anthropic-version") == "" {
+			t.Errorf("missing authentication headers: %v", r.Header)
+		}
+
+		var req AnthropicCountTokensRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+
+		if status != http.StatusOK {
+			http.Error(w, `{"type":"error"}`, status)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]int{"input_tokens": 42 + len(req.Messages)})
+	}))
+}
+
+func TestNewAnthropicCountTokensRequest(t *testing.T) {
+	req := newAnthropicCountTokensRequest(tokentracker.TokenCountParams{
+		Model: "claude-3-haiku",
+		Messages: []tokentracker.Message{
+			{Role: "system", Content: "Be brief"},
+			{Role: "user", Content: []tokentracker.ContentPart{{Type: "text", Text: "Hi"}}},
+		},
+		Tools: []tokentracker.Tool{{
+			Type: "function",
+			Function: map[string]interface{}{
+				"name":       "get_weather",
+				"parameters": map[string]interface{}{"type": "object"},
+			},
+		}},
+	})
+
+	if req.Model != "claude-3-haiku-20240307" {
+		t.Errorf("Model = %q, want API model id", req.Model)
+	}
+	if req.System != "Be brief" || len(req.Messages) != 1 || req.Messages[0].Role != "user" {
+		t.Errorf("system prompt not separated from messages: %+v", req)
+	}
+	if len(req.Tools) != 1 || req.Tools[0]["name"] != "get_weather" || req.Tools[0]["input_schema"] == nil {
+		t.Errorf("Tools = %+v, want converted tool", req.Tools)
+	}
+}
+
+func TestClaudeProvider_CountTokensWithAPI(t *testing.T) {
+	var calls int32
+	server := newCountTokensServer(t, http.StatusOK, &calls)
+	defer server.Close()
+
+	provider := NewClaudeProvider(tokentracker.NewConfig())
+	provider.SetSDKClient(NewAnthropicCountTokensClient("test-key").WithBaseURL(server.URL))
+
+	text := "Count me exactly via the API"
+	params := tokentracker.TokenCountParams{Model: "claude-3-haiku", Text: &text}
+
+	for i := 0; i < 2; i++ {
+		count, err := provider.CountTokens(params)
+		if err != nil {
+			t.Fatalf("CountTokens() error = %v", err)
+		}
+		if count.InputTokens != 43 {
+			t.Errorf("InputTokens = %d, want 43 from the API", count.InputTokens)
+		}
+	}
+
+	if calls != 1 {
+		t.Errorf("API called %d times, want 1 (second call cached)", calls)
+	}
+}
+
+func TestClaudeProvider_CountTokensOfflineFallback(t *testing.T) {
+	var calls int32
+	server := newCountTokensServer(t, http.StatusInternalServerError, &calls)
+	defer server.Close()
+
+	provider := NewClaudeProvider(tokentracker.NewConfig())
+	provider.SetTokenCounter(NewAnthropicCountTokensClient("test-key").WithBaseURL(server.URL))
+
+	text := "The API is down for this one"
+	params := tokentracker.TokenCountParams{Model: "claude-3-haiku", Text: &text}
+
+	count, err := provider.CountTokens(params)
+	if err != nil {
+		t.Fatalf("CountTokens() with fallback error = %v", err)
+	}
+	if count.InputTokens != provider.approximateTokenCount(text) {
+		t.Errorf("InputTokens = %d, want the approximation", count.InputTokens)
+	}
+
+	provider.SetOfflineFallback(false)
+	if _, err := provider.CountTokens(params); err == nil {
+		t.Error("CountTokens() without fallback should fail when the API fails")
+	}
+}
+
+func TestClaudeProvider_CountTokensResilience(t *testing.T) {
+	var calls int32
+	server := newCountTokensServer(t, http.StatusServiceUnavailable, &calls)
+	defer server.Close()
+
+	var warnings []tokentracker.FallbackWarning
+	config := tokentracker.NewConfig()
+	config.SetResilience(tokentracker.NewResilience(tokentracker.ResilienceOptions{
+		MaxAttempts:      2,
+		Backoff:          time.Millisecond,
+		FailureThreshold: 2,
+		OnFallback:       func(w tokentracker.FallbackWarning) { warnings = append(warnings, w) },
+	}))
+	provider := NewClaudeProvider(config)
+	provider.SetTokenCounter(NewAnthropicCountTokensClient("test-key").WithBaseURL(server.URL))
+
+	text := "Retried, then approximated"
+	params := tokentracker.TokenCountParams{Model: "claude-3-haiku", Text: &text}
+	if _, err := provider.CountTokens(params); err != nil {
+		t.Fatalf("CountTokens() error = %v", err)
+	}
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("count_tokens called %d times, want a retry", got)
+	}
+	if len(warnings) != 1 || warnings[0].Provider != "anthropic" || warnings[0].Fallback != tokentracker.FallbackApproximation {
+		t.Errorf("warnings = %+v, want the approximation reported", warnings)
+	}
+
+	// The circuit is open, so the API is not called again
+	count, err := provider.CountTokens(params)
+	if err != nil || count.InputTokens != provider.approximateTokenCount(text) {
+		t.Errorf("CountTokens() with an open circuit = %+v, %v, want the approximation", count, err)
+	}
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("count_tokens called %d times, want no calls while the circuit is open", got)
+	}
+}
+
+func TestClaudeProvider_ReportedUsageIsEstimatedLocally(t *testing.T) {
+	var calls int32
+	server := newCountTokensServer(t, http.StatusOK, &calls)
+	defer server.Close()
+
+	config := tokentracker.NewConfig()
+	provider := NewClaudeProvider(config)
+	provider.SetSDKClient(NewAnthropicCountTokensClient("test-key").WithBaseURL(server.URL))
+	tracker := tokentracker.NewTokenTracker(config, tokentracker.WithAccuracyTracking())
+	tracker.RegisterProvider(provider)
+
+	text := "Estimate me without a count_tokens request"
+	call := tokentracker.CallParams{Model: "claude-3-haiku", Params: tokentracker.TokenCountParams{Text: &text}, StartTime: time.Now()}
+	metrics, err := tracker.TrackUsage(call, map[string]interface{}{
+		"usage": map[string]interface{}{"input_tokens": float64(12), "output_tokens": float64(5)},
+	})
+	if err != nil {
+		t.Fatalf("TrackUsage() error = %v", err)
+	}
+	if calls != 0 || metrics.TokenCount.InputTokens != 12 || metrics.TokenCount.EstimatedInputTokens == 0 {
+		t.Errorf("API called %d times, usage = %+v, want the reported usage and a local estimate", calls, metrics.TokenCount)
+	}
+
+	// Without reported usage the prompt is counted with the API
+	if _, err := tracker.TrackUsage(call, nil); err != nil {
+		t.Fatalf("TrackUsage() error = %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("API called %d times, want 1 for the call without usage", calls)
+	}
+}
+
+func TestClaudeProvider_APICountsAreNotCalibrated(t *testing.T) {
+	var calls int32
+	server := newCountTokensServer(t, http.StatusOK, &calls)
+	defer server.Close()
+
+	config := tokentracker.NewConfig()
+	provider := NewClaudeProvider(config)
+	provider.SetSDKClient(NewAnthropicCountTokensClient("test-key").WithBaseURL(server.URL))
+	tracker := tokentracker.NewTokenTracker(config, tokentracker.WithCalibration(tokentracker.CalibrationOptions{
+		MinSamples: 1,
+		Factors:    map[string]tokentracker.CalibrationFactor{"claude-3-haiku": {Factor: 0.5, Samples: 10}},
+	}))
+	tracker.RegisterProvider(provider)
+
+	text := "Count me exactly via the API"
+	count, err := tracker.CountTokens(tokentracker.TokenCountParams{Model: "claude-3-haiku", Text: &text})
+	if err != nil {
+		t.Fatalf("CountTokens() error = %v", err)
+	}
+	if !count.CountedByAPI || count.InputTokens != 43 || count.CalibrationFactor != 0 {
+		t.Errorf("CountTokens() = %+v, want the uncorrected API count", count)
+	}
+}
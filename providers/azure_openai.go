@@ -0,0 +1,267 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/TrustSight-io/tokentracker"
+)
+
+// AzureOpenAIProviderName is the provider name of Azure OpenAI
+const AzureOpenAIProviderName = "azure-openai"
+
+// AzurePricingTier is the Azure OpenAI deployment type, which determines the price
+type AzurePricingTier string
+
+// Azure OpenAI pricing tiers
+const (
+	AzureTierGlobal      AzurePricingTier = "global"      // Global Standard deployments
+	AzureTierDataZone    AzurePricingTier = "data-zone"   // Data Zone Standard deployments
+	AzureTierRegional    AzurePricingTier = "regional"    // Regional Standard deployments
+	AzureTierProvisioned AzurePricingTier = "provisioned" // provisioned throughput, billed per PTU hour
+)
+
+// AzureDeployment maps an Azure OpenAI deployment to the model it serves
+type AzureDeployment struct {
+	Name  string // deployment name used in requests
+	Model string // underlying OpenAI model, e.g. gpt-4o
+	Tier  AzurePricingTier
+}
+
+// AzurePricingKey returns the pricing key of a model in a tier, e.g. "gpt-4o@data-zone".
+// Prices set for the key take precedence over those of the bare model name.
+func AzurePricingKey(model string, tier AzurePricingTier) string {
+	if tier == "" {
+		return model
+	}
+	return model + "@" + string(tier)
+}
+
+// AzureOpenAIProvider implements the Provider interface for Azure OpenAI deployments.
+// Calls name the deployment instead of the model; tokens are counted and responses
+// read like OpenAI's, and prices are looked up for the deployment's model and tier.
+type AzureOpenAIProvider struct {
+	config      *tokentracker.Config
+	openai      *OpenAIProvider
+	deployments map[string]AzureDeployment
+	mu          sync.RWMutex
+}
+
+// NewAzureOpenAIProvider creates a new Azure OpenAI provider
+func NewAzureOpenAIProvider(config *tokentracker.Config, deployments ...AzureDeployment) *AzureOpenAIProvider {
+	provider := &AzureOpenAIProvider{
+		config:      config,
+		openai:      NewOpenAIProvider(config),
+		deployments: make(map[string]AzureDeployment),
+	}
+	for _, deployment := range deployments {
+		provider.AddDeployment(deployment)
+	}
+	return provider
+}
+
+// Name returns the provider name
+func (p *AzureOpenAIProvider) Name() string {
+	return AzureOpenAIProviderName
+}
+
+// AddDeployment adds or replaces a deployment. Deployments without a tier are global.
+func (p *AzureOpenAIProvider) AddDeployment(deployment AzureDeployment) {
+	if deployment.Tier == "" {
+		deployment.Tier = AzureTierGlobal
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.deployments[deployment.Name] = deployment
+}
+
+// Deployment returns the deployment with the given name
+func (p *AzureOpenAIProvider) Deployment(name string) (AzureDeployment, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	deployment, exists := p.deployments[name]
+	return deployment, exists
+}
+
+// SupportsModel checks if the given name is a known deployment. Plain model names
+// are left to the OpenAI provider.
+func (p *AzureOpenAIProvider) SupportsModel(model string) bool {
+	_, exists := p.Deployment(model)
+	return exists
+}
+
+// DefaultModels returns the names of the known deployments
+func (p *AzureOpenAIProvider) DefaultModels() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	names := make([]string, 0, len(p.deployments))
+	for name := range p.deployments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CountTokens counts tokens for the given parameters
+func (p *AzureOpenAIProvider) CountTokens(params tokentracker.TokenCountParams) (tokentracker.TokenCount, error) {
+	return p.CountTokensCtx(context.Background(), params)
+}
+
+// CountTokensCtx counts tokens with the tokenizer of the deployment's model
+func (p *AzureOpenAIProvider) CountTokensCtx(ctx context.Context, params tokentracker.TokenCountParams) (tokentracker.TokenCount, error) {
+	deployment, err := p.resolve(params.Model)
+	if err != nil {
+		return tokentracker.TokenCount{}, err
+	}
+
+	params.Model = deployment.Model
+	return p.openai.CountTokensCtx(ctx, params)
+}
+
+// CountTextTokens counts text with the tokenizer of the deployment's model, see
+// tokentracker.TextTokenCounter
+func (p *AzureOpenAIProvider) CountTextTokens(model, text string) (int, error) {
+	deployment, err := p.resolve(model)
+	if err != nil {
+		return 0, err
+	}
+	return p.openai.CountTextTokens(deployment.Model, text)
+}
+
+// TextPromptOverhead returns the tokens added to text prompts: none
+func (p *AzureOpenAIProvider) TextPromptOverhead(string) int {
+	return 0
+}
+
+// CalculatePrice calculates the price of a call to a deployment
+func (p *AzureOpenAIProvider) CalculatePrice(model string, inputTokens, outputTokens int) (tokentracker.Price, error) {
+	return p.CalculateUsagePrice(model, tokentracker.BillableUsage{InputTokens: inputTokens, OutputTokens: outputTokens})
+}
+
+// CalculateUsagePrice calculates the price of a call to a deployment from its billable
+// usage. Provisioned deployments are billed per PTU hour, so their calls have no token cost.
+func (p *AzureOpenAIProvider) CalculateUsagePrice(model string, usage tokentracker.BillableUsage) (tokentracker.Price, error) {
+	deployment, err := p.resolve(model)
+	if err != nil {
+		return tokentracker.Price{}, err
+	}
+
+	pricing, exists := p.pricing(deployment)
+	if !exists {
+		return tokentracker.Price{}, tokentracker.NewError(tokentracker.ErrPricingNotFound, fmt.Sprintf("pricing not found for deployment: %s (%s)", deployment.Name, deployment.Model), nil)
+	}
+	if deployment.Tier == AzureTierProvisioned {
+		pricing = tokentracker.ModelPricing{Currency: pricing.Currency}
+	}
+
+	return pricing.Cost(usage), nil
+}
+
+// pricing looks up the price of a deployment: the Azure price of its model in its
+// tier, then the Azure price of its model, then the OpenAI price of its model
+func (p *AzureOpenAIProvider) pricing(deployment AzureDeployment) (tokentracker.ModelPricing, bool) {
+	if pricing, exists := p.config.GetModelPricing(AzureOpenAIProviderName, AzurePricingKey(deployment.Model, deployment.Tier)); exists {
+		return pricing, true
+	}
+	if pricing, exists := p.config.GetModelPricing(AzureOpenAIProviderName, deployment.Model); exists {
+		return pricing, true
+	}
+	return p.config.GetModelPricing("openai", deployment.Model)
+}
+
+// resolve returns the deployment with the given name
+func (p *AzureOpenAIProvider) resolve(name string) (AzureDeployment, error) {
+	if name == "" {
+		return AzureDeployment{}, tokentracker.NewError(tokentracker.ErrInvalidParams, "model is required", nil)
+	}
+
+	deployment, exists := p.Deployment(name)
+	if !exists {
+		return AzureDeployment{}, tokentracker.NewError(tokentracker.ErrInvalidModel, fmt.Sprintf("unknown Azure OpenAI deployment: %s", name), nil)
+	}
+	return deployment, nil
+}
+
+// SetSDKClient sets the provider-specific SDK client
+func (p *AzureOpenAIProvider) SetSDKClient(client interface{}) {
+	p.openai.SetSDKClient(client)
+}
+
+// GetModelInfo returns information about a deployment. Its pricing reference is the
+// deployed model.
+func (p *AzureOpenAIProvider) GetModelInfo(model string) (tokentracker.ModelInfo, error) {
+	deployment, err := p.resolve(model)
+	if err != nil {
+		return tokentracker.ModelInfo{}, err
+	}
+
+	info, _ := lookupModelInfo(p.config, p.openai.Name(), openAIModelInfo, deployment.Model)
+	info.Name = deployment.Name
+	info.Provider = AzureOpenAIProviderName
+	info.Capabilities = []string{"text", "chat", "function-calling"}
+	info.PricingRef = deployment.Model
+	info.Description = fmt.Sprintf("%s deployment of %s", deployment.Tier, deployment.Model)
+	return info, nil
+}
+
+// ExtractTokenUsageFromResponse extracts token usage from an Azure OpenAI response.
+// Azure responses carry OpenAI's usage block alongside content filter results;
+// embedding responses report no completion tokens.
+func (p *AzureOpenAIProvider) ExtractTokenUsageFromResponse(response interface{}) (tokentracker.TokenCount, error) {
+	if response == nil {
+		return tokentracker.TokenCount{}, tokentracker.NewError(tokentracker.ErrInvalidParams, "response is nil", nil)
+	}
+
+	respMap, ok := response.(map[string]interface{})
+	if !ok {
+		return tokentracker.TokenCount{}, tokentracker.NewError(tokentracker.ErrInvalidParams, "response is not a map", nil)
+	}
+
+	usage, ok := respMap["usage"].(map[string]interface{})
+	if !ok {
+		return tokentracker.TokenCount{}, tokentracker.NewError(tokentracker.ErrInvalidParams, "usage information not found in response", nil)
+	}
+
+	promptTokens, ok := usage["prompt_tokens"].(float64)
+	if !ok {
+		return tokentracker.TokenCount{}, tokentracker.NewError(tokentracker.ErrInvalidParams, "token counts not found in response", nil)
+	}
+	completionTokens, _ := usage["completion_tokens"].(float64)
+	totalTokens, ok := usage["total_tokens"].(float64)
+	if !ok {
+		totalTokens = promptTokens + completionTokens
+	}
+	var cachedTokens, audioTokens float64
+	if details, ok := usage["prompt_tokens_details"].(map[string]interface{}); ok {
+		cachedTokens, _ = details["cached_tokens"].(float64)
+		audioTokens, _ = details["audio_tokens"].(float64)
+	}
+	var reasoningTokens float64
+	if details, ok := usage["completion_tokens_details"].(map[string]interface{}); ok {
+		reasoningTokens, _ = details["reasoning_tokens"].(float64)
+	}
+	model, _ := respMap["model"].(string)
+	toolTokens := toolCallTokens(openAIResponseToolCalls(respMap), int(completionTokens), p.openai.textTokenCounter(model))
+
+	return tokentracker.TokenCount{
+		InputTokens:       int(promptTokens),
+		ResponseTokens:    int(completionTokens),
+		TotalTokens:       int(totalTokens),
+		CachedInputTokens: int(cachedTokens),
+		AudioTokens:       int(audioTokens),
+		ReasoningTokens:   int(reasoningTokens),
+		ToolCallTokens:    toolTokens,
+	}, nil
+}
+
+// UpdatePricing updates the pricing information for this provider. Azure prices
+// follow OpenAI's, which serve as the fallback of every deployment.
+func (p *AzureOpenAIProvider) UpdatePricing() error {
+	return p.openai.UpdatePricing()
+}
@@ -0,0 +1,147 @@
+package providers
+
+import (
+	"testing"
+
+	"github.com/TrustSight-io/tokentracker"
+)
+
+func newAzureTestProvider() (*AzureOpenAIProvider, *tokentracker.Config) {
+	config := tokentracker.NewConfig()
+	provider := NewAzureOpenAIProvider(config,
+		AzureDeployment{Name: "prod-gpt4", Model: "gpt-4"},
+		AzureDeployment{Name: "eu-gpt4", Model: "gpt-4", Tier: AzureTierDataZone},
+		AzureDeployment{Name: "ptu-gpt4", Model: "gpt-4", Tier: AzureTierProvisioned},
+	)
+	return provider, config
+}
+
+func TestAzureOpenAIProvider_Deployments(t *testing.T) {
+	provider, _ := newAzureTestProvider()
+
+	if provider.Name() != "azure-openai" {
+		t.Errorf("Name() = %s, want azure-openai", provider.Name())
+	}
+	if !provider.SupportsModel("prod-gpt4") {
+		t.Error("SupportsModel() should accept deployment names")
+	}
+	if provider.SupportsModel("gpt-4") {
+		t.Error("SupportsModel() should leave plain model names to the OpenAI provider")
+	}
+	if deployment, _ := provider.Deployment("prod-gpt4"); deployment.Tier != AzureTierGlobal {
+		t.Errorf("Tier = %s, want deployments to default to global", deployment.Tier)
+	}
+	if got := provider.DefaultModels(); len(got) != 3 || got[0] != "eu-gpt4" {
+		t.Errorf("DefaultModels() = %v, want the sorted deployment names", got)
+	}
+
+	info, err := provider.GetModelInfo("eu-gpt4")
+	if err != nil {
+		t.Fatalf("GetModelInfo() error = %v", err)
+	}
+	if info.Name != "eu-gpt4" || info.PricingRef != "gpt-4" || info.ContextWindow != 8192 {
+		t.Errorf("GetModelInfo() = %+v, want the deployment priced like its model", info)
+	}
+	if _, err := provider.GetModelInfo("unknown"); err == nil {
+		t.Error("GetModelInfo() of an unknown deployment should fail")
+	}
+}
+
+func TestAzureOpenAIProvider_CalculatePrice(t *testing.T) {
+	provider, config := newAzureTestProvider()
+	config.SetModelPricing(AzureOpenAIProviderName, AzurePricingKey("gpt-4", AzureTierDataZone), tokentracker.ModelPricing{
+		InputPricePerToken:  0.000033,
+		OutputPricePerToken: 0.000066,
+		Currency:            "USD",
+	})
+
+	tests := []struct {
+		deployment string
+		want       float64
+	}{
+		{"prod-gpt4", 1000*0.00003 + 500*0.00006}, // falls back to OpenAI's price
+		{"eu-gpt4", 1000*0.000033 + 500*0.000066}, // tier price
+		{"ptu-gpt4", 0}, // billed per PTU hour
+	}
+	for _, tt := range tests {
+		price, err := provider.CalculatePrice(tt.deployment, 1000, 500)
+		if err != nil {
+			t.Fatalf("CalculatePrice(%s) error = %v", tt.deployment, err)
+		}
+		if !approxEqual(price.TotalCost, tt.want) {
+			t.Errorf("CalculatePrice(%s) = %v, want %v", tt.deployment, price.TotalCost, tt.want)
+		}
+	}
+
+	provider.AddDeployment(AzureDeployment{Name: "custom", Model: "my-finetune"})
+	if _, err := provider.CalculatePrice("custom", 10, 10); err == nil {
+		t.Error("CalculatePrice() of a deployment without pricing should fail")
+	}
+	if _, err := provider.CalculatePrice("gpt-4", 10, 10); err == nil {
+		t.Error("CalculatePrice() of an unknown deployment should fail")
+	}
+}
+
+func TestAzureOpenAIProvider_ExtractTokenUsageFromResponse(t *testing.T) {
+	provider, _ := newAzureTestProvider()
+
+	chat := map[string]interface{}{
+		"id":    "chatcmpl-1",
+		"model": "gpt-4",
+		"prompt_filter_results": []interface{}{
+			map[string]interface{}{"prompt_index": float64(0), "content_filter_results": map[string]interface{}{}},
+		},
+		"usage": map[string]interface{}{"prompt_tokens": float64(20), "completion_tokens": float64(5), "total_tokens": float64(25)},
+	}
+	count, err := provider.ExtractTokenUsageFromResponse(chat)
+	if err != nil {
+		t.Fatalf("ExtractTokenUsageFromResponse() error = %v", err)
+	}
+	if count.InputTokens != 20 || count.ResponseTokens != 5 || count.TotalTokens != 25 {
+		t.Errorf("count = %+v", count)
+	}
+
+	embedding := map[string]interface{}{
+		"usage": map[string]interface{}{"prompt_tokens": float64(8), "total_tokens": float64(8)},
+	}
+	if count, err := provider.ExtractTokenUsageFromResponse(embedding); err != nil || count.InputTokens != 8 || count.ResponseTokens != 0 {
+		t.Errorf("embedding usage = %+v, %v", count, err)
+	}
+
+	if _, err := provider.ExtractTokenUsageFromResponse(map[string]interface{}{}); err == nil {
+		t.Error("ExtractTokenUsageFromResponse() without usage should fail")
+	}
+}
+
+func TestAzureOpenAIProvider_CountTokens(t *testing.T) {
+	provider, _ := newAzureTestProvider()
+
+	if _, err := provider.CountTokens(tokentracker.TokenCountParams{Model: "unknown", Text: StringPtr("Hi")}); err == nil {
+		t.Error("CountTokens() for an unknown deployment should fail")
+	}
+
+	count, err := provider.CountTokens(tokentracker.TokenCountParams{Model: "prod-gpt4", Text: StringPtr("Hello, world!")})
+	skipIfEncodingUnavailable(t, err)
+	if count.InputTokens == 0 {
+		t.Error("CountTokens() should count with the deployment's model")
+	}
+}
+
+func TestAzureOpenAIProvider_Tracking(t *testing.T) {
+	config := tokentracker.NewConfig()
+	tracker := tokentracker.NewTokenTracker(config)
+	tracker.RegisterProvider(NewOpenAIProvider(config))
+	tracker.RegisterProvider(NewAzureOpenAIProvider(config, AzureDeployment{Name: "prod-gpt4", Model: "gpt-4"}))
+
+	price, err := tracker.CalculatePrice("prod-gpt4", 100, 10)
+	if err != nil {
+		t.Fatalf("CalculatePrice() of a deployment error = %v", err)
+	}
+	if !approxEqual(price.TotalCost, 100*0.00003+10*0.00006) {
+		t.Errorf("TotalCost = %v", price.TotalCost)
+	}
+}
+
+func TestAzureOpenAIProvider_CountsSystemInstructionWithText(t *testing.T) {
+	assertCountsSystemInstructionWithText(t, NewAzureOpenAIProvider(tokentracker.NewConfig(), AzureDeployment{Name: "prod-gpt4", Model: "gpt-4"}), "prod-gpt4")
+}
@@ -1,28 +1,32 @@
 package providers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"strings"
+	"sort"
 	"sync"
-	"unicode/utf8"
+	"time"
 
 	"github.com/TrustSight-io/tokentracker"
 )
 
 // ClaudeProvider implements the Provider interface for Claude models
 type ClaudeProvider struct {
-	config    *tokentracker.Config
-	sdkClient interface{}
-	modelInfo map[string]interface{}
-	mu        sync.RWMutex
+	config          *tokentracker.Config
+	sdkClient       interface{}
+	counter         AnthropicTokenCounter
+	offlineFallback bool
+	modelInfo       map[string]tokentracker.ModelInfo
+	mu              sync.RWMutex
 }
 
 // NewClaudeProvider creates a new Claude provider
 func NewClaudeProvider(config *tokentracker.Config) *ClaudeProvider {
 	provider := &ClaudeProvider{
-		config:    config,
-		modelInfo: make(map[string]interface{}),
+		config:          config,
+		offlineFallback: true,
+		modelInfo:       make(map[string]tokentracker.ModelInfo),
 	}
 
 	// Initialize with default model info
@@ -36,87 +40,150 @@ func (p *ClaudeProvider) Name() string {
 	return "anthropic"
 }
 
-// SupportsModel checks if the provider supports a specific model
+// claudeModels lists the models supported out of the box
+var claudeModels = map[string]bool{
+	"claude-3-haiku":  true,
+	"claude-3-sonnet": true,
+	"claude-3-opus":   true,
+	// Add more models as needed
+}
+
+// SupportsModel checks if the provider supports the given model, either out of the
+// box or through the model catalog of the configuration
 func (p *ClaudeProvider) SupportsModel(model string) bool {
-	supportedModels := map[string]bool{
-		"claude-3-haiku":  true,
-		"claude-3-sonnet": true,
-		"claude-3-opus":   true,
-		// Add more models as needed
-	}
+	return claudeModels[model] || p.config.ModelCatalog().Supports(p.Name(), model)
+}
 
-	return supportedModels[model]
+// DefaultModels returns the models the provider supports out of the box
+func (p *ClaudeProvider) DefaultModels() []string {
+	models := make([]string, 0, len(claudeModels))
+	for model := range claudeModels {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+	return models
 }
 
 // CountTokens counts tokens for the given parameters
-// Note: This is a simplified implementation for Claude token counting
-// In a production environment, you would want to use Anthropic's official tokenizer
 func (p *ClaudeProvider) CountTokens(params tokentracker.TokenCountParams) (tokentracker.TokenCount, error) {
+	return p.CountTokensCtx(context.Background(), params)
+}
+
+// CountTokensCtx counts tokens for the given parameters, honoring cancellation of ctx.
+// When a token counter is configured, input tokens are counted by Anthropic's
+// count_tokens API; otherwise (or on API failure, if the offline fallback is enabled)
+// a character-based approximation is used, as it is for contexts marked with
+// tokentracker.ContextWithLocalCounting. API calls go through the resilience layer of
+// the configuration, see tokentracker.Resilience.
+func (p *ClaudeProvider) CountTokensCtx(ctx context.Context, params tokentracker.TokenCountParams) (tokentracker.TokenCount, error) {
+	if err := ctx.Err(); err != nil {
+		return tokentracker.TokenCount{}, err
+	}
+
 	if params.Model == "" {
 		return tokentracker.TokenCount{}, tokentracker.NewError(tokentracker.ErrInvalidParams, "model is required", nil)
 	}
 
+	// Normalize the input before tokenization
+	normalization := p.config.GetNormalization()
+	params = normalization.ApplyToParams(params.WithSystemMessage())
+
+	if params.Text == nil && len(params.Messages) == 0 {
+		return tokentracker.TokenCount{}, tokentracker.NewError(tokentracker.ErrInvalidParams, "either text or messages must be provided", nil)
+	}
+
 	var inputTokens int
+	var countedByAPI bool
 
-	// Count tokens based on input type
-	if params.Text != nil {
-		// Count tokens for text
-		inputTokens = p.approximateTokenCount(*params.Text)
-	} else if len(params.Messages) > 0 {
-		// Count tokens for messages
-		inputTokens = p.countMessageTokens(params.Messages, params.Tools, params.ToolChoice)
+	p.mu.RLock()
+	counter, offlineFallback := p.counter, p.offlineFallback
+	p.mu.RUnlock()
+
+	if counter != nil && !tokentracker.LocalCountingFromContext(ctx) {
+		count, err := p.countTokensWithAPI(ctx, counter, params)
+		switch {
+		case err == nil:
+			inputTokens, countedByAPI = count, true
+		case !offlineFallback || ctx.Err() != nil:
+			return tokentracker.TokenCount{}, tokentracker.NewError(tokentracker.ErrTokenizationFailed, "count_tokens API failed", err)
+		default:
+			p.config.ReportFallback(tokentracker.FallbackWarning{Provider: p.Name(), Operation: "count_tokens", Fallback: tokentracker.FallbackApproximation, Err: err})
+			inputTokens = p.approximateInputTokens(params)
+		}
 	} else {
-		return tokentracker.TokenCount{}, tokentracker.NewError(tokentracker.ErrInvalidParams, "either text or messages must be provided", nil)
+		inputTokens = p.approximateInputTokens(params)
 	}
 
 	// Estimate response tokens if requested
 	var responseTokens int
 	if params.CountResponseTokens {
-		responseTokens = p.estimateResponseTokens(params.Model, inputTokens)
+		responseTokens = p.config.GetResponseEstimator().EstimateResponseTokens(params, inputTokens)
 	}
 
 	return tokentracker.TokenCount{
 		InputTokens:    inputTokens,
 		ResponseTokens: responseTokens,
 		TotalTokens:    inputTokens + responseTokens,
+		Normalization:  normalization.Profile(),
+		CountedByAPI:   countedByAPI,
 	}, nil
 }
 
 // CalculatePrice calculates price based on token usage
 func (p *ClaudeProvider) CalculatePrice(model string, inputTokens, outputTokens int) (tokentracker.Price, error) {
+	return p.CalculateUsagePrice(model, tokentracker.BillableUsage{InputTokens: inputTokens, OutputTokens: outputTokens})
+}
+
+// CalculateUsagePrice calculates price based on billable usage, including cached input
+// tokens, pricing tiers and batch discounts
+func (p *ClaudeProvider) CalculateUsagePrice(model string, usage tokentracker.BillableUsage) (tokentracker.Price, error) {
 	pricing, exists := p.config.GetModelPricing("anthropic", model)
 	if !exists {
 		return tokentracker.Price{}, tokentracker.NewError(tokentracker.ErrPricingNotFound, fmt.Sprintf("pricing not found for model: %s", model), nil)
 	}
 
-	inputCost := float64(inputTokens) * pricing.InputPricePerToken
-	outputCost := float64(outputTokens) * pricing.OutputPricePerToken
-
-	return tokentracker.Price{
-		InputCost:  inputCost,
-		OutputCost: outputCost,
-		TotalCost:  inputCost + outputCost,
-		Currency:   pricing.Currency,
-	}, nil
+	return pricing.Cost(usage), nil
 }
 
-// SetSDKClient sets the provider-specific SDK client
+// SetSDKClient sets the provider-specific SDK client.
+// A client implementing AnthropicTokenCounter is also used for token counting.
 func (p *ClaudeProvider) SetSDKClient(client interface{}) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	p.sdkClient = client
+	if counter, ok := client.(AnthropicTokenCounter); ok {
+		p.counter = counter
+	}
+}
+
+// SetTokenCounter sets the counter used for exact token counting (nil disables it)
+func (p *ClaudeProvider) SetTokenCounter(counter AnthropicTokenCounter) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.counter = counter
+}
+
+// SetOfflineFallback controls whether the approximation is used when the count_tokens
+// API fails. When disabled, API failures are returned as errors. It is enabled by default.
+func (p *ClaudeProvider) SetOfflineFallback(enabled bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.offlineFallback = enabled
 }
 
 // GetModelInfo returns information about a specific model
-func (p *ClaudeProvider) GetModelInfo(model string) (interface{}, error) {
+func (p *ClaudeProvider) GetModelInfo(model string) (tokentracker.ModelInfo, error) {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
-	info, exists := p.modelInfo[model]
+	// Dated versions share the information of their model
+	info, exists := lookupModelInfo(p.config, p.Name(), p.modelInfo, model)
 	if !exists {
-		return nil, tokentracker.NewError(tokentracker.ErrInvalidModel, fmt.Sprintf("model info not found for: %s", model), nil)
+		return tokentracker.ModelInfo{}, tokentracker.NewError(tokentracker.ErrInvalidModel, fmt.Sprintf("model info not found for: %s", model), nil)
 	}
 
+	info.Name = model
+	info.Provider = p.Name()
 	return info, nil
 }
 
@@ -148,10 +215,19 @@ func (p *ClaudeProvider) ExtractTokenUsageFromResponse(response interface{}) (to
 		return tokentracker.TokenCount{}, tokentracker.NewError(tokentracker.ErrInvalidParams, "token counts not found in response", nil)
 	}
 
+	// With prompt caching, input_tokens only counts the tokens after the last cache
+	// breakpoint; tokens read from and written to the cache are reported separately
+	cacheRead, _ := usage["cache_read_input_tokens"].(float64)
+	cacheWrite, _ := usage["cache_creation_input_tokens"].(float64)
+	input := int(inputTokens) + int(cacheRead) + int(cacheWrite)
+
 	return tokentracker.TokenCount{
-		InputTokens:    int(inputTokens),
-		ResponseTokens: int(outputTokens),
-		TotalTokens:    int(inputTokens) + int(outputTokens),
+		InputTokens:       input,
+		ResponseTokens:    int(outputTokens),
+		TotalTokens:       input + int(outputTokens),
+		CachedInputTokens: int(cacheRead),
+		CacheWriteTokens:  int(cacheWrite),
+		ToolCallTokens:    toolCallTokens(claudeResponseToolCalls(respMap), int(outputTokens), p.approximateTokenCount),
 	}, nil
 }
 
@@ -163,59 +239,131 @@ func (p *ClaudeProvider) UpdatePricing() error {
 	// If we have an SDK client, we could use it to fetch the latest pricing
 	// For now, we'll just update with hardcoded values
 
-	// Claude 3 Haiku pricing (as of March 2024)
+	// Claude 3 Haiku pricing (as of March 2024). Cache writes cost 1.25 times the
+	// input price and cache reads 0.1 times.
 	p.config.SetModelPricing("anthropic", "claude-3-haiku", tokentracker.ModelPricing{
-		InputPricePerToken:  0.00000025,
-		OutputPricePerToken: 0.00000125,
-		Currency:            "USD",
+		InputPricePerToken:       0.00000025,
+		OutputPricePerToken:      0.00000125,
+		CachedInputPricePerToken: 0.00000003,
+		CacheWritePricePerToken:  0.0000003,
+		Currency:                 "USD",
 	})
 
 	// Claude 3 Sonnet pricing
 	p.config.SetModelPricing("anthropic", "claude-3-sonnet", tokentracker.ModelPricing{
-		InputPricePerToken:  0.000003,
-		OutputPricePerToken: 0.000015,
-		Currency:            "USD",
+		InputPricePerToken:       0.000003,
+		OutputPricePerToken:      0.000015,
+		CachedInputPricePerToken: 0.0000003,
+		CacheWritePricePerToken:  0.00000375,
+		Currency:                 "USD",
 	})
 
 	// Claude 3 Opus pricing
 	p.config.SetModelPricing("anthropic", "claude-3-opus", tokentracker.ModelPricing{
-		InputPricePerToken:  0.000015,
-		OutputPricePerToken: 0.000075,
-		Currency:            "USD",
+		InputPricePerToken:       0.000015,
+		OutputPricePerToken:      0.000075,
+		CachedInputPricePerToken: 0.0000015,
+		CacheWritePricePerToken:  0.00001875,
+		Currency:                 "USD",
 	})
 
 	return nil
 }
 
-// approximateTokenCount provides an approximate token count for Claude models
-// This is a simplified implementation and should be replaced with Anthropic's official tokenizer
+// claudeSpecialTokens is the overhead of special tokens added to approximate counts
+const claudeSpecialTokens = 5
+
+// approximateTokenCount counts text offline with the tokenizer configured for the
+// provider, ClaudeApproxTokenizer by default
 func (p *ClaudeProvider) approximateTokenCount(text string) int {
-	// Check if we have a cached result
-	if count, exists := tokentracker.GetCachedTokenCount("anthropic", "", text); exists {
-		return count
+	tokenizer, exists := p.config.GetTokenizer("anthropic")
+	if !exists {
+		tokenizer = ClaudeApproxTokenizer{}
 	}
 
-	// Claude uses a tokenizer similar to GPT models but with some differences
-	// A rough approximation is about 4 characters per token for English text
-	// This is a very simplified approach and should be replaced with a proper tokenizer
-	charCount := utf8.RuneCountInString(text)
+	// Cache entries are scoped to the tokenizer and the active normalization profile
+	scope := tokentracker.CacheScope(tokenizer.Name(), p.config.GetNormalization())
 
-	// Claude tends to have slightly fewer tokens than GPT for the same text
-	tokenCount := (charCount * 95) / 400 // Approximately 0.95 * charCount / 4
+	// Check if we have a cached result
+	if count, exists := p.config.GetTokenCache().Get("anthropic", scope, text); exists {
+		return count
+	}
 
 	// Add a small overhead for special tokens
-	tokenCount += 5
+	tokenCount := tokenizer.CountTokens(text) + claudeSpecialTokens
 
 	// Cache the result
-	tokentracker.SetCachedTokenCount("anthropic", "", text, tokenCount)
+	p.config.GetTokenCache().Set("anthropic", scope, text, tokenCount)
 
 	return tokenCount
 }
 
+// CountTextTokens approximates the tokens of normalized text with the offline
+// tokenizer, even when the count_tokens API is enabled, see tokentracker.TextTokenCounter
+func (p *ClaudeProvider) CountTextTokens(_, text string) (int, error) {
+	return p.approximateTokenCount(p.config.GetNormalization().Apply(text)) - claudeSpecialTokens, nil
+}
+
+// TextPromptOverhead returns the special tokens added to approximate counts
+func (p *ClaudeProvider) TextPromptOverhead(string) int {
+	return claudeSpecialTokens
+}
+
+// countTokensWithAPI counts input tokens with the count_tokens API, caching results
+func (p *ClaudeProvider) countTokensWithAPI(ctx context.Context, counter AnthropicTokenCounter, params tokentracker.TokenCountParams) (int, error) {
+	req := newAnthropicCountTokensRequest(params)
+
+	key, err := json.Marshal(req)
+	if err != nil {
+		return 0, err
+	}
+	scope := tokentracker.CacheScope(req.Model, p.config.GetNormalization())
+
+	if count, exists := p.config.GetTokenCache().Get("anthropic-api", scope, string(key)); exists {
+		return count, nil
+	}
+
+	var count int
+	err = p.config.GetResilience().Do(ctx, p.Name(), func(ctx context.Context) error {
+		count, err = counter.CountTokens(ctx, req)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	p.config.GetTokenCache().Set("anthropic-api", scope, string(key), count)
+	return count, nil
+}
+
+// approximateInputTokens estimates input tokens without calling the API
+func (p *ClaudeProvider) approximateInputTokens(params tokentracker.TokenCountParams) int {
+	if params.Text != nil {
+		return p.approximateTokenCount(*params.Text)
+	}
+	return p.countMessageTokens(params.Messages, params.Tools, params.ToolChoice)
+}
+
+// CountMessageTokens counts one message of a conversation, see
+// tokentracker.MessageTokenCounter. Conversations counted with the count_tokens API
+// are not the sum of their messages, so it fails while a token counter is attached.
+func (p *ClaudeProvider) CountMessageTokens(model string, message tokentracker.Message) (int, error) {
+	p.mu.RLock()
+	counter := p.counter
+	p.mu.RUnlock()
+	if counter != nil {
+		return 0, tokentracker.NewError(tokentracker.ErrTokenizationFailed, "messages are counted with the count_tokens API", nil)
+	}
+
+	params := p.config.GetNormalization().ApplyToParams(tokentracker.TokenCountParams{Model: model, Messages: []tokentracker.Message{message}})
+	// The special tokens are counted once per conversation
+	return p.countMessageTokens(params.Messages, nil, nil) - claudeSpecialTokens, nil
+}
+
 // countMessageTokens counts tokens for chat messages
 func (p *ClaudeProvider) countMessageTokens(messages []tokentracker.Message, tools []tokentracker.Tool, toolChoice *tokentracker.ToolChoice) int {
-	// Extract all text from messages
-	allText := tokentracker.ExtractTextFromMessages(messages)
+	// Extract all text from messages, including tool calls and tool results
+	allText := tokentracker.ExtractTextFromMessages(messages) + tokentracker.ExtractToolCallText(messages)
 
 	// Count tokens for the combined text
 	tokens := p.approximateTokenCount(allText)
@@ -243,35 +391,29 @@ func (p *ClaudeProvider) countMessageTokens(messages []tokentracker.Message, too
 	return tokens
 }
 
-// estimateResponseTokens estimates the number of response tokens
-func (p *ClaudeProvider) estimateResponseTokens(model string, inputTokens int) int {
-	// This is a simplified estimation based on the model
-	switch {
-	case strings.Contains(model, "opus"):
-		return inputTokens * 2 // Claude Opus can be quite verbose
-	case strings.Contains(model, "sonnet"):
-		return inputTokens * 3 / 2 // Claude Sonnet is moderately verbose
-	case strings.Contains(model, "haiku"):
-		return inputTokens // Claude Haiku is more concise
-	default:
-		return tokentracker.EstimateResponseTokens(model, inputTokens)
-	}
-}
-
 // initializeModelInfo initializes the model information
 func (p *ClaudeProvider) initializeModelInfo() {
-	p.modelInfo["claude-3-haiku"] = map[string]interface{}{
-		"contextWindow": 200000,
-		"description":   "Claude 3 Haiku - fastest and most compact model",
+	p.modelInfo["claude-3-haiku"] = tokentracker.ModelInfo{
+		ContextWindow:   200000,
+		MaxOutputTokens: 4096,
+		Modalities:      textImageModalities,
+		TrainingCutoff:  trainingCutoff(2023, time.August),
+		Description:     "Claude 3 Haiku - fastest and most compact model",
 	}
 
-	p.modelInfo["claude-3-sonnet"] = map[string]interface{}{
-		"contextWindow": 200000,
-		"description":   "Claude 3 Sonnet - balanced performance and intelligence",
+	p.modelInfo["claude-3-sonnet"] = tokentracker.ModelInfo{
+		ContextWindow:   200000,
+		MaxOutputTokens: 4096,
+		Modalities:      textImageModalities,
+		TrainingCutoff:  trainingCutoff(2023, time.August),
+		Description:     "Claude 3 Sonnet - balanced performance and intelligence",
 	}
 
-	p.modelInfo["claude-3-opus"] = map[string]interface{}{
-		"contextWindow": 200000,
-		"description":   "Claude 3 Opus - most powerful model for complex tasks",
+	p.modelInfo["claude-3-opus"] = tokentracker.ModelInfo{
+		ContextWindow:   200000,
+		MaxOutputTokens: 4096,
+		Modalities:      textImageModalities,
+		TrainingCutoff:  trainingCutoff(2023, time.August),
+		Description:     "Claude 3 Opus - most powerful model for complex tasks",
 	}
 }
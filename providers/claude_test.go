@@ -1,6 +1,10 @@
 package providers
 
 import (
+	"context"
+	"errors"
+	"math"
+	"strings"
 	"testing"
 
 	"github.com/TrustSight-io/tokentracker"
@@ -250,6 +254,13 @@ func TestClaudeProvider_CalculatePrice(t *testing.T) {
 				if price.Currency != "USD" {
 					t.Errorf("CalculatePrice() Currency = %v, want USD", price.Currency)
 				}
+				if !approxEqual(price.EffectiveInputPricePer1K, 0.00025) || !approxEqual(price.EffectiveOutputPricePer1K, 0.00125) {
+					t.Errorf("CalculatePrice() unit prices = %v/%v per 1K, want 0.00025/0.00125",
+						price.EffectiveInputPricePer1K, price.EffectiveOutputPricePer1K)
+				}
+				if !approxEqual(price.BlendedPricePer1K, expectedTotalCost/1.5) {
+					t.Errorf("CalculatePrice() BlendedPricePer1K = %v, want %v", price.BlendedPricePer1K, expectedTotalCost/1.5)
+				}
 			}
 		})
 	}
@@ -336,6 +347,46 @@ func TestClaudeProvider_ExtractTokenUsageFromResponse(t *testing.T) {
 	}
 }
 
+func TestClaudeProvider_PromptCaching(t *testing.T) {
+	config := tokentracker.NewConfig()
+	provider := NewClaudeProvider(config)
+	if err := provider.UpdatePricing(); err != nil {
+		t.Fatalf("UpdatePricing() error = %v", err)
+	}
+
+	response := map[string]interface{}{
+		"id":    "msg_123",
+		"model": "claude-3-sonnet",
+		"usage": map[string]interface{}{
+			"input_tokens":                float64(20),
+			"cache_creation_input_tokens": float64(1000),
+			"cache_read_input_tokens":     float64(9000),
+			"output_tokens":               float64(100),
+		},
+	}
+	count, err := provider.ExtractTokenUsageFromResponse(response)
+	if err != nil {
+		t.Fatalf("ExtractTokenUsageFromResponse() error = %v", err)
+	}
+	if count.InputTokens != 10020 || count.CachedInputTokens != 9000 || count.CacheWriteTokens != 1000 || count.TotalTokens != 10120 {
+		t.Errorf("count = %+v, want the cache tokens included in the input", count)
+	}
+
+	price, err := provider.CalculateUsagePrice("claude-3-sonnet", tokentracker.BillableUsage{
+		InputTokens:       count.InputTokens,
+		OutputTokens:      count.ResponseTokens,
+		CachedInputTokens: count.CachedInputTokens,
+		CacheWriteTokens:  count.CacheWriteTokens,
+	})
+	if err != nil {
+		t.Fatalf("CalculateUsagePrice() error = %v", err)
+	}
+	wantInput := 20*0.000003 + 1000*0.00000375 + 9000*0.0000003
+	if math.Abs(price.InputCost-wantInput) > 1e-12 {
+		t.Errorf("InputCost = %v, want %v with cache reads at 0.1x and writes at 1.25x", price.InputCost, wantInput)
+	}
+}
+
 func TestClaudeProvider_GetModelInfo(t *testing.T) {
 	config := tokentracker.NewConfig()
 	provider := NewClaudeProvider(config)
@@ -364,6 +415,12 @@ func TestClaudeProvider_GetModelInfo(t *testing.T) {
 			wantErr:   false,
 			checkInfo: true,
 		},
+		{
+			name:      "Dated Claude Haiku",
+			model:     "claude-3-haiku-20240307",
+			wantErr:   false,
+			checkInfo: true,
+		},
 		{
 			name:    "Unsupported model",
 			model:   "unsupported-model",
@@ -383,26 +440,19 @@ func TestClaudeProvider_GetModelInfo(t *testing.T) {
 			}
 
 			if tt.checkInfo {
-				modelInfo, ok := info.(map[string]interface{})
-				if !ok {
-					t.Errorf("GetModelInfo() did not return a map[string]interface{}")
-					return
-				}
-
 				// Check context window
-				contextWindow, exists := modelInfo["contextWindow"]
-				if !exists {
-					t.Errorf("GetModelInfo() did not include contextWindow")
-				} else if contextWindow.(int) <= 0 {
-					t.Errorf("GetModelInfo() contextWindow = %v, expected > 0", contextWindow)
+				if info.ContextWindow <= 0 {
+					t.Errorf("GetModelInfo() ContextWindow = %v, expected > 0", info.ContextWindow)
 				}
 
 				// Check description
-				description, exists := modelInfo["description"]
-				if !exists {
-					t.Errorf("GetModelInfo() did not include description")
-				} else if description.(string) == "" {
-					t.Errorf("GetModelInfo() description is empty")
+				if info.Description == "" {
+					t.Errorf("GetModelInfo() Description is empty")
+				}
+
+				// Check modalities
+				if len(info.Modalities) == 0 || info.Modalities[0] != "text" {
+					t.Errorf("GetModelInfo() Modalities = %v, want text input", info.Modalities)
 				}
 			}
 		})
@@ -445,3 +495,164 @@ func TestClaudeProvider_UpdatePricing(t *testing.T) {
 		}
 	}
 }
+
+func TestClaudeProvider_UpdatePricingKeepsEnvOverrides(t *testing.T) {
+	config := tokentracker.NewConfig()
+	provider := NewClaudeProvider(config)
+	if err := config.ApplyEnv([]string{"TOKENTRACKER_PRICING_ANTHROPIC_CLAUDE_3_HAIKU_INPUT=0.000001"}); err != nil {
+		t.Fatalf("ApplyEnv() error = %v", err)
+	}
+
+	if err := provider.UpdatePricing(); err != nil {
+		t.Fatalf("UpdatePricing() error = %v", err)
+	}
+	if pricing, _ := config.GetModelPricing("anthropic", "claude-3-haiku"); pricing.InputPricePerToken != 0.000001 {
+		t.Errorf("InputPricePerToken = %v after UpdatePricing(), want the environment override", pricing.InputPricePerToken)
+	}
+}
+
+func TestClaudeProvider_CountTokensNormalization(t *testing.T) {
+	config := tokentracker.NewConfig()
+	provider := NewClaudeProvider(config)
+
+	raw := "Hello   world\n\n\n"
+	clean := "Hello world"
+
+	before, err := provider.CountTokens(tokentracker.TokenCountParams{Model: "claude-3-haiku", Text: &raw})
+	if err != nil {
+		t.Fatalf("CountTokens() error = %v", err)
+	}
+	if before.Normalization != "" {
+		t.Errorf("Normalization = %q, want empty when disabled", before.Normalization)
+	}
+
+	config.SetNormalization(tokentracker.NormalizationOptions{CollapseWhitespace: true, TrimTrailingNewlines: true})
+
+	normalized, err := provider.CountTokens(tokentracker.TokenCountParams{Model: "claude-3-haiku", Text: &raw})
+	if err != nil {
+		t.Fatalf("CountTokens() error = %v", err)
+	}
+	expected, _ := provider.CountTokens(tokentracker.TokenCountParams{Model: "claude-3-haiku", Text: &clean})
+
+	if normalized.InputTokens != expected.InputTokens {
+		t.Errorf("normalized InputTokens = %d, want %d (same as pre-normalized text)", normalized.InputTokens, expected.InputTokens)
+	}
+	if normalized.Normalization != "ws+trim-nl" {
+		t.Errorf("Normalization = %q, want %q", normalized.Normalization, "ws+trim-nl")
+	}
+}
+
+func TestClaudeProvider_CountTokensCtx(t *testing.T) {
+	provider := NewClaudeProvider(tokentracker.NewConfig())
+	text := "Hello, world!"
+	params := tokentracker.TokenCountParams{Model: "claude-3-haiku", Text: &text}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := provider.CountTokensCtx(ctx, params); !errors.Is(err, context.Canceled) {
+		t.Errorf("CountTokensCtx() with canceled context error = %v, want context.Canceled", err)
+	}
+
+	withCtx, err := provider.CountTokensCtx(context.Background(), params)
+	if err != nil {
+		t.Fatalf("CountTokensCtx() error = %v", err)
+	}
+	plain, _ := provider.CountTokens(params)
+	if withCtx != plain {
+		t.Errorf("CountTokensCtx() = %+v, CountTokens() = %+v", withCtx, plain)
+	}
+}
+
+// approxEqual compares floats up to rounding errors
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-12
+}
+
+func TestClaudeProvider_ModelCatalog(t *testing.T) {
+	config := tokentracker.NewConfig()
+	provider := NewClaudeProvider(config)
+
+	if !provider.SupportsModel("claude-3-5-sonnet-20240620") {
+		t.Error("SupportsModel() should accept models of the catalog")
+	}
+	price, err := provider.CalculatePrice("claude-3-5-sonnet-20240620", 1000, 0)
+	if err != nil {
+		t.Fatalf("CalculatePrice() error = %v", err)
+	}
+	want, _ := provider.CalculatePrice("claude-3-sonnet", 1000, 0)
+	if price.TotalCost != want.TotalCost {
+		t.Errorf("TotalCost = %v, want claude-3-sonnet's %v", price.TotalCost, want.TotalCost)
+	}
+}
+
+func TestClaudeProvider_CountMessageTokens(t *testing.T) {
+	config := tokentracker.NewConfig()
+	tracker := tokentracker.NewTokenTracker(config)
+	provider := NewClaudeProvider(config)
+	tracker.RegisterProvider(provider)
+	counter := tracker.NewConversationCounter(tokentracker.TokenCacheOptions{})
+
+	messages := []tokentracker.Message{
+		{Role: "user", Content: "What is the capital of France?"},
+		{Role: "assistant", Content: "The capital of France is Paris."},
+		{Role: "user", Content: "And of Italy?"},
+	}
+	tools := []tokentracker.Tool{{Type: "function", Function: map[string]interface{}{"name": "lookup"}}}
+	for n := 1; n <= len(messages); n++ {
+		params := tokentracker.TokenCountParams{Model: "claude-3-haiku", Messages: messages[:n], Tools: tools}
+		want, err := provider.CountTokens(params)
+		if err != nil {
+			t.Fatalf("CountTokens() error = %v", err)
+		}
+		got, err := counter.CountTokens(params)
+		if err != nil {
+			t.Fatalf("ConversationCounter.CountTokens() error = %v", err)
+		}
+		if got.InputTokens != want.InputTokens {
+			t.Errorf("%d messages: InputTokens = %d, want %d", n, got.InputTokens, want.InputTokens)
+		}
+	}
+
+	// Conversations counted with the API are not the sum of their messages
+	provider.SetTokenCounter(NewAnthropicCountTokensClient("test-key"))
+	if _, err := provider.CountMessageTokens("claude-3-haiku", messages[0]); err == nil {
+		t.Error("CountMessageTokens() with a token counter should fail")
+	}
+}
+
+func BenchmarkClaudeProvider_CountTokensCached(b *testing.B) {
+	provider := NewClaudeProvider(tokentracker.NewConfig())
+	text := strings.Repeat("The quick brown fox jumps over the lazy dog. ", 200)
+	messages := []tokentracker.Message{
+		{Role: "system", Content: "You are a helpful assistant."},
+		{Role: "user", Content: text},
+	}
+
+	for _, bench := range []struct {
+		name   string
+		params tokentracker.TokenCountParams
+	}{
+		{"Text", tokentracker.TokenCountParams{Model: "claude-3-haiku", Text: &text}},
+		{"Messages", tokentracker.TokenCountParams{Model: "claude-3-haiku", Messages: messages}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			if _, err := provider.CountTokens(bench.params); err != nil {
+				b.Fatalf("CountTokens() error = %v", err)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, _ = provider.CountTokens(bench.params)
+			}
+		})
+	}
+}
+
+func TestClaudeProvider_CountsTemplatesAsRenderedPrompts(t *testing.T) {
+	assertTemplateCountMatchesRendered(t, NewClaudeProvider(tokentracker.NewConfig()), "claude-3-haiku")
+}
+
+func TestClaudeProvider_CountsSystemInstructionWithText(t *testing.T) {
+	assertCountsSystemInstructionWithText(t, NewClaudeProvider(tokentracker.NewConfig()), "claude-3-haiku")
+}
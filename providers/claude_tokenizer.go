@@ -0,0 +1,231 @@
+package providers
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/TrustSight-io/tokentracker"
+	"github.com/pkoukk/tiktoken-go"
+)
+
+// Names of the built-in offline tokenizers
+const (
+	// ClaudeApproxTokenizerName is the default offline tokenizer of Claude models
+	ClaudeApproxTokenizerName = "claude-approx"
+)
+
+// GPT2Pattern is the pre-tokenization pattern of GPT-2 style BPE vocabularies, splitting
+// text into contractions, words, numbers, punctuation and whitespace
+const GPT2Pattern = `'s|'t|'re|'ve|'m|'ll|'d| ?\p{L}+| ?\p{N}+| ?[^\s\p{L}\p{N}]+|\s+(?!\S)|\s+`
+
+func init() {
+	tokentracker.RegisterTokenizer(ClaudeApproxTokenizer{})
+}
+
+// ClaudeApproxTokenizer approximates the tokenizer of Claude models without a vocabulary;
+// its counts are estimates, not Claude's tokenization. It splits text into words, numbers, punctuation and whitespace like a BPE pre-tokenizer
+// and estimates the pieces each splits into, which follows the real counts of code,
+// non-English text and numbers much closer than a characters-per-token ratio.
+type ClaudeApproxTokenizer struct{}
+
+// Name returns the name of the tokenizer
+func (ClaudeApproxTokenizer) Name() string {
+	return ClaudeApproxTokenizerName
+}
+
+// CountTokens returns the approximate number of tokens of text
+func (ClaudeApproxTokenizer) CountTokens(text string) int {
+	tokens := 0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case r == ' ' && i+size < len(text) && isWordRune(text[i+size:]):
+			// A single space is merged into the word following it
+			i += size
+		case unicode.IsSpace(r):
+			n, newlines := spanWhitespace(text[i:])
+			if newlines > 0 {
+				tokens++ // runs of newlines are merged
+			}
+			if indent := n - newlines; indent > 1 {
+				tokens += (indent + 3) / 4
+			}
+			i += n
+		case isCJK(r):
+			tokens++
+			i += size
+		case unicode.IsLetter(r):
+			n, runes, ascii := spanLetters(text[i:])
+			tokens += wordTokens(runes, ascii)
+			i += n
+		case unicode.IsDigit(r):
+			n := spanFunc(text[i:], unicode.IsDigit)
+			tokens += (utf8.RuneCountInString(text[i:i+n]) + 2) / 3
+			i += n
+		case unicode.IsPunct(r):
+			n := spanFunc(text[i:], func(c rune) bool { return c == r })
+			tokens += 1 + (n/size-1)/8 // repeated punctuation like "----" merges
+			i += n
+		default:
+			tokens += 2 // emoji and symbols are split into bytes
+			i += size
+		}
+	}
+	return tokens
+}
+
+// wordTokens estimates the tokens of a word: short words are a single token, longer
+// words split into pieces of about four characters, and words of other scripts than
+// Latin into pieces of about two
+func wordTokens(runes int, ascii bool) int {
+	if !ascii {
+		return (runes + 1) / 2
+	}
+	if runes <= 7 {
+		return 1
+	}
+	return 1 + (runes-7+3)/4
+}
+
+// isWordRune reports whether text starts with a letter or digit
+func isWordRune(text string) bool {
+	r, _ := utf8.DecodeRuneInString(text)
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// isCJK reports whether r is a Chinese, Japanese or Korean character, which are mostly
+// a token each
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// spanLetters returns the length in bytes and runes of the letters text starts with, and
+// whether they are all ASCII
+func spanLetters(text string) (n, runes int, ascii bool) {
+	ascii = true
+	for n < len(text) {
+		r, size := utf8.DecodeRuneInString(text[n:])
+		if !unicode.IsLetter(r) || isCJK(r) {
+			break
+		}
+		if r >= utf8.RuneSelf {
+			ascii = false
+		}
+		n += size
+		runes++
+	}
+	return n, runes, ascii
+}
+
+// spanWhitespace returns the length of the whitespace text starts with and the number
+// of newlines in it
+func spanWhitespace(text string) (n, newlines int) {
+	for n < len(text) {
+		r, size := utf8.DecodeRuneInString(text[n:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		if r == '\n' {
+			newlines++
+		}
+		n += size
+	}
+	return n, newlines
+}
+
+// spanFunc returns the length of the runes satisfying f text starts with
+func spanFunc(text string, f func(rune) bool) int {
+	n := 0
+	for n < len(text) {
+		r, size := utf8.DecodeRuneInString(text[n:])
+		if !f(r) {
+			break
+		}
+		n += size
+	}
+	return n
+}
+
+// BPETokenizer counts tokens exactly with a byte pair encoding vocabulary, such as a
+// vocabulary published for a model family, without network access
+type BPETokenizer struct {
+	name     string
+	encoding *tiktoken.Tiktoken
+}
+
+// NewBPETokenizer creates a tokenizer from a vocabulary in the tiktoken format: a line
+// per token with the base64-encoded token bytes and its merge rank, separated by a
+// space. pattern is the pre-tokenization pattern; empty means GPT2Pattern.
+func NewBPETokenizer(name, pattern string, vocabulary io.Reader) (*BPETokenizer, error) {
+	if name == "" {
+		return nil, tokentracker.NewError(tokentracker.ErrInvalidParams, "tokenizer name is required", nil)
+	}
+	if pattern == "" {
+		pattern = GPT2Pattern
+	}
+
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(vocabulary)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, tokentracker.NewError(tokentracker.ErrInvalidParams, fmt.Sprintf("vocabulary line %d: want a token and a rank", line), nil)
+		}
+		token, err := base64.StdEncoding.DecodeString(fields[0])
+		if err != nil {
+			return nil, tokentracker.NewError(tokentracker.ErrInvalidParams, fmt.Sprintf("vocabulary line %d: invalid token", line), err)
+		}
+		rank, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, tokentracker.NewError(tokentracker.ErrInvalidParams, fmt.Sprintf("vocabulary line %d: invalid rank", line), err)
+		}
+		ranks[string(token)] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, tokentracker.NewError(tokentracker.ErrInvalidParams, "failed to read vocabulary", err)
+	}
+	// Byte pair encoding falls back to single bytes, so every byte needs a rank
+	for b := 0; b < 256; b++ {
+		if _, exists := ranks[string([]byte{byte(b)})]; !exists {
+			return nil, tokentracker.NewError(tokentracker.ErrInvalidParams, fmt.Sprintf("vocabulary has no token for byte %#x", b), nil)
+		}
+	}
+
+	bpe, err := tiktoken.NewCoreBPE(ranks, map[string]int{}, pattern)
+	if err != nil {
+		return nil, tokentracker.NewError(tokentracker.ErrInvalidParams, "invalid pre-tokenization pattern", err)
+	}
+	encoding := &tiktoken.Encoding{Name: name, PatStr: pattern, MergeableRanks: ranks, SpecialTokens: map[string]int{}}
+	return &BPETokenizer{name: name, encoding: tiktoken.NewTiktoken(bpe, encoding, map[string]any{})}, nil
+}
+
+// LoadBPETokenizer creates a tokenizer from a vocabulary file in the tiktoken format
+func LoadBPETokenizer(name, pattern, path string) (*BPETokenizer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, tokentracker.NewError(tokentracker.ErrInvalidParams, "failed to open vocabulary", err)
+	}
+	defer file.Close()
+
+	return NewBPETokenizer(name, pattern, file)
+}
+
+// Name returns the name of the tokenizer
+func (t *BPETokenizer) Name() string {
+	return t.name
+}
+
+// CountTokens returns the number of tokens of text
+func (t *BPETokenizer) CountTokens(text string) int {
+	return len(t.encoding.EncodeOrdinary(text))
+}
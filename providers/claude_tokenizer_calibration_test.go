@@ -0,0 +1,80 @@
+//go:build integration
+// +build integration
+
+package providers
+
+import (
+	"context"
+	"math"
+	"os"
+	"testing"
+	"time"
+)
+
+// Target error bounds of ClaudeApproxTokenizer against the count_tokens API: the whole
+// corpus and each kind of text
+const (
+	claudeApproxCorpusErrorBound = 0.10
+	claudeApproxTextErrorBound   = 0.20
+)
+
+// claudeCalibrationCorpus are texts of the kinds prompts are made of
+var claudeCalibrationCorpus = []struct {
+	kind string
+	text string
+}{
+	{"prose", "The quarterly report shows revenue grew by eleven percent, driven mostly by the new subscription tier. Churn stayed flat, while support tickets dropped after the onboarding redesign shipped in March."},
+	{"instructions", "You are a helpful assistant. Answer the customer's question politely and concisely. If you do not know the answer, say so and suggest contacting support@example.com."},
+	{"code", "func (s *Server) handle(w http.ResponseWriter, r *http.Request) {\n\tctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)\n\tdefer cancel()\n\tif err := s.store.Save(ctx, r.Body); err != nil {\n\t\thttp.Error(w, err.Error(), http.StatusInternalServerError)\n\t\treturn\n\t}\n\tw.WriteHeader(http.StatusNoContent)\n}\n"},
+	{"json", `{"order_id": "A-10293", "items": [{"sku": "KB-201", "quantity": 2, "price": 49.99}, {"sku": "MS-044", "quantity": 1, "price": 19.5}], "shipping": {"method": "express", "country": "DE"}}`},
+	{"numbers", "2024-03-01 12:45:09 latency_ms=184 status=200 bytes=53211\n2024-03-01 12:45:10 latency_ms=97 status=200 bytes=1204\n2024-03-01 12:45:12 latency_ms=2310 status=504 bytes=0"},
+	{"german", "Die Bundesregierung hat am Mittwoch neue Förderprogramme für energieeffiziente Gebäudesanierungen beschlossen, die ab dem kommenden Jahr beantragt werden können."},
+	{"chinese", "今天的会议讨论了新产品的发布计划，市场部门将在下个月开始宣传活动。"},
+}
+
+// TestClaudeApproxTokenizer_Calibration measures the error of the approximate tokenizer
+// against count_tokens. It needs ANTHROPIC_API_KEY and makes a request per text.
+func TestClaudeApproxTokenizer_Calibration(t *testing.T) {
+	apiKey := os.Getenv("ANTHROPIC_API_KEY")
+	if apiKey == "" {
+		t.Skip("ANTHROPIC_API_KEY is not set")
+	}
+	client := NewAnthropicCountTokensClient(apiKey)
+	count := func(text string) int {
+		t.Helper()
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		tokens, err := client.CountTokens(ctx, AnthropicCountTokensRequest{
+			Model:    anthropicAPIModels["claude-3-haiku"],
+			Messages: []AnthropicMessage{{Role: "user", Content: text}},
+		})
+		if err != nil {
+			t.Fatalf("CountTokens() error = %v", err)
+		}
+		return tokens
+	}
+
+	// A message of a single token measures the framing count_tokens adds to a message
+	overhead := count("x") - 1
+
+	tokenizer := ClaudeApproxTokenizer{}
+	var approxTotal, actualTotal int
+	for _, sample := range claudeCalibrationCorpus {
+		actual := count(sample.text) - overhead
+		approx := tokenizer.CountTokens(sample.text)
+		approxTotal += approx
+		actualTotal += actual
+
+		relative := math.Abs(float64(approx-actual)) / float64(actual)
+		t.Logf("%-12s approx %4d, count_tokens %4d, error %5.1f%%", sample.kind, approx, actual, 100*relative)
+		if relative > claudeApproxTextErrorBound {
+			t.Errorf("%s: approximated %d tokens, count_tokens counted %d", sample.kind, approx, actual)
+		}
+	}
+
+	relative := math.Abs(float64(approxTotal-actualTotal)) / float64(actualTotal)
+	t.Logf("corpus       approx %4d, count_tokens %4d, error %5.1f%%", approxTotal, actualTotal, 100*relative)
+	if relative > claudeApproxCorpusErrorBound {
+		t.Errorf("corpus approximated as %d tokens, count_tokens counted %d", approxTotal, actualTotal)
+	}
+}
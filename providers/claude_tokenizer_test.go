@@ -0,0 +1,113 @@
+package providers
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/TrustSight-io/tokentracker"
+)
+
+func TestClaudeApproxTokenizer_CountTokens(t *testing.T) {
+	tokenizer := ClaudeApproxTokenizer{}
+
+	tests := []struct {
+		name     string
+		text     string
+		min, max int
+	}{
+		{"empty", "", 0, 0},
+		{"short words", "The cat sat on the mat.", 6, 8},
+		{"long words", "internationalization considerations", 4, 8},
+		{"numbers", "1234567890", 3, 5},
+		{"code", "func main() {\n\tfmt.Println(\"hello\")\n}\n", 10, 20},
+		{"chinese", "你好世界", 4, 4},
+		{"repeated punctuation", "----------------", 1, 3},
+		{"indentation", "\n        return", 2, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tokenizer.CountTokens(tt.text); got < tt.min || got > tt.max {
+				t.Errorf("CountTokens(%q) = %d, want between %d and %d", tt.text, got, tt.min, tt.max)
+			}
+		})
+	}
+
+	if _, exists := tokentracker.LookupTokenizer(ClaudeApproxTokenizerName); !exists {
+		t.Errorf("%s is not registered", ClaudeApproxTokenizerName)
+	}
+}
+
+// testVocabulary returns a vocabulary of all bytes and the given merges, in the tiktoken format
+func testVocabulary(merges ...string) string {
+	var b strings.Builder
+	rank := 0
+	for i := 0; i < 256; i++ {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte{byte(i)}), rank)
+		rank++
+	}
+	for _, merge := range merges {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(merge)), rank)
+		rank++
+	}
+	return b.String()
+}
+
+func TestBPETokenizer(t *testing.T) {
+	tokenizer, err := NewBPETokenizer("test-bpe", "", strings.NewReader(testVocabulary("he", "ll", "hell", "hello", " w", " wo", "or", " wor", " world")))
+	if err != nil {
+		t.Fatalf("NewBPETokenizer() error = %v", err)
+	}
+	if tokenizer.Name() != "test-bpe" {
+		t.Errorf("Name() = %s, want test-bpe", tokenizer.Name())
+	}
+	if got := tokenizer.CountTokens("hello world"); got != 2 {
+		t.Errorf("CountTokens(hello world) = %d, want 2", got)
+	}
+	if got := tokenizer.CountTokens("help"); got != 3 {
+		t.Errorf("CountTokens(help) = %d, want 3", got)
+	}
+
+	if _, err := NewBPETokenizer("test-bpe", "", strings.NewReader("aGk= 0\n")); err == nil {
+		t.Error("NewBPETokenizer() of a vocabulary without all bytes should fail")
+	}
+	if _, err := NewBPETokenizer("test-bpe", "", strings.NewReader("not-base64! 0\n")); err == nil {
+		t.Error("NewBPETokenizer() of an invalid vocabulary should fail")
+	}
+	if _, err := LoadBPETokenizer("test-bpe", "", "missing.tiktoken"); err == nil {
+		t.Error("LoadBPETokenizer() of a missing file should fail")
+	}
+}
+
+func TestClaudeProvider_ConfiguredTokenizer(t *testing.T) {
+	tokenizer, err := NewBPETokenizer("test-claude-bpe", "", strings.NewReader(testVocabulary("he", "ll", "hell", "hello")))
+	if err != nil {
+		t.Fatalf("NewBPETokenizer() error = %v", err)
+	}
+	tokentracker.RegisterTokenizer(tokenizer)
+
+	config := tokentracker.NewConfig()
+	provider := NewClaudeProvider(config)
+	params := tokentracker.TokenCountParams{Model: "claude-3-haiku", Text: StringPtr("hello hello")}
+
+	count, err := provider.CountTokens(params)
+	if err != nil {
+		t.Fatalf("CountTokens() error = %v", err)
+	}
+	if want := (ClaudeApproxTokenizer{}).CountTokens("hello hello") + 5; count.InputTokens != want {
+		t.Errorf("InputTokens = %d, want %d with the default tokenizer", count.InputTokens, want)
+	}
+
+	if err := config.SetTokenizer("anthropic", "test-claude-bpe"); err != nil {
+		t.Fatalf("SetTokenizer() error = %v", err)
+	}
+	count, err = provider.CountTokens(params)
+	if err != nil {
+		t.Fatalf("CountTokens() error = %v", err)
+	}
+	// "hello", " " and "hello" plus the overhead; cached counts of the default are not reused
+	if want := 3 + 5; count.InputTokens != want {
+		t.Errorf("InputTokens = %d, want %d with the configured tokenizer", count.InputTokens, want)
+	}
+}
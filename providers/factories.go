@@ -0,0 +1,21 @@
+package providers
+
+import "github.com/TrustSight-io/tokentracker"
+
+// init registers factories for the built-in providers, so importing this package makes
+// them available to tokentracker.NewProvider and RegisterConfiguredProviders. Azure
+// OpenAI is not registered because it needs its deployments.
+func init() {
+	tokentracker.RegisterProviderFactory("openai", func(config *tokentracker.Config) tokentracker.Provider {
+		return NewOpenAIProvider(config)
+	})
+	tokentracker.RegisterProviderFactory("anthropic", func(config *tokentracker.Config) tokentracker.Provider {
+		return NewClaudeProvider(config)
+	})
+	tokentracker.RegisterProviderFactory("gemini", func(config *tokentracker.Config) tokentracker.Provider {
+		return NewGeminiProvider(config)
+	})
+	tokentracker.RegisterProviderFactory("mistral", func(config *tokentracker.Config) tokentracker.Provider {
+		return NewMistralProvider(config)
+	})
+}
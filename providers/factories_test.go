@@ -0,0 +1,33 @@
+package providers
+
+import (
+	"testing"
+
+	"github.com/TrustSight-io/tokentracker"
+)
+
+func TestBuiltInProviderFactories(t *testing.T) {
+	config := tokentracker.NewConfig()
+
+	for _, name := range []string{"openai", "anthropic", "gemini", "mistral"} {
+		provider, err := tokentracker.NewProvider(name, config)
+		if err != nil {
+			t.Fatalf("NewProvider(%s) error = %v", name, err)
+		}
+		if provider.Name() != name {
+			t.Errorf("NewProvider(%s).Name() = %s", name, provider.Name())
+		}
+	}
+
+	tracker := tokentracker.NewTokenTracker(config)
+	if err := tracker.RegisterConfiguredProviders(); err != nil {
+		t.Fatalf("RegisterConfiguredProviders() error = %v", err)
+	}
+	price, err := tracker.CalculatePrice("mistral-small", 1000, 0)
+	if err != nil {
+		t.Fatalf("CalculatePrice() error = %v", err)
+	}
+	if !approxEqual(price.TotalCost, 0.002) {
+		t.Errorf("TotalCost = %v, want the configured mistral-small price", price.TotalCost)
+	}
+}
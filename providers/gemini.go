@@ -1,22 +1,76 @@
 package providers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"unicode/utf8"
 
 	"github.com/TrustSight-io/tokentracker"
+	"github.com/TrustSight-io/tokentracker/common"
 )
 
+// GeminiTokenCounter counts input tokens with Gemini's tokenizer, e.g. through the genai
+// SDK's GenerativeModel.CountTokens. sdkwrappers.GeminiSDKWrapper implements it.
+type GeminiTokenCounter interface {
+	CountTokens(ctx context.Context, model string, parts []string) (int, error)
+}
+
+// GeminiRequestTokenCounter counts whole requests the way Gemini's countTokens does,
+// with the system instruction and tool declarations in fields of their own, so counts
+// match the prompt tokens of UsageMetadata. sdkwrappers.GeminiSDKWrapper implements it;
+// counters implementing only GeminiTokenCounter receive them as text parts.
+type GeminiRequestTokenCounter interface {
+	CountRequestTokens(ctx context.Context, model string, request GeminiCountRequest) (int, error)
+}
+
+// GeminiCountRequest is a request counted by a GeminiRequestTokenCounter, see
+// common.GeminiCountRequest
+type GeminiCountRequest = common.GeminiCountRequest
+
+// GeminiFunctionDeclaration is a function declared to Gemini, see
+// common.GeminiFunctionDeclaration
+type GeminiFunctionDeclaration = common.GeminiFunctionDeclaration
+
+// GeminiFunctionDeclarations returns the function declarations of tools, which take
+// the function definitions of Tool.Function as in OpenAI's format: an object with a
+// name, description and parameters schema. Tools that are not functions are skipped.
+func GeminiFunctionDeclarations(tools []tokentracker.Tool) []GeminiFunctionDeclaration {
+	var declarations []GeminiFunctionDeclaration
+	for _, tool := range tools {
+		if (tool.Type != "" && tool.Type != "function") || tool.Function == nil {
+			continue
+		}
+		data, err := json.Marshal(tool.Function)
+		if err != nil {
+			continue
+		}
+		var declaration GeminiFunctionDeclaration
+		if err := json.Unmarshal(data, &declaration); err != nil || declaration.Name == "" {
+			continue
+		}
+		declarations = append(declarations, declaration)
+	}
+	return declarations
+}
+
 // GeminiProvider implements the Provider interface for Gemini models
 type GeminiProvider struct {
-	config *tokentracker.Config
+	config          *tokentracker.Config
+	sdkClient       interface{}
+	counter         GeminiTokenCounter
+	offlineFallback bool
+	mu              sync.RWMutex
 }
 
 // NewGeminiProvider creates a new Gemini provider
 func NewGeminiProvider(config *tokentracker.Config) *GeminiProvider {
 	return &GeminiProvider{
-		config: config,
+		config:          config,
+		offlineFallback: true,
 	}
 }
 
@@ -25,74 +79,133 @@ func (p *GeminiProvider) Name() string {
 	return "gemini"
 }
 
-// SupportsModel checks if the provider supports a specific model
+// geminiModels lists the models supported out of the box
+var geminiModels = map[string]bool{
+	"gemini-pro":   true,
+	"gemini-ultra": true,
+	// Add more models as needed
+}
+
+// Gemini bills audio and video inputs at fixed rates per second; video includes
+// its frames, sampled at one per second, and its audio track
+const (
+	geminiAudioTokensPerSecond = 32
+	geminiVideoTokensPerSecond = 263
+)
+
+// SupportsModel checks if the provider supports the given model, either out of the
+// box or through the model catalog of the configuration
 func (p *GeminiProvider) SupportsModel(model string) bool {
-	supportedModels := map[string]bool{
-		"gemini-pro":   true,
-		"gemini-ultra": true,
-		// Add more models as needed
-	}
+	return geminiModels[model] || p.config.ModelCatalog().Supports(p.Name(), model)
+}
 
-	return supportedModels[model]
+// DefaultModels returns the models the provider supports out of the box
+func (p *GeminiProvider) DefaultModels() []string {
+	models := make([]string, 0, len(geminiModels))
+	for model := range geminiModels {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+	return models
 }
 
 // CountTokens counts tokens for the given parameters
-// Note: This is a simplified implementation for Gemini token counting
-// In a production environment, you would want to use Google's official tokenizer
 func (p *GeminiProvider) CountTokens(params tokentracker.TokenCountParams) (tokentracker.TokenCount, error) {
+	return p.CountTokensCtx(context.Background(), params)
+}
+
+// CountTokensCtx counts tokens for the given parameters, honoring cancellation of ctx.
+// When a token counter is attached, input tokens are counted by the Gemini API;
+// otherwise (or on API failure, if the offline fallback is enabled) a character-based
+// approximation is used, as it is for contexts marked with
+// tokentracker.ContextWithLocalCounting. API calls go through the resilience layer of
+// the configuration, see tokentracker.Resilience.
+func (p *GeminiProvider) CountTokensCtx(ctx context.Context, params tokentracker.TokenCountParams) (tokentracker.TokenCount, error) {
+	if err := ctx.Err(); err != nil {
+		return tokentracker.TokenCount{}, err
+	}
+
 	if params.Model == "" {
 		return tokentracker.TokenCount{}, tokentracker.NewError(tokentracker.ErrInvalidParams, "model is required", nil)
 	}
 
-	var inputTokens int
+	// Normalize the input before tokenization
+	normalization := p.config.GetNormalization()
+	params = normalization.ApplyToParams(params)
 
-	// Count tokens based on input type
-	if params.Text != nil {
-		// Count tokens for text
-		inputTokens = p.approximateTokenCount(*params.Text)
-	} else if len(params.Messages) > 0 {
-		// Count tokens for messages
-		inputTokens = p.countMessageTokens(params.Messages, params.Tools, params.ToolChoice)
-	} else {
+	if params.Text == nil && len(params.Messages) == 0 {
 		return tokentracker.TokenCount{}, tokentracker.NewError(tokentracker.ErrInvalidParams, "either text or messages must be provided", nil)
 	}
 
+	var inputTokens int
+	var countedByAPI bool
+
+	p.mu.RLock()
+	counter, offlineFallback := p.counter, p.offlineFallback
+	p.mu.RUnlock()
+
+	if counter != nil && !tokentracker.LocalCountingFromContext(ctx) {
+		count, err := p.countTokensWithAPI(ctx, counter, params)
+		switch {
+		case err == nil:
+			inputTokens, countedByAPI = count, true
+		case !offlineFallback || ctx.Err() != nil:
+			return tokentracker.TokenCount{}, tokentracker.NewError(tokentracker.ErrTokenizationFailed, "Gemini CountTokens failed", err)
+		default:
+			p.config.ReportFallback(tokentracker.FallbackWarning{Provider: p.Name(), Operation: "count_tokens", Fallback: tokentracker.FallbackApproximation, Err: err})
+			inputTokens = p.approximateInputTokens(params)
+		}
+	} else {
+		inputTokens = p.approximateInputTokens(params)
+	}
+
+	// Audio and video are billed by duration; only text parts are sent to CountTokens
+	audio, video := tokentracker.MediaDurations(params.Messages)
+	audioTokens := tokentracker.MediaTokens(audio, geminiAudioTokensPerSecond)
+	videoTokens := tokentracker.MediaTokens(video, geminiVideoTokensPerSecond)
+	inputTokens += audioTokens + videoTokens
+
 	// Estimate response tokens if requested
 	var responseTokens int
 	if params.CountResponseTokens {
-		responseTokens = p.estimateResponseTokens(params.Model, inputTokens)
+		responseTokens = p.config.GetResponseEstimator().EstimateResponseTokens(params, inputTokens)
 	}
 
 	return tokentracker.TokenCount{
 		InputTokens:    inputTokens,
 		ResponseTokens: responseTokens,
 		TotalTokens:    inputTokens + responseTokens,
+		AudioTokens:    audioTokens,
+		VideoTokens:    videoTokens,
+		Normalization:  normalization.Profile(),
+		CountedByAPI:   countedByAPI,
 	}, nil
 }
 
 // CalculatePrice calculates price based on token usage
 func (p *GeminiProvider) CalculatePrice(model string, inputTokens, outputTokens int) (tokentracker.Price, error) {
+	return p.CalculateUsagePrice(model, tokentracker.BillableUsage{InputTokens: inputTokens, OutputTokens: outputTokens})
+}
+
+// CalculateUsagePrice calculates price based on billable usage, including cached input
+// tokens, pricing tiers and batch discounts
+func (p *GeminiProvider) CalculateUsagePrice(model string, usage tokentracker.BillableUsage) (tokentracker.Price, error) {
 	pricing, exists := p.config.GetModelPricing("gemini", model)
 	if !exists {
 		return tokentracker.Price{}, tokentracker.NewError(tokentracker.ErrPricingNotFound, fmt.Sprintf("pricing not found for model: %s", model), nil)
 	}
 
-	inputCost := float64(inputTokens) * pricing.InputPricePerToken
-	outputCost := float64(outputTokens) * pricing.OutputPricePerToken
-
-	return tokentracker.Price{
-		InputCost:  inputCost,
-		OutputCost: outputCost,
-		TotalCost:  inputCost + outputCost,
-		Currency:   pricing.Currency,
-	}, nil
+	return pricing.Cost(usage), nil
 }
 
 // approximateTokenCount provides an approximate token count for Gemini models
 // This is a simplified implementation and should be replaced with Google's official tokenizer
 func (p *GeminiProvider) approximateTokenCount(text string) int {
+	// Cache entries are scoped to the active normalization profile
+	scope := tokentracker.CacheScope("", p.config.GetNormalization())
+
 	// Check if we have a cached result
-	if count, exists := tokentracker.GetCachedTokenCount("gemini", "", text); exists {
+	if count, exists := p.config.GetTokenCache().Get("gemini", scope, text); exists {
 		return count
 	}
 
@@ -103,18 +216,131 @@ func (p *GeminiProvider) approximateTokenCount(text string) int {
 	tokenCount := charCount / 4
 
 	// Add a small overhead for special tokens
-	tokenCount += 3
+	tokenCount += geminiSpecialTokens
 
 	// Cache the result
-	tokentracker.SetCachedTokenCount("gemini", "", text, tokenCount)
+	p.config.GetTokenCache().Set("gemini", scope, text, tokenCount)
 
 	return tokenCount
 }
 
+// geminiSpecialTokens is the overhead of special tokens added to approximate counts
+const geminiSpecialTokens = 3
+
+// CountTextTokens approximates the tokens of normalized text, even when a token counter
+// is attached, see tokentracker.TextTokenCounter
+func (p *GeminiProvider) CountTextTokens(_, text string) (int, error) {
+	return p.approximateTokenCount(p.config.GetNormalization().Apply(text)) - geminiSpecialTokens, nil
+}
+
+// TextPromptOverhead returns the special tokens added to approximate counts
+func (p *GeminiProvider) TextPromptOverhead(string) int {
+	return geminiSpecialTokens
+}
+
+// countTokensWithAPI counts input tokens with the attached counter, caching results.
+// Counters implementing GeminiRequestTokenCounter count the whole request.
+func (p *GeminiProvider) countTokensWithAPI(ctx context.Context, counter GeminiTokenCounter, params tokentracker.TokenCountParams) (int, error) {
+	requestCounter, countsRequests := counter.(GeminiRequestTokenCounter)
+	request := geminiCountRequest(params)
+	parts := geminiCountParts(params)
+
+	var key []byte
+	var err error
+	if countsRequests {
+		key, err = json.Marshal(request)
+	} else {
+		key, err = json.Marshal(parts)
+	}
+	if err != nil {
+		return 0, err
+	}
+	scope := tokentracker.CacheScope(params.Model, p.config.GetNormalization())
+
+	if count, exists := p.config.GetTokenCache().Get("gemini-api", scope, string(key)); exists {
+		return count, nil
+	}
+
+	var count int
+	err = p.config.GetResilience().Do(ctx, p.Name(), func(ctx context.Context) error {
+		if countsRequests {
+			count, err = requestCounter.CountRequestTokens(ctx, params.Model, request)
+		} else {
+			count, err = counter.CountTokens(ctx, params.Model, parts)
+		}
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	p.config.GetTokenCache().Set("gemini-api", scope, string(key), count)
+	return count, nil
+}
+
+// geminiCountRequest returns the request sent to CountRequestTokens
+func geminiCountRequest(params tokentracker.TokenCountParams) GeminiCountRequest {
+	return GeminiCountRequest{
+		SystemInstruction: params.SystemInstruction,
+		Contents:          geminiContentParts(params),
+		Tools:             GeminiFunctionDeclarations(params.Tools),
+	}
+}
+
+// geminiCountParts flattens the input into the text parts sent to CountTokens. The
+// system instruction, tool definitions and the tool calls of messages are sent as text.
+func geminiCountParts(params tokentracker.TokenCountParams) []string {
+	var parts []string
+	if params.SystemInstruction != "" {
+		parts = append(parts, params.SystemInstruction)
+	}
+	parts = append(parts, geminiContentParts(params)...)
+
+	if len(params.Tools) > 0 {
+		if toolsJSON, err := json.Marshal(params.Tools); err == nil {
+			parts = append(parts, string(toolsJSON))
+		}
+	}
+
+	return parts
+}
+
+// geminiContentParts flattens the text and messages into text parts, including the
+// tool calls of messages
+func geminiContentParts(params tokentracker.TokenCountParams) []string {
+	var parts []string
+	if params.Text != nil {
+		parts = append(parts, *params.Text)
+	}
+
+	for _, message := range params.Messages {
+		if text := strings.TrimSpace(tokentracker.ExtractTextFromMessages([]tokentracker.Message{message})); text != "" {
+			parts = append(parts, text)
+		}
+		if text := strings.TrimSpace(tokentracker.ExtractToolCallText([]tokentracker.Message{message})); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return parts
+}
+
+// approximateInputTokens estimates input tokens without calling the API, including
+// the system instruction
+func (p *GeminiProvider) approximateInputTokens(params tokentracker.TokenCountParams) int {
+	tokens := 0
+	if params.SystemInstruction != "" {
+		tokens += p.approximateTokenCount(params.SystemInstruction)
+	}
+	if params.Text != nil {
+		return tokens + p.approximateTokenCount(*params.Text)
+	}
+	return tokens + p.countMessageTokens(params.Messages, params.Tools, params.ToolChoice)
+}
+
 // countMessageTokens counts tokens for chat messages
 func (p *GeminiProvider) countMessageTokens(messages []tokentracker.Message, tools []tokentracker.Tool, toolChoice *tokentracker.ToolChoice) int {
-	// Extract all text from messages
-	allText := tokentracker.ExtractTextFromMessages(messages)
+	// Extract all text from messages, including tool calls and tool results
+	allText := tokentracker.ExtractTextFromMessages(messages) + tokentracker.ExtractToolCallText(messages)
 
 	// Count tokens for the combined text
 	tokens := p.approximateTokenCount(allText)
@@ -122,9 +348,15 @@ func (p *GeminiProvider) countMessageTokens(messages []tokentracker.Message, too
 	// Add tokens for message structure (roles, formatting)
 	tokens += len(messages) * 4
 
-	// Count tokens for tools if provided
+	// Count tokens for tools if provided, as the function declarations sent to Gemini
 	if len(tools) > 0 {
-		toolsJSON, err := json.Marshal(tools)
+		var toolsJSON []byte
+		var err error
+		if declarations := GeminiFunctionDeclarations(tools); len(declarations) == len(tools) {
+			toolsJSON, err = json.Marshal(declarations)
+		} else {
+			toolsJSON, err = json.Marshal(tools)
+		}
 		if err == nil {
 			tokens += p.approximateTokenCount(string(toolsJSON))
 		}
@@ -141,44 +373,73 @@ func (p *GeminiProvider) countMessageTokens(messages []tokentracker.Message, too
 	return tokens
 }
 
-// estimateResponseTokens estimates the number of response tokens
-func (p *GeminiProvider) estimateResponseTokens(model string, inputTokens int) int {
-	return tokentracker.EstimateResponseTokens(model, inputTokens)
+// SetSDKClient sets the provider-specific SDK client.
+// A client implementing GeminiTokenCounter is also used for token counting.
+func (p *GeminiProvider) SetSDKClient(client interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sdkClient = client
+	if counter, ok := client.(GeminiTokenCounter); ok {
+		p.counter = counter
+	}
 }
 
-// SetSDKClient sets the provider-specific SDK client
-func (p *GeminiProvider) SetSDKClient(client interface{}) {
-	// Store the client for later use
-	// In a real implementation, this would be used to make API calls
+// SetTokenCounter sets the counter used for exact token counting (nil disables it)
+func (p *GeminiProvider) SetTokenCounter(counter GeminiTokenCounter) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.counter = counter
+}
+
+// SetOfflineFallback controls whether the approximation is used when the CountTokens
+// call fails. When disabled, failures are returned as errors. It is enabled by default.
+func (p *GeminiProvider) SetOfflineFallback(enabled bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.offlineFallback = enabled
 }
 
 // GetModelInfo returns information about a specific model
-func (p *GeminiProvider) GetModelInfo(model string) (interface{}, error) {
+func (p *GeminiProvider) GetModelInfo(model string) (tokentracker.ModelInfo, error) {
 	// Check if the model is supported
 	if !p.SupportsModel(model) {
-		return nil, tokentracker.NewError(tokentracker.ErrInvalidModel, fmt.Sprintf("unsupported model: %s", model), nil)
+		return tokentracker.ModelInfo{}, tokentracker.NewError(tokentracker.ErrInvalidModel, fmt.Sprintf("unsupported model: %s", model), nil)
 	}
 
-	// Return model information with contextWindow and description
-	modelInfo := map[string]interface{}{
-		"name":          model,
-		"provider":      "gemini",
-		"capabilities":  []string{"text", "chat", "image-understanding"},
-		"contextWindow": 32768, // Default context window
-		"description":   fmt.Sprintf("%s is a language model by Google", model),
+	// Default information, refined for specific models below
+	info := tokentracker.ModelInfo{
+		Name:            model,
+		Provider:        "gemini",
+		Capabilities:    []string{"text", "chat", "image-understanding"},
+		ContextWindow:   32768,
+		MaxOutputTokens: 2048,
+		Modalities:      textImageModalities,
+		PricingRef:      pricingRef(p.config, p.Name(), model),
+		Description:     fmt.Sprintf("%s is a language model by Google", model),
 	}
 
 	// Add specific details for each model
-	switch model {
-	case "gemini-pro":
-		modelInfo["contextWindow"] = 32768
-		modelInfo["description"] = "Gemini Pro - Balanced performance and efficiency"
-	case "gemini-ultra":
-		modelInfo["contextWindow"] = 32768
-		modelInfo["description"] = "Gemini Ultra - Advanced reasoning and instruction following"
+	switch {
+	case model == "gemini-pro":
+		info.Description = "Gemini Pro - Balanced performance and efficiency"
+	case model == "gemini-ultra":
+		info.Description = "Gemini Ultra - Advanced reasoning and instruction following"
+	case strings.HasPrefix(model, "gemini-1.5-pro"):
+		info.ContextWindow = 2097152
+		info.MaxOutputTokens = 8192
+		info.Modalities = []string{"text", "image", "audio", "video"}
+	case strings.HasPrefix(model, "gemini-1.5-flash"):
+		info.ContextWindow = 1048576
+		info.MaxOutputTokens = 8192
+		info.Modalities = []string{"text", "image", "audio", "video"}
+	case model == "text-embedding-004" || model == "embedding-001":
+		info.Capabilities = []string{"text", "embeddings"}
+		info.ContextWindow = 2048
+		info.MaxOutputTokens = 0
+		info.Modalities = textModalities
 	}
 
-	return modelInfo, nil
+	return info, nil
 }
 
 // ExtractTokenUsageFromResponse extracts token usage from a provider response
@@ -218,6 +479,16 @@ func (p *GeminiProvider) ExtractTokenUsageFromResponse(response interface{}) (to
 			}
 		}
 
+		// Check for the embedding statistics of Vertex AI embedding predictions
+		if predictions, ok := respMap["predictions"].([]interface{}); ok {
+			if tokens, ok := geminiEmbeddingTokens(predictions); ok {
+				return tokentracker.TokenCount{
+					InputTokens: tokens,
+					TotalTokens: tokens,
+				}, nil
+			}
+		}
+
 		// Check for usageMetadata structure
 		if usageMetadata, ok := respMap["usageMetadata"].(map[string]interface{}); ok {
 			promptTokens, ok1 := usageMetadata["promptTokenCount"].(float64)
@@ -225,10 +496,18 @@ func (p *GeminiProvider) ExtractTokenUsageFromResponse(response interface{}) (to
 			totalTokens, ok3 := usageMetadata["totalTokenCount"].(float64)
 
 			if ok1 && ok2 && ok3 {
+				// Tokens of cached content are part of the prompt
+				cachedTokens, _ := usageMetadata["cachedContentTokenCount"].(float64)
+				audioTokens, videoTokens := geminiModalityTokens(usageMetadata["promptTokensDetails"])
+
 				return tokentracker.TokenCount{
-					InputTokens:    int(promptTokens),
-					ResponseTokens: int(candidatesTokens),
-					TotalTokens:    int(totalTokens),
+					InputTokens:       int(promptTokens),
+					ResponseTokens:    int(candidatesTokens),
+					TotalTokens:       int(totalTokens),
+					CachedInputTokens: int(cachedTokens),
+					AudioTokens:       audioTokens,
+					VideoTokens:       videoTokens,
+					ToolCallTokens:    toolCallTokens(geminiResponseToolCalls(respMap), int(candidatesTokens), p.approximateTokenCount),
 				}, nil
 			}
 		}
@@ -237,6 +516,41 @@ func (p *GeminiProvider) ExtractTokenUsageFromResponse(response interface{}) (to
 	return tokentracker.TokenCount{}, tokentracker.NewError(tokentracker.ErrInvalidParams, "token counts not found in response", nil)
 }
 
+// geminiEmbeddingTokens sums the token counts of embedding predictions
+func geminiEmbeddingTokens(predictions []interface{}) (int, bool) {
+	var tokens int
+	var found bool
+	for _, item := range predictions {
+		prediction, _ := item.(map[string]interface{})
+		embeddings, _ := prediction["embeddings"].(map[string]interface{})
+		statistics, _ := embeddings["statistics"].(map[string]interface{})
+		if count, ok := statistics["token_count"].(float64); ok {
+			tokens += int(count)
+			found = true
+		}
+	}
+	return tokens, found
+}
+
+// geminiModalityTokens returns the audio and video tokens of a promptTokensDetails list
+func geminiModalityTokens(details interface{}) (audio, video int) {
+	items, _ := details.([]interface{})
+	for _, item := range items {
+		detail, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		tokens, _ := detail["tokenCount"].(float64)
+		switch detail["modality"] {
+		case "AUDIO":
+			audio += int(tokens)
+		case "VIDEO":
+			video += int(tokens)
+		}
+	}
+	return audio, video
+}
+
 // UpdatePricing updates the pricing information for this provider
 func (p *GeminiProvider) UpdatePricing() error {
 	// If we have an SDK client, we could use it to fetch the latest pricing
@@ -256,5 +570,30 @@ func (p *GeminiProvider) UpdatePricing() error {
 		Currency:            "USD",
 	})
 
+	// Gemini 1.5 pricing (as of May 2024); prompts longer than 128K tokens cost twice as much
+	p.config.SetModelPricing("gemini", "gemini-1.5-pro", tokentracker.ModelPricing{
+		InputPricePerToken:  0.0000035,
+		OutputPricePerToken: 0.0000105,
+		Currency:            "USD",
+		Tiers: []tokentracker.PricingTier{
+			{AboveInputTokens: 128000, InputPricePerToken: 0.000007, OutputPricePerToken: 0.000021},
+		},
+	})
+	p.config.SetModelPricing("gemini", "gemini-1.5-flash", tokentracker.ModelPricing{
+		InputPricePerToken:  0.00000035,
+		OutputPricePerToken: 0.00000105,
+		Currency:            "USD",
+		Tiers: []tokentracker.PricingTier{
+			{AboveInputTokens: 128000, InputPricePerToken: 0.0000007, OutputPricePerToken: 0.0000021},
+		},
+	})
+
+	// Text embedding pricing (Vertex AI, as of May 2024): $0.025 per million characters,
+	// about four characters per token
+	p.config.SetModelPricing("gemini", "text-embedding-004", tokentracker.ModelPricing{
+		InputPricePerToken: 0.0000001,
+		Currency:           "USD",
+	})
+
 	return nil
 }
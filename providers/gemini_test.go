@@ -1,7 +1,10 @@
 package providers
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/TrustSight-io/tokentracker"
 )
@@ -346,26 +349,19 @@ func TestGeminiProvider_GetModelInfo(t *testing.T) {
 			}
 
 			if tt.checkInfo {
-				modelInfo, ok := info.(map[string]interface{})
-				if !ok {
-					t.Errorf("GetModelInfo() did not return a map[string]interface{}")
-					return
-				}
-
 				// Check context window
-				contextWindow, exists := modelInfo["contextWindow"]
-				if !exists {
-					t.Errorf("GetModelInfo() did not include contextWindow")
-				} else if contextWindow.(int) <= 0 {
-					t.Errorf("GetModelInfo() contextWindow = %v, expected > 0", contextWindow)
+				if info.ContextWindow <= 0 {
+					t.Errorf("GetModelInfo() ContextWindow = %v, expected > 0", info.ContextWindow)
 				}
 
 				// Check description
-				description, exists := modelInfo["description"]
-				if !exists {
-					t.Errorf("GetModelInfo() did not include description")
-				} else if description.(string) == "" {
-					t.Errorf("GetModelInfo() description is empty")
+				if info.Description == "" {
+					t.Errorf("GetModelInfo() Description is empty")
+				}
+
+				// Check modalities
+				if len(info.Modalities) == 0 || info.Modalities[0] != "text" {
+					t.Errorf("GetModelInfo() Modalities = %v, want text input", info.Modalities)
 				}
 			}
 		})
@@ -408,3 +404,248 @@ func TestGeminiProvider_UpdatePricing(t *testing.T) {
 		}
 	}
 }
+
+// fakeGeminiCounter is a GeminiTokenCounter returning a fixed count
+type fakeGeminiCounter struct {
+	count int
+	err   error
+	calls int
+	parts []string
+}
+
+func (c *fakeGeminiCounter) CountTokens(ctx context.Context, model string, parts []string) (int, error) {
+	c.calls++
+	c.parts = parts
+	return c.count, c.err
+}
+
+func TestGeminiProvider_CountTokensWithSDK(t *testing.T) {
+	provider := NewGeminiProvider(tokentracker.NewConfig())
+	counter := &fakeGeminiCounter{count: 17}
+	provider.SetSDKClient(counter)
+
+	params := tokentracker.TokenCountParams{
+		Model: "gemini-pro",
+		Messages: []tokentracker.Message{
+			{Role: "user", Content: "Count these tokens with the SDK"},
+			{Role: "model", Content: "Sure"},
+		},
+	}
+
+	for i := 0; i < 2; i++ {
+		count, err := provider.CountTokens(params)
+		if err != nil {
+			t.Fatalf("CountTokens() error = %v", err)
+		}
+		if count.InputTokens != 17 {
+			t.Errorf("InputTokens = %d, want 17 from the SDK", count.InputTokens)
+		}
+	}
+
+	if counter.calls != 1 {
+		t.Errorf("SDK called %d times, want 1 (second call cached)", counter.calls)
+	}
+	if len(counter.parts) != 2 || counter.parts[1] != "Sure" {
+		t.Errorf("parts = %q, want one part per message", counter.parts)
+	}
+}
+
+type fakeGeminiRequestCounter struct {
+	fakeGeminiCounter
+	request GeminiCountRequest
+}
+
+func (c *fakeGeminiRequestCounter) CountRequestTokens(ctx context.Context, model string, request GeminiCountRequest) (int, error) {
+	c.calls++
+	c.request = request
+	return c.count, c.err
+}
+
+func TestGeminiProvider_CountsSystemInstructionAndTools(t *testing.T) {
+	provider := NewGeminiProvider(tokentracker.NewConfig())
+	counter := &fakeGeminiRequestCounter{fakeGeminiCounter: fakeGeminiCounter{count: 42}}
+	provider.SetTokenCounter(counter)
+
+	params := tokentracker.TokenCountParams{
+		Model:             "gemini-pro",
+		SystemInstruction: "Answer briefly.",
+		Messages:          []tokentracker.Message{{Role: "user", Content: "What's the weather in Paris?"}},
+		Tools: []tokentracker.Tool{
+			{Type: "function", Function: map[string]interface{}{
+				"name":        "get_weather",
+				"description": "Get the weather",
+				"parameters":  map[string]interface{}{"type": "object", "properties": map[string]interface{}{"city": map[string]interface{}{"type": "string"}}},
+			}},
+			{Type: "google_search"},
+		},
+	}
+
+	count, err := provider.CountTokens(params)
+	if err != nil {
+		t.Fatalf("CountTokens() error = %v", err)
+	}
+	if count.InputTokens != 42 || counter.calls != 1 {
+		t.Errorf("count = %+v after %d calls, want 42 from the request counter", count, counter.calls)
+	}
+
+	// The system instruction and function declarations are sent apart from the contents
+	request := counter.request
+	if request.SystemInstruction != "Answer briefly." || len(request.Contents) != 1 || request.Contents[0] != "What's the weather in Paris?" {
+		t.Errorf("request = %+v, want the system instruction apart from the contents", request)
+	}
+	if len(request.Tools) != 1 || request.Tools[0].Name != "get_weather" || request.Tools[0].Description != "Get the weather" ||
+		string(request.Tools[0].Parameters) != `{"properties":{"city":{"type":"string"}},"type":"object"}` {
+		t.Errorf("tools = %+v, want the get_weather declaration", request.Tools)
+	}
+
+	// Counters of text parts receive the system instruction as the first part
+	parts := &fakeGeminiCounter{count: 7}
+	provider.SetTokenCounter(parts)
+	if _, err := provider.CountTokens(params); err != nil {
+		t.Fatalf("CountTokens() error = %v", err)
+	}
+	if len(parts.parts) != 3 || parts.parts[0] != "Answer briefly." {
+		t.Errorf("parts = %q, want the system instruction, message and tools", parts.parts)
+	}
+
+	// The approximation counts the system instruction too
+	provider.SetTokenCounter(nil)
+	withSystem, _ := provider.CountTokens(params)
+	params.SystemInstruction = ""
+	withoutSystem, _ := provider.CountTokens(params)
+	if withSystem.InputTokens != withoutSystem.InputTokens+provider.approximateTokenCount("Answer briefly.") {
+		t.Errorf("approximation = %d, want %d plus the system instruction", withSystem.InputTokens, withoutSystem.InputTokens)
+	}
+}
+
+func TestGeminiProvider_CountTokensOfflineFallback(t *testing.T) {
+	provider := NewGeminiProvider(tokentracker.NewConfig())
+	provider.SetTokenCounter(&fakeGeminiCounter{err: errors.New("unavailable")})
+
+	text := "The SDK call fails for this text"
+	params := tokentracker.TokenCountParams{Model: "gemini-pro", Text: &text}
+
+	count, err := provider.CountTokens(params)
+	if err != nil {
+		t.Fatalf("CountTokens() with fallback error = %v", err)
+	}
+	if count.InputTokens != provider.approximateTokenCount(text) {
+		t.Errorf("InputTokens = %d, want the approximation", count.InputTokens)
+	}
+
+	provider.SetOfflineFallback(false)
+	if _, err := provider.CountTokens(params); err == nil {
+		t.Error("CountTokens() without fallback should fail when the SDK call fails")
+	}
+}
+
+func TestGeminiProvider_TieredPricing(t *testing.T) {
+	config := tokentracker.NewConfig()
+	provider := NewGeminiProvider(config)
+	if err := provider.UpdatePricing(); err != nil {
+		t.Fatalf("UpdatePricing() error = %v", err)
+	}
+
+	short, err := provider.CalculatePrice("gemini-1.5-pro", 100000, 1000)
+	if err != nil {
+		t.Fatalf("CalculatePrice() error = %v", err)
+	}
+	if !approxEqual(short.TotalCost, 100000*0.0000035+1000*0.0000105) {
+		t.Errorf("TotalCost = %v, want the base rates", short.TotalCost)
+	}
+
+	long, _ := provider.CalculatePrice("gemini-1.5-pro", 200000, 1000)
+	if !approxEqual(long.TotalCost, 200000*0.000007+1000*0.000021) {
+		t.Errorf("TotalCost = %v, want the rates above 128K tokens", long.TotalCost)
+	}
+
+	count, err := provider.ExtractTokenUsageFromResponse(map[string]interface{}{
+		"usageMetadata": map[string]interface{}{
+			"promptTokenCount":        float64(1000),
+			"candidatesTokenCount":    float64(10),
+			"totalTokenCount":         float64(1010),
+			"cachedContentTokenCount": float64(800),
+		},
+	})
+	if err != nil || count.CachedInputTokens != 800 {
+		t.Errorf("ExtractTokenUsageFromResponse() = %+v, %v, want the cached content tokens", count, err)
+	}
+}
+
+func TestGeminiProvider_MediaTokens(t *testing.T) {
+	provider := NewGeminiProvider(tokentracker.NewConfig())
+
+	text := []tokentracker.Message{{Role: "user", Content: []tokentracker.ContentPart{
+		{Type: tokentracker.ContentTypeText, Text: "Summarize this clip."},
+	}}}
+	base, err := provider.CountTokens(tokentracker.TokenCountParams{Model: "gemini-1.5-pro", Messages: text})
+	if err != nil {
+		t.Fatalf("CountTokens() error = %v", err)
+	}
+
+	media := []tokentracker.Message{{Role: "user", Content: []tokentracker.ContentPart{
+		{Type: tokentracker.ContentTypeText, Text: "Summarize this clip."},
+		{Type: tokentracker.ContentTypeAudio, Duration: 10 * time.Second},
+		{Type: tokentracker.ContentTypeVideo, Duration: 2 * time.Second},
+	}}}
+	count, err := provider.CountTokens(tokentracker.TokenCountParams{Model: "gemini-1.5-pro", Messages: media})
+	if err != nil {
+		t.Fatalf("CountTokens() error = %v", err)
+	}
+	if count.AudioTokens != 320 || count.VideoTokens != 526 {
+		t.Errorf("AudioTokens/VideoTokens = %d/%d, want 320/526", count.AudioTokens, count.VideoTokens)
+	}
+	if count.InputTokens != base.InputTokens+320+526 {
+		t.Errorf("InputTokens = %d, want the text tokens plus the media tokens", count.InputTokens)
+	}
+
+	usage, err := provider.ExtractTokenUsageFromResponse(map[string]interface{}{
+		"usageMetadata": map[string]interface{}{
+			"promptTokenCount":     float64(850),
+			"candidatesTokenCount": float64(40),
+			"totalTokenCount":      float64(890),
+			"promptTokensDetails": []interface{}{
+				map[string]interface{}{"modality": "TEXT", "tokenCount": float64(4)},
+				map[string]interface{}{"modality": "AUDIO", "tokenCount": float64(320)},
+				map[string]interface{}{"modality": "VIDEO", "tokenCount": float64(526)},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ExtractTokenUsageFromResponse() error = %v", err)
+	}
+	if usage.AudioTokens != 320 || usage.VideoTokens != 526 {
+		t.Errorf("usage = %+v, want the audio and video prompt tokens", usage)
+	}
+}
+
+func TestGeminiProvider_Embeddings(t *testing.T) {
+	config := tokentracker.NewConfig()
+	provider := NewGeminiProvider(config)
+
+	if !provider.SupportsModel("text-embedding-004") || NewOpenAIProvider(config).SupportsModel("text-embedding-004") {
+		t.Error("text-embedding-004 should be a Gemini model")
+	}
+
+	count, err := provider.ExtractTokenUsageFromResponse(map[string]interface{}{
+		"predictions": []interface{}{
+			map[string]interface{}{"embeddings": map[string]interface{}{
+				"statistics": map[string]interface{}{"token_count": float64(7), "truncated": false},
+				"values":     []interface{}{0.1, 0.2},
+			}},
+			map[string]interface{}{"embeddings": map[string]interface{}{
+				"statistics": map[string]interface{}{"token_count": float64(5), "truncated": false},
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ExtractTokenUsageFromResponse() error = %v", err)
+	}
+	if count.InputTokens != 12 || count.TotalTokens != 12 {
+		t.Errorf("count = %+v, want the summed token counts", count)
+	}
+
+	if _, err := provider.CalculatePrice("text-embedding-004", 1000, 0); err != nil {
+		t.Errorf("CalculatePrice() error = %v", err)
+	}
+}
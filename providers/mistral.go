@@ -0,0 +1,309 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/TrustSight-io/tokentracker"
+)
+
+// MistralProvider implements the Provider interface for Mistral AI models
+type MistralProvider struct {
+	config    *tokentracker.Config
+	sdkClient interface{}
+}
+
+// NewMistralProvider creates a new Mistral provider
+func NewMistralProvider(config *tokentracker.Config) *MistralProvider {
+	return &MistralProvider{
+		config: config,
+	}
+}
+
+// Name returns the provider name
+func (p *MistralProvider) Name() string {
+	return "mistral"
+}
+
+// mistralModels lists the models supported out of the box
+var mistralModels = map[string]bool{
+	"mistral-small":      true,
+	"mistral-medium":     true,
+	"mistral-large":      true,
+	"open-mistral-7b":    true,
+	"open-mixtral-8x7b":  true,
+	"open-mixtral-8x22b": true,
+}
+
+// mistralVersionSuffix matches the "-latest" alias and dated versions such as "-2402"
+var mistralVersionSuffix = regexp.MustCompile(`-(latest|\d{4})$`)
+
+// mistralBaseModel returns the model without its version, e.g. mistral-large for
+// mistral-large-latest and mistral-large-2402
+func mistralBaseModel(model string) string {
+	return mistralVersionSuffix.ReplaceAllString(model, "")
+}
+
+// SupportsModel checks if the provider supports the given model, including its
+// "-latest" alias and dated versions, or through the model catalog of the configuration
+func (p *MistralProvider) SupportsModel(model string) bool {
+	return mistralModels[mistralBaseModel(model)] || p.config.ModelCatalog().Supports("mistral", model)
+}
+
+// DefaultModels returns the models the provider supports out of the box
+func (p *MistralProvider) DefaultModels() []string {
+	models := make([]string, 0, len(mistralModels))
+	for model := range mistralModels {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+	return models
+}
+
+// CountTokens counts tokens for the given parameters
+func (p *MistralProvider) CountTokens(params tokentracker.TokenCountParams) (tokentracker.TokenCount, error) {
+	return p.CountTokensCtx(context.Background(), params)
+}
+
+// CountTokensCtx counts tokens for the given parameters, honoring cancellation of ctx.
+// Mistral's tokenizer is not available offline, so counts are approximated.
+func (p *MistralProvider) CountTokensCtx(ctx context.Context, params tokentracker.TokenCountParams) (tokentracker.TokenCount, error) {
+	if err := ctx.Err(); err != nil {
+		return tokentracker.TokenCount{}, err
+	}
+
+	if params.Model == "" {
+		return tokentracker.TokenCount{}, tokentracker.NewError(tokentracker.ErrInvalidParams, "model is required", nil)
+	}
+
+	// Normalize the input before tokenization
+	normalization := p.config.GetNormalization()
+	params = normalization.ApplyToParams(params.WithSystemMessage())
+
+	var inputTokens int
+	if params.Text != nil {
+		inputTokens = p.approximateTokenCount(*params.Text)
+	} else if len(params.Messages) > 0 {
+		inputTokens = p.countMessageTokens(params.Messages, params.Tools, params.ToolChoice)
+	} else {
+		return tokentracker.TokenCount{}, tokentracker.NewError(tokentracker.ErrInvalidParams, "either text or messages must be provided", nil)
+	}
+
+	// Estimate response tokens if requested
+	var responseTokens int
+	if params.CountResponseTokens {
+		responseTokens = p.config.GetResponseEstimator().EstimateResponseTokens(params, inputTokens)
+	}
+
+	return tokentracker.TokenCount{
+		InputTokens:    inputTokens,
+		ResponseTokens: responseTokens,
+		TotalTokens:    inputTokens + responseTokens,
+		Normalization:  normalization.Profile(),
+	}, nil
+}
+
+// mistralBOSTokens is the beginning-of-sequence token added to approximate counts
+const mistralBOSTokens = 1
+
+// CountTextTokens approximates the tokens of normalized text, see
+// tokentracker.TextTokenCounter
+func (p *MistralProvider) CountTextTokens(_, text string) (int, error) {
+	return p.approximateTokenCount(p.config.GetNormalization().Apply(text)) - mistralBOSTokens, nil
+}
+
+// TextPromptOverhead returns the beginning-of-sequence token added to approximate counts
+func (p *MistralProvider) TextPromptOverhead(string) int {
+	return mistralBOSTokens
+}
+
+// approximateTokenCount approximates the token count of text. Mistral's SentencePiece
+// vocabulary is smaller than cl100k_base, so text takes about one token per 3.5 characters.
+func (p *MistralProvider) approximateTokenCount(text string) int {
+	// Cache entries are scoped to the active normalization profile
+	scope := tokentracker.CacheScope("", p.config.GetNormalization())
+
+	if count, exists := p.config.GetTokenCache().Get("mistral", scope, text); exists {
+		return count
+	}
+
+	tokenCount := utf8.RuneCountInString(text)*2/7 + mistralBOSTokens
+
+	p.config.GetTokenCache().Set("mistral", scope, text, tokenCount)
+	return tokenCount
+}
+
+// countMessageTokens counts tokens for chat messages, adding the [INST] and [/INST]
+// control tokens that wrap each message
+func (p *MistralProvider) countMessageTokens(messages []tokentracker.Message, tools []tokentracker.Tool, toolChoice *tokentracker.ToolChoice) int {
+	tokens := p.approximateTokenCount(tokentracker.ExtractTextFromMessages(messages) + tokentracker.ExtractToolCallText(messages))
+	tokens += len(messages) * 2
+
+	if len(tools) > 0 {
+		if toolsJSON, err := json.Marshal(tools); err == nil {
+			// Tools are wrapped in [AVAILABLE_TOOLS] and [/AVAILABLE_TOOLS]
+			tokens += p.approximateTokenCount(string(toolsJSON)) + 2
+		}
+	}
+	if toolChoice != nil {
+		if toolChoiceJSON, err := json.Marshal(toolChoice); err == nil {
+			tokens += p.approximateTokenCount(string(toolChoiceJSON))
+		}
+	}
+
+	return tokens
+}
+
+// CalculatePrice calculates price based on token usage. Versioned model names are
+// priced like their base model unless they have pricing of their own.
+func (p *MistralProvider) CalculatePrice(model string, inputTokens, outputTokens int) (tokentracker.Price, error) {
+	return p.CalculateUsagePrice(model, tokentracker.BillableUsage{InputTokens: inputTokens, OutputTokens: outputTokens})
+}
+
+// CalculateUsagePrice calculates price based on billable usage
+func (p *MistralProvider) CalculateUsagePrice(model string, usage tokentracker.BillableUsage) (tokentracker.Price, error) {
+	if model == "" {
+		return tokentracker.Price{}, tokentracker.NewError(tokentracker.ErrInvalidParams, "model is required", nil)
+	}
+
+	pricing, exists := p.config.GetModelPricing("mistral", model)
+	if !exists {
+		pricing, exists = p.config.GetModelPricing("mistral", mistralBaseModel(model))
+	}
+	if !exists {
+		return tokentracker.Price{}, tokentracker.NewError(tokentracker.ErrPricingNotFound, fmt.Sprintf("pricing not found for model: %s", model), nil)
+	}
+
+	return pricing.Cost(usage), nil
+}
+
+// SetSDKClient sets the provider-specific SDK client
+func (p *MistralProvider) SetSDKClient(client interface{}) {
+	p.sdkClient = client
+}
+
+// mistralContextWindows are the context windows of the supported models
+var mistralContextWindows = map[string]int{
+	"mistral-small":      32000,
+	"mistral-medium":     32000,
+	"mistral-large":      32000,
+	"open-mistral-7b":    32000,
+	"open-mixtral-8x7b":  32000,
+	"open-mixtral-8x22b": 64000,
+}
+
+// GetModelInfo returns information about a specific model. Versions of a model are
+// priced like the model.
+func (p *MistralProvider) GetModelInfo(model string) (tokentracker.ModelInfo, error) {
+	if !p.SupportsModel(model) {
+		return tokentracker.ModelInfo{}, tokentracker.NewError(tokentracker.ErrInvalidModel, fmt.Sprintf("unsupported model: %s", model), nil)
+	}
+
+	base := mistralBaseModel(model)
+	capabilities := []string{"text", "chat"}
+	if !strings.HasPrefix(base, "open-mistral") {
+		capabilities = append(capabilities, "function-calling")
+	}
+
+	info := tokentracker.ModelInfo{
+		Name:          model,
+		Provider:      "mistral",
+		Capabilities:  capabilities,
+		ContextWindow: mistralContextWindows[base],
+		Modalities:    textModalities,
+	}
+	if base != model {
+		info.PricingRef = base
+	}
+	return info, nil
+}
+
+// ExtractTokenUsageFromResponse extracts token usage from a chat completion response,
+// whose usage block has the same shape as OpenAI's
+func (p *MistralProvider) ExtractTokenUsageFromResponse(response interface{}) (tokentracker.TokenCount, error) {
+	if response == nil {
+		return tokentracker.TokenCount{}, tokentracker.NewError(tokentracker.ErrInvalidParams, "response is nil", nil)
+	}
+
+	respMap, ok := response.(map[string]interface{})
+	if !ok {
+		return tokentracker.TokenCount{}, tokentracker.NewError(tokentracker.ErrInvalidParams, "response is not a map", nil)
+	}
+
+	usage, ok := respMap["usage"].(map[string]interface{})
+	if !ok {
+		return tokentracker.TokenCount{}, tokentracker.NewError(tokentracker.ErrInvalidParams, "usage information not found in response", nil)
+	}
+
+	promptTokens, ok1 := usage["prompt_tokens"].(float64)
+	completionTokens, ok2 := usage["completion_tokens"].(float64)
+	if !ok1 || !ok2 {
+		return tokentracker.TokenCount{}, tokentracker.NewError(tokentracker.ErrInvalidParams, "token counts not found in response", nil)
+	}
+
+	totalTokens, ok := usage["total_tokens"].(float64)
+	if !ok {
+		totalTokens = promptTokens + completionTokens
+	}
+
+	return tokentracker.TokenCount{
+		InputTokens:    int(promptTokens),
+		ResponseTokens: int(completionTokens),
+		TotalTokens:    int(totalTokens),
+	}, nil
+}
+
+// UpdatePricing updates the pricing information for this provider
+func (p *MistralProvider) UpdatePricing() error {
+	// If we have an SDK client, we could use it to fetch the latest pricing
+	// For now, we'll just update with hardcoded values
+
+	// Mistral Small pricing (as of March 2024)
+	p.config.SetModelPricing("mistral", "mistral-small", tokentracker.ModelPricing{
+		InputPricePerToken:  0.000002,
+		OutputPricePerToken: 0.000006,
+		Currency:            "USD",
+	})
+
+	// Mistral Medium pricing (as of March 2024)
+	p.config.SetModelPricing("mistral", "mistral-medium", tokentracker.ModelPricing{
+		InputPricePerToken:  0.0000027,
+		OutputPricePerToken: 0.0000081,
+		Currency:            "USD",
+	})
+
+	// Mistral Large pricing (as of March 2024)
+	p.config.SetModelPricing("mistral", "mistral-large", tokentracker.ModelPricing{
+		InputPricePerToken:  0.000008,
+		OutputPricePerToken: 0.000024,
+		Currency:            "USD",
+	})
+
+	// Mistral 7B pricing (as of March 2024)
+	p.config.SetModelPricing("mistral", "open-mistral-7b", tokentracker.ModelPricing{
+		InputPricePerToken:  0.00000025,
+		OutputPricePerToken: 0.00000025,
+		Currency:            "USD",
+	})
+
+	// Mixtral 8x7B pricing (as of March 2024)
+	p.config.SetModelPricing("mistral", "open-mixtral-8x7b", tokentracker.ModelPricing{
+		InputPricePerToken:  0.0000007,
+		OutputPricePerToken: 0.0000007,
+		Currency:            "USD",
+	})
+
+	// Mixtral 8x22B pricing (as of March 2024)
+	p.config.SetModelPricing("mistral", "open-mixtral-8x22b", tokentracker.ModelPricing{
+		InputPricePerToken:  0.000002,
+		OutputPricePerToken: 0.000006,
+		Currency:            "USD",
+	})
+
+	return nil
+}
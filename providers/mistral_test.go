@@ -0,0 +1,154 @@
+package providers
+
+import (
+	"testing"
+
+	"github.com/TrustSight-io/tokentracker"
+)
+
+func TestMistralProvider_SupportsModel(t *testing.T) {
+	provider := NewMistralProvider(tokentracker.NewConfig())
+
+	if provider.Name() != "mistral" {
+		t.Errorf("Name() = %s, want mistral", provider.Name())
+	}
+
+	tests := []struct {
+		model string
+		want  bool
+	}{
+		{"mistral-small", true},
+		{"mistral-large-latest", true},
+		{"mistral-large-2402", true},
+		{"open-mixtral-8x22b", true},
+		{"open-mixtral-8x22b-2404", true},
+		{"gpt-4", false},
+		{"mistral-tiny-extra", false},
+	}
+	for _, tt := range tests {
+		if got := provider.SupportsModel(tt.model); got != tt.want {
+			t.Errorf("SupportsModel(%s) = %v, want %v", tt.model, got, tt.want)
+		}
+	}
+
+	if got := provider.DefaultModels(); len(got) != len(mistralModels) || got[0] != "mistral-large" {
+		t.Errorf("DefaultModels() = %v, want the sorted models", got)
+	}
+}
+
+func TestMistralProvider_CountTokens(t *testing.T) {
+	provider := NewMistralProvider(tokentracker.NewConfig())
+
+	text, err := provider.CountTokens(tokentracker.TokenCountParams{Model: "mistral-small", Text: StringPtr("Hello, world!")})
+	if err != nil {
+		t.Fatalf("CountTokens() error = %v", err)
+	}
+	if text.InputTokens == 0 || text.TotalTokens != text.InputTokens {
+		t.Errorf("CountTokens() = %+v", text)
+	}
+
+	chat, err := provider.CountTokens(tokentracker.TokenCountParams{
+		Model: "mistral-small",
+		Messages: []tokentracker.Message{
+			{Role: "system", Content: "You are helpful."},
+			{Role: "user", Content: "Hello, world!"},
+		},
+		CountResponseTokens: true,
+	})
+	if err != nil {
+		t.Fatalf("CountTokens() error = %v", err)
+	}
+	if chat.InputTokens <= text.InputTokens || chat.ResponseTokens == 0 {
+		t.Errorf("CountTokens() of messages = %+v, want message overhead and a response estimate", chat)
+	}
+
+	if _, err := provider.CountTokens(tokentracker.TokenCountParams{Model: "mistral-small"}); err == nil {
+		t.Error("CountTokens() without text or messages should fail")
+	}
+}
+
+func TestMistralProvider_CalculatePrice(t *testing.T) {
+	config := tokentracker.NewConfig()
+	provider := NewMistralProvider(config)
+
+	price, err := provider.CalculatePrice("mistral-large-latest", 1000, 500)
+	if err != nil {
+		t.Fatalf("CalculatePrice() error = %v", err)
+	}
+	if !approxEqual(price.TotalCost, 1000*0.000008+500*0.000024) {
+		t.Errorf("TotalCost = %v, want mistral-large's price", price.TotalCost)
+	}
+
+	config.SetModelPricing("mistral", "mistral-large-2407", tokentracker.ModelPricing{
+		InputPricePerToken:  0.000003,
+		OutputPricePerToken: 0.000009,
+		Currency:            "USD",
+	})
+	price, _ = provider.CalculatePrice("mistral-large-2407", 1000, 500)
+	if !approxEqual(price.TotalCost, 1000*0.000003+500*0.000009) {
+		t.Errorf("TotalCost = %v, want the version's own price", price.TotalCost)
+	}
+
+	if _, err := provider.CalculatePrice("unknown", 1, 1); err == nil {
+		t.Error("CalculatePrice() of an unknown model should fail")
+	}
+
+	if err := provider.UpdatePricing(); err != nil {
+		t.Fatalf("UpdatePricing() error = %v", err)
+	}
+	if _, exists := config.GetModelPricing("mistral", "open-mixtral-8x7b"); !exists {
+		t.Error("UpdatePricing() should set pricing for the open models")
+	}
+}
+
+func TestMistralProvider_ExtractTokenUsageFromResponse(t *testing.T) {
+	provider := NewMistralProvider(tokentracker.NewConfig())
+
+	response := map[string]interface{}{
+		"id":    "cmpl-e5cc70bb28c444948073e77776eb30ef",
+		"model": "mistral-small-latest",
+		"usage": map[string]interface{}{
+			"prompt_tokens":     float64(16),
+			"completion_tokens": float64(34),
+			"total_tokens":      float64(50),
+		},
+	}
+	count, err := provider.ExtractTokenUsageFromResponse(response)
+	if err != nil {
+		t.Fatalf("ExtractTokenUsageFromResponse() error = %v", err)
+	}
+	if count.InputTokens != 16 || count.ResponseTokens != 34 || count.TotalTokens != 50 {
+		t.Errorf("count = %+v", count)
+	}
+
+	if _, err := provider.ExtractTokenUsageFromResponse(map[string]interface{}{}); err == nil {
+		t.Error("ExtractTokenUsageFromResponse() without usage should fail")
+	}
+	if _, err := provider.ExtractTokenUsageFromResponse(nil); err == nil {
+		t.Error("ExtractTokenUsageFromResponse() of nil should fail")
+	}
+}
+
+func TestMistralProvider_TokenCacheIsolation(t *testing.T) {
+	first, second := tokentracker.NewConfig(), tokentracker.NewConfig()
+	firstCache := tokentracker.NewTokenCache(tokentracker.TokenCacheOptions{})
+	secondCache := tokentracker.NewTokenCache(tokentracker.TokenCacheOptions{})
+	first.SetTokenCache(firstCache)
+	second.SetTokenCache(secondCache)
+
+	params := tokentracker.TokenCountParams{Model: "mistral-small", Text: StringPtr("Isolated caches")}
+	if _, err := NewMistralProvider(first).CountTokens(params); err != nil {
+		t.Fatalf("CountTokens() error = %v", err)
+	}
+
+	if entries := firstCache.Stats().Entries; entries != 1 {
+		t.Errorf("first cache entries = %d, want 1", entries)
+	}
+	if entries := secondCache.Stats().Entries; entries != 0 {
+		t.Errorf("second cache entries = %d, want the count kept out of other trackers' caches", entries)
+	}
+}
+
+func TestMistralProvider_CountsSystemInstructionWithText(t *testing.T) {
+	assertCountsSystemInstructionWithText(t, NewMistralProvider(tokentracker.NewConfig()), "mistral-small")
+}
@@ -0,0 +1,40 @@
+package providers
+
+import (
+	"time"
+
+	"github.com/TrustSight-io/tokentracker"
+)
+
+// Input modalities shared by the model tables
+var (
+	textModalities      = []string{"text"}
+	textImageModalities = []string{"text", "image"}
+)
+
+// trainingCutoff returns the month a model's training data ends
+func trainingCutoff(year int, month time.Month) time.Time {
+	return time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// pricingRef returns the model the catalog prices a model of the provider like, or ""
+// when the model is priced on its own
+func pricingRef(config *tokentracker.Config, provider, model string) string {
+	entry, exists := config.ModelCatalog().Lookup(model)
+	if !exists || entry.Provider != provider || entry.PricingModel == model {
+		return ""
+	}
+	return entry.PricingModel
+}
+
+// lookupModelInfo returns the information in infos about a model, or about the model
+// the catalog prices it like, which is recorded as the pricing reference
+func lookupModelInfo(config *tokentracker.Config, provider string, infos map[string]tokentracker.ModelInfo, model string) (tokentracker.ModelInfo, bool) {
+	ref := pricingRef(config, provider, model)
+	info, exists := infos[model]
+	if !exists && ref != "" {
+		info, exists = infos[ref]
+	}
+	info.PricingRef = ref
+	return info, exists
+}
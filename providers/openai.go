@@ -1,8 +1,14 @@
 package providers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
 	"github.com/TrustSight-io/tokentracker"
 	"github.com/pkoukk/tiktoken-go"
@@ -10,13 +16,18 @@ import (
 
 // OpenAIProvider implements the Provider interface for OpenAI models
 type OpenAIProvider struct {
-	config *tokentracker.Config
+	config    *tokentracker.Config
+	encodings map[string]string // set with SetEncoding
+	warned    map[string]bool   // unknown models logged
+	mu        sync.RWMutex
 }
 
 // NewOpenAIProvider creates a new OpenAI provider
 func NewOpenAIProvider(config *tokentracker.Config) *OpenAIProvider {
 	return &OpenAIProvider{
-		config: config,
+		config:    config,
+		encodings: make(map[string]string),
+		warned:    make(map[string]bool),
 	}
 }
 
@@ -25,65 +36,147 @@ func (p *OpenAIProvider) Name() string {
 	return "openai"
 }
 
-// SupportsModel checks if the provider supports a specific model
+// openAIModels lists the models supported out of the box
+var openAIModels = map[string]bool{
+	"gpt-3.5-turbo":          true,
+	"gpt-3.5-turbo-16k":      true,
+	"gpt-4":                  true,
+	"gpt-4-turbo":            true,
+	"gpt-4-32k":              true,
+	"gpt-4o":                 true,
+	"gpt-4o-audio-preview":   true,
+	"o1":                     true,
+	"o1-mini":                true,
+	"o3-mini":                true,
+	"text-embedding-ada":     true,
+	"text-embedding-ada-002": true,
+	"text-embedding-3-small": true,
+	"text-embedding-3-large": true,
+	// Add more models as needed
+}
+
+// openAIAudioTokensPerSecond is the rate at which GPT-4o bills audio inputs
+const openAIAudioTokensPerSecond = 10
+
+// SupportsModel checks if the provider supports the given model, either out of the
+// box or through the model catalog of the configuration
 func (p *OpenAIProvider) SupportsModel(model string) bool {
-	supportedModels := map[string]bool{
-		"gpt-3.5-turbo":      true,
-		"gpt-3.5-turbo-16k":  true,
-		"gpt-4":              true,
-		"gpt-4-turbo":        true,
-		"gpt-4-32k":          true,
-		"gpt-4o":             true,
-		"text-embedding-ada": true,
-		// Add more models as needed
-	}
+	return openAIModels[model] || p.config.ModelCatalog().Supports(p.Name(), model)
+}
 
-	return supportedModels[model]
+// DefaultModels returns the models the provider supports out of the box
+func (p *OpenAIProvider) DefaultModels() []string {
+	models := make([]string, 0, len(openAIModels))
+	for model := range openAIModels {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+	return models
 }
 
 // CountTokens counts tokens for the given parameters
 func (p *OpenAIProvider) CountTokens(params tokentracker.TokenCountParams) (tokentracker.TokenCount, error) {
+	return p.CountTokensCtx(context.Background(), params)
+}
+
+// CountTokensCtx counts tokens for the given parameters, honoring cancellation of ctx
+func (p *OpenAIProvider) CountTokensCtx(ctx context.Context, params tokentracker.TokenCountParams) (tokentracker.TokenCount, error) {
+	if err := ctx.Err(); err != nil {
+		return tokentracker.TokenCount{}, err
+	}
+
 	if params.Model == "" {
 		return tokentracker.TokenCount{}, tokentracker.NewError(tokentracker.ErrInvalidParams, "model is required", nil)
 	}
 
-	// Get the encoding for the model
-	encoding, err := p.getEncoding(params.Model)
-	if err != nil {
-		return tokentracker.TokenCount{}, err
-	}
+	// Normalize the input before tokenization
+	normalization := p.config.GetNormalization()
+	params = normalization.ApplyToParams(params.WithSystemMessage())
 
 	var inputTokens int
+	var algorithm string
 
 	// Count tokens based on the input type
 	if params.Text != nil {
 		// Count tokens for text
-		inputTokens = len(encoding.Encode(*params.Text, nil, nil))
+		count, err := p.countTextTokens(params.Model, *params.Text)
+		if err != nil {
+			return tokentracker.TokenCount{}, err
+		}
+		inputTokens = count
 	} else if len(params.Messages) > 0 {
-		// Count tokens for chat messages
-		inputTokens, err = p.countMessageTokens(params.Model, params.Messages, params.Tools, params.ToolChoice, encoding)
+		// Get the encoding for the model
+		encoding, err := p.getEncoding(params.Model)
 		if err != nil {
 			return tokentracker.TokenCount{}, err
 		}
+
+		// Count tokens for chat messages with the algorithm selected by the counting flag
+		key := tokentracker.PromptFingerprint(params)
+		count, err := p.config.CountWithAlgorithm(p.Name(), params.Model, key, func(alg tokentracker.CountingAlgorithm) (tokentracker.TokenCount, error) {
+			var tokens int
+			var err error
+			if alg == tokentracker.AlgorithmV2 {
+				tokens, err = p.countChatMLTokens(params.Model, params.Messages, params.Tools, params.ToolChoice, encoding)
+			} else {
+				tokens, err = p.countMessageTokens(params.Model, params.Messages, params.Tools, params.ToolChoice, encoding)
+			}
+			return tokentracker.TokenCount{InputTokens: tokens}, err
+		})
+		if err != nil {
+			return tokentracker.TokenCount{}, err
+		}
+		inputTokens = count.InputTokens
+		algorithm = count.Algorithm
 	} else {
 		return tokentracker.TokenCount{}, tokentracker.NewError(tokentracker.ErrInvalidParams, "either text or messages must be provided", nil)
 	}
 
+	// Audio inputs are billed by duration
+	audio, _ := tokentracker.MediaDurations(params.Messages)
+	audioTokens := tokentracker.MediaTokens(audio, openAIAudioTokensPerSecond)
+	inputTokens += audioTokens
+
 	// Estimate response tokens if requested
 	var responseTokens int
 	if params.CountResponseTokens {
-		responseTokens = p.estimateResponseTokens(params.Model, inputTokens)
+		responseTokens = p.config.GetResponseEstimator().EstimateResponseTokens(params, inputTokens)
 	}
 
 	return tokentracker.TokenCount{
 		InputTokens:    inputTokens,
 		ResponseTokens: responseTokens,
 		TotalTokens:    inputTokens + responseTokens,
+		AudioTokens:    audioTokens,
+		Normalization:  normalization.Profile(),
+		Algorithm:      algorithm,
 	}, nil
 }
 
+// TokenizeText returns the pieces of text making up its tokens, so text can be cut at
+// token boundaries. A piece may end inside a multi-byte character.
+func (p *OpenAIProvider) TokenizeText(model, text string) ([]string, error) {
+	encoding, err := p.getEncoding(model)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := encoding.Encode(text, nil, nil)
+	pieces := make([]string, len(tokens))
+	for i, token := range tokens {
+		pieces[i] = encoding.Decode([]int{token})
+	}
+	return pieces, nil
+}
+
 // CalculatePrice calculates price based on token usage
 func (p *OpenAIProvider) CalculatePrice(model string, inputTokens, outputTokens int) (tokentracker.Price, error) {
+	return p.CalculateUsagePrice(model, tokentracker.BillableUsage{InputTokens: inputTokens, OutputTokens: outputTokens})
+}
+
+// CalculateUsagePrice calculates price based on billable usage, billing prompt cache
+// hits at the cached input price
+func (p *OpenAIProvider) CalculateUsagePrice(model string, usage tokentracker.BillableUsage) (tokentracker.Price, error) {
 	if model == "" {
 		return tokentracker.Price{}, tokentracker.NewError(tokentracker.ErrInvalidParams, "model is required", nil)
 	}
@@ -94,17 +187,7 @@ func (p *OpenAIProvider) CalculatePrice(model string, inputTokens, outputTokens
 		return tokentracker.Price{}, tokentracker.NewError(tokentracker.ErrPricingNotFound, fmt.Sprintf("pricing not found for model: %s", model), nil)
 	}
 
-	// Calculate costs
-	inputCost := float64(inputTokens) * pricing.InputPricePerToken
-	outputCost := float64(outputTokens) * pricing.OutputPricePerToken
-	totalCost := inputCost + outputCost
-
-	return tokentracker.Price{
-		InputCost:  inputCost,
-		OutputCost: outputCost,
-		TotalCost:  totalCost,
-		Currency:   pricing.Currency,
-	}, nil
+	return pricing.Cost(usage), nil
 }
 
 // SetSDKClient sets the provider-specific SDK client
@@ -114,14 +197,35 @@ func (p *OpenAIProvider) SetSDKClient(client interface{}) {
 }
 
 // GetModelInfo returns information about a specific model
-func (p *OpenAIProvider) GetModelInfo(model string) (interface{}, error) {
-	// In a real implementation, this would return model information
-	// For now, we'll just return a simple map
-	return map[string]interface{}{
-		"name":         model,
-		"provider":     "openai",
-		"capabilities": []string{"text", "chat", "function-calling"},
-	}, nil
+func (p *OpenAIProvider) GetModelInfo(model string) (tokentracker.ModelInfo, error) {
+	info, _ := lookupModelInfo(p.config, p.Name(), openAIModelInfo, model)
+	info.Name = model
+	info.Provider = p.Name()
+	info.Capabilities = []string{"text", "chat", "function-calling"}
+	if strings.HasPrefix(model, "text-embedding-") {
+		info.Capabilities = []string{"text", "embeddings"}
+	}
+	return info, nil
+}
+
+// openAIModelInfo holds the token limits, input modalities and training cutoffs of the
+// supported models (as of August 2024)
+var openAIModelInfo = map[string]tokentracker.ModelInfo{
+	"gpt-3.5-turbo":          {ContextWindow: 16385, MaxOutputTokens: 4096, Modalities: textModalities, TrainingCutoff: trainingCutoff(2021, time.September)},
+	"gpt-3.5-turbo-16k":      {ContextWindow: 16385, MaxOutputTokens: 4096, Modalities: textModalities, TrainingCutoff: trainingCutoff(2021, time.September), Deprecated: true},
+	"gpt-4":                  {ContextWindow: 8192, MaxOutputTokens: 8192, Modalities: textModalities, TrainingCutoff: trainingCutoff(2021, time.September)},
+	"gpt-4-32k":              {ContextWindow: 32768, MaxOutputTokens: 32768, Modalities: textModalities, TrainingCutoff: trainingCutoff(2021, time.September), Deprecated: true},
+	"gpt-4-turbo":            {ContextWindow: 128000, MaxOutputTokens: 4096, Modalities: textImageModalities, TrainingCutoff: trainingCutoff(2023, time.December)},
+	"gpt-4o":                 {ContextWindow: 128000, MaxOutputTokens: 4096, Modalities: textImageModalities, TrainingCutoff: trainingCutoff(2023, time.October)},
+	"gpt-4o-mini":            {ContextWindow: 128000, MaxOutputTokens: 16384, Modalities: textImageModalities, TrainingCutoff: trainingCutoff(2023, time.October)},
+	"gpt-4o-audio-preview":   {ContextWindow: 128000, MaxOutputTokens: 16384, Modalities: []string{"text", "audio"}, TrainingCutoff: trainingCutoff(2023, time.October)},
+	"o1":                     {ContextWindow: 200000, MaxOutputTokens: 100000, Modalities: textImageModalities, TrainingCutoff: trainingCutoff(2023, time.October)},
+	"o1-mini":                {ContextWindow: 128000, MaxOutputTokens: 65536, Modalities: textModalities, TrainingCutoff: trainingCutoff(2023, time.October)},
+	"o3-mini":                {ContextWindow: 200000, MaxOutputTokens: 100000, Modalities: textModalities, TrainingCutoff: trainingCutoff(2023, time.October)},
+	"text-embedding-ada":     {ContextWindow: 8191, Modalities: textModalities},
+	"text-embedding-ada-002": {ContextWindow: 8191, Modalities: textModalities},
+	"text-embedding-3-small": {ContextWindow: 8191, Modalities: textModalities},
+	"text-embedding-3-large": {ContextWindow: 8191, Modalities: textModalities},
 }
 
 // ExtractTokenUsageFromResponse extracts token usage from a provider response
@@ -143,19 +247,39 @@ func (p *OpenAIProvider) ExtractTokenUsageFromResponse(response interface{}) (to
 		return tokentracker.TokenCount{}, tokentracker.NewError(tokentracker.ErrInvalidParams, "usage information not found in response", nil)
 	}
 
-	// Extract token counts
+	// Extract token counts; embedding responses report no completion tokens
 	promptTokens, ok1 := usage["prompt_tokens"].(float64)
 	completionTokens, ok2 := usage["completion_tokens"].(float64)
 	totalTokens, ok3 := usage["total_tokens"].(float64)
 
-	if !ok1 || !ok2 || !ok3 {
+	if !ok1 || !ok3 || (!ok2 && usage["completion_tokens"] != nil) {
 		return tokentracker.TokenCount{}, tokentracker.NewError(tokentracker.ErrInvalidParams, "token counts not found in response", nil)
 	}
 
+	// Prompt cache hits and audio inputs are reported as part of the prompt tokens
+	var cachedTokens, audioTokens float64
+	if details, ok := usage["prompt_tokens_details"].(map[string]interface{}); ok {
+		cachedTokens, _ = details["cached_tokens"].(float64)
+		audioTokens, _ = details["audio_tokens"].(float64)
+	}
+
+	// o-series reasoning is reported as part of the completion tokens
+	var reasoningTokens float64
+	if details, ok := usage["completion_tokens_details"].(map[string]interface{}); ok {
+		reasoningTokens, _ = details["reasoning_tokens"].(float64)
+	}
+
+	model, _ := respMap["model"].(string)
+	toolTokens := toolCallTokens(openAIResponseToolCalls(respMap), int(completionTokens), p.textTokenCounter(model))
+
 	return tokentracker.TokenCount{
-		InputTokens:    int(promptTokens),
-		ResponseTokens: int(completionTokens),
-		TotalTokens:    int(totalTokens),
+		InputTokens:       int(promptTokens),
+		ResponseTokens:    int(completionTokens),
+		TotalTokens:       int(totalTokens),
+		CachedInputTokens: int(cachedTokens),
+		AudioTokens:       int(audioTokens),
+		ReasoningTokens:   int(reasoningTokens),
+		ToolCallTokens:    toolTokens,
 	}, nil
 }
 
@@ -185,29 +309,112 @@ func (p *OpenAIProvider) UpdatePricing() error {
 		Currency:            "USD",
 	})
 
+	// o-series pricing (as of January 2025); reasoning tokens are billed as output
+	p.config.SetModelPricing("openai", "o1", tokentracker.ModelPricing{
+		InputPricePerToken:       0.000015,
+		OutputPricePerToken:      0.00006,
+		CachedInputPricePerToken: 0.0000075,
+		Currency:                 "USD",
+	})
+	p.config.SetModelPricing("openai", "o1-mini", tokentracker.ModelPricing{
+		InputPricePerToken:       0.0000011,
+		OutputPricePerToken:      0.0000044,
+		CachedInputPricePerToken: 0.00000055,
+		Currency:                 "USD",
+	})
+	p.config.SetModelPricing("openai", "o3-mini", tokentracker.ModelPricing{
+		InputPricePerToken:       0.0000011,
+		OutputPricePerToken:      0.0000044,
+		CachedInputPricePerToken: 0.00000055,
+		Currency:                 "USD",
+	})
+
+	// Embedding pricing (as of March 2024); embeddings have no output tokens
+	p.config.SetModelPricing("openai", "text-embedding-3-small", tokentracker.ModelPricing{
+		InputPricePerToken: 0.00000002,
+		Currency:           "USD",
+	})
+	p.config.SetModelPricing("openai", "text-embedding-3-large", tokentracker.ModelPricing{
+		InputPricePerToken: 0.00000013,
+		Currency:           "USD",
+	})
+	p.config.SetModelPricing("openai", "text-embedding-ada-002", tokentracker.ModelPricing{
+		InputPricePerToken: 0.0000001,
+		Currency:           "USD",
+	})
+
+	// Fine-tuned models and their training (as of August 2024)
+	p.config.SetFineTunedPricing("openai", "gpt-3.5-turbo", tokentracker.ModelPricing{
+		InputPricePerToken:    0.000003,
+		OutputPricePerToken:   0.000006,
+		TrainingPricePerToken: 0.000008,
+		Currency:              "USD",
+	})
+	p.config.SetFineTunedPricing("openai", "gpt-4o-mini", tokentracker.ModelPricing{
+		InputPricePerToken:    0.0000003,
+		OutputPricePerToken:   0.0000012,
+		TrainingPricePerToken: 0.000003,
+		Currency:              "USD",
+	})
+	p.config.SetFineTunedPricing("openai", "gpt-4o", tokentracker.ModelPricing{
+		InputPricePerToken:    0.00000375,
+		OutputPricePerToken:   0.000015,
+		TrainingPricePerToken: 0.000025,
+		Currency:              "USD",
+	})
+
+	// GPT-4o audio pricing (as of October 2024); audio inputs cost more than text
+	p.config.SetModelPricing("openai", "gpt-4o-audio-preview", tokentracker.ModelPricing{
+		InputPricePerToken:      0.0000025,
+		OutputPricePerToken:     0.00001,
+		Currency:                "USD",
+		AudioInputPricePerToken: 0.0001,
+	})
+
 	return nil
 }
 
-// getEncoding returns the encoding for the given model
-func (p *OpenAIProvider) getEncoding(model string) (*tiktoken.Tiktoken, error) {
-	// Map model to encoding
-	encodingName := "cl100k_base" // Default for most newer models
+// textTokenCounter returns a function counting text with the encoding of a model. When
+// the encoding is unavailable, text is approximated at 4 characters per token.
+func (p *OpenAIProvider) textTokenCounter(model string) func(string) int {
+	encoding, err := p.getEncoding(model)
+	if err != nil {
+		return func(text string) int { return (utf8.RuneCountInString(text) + 3) / 4 }
+	}
+	return func(text string) int { return len(encoding.Encode(text, nil, nil)) }
+}
 
-	// Override for specific models if needed
-	if model == "text-embedding-ada" {
-		encodingName = "r50k_base"
+// CountTextTokens counts the tokens of normalized text, see tokentracker.TextTokenCounter
+func (p *OpenAIProvider) CountTextTokens(model, text string) (int, error) {
+	return p.countTextTokens(model, p.config.GetNormalization().Apply(text))
+}
+
+// TextPromptOverhead returns the tokens added to text prompts: none, as text is
+// counted as it is encoded
+func (p *OpenAIProvider) TextPromptOverhead(string) int {
+	return 0
+}
+
+// countTextTokens counts the tokens of a text. Counts are cached by encoding, so a
+// cached text is neither encoded nor is the encoding loaded.
+func (p *OpenAIProvider) countTextTokens(model, text string) (int, error) {
+	encodingName, _ := p.Encoding(model)
+	scope := tokentracker.CacheScope(encodingName, p.config.GetNormalization())
+	if count, exists := p.config.GetTokenCache().Get("openai", scope, text); exists {
+		return count, nil
 	}
 
-	// Get the encoding
-	encoding, err := tiktoken.GetEncoding(encodingName)
+	encoding, err := p.getEncoding(model)
 	if err != nil {
-		return nil, tokentracker.NewError(tokentracker.ErrTokenizationFailed, "failed to get encoding", err)
+		return 0, err
 	}
-
-	return encoding, nil
+	count := len(encoding.Encode(text, nil, nil))
+	p.config.GetTokenCache().Set("openai", scope, text, count)
+	return count, nil
 }
 
-// countMessageTokens counts tokens for chat messages
+// countMessageTokens counts tokens for chat messages with the legacy algorithm, which
+// tokenizes their JSON encoding and overcounts by about 15%
 func (p *OpenAIProvider) countMessageTokens(_ string, messages []tokentracker.Message, tools []tokentracker.Tool, toolChoice *tokentracker.ToolChoice, encoding *tiktoken.Tiktoken) (int, error) {
 	// Convert messages to JSON for token counting
 	messagesJSON, err := json.Marshal(messages)
@@ -219,24 +426,11 @@ func (p *OpenAIProvider) countMessageTokens(_ string, messages []tokentracker.Me
 	tokens := len(encoding.Encode(string(messagesJSON), nil, nil))
 
 	// Add tokens for tools if present
-	if len(tools) > 0 {
-		toolsJSON, err := json.Marshal(tools)
-		if err != nil {
-			return 0, tokentracker.NewError(tokentracker.ErrTokenizationFailed, "failed to marshal tools", err)
-		}
-
-		tokens += len(encoding.Encode(string(toolsJSON), nil, nil))
-	}
-
-	// Add tokens for tool choice if present
-	if toolChoice != nil {
-		toolChoiceJSON, err := json.Marshal(toolChoice)
-		if err != nil {
-			return 0, tokentracker.NewError(tokentracker.ErrTokenizationFailed, "failed to marshal tool choice", err)
-		}
-
-		tokens += len(encoding.Encode(string(toolChoiceJSON), nil, nil))
+	toolTokens, err := p.countToolTokens(messages, tools, toolChoice, encoding)
+	if err != nil {
+		return 0, err
 	}
+	tokens += toolTokens
 
 	// Add tokens for message formatting
 	// This is a simplified approach; a real implementation would be more precise
@@ -245,9 +439,78 @@ func (p *OpenAIProvider) countMessageTokens(_ string, messages []tokentracker.Me
 	return tokens, nil
 }
 
-// estimateResponseTokens estimates the number of response tokens
-func (p *OpenAIProvider) estimateResponseTokens(model string, inputTokens int) int {
-	// This is a very simplified estimation
-	// In a real implementation, this would be more sophisticated
-	return tokentracker.EstimateResponseTokens(model, inputTokens)
+// openAIMessageOverhead returns the tokens OpenAI adds per message and per name for
+// a model family, following OpenAI's documented counting algorithm
+func openAIMessageOverhead(model string) (tokensPerMessage, tokensPerName int) {
+	// gpt-3.5-turbo-0301 renders every message as <|start|>{role/name}\n{content}<|end|>\n,
+	// and a name replaces the role
+	if model == "gpt-3.5-turbo-0301" {
+		return 4, -1
+	}
+	return 3, 1
+}
+
+// openAIReplyPriming is the tokens priming the reply of a ChatML conversation
+const openAIReplyPriming = 3
+
+// CountMessageTokens counts one message of a conversation, see
+// tokentracker.MessageTokenCounter. Only the ChatML algorithm counts conversations as
+// the sum of their messages, so it fails unless every request of the provider is
+// counted with AlgorithmV2, and for messages with audio, which is billed separately.
+func (p *OpenAIProvider) CountMessageTokens(model string, message tokentracker.Message) (int, error) {
+	flag := p.config.GetCountingFlag(p.Name())
+	if flag.Algorithm != tokentracker.AlgorithmV2 || (flag.RolloutPercent > 0 && flag.RolloutPercent < 100) {
+		return 0, tokentracker.NewError(tokentracker.ErrTokenizationFailed, "messages are counted on their own only with the v2 algorithm", nil)
+	}
+	if audio, _ := tokentracker.MediaDurations([]tokentracker.Message{message}); audio > 0 {
+		return 0, tokentracker.NewError(tokentracker.ErrTokenizationFailed, "messages with audio are not counted on their own", nil)
+	}
+
+	encoding, err := p.getEncoding(model)
+	if err != nil {
+		return 0, err
+	}
+	params := p.config.GetNormalization().ApplyToParams(tokentracker.TokenCountParams{Model: model, Messages: []tokentracker.Message{message}})
+	tokens, err := p.countChatMLTokens(model, params.Messages, nil, nil, encoding)
+	if err != nil {
+		return 0, err
+	}
+	// The reply is primed once per conversation
+	return tokens - openAIReplyPriming, nil
+}
+
+// countChatMLTokens counts chat messages the way OpenAI renders them in ChatML,
+// following OpenAI's documented algorithm: every message adds its delimiter tokens
+// plus its role, name, text content and tool calls, and the reply is primed with 3
+// more tokens
+func (p *OpenAIProvider) countChatMLTokens(model string, messages []tokentracker.Message, tools []tokentracker.Tool, toolChoice *tokentracker.ToolChoice, encoding *tiktoken.Tiktoken) (int, error) {
+	tokensPerMessage, tokensPerName := openAIMessageOverhead(model)
+
+	// Function definitions are rendered after the system message, separated by a newline
+	padSystem := hasOpenAIFunctions(tools)
+
+	tokens := openAIReplyPriming
+	for _, message := range messages {
+		tokens += tokensPerMessage
+		tokens += len(encoding.Encode(message.Role, nil, nil))
+		text := strings.TrimSuffix(tokentracker.ExtractTextFromMessages([]tokentracker.Message{message}), "\n")
+		if padSystem && message.Role == "system" {
+			text += "\n"
+			padSystem = false
+		}
+		tokens += len(encoding.Encode(text, nil, nil))
+		if message.Name != "" {
+			tokens += len(encoding.Encode(message.Name, nil, nil)) + tokensPerName
+		}
+		// Tool calls of assistant messages and the call IDs of tool results
+		if toolText := tokentracker.ExtractToolCallText([]tokentracker.Message{message}); toolText != "" {
+			tokens += len(encoding.Encode(strings.TrimSuffix(toolText, "\n"), nil, nil))
+		}
+	}
+
+	toolTokens, err := p.countToolTokens(messages, tools, toolChoice, encoding)
+	if err != nil {
+		return 0, err
+	}
+	return tokens + toolTokens, nil
 }
@@ -0,0 +1,135 @@
+package providers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/TrustSight-io/tokentracker"
+	"github.com/pkoukk/tiktoken-go"
+)
+
+// Encodings of OpenAI models
+const (
+	EncodingO200kBase  = "o200k_base"  // GPT-4o, GPT-4.1 and the o-series
+	EncodingCl100kBase = "cl100k_base" // GPT-4, GPT-3.5 Turbo and the embedding models
+	EncodingP50kBase   = "p50k_base"
+	EncodingR50kBase   = "r50k_base"
+
+	// DefaultOpenAIEncoding counts models missing from the encoding table
+	DefaultOpenAIEncoding = EncodingCl100kBase
+)
+
+// openAIEncodings maps models to their encodings. Keys ending in "*" are prefixes
+// matching dated and point releases; the longest matching prefix wins.
+var openAIEncodings = map[string]string{
+	"gpt-4o*":                EncodingO200kBase,
+	"chatgpt-4o*":            EncodingO200kBase,
+	"gpt-4.1*":               EncodingO200kBase,
+	"gpt-4.5*":               EncodingO200kBase,
+	"gpt-5*":                 EncodingO200kBase,
+	"o1*":                    EncodingO200kBase,
+	"o3*":                    EncodingO200kBase,
+	"o4*":                    EncodingO200kBase,
+	"gpt-4*":                 EncodingCl100kBase,
+	"gpt-3.5-turbo*":         EncodingCl100kBase,
+	"text-embedding-3-*":     EncodingCl100kBase,
+	"text-embedding-ada-002": EncodingCl100kBase,
+	"text-embedding-ada":     EncodingR50kBase,
+	"davinci-002":            EncodingCl100kBase,
+	"babbage-002":            EncodingCl100kBase,
+}
+
+// knownEncodings are the encodings models can be mapped to
+var knownEncodings = map[string]bool{
+	EncodingO200kBase:  true,
+	EncodingCl100kBase: true,
+	EncodingP50kBase:   true,
+	EncodingR50kBase:   true,
+	"p50k_edit":        true,
+}
+
+// lookupEncoding returns the encoding of a model in a table of models and prefixes
+func lookupEncoding(table map[string]string, model string) (string, bool) {
+	if encoding, exists := table[model]; exists {
+		return encoding, true
+	}
+
+	encoding, longest := "", -1
+	for key, candidate := range table {
+		prefix, isPrefix := strings.CutSuffix(key, "*")
+		if isPrefix && strings.HasPrefix(model, prefix) && len(prefix) > longest {
+			encoding, longest = candidate, len(prefix)
+		}
+	}
+	return encoding, longest >= 0
+}
+
+// SetEncoding maps a model to an encoding, overriding the built-in table, e.g. for a
+// fine-tuned model or a new release. A model ending in "*" maps every model starting
+// with the rest of it.
+func (p *OpenAIProvider) SetEncoding(model, encoding string) error {
+	if model == "" || model == "*" {
+		return tokentracker.NewError(tokentracker.ErrInvalidParams, "model is required", nil)
+	}
+	if !knownEncodings[encoding] {
+		return tokentracker.NewError(tokentracker.ErrInvalidParams, fmt.Sprintf("unknown encoding: %s", encoding), nil)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.encodings[model] = encoding
+	return nil
+}
+
+// Encoding returns the name of the encoding counting the tokens of a model, and false
+// when the model is unknown and counted with DefaultOpenAIEncoding. Models of the
+// model catalog are counted like their pricing model.
+func (p *OpenAIProvider) Encoding(model string) (string, bool) {
+	if encoding, exists := p.lookupEncoding(model); exists {
+		return encoding, true
+	}
+	// Fine-tuned models are tokenized like their base model
+	if fineTuned, ok := tokentracker.ParseFineTunedModel(model); ok {
+		return p.Encoding(fineTuned.BaseModel)
+	}
+	if entry, ok := p.config.ModelCatalog().Lookup(model); ok && entry.PricingModel != "" {
+		if encoding, exists := p.lookupEncoding(entry.PricingModel); exists {
+			return encoding, true
+		}
+	}
+	return DefaultOpenAIEncoding, false
+}
+
+// lookupEncoding returns the encoding of a model set with SetEncoding, or else built in
+func (p *OpenAIProvider) lookupEncoding(model string) (string, bool) {
+	p.mu.RLock()
+	encoding, exists := lookupEncoding(p.encodings, model)
+	p.mu.RUnlock()
+	if exists {
+		return encoding, true
+	}
+	return lookupEncoding(openAIEncodings, model)
+}
+
+// getEncoding returns the encoding for the given model. Unknown models are counted with
+// DefaultOpenAIEncoding and logged once, as their counts may be off.
+func (p *OpenAIProvider) getEncoding(model string) (*tiktoken.Tiktoken, error) {
+	encodingName, known := p.Encoding(model)
+	if !known {
+		p.mu.Lock()
+		warn := !p.warned[model]
+		p.warned[model] = true
+		p.mu.Unlock()
+		if warn {
+			p.config.GetLogger().Warn("no encoding known for model, counting with the default encoding",
+				"provider", p.Name(), "model", model, "encoding", encodingName)
+		}
+	}
+
+	encoding, err := tiktoken.GetEncoding(encodingName)
+	if err != nil {
+		return nil, tokentracker.NewError(tokentracker.ErrTokenizationFailed, "failed to get encoding", err)
+	}
+	return encoding, nil
+}
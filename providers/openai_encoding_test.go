@@ -0,0 +1,83 @@
+package providers
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/TrustSight-io/tokentracker"
+)
+
+func TestOpenAIProvider_Encoding(t *testing.T) {
+	provider := NewOpenAIProvider(tokentracker.NewConfig())
+
+	tests := []struct {
+		model    string
+		encoding string
+		known    bool
+	}{
+		{"gpt-4o", EncodingO200kBase, true},
+		{"gpt-4o-mini-2024-07-18", EncodingO200kBase, true},
+		{"o1-preview", EncodingO200kBase, true},
+		{"o3-mini", EncodingO200kBase, true},
+		{"gpt-4", EncodingCl100kBase, true},
+		{"gpt-4-turbo-2024-04-09", EncodingCl100kBase, true},
+		{"gpt-3.5-turbo-0125", EncodingCl100kBase, true},
+		{"text-embedding-3-small", EncodingCl100kBase, true},
+		{"text-embedding-ada", EncodingR50kBase, true},
+		{"my-model", DefaultOpenAIEncoding, false},
+	}
+	for _, tt := range tests {
+		encoding, known := provider.Encoding(tt.model)
+		if encoding != tt.encoding || known != tt.known {
+			t.Errorf("Encoding(%s) = %s, %v, want %s, %v", tt.model, encoding, known, tt.encoding, tt.known)
+		}
+	}
+}
+
+func TestOpenAIProvider_SetEncoding(t *testing.T) {
+	config := tokentracker.NewConfig()
+	provider := NewOpenAIProvider(config)
+
+	if err := provider.SetEncoding("ft:gpt-4o-mini:*", EncodingO200kBase); err != nil {
+		t.Fatalf("SetEncoding() error = %v", err)
+	}
+	if err := provider.SetEncoding("gpt-4-legacy", EncodingP50kBase); err != nil {
+		t.Fatalf("SetEncoding() error = %v", err)
+	}
+	if encoding, known := provider.Encoding("ft:gpt-4o-mini:acme:support:abc123"); encoding != EncodingO200kBase || !known {
+		t.Errorf("Encoding() of a fine-tuned model = %s, %v, want the prefix mapping", encoding, known)
+	}
+	if encoding, _ := provider.Encoding("gpt-4-legacy"); encoding != EncodingP50kBase {
+		t.Errorf("Encoding() = %s, want the override of the built-in prefix", encoding)
+	}
+	if err := provider.SetEncoding("gpt-x", "unknown_base"); err == nil {
+		t.Error("SetEncoding() with an unknown encoding should fail")
+	}
+
+	// Catalog models are counted like their pricing model
+	_ = config.ModelCatalog().Add(tokentracker.ModelEntry{Provider: "openai", Model: "support-bot", PricingModel: "gpt-4o"})
+	if encoding, known := provider.Encoding("support-bot"); encoding != EncodingO200kBase || !known {
+		t.Errorf("Encoding() of a catalog model = %s, %v, want the encoding of its pricing model", encoding, known)
+	}
+}
+
+func TestOpenAIProvider_WarnsOnUnknownModel(t *testing.T) {
+	var buf bytes.Buffer
+	config := tokentracker.NewConfig()
+	config.SetLogger(slog.New(slog.NewTextHandler(&buf, nil)))
+	provider := NewOpenAIProvider(config)
+
+	// The encoding may be unavailable offline; the warning is logged before it is loaded
+	_, _ = provider.getEncoding("my-model")
+	_, _ = provider.getEncoding("my-model")
+	_, _ = provider.getEncoding("gpt-4o")
+
+	if warnings := strings.Count(buf.String(), "no encoding known for model"); warnings != 1 {
+		t.Errorf("logged %d warnings, want one for the unknown model:\n%s", warnings, buf.String())
+	}
+	if !strings.Contains(buf.String(), "model=my-model") {
+		t.Errorf("warning %q does not name the model", buf.String())
+	}
+}
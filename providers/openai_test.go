@@ -1,7 +1,10 @@
 package providers
 
 import (
+	"math"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/TrustSight-io/tokentracker"
 )
@@ -94,6 +97,23 @@ func TestOpenAIProvider_CountTokens(t *testing.T) {
 	}
 }
 
+func TestOpenAIProvider_CountTokensCachedText(t *testing.T) {
+	config := tokentracker.NewConfig()
+	config.SetTokenCache(tokentracker.NewTokenCache(tokentracker.TokenCacheOptions{}))
+	provider := NewOpenAIProvider(config)
+
+	// A cached text is counted without loading its encoding
+	text := "Hello, world!"
+	config.GetTokenCache().Set("openai", "cl100k_base", text, 42)
+	count, err := provider.CountTokens(tokentracker.TokenCountParams{Model: "gpt-4", Text: &text})
+	if err != nil {
+		t.Fatalf("CountTokens() error = %v", err)
+	}
+	if count.InputTokens != 42 {
+		t.Errorf("InputTokens = %d, want the cached 42", count.InputTokens)
+	}
+}
+
 func TestOpenAIProvider_CalculatePrice(t *testing.T) {
 	// Create a new configuration
 	config := tokentracker.NewConfig()
@@ -162,3 +182,325 @@ func TestOpenAIProvider_CalculatePrice(t *testing.T) {
 		})
 	}
 }
+
+func TestOpenAIProvider_CountingAlgorithms(t *testing.T) {
+	config := tokentracker.NewConfig()
+	provider := NewOpenAIProvider(config)
+
+	params := tokentracker.TokenCountParams{
+		Model: "gpt-4",
+		Messages: []tokentracker.Message{
+			{Role: "system", Content: "You are a helpful assistant."},
+			{Role: "user", Content: "Hello!"},
+		},
+	}
+
+	// OpenAI's documented algorithm is the default
+	official, err := provider.CountTokens(params)
+	skipIfEncodingUnavailable(t, err)
+	if official.Algorithm != string(tokentracker.AlgorithmV2) {
+		t.Errorf("Algorithm = %q, want v2", official.Algorithm)
+	}
+
+	config.SetCountingFlag("openai", tokentracker.CountingFlag{})
+	legacy, err := provider.CountTokens(params)
+	if err != nil {
+		t.Fatalf("CountTokens() error = %v", err)
+	}
+	if legacy.Algorithm != string(tokentracker.AlgorithmLegacy) || legacy.InputTokens <= official.InputTokens {
+		t.Errorf("legacy count = %+v, want the JSON encoding's larger count", legacy)
+	}
+
+	var shadows []tokentracker.ShadowCount
+	config.SetShadowReporter(func(s tokentracker.ShadowCount) { shadows = append(shadows, s) })
+	config.SetCountingFlag("openai", tokentracker.CountingFlag{Algorithm: tokentracker.AlgorithmV2, Shadow: true})
+
+	v2, err := provider.CountTokens(params)
+	if err != nil {
+		t.Fatalf("CountTokens() error = %v", err)
+	}
+	// ChatML: 3 per message + role + content, plus 3 reply priming tokens
+	if v2.InputTokens != 19 || v2.Algorithm != string(tokentracker.AlgorithmV2) {
+		t.Errorf("v2 count = %+v, want 19 ChatML tokens", v2)
+	}
+	if len(shadows) != 1 || shadows[0].Shadowed.InputTokens != legacy.InputTokens {
+		t.Errorf("shadows = %+v, want the legacy count as shadow", shadows)
+	}
+
+	// Names add their tokens plus one
+	params.Messages[1].Name = "alice"
+	named, err := provider.CountTokens(params)
+	if err != nil {
+		t.Fatalf("CountTokens() error = %v", err)
+	}
+	if named.InputTokens != v2.InputTokens+2 {
+		t.Errorf("count with name = %d, want %d", named.InputTokens, v2.InputTokens+2)
+	}
+}
+
+func TestOpenAIMessageOverhead(t *testing.T) {
+	tests := []struct {
+		model            string
+		perMessage, name int
+	}{
+		{"gpt-3.5-turbo-0301", 4, -1},
+		{"gpt-3.5-turbo-0613", 3, 1},
+		{"gpt-4", 3, 1},
+		{"gpt-4o", 3, 1},
+	}
+	for _, tt := range tests {
+		perMessage, name := openAIMessageOverhead(tt.model)
+		if perMessage != tt.perMessage || name != tt.name {
+			t.Errorf("openAIMessageOverhead(%s) = %d, %d, want %d, %d", tt.model, perMessage, name, tt.perMessage, tt.name)
+		}
+	}
+}
+
+func TestOpenAIProvider_PromptCaching(t *testing.T) {
+	config := tokentracker.NewConfig()
+	config.SetModelPricing("openai", "gpt-4o", tokentracker.ModelPricing{
+		InputPricePerToken:       0.0000025,
+		OutputPricePerToken:      0.00001,
+		CachedInputPricePerToken: 0.00000125,
+		Currency:                 "USD",
+	})
+	provider := NewOpenAIProvider(config)
+
+	count, err := provider.ExtractTokenUsageFromResponse(map[string]interface{}{
+		"usage": map[string]interface{}{
+			"prompt_tokens":         float64(2000),
+			"completion_tokens":     float64(100),
+			"total_tokens":          float64(2100),
+			"prompt_tokens_details": map[string]interface{}{"cached_tokens": float64(1920)},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ExtractTokenUsageFromResponse() error = %v", err)
+	}
+	if count.CachedInputTokens != 1920 {
+		t.Errorf("CachedInputTokens = %d, want 1920", count.CachedInputTokens)
+	}
+
+	price, err := provider.CalculateUsagePrice("gpt-4o", tokentracker.BillableUsage{
+		InputTokens:       count.InputTokens,
+		OutputTokens:      count.ResponseTokens,
+		CachedInputTokens: count.CachedInputTokens,
+	})
+	if err != nil {
+		t.Fatalf("CalculateUsagePrice() error = %v", err)
+	}
+	if !approxEqual(price.InputCost, 80*0.0000025+1920*0.00000125) {
+		t.Errorf("InputCost = %v, want cache hits at the cached price", price.InputCost)
+	}
+}
+
+func TestOpenAIProvider_ReasoningTokens(t *testing.T) {
+	config := tokentracker.NewConfig()
+	provider := NewOpenAIProvider(config)
+	if err := provider.UpdatePricing(); err != nil {
+		t.Fatalf("UpdatePricing() error = %v", err)
+	}
+
+	count, err := provider.ExtractTokenUsageFromResponse(map[string]interface{}{
+		"model": "o1-2024-12-17",
+		"usage": map[string]interface{}{
+			"prompt_tokens":             float64(50),
+			"completion_tokens":         float64(1200),
+			"total_tokens":              float64(1250),
+			"completion_tokens_details": map[string]interface{}{"reasoning_tokens": float64(1000)},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ExtractTokenUsageFromResponse() error = %v", err)
+	}
+	if count.ReasoningTokens != 1000 || count.ResponseTokens != 1200 {
+		t.Errorf("count = %+v, want 1000 reasoning tokens within the 1200 response tokens", count)
+	}
+
+	price, err := provider.CalculateUsagePrice("o1-2024-12-17", tokentracker.BillableUsage{
+		InputTokens:     count.InputTokens,
+		OutputTokens:    count.ResponseTokens,
+		ReasoningTokens: count.ReasoningTokens,
+	})
+	if err != nil {
+		t.Fatalf("CalculateUsagePrice() error = %v", err)
+	}
+	if !approxEqual(price.OutputCost, 1200*0.00006) || !approxEqual(price.ReasoningCost, 1000*0.00006) {
+		t.Errorf("price = %+v, want reasoning billed as output and broken out", price)
+	}
+}
+
+func TestOpenAIProvider_AudioTokens(t *testing.T) {
+	config := tokentracker.NewConfig()
+	provider := NewOpenAIProvider(config)
+	if err := provider.UpdatePricing(); err != nil {
+		t.Fatalf("UpdatePricing() error = %v", err)
+	}
+
+	count, err := provider.ExtractTokenUsageFromResponse(map[string]interface{}{
+		"usage": map[string]interface{}{
+			"prompt_tokens":         float64(120),
+			"completion_tokens":     float64(20),
+			"total_tokens":          float64(140),
+			"prompt_tokens_details": map[string]interface{}{"audio_tokens": float64(100)},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ExtractTokenUsageFromResponse() error = %v", err)
+	}
+	if count.AudioTokens != 100 {
+		t.Errorf("AudioTokens = %d, want 100", count.AudioTokens)
+	}
+
+	price, err := provider.CalculateUsagePrice("gpt-4o-audio-preview-2024-10-01", tokentracker.BillableUsage{
+		InputTokens:      count.InputTokens,
+		OutputTokens:     count.ResponseTokens,
+		AudioInputTokens: count.AudioTokens,
+	})
+	if err != nil {
+		t.Fatalf("CalculateUsagePrice() error = %v", err)
+	}
+	if !approxEqual(price.InputCost, 20*0.0000025+100*0.0001) {
+		t.Errorf("InputCost = %v, want audio tokens at the audio price", price.InputCost)
+	}
+
+	withAudio, err := provider.CountTokens(tokentracker.TokenCountParams{
+		Model: "gpt-4o-audio-preview",
+		Messages: []tokentracker.Message{{Role: "user", Content: []tokentracker.ContentPart{
+			{Type: tokentracker.ContentTypeText, Text: "Transcribe this."},
+			{Type: tokentracker.ContentTypeAudio, Duration: 30 * time.Second},
+		}}},
+	})
+	skipIfEncodingUnavailable(t, err)
+	if withAudio.AudioTokens != 300 || withAudio.InputTokens <= 300 {
+		t.Errorf("CountTokens() = %+v, want 300 audio tokens included in the input", withAudio)
+	}
+}
+
+func TestOpenAIProvider_Embeddings(t *testing.T) {
+	provider := NewOpenAIProvider(tokentracker.NewConfig())
+
+	// Embedding responses report no completion tokens
+	count, err := provider.ExtractTokenUsageFromResponse(map[string]interface{}{
+		"object": "list",
+		"model":  "text-embedding-3-small",
+		"usage":  map[string]interface{}{"prompt_tokens": float64(8), "total_tokens": float64(8)},
+	})
+	if err != nil {
+		t.Fatalf("ExtractTokenUsageFromResponse() error = %v", err)
+	}
+	if count.InputTokens != 8 || count.ResponseTokens != 0 || count.TotalTokens != 8 {
+		t.Errorf("count = %+v", count)
+	}
+
+	for _, model := range []string{"text-embedding-3-small", "text-embedding-3-large", "text-embedding-ada-002"} {
+		if !provider.SupportsModel(model) {
+			t.Errorf("SupportsModel(%s) = false", model)
+		}
+	}
+
+	price, err := provider.CalculatePrice("text-embedding-3-small", 1000000, 0)
+	if err != nil {
+		t.Fatalf("CalculatePrice() error = %v", err)
+	}
+	if !approxEqual(price.TotalCost, 0.02) {
+		t.Errorf("TotalCost = %v, want 0.02", price.TotalCost)
+	}
+}
+
+func TestOpenAIProvider_GetModelInfo(t *testing.T) {
+	provider := NewOpenAIProvider(tokentracker.NewConfig())
+
+	tests := []struct {
+		model         string
+		contextWindow int
+		pricingRef    string
+	}{
+		{"gpt-4", 8192, ""},
+		{"gpt-4o", 128000, ""},
+		{"gpt-4o-2024-05-13", 128000, "gpt-4o"},
+		{"text-embedding-3-small", 8191, ""},
+	}
+	for _, tt := range tests {
+		info, err := provider.GetModelInfo(tt.model)
+		if err != nil {
+			t.Fatalf("GetModelInfo(%s) error = %v", tt.model, err)
+		}
+		if info.ContextWindow != tt.contextWindow || info.Provider != "openai" || info.PricingRef != tt.pricingRef {
+			t.Errorf("GetModelInfo(%s) = %+v, want a context window of %d priced like %q", tt.model, info, tt.contextWindow, tt.pricingRef)
+		}
+	}
+
+	info, _ := provider.GetModelInfo("gpt-4-32k")
+	if !info.Deprecated || info.TrainingCutoff.IsZero() {
+		t.Errorf("GetModelInfo(gpt-4-32k) = %+v, want a deprecated model with a training cutoff", info)
+	}
+
+	info, _ = provider.GetModelInfo("text-embedding-3-small")
+	if strings.Join(info.Capabilities, ",") != "text,embeddings" {
+		t.Errorf("GetModelInfo(text-embedding-3-small) capabilities = %v, want an embedding model", info.Capabilities)
+	}
+}
+
+func TestOpenAIProvider_TokenizeText(t *testing.T) {
+	provider := NewOpenAIProvider(tokentracker.NewConfig())
+
+	text := "Hello, wörld! Tokens 🙂 split here."
+	pieces, err := provider.TokenizeText("gpt-4", text)
+	skipIfEncodingUnavailable(t, err)
+	if strings.Join(pieces, "") != text {
+		t.Errorf("TokenizeText() pieces = %q, want them to concatenate to the text", pieces)
+	}
+
+	count, _ := provider.CountTokens(tokentracker.TokenCountParams{Model: "gpt-4", Text: &text})
+	if len(pieces) != count.InputTokens {
+		t.Errorf("TokenizeText() = %d pieces, want %d tokens", len(pieces), count.InputTokens)
+	}
+}
+
+func BenchmarkOpenAIProvider_CountTokensCached(b *testing.B) {
+	provider := NewOpenAIProvider(tokentracker.NewConfig())
+	text := strings.Repeat("The quick brown fox jumps over the lazy dog. ", 200)
+	params := tokentracker.TokenCountParams{Model: "gpt-4", Text: &text}
+	_, err := provider.CountTokens(params)
+	skipIfEncodingUnavailable(b, err)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = provider.CountTokens(params)
+	}
+}
+
+func TestOpenAIProvider_FineTunedModels(t *testing.T) {
+	config := tokentracker.NewConfig()
+	provider := NewOpenAIProvider(config)
+	if err := provider.UpdatePricing(); err != nil {
+		t.Fatalf("UpdatePricing() error = %v", err)
+	}
+	model := "ft:gpt-4o-mini-2024-07-18:acme:support:9abcDEF"
+
+	if !provider.SupportsModel(model) {
+		t.Errorf("SupportsModel(%q) = false", model)
+	}
+	if encoding, known := provider.Encoding(model); !known || encoding != "o200k_base" {
+		t.Errorf("Encoding(%q) = %q, %v, want the base model's o200k_base", model, encoding, known)
+	}
+
+	// 1M input and output tokens at the fine-tuned rates of $0.30 and $1.20
+	price, err := provider.CalculatePrice(model, 1000000, 1000000)
+	if err != nil {
+		t.Fatalf("CalculatePrice() error = %v", err)
+	}
+	if math.Abs(price.TotalCost-1.5) > 1e-9 {
+		t.Errorf("TotalCost = %v, want 1.5", price.TotalCost)
+	}
+}
+
+func TestOpenAIProvider_CountsTemplatesAsRenderedPrompts(t *testing.T) {
+	assertTemplateCountMatchesRendered(t, NewOpenAIProvider(tokentracker.NewConfig()), "gpt-4o")
+}
+
+func TestOpenAIProvider_CountsSystemInstructionWithText(t *testing.T) {
+	assertCountsSystemInstructionWithText(t, NewOpenAIProvider(tokentracker.NewConfig()), "gpt-4o")
+}
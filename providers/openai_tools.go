@@ -0,0 +1,345 @@
+package providers
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/TrustSight-io/tokentracker"
+	"github.com/pkoukk/tiktoken-go"
+)
+
+// OpenAI renders function definitions into the system prompt as a TypeScript-like
+// namespace, e.g.
+//
+//	namespace functions {
+//
+//	// Get the weather
+//	type get_weather = (_: {
+//	// The city
+//	location: string,
+//	unit?: "celsius" | "fahrenheit",
+//	}) => any;
+//
+//	} // namespace functions
+//
+// and counts the rendering plus a fixed overhead, rather than the JSON schema.
+const (
+	// openAIFunctionsOverhead is the tokens wrapping the rendered definitions
+	openAIFunctionsOverhead = 9
+
+	// openAIFunctionsSystemDiscount is the tokens saved when the definitions share
+	// the prompt's system message instead of getting one of their own
+	openAIFunctionsSystemDiscount = 4
+
+	// openAIForcedFunctionOverhead is the tokens of a tool choice forcing a function,
+	// besides its name
+	openAIForcedFunctionOverhead = 4
+)
+
+// schemaObject is a decoded JSON object that keeps the order of its keys, which
+// OpenAI renders the parameters of functions in
+type schemaObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// object returns the value of key if it is an object
+func (o *schemaObject) object(key string) (*schemaObject, bool) {
+	value, ok := o.values[key].(*schemaObject)
+	return value, ok
+}
+
+// string returns the value of key if it is a string
+func (o *schemaObject) string(key string) string {
+	value, _ := o.values[key].(string)
+	return value
+}
+
+// decodeSchema decodes JSON keeping the order of object keys
+func decodeSchema(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch token {
+	case json.Delim('{'):
+		object := &schemaObject{values: make(map[string]interface{})}
+		for decoder.More() {
+			keyToken, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			key, _ := keyToken.(string)
+			value, err := decodeSchema(decoder)
+			if err != nil {
+				return nil, err
+			}
+			if _, exists := object.values[key]; !exists {
+				object.keys = append(object.keys, key)
+			}
+			object.values[key] = value
+		}
+		_, err := decoder.Token()
+		return object, err
+	case json.Delim('['):
+		var array []interface{}
+		for decoder.More() {
+			value, err := decodeSchema(decoder)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		_, err := decoder.Token()
+		return array, err
+	}
+	return token, nil
+}
+
+// openAIFunction returns the definition of a function tool, keeping the order of its
+// parameters. Tools of other types, e.g. file search, are not functions.
+func openAIFunction(tool tokentracker.Tool) (*schemaObject, bool, error) {
+	if (tool.Type != "" && tool.Type != "function") || tool.Function == nil {
+		return nil, false, nil
+	}
+	data, err := json.Marshal(tool.Function)
+	if err != nil {
+		return nil, false, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	value, err := decodeSchema(decoder)
+	if err != nil {
+		return nil, false, err
+	}
+	function, ok := value.(*schemaObject)
+	return function, ok && function.string("name") != "", nil
+}
+
+// hasOpenAIFunctions reports whether tools include function definitions
+func hasOpenAIFunctions(tools []tokentracker.Tool) bool {
+	for _, tool := range tools {
+		if _, ok, _ := openAIFunction(tool); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// formatOpenAIFunctions renders function definitions the way OpenAI does
+func formatOpenAIFunctions(functions []*schemaObject) string {
+	lines := []string{"namespace functions {", ""}
+	for _, function := range functions {
+		if description := function.string("description"); description != "" {
+			lines = append(lines, "// "+description)
+		}
+		name := function.string("name")
+		parameters, _ := function.object("parameters")
+		if properties, ok := parametersProperties(parameters); ok && len(properties.keys) > 0 {
+			lines = append(lines, "type "+name+" = (_: {", formatSchemaProperties(parameters, 0), "}) => any;")
+		} else {
+			lines = append(lines, "type "+name+" = () => any;")
+		}
+		lines = append(lines, "")
+	}
+	lines = append(lines, "} // namespace functions")
+	return strings.Join(lines, "\n")
+}
+
+// parametersProperties returns the properties of an object schema
+func parametersProperties(schema *schemaObject) (*schemaObject, bool) {
+	if schema == nil {
+		return nil, false
+	}
+	return schema.object("properties")
+}
+
+// formatSchemaProperties renders the properties of an object schema, marking optional
+// ones with "?". Descriptions are rendered for the top two levels only.
+func formatSchemaProperties(schema *schemaObject, indent int) string {
+	properties, _ := parametersProperties(schema)
+	if properties == nil {
+		return ""
+	}
+	required := make(map[string]bool)
+	if names, ok := schema.values["required"].([]interface{}); ok {
+		for _, name := range names {
+			if name, ok := name.(string); ok {
+				required[name] = true
+			}
+		}
+	}
+
+	padding := strings.Repeat(" ", indent)
+	var lines []string
+	for _, name := range properties.keys {
+		property, _ := properties.values[name].(*schemaObject)
+		if property == nil {
+			property = &schemaObject{values: make(map[string]interface{})}
+		}
+		if description := property.string("description"); description != "" && indent < 2 {
+			lines = append(lines, padding+"// "+description)
+		}
+		optional := "?"
+		if required[name] {
+			optional = ""
+		}
+		lines = append(lines, padding+name+optional+": "+formatSchemaType(property, indent)+",")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatSchemaType renders the type of a property in TypeScript notation. Unions of
+// anyOf and oneOf alternatives and of type lists are joined with "|"; types that
+// cannot be rendered, e.g. $ref references, are "any".
+func formatSchemaType(property *schemaObject, indent int) string {
+	for _, key := range []string{"anyOf", "oneOf"} {
+		if alternatives, ok := property.values[key].([]interface{}); ok && len(alternatives) > 0 {
+			types := make([]string, len(alternatives))
+			for i, alternative := range alternatives {
+				schema, _ := alternative.(*schemaObject)
+				if schema == nil {
+					schema = &schemaObject{values: make(map[string]interface{})}
+				}
+				types[i] = formatSchemaType(schema, indent)
+			}
+			return strings.Join(types, " | ")
+		}
+	}
+	if names, ok := property.values["type"].([]interface{}); ok && len(names) > 0 {
+		types := make([]string, len(names))
+		for i, name := range names {
+			single := &schemaObject{keys: property.keys, values: make(map[string]interface{}, len(property.values))}
+			for key, value := range property.values {
+				single.values[key] = value
+			}
+			single.values["type"] = name
+			types[i] = formatSchemaType(single, indent)
+		}
+		return strings.Join(types, " | ")
+	}
+
+	switch property.string("type") {
+	case "string":
+		if values, ok := property.values["enum"].([]interface{}); ok {
+			return joinEnum(values, true)
+		}
+		return "string"
+	case "number", "integer":
+		if values, ok := property.values["enum"].([]interface{}); ok {
+			return joinEnum(values, false)
+		}
+		return "number"
+	case "boolean":
+		return "boolean"
+	case "null":
+		return "null"
+	case "object":
+		return "{\n" + formatSchemaProperties(property, indent+2) + "\n}"
+	case "array":
+		if items, ok := property.object("items"); ok {
+			itemType := formatSchemaType(items, indent)
+			if strings.Contains(itemType, " | ") {
+				itemType = "(" + itemType + ")"
+			}
+			return itemType + "[]"
+		}
+		return "any[]"
+	}
+	return "any"
+}
+
+// joinEnum renders enum values as a union type
+func joinEnum(values []interface{}, quote bool) string {
+	rendered := make([]string, len(values))
+	for i, value := range values {
+		var text string
+		switch v := value.(type) {
+		case string:
+			text = v
+		case json.Number:
+			text = v.String()
+		default:
+			data, _ := json.Marshal(v)
+			text = string(data)
+		}
+		if quote {
+			text = `"` + text + `"`
+		}
+		rendered[i] = text
+	}
+	return strings.Join(rendered, " | ")
+}
+
+// hasSystemMessage reports whether a conversation has a system message
+func hasSystemMessage(messages []tokentracker.Message) bool {
+	for _, message := range messages {
+		if message.Role == "system" {
+			return true
+		}
+	}
+	return false
+}
+
+// countToolTokens counts tool definitions and the tool choice the way OpenAI does:
+// function definitions in their rendered form, other tools as JSON. Definitions share
+// the system message of conversations that have one.
+func (p *OpenAIProvider) countToolTokens(messages []tokentracker.Message, tools []tokentracker.Tool, toolChoice *tokentracker.ToolChoice, encoding *tiktoken.Tiktoken) (int, error) {
+	tokens := 0
+	var functions []*schemaObject
+	var others []tokentracker.Tool
+	for _, tool := range tools {
+		function, ok, err := openAIFunction(tool)
+		if err != nil {
+			return 0, tokentracker.NewError(tokentracker.ErrTokenizationFailed, "failed to marshal tools", err)
+		}
+		if ok {
+			functions = append(functions, function)
+		} else {
+			others = append(others, tool)
+		}
+	}
+
+	if len(functions) > 0 {
+		tokens += len(encoding.Encode(formatOpenAIFunctions(functions), nil, nil)) + openAIFunctionsOverhead
+		if hasSystemMessage(messages) {
+			tokens -= openAIFunctionsSystemDiscount
+		}
+	}
+	if len(others) > 0 {
+		othersJSON, err := json.Marshal(others)
+		if err != nil {
+			return 0, tokentracker.NewError(tokentracker.ErrTokenizationFailed, "failed to marshal tools", err)
+		}
+		tokens += len(encoding.Encode(string(othersJSON), nil, nil))
+	}
+
+	if toolChoice != nil {
+		tokens += openAIToolChoiceTokens(*toolChoice, encoding)
+	}
+	return tokens, nil
+}
+
+// openAIToolChoiceTokens counts a tool choice: "auto" and "required" are free, "none"
+// is a token, and forcing a function costs its name plus a fixed overhead
+func openAIToolChoiceTokens(toolChoice tokentracker.ToolChoice, encoding *tiktoken.Tiktoken) int {
+	if toolChoice.Type == "none" {
+		return 1
+	}
+	if toolChoice.Function == nil {
+		return 0
+	}
+
+	name := ""
+	data, err := json.Marshal(toolChoice.Function)
+	if err == nil {
+		var function struct {
+			Name string `json:"name"`
+		}
+		if json.Unmarshal(data, &function) == nil {
+			name = function.Name
+		}
+	}
+	return len(encoding.Encode(name, nil, nil)) + openAIForcedFunctionOverhead
+}
@@ -0,0 +1,161 @@
+package providers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/TrustSight-io/tokentracker"
+)
+
+func TestOpenAIProvider_CountsFunctionDefinitions(t *testing.T) {
+	provider := NewOpenAIProvider(tokentracker.NewConfig())
+
+	tests := []struct {
+		name     string
+		messages []tokentracker.Message
+		tools    []tokentracker.Tool
+		choice   *tokentracker.ToolChoice
+		want     int
+	}{
+		{
+			name:  "function without parameters",
+			tools: []tokentracker.Tool{{Type: "function", Function: map[string]interface{}{"name": "foo"}}},
+			want:  31,
+		},
+		{
+			name:  "function with description",
+			tools: []tokentracker.Tool{{Type: "function", Function: map[string]interface{}{"name": "foo", "description": "Do a foo"}}},
+			want:  36,
+		},
+		{
+			name: "function with a parameter",
+			tools: []tokentracker.Tool{{Type: "function", Function: map[string]interface{}{
+				"name":        "bing_bong",
+				"description": "Do a bing bong",
+				"parameters": map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{"foo": map[string]interface{}{"type": "string"}},
+				},
+			}}},
+			want: 49,
+		},
+		{
+			name: "function with described parameters",
+			// Raw JSON keeps the order of the parameters
+			tools: []tokentracker.Tool{{Type: "function", Function: json.RawMessage(`{
+				"name": "bing_bong",
+				"description": "Do a bing bong",
+				"parameters": {
+					"type": "object",
+					"properties": {
+						"foo": {"type": "string"},
+						"bar": {"type": "number", "description": "A number"}
+					}
+				}
+			}`)}},
+			want: 57,
+		},
+		{
+			name:   "forced function",
+			tools:  []tokentracker.Tool{{Type: "function", Function: map[string]interface{}{"name": "foo"}}},
+			choice: &tokentracker.ToolChoice{Type: "function", Function: map[string]interface{}{"name": "foo"}},
+			want:   36,
+		},
+		{
+			name:   "no tool calls",
+			tools:  []tokentracker.Tool{{Type: "function", Function: map[string]interface{}{"name": "foo"}}},
+			choice: &tokentracker.ToolChoice{Type: "none"},
+			want:   32,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages := append(tt.messages, tokentracker.Message{Role: "user", Content: "hello"})
+			count, err := provider.CountTokens(tokentracker.TokenCountParams{
+				Model:      "gpt-3.5-turbo",
+				Messages:   messages,
+				Tools:      tt.tools,
+				ToolChoice: tt.choice,
+			})
+			skipIfEncodingUnavailable(t, err)
+			if count.InputTokens != tt.want {
+				t.Errorf("InputTokens = %d, want %d", count.InputTokens, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatOpenAIFunctions(t *testing.T) {
+	function, ok, err := openAIFunction(tokentracker.Tool{Type: "function", Function: map[string]interface{}{
+		"name":        "get_weather",
+		"description": "Get the weather",
+		"parameters": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"location": map[string]interface{}{"type": "string", "description": "The city"},
+				"days":     map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "integer"}},
+			},
+			"required": []string{"location"},
+		},
+	}})
+	if err != nil || !ok {
+		t.Fatalf("openAIFunction() = %v, %v", ok, err)
+	}
+
+	// Maps marshal with sorted keys
+	want := "namespace functions {\n\n// Get the weather\ntype get_weather = (_: {\ndays?: number[],\n// The city\nlocation: string,\n}) => any;\n\n} // namespace functions"
+	if got := formatOpenAIFunctions([]*schemaObject{function}); got != want {
+		t.Errorf("formatOpenAIFunctions() =\n%s\nwant\n%s", got, want)
+	}
+
+	if _, ok, _ := openAIFunction(tokentracker.Tool{Type: "file_search"}); ok {
+		t.Error("openAIFunction() accepted a tool that is not a function")
+	}
+}
+
+func TestFormatOpenAIFunctions_Types(t *testing.T) {
+	tests := []struct {
+		name     string
+		function string
+		want     string
+	}{
+		{
+			name:     "no parameters",
+			function: `{"name": "foo"}`,
+			want:     "type foo = () => any;",
+		},
+		{
+			name:     "enums",
+			function: `{"name": "set", "parameters": {"type": "object", "properties": {"unit": {"type": "string", "enum": ["celsius", "fahrenheit"]}, "level": {"type": "integer", "enum": [1, 2]}}, "required": ["unit"]}}`,
+			want:     "type set = (_: {\nunit: \"celsius\" | \"fahrenheit\",\nlevel?: 1 | 2,\n}) => any;",
+		},
+		{
+			name:     "nested object",
+			function: `{"name": "book", "parameters": {"type": "object", "properties": {"guest": {"type": "object", "description": "The guest", "properties": {"name": {"type": "string", "description": "Full name"}}}}}}`,
+			want:     "type book = (_: {\n// The guest\nguest?: {\n  name?: string,\n},\n}) => any;",
+		},
+		{
+			name:     "anyOf and type lists",
+			function: `{"name": "find", "parameters": {"type": "object", "properties": {"id": {"anyOf": [{"type": "string"}, {"type": "integer"}]}, "note": {"type": ["string", "null"]}, "tags": {"type": "array", "items": {"oneOf": [{"type": "string"}, {"type": "boolean"}]}}}}}`,
+			want:     "type find = (_: {\nid?: string | number,\nnote?: string | null,\ntags?: (string | boolean)[],\n}) => any;",
+		},
+		{
+			name:     "references",
+			function: `{"name": "ship", "parameters": {"type": "object", "properties": {"address": {"$ref": "#/$defs/address"}}}}`,
+			want:     "type ship = (_: {\naddress?: any,\n}) => any;",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			function, ok, err := openAIFunction(tokentracker.Tool{Type: "function", Function: json.RawMessage(tt.function)})
+			if err != nil || !ok {
+				t.Fatalf("openAIFunction() = %v, %v", ok, err)
+			}
+			want := "namespace functions {\n\n" + tt.want + "\n\n} // namespace functions"
+			if got := formatOpenAIFunctions([]*schemaObject{function}); got != want {
+				t.Errorf("formatOpenAIFunctions() =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
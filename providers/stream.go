@@ -0,0 +1,142 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/TrustSight-io/tokentracker"
+)
+
+// OpenAIStreamTracker accumulates usage from OpenAI chat completion chunks. OpenAI only
+// reports usage in the final chunk when the request sets stream_options.include_usage.
+type OpenAIStreamTracker struct {
+	*tokentracker.StreamTracker
+}
+
+// NewOpenAIStreamTracker creates a stream tracker for an OpenAI call
+func NewOpenAIStreamTracker(ctx context.Context, tracker *tokentracker.DefaultTokenTracker, callParams tokentracker.CallParams) *OpenAIStreamTracker {
+	return &OpenAIStreamTracker{tokentracker.NewStreamTracker(ctx, tracker, callParams)}
+}
+
+// AddChunk consumes a streamed chunk, either decoded or as the JSON data of an event
+func (s *OpenAIStreamTracker) AddChunk(chunk interface{}) error {
+	data, err := decodeChunk(chunk)
+	if err != nil || data == nil {
+		return err
+	}
+
+	if model, ok := data["model"].(string); ok {
+		s.SetModel(model)
+	}
+	if usage, ok := data["usage"].(map[string]interface{}); ok {
+		if promptTokens, ok := usage["prompt_tokens"].(float64); ok {
+			s.ReportInputTokens(int(promptTokens))
+		}
+		if completionTokens, ok := usage["completion_tokens"].(float64); ok {
+			s.ReportOutputTokens(int(completionTokens))
+		}
+	}
+	return nil
+}
+
+// ClaudeStreamTracker accumulates usage from Anthropic message stream events. The
+// input tokens arrive with message_start and cumulative output tokens with message_delta.
+type ClaudeStreamTracker struct {
+	*tokentracker.StreamTracker
+}
+
+// NewClaudeStreamTracker creates a stream tracker for an Anthropic call
+func NewClaudeStreamTracker(ctx context.Context, tracker *tokentracker.DefaultTokenTracker, callParams tokentracker.CallParams) *ClaudeStreamTracker {
+	return &ClaudeStreamTracker{tokentracker.NewStreamTracker(ctx, tracker, callParams)}
+}
+
+// AddChunk consumes a streamed event, either decoded or as the JSON data of the event
+func (s *ClaudeStreamTracker) AddChunk(chunk interface{}) error {
+	data, err := decodeChunk(chunk)
+	if err != nil || data == nil {
+		return err
+	}
+
+	var usage map[string]interface{}
+	switch data["type"] {
+	case "message_start":
+		message, _ := data["message"].(map[string]interface{})
+		if model, ok := message["model"].(string); ok {
+			s.SetModel(model)
+		}
+		usage, _ = message["usage"].(map[string]interface{})
+	case "message_delta":
+		usage, _ = data["usage"].(map[string]interface{})
+	default:
+		return nil
+	}
+
+	if inputTokens, ok := usage["input_tokens"].(float64); ok {
+		s.ReportInputTokens(int(inputTokens))
+	}
+	if outputTokens, ok := usage["output_tokens"].(float64); ok {
+		s.ReportOutputTokens(int(outputTokens))
+	}
+	return nil
+}
+
+// GeminiStreamTracker accumulates usage from Gemini streamGenerateContent responses.
+// Each response carries the usage so far, so the last one wins.
+type GeminiStreamTracker struct {
+	*tokentracker.StreamTracker
+}
+
+// NewGeminiStreamTracker creates a stream tracker for a Gemini call
+func NewGeminiStreamTracker(ctx context.Context, tracker *tokentracker.DefaultTokenTracker, callParams tokentracker.CallParams) *GeminiStreamTracker {
+	return &GeminiStreamTracker{tokentracker.NewStreamTracker(ctx, tracker, callParams)}
+}
+
+// AddChunk consumes a streamed response, either decoded or as its JSON
+func (s *GeminiStreamTracker) AddChunk(chunk interface{}) error {
+	data, err := decodeChunk(chunk)
+	if err != nil || data == nil {
+		return err
+	}
+
+	if model, ok := data["modelVersion"].(string); ok {
+		s.SetModel(model)
+	}
+	if usageMetadata, ok := data["usageMetadata"].(map[string]interface{}); ok {
+		if promptTokens, ok := usageMetadata["promptTokenCount"].(float64); ok {
+			s.ReportInputTokens(int(promptTokens))
+		}
+		if candidatesTokens, ok := usageMetadata["candidatesTokenCount"].(float64); ok {
+			s.ReportOutputTokens(int(candidatesTokens))
+		}
+	}
+	return nil
+}
+
+// decodeChunk returns a chunk as a JSON object. Raw chunks may carry the "data:" prefix
+// of a server-sent event; the "[DONE]" sentinel and empty lines decode to nil.
+func decodeChunk(chunk interface{}) (map[string]interface{}, error) {
+	var raw string
+	switch c := chunk.(type) {
+	case map[string]interface{}:
+		return c, nil
+	case []byte:
+		raw = string(c)
+	case string:
+		raw = c
+	default:
+		return nil, tokentracker.NewError(tokentracker.ErrInvalidParams, fmt.Sprintf("unsupported chunk type: %T", chunk), nil)
+	}
+
+	raw = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(raw), "data:"))
+	if raw == "" || raw == "[DONE]" {
+		return nil, nil
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &data); err != nil {
+		return nil, tokentracker.NewError(tokentracker.ErrInvalidParams, "invalid stream chunk", err)
+	}
+	return data, nil
+}
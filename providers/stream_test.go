@@ -0,0 +1,127 @@
+package providers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker"
+)
+
+func newStreamTestTracker() (*tokentracker.DefaultTokenTracker, *tokentracker.MemoryUsageStore) {
+	config := tokentracker.NewConfig()
+	store := tokentracker.NewMemoryUsageStore()
+	tracker := tokentracker.NewTokenTracker(config, tokentracker.WithUsageStore(store))
+	tracker.RegisterProvider(NewOpenAIProvider(config))
+	tracker.RegisterProvider(NewClaudeProvider(config))
+	tracker.RegisterProvider(NewGeminiProvider(config))
+	return tracker, store
+}
+
+func TestOpenAIStreamTracker(t *testing.T) {
+	tracker, store := newStreamTestTracker()
+	stream := NewOpenAIStreamTracker(context.Background(), tracker, tokentracker.CallParams{StartTime: time.Now()})
+
+	chunks := []interface{}{
+		`data: {"model":"gpt-4","choices":[{"delta":{"content":"Hel"}}],"usage":null}`,
+		[]byte(`{"model":"gpt-4","choices":[{"delta":{"content":"lo"}}],"usage":null}`),
+		map[string]interface{}{"model": "gpt-4", "choices": []interface{}{}, "usage": map[string]interface{}{
+			"prompt_tokens": float64(12), "completion_tokens": float64(2), "total_tokens": float64(14),
+		}},
+		"data: [DONE]",
+	}
+	for _, chunk := range chunks {
+		if err := stream.AddChunk(chunk); err != nil {
+			t.Fatalf("AddChunk(%v) error = %v", chunk, err)
+		}
+	}
+
+	metrics, err := stream.Close()
+	if err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if metrics.Model != "gpt-4" || metrics.TokenCount.InputTokens != 12 || metrics.TokenCount.ResponseTokens != 2 {
+		t.Errorf("metrics = %+v, want the streamed usage of gpt-4", metrics)
+	}
+	if !approxEqual(metrics.Price.TotalCost, 12*0.00003+2*0.00006) {
+		t.Errorf("TotalCost = %v", metrics.Price.TotalCost)
+	}
+
+	// Closing again does not track the call twice
+	if _, err := stream.Close(); err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+	if records, _ := store.Query(tokentracker.UsageFilter{}); len(records) != 1 {
+		t.Errorf("tracked %d calls, want 1", len(records))
+	}
+
+	if err := stream.AddChunk("data: {not json"); err == nil {
+		t.Error("AddChunk() with invalid JSON should fail")
+	}
+	if err := stream.AddChunk(42); err == nil {
+		t.Error("AddChunk() with an unsupported chunk type should fail")
+	}
+}
+
+func TestOpenAIStreamTracker_NoUsage(t *testing.T) {
+	tracker, _ := newStreamTestTracker()
+	stream := NewOpenAIStreamTracker(context.Background(), tracker, tokentracker.CallParams{Model: "gpt-4"})
+	_ = stream.AddChunk(`{"model":"gpt-4","choices":[{"delta":{"content":"Hi"}}]}`)
+
+	if _, err := stream.Close(); err == nil {
+		t.Error("Close() of a stream without a usage chunk should fail")
+	}
+}
+
+func TestClaudeStreamTracker(t *testing.T) {
+	tracker, _ := newStreamTestTracker()
+	stream := NewClaudeStreamTracker(context.Background(), tracker, tokentracker.CallParams{StartTime: time.Now()})
+
+	events := []string{
+		`{"type":"message_start","message":{"id":"msg_1","model":"claude-3-haiku","usage":{"input_tokens":25,"output_tokens":1}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`,
+		`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":15}}`,
+		`{"type":"message_stop"}`,
+	}
+	for _, event := range events {
+		if err := stream.AddChunk(event); err != nil {
+			t.Fatalf("AddChunk(%s) error = %v", event, err)
+		}
+	}
+
+	if usage := stream.Usage(); usage.InputTokens != 25 || usage.ResponseTokens != 15 {
+		t.Errorf("Usage() = %+v, want 25 input and 15 output tokens", usage)
+	}
+
+	metrics, err := stream.Close()
+	if err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if metrics.Model != "claude-3-haiku" || metrics.Provider != "anthropic" || metrics.TokenCount.TotalTokens != 40 {
+		t.Errorf("metrics = %+v, want 40 claude-3-haiku tokens", metrics)
+	}
+}
+
+func TestGeminiStreamTracker(t *testing.T) {
+	tracker, _ := newStreamTestTracker()
+	stream := NewGeminiStreamTracker(context.Background(), tracker, tokentracker.CallParams{Model: "gemini-pro", StartTime: time.Now()})
+
+	chunks := []string{
+		`{"candidates":[{"content":{"parts":[{"text":"Hel"}]}}],"usageMetadata":{"promptTokenCount":8,"candidatesTokenCount":1,"totalTokenCount":9}}`,
+		`{"candidates":[{"content":{"parts":[{"text":"lo"}]}}],"usageMetadata":{"promptTokenCount":8,"candidatesTokenCount":6,"totalTokenCount":14}}`,
+	}
+	for _, chunk := range chunks {
+		if err := stream.AddChunk(chunk); err != nil {
+			t.Fatalf("AddChunk() error = %v", err)
+		}
+	}
+
+	metrics, err := stream.Close()
+	if err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if metrics.Provider != "gemini" || metrics.TokenCount.InputTokens != 8 || metrics.TokenCount.ResponseTokens != 6 {
+		t.Errorf("metrics = %+v, want the last reported usage", metrics)
+	}
+}
@@ -1,7 +1,11 @@
 package providers
 
 import (
+	"errors"
+	"net/url"
 	"testing"
+
+	"github.com/TrustSight-io/tokentracker"
 )
 
 func TestStringPtr(t *testing.T) {
@@ -25,3 +29,76 @@ func TestStringPtr(t *testing.T) {
 		t.Errorf("StringPtr() = %q, want %q", *ptr, testStr)
 	}
 }
+
+// skipIfEncodingUnavailable skips a test when tiktoken could not download an
+// encoding, and fails it on any other error
+func skipIfEncodingUnavailable(t testing.TB, err error) {
+	t.Helper()
+	if err == nil {
+		return
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		t.Skipf("tiktoken encoding unavailable: %v", err)
+	}
+	t.Fatalf("unexpected error: %v", err)
+}
+
+// assertTemplateCountMatchesRendered checks that a tracker counts a prompt template
+// as many tokens as the rendered prompt
+func assertTemplateCountMatchesRendered(t *testing.T, provider tokentracker.Provider, model string) {
+	t.Helper()
+	tracker := tokentracker.NewTokenTracker(tokentracker.NewConfig())
+	tracker.RegisterProvider(provider)
+
+	template := tokentracker.PromptTemplate{
+		Name:  "support",
+		Model: model,
+		Text:  "You are a helpful support agent for our product. Answer politely.\nCustomer {{name}} asks: {{question}}\nReply to {{name}} kindly.",
+	}
+	if err := tracker.RegisterPromptTemplate(template); err != nil {
+		t.Fatalf("RegisterPromptTemplate() error = %v", err)
+	}
+
+	vars := map[string]string{"name": "Ada", "question": "how do I reset my password?"}
+	rendered, err := tracker.RenderPromptTemplate("support", vars)
+	if err != nil {
+		t.Fatalf("RenderPromptTemplate() error = %v", err)
+	}
+	want, err := tracker.CountTokens(tokentracker.TokenCountParams{Model: model, Text: &rendered})
+	skipIfEncodingUnavailable(t, err)
+
+	count, err := tracker.CountTokensForTemplate("support", vars)
+	if err != nil {
+		t.Fatalf("CountTokensForTemplate() error = %v", err)
+	}
+	if count.InputTokens != want.InputTokens {
+		t.Errorf("CountTokensForTemplate() = %d tokens, want %d as for the rendered prompt", count.InputTokens, want.InputTokens)
+	}
+}
+
+// assertCountsSystemInstructionWithText checks that a provider counts the system
+// instruction of a text prompt as the conversation of a system and a user message
+func assertCountsSystemInstructionWithText(t *testing.T, provider tokentracker.Provider, model string) {
+	t.Helper()
+	text := "Summarize the attached quarterly report."
+	system := "You are a terse financial analyst. Answer in one sentence."
+
+	count, err := provider.CountTokens(tokentracker.TokenCountParams{Model: model, Text: &text, SystemInstruction: system})
+	skipIfEncodingUnavailable(t, err)
+	want, err := provider.CountTokens(tokentracker.TokenCountParams{Model: model, Messages: []tokentracker.Message{
+		{Role: "system", Content: system},
+		{Role: "user", Content: text},
+	}})
+	if err != nil {
+		t.Fatalf("CountTokens() error = %v", err)
+	}
+	textOnly, err := provider.CountTokens(tokentracker.TokenCountParams{Model: model, Text: &text})
+	if err != nil {
+		t.Fatalf("CountTokens() error = %v", err)
+	}
+
+	if count.InputTokens != want.InputTokens || count.InputTokens <= textOnly.InputTokens {
+		t.Errorf("CountTokens() with a system instruction = %d, want %d as for the conversation (text alone %d)", count.InputTokens, want.InputTokens, textOnly.InputTokens)
+	}
+}
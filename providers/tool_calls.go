@@ -0,0 +1,109 @@
+package providers
+
+import (
+	"encoding/json"
+
+	"github.com/TrustSight-io/tokentracker"
+)
+
+// openAIResponseToolCalls returns the tool calls, and legacy function calls, in the
+// choices of an OpenAI chat completion
+func openAIResponseToolCalls(response map[string]interface{}) []tokentracker.ToolCall {
+	choices, _ := response["choices"].([]interface{})
+
+	var calls []tokentracker.ToolCall
+	for _, choiceInterface := range choices {
+		choice, _ := choiceInterface.(map[string]interface{})
+		message, ok := choice["message"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		toolCalls, _ := message["tool_calls"].([]interface{})
+		for _, callInterface := range toolCalls {
+			call, _ := callInterface.(map[string]interface{})
+			function, ok := call["function"].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			id, _ := call["id"].(string)
+			calls = append(calls, openAIFunctionCall(id, function))
+		}
+
+		if function, ok := message["function_call"].(map[string]interface{}); ok {
+			calls = append(calls, openAIFunctionCall("", function))
+		}
+	}
+	return calls
+}
+
+// openAIFunctionCall converts the function of an OpenAI tool call
+func openAIFunctionCall(id string, function map[string]interface{}) tokentracker.ToolCall {
+	name, _ := function["name"].(string)
+	arguments, _ := function["arguments"].(string)
+	return tokentracker.ToolCall{ID: id, Name: name, Arguments: arguments}
+}
+
+// claudeResponseToolCalls returns the tool_use blocks in the content of a Claude message
+func claudeResponseToolCalls(response map[string]interface{}) []tokentracker.ToolCall {
+	content, _ := response["content"].([]interface{})
+
+	var calls []tokentracker.ToolCall
+	for _, blockInterface := range content {
+		block, _ := blockInterface.(map[string]interface{})
+		if block["type"] != "tool_use" {
+			continue
+		}
+		id, _ := block["id"].(string)
+		name, _ := block["name"].(string)
+		calls = append(calls, tokentracker.ToolCall{ID: id, Name: name, Arguments: encodeToolArguments(block["input"])})
+	}
+	return calls
+}
+
+// geminiResponseToolCalls returns the function calls in the candidates of a Gemini response
+func geminiResponseToolCalls(response map[string]interface{}) []tokentracker.ToolCall {
+	candidates, _ := response["candidates"].([]interface{})
+
+	var calls []tokentracker.ToolCall
+	for _, candidateInterface := range candidates {
+		candidate, _ := candidateInterface.(map[string]interface{})
+		content, _ := candidate["content"].(map[string]interface{})
+		parts, _ := content["parts"].([]interface{})
+		for _, partInterface := range parts {
+			part, _ := partInterface.(map[string]interface{})
+			call, ok := part["functionCall"].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _ := call["name"].(string)
+			calls = append(calls, tokentracker.ToolCall{Name: name, Arguments: encodeToolArguments(call["args"])})
+		}
+	}
+	return calls
+}
+
+// encodeToolArguments encodes decoded tool arguments back into JSON
+func encodeToolArguments(arguments interface{}) string {
+	if arguments == nil {
+		return "{}"
+	}
+	data, err := json.Marshal(arguments)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+// toolCallTokens counts the tool calls of a response with count, capped at the
+// response tokens the provider reported
+func toolCallTokens(calls []tokentracker.ToolCall, responseTokens int, count func(string) int) int {
+	tokens := 0
+	for _, call := range calls {
+		tokens += count(tokentracker.ToolCallText(call))
+	}
+	if tokens > responseTokens {
+		return responseTokens
+	}
+	return tokens
+}
@@ -0,0 +1,181 @@
+package providers
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/TrustSight-io/tokentracker"
+)
+
+func TestExtractToolCallTokens(t *testing.T) {
+	config := tokentracker.NewConfig()
+	arguments := `{"location":"Paris, France","unit":"celsius","days":5}`
+
+	tests := []struct {
+		name     string
+		provider tokentracker.Provider
+		response map[string]interface{}
+	}{
+		{
+			name:     "openai tool_calls",
+			provider: NewOpenAIProvider(config),
+			response: map[string]interface{}{
+				"model": "gpt-4o",
+				"choices": []interface{}{map[string]interface{}{
+					"message": map[string]interface{}{
+						"role": "assistant",
+						"tool_calls": []interface{}{map[string]interface{}{
+							"id":       "call_abc",
+							"type":     "function",
+							"function": map[string]interface{}{"name": "get_forecast", "arguments": arguments},
+						}},
+					},
+				}},
+				"usage": map[string]interface{}{"prompt_tokens": float64(80), "completion_tokens": float64(30), "total_tokens": float64(110)},
+			},
+		},
+		{
+			name:     "openai function_call",
+			provider: NewOpenAIProvider(config),
+			response: map[string]interface{}{
+				"model": "gpt-3.5-turbo",
+				"choices": []interface{}{map[string]interface{}{
+					"message": map[string]interface{}{
+						"function_call": map[string]interface{}{"name": "get_forecast", "arguments": arguments},
+					},
+				}},
+				"usage": map[string]interface{}{"prompt_tokens": float64(80), "completion_tokens": float64(30), "total_tokens": float64(110)},
+			},
+		},
+		{
+			name:     "claude tool_use",
+			provider: NewClaudeProvider(config),
+			response: map[string]interface{}{
+				"content": []interface{}{
+					map[string]interface{}{"type": "text", "text": "Let me check."},
+					map[string]interface{}{
+						"type":  "tool_use",
+						"id":    "toolu_01",
+						"name":  "get_forecast",
+						"input": map[string]interface{}{"location": "Paris, France", "unit": "celsius", "days": float64(5)},
+					},
+				},
+				"usage": map[string]interface{}{"input_tokens": float64(80), "output_tokens": float64(40)},
+			},
+		},
+		{
+			name:     "gemini functionCall",
+			provider: NewGeminiProvider(config),
+			response: map[string]interface{}{
+				"candidates": []interface{}{map[string]interface{}{
+					"content": map[string]interface{}{"parts": []interface{}{map[string]interface{}{
+						"functionCall": map[string]interface{}{
+							"name": "get_forecast",
+							"args": map[string]interface{}{"location": "Paris, France", "unit": "celsius", "days": float64(5)},
+						},
+					}}},
+				}},
+				"usageMetadata": map[string]interface{}{"promptTokenCount": float64(80), "candidatesTokenCount": float64(30), "totalTokenCount": float64(110)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count, err := tt.provider.ExtractTokenUsageFromResponse(tt.response)
+			if err != nil {
+				t.Fatalf("ExtractTokenUsageFromResponse() error = %v", err)
+			}
+			if count.ToolCallTokens == 0 || count.ToolCallTokens > count.ResponseTokens {
+				t.Errorf("ToolCallTokens = %d, want the call counted within the %d response tokens", count.ToolCallTokens, count.ResponseTokens)
+			}
+		})
+	}
+
+	// Tool call tokens never exceed the reported output
+	response := tests[0].response
+	response["usage"] = map[string]interface{}{"prompt_tokens": float64(80), "completion_tokens": float64(2), "total_tokens": float64(82)}
+	if count, _ := NewOpenAIProvider(config).ExtractTokenUsageFromResponse(response); count.ToolCallTokens != 2 {
+		t.Errorf("ToolCallTokens = %d, want it capped at the completion tokens", count.ToolCallTokens)
+	}
+
+	// Responses without tool calls report none
+	plain := map[string]interface{}{"usage": map[string]interface{}{"input_tokens": float64(10), "output_tokens": float64(5)}}
+	if count, _ := NewClaudeProvider(config).ExtractTokenUsageFromResponse(plain); count.ToolCallTokens != 0 {
+		t.Errorf("ToolCallTokens = %d, want 0", count.ToolCallTokens)
+	}
+}
+
+func TestCountTokens_ToolCallsAndResults(t *testing.T) {
+	config := tokentracker.NewConfig()
+
+	question := []tokentracker.Message{{Role: "user", Content: "What's the weather in Paris for the next five days?"}}
+	conversation := append(append([]tokentracker.Message(nil), question...),
+		tokentracker.Message{Role: "assistant", ToolCalls: []tokentracker.ToolCall{
+			{ID: "call_abc", Name: "get_forecast", Arguments: `{"location":"Paris, France","days":5}`},
+		}},
+		tokentracker.Message{Role: "tool", ToolCallID: "call_abc", Content: `{"forecast":["sunny","sunny","rain","cloudy","sunny"]}`},
+	)
+
+	tests := []struct {
+		provider tokentracker.Provider
+		model    string
+	}{
+		{NewClaudeProvider(config), "claude-3-haiku"},
+		{NewGeminiProvider(config), "gemini-pro"},
+		{NewMistralProvider(config), "mistral-small"},
+	}
+	for _, tt := range tests {
+		before, err := tt.provider.CountTokens(tokentracker.TokenCountParams{Model: tt.model, Messages: question})
+		if err != nil {
+			t.Fatalf("%s: CountTokens() error = %v", tt.provider.Name(), err)
+		}
+		withCall, _ := tt.provider.CountTokens(tokentracker.TokenCountParams{Model: tt.model, Messages: conversation[:2]})
+		after, _ := tt.provider.CountTokens(tokentracker.TokenCountParams{Model: tt.model, Messages: conversation})
+
+		// The call's arguments alone are about 10 tokens
+		if withCall.InputTokens < before.InputTokens+10 || after.InputTokens <= withCall.InputTokens {
+			t.Errorf("%s: CountTokens() = %d, %d, %d, want the tool call and its result counted", tt.provider.Name(), before.InputTokens, withCall.InputTokens, after.InputTokens)
+		}
+	}
+
+	chatML, err := NewOpenAIProvider(config).CountTokens(tokentracker.TokenCountParams{Model: "gpt-4o", Messages: conversation})
+	skipIfEncodingUnavailable(t, err)
+	withoutCalls, _ := NewOpenAIProvider(config).CountTokens(tokentracker.TokenCountParams{Model: "gpt-4o", Messages: question})
+	if chatML.InputTokens <= withoutCalls.InputTokens+10 {
+		t.Errorf("CountTokens() = %d, want the tool call counted", chatML.InputTokens)
+	}
+}
+
+func TestAnthropicCountTokensRequest_ToolCalls(t *testing.T) {
+	req := newAnthropicCountTokensRequest(tokentracker.TokenCountParams{
+		Model: "claude-3-haiku",
+		Messages: []tokentracker.Message{
+			{Role: "user", Content: "Weather in Paris?"},
+			{Role: "assistant", Content: "Checking.", ToolCalls: []tokentracker.ToolCall{
+				{ID: "toolu_01", Name: "get_forecast", Arguments: `{"location":"Paris"}`},
+			}},
+			{Role: "tool", ToolCallID: "toolu_01", Content: "sunny"},
+		},
+	})
+
+	data, err := json.Marshal(req.Messages)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	want := `[{"role":"user","content":"Weather in Paris?"},` +
+		`{"role":"assistant","content":[{"text":"Checking.","type":"text"},{"id":"toolu_01","input":{"location":"Paris"},"name":"get_forecast","type":"tool_use"}]},` +
+		`{"role":"user","content":[{"content":"sunny","tool_use_id":"toolu_01","type":"tool_result"}]}]`
+	if string(data) != want {
+		t.Errorf("messages = %s\nwant %s", data, want)
+	}
+
+	// Invalid arguments are sent as an empty input
+	req = newAnthropicCountTokensRequest(tokentracker.TokenCountParams{
+		Model:    "claude-3-haiku",
+		Messages: []tokentracker.Message{{Role: "assistant", ToolCalls: []tokentracker.ToolCall{{ID: "toolu_02", Name: "f", Arguments: "{not json"}}}},
+	})
+	if data, _ := json.Marshal(req.Messages); !strings.Contains(string(data), `"input":{}`) {
+		t.Errorf("messages = %s, want an empty input", data)
+	}
+}
@@ -0,0 +1,394 @@
+package tokentracker
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultProxyMaxBodyBytes is the largest request or response body ProxyMiddleware inspects
+const DefaultProxyMaxBodyBytes = 10 << 20
+
+// ProxyIdentity is the tenant and user a proxied call is attributed to
+type ProxyIdentity struct {
+	// TenantID becomes the ProjectID of the tracked usage, so tenant quotas apply
+	TenantID string
+
+	// UserID becomes the UserID of the tracked usage; without it, the user field of
+	// OpenAI requests is used
+	UserID string
+}
+
+// ProxyIdentityResolver returns the identity of a proxied request. It must derive it
+// from an authenticated source, such as a verified token or the caller's API key, and
+// never from headers the client sets freely, or callers could spend other tenants' quotas.
+type ProxyIdentityResolver func(r *http.Request) (ProxyIdentity, error)
+
+// ProxyMiddleware is net/http middleware for LLM proxies. It inspects the JSON
+// responses of the OpenAI, Anthropic and Gemini APIs the wrapped handler proxies,
+// extracts their usage with the provider of the model and tracks it, attributing the
+// call to the identity its resolver returns. The API key of the request and the OpenAI-Organization
+// and OpenAI-Project response headers attribute it to the billed account. Bodies are
+// passed through unchanged.
+//
+// Streamed responses (server-sent events) are tracked when the handler returns, from
+// OpenAI's usage chunk (see stream_options.include_usage), Anthropic's message_start
+// and message_delta events or Gemini's last usageMetadata.
+type ProxyMiddleware struct {
+	// Tracker receives the usage of every tracked call
+	Tracker *DefaultTokenTracker
+
+	// Resolve returns the tenant and user of a call. It is required: calls it fails
+	// to identify are rejected with 401 Unauthorized, and without it every call fails.
+	Resolve ProxyIdentityResolver
+
+	// HeaderTags tags calls with request headers, mapping header names to tag keys
+	HeaderTags map[string]string
+
+	// Tags are attached to every tracked call
+	Tags map[string]string
+
+	// MaxBodyBytes bounds the request and response bodies inspected; larger calls are
+	// proxied but not tracked (0 uses DefaultProxyMaxBodyBytes)
+	MaxBodyBytes int64
+
+	// OnError receives tracking failures; they never fail the proxied call (nil ignores them)
+	OnError func(error)
+}
+
+// NewProxyMiddleware creates middleware tracking the calls proxied through it, with
+// tenants and users returned by resolve
+func NewProxyMiddleware(tracker *DefaultTokenTracker, resolve ProxyIdentityResolver) *ProxyMiddleware {
+	return &ProxyMiddleware{
+		Tracker: tracker,
+		Resolve: resolve,
+	}
+}
+
+// proxyRequest is the part of a proxied request needed for tracking
+type proxyRequest struct {
+	Model string `json:"model"`
+	User  string `json:"user"`
+}
+
+// Handler wraps next, tracking the usage of the POST requests it serves. It fits any
+// router accepting net/http middleware, e.g. gin.WrapH or echo.WrapMiddleware.
+func (m *ProxyMiddleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Body == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if m.Resolve == nil {
+			m.reportError(NewError(ErrInvalidParams, "proxy middleware has no identity resolver", nil))
+			http.Error(w, "proxy is not configured", http.StatusInternalServerError)
+			return
+		}
+		identity, err := m.Resolve(r)
+		if err != nil {
+			m.reportError(NewError(ErrInvalidParams, "failed to resolve the identity of a proxied call", err))
+			http.Error(w, "unauthenticated", http.StatusUnauthorized)
+			return
+		}
+
+		// Read the request body for the model, leaving it intact for next
+		body, err := io.ReadAll(io.LimitReader(r.Body, m.maxBodyBytes()+1))
+		if err != nil {
+			http.Error(w, "failed to read request body", http.StatusBadRequest)
+			return
+		}
+		if int64(len(body)) > m.maxBodyBytes() {
+			r.Body = readCloser{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+			next.ServeHTTP(w, r)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		var request proxyRequest
+		_ = json.Unmarshal(body, &request)
+		if request.Model == "" {
+			request.Model = geminiPathModel(r.URL.Path)
+		}
+
+		callParams := CallParams{
+			Model:     request.Model,
+			StartTime: m.Tracker.clock().Now(),
+			Tags:      m.tags(r),
+			UserID:    identity.UserID,
+			ProjectID: identity.TenantID,
+		}
+		if callParams.UserID == "" {
+			callParams.UserID = request.User
+		}
+
+		recorder := &proxyResponseWriter{ResponseWriter: w, limit: m.maxBodyBytes()}
+		next.ServeHTTP(recorder, r)
+		attributeCall(&callParams, r.Header, recorder.Header())
+		m.track(r, callParams, recorder)
+	})
+}
+
+// track records the usage of a proxied call's response
+func (m *ProxyMiddleware) track(r *http.Request, callParams CallParams, recorder *proxyResponseWriter) {
+	if recorder.status < 200 || recorder.status >= 300 {
+		return
+	}
+	if recorder.overflow {
+		m.reportError(NewError(ErrInvalidParams, fmt.Sprintf("response exceeds %d bytes and was not tracked", recorder.limit), nil))
+		return
+	}
+
+	usage := &recorder.usage
+	if !recorder.stream {
+		if err := usage.observeBody(recorder.body.Bytes(), recorder.Header().Get("Content-Encoding")); err != nil {
+			m.reportError(NewError(ErrInvalidParams, "failed to parse proxied response", err))
+			return
+		}
+	}
+	if usage.usage == nil && usage.usageMetadata == nil {
+		m.reportError(NewError(ErrInvalidParams, "usage information not found in response", nil))
+		return
+	}
+
+	// Price by the requested model, falling back to the model named in the response
+	if _, known := m.Tracker.providerForModel(callParams.Model); !known && usage.model != "" {
+		callParams.Model = usage.model
+	}
+	provider, exists := m.Tracker.providerForModel(callParams.Model)
+	if !exists {
+		m.reportError(NewError(ErrProviderNotFound, fmt.Sprintf("no provider found for model: %s", callParams.Model), nil))
+		return
+	}
+
+	count, err := provider.ExtractTokenUsageFromResponse(usage.response())
+	if err != nil {
+		m.reportError(err)
+		return
+	}
+	_, err = m.Tracker.TrackReportedUsage(r.Context(), callParams, count)
+	m.reportError(err)
+}
+
+// tags returns the configured tags and those of the request's headers
+func (m *ProxyMiddleware) tags(r *http.Request) map[string]string {
+	tags := copyTags(m.Tags)
+	for header, tag := range m.HeaderTags {
+		if value := r.Header.Get(header); value != "" {
+			if tags == nil {
+				tags = make(map[string]string)
+			}
+			tags[tag] = value
+		}
+	}
+	return tags
+}
+
+// maxBodyBytes returns the largest body inspected
+func (m *ProxyMiddleware) maxBodyBytes() int64 {
+	if m.MaxBodyBytes > 0 {
+		return m.MaxBodyBytes
+	}
+	return DefaultProxyMaxBodyBytes
+}
+
+// reportError passes a tracking failure to OnError
+func (m *ProxyMiddleware) reportError(err error) {
+	if err != nil && m.OnError != nil {
+		m.OnError(err)
+	}
+}
+
+// geminiPathModel returns the model of a Gemini API path such as
+// "/v1beta/models/gemini-1.5-pro:generateContent", which Gemini requests do not carry
+// in their body
+func geminiPathModel(path string) string {
+	_, model, ok := strings.Cut(path, "/models/")
+	if !ok {
+		return ""
+	}
+	model, _, _ = strings.Cut(model, ":")
+	return model
+}
+
+// readCloser reads from one reader and closes another
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// proxyResponseWriter passes a response through, capturing its status and the usage
+// of its body
+type proxyResponseWriter struct {
+	http.ResponseWriter
+	status   int
+	stream   bool
+	limit    int64
+	body     bytes.Buffer
+	overflow bool
+	pending  []byte
+	usage    proxyUsage
+}
+
+// WriteHeader records the status and whether the response is a stream
+func (w *proxyResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+		w.stream = strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream")
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write passes data through, capturing it for tracking
+func (w *proxyResponseWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(data)
+	w.capture(data[:n])
+	return n, err
+}
+
+// Flush flushes the underlying writer, so streams reach the client as they are proxied
+func (w *proxyResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the underlying writer for http.ResponseController
+func (w *proxyResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// capture buffers the body of a response, or scans the complete events of a stream
+func (w *proxyResponseWriter) capture(data []byte) {
+	if w.overflow {
+		return
+	}
+	if !w.stream {
+		if int64(w.body.Len()+len(data)) > w.limit {
+			w.overflow = true
+			w.body = bytes.Buffer{}
+			return
+		}
+		w.body.Write(data)
+		return
+	}
+
+	w.pending = append(w.pending, data...)
+	for {
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			if int64(len(w.pending)) > w.limit {
+				w.overflow = true
+			}
+			return
+		}
+		line := bytes.TrimSpace(w.pending[:i])
+		w.pending = w.pending[i+1:]
+
+		payload, ok := bytes.CutPrefix(line, []byte("data:"))
+		if !ok {
+			continue
+		}
+		var event map[string]interface{}
+		if json.Unmarshal(bytes.TrimSpace(payload), &event) == nil {
+			w.usage.observe(event)
+		}
+	}
+}
+
+// proxyUsage accumulates the model and usage of a response or the events of a stream.
+// Later usage blocks overwrite the counts of earlier ones, which yields the totals of
+// OpenAI and Gemini streams and merges Anthropic's input and output counts.
+type proxyUsage struct {
+	model         string
+	usage         map[string]interface{}
+	usageMetadata map[string]interface{}
+}
+
+// observeBody observes a whole JSON response, or the array of responses Gemini streams
+// without server-sent events
+func (u *proxyUsage) observeBody(body []byte, encoding string) error {
+	switch encoding {
+	case "", "identity":
+	case "gzip":
+		reader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		if body, err = io.ReadAll(reader); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported content encoding: %s", encoding)
+	}
+
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		var events []map[string]interface{}
+		if err := json.Unmarshal(trimmed, &events); err != nil {
+			return err
+		}
+		for _, event := range events {
+			u.observe(event)
+		}
+		return nil
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return err
+	}
+	u.observe(response)
+	return nil
+}
+
+// observe records the model and usage of a response or event
+func (u *proxyUsage) observe(event map[string]interface{}) {
+	if model, _ := event["model"].(string); model != "" {
+		u.model = model
+	} else if version, _ := event["modelVersion"].(string); version != "" && u.model == "" {
+		u.model = version
+	}
+
+	// Anthropic's message_start event carries the message with the input usage
+	if message, ok := event["message"].(map[string]interface{}); ok {
+		u.observe(message)
+	}
+	if usage, ok := event["usage"].(map[string]interface{}); ok {
+		u.usage = mergeUsage(u.usage, usage)
+	}
+	if metadata, ok := event["usageMetadata"].(map[string]interface{}); ok {
+		u.usageMetadata = mergeUsage(u.usageMetadata, metadata)
+	}
+}
+
+// response returns the accumulated usage as a response the providers extract usage from
+func (u *proxyUsage) response() map[string]interface{} {
+	response := map[string]interface{}{"model": u.model}
+	if u.usage != nil {
+		response["usage"] = u.usage
+	}
+	if u.usageMetadata != nil {
+		response["usageMetadata"] = u.usageMetadata
+	}
+	return response
+}
+
+// mergeUsage copies the counts of a usage block over those seen before
+func mergeUsage(into, usage map[string]interface{}) map[string]interface{} {
+	if into == nil {
+		into = make(map[string]interface{}, len(usage))
+	}
+	for key, value := range usage {
+		if value != nil {
+			into[key] = value
+		}
+	}
+	return into
+}
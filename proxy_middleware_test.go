@@ -0,0 +1,223 @@
+package tokentracker
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// proxyUsageProvider extracts the usage blocks of OpenAI, Anthropic and Gemini responses
+type proxyUsageProvider struct {
+	usagePricedProvider
+}
+
+func (p *proxyUsageProvider) ExtractTokenUsageFromResponse(response interface{}) (TokenCount, error) {
+	resp := response.(map[string]interface{})
+	number := func(block interface{}, key string) int {
+		value, _ := block.(map[string]interface{})[key].(float64)
+		return int(value)
+	}
+	if metadata, ok := resp["usageMetadata"]; ok {
+		return TokenCount{InputTokens: number(metadata, "promptTokenCount"), ResponseTokens: number(metadata, "candidatesTokenCount")}, nil
+	}
+	usage := resp["usage"]
+	if input := number(usage, "prompt_tokens"); input > 0 {
+		return TokenCount{InputTokens: input, ResponseTokens: number(usage, "completion_tokens")}, nil
+	}
+	return TokenCount{InputTokens: number(usage, "input_tokens"), ResponseTokens: number(usage, "output_tokens")}, nil
+}
+
+func newProxyTracker(t *testing.T) (*DefaultTokenTracker, *MemoryUsageStore) {
+	store := NewMemoryUsageStore()
+	tracker := newPricedTestTracker(t, WithUsageStore(store))
+	tracker.RegisterProvider(&proxyUsageProvider{newPricedProvider(tracker.Config(), "acme", "acme-1")})
+	return tracker, store
+}
+
+// proxyIdentities are the callers of the proxy by API key
+var proxyIdentities = map[string]ProxyIdentity{
+	"Bearer key-acme": {TenantID: "acme-corp"},
+	"Bearer key-user": {UserID: "user-1"},
+}
+
+// resolveProxyIdentity identifies callers by their API key, as an authenticating proxy would
+func resolveProxyIdentity(r *http.Request) (ProxyIdentity, error) {
+	identity, ok := proxyIdentities[r.Header.Get("Authorization")]
+	if !ok {
+		return ProxyIdentity{}, errors.New("unknown API key")
+	}
+	return identity, nil
+}
+
+// serveProxied sends a request through the middleware to a handler replying with body
+func serveProxied(middleware *ProxyMiddleware, path, request, contentType, body string, header http.Header) *httptest.ResponseRecorder {
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ := io.ReadAll(r.Body)
+		if string(received) != request {
+			http.Error(w, "request body changed", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		for _, line := range strings.SplitAfter(body, "\n") {
+			io.WriteString(w, line)
+			w.(http.Flusher).Flush()
+		}
+	})
+
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(request))
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	rec := httptest.NewRecorder()
+	middleware.Handler(upstream).ServeHTTP(rec, req)
+	return rec
+}
+
+func TestProxyMiddleware_TracksResponses(t *testing.T) {
+	tests := []struct {
+		name, path, request, body string
+		input, output             int
+	}{
+		{"openai", "/v1/chat/completions", `{"model":"acme-1","user":"user-9"}`, `{"id":"chatcmpl-1","model":"acme-1","usage":{"prompt_tokens":25,"completion_tokens":8,"total_tokens":33}}`, 25, 8},
+		{"anthropic", "/v1/messages", `{"model":"acme-1","max_tokens":100}`, `{"id":"msg_1","model":"acme-1","usage":{"input_tokens":40,"output_tokens":12}}`, 40, 12},
+		{"gemini", "/v1beta/models/acme-1:generateContent", `{"contents":[]}`, `{"candidates":[],"usageMetadata":{"promptTokenCount":7,"candidatesTokenCount":3,"totalTokenCount":10}}`, 7, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker, store := newProxyTracker(t)
+			middleware := NewProxyMiddleware(tracker, resolveProxyIdentity)
+			middleware.HeaderTags = map[string]string{"X-Feature": "feature"}
+			middleware.OnError = func(err error) { t.Errorf("OnError(%v)", err) }
+
+			header := http.Header{"Authorization": {"Bearer key-acme"}, "X-Feature": {"search"}}
+			rec := serveProxied(middleware, tt.path, tt.request, "application/json", tt.body, header)
+			if rec.Code != http.StatusOK || rec.Body.String() != tt.body {
+				t.Fatalf("response = %d %q, want the upstream response", rec.Code, rec.Body.String())
+			}
+
+			records, _ := store.Query(UsageFilter{})
+			if len(records) != 1 {
+				t.Fatalf("tracked %d calls, want 1", len(records))
+			}
+			record := records[0]
+			if record.TokenCount.InputTokens != tt.input || record.TokenCount.ResponseTokens != tt.output || record.Model != "acme-1" {
+				t.Errorf("record = %+v", record)
+			}
+			if record.ProjectID != "acme-corp" || record.Tags["feature"] != "search" {
+				t.Errorf("record = %+v, want the tenant and header tags", record)
+			}
+		})
+	}
+}
+
+func TestProxyMiddleware_TracksStreams(t *testing.T) {
+	tests := []struct {
+		name, body    string
+		input, output int
+	}{
+		{"openai", "data: {\"model\":\"acme-1\",\"choices\":[{\"delta\":{\"content\":\"Hi\"}}],\"usage\":null}\n\ndata: {\"model\":\"acme-1\",\"choices\":[],\"usage\":{\"prompt_tokens\":9,\"completion_tokens\":2}}\n\ndata: [DONE]\n\n", 9, 2},
+		{"anthropic", "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"model\":\"acme-1\",\"usage\":{\"input_tokens\":30,\"output_tokens\":1}}}\n\nevent: message_delta\ndata: {\"type\":\"message_delta\",\"usage\":{\"output_tokens\":15}}\n\n", 30, 15},
+		{"gemini", "data: {\"usageMetadata\":{\"promptTokenCount\":5,\"candidatesTokenCount\":1}}\n\ndata: {\"usageMetadata\":{\"promptTokenCount\":5,\"candidatesTokenCount\":6}}\n\n", 5, 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker, store := newProxyTracker(t)
+			middleware := NewProxyMiddleware(tracker, resolveProxyIdentity)
+			middleware.OnError = func(err error) { t.Errorf("OnError(%v)", err) }
+
+			rec := serveProxied(middleware, "/v1beta/models/acme-1:streamGenerateContent", `{"model":"acme-1","stream":true}`, "text/event-stream", tt.body, http.Header{"Authorization": {"Bearer key-user"}})
+			if rec.Body.String() != tt.body {
+				t.Errorf("response = %q, want the stream passed through", rec.Body.String())
+			}
+
+			records, _ := store.Query(UsageFilter{})
+			if len(records) != 1 {
+				t.Fatalf("tracked %d calls, want 1", len(records))
+			}
+			if count := records[0].TokenCount; count.InputTokens != tt.input || count.ResponseTokens != tt.output || records[0].UserID != "user-1" {
+				t.Errorf("record = %+v", records[0])
+			}
+		})
+	}
+}
+
+func TestProxyMiddleware_SkipsAndReports(t *testing.T) {
+	tracker, store := newProxyTracker(t)
+	middleware := NewProxyMiddleware(tracker, func(*http.Request) (ProxyIdentity, error) { return ProxyIdentity{}, nil })
+	var errs []error
+	middleware.OnError = func(err error) { errs = append(errs, err) }
+
+	// Failed calls are not tracked
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"rate limited"}`, http.StatusTooManyRequests)
+	})
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"acme-1"}`))
+	middleware.Handler(upstream).ServeHTTP(httptest.NewRecorder(), req)
+
+	// Responses without usage are reported
+	serveProxied(middleware, "/v1/chat/completions", `{"model":"acme-1"}`, "application/json", `{"id":"x"}`, nil)
+	if records, _ := store.Query(UsageFilter{}); len(records) != 0 || len(errs) != 1 {
+		t.Fatalf("records = %d, errors = %v, want none tracked and one error", len(records), errs)
+	}
+
+	// Gzipped responses are decompressed for tracking
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	fmt.Fprint(zw, `{"model":"acme-1","usage":{"input_tokens":4,"output_tokens":2}}`)
+	zw.Close()
+	upstream = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(compressed.Bytes())
+	})
+	req = httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"acme-1"}`))
+	middleware.Handler(upstream).ServeHTTP(httptest.NewRecorder(), req)
+	if records, _ := store.Query(UsageFilter{}); len(records) != 1 || records[0].TokenCount.InputTokens != 4 {
+		t.Errorf("records = %+v, want the gzipped response tracked", records)
+	}
+}
+
+func TestProxyMiddleware_RequiresIdentity(t *testing.T) {
+	tracker, store := newProxyTracker(t)
+	middleware := NewProxyMiddleware(tracker, resolveProxyIdentity)
+	var errs []error
+	middleware.OnError = func(err error) { errs = append(errs, err) }
+	body := `{"model":"acme-1","usage":{"input_tokens":4,"output_tokens":2}}`
+
+	// Tenant headers set by the caller are ignored
+	spoofed := http.Header{"Authorization": {"Bearer key-user"}, "X-Tenant-Id": {"acme-corp"}}
+	serveProxied(middleware, "/v1/messages", `{"model":"acme-1"}`, "application/json", body, spoofed)
+	if records, _ := store.Query(UsageFilter{}); len(records) != 1 || records[0].ProjectID != "" || records[0].UserID != "user-1" {
+		t.Fatalf("records = %+v, want the call attributed to the resolved identity only", records)
+	}
+
+	// Calls that cannot be identified are rejected before reaching the upstream
+	rec := serveProxied(middleware, "/v1/messages", `{"model":"acme-1"}`, "application/json", body, http.Header{"X-Tenant-Id": {"acme-corp"}})
+	var trackerErr *TokenTrackerError
+	if rec.Code != http.StatusUnauthorized || rec.Body.String() == body || len(errs) != 1 || !errors.As(errs[0], &trackerErr) || trackerErr.Type != ErrInvalidParams {
+		t.Errorf("response = %d %q, errors = %v, want 401 and an error", rec.Code, rec.Body.String(), errs)
+	}
+
+	// Without a resolver every call fails
+	middleware.Resolve = nil
+	if rec := serveProxied(middleware, "/v1/messages", `{"model":"acme-1"}`, "application/json", body, spoofed); rec.Code != http.StatusInternalServerError {
+		t.Errorf("response = %d, want 500 without a resolver", rec.Code)
+	}
+	if records, _ := store.Query(UsageFilter{}); len(records) != 1 {
+		t.Errorf("tracked %d calls, want only the identified one", len(records))
+	}
+}
+
+func TestGeminiPathModel(t *testing.T) {
+	if model := geminiPathModel("/v1beta/models/gemini-1.5-pro:streamGenerateContent"); model != "gemini-1.5-pro" {
+		t.Errorf("geminiPathModel() = %q", model)
+	}
+	if model := geminiPathModel("/v1/chat/completions"); model != "" {
+		t.Errorf("geminiPathModel() = %q, want none", model)
+	}
+}
@@ -0,0 +1,107 @@
+package tokentracker
+
+import (
+	"time"
+)
+
+// AggregationQuery is one aggregation evaluated by QueryBundle
+type AggregationQuery struct {
+	Name    string      // key of the result
+	Filter  UsageFilter // Limit applies to the summaries
+	GroupBy []GroupBy
+}
+
+// QueryBundleResult holds the results of a QueryBundle, all computed from the same snapshot
+type QueryBundleResult struct {
+	SnapshotAt time.Time
+	Records    int // records in the snapshot
+	Results    map[string][]UsageSummary
+}
+
+// QueryBundle evaluates several aggregation queries against one consistent snapshot of
+// the usage store, so e.g. totals and per-model breakdowns of a dashboard always agree.
+// The snapshot is read with a single store query spanning all query periods.
+func (t *DefaultTokenTracker) QueryBundle(queries ...AggregationQuery) (QueryBundleResult, error) {
+	names := make(map[string]bool, len(queries))
+	for _, query := range queries {
+		if query.Name == "" {
+			return QueryBundleResult{}, NewError(ErrInvalidParams, "query name is required", nil)
+		}
+		if names[query.Name] {
+			return QueryBundleResult{}, NewError(ErrInvalidParams, "duplicate query name: "+query.Name, nil)
+		}
+		names[query.Name] = true
+	}
+
+	snapshotAt := t.clock().Now()
+	records, err := t.GetUsage(snapshotFilter(queries))
+	if err != nil {
+		return QueryBundleResult{}, err
+	}
+
+	result := QueryBundleResult{
+		SnapshotAt: snapshotAt,
+		Records:    len(records),
+		Results:    make(map[string][]UsageSummary, len(queries)),
+	}
+	for _, query := range queries {
+		filter := query.Filter
+		filter.Limit = 0
+
+		summaries := SummarizeUsage(filterUsage(records, filter), query.GroupBy...)
+		if query.Filter.Limit > 0 && len(summaries) > query.Filter.Limit {
+			summaries = summaries[:query.Filter.Limit]
+		}
+		result.Results[query.Name] = summaries
+	}
+	return result, nil
+}
+
+// snapshotFilter returns the narrowest filter covering the records of all queries
+func snapshotFilter(queries []AggregationQuery) UsageFilter {
+	if len(queries) == 0 {
+		return UsageFilter{}
+	}
+
+	filter := queries[0].Filter
+	filter.Limit = 0
+	for _, query := range queries[1:] {
+		if query.Filter.Start.IsZero() || query.Filter.Start.Before(filter.Start) {
+			filter.Start = query.Filter.Start
+		}
+		if query.Filter.End.IsZero() || (!filter.End.IsZero() && query.Filter.End.After(filter.End)) {
+			filter.End = query.Filter.End
+		}
+		if query.Filter.Model != filter.Model {
+			filter.Model = ""
+		}
+		if query.Filter.Provider != filter.Provider {
+			filter.Provider = ""
+		}
+		if query.Filter.UserID != filter.UserID {
+			filter.UserID = ""
+		}
+		if query.Filter.ProjectID != filter.ProjectID {
+			filter.ProjectID = ""
+		}
+		if query.Filter.APIKeyID != filter.APIKeyID {
+			filter.APIKeyID = ""
+		}
+		filter.Tags = sharedTags(filter.Tags, query.Filter.Tags)
+	}
+	return filter
+}
+
+// sharedTags returns the tags present with the same value in both a and b
+func sharedTags(a, b map[string]string) map[string]string {
+	var shared map[string]string
+	for key, value := range a {
+		if other, exists := b[key]; exists && other == value {
+			if shared == nil {
+				shared = make(map[string]string)
+			}
+			shared[key] = value
+		}
+	}
+	return shared
+}
@@ -0,0 +1,98 @@
+package tokentracker
+
+import (
+	"testing"
+	"time"
+)
+
+// countingStore counts the queries made against a memory store
+type countingStore struct {
+	*MemoryUsageStore
+	queries int
+}
+
+func (s *countingStore) Query(filter UsageFilter) ([]UsageMetrics, error) {
+	s.queries++
+	return s.MemoryUsageStore.Query(filter)
+}
+
+func TestDefaultTokenTracker_QueryBundle(t *testing.T) {
+	store := &countingStore{MemoryUsageStore: NewMemoryUsageStore()}
+	base := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	_ = store.Record(sampleUsage("gpt-4", "openai", base, 1))
+	_ = store.Record(sampleUsage("gpt-4", "openai", base.Add(time.Hour), 2))
+	_ = store.Record(sampleUsage("claude-3-haiku", "anthropic", base.Add(2*time.Hour), 0.5))
+	_ = store.Record(sampleUsage("gpt-4", "openai", base.AddDate(0, 0, 1), 4))
+
+	tracker := NewTokenTracker(NewConfig(), WithUsageStore(store))
+
+	day := UsageFilter{Start: base, End: base.AddDate(0, 0, 1)}
+	result, err := tracker.QueryBundle(
+		AggregationQuery{Name: "totals", Filter: day},
+		AggregationQuery{Name: "by_model", Filter: day, GroupBy: []GroupBy{GroupByModel}},
+		AggregationQuery{Name: "openai_daily", Filter: UsageFilter{Provider: "openai"}, GroupBy: []GroupBy{GroupByDay}},
+		AggregationQuery{Name: "top_model", Filter: UsageFilter{Start: base, End: base.AddDate(0, 0, 1), Limit: 1}, GroupBy: []GroupBy{GroupByModel}},
+	)
+	if err != nil {
+		t.Fatalf("QueryBundle() error = %v", err)
+	}
+
+	if store.queries != 1 {
+		t.Errorf("QueryBundle() made %d store queries, want a single snapshot", store.queries)
+	}
+	if result.Records != 4 {
+		t.Errorf("snapshot has %d records, want 4", result.Records)
+	}
+
+	totals := result.Results["totals"]
+	if len(totals) != 1 || totals[0].Calls != 3 || totals[0].TotalCost != 3.5 {
+		t.Errorf("totals = %+v, want 3 calls costing 3.5", totals)
+	}
+
+	var byModelCost float64
+	for _, summary := range result.Results["by_model"] {
+		byModelCost += summary.TotalCost
+	}
+	if byModelCost != totals[0].TotalCost {
+		t.Errorf("by_model adds up to %v, want the totals' %v", byModelCost, totals[0].TotalCost)
+	}
+
+	if daily := result.Results["openai_daily"]; len(daily) != 2 {
+		t.Errorf("openai_daily = %+v, want two days", daily)
+	}
+	if top := result.Results["top_model"]; len(top) != 1 {
+		t.Errorf("top_model = %+v, want the limit applied to summaries", top)
+	}
+
+	if _, err := tracker.QueryBundle(AggregationQuery{Name: "a"}, AggregationQuery{Name: "a"}); err == nil {
+		t.Error("QueryBundle() with duplicate names should fail")
+	}
+}
+
+func TestSnapshotFilter(t *testing.T) {
+	base := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	filter := snapshotFilter([]AggregationQuery{
+		{Filter: UsageFilter{Start: base, End: base.AddDate(0, 0, 1), Provider: "openai", Model: "gpt-4", ProjectID: "proj-1",
+			Tags: map[string]string{"env": "prod", "feature": "search"}}},
+		{Filter: UsageFilter{Start: base.AddDate(0, 0, -1), End: base.AddDate(0, 0, 2), Provider: "openai", ProjectID: "proj-1",
+			UserID: "user-1", Tags: map[string]string{"env": "prod"}}},
+	})
+	if !filter.Start.Equal(base.AddDate(0, 0, -1)) || !filter.End.Equal(base.AddDate(0, 0, 2)) {
+		t.Errorf("period = %v - %v, want the union of the periods", filter.Start, filter.End)
+	}
+	if filter.Provider != "openai" || filter.Model != "" || filter.ProjectID != "proj-1" || filter.UserID != "" {
+		t.Errorf("filter = %+v, want the shared provider and project only", filter)
+	}
+	if len(filter.Tags) != 1 || filter.Tags["env"] != "prod" {
+		t.Errorf("Tags = %v, want the shared tags", filter.Tags)
+	}
+
+	filter = snapshotFilter([]AggregationQuery{
+		{Filter: UsageFilter{Start: base}},
+		{Filter: UsageFilter{End: base}},
+	})
+	if !filter.Start.IsZero() || !filter.End.IsZero() {
+		t.Errorf("filter = %+v, want an unbounded period", filter)
+	}
+}
@@ -0,0 +1,286 @@
+package tokentracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimit is a provider's limit on the tokens and requests per minute of a model,
+// or of all its models without a limit of their own
+type RateLimit struct {
+	Provider          string
+	Model             string // empty limits every model of the provider
+	TokensPerMinute   int    // 0 means unlimited
+	RequestsPerMinute int    // 0 means unlimited
+}
+
+// Reservation is a call admitted by a RateLimiter, or refused until RetryAfter
+type Reservation struct {
+	Provider string
+	Model    string
+
+	// Tokens is the size of the call: its counted input tokens plus MaxTokens, or the
+	// estimated response tokens when the request has no MaxTokens
+	Tokens int
+
+	// RetryAfter is how long until the call fits within the limits, when it was refused
+	RetryAfter time.Duration
+
+	bucket *rateLimitState
+}
+
+// RateLimiter keeps calls within the tokens and requests per minute providers allow,
+// so they are delayed locally instead of failing with 429 responses. Calls are sized
+// with the tracker's token counting, and limits refill continuously over a minute.
+type RateLimiter struct {
+	tracker *DefaultTokenTracker
+	limits  map[rateLimitKey]*rateLimitState
+	mu      sync.Mutex
+}
+
+// rateLimitKey identifies the limit of a provider's model, or of the whole provider
+type rateLimitKey struct {
+	provider string
+	model    string
+}
+
+// rateLimitState holds the tokens and requests left under a limit
+type rateLimitState struct {
+	limit    RateLimit
+	tokens   rateBucket
+	requests rateBucket
+}
+
+// rateBucket is a budget refilling at its per minute limit, up to the limit
+type rateBucket struct {
+	limit     float64
+	available float64
+	updated   time.Time
+}
+
+// NewRateLimiter creates a rate limiter sizing calls with this tracker
+func (t *DefaultTokenTracker) NewRateLimiter(limits ...RateLimit) (*RateLimiter, error) {
+	limiter := &RateLimiter{
+		tracker: t,
+		limits:  make(map[rateLimitKey]*rateLimitState),
+	}
+	for _, limit := range limits {
+		if err := limiter.SetLimit(limit); err != nil {
+			return nil, err
+		}
+	}
+	return limiter, nil
+}
+
+// SetLimit sets or replaces the limit of a provider or model; a limit with neither
+// tokens nor requests removes it. A new limit starts full. A replaced limit keeps the
+// tokens and requests already used under it, and calls reserved under the old limit
+// settle against the new one.
+func (l *RateLimiter) SetLimit(limit RateLimit) error {
+	if limit.Provider == "" {
+		return NewError(ErrInvalidParams, "provider is required", nil)
+	}
+	if limit.TokensPerMinute < 0 || limit.RequestsPerMinute < 0 {
+		return NewError(ErrInvalidParams, "limits must not be negative", nil)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := rateLimitKey{provider: limit.Provider, model: limit.Model}
+	if limit.TokensPerMinute == 0 && limit.RequestsPerMinute == 0 {
+		delete(l.limits, key)
+		return nil
+	}
+	now := l.tracker.clock().Now()
+	if state, exists := l.limits[key]; exists {
+		// Resize in place, as outstanding reservations refer to the state
+		state.limit = limit
+		state.tokens.resize(limit.TokensPerMinute, now)
+		state.requests.resize(limit.RequestsPerMinute, now)
+		return nil
+	}
+	l.limits[key] = &rateLimitState{
+		limit:    limit,
+		tokens:   newRateBucket(limit.TokensPerMinute, now),
+		requests: newRateBucket(limit.RequestsPerMinute, now),
+	}
+	return nil
+}
+
+// Limits returns the configured limits
+func (l *RateLimiter) Limits() []RateLimit {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limits := make([]RateLimit, 0, len(l.limits))
+	for _, state := range l.limits {
+		limits = append(limits, state.limit)
+	}
+	return limits
+}
+
+// Reserve admits a call within its model's limits, or refuses it with an
+// ErrRateLimited error and a reservation whose RetryAfter says how long to wait.
+// Calls of models without a limit are always admitted.
+func (l *RateLimiter) Reserve(params TokenCountParams) (Reservation, error) {
+	return l.ReserveCtx(context.Background(), params)
+}
+
+// ReserveCtx admits a call like Reserve, honoring cancellation of ctx while the call
+// is counted
+func (l *RateLimiter) ReserveCtx(ctx context.Context, params TokenCountParams) (Reservation, error) {
+	reservation, err := l.size(ctx, params)
+	if err != nil {
+		return Reservation{}, err
+	}
+	return reservation, l.reserve(&reservation)
+}
+
+// Wait admits a call within its model's limits, blocking until it fits or ctx is done
+func (l *RateLimiter) Wait(ctx context.Context, params TokenCountParams) (Reservation, error) {
+	reservation, err := l.size(ctx, params)
+	if err != nil {
+		return Reservation{}, err
+	}
+
+	for {
+		err := l.reserve(&reservation)
+		var trackerErr *TokenTrackerError
+		if err == nil || !errors.As(err, &trackerErr) || trackerErr.Type != ErrRateLimited {
+			return reservation, err
+		}
+
+		ready := make(chan struct{})
+		timer := l.tracker.clock().AfterFunc(reservation.RetryAfter, func() { close(ready) })
+		select {
+		case <-ready:
+		case <-ctx.Done():
+			timer.Stop()
+			return reservation, ctx.Err()
+		}
+	}
+}
+
+// Settle corrects the tokens taken by an admitted call once its actual usage is
+// known: tokens reserved but not used are returned, and tokens used beyond the
+// reservation are taken
+func (l *RateLimiter) Settle(reservation Reservation, actualTokens int) {
+	if reservation.bucket == nil || reservation.RetryAfter > 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket := &reservation.bucket.tokens
+	if bucket.limit > 0 {
+		bucket.refill(l.tracker.clock().Now())
+		bucket.available = math.Min(bucket.limit, bucket.available+float64(reservation.Tokens-actualTokens))
+	}
+}
+
+// size counts the tokens of a call and finds the limit it falls under
+func (l *RateLimiter) size(ctx context.Context, params TokenCountParams) (Reservation, error) {
+	if params.Model == "" {
+		return Reservation{}, NewError(ErrInvalidParams, "model is required", nil)
+	}
+	provider, exists := l.tracker.providerForModel(params.Model)
+	if !exists {
+		return Reservation{}, NewError(ErrProviderNotFound, fmt.Sprintf("no provider found for model: %s", params.Model), nil)
+	}
+
+	reservation := Reservation{Provider: provider.Name(), Model: params.Model}
+
+	l.mu.Lock()
+	state, exists := l.limits[rateLimitKey{provider: reservation.Provider, model: params.Model}]
+	if !exists {
+		state = l.limits[rateLimitKey{provider: reservation.Provider}]
+	}
+	l.mu.Unlock()
+	if state == nil {
+		return reservation, nil
+	}
+	reservation.bucket = state
+
+	if state.limit.TokensPerMinute > 0 {
+		params.CountResponseTokens = params.MaxTokens == 0
+		count, err := l.tracker.CountTokensCtx(ctx, params)
+		if err != nil {
+			return Reservation{}, err
+		}
+		reservation.Tokens = count.InputTokens + count.ResponseTokens + params.MaxTokens
+		if reservation.Tokens > state.limit.TokensPerMinute {
+			return Reservation{}, NewError(ErrInvalidParams, fmt.Sprintf("call of %d tokens exceeds the limit of %d tokens per minute", reservation.Tokens, state.limit.TokensPerMinute), nil)
+		}
+	}
+	return reservation, nil
+}
+
+// reserve takes a sized call from its limit's budgets, or sets how long until it fits
+func (l *RateLimiter) reserve(reservation *Reservation) error {
+	state := reservation.bucket
+	if state == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.tracker.clock().Now()
+	state.tokens.refill(now)
+	state.requests.refill(now)
+
+	reservation.RetryAfter = max(state.tokens.wait(float64(reservation.Tokens)), state.requests.wait(1))
+	if reservation.RetryAfter > 0 {
+		return NewError(ErrRateLimited, fmt.Sprintf("rate limit of %s reached, retry after %s", reservation.Model, reservation.RetryAfter), nil)
+	}
+	state.tokens.take(float64(reservation.Tokens))
+	state.requests.take(1)
+	return nil
+}
+
+// newRateBucket creates a full budget of limit per minute (0 means unlimited)
+func newRateBucket(limit int, now time.Time) rateBucket {
+	return rateBucket{limit: float64(limit), available: float64(limit), updated: now}
+}
+
+// refill adds the budget accrued since the last update
+func (b *rateBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.available = math.Min(b.limit, b.available+b.limit*elapsed.Minutes())
+		b.updated = now
+	}
+}
+
+// resize changes the limit per minute, keeping the budget already spent. A budget that
+// was unlimited starts full.
+func (b *rateBucket) resize(limit int, now time.Time) {
+	if b.limit == 0 {
+		*b = newRateBucket(limit, now)
+		return
+	}
+	b.refill(now)
+	spent := b.limit - b.available
+	b.limit = float64(limit)
+	b.available = b.limit - spent
+}
+
+// wait returns how long until n fits in the budget
+func (b *rateBucket) wait(n float64) time.Duration {
+	if b.limit == 0 || b.available >= n {
+		return 0
+	}
+	return time.Duration(math.Ceil((n - b.available) / b.limit * float64(time.Minute)))
+}
+
+// take spends n of the budget
+func (b *rateBucket) take(n float64) {
+	if b.limit > 0 {
+		b.available -= n
+	}
+}
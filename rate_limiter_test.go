@@ -0,0 +1,171 @@
+package tokentracker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker/tokentrackertest"
+)
+
+// newRateLimitedTracker creates a tracker on a fake clock whose acme-1 calls are 1000
+// input tokens with a 200 token response estimate
+func newRateLimitedTracker(t *testing.T) (*DefaultTokenTracker, *tokentrackertest.FakeClock) {
+	clock := tokentrackertest.NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	tracker := newPricedTestTracker(t, WithClock(clock))
+	tracker.RegisterProvider(&estimatingProvider{
+		usagePricedProvider: newPricedProvider(tracker.Config(), "acme", "acme-1", "acme-2"),
+		count:               TokenCount{InputTokens: 1000, ResponseTokens: 200},
+	})
+	return tracker, clock
+}
+
+func TestRateLimiter_Reserve(t *testing.T) {
+	tracker, clock := newRateLimitedTracker(t)
+	limiter, err := tracker.NewRateLimiter(
+		RateLimit{Provider: "acme", TokensPerMinute: 3000},
+		RateLimit{Provider: "acme", Model: "acme-2", RequestsPerMinute: 2},
+	)
+	if err != nil {
+		t.Fatalf("NewRateLimiter() error = %v", err)
+	}
+	params := TokenCountParams{Model: "acme-1", Text: stringPtr("Hi")}
+
+	// Calls are sized with their input and response estimate
+	for i := 0; i < 2; i++ {
+		reservation, err := limiter.Reserve(params)
+		if err != nil {
+			t.Fatalf("Reserve() #%d error = %v", i, err)
+		}
+		if reservation.Tokens != 1200 || reservation.Provider != "acme" || reservation.RetryAfter != 0 {
+			t.Errorf("Reserve() = %+v, want 1200 tokens admitted", reservation)
+		}
+	}
+
+	reservation, err := limiter.Reserve(params)
+	var trackerErr *TokenTrackerError
+	if !errors.As(err, &trackerErr) || trackerErr.Type != ErrRateLimited {
+		t.Fatalf("Reserve() error = %v, want %s", err, ErrRateLimited)
+	}
+	// 600 tokens are left and 600 more refill in 12 seconds
+	if reservation.RetryAfter != 12*time.Second {
+		t.Errorf("RetryAfter = %v, want 12s", reservation.RetryAfter)
+	}
+
+	clock.Advance(12 * time.Second)
+	if _, err := limiter.Reserve(params); err != nil {
+		t.Errorf("Reserve() after RetryAfter error = %v", err)
+	}
+
+	// MaxTokens sizes the response instead of the estimate
+	clock.Advance(time.Minute)
+	if reservation, _ := limiter.Reserve(TokenCountParams{Model: "acme-1", Text: stringPtr("Hi"), MaxTokens: 50}); reservation.Tokens != 1050 {
+		t.Errorf("Tokens = %d with MaxTokens, want 1050", reservation.Tokens)
+	}
+	if _, err := limiter.Reserve(TokenCountParams{Model: "acme-1", Text: stringPtr("Hi"), MaxTokens: 5000}); err == nil {
+		t.Error("Reserve() of a call above the limit should fail")
+	}
+
+	// The model's own limit replaces the provider's
+	for i := 0; i < 2; i++ {
+		if _, err := limiter.Reserve(TokenCountParams{Model: "acme-2", Text: stringPtr("Hi")}); err != nil {
+			t.Fatalf("Reserve() error = %v", err)
+		}
+	}
+	if reservation, err := limiter.Reserve(TokenCountParams{Model: "acme-2", Text: stringPtr("Hi")}); err == nil || reservation.RetryAfter != 30*time.Second {
+		t.Errorf("Reserve() = %+v, %v, want a retry after 30s", reservation, err)
+	}
+}
+
+func TestRateLimiter_WaitAndSettle(t *testing.T) {
+	tracker, clock := newRateLimitedTracker(t)
+	limiter, _ := tracker.NewRateLimiter(RateLimit{Provider: "acme", TokensPerMinute: 1200})
+	params := TokenCountParams{Model: "acme-1", Text: stringPtr("Hi")}
+
+	first, err := limiter.Wait(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	// The call used less than reserved, so the rest is returned
+	limiter.Settle(first, 600)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := limiter.Wait(context.Background(), params)
+		done <- err
+	}()
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(30 * time.Second)
+	if err := <-done; err != nil {
+		t.Errorf("Wait() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := limiter.Wait(ctx, params); !errors.Is(err, context.Canceled) {
+		t.Errorf("Wait() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestRateLimiter_SetLimitKeepsUsage(t *testing.T) {
+	tracker, _ := newRateLimitedTracker(t)
+	limiter, _ := tracker.NewRateLimiter(RateLimit{Provider: "acme", TokensPerMinute: 3000})
+	params := TokenCountParams{Model: "acme-1", Text: stringPtr("Hi")}
+
+	first, _ := limiter.Reserve(params)
+	if _, err := limiter.Reserve(params); err != nil {
+		t.Fatalf("Reserve() error = %v", err)
+	}
+
+	// Lowering the limit keeps the 2400 tokens already taken
+	_ = limiter.SetLimit(RateLimit{Provider: "acme", TokensPerMinute: 2400})
+	if refused, err := limiter.Reserve(params); err == nil || refused.RetryAfter != 30*time.Second {
+		t.Errorf("Reserve() after lowering the limit = %+v, %v, want a 30s wait", refused, err)
+	}
+
+	// A reservation made under the old limit settles against the new one
+	limiter.Settle(first, 0)
+	if _, err := limiter.Reserve(params); err != nil {
+		t.Errorf("Reserve() after settling error = %v", err)
+	}
+
+	// Raising the limit adds its headroom to what is left
+	_ = limiter.SetLimit(RateLimit{Provider: "acme", TokensPerMinute: 4800})
+	for i := 0; i < 2; i++ {
+		if _, err := limiter.Reserve(params); err != nil {
+			t.Errorf("Reserve() %d after raising the limit error = %v", i, err)
+		}
+	}
+	if _, err := limiter.Reserve(params); err == nil {
+		t.Error("Reserve() beyond the raised limit should be refused")
+	}
+}
+
+func TestRateLimiter_Limits(t *testing.T) {
+	tracker, _ := newRateLimitedTracker(t)
+	if _, err := tracker.NewRateLimiter(RateLimit{TokensPerMinute: 10}); err == nil {
+		t.Error("NewRateLimiter() without a provider should fail")
+	}
+
+	limiter, _ := tracker.NewRateLimiter(RateLimit{Provider: "acme", RequestsPerMinute: 1})
+	if len(limiter.Limits()) != 1 {
+		t.Errorf("Limits() = %v, want 1", limiter.Limits())
+	}
+	_ = limiter.SetLimit(RateLimit{Provider: "acme"})
+	if len(limiter.Limits()) != 0 {
+		t.Errorf("Limits() = %v after removing the limit", limiter.Limits())
+	}
+
+	// Models without a limit are admitted without counting
+	for i := 0; i < 3; i++ {
+		if _, err := limiter.Reserve(TokenCountParams{Model: "acme-1"}); err != nil {
+			t.Errorf("Reserve() without a limit error = %v", err)
+		}
+	}
+	if _, err := limiter.Reserve(TokenCountParams{Model: "unknown"}); err == nil {
+		t.Error("Reserve() of an unknown model should fail")
+	}
+}
@@ -0,0 +1,239 @@
+package tokentracker
+
+import (
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// DefaultReconcileTolerance is the relative difference within which tracked and billed
+// usage match
+const DefaultReconcileTolerance = 0.01
+
+// reconcileEpsilon absorbs floating point error in summed costs
+const reconcileEpsilon = 1e-9
+
+// ReconcileOptions controls how tracked usage is compared with billed usage
+type ReconcileOptions struct {
+	// Location is the time zone days are cut in, usually that of the export (nil means UTC)
+	Location *time.Location
+
+	// Tolerance is the relative difference of tokens and costs within which the usage
+	// of a day matches, e.g. 0.01 for 1% (0 uses DefaultReconcileTolerance)
+	Tolerance float64
+
+	// ByAPIKey compares the usage of each API key separately
+	ByAPIKey bool
+
+	// APIKeyMap maps the key identifiers of the export to the APIKeyIDs of tracked
+	// usage, e.g. OpenAI's "key_abc123" to "sk-...abcd". Exports identify keys by IDs
+	// that cannot be derived from the keys, so keys only match per key when mapped.
+	APIKeyMap map[string]string
+
+	// ModelMap maps the models of the export to tracked models, e.g. the snapshot
+	// "gpt-4o-2024-08-06" to "gpt-4o"
+	ModelMap map[string]string
+}
+
+// ReconciliationTotals are the usage totals of one side of a reconciliation
+type ReconciliationTotals struct {
+	Records        int     `json:"records"`
+	InputTokens    int     `json:"input_tokens"`
+	ResponseTokens int     `json:"response_tokens"`
+	TotalTokens    int     `json:"total_tokens"`
+	Cost           float64 `json:"cost"`
+}
+
+// add adds a usage record to the totals, scaling sampled records
+func (t *ReconciliationTotals) add(metrics UsageMetrics) {
+	weight := metrics.SampleWeight()
+	t.Records++
+	t.InputTokens += weight * metrics.TokenCount.InputTokens
+	t.ResponseTokens += weight * metrics.TokenCount.ResponseTokens
+	t.TotalTokens += weight * metrics.TokenCount.TotalTokens
+	t.Cost += float64(weight) * metrics.Price.TotalCost
+}
+
+// ReconciliationLine compares the tracked and billed usage of a model on a day
+type ReconciliationLine struct {
+	Day      time.Time `json:"day"`
+	Provider string    `json:"provider"`
+	Model    string    `json:"model"`
+	APIKeyID string    `json:"api_key_id,omitempty"` // set with ByAPIKey
+	Currency string    `json:"currency"`
+
+	Tracked ReconciliationTotals `json:"tracked"`
+	Billed  ReconciliationTotals `json:"billed"`
+
+	TokenDifference int     `json:"token_difference"` // billed minus tracked tokens
+	CostDifference  float64 `json:"cost_difference"`  // billed minus tracked cost
+
+	// Matched reports whether the tokens and costs are within the tolerance
+	Matched bool `json:"matched"`
+}
+
+// ReconciliationReport is the comparison of tracked usage with billed usage
+type ReconciliationReport struct {
+	Start time.Time `json:"start"` // first day compared
+	End   time.Time `json:"end"`   // day after the last day compared
+
+	// Lines are ordered by day, provider, model and API key
+	Lines []ReconciliationLine `json:"lines"`
+
+	Tracked        ReconciliationTotals `json:"tracked"`
+	Billed         ReconciliationTotals `json:"billed"`
+	CostDifference float64              `json:"cost_difference"` // billed minus tracked cost
+}
+
+// Discrepancies returns the lines whose tracked and billed usage differ
+func (r ReconciliationReport) Discrepancies() []ReconciliationLine {
+	var discrepancies []ReconciliationLine
+	for _, line := range r.Lines {
+		if !line.Matched {
+			discrepancies = append(discrepancies, line)
+		}
+	}
+	return discrepancies
+}
+
+// Matched reports whether the usage of every day and model matches
+func (r ReconciliationReport) Matched() bool {
+	return len(r.Discrepancies()) == 0
+}
+
+// reconcileKey identifies a line while reconciling
+type reconcileKey struct {
+	day      time.Time
+	provider string
+	model    string
+	apiKey   string
+}
+
+// Reconcile compares tracked usage with billed usage, e.g. read with
+// ImportBillingExport, per day and model. Tracked records that were themselves
+// imported from a billing export are ignored. Costs are compared in the currencies
+// they were recorded in.
+func Reconcile(tracked, billed []UsageMetrics, opts ReconcileOptions) (ReconciliationReport, error) {
+	if opts.Tolerance < 0 {
+		return ReconciliationReport{}, NewError(ErrInvalidParams, "reconcile tolerance must not be negative", nil)
+	}
+	if opts.Tolerance == 0 {
+		opts.Tolerance = DefaultReconcileTolerance
+	}
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+
+	var report ReconciliationReport
+	lines := make(map[reconcileKey]*ReconciliationLine)
+	line := func(metrics UsageMetrics, model string) *ReconciliationLine {
+		key := reconcileKey{day: reconcileDay(metrics.Timestamp, opts.Location), provider: metrics.Provider, model: model}
+		if opts.ByAPIKey {
+			key.apiKey = metrics.APIKeyID
+		}
+		l, exists := lines[key]
+		if !exists {
+			l = &ReconciliationLine{Day: key.day, Provider: key.provider, Model: key.model, APIKeyID: key.apiKey}
+			lines[key] = l
+		}
+		if l.Currency == "" {
+			l.Currency = metrics.Price.Currency
+		}
+		return l
+	}
+
+	for _, metrics := range billed {
+		model := metrics.Model
+		if mapped, exists := opts.ModelMap[model]; exists {
+			model = mapped
+		}
+		if mapped, exists := opts.APIKeyMap[metrics.APIKeyID]; exists {
+			metrics.APIKeyID = mapped
+		}
+		line(metrics, model).Billed.add(metrics)
+		report.Billed.add(metrics)
+	}
+	for _, metrics := range tracked {
+		if metrics.Tags[ImportTagSource] != "" {
+			continue
+		}
+		line(metrics, metrics.Model).Tracked.add(metrics)
+		report.Tracked.add(metrics)
+	}
+
+	report.Lines = make([]ReconciliationLine, 0, len(lines))
+	for _, l := range lines {
+		l.TokenDifference = l.Billed.TotalTokens - l.Tracked.TotalTokens
+		l.CostDifference = l.Billed.Cost - l.Tracked.Cost
+		l.Matched = withinTolerance(float64(l.Billed.TotalTokens), float64(l.Tracked.TotalTokens), opts.Tolerance) &&
+			withinTolerance(l.Billed.Cost, l.Tracked.Cost, opts.Tolerance)
+		report.Lines = append(report.Lines, *l)
+	}
+	sort.Slice(report.Lines, func(i, j int) bool {
+		a, b := report.Lines[i], report.Lines[j]
+		if !a.Day.Equal(b.Day) {
+			return a.Day.Before(b.Day)
+		}
+		if a.Provider != b.Provider {
+			return a.Provider < b.Provider
+		}
+		if a.Model != b.Model {
+			return a.Model < b.Model
+		}
+		return a.APIKeyID < b.APIKeyID
+	})
+
+	if len(report.Lines) > 0 {
+		report.Start = report.Lines[0].Day
+		report.End = report.Lines[len(report.Lines)-1].Day.AddDate(0, 0, 1)
+	}
+	report.CostDifference = report.Billed.Cost - report.Tracked.Cost
+	return report, nil
+}
+
+// ReconcileBillingExport compares the stored usage with a provider billing export over
+// the days the export covers, pricing export rows without costs with the tracker's
+// pricing. Only stored usage of the export's provider is compared.
+func (t *DefaultTokenTracker) ReconcileBillingExport(format BillingExportFormat, r io.Reader, opts ReconcileOptions) (ReconciliationReport, error) {
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+
+	billed, err := ImportBillingExport(format, r, BillingImportOptions{Pricer: t, Location: opts.Location})
+	if err != nil {
+		return ReconciliationReport{}, err
+	}
+	if len(billed) == 0 {
+		return Reconcile(nil, nil, opts)
+	}
+
+	filter := UsageFilter{Provider: billed[0].Provider}
+	for _, metrics := range billed {
+		day := reconcileDay(metrics.Timestamp, opts.Location)
+		if filter.Start.IsZero() || day.Before(filter.Start) {
+			filter.Start = day
+		}
+		if end := day.AddDate(0, 0, 1); end.After(filter.End) {
+			filter.End = end
+		}
+	}
+
+	tracked, err := t.GetUsage(filter)
+	if err != nil {
+		return ReconciliationReport{}, err
+	}
+	return Reconcile(tracked, billed, opts)
+}
+
+// reconcileDay returns the start of the day of a timestamp in a time zone
+func reconcileDay(timestamp time.Time, loc *time.Location) time.Time {
+	year, month, day := timestamp.In(loc).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, loc)
+}
+
+// withinTolerance reports whether two values differ by at most tolerance relative to
+// the larger one
+func withinTolerance(a, b, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance*math.Max(math.Abs(a), math.Abs(b))+reconcileEpsilon
+}
@@ -0,0 +1,110 @@
+package tokentracker
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func reconcileUsage(day int, model, apiKey string, tokens int, cost float64) UsageMetrics {
+	return UsageMetrics{
+		Timestamp:  time.Date(2024, 3, day, 15, 0, 0, 0, time.UTC),
+		Provider:   "openai",
+		Model:      model,
+		APIKeyID:   apiKey,
+		TokenCount: TokenCount{InputTokens: tokens, TotalTokens: tokens},
+		Price:      Price{TotalCost: cost, Currency: "USD"},
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	billed := []UsageMetrics{
+		reconcileUsage(1, "gpt-4o-2024-08-06", "key_1", 1000, 0.010),
+		reconcileUsage(1, "gpt-4o-2024-08-06", "key_2", 500, 0.005),
+		reconcileUsage(2, "gpt-4o-2024-08-06", "key_1", 2000, 0.020),
+	}
+	tracked := []UsageMetrics{
+		reconcileUsage(1, "gpt-4o", "key_1", 995, 0.00995),
+		reconcileUsage(1, "gpt-4o", "key_2", 500, 0.005),
+		reconcileUsage(2, "gpt-4o", "key_1", 1000, 0.010),
+	}
+
+	report, err := Reconcile(tracked, billed, ReconcileOptions{ModelMap: map[string]string{"gpt-4o-2024-08-06": "gpt-4o"}})
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if len(report.Lines) != 2 || !report.Lines[0].Matched || report.Lines[0].Tracked.Records != 2 {
+		t.Fatalf("Lines = %+v, want a matching first day within the tolerance", report.Lines)
+	}
+	discrepancies := report.Discrepancies()
+	if len(discrepancies) != 1 || discrepancies[0].TokenDifference != 1000 || discrepancies[0].Day.Day() != 2 {
+		t.Errorf("Discrepancies() = %+v, want the second day under-tracked by 1000 tokens", discrepancies)
+	}
+	if report.Matched() || report.CostDifference < 0.01005 || report.CostDifference > 0.01006 {
+		t.Errorf("report = %+v", report)
+	}
+	if !report.Start.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) || !report.End.Equal(time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("report covers %v to %v", report.Start, report.End)
+	}
+
+	// Per key, the unmapped models of the export do not match the tracked ones
+	report, _ = Reconcile(tracked, billed, ReconcileOptions{ByAPIKey: true})
+	if len(report.Lines) != 6 || report.Lines[0].APIKeyID != "key_1" {
+		t.Errorf("Lines = %+v, want a line per day, model and key", report.Lines)
+	}
+
+	if _, err := Reconcile(nil, nil, ReconcileOptions{Tolerance: -1}); err == nil {
+		t.Error("Reconcile() with a negative tolerance should fail")
+	}
+}
+
+func TestReconcileBillingExport(t *testing.T) {
+	tracker, store := newTransportTracker()
+	for _, record := range []UsageMetrics{
+		reconcileUsage(1, "gpt-4o", "", 1500, 0.015),
+		reconcileUsage(9, "gpt-4o", "", 1500, 0.015), // outside the export
+	} {
+		_ = store.Record(record)
+	}
+	imported := reconcileUsage(1, "gpt-4o", "", 1500, 0.015)
+	imported.Tags = map[string]string{ImportTagSource: string(BillingExportOpenAI)}
+	_ = store.Record(imported)
+
+	export := `start_time_iso,project_id,api_key_id,model,input_tokens,output_tokens,cost
+2024-03-01T00:00:00+00:00,proj_abc,key_1,gpt-4o,1200,300,0.015
+`
+	report, err := tracker.ReconcileBillingExport(BillingExportOpenAI, strings.NewReader(export), ReconcileOptions{})
+	if err != nil {
+		t.Fatalf("ReconcileBillingExport() error = %v", err)
+	}
+	if len(report.Lines) != 1 || !report.Matched() || report.Tracked.Records != 1 {
+		t.Errorf("report = %+v, want the tracked day matching the export", report)
+	}
+}
+
+func TestReconcileBillingExport_ByAPIKey(t *testing.T) {
+	tracker, store := newTransportTracker()
+	keyID := APIKeyID("sk-proj-4fQx7Jm2Lr9TzW1p")
+	_ = store.Record(reconcileUsage(1, "gpt-4o", keyID, 1500, 0.02))
+
+	export := `start_time,end_time,start_time_iso,end_time_iso,project_id,num_model_requests,user_id,api_key_id,model,batch,input_tokens,output_tokens
+1709251200,1709337600,2024-03-01T00:00:00+00:00,2024-03-02T00:00:00+00:00,proj_abc,12,,key_Vn3bX8,gpt-4o,false,1200,300
+`
+	// The export's key ID never equals the tracked identifier of the key
+	report, err := tracker.ReconcileBillingExport(BillingExportOpenAI, strings.NewReader(export), ReconcileOptions{ByAPIKey: true})
+	if err != nil {
+		t.Fatalf("ReconcileBillingExport() error = %v", err)
+	}
+	if len(report.Lines) != 2 || report.Matched() {
+		t.Errorf("report = %+v, want unmatched lines of the tracked and the exported key", report)
+	}
+
+	opts := ReconcileOptions{ByAPIKey: true, APIKeyMap: map[string]string{"key_Vn3bX8": keyID}}
+	report, err = tracker.ReconcileBillingExport(BillingExportOpenAI, strings.NewReader(export), opts)
+	if err != nil {
+		t.Fatalf("ReconcileBillingExport() error = %v", err)
+	}
+	if len(report.Lines) != 1 || !report.Matched() || report.Lines[0].APIKeyID != keyID || report.Lines[0].Billed.TotalTokens != 1500 {
+		t.Errorf("report = %+v, want the mapped key matching its tracked usage", report)
+	}
+}
@@ -0,0 +1,337 @@
+package tokentracker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Default resilience settings
+const (
+	DefaultRetryAttempts       = 3
+	DefaultRetryBackoff        = 100 * time.Millisecond
+	DefaultRetryMaxBackoff     = 2 * time.Second
+	DefaultCallTimeout         = 10 * time.Second
+	DefaultFailureThreshold    = 5
+	DefaultCircuitOpenDuration = 30 * time.Second
+)
+
+// Fallbacks reported in a FallbackWarning
+const (
+	FallbackApproximation = "approximation"  // heuristic token counts
+	FallbackCachedPricing = "cached_pricing" // the last-known-good prices
+)
+
+// ResilienceOptions configures retries, timeouts and circuit breakers of the network
+// calls made for pricing and token counting
+type ResilienceOptions struct {
+	// MaxAttempts is the number of attempts per call, including the first (0 uses the default)
+	MaxAttempts int
+
+	// Backoff is the wait before the first retry, doubled for every further retry up
+	// to MaxBackoff (0 uses the defaults)
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// Timeout limits each attempt (0 uses the default, negative disables)
+	Timeout time.Duration
+
+	// FailureThreshold is the number of consecutive failed attempts that opens the
+	// circuit of a provider (0 uses the default)
+	FailureThreshold int
+
+	// OpenDuration is how long an open circuit rejects calls before one trial call is
+	// let through (0 uses the default)
+	OpenDuration time.Duration
+
+	// Retryable reports whether a failed attempt is retried (nil retries all errors)
+	Retryable func(err error) bool
+
+	// OnFallback receives a warning when a fallback replaces a failed call (nil logs it)
+	OnFallback func(FallbackWarning)
+
+	// Clock times backoffs and open circuits (nil uses the system clock)
+	Clock Clock
+}
+
+// FallbackWarning reports that a call failed and a fallback was used instead
+type FallbackWarning struct {
+	Provider  string
+	Operation string // e.g. "count_tokens" or "update_pricing"
+	Fallback  string // e.g. FallbackApproximation or FallbackCachedPricing
+	Err       error
+}
+
+// CircuitState is the state of the circuit breaker of a provider
+type CircuitState string
+
+// Circuit breaker states
+const (
+	CircuitClosed   CircuitState = "closed"    // calls go through
+	CircuitOpen     CircuitState = "open"      // calls are rejected
+	CircuitHalfOpen CircuitState = "half_open" // one trial call goes through
+)
+
+// Resilience retries failed calls with exponential backoff, limits their duration and
+// keeps a circuit breaker per provider, so a failing endpoint is not called on every
+// request. A nil *Resilience calls once, without timeout or circuit breaker.
+type Resilience struct {
+	opts     ResilienceOptions
+	breakers map[string]*circuitBreaker
+	mu       sync.Mutex
+}
+
+// circuitBreaker counts the consecutive failures of a provider
+type circuitBreaker struct {
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	trial    bool // a half-open trial call is in flight
+}
+
+// NewResilience creates a resilience layer with the given options
+func NewResilience(opts ResilienceOptions) *Resilience {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultRetryAttempts
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = DefaultRetryBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultRetryMaxBackoff
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = DefaultCallTimeout
+	}
+	if opts.FailureThreshold <= 0 {
+		opts.FailureThreshold = DefaultFailureThreshold
+	}
+	if opts.OpenDuration <= 0 {
+		opts.OpenDuration = DefaultCircuitOpenDuration
+	}
+	if opts.Clock == nil {
+		opts.Clock = SystemClock
+	}
+
+	return &Resilience{
+		opts:     opts,
+		breakers: make(map[string]*circuitBreaker),
+	}
+}
+
+// Do calls fn for provider, retrying failed attempts while the circuit of the provider
+// is closed. It fails with an ErrCircuitOpen error without calling fn while the
+// circuit is open, and returns the error of the last attempt otherwise.
+func (r *Resilience) Do(ctx context.Context, provider string, fn func(ctx context.Context) error) error {
+	if r == nil {
+		return fn(ctx)
+	}
+
+	backoff := r.opts.Backoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		if !r.allow(provider) {
+			if err == nil {
+				err = NewError(ErrCircuitOpen, fmt.Sprintf("circuit of %s is open", provider), nil)
+			}
+			return err
+		}
+
+		err = r.attempt(ctx, fn)
+		if ctx.Err() != nil {
+			// The caller gave up; that says nothing about the provider
+			r.release(provider)
+			return err
+		}
+		r.record(provider, err == nil)
+		if err == nil || attempt >= r.opts.MaxAttempts {
+			return err
+		}
+		if r.opts.Retryable != nil && !r.opts.Retryable(err) {
+			return err
+		}
+
+		if err := r.sleep(ctx, backoff); err != nil {
+			return err
+		}
+		backoff *= 2
+		if backoff > r.opts.MaxBackoff {
+			backoff = r.opts.MaxBackoff
+		}
+	}
+}
+
+// attempt calls fn once, limited by the timeout
+func (r *Resilience) attempt(ctx context.Context, fn func(ctx context.Context) error) error {
+	if r.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.opts.Timeout)
+		defer cancel()
+	}
+	return fn(ctx)
+}
+
+// sleep waits for d on the clock, or until ctx is done
+func (r *Resilience) sleep(ctx context.Context, d time.Duration) error {
+	elapsed := make(chan struct{})
+	timer := r.opts.Clock.AfterFunc(d, func() { close(elapsed) })
+	defer timer.Stop()
+
+	select {
+	case <-elapsed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// allow reports whether a call to provider may go through, moving an open circuit
+// to half-open once its open duration has passed
+func (r *Resilience) allow(provider string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	breaker := r.breaker(provider)
+	switch breaker.state {
+	case CircuitOpen:
+		if r.opts.Clock.Since(breaker.openedAt) < r.opts.OpenDuration {
+			return false
+		}
+		breaker.state = CircuitHalfOpen
+		breaker.trial = true
+		return true
+	case CircuitHalfOpen:
+		if breaker.trial {
+			return false
+		}
+		breaker.trial = true
+		return true
+	}
+	return true
+}
+
+// record updates the circuit of provider with the outcome of an attempt
+func (r *Resilience) record(provider string, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	breaker := r.breaker(provider)
+	breaker.trial = false
+	if ok {
+		breaker.state = CircuitClosed
+		breaker.failures = 0
+		return
+	}
+
+	breaker.failures++
+	if breaker.state == CircuitHalfOpen || breaker.failures >= r.opts.FailureThreshold {
+		breaker.state = CircuitOpen
+		breaker.openedAt = r.opts.Clock.Now()
+	}
+}
+
+// release ends a trial call without an outcome
+func (r *Resilience) release(provider string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.breaker(provider).trial = false
+}
+
+// breaker returns the circuit breaker of provider; callers must hold r.mu
+func (r *Resilience) breaker(provider string) *circuitBreaker {
+	breaker, exists := r.breakers[provider]
+	if !exists {
+		breaker = &circuitBreaker{state: CircuitClosed}
+		r.breakers[provider] = breaker
+	}
+	return breaker
+}
+
+// State returns the state of the circuit of provider
+func (r *Resilience) State(provider string) CircuitState {
+	if r == nil {
+		return CircuitClosed
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	breaker, exists := r.breakers[provider]
+	if !exists {
+		return CircuitClosed
+	}
+	if breaker.state == CircuitOpen && r.opts.Clock.Since(breaker.openedAt) >= r.opts.OpenDuration {
+		return CircuitHalfOpen
+	}
+	return breaker.state
+}
+
+// OpenCircuits returns the providers whose circuit is open, sorted
+func (r *Resilience) OpenCircuits() []string {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	providers := make([]string, 0, len(r.breakers))
+	for provider := range r.breakers {
+		providers = append(providers, provider)
+	}
+	r.mu.Unlock()
+
+	var open []string
+	for _, provider := range providers {
+		if r.State(provider) == CircuitOpen {
+			open = append(open, provider)
+		}
+	}
+	sort.Strings(open)
+	return open
+}
+
+// Fallback reports that a fallback replaced a failed call
+func (r *Resilience) Fallback(warning FallbackWarning) {
+	if r == nil {
+		return
+	}
+	if r.opts.OnFallback != nil {
+		r.opts.OnFallback(warning)
+		return
+	}
+	slog.Default().Warn("call failed, using fallback",
+		"provider", warning.Provider, "operation", warning.Operation, "fallback", warning.Fallback, "error", warning.Err)
+}
+
+// SetResilience sets the resilience layer of the network calls made by providers
+// using this configuration (nil calls once, without retries)
+func (c *Config) SetResilience(resilience *Resilience) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.resilience = resilience
+}
+
+// GetResilience returns the resilience layer of providers using this configuration
+func (c *Config) GetResilience() *Resilience {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.resilience
+}
+
+// WithResilience makes the tracker and its providers retry failed pricing and token
+// counting calls, limit their duration and stop calling failing providers for a while.
+// Failed token counts fall back to approximations and failed pricing updates keep the
+// last-known-good prices, each reported to opts.OnFallback.
+func WithResilience(opts ResilienceOptions) TrackerOption {
+	return func(t *DefaultTokenTracker) {
+		if opts.Clock == nil {
+			opts.Clock = configClock{t.config}
+		}
+		t.config.SetResilience(NewResilience(opts))
+	}
+}
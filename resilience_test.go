@@ -0,0 +1,137 @@
+package tokentracker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker/tokentrackertest"
+)
+
+func TestResilience_Retry(t *testing.T) {
+	resilience := NewResilience(ResilienceOptions{MaxAttempts: 3, Backoff: time.Millisecond})
+
+	calls := 0
+	err := resilience.Do(context.Background(), "acme", func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return errors.New("unavailable")
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("Do() = %v after %d calls, want success on the third attempt", err, calls)
+	}
+
+	calls = 0
+	permanent := errors.New("bad request")
+	resilience = NewResilience(ResilienceOptions{
+		Backoff:   time.Millisecond,
+		Retryable: func(err error) bool { return err != permanent },
+	})
+	if err := resilience.Do(context.Background(), "acme", func(ctx context.Context) error {
+		calls++
+		return permanent
+	}); err != permanent || calls != 1 {
+		t.Errorf("Do() = %v after %d calls, want no retries of permanent errors", err, calls)
+	}
+
+	var nilResilience *Resilience
+	if err := nilResilience.Do(context.Background(), "acme", func(ctx context.Context) error { return permanent }); err != permanent {
+		t.Errorf("Do() of a nil Resilience = %v, want a single call", err)
+	}
+}
+
+func TestResilience_Timeout(t *testing.T) {
+	resilience := NewResilience(ResilienceOptions{MaxAttempts: 1, Timeout: 10 * time.Millisecond})
+
+	err := resilience.Do(context.Background(), "acme", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Do() = %v, want the attempt to time out", err)
+	}
+}
+
+func TestResilience_CircuitBreaker(t *testing.T) {
+	clock := tokentrackertest.NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	resilience := NewResilience(ResilienceOptions{
+		MaxAttempts:      1,
+		FailureThreshold: 2,
+		OpenDuration:     time.Minute,
+		Clock:            clock,
+	})
+
+	calls := 0
+	failing := func(ctx context.Context) error {
+		calls++
+		return errors.New("unavailable")
+	}
+	_ = resilience.Do(context.Background(), "acme", failing)
+	_ = resilience.Do(context.Background(), "acme", failing)
+	if state := resilience.State("acme"); state != CircuitOpen {
+		t.Fatalf("State() = %s, want open after 2 failures", state)
+	}
+
+	var trackerErr *TokenTrackerError
+	err := resilience.Do(context.Background(), "acme", failing)
+	if !errors.As(err, &trackerErr) || trackerErr.Type != ErrCircuitOpen || calls != 2 {
+		t.Errorf("Do() with an open circuit = %v after %d calls, want %s without a call", err, calls, ErrCircuitOpen)
+	}
+	if open := resilience.OpenCircuits(); len(open) != 1 || open[0] != "acme" {
+		t.Errorf("OpenCircuits() = %v", open)
+	}
+	if state := resilience.State("other"); state != CircuitClosed {
+		t.Errorf("State() of another provider = %s, want closed", state)
+	}
+
+	// After the open duration a failed trial call opens the circuit again
+	clock.Advance(time.Minute)
+	if state := resilience.State("acme"); state != CircuitHalfOpen {
+		t.Errorf("State() = %s, want half-open", state)
+	}
+	_ = resilience.Do(context.Background(), "acme", failing)
+	if state := resilience.State("acme"); state != CircuitOpen || calls != 3 {
+		t.Errorf("State() = %s after %d calls, want open after the failed trial", state, calls)
+	}
+
+	// A successful trial call closes it
+	clock.Advance(time.Minute)
+	if err := resilience.Do(context.Background(), "acme", func(ctx context.Context) error { return nil }); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if state := resilience.State("acme"); state != CircuitClosed {
+		t.Errorf("State() = %s, want closed after a successful trial", state)
+	}
+}
+
+func TestDefaultTokenTracker_PricingSourceResilience(t *testing.T) {
+	var warnings []FallbackWarning
+	calls := 0
+	source := PricingSourceFunc(func(ctx context.Context) (PricingManifest, error) {
+		calls++
+		return PricingManifest{}, errors.New("feed unavailable")
+	})
+	tracker := NewTokenTracker(NewConfig(), WithPricingSource(source), WithResilience(ResilienceOptions{
+		MaxAttempts:      2,
+		Backoff:          time.Millisecond,
+		FailureThreshold: 2,
+		OnFallback:       func(w FallbackWarning) { warnings = append(warnings, w) },
+		Clock:            SystemClock,
+	}))
+
+	if err := tracker.UpdateAllPricing(); err == nil {
+		t.Fatal("UpdateAllPricing() should report the failed update")
+	}
+	if calls != 2 {
+		t.Errorf("pricing source called %d times, want a retry", calls)
+	}
+	if len(warnings) != 1 || warnings[0].Provider != PricingSourceCircuit || warnings[0].Fallback != FallbackCachedPricing {
+		t.Errorf("warnings = %+v, want the cached pricing reported", warnings)
+	}
+	if open := tracker.Stats().OpenCircuits; len(open) != 1 || open[0] != PricingSourceCircuit {
+		t.Errorf("Stats().OpenCircuits = %v, want the pricing source", open)
+	}
+}
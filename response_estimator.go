@@ -0,0 +1,169 @@
+package tokentracker
+
+import (
+	"sync"
+)
+
+// Defaults of HistoricalEstimator
+const (
+	DefaultEstimatorSmoothing  = 0.05
+	DefaultEstimatorMinSamples = 10
+)
+
+// ResponseEstimator estimates the response tokens of a request before it is made.
+// Providers estimate with the estimator of their configuration when
+// TokenCountParams.CountResponseTokens is set.
+type ResponseEstimator interface {
+	EstimateResponseTokens(params TokenCountParams, inputTokens int) int
+}
+
+// ResponseObserver is implemented by response estimators learning from the usage of
+// tracked calls. The tracker reports every call whose usage the provider reported.
+type ResponseObserver interface {
+	ObserveResponse(params TokenCountParams, count TokenCount)
+}
+
+// HistoricalEstimator estimates response tokens from the rolling ratio of output to
+// input tokens of each model's tracked calls. Requests with stop sequences, which
+// tend to end early, keep a history of their own. Until a model has MinSamples calls
+// the estimate falls back to EstimateResponseTokens. Estimates never exceed the
+// request's MaxTokens.
+type HistoricalEstimator struct {
+	// Smoothing is the weight of a new call in the moving average ratio, between 0
+	// and 1 (0 uses DefaultEstimatorSmoothing)
+	Smoothing float64
+
+	// MinSamples is the number of calls of a model before its history is used (0 uses
+	// DefaultEstimatorMinSamples)
+	MinSamples int
+
+	history map[responseHistoryKey]*responseHistory
+	mu      sync.RWMutex
+}
+
+// responseHistoryKey identifies the history of a model's requests with or without
+// stop sequences
+type responseHistoryKey struct {
+	model string
+	stop  bool
+}
+
+// responseHistory is the moving average output to input ratio of a model's calls
+type responseHistory struct {
+	ratio   float64
+	samples int
+}
+
+// NewHistoricalEstimator creates an estimator without history
+func NewHistoricalEstimator() *HistoricalEstimator {
+	return &HistoricalEstimator{}
+}
+
+// EstimateResponseTokens estimates the response tokens of a request from the history
+// of its model
+func (e *HistoricalEstimator) EstimateResponseTokens(params TokenCountParams, inputTokens int) int {
+	estimate := EstimateResponseTokens(params.Model, inputTokens)
+	if ratio, exists := e.Ratio(params.Model, len(params.StopSequences) > 0); exists {
+		estimate = int(ratio*float64(inputTokens) + 0.5)
+	}
+	if params.MaxTokens > 0 && estimate > params.MaxTokens {
+		estimate = params.MaxTokens
+	}
+	return estimate
+}
+
+// ObserveResponse records the output to input ratio of a tracked call
+func (e *HistoricalEstimator) ObserveResponse(params TokenCountParams, count TokenCount) {
+	if params.Model == "" || count.InputTokens <= 0 {
+		return
+	}
+	ratio := float64(count.ResponseTokens) / float64(count.InputTokens)
+	smoothing := e.Smoothing
+	if smoothing <= 0 || smoothing > 1 {
+		smoothing = DefaultEstimatorSmoothing
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.history == nil {
+		e.history = make(map[responseHistoryKey]*responseHistory)
+	}
+	key := responseHistoryKey{model: params.Model, stop: len(params.StopSequences) > 0}
+	history, exists := e.history[key]
+	if !exists {
+		e.history[key] = &responseHistory{ratio: ratio, samples: 1}
+		return
+	}
+	history.ratio += smoothing * (ratio - history.ratio)
+	history.samples++
+}
+
+// Ratio returns the moving average output to input ratio of a model's calls with or
+// without stop sequences, falling back to all of the model's calls, and false until
+// there are MinSamples of them
+func (e *HistoricalEstimator) Ratio(model string, stop bool) (float64, bool) {
+	minSamples := e.MinSamples
+	if minSamples <= 0 {
+		minSamples = DefaultEstimatorMinSamples
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if history, exists := e.history[responseHistoryKey{model: model, stop: stop}]; exists && history.samples >= minSamples {
+		return history.ratio, true
+	}
+	other, exists := e.history[responseHistoryKey{model: model, stop: !stop}]
+	if stop && exists && other.samples >= minSamples {
+		return other.ratio, true
+	}
+	return 0, false
+}
+
+// SetResponseEstimator sets the estimator providers using this configuration estimate
+// response tokens with
+func (c *Config) SetResponseEstimator(estimator ResponseEstimator) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.responseEstimator = estimator
+}
+
+// GetResponseEstimator returns the response estimator of this configuration, by
+// default a HistoricalEstimator shared by the tracker and providers using it
+func (c *Config) GetResponseEstimator() ResponseEstimator {
+	c.mu.RLock()
+	estimator := c.responseEstimator
+	c.mu.RUnlock()
+	if estimator != nil {
+		return estimator
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.responseEstimator == nil {
+		c.responseEstimator = NewHistoricalEstimator()
+	}
+	return c.responseEstimator
+}
+
+// WithResponseEstimator makes the tracker's providers estimate response tokens with
+// estimator
+func WithResponseEstimator(estimator ResponseEstimator) TrackerOption {
+	return func(t *DefaultTokenTracker) {
+		t.config.SetResponseEstimator(estimator)
+	}
+}
+
+// observeResponse reports the reported usage of a tracked call to the response
+// estimator when it learns from tracked calls
+func (t *DefaultTokenTracker) observeResponse(callParams CallParams, count TokenCount) {
+	observer, ok := t.config.GetResponseEstimator().(ResponseObserver)
+	if !ok {
+		return
+	}
+	params := callParams.Params
+	params.Model = callParams.Model
+	observer.ObserveResponse(params, count)
+}
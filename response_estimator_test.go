@@ -0,0 +1,78 @@
+package tokentracker
+
+import (
+	"context"
+	"testing"
+)
+
+func TestHistoricalEstimator(t *testing.T) {
+	estimator := &HistoricalEstimator{MinSamples: 2, Smoothing: 0.5}
+	params := TokenCountParams{Model: "claude-3-opus"}
+
+	// Without history the built-in multipliers are used
+	if got := estimator.EstimateResponseTokens(params, 100); got != 200 {
+		t.Errorf("EstimateResponseTokens() without history = %d, want 200", got)
+	}
+
+	estimator.ObserveResponse(params, TokenCount{InputTokens: 1000, ResponseTokens: 300})
+	if got := estimator.EstimateResponseTokens(params, 100); got != 200 {
+		t.Errorf("EstimateResponseTokens() before MinSamples = %d, want 200", got)
+	}
+	estimator.ObserveResponse(params, TokenCount{InputTokens: 1000, ResponseTokens: 100})
+	if got := estimator.EstimateResponseTokens(params, 100); got != 20 {
+		t.Errorf("EstimateResponseTokens() with history = %d, want 20", got)
+	}
+
+	// max_tokens caps the estimate
+	capped := params
+	capped.MaxTokens = 15
+	if got := estimator.EstimateResponseTokens(capped, 100); got != 15 {
+		t.Errorf("EstimateResponseTokens() with MaxTokens = %d, want 15", got)
+	}
+	capped.Model = "gpt-4"
+	if got := estimator.EstimateResponseTokens(capped, 1000); got != 15 {
+		t.Errorf("EstimateResponseTokens() of a model without history = %d, want MaxTokens", got)
+	}
+
+	// Requests with stop sequences use the model's history until they have their own
+	stop := params
+	stop.StopSequences = []string{"\n\n"}
+	if got := estimator.EstimateResponseTokens(stop, 100); got != 20 {
+		t.Errorf("EstimateResponseTokens() with stop sequences = %d, want the model's history", got)
+	}
+	estimator.ObserveResponse(stop, TokenCount{InputTokens: 1000, ResponseTokens: 50})
+	estimator.ObserveResponse(stop, TokenCount{InputTokens: 1000, ResponseTokens: 50})
+	if got := estimator.EstimateResponseTokens(stop, 100); got != 5 {
+		t.Errorf("EstimateResponseTokens() with stop sequences = %d, want 5", got)
+	}
+	if ratio, _ := estimator.Ratio("claude-3-opus", false); ratio != 0.2 {
+		t.Errorf("Ratio() without stop sequences = %v, want 0.2", ratio)
+	}
+}
+
+func TestDefaultTokenTracker_ObservesResponses(t *testing.T) {
+	estimator := &HistoricalEstimator{MinSamples: 2}
+	tracker := newPricedTestTracker(t, WithResponseEstimator(estimator))
+	config := tracker.Config()
+	config.SetModelPricing("acme", "acme-1", ModelPricing{InputPricePerToken: 0.00001, Currency: "USD"})
+	tracker.RegisterProvider(&reportingProvider{newPricedProvider(config, "acme", "acme-1")})
+
+	if config.GetResponseEstimator() != estimator {
+		t.Fatal("GetResponseEstimator() does not return the configured estimator")
+	}
+
+	call := CallParams{Model: "acme-1"}
+	if _, err := tracker.TrackUsage(call, TokenCount{InputTokens: 1000, ResponseTokens: 400}); err != nil {
+		t.Fatalf("TrackUsage() error = %v", err)
+	}
+	if _, err := tracker.TrackReportedUsage(context.Background(), call, TokenCount{InputTokens: 1000, ResponseTokens: 400}); err != nil {
+		t.Fatalf("TrackReportedUsage() error = %v", err)
+	}
+	if ratio, exists := estimator.Ratio("acme-1", false); !exists || ratio != 0.4 {
+		t.Errorf("Ratio() = %v, %v, want 0.4 from the tracked calls", ratio, exists)
+	}
+
+	if _, ok := NewConfig().GetResponseEstimator().(*HistoricalEstimator); !ok {
+		t.Error("GetResponseEstimator() default is not a HistoricalEstimator")
+	}
+}
@@ -0,0 +1,115 @@
+package tokentracker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultRetentionPruneInterval is how often usage older than the retention period is pruned
+const DefaultRetentionPruneInterval = time.Hour
+
+// UsagePruner is implemented by usage stores that can delete old usage, as required
+// by retention policies
+type UsagePruner interface {
+	// Prune deletes the usage recorded before a time, returning the number of records deleted
+	Prune(before time.Time) (int64, error)
+}
+
+// RetentionPolicy limits how long usage records are kept
+type RetentionPolicy struct {
+	// MaxAge is how long usage is kept in the usage store and rotated usage log files
+	MaxAge time.Duration
+
+	// PruneInterval is how often older usage is pruned (0 uses the default)
+	PruneInterval time.Duration
+
+	// OnError is called when pruning fails (nil logs the errors)
+	OnError func(error)
+}
+
+// retention prunes usage in the background
+type retention struct {
+	policy RetentionPolicy
+	stop   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+// WithRetention makes the tracker delete usage older than MaxAge from its usage store
+// and rotated usage log files once it is created and at every prune interval, until it
+// is closed. The usage store must implement UsagePruner, as MemoryUsageStore,
+// FileUsageStore and the sqlitestore package do.
+func WithRetention(policy RetentionPolicy) TrackerOption {
+	return func(t *DefaultTokenTracker) {
+		if policy.MaxAge <= 0 {
+			t.retention = nil
+			return
+		}
+		if policy.PruneInterval <= 0 {
+			policy.PruneInterval = DefaultRetentionPruneInterval
+		}
+		t.retention = &retention{
+			policy: policy,
+			stop:   make(chan struct{}),
+			done:   make(chan struct{}),
+		}
+	}
+}
+
+// PruneUsage deletes the usage recorded before a time from the usage store and the
+// rotated usage log files. It returns the number of records deleted from the store.
+func (t *DefaultTokenTracker) PruneUsage(before time.Time) (int64, error) {
+	var deleted int64
+	var errs []error
+	if store := t.UsageStore(); store != nil {
+		pruner, ok := store.(UsagePruner)
+		if !ok {
+			return 0, NewError(ErrStorageFailed, "usage store does not support pruning", nil)
+		}
+		n, err := pruner.Prune(before)
+		if err != nil {
+			errs = append(errs, NewError(ErrStorageFailed, "failed to prune usage store", err))
+		}
+		deleted = n
+	}
+
+	if path, enabled := t.config.usageLogTarget(); enabled {
+		if _, err := PruneUsageLogFiles(path, before); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return deleted, errors.Join(errs...)
+}
+
+// runRetention prunes usage older than the retention period now and at every prune
+// interval until the retention is closed
+func (t *DefaultTokenTracker) runRetention(r *retention) {
+	defer close(r.done)
+
+	ticker := t.clock().NewTicker(r.policy.PruneInterval)
+	defer ticker.Stop()
+
+	for {
+		// Failed prunes are retried at the next interval
+		if _, err := t.PruneUsage(t.clock().Now().Add(-r.policy.MaxAge)); err != nil {
+			if r.policy.OnError != nil {
+				r.policy.OnError(err)
+			} else {
+				t.logger().Warn("usage pruning failed", "error", err)
+			}
+		}
+
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.Chan():
+		}
+	}
+}
+
+// close stops the pruning and waits for a prune in progress
+func (r *retention) close() {
+	r.once.Do(func() { close(r.stop) })
+	<-r.done
+}
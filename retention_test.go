@@ -0,0 +1,93 @@
+package tokentracker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker/tokentrackertest"
+)
+
+func TestUsageStores_Prune(t *testing.T) {
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	fileStore, err := NewFileUsageStore(filepath.Join(t.TempDir(), "usage.jsonl"))
+	if err != nil {
+		t.Fatalf("NewFileUsageStore() error = %v", err)
+	}
+	defer fileStore.Close()
+
+	for name, store := range map[string]UsageStore{"memory": NewMemoryUsageStore(), "file": fileStore} {
+		for i := 0; i < 3; i++ {
+			store.Record(sampleUsage("gpt-4", "openai", base.AddDate(0, 0, i), 1))
+		}
+
+		deleted, err := store.(UsagePruner).Prune(base.AddDate(0, 0, 2))
+		if err != nil || deleted != 2 {
+			t.Errorf("%s: Prune() = %d, %v, want 2 records deleted", name, deleted, err)
+		}
+		if records, _ := store.Query(UsageFilter{}); len(records) != 1 || !records[0].Timestamp.Equal(base.AddDate(0, 0, 2)) {
+			t.Errorf("%s: kept %+v, want the newest record", name, records)
+		}
+	}
+
+	// The file is rewritten, so pruned records are gone when it is reopened
+	fileStore.Close()
+	reopened, err := NewFileUsageStore(fileStore.Path())
+	if err != nil {
+		t.Fatalf("NewFileUsageStore() error = %v", err)
+	}
+	defer reopened.Close()
+	if records, _ := reopened.Query(UsageFilter{}); len(records) != 1 {
+		t.Errorf("reopened store has %d records, want 1", len(records))
+	}
+}
+
+func TestPruneUsageLogFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "usage.jsonl")
+	for _, name := range []string{"usage.jsonl", "usage-20240101T000000.000.jsonl", "usage-20240301T000000.000.jsonl"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("{}\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := PruneUsageLogFiles(path, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil || removed != 1 {
+		t.Fatalf("PruneUsageLogFiles() = %d, %v, want 1 file removed", removed, err)
+	}
+	files, _ := UsageLogFiles(path)
+	if len(files) != 2 || filepath.Base(files[0]) != "usage-20240301T000000.000.jsonl" {
+		t.Errorf("files = %v, want the newer backup and the active file", files)
+	}
+}
+
+func TestWithRetention_PrunesOldUsage(t *testing.T) {
+	now := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryUsageStore()
+	store.Record(sampleUsage("gpt-4", "openai", now.AddDate(0, 0, -40), 1))
+	store.Record(sampleUsage("gpt-4", "openai", now.AddDate(0, 0, -1), 1))
+
+	tracker := NewTokenTracker(NewConfig(),
+		WithClock(tokentrackertest.NewFakeClock(now)),
+		WithRetention(RetentionPolicy{MaxAge: 30 * 24 * time.Hour}),
+		WithUsageStore(store),
+	)
+	// Closing waits for the prune that runs when the tracker is created
+	if err := tracker.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if records, _ := store.Query(UsageFilter{}); len(records) != 1 || !records[0].Timestamp.Equal(now.AddDate(0, 0, -1)) {
+		t.Errorf("records = %+v, want only usage within 30 days", records)
+	}
+}
+
+func TestPruneUsage_RequiresPrunableStore(t *testing.T) {
+	// Embedding the interface hides the memory store's Prune method
+	store := struct{ UsageStore }{NewMemoryUsageStore()}
+	tracker := NewTokenTracker(NewConfig(), WithUsageStore(store))
+	if _, err := tracker.PruneUsage(time.Now()); err == nil {
+		t.Error("PruneUsage() should fail for a store that cannot prune")
+	}
+}
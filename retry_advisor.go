@@ -0,0 +1,172 @@
+package tokentracker
+
+import (
+	"fmt"
+)
+
+// RetryAction is the action recommended for a failed call
+type RetryAction string
+
+// Retry actions
+const (
+	RetryActionRetry     RetryAction = "retry"     // retry on the same model
+	RetryActionDowngrade RetryAction = "downgrade" // retry on a cheaper model
+	RetryActionAbort     RetryAction = "abort"     // give up
+)
+
+// PriceCalculator calculates the price of a call; TokenTracker implements it
+type PriceCalculator interface {
+	CalculatePrice(model string, inputTokens, outputTokens int) (Price, error)
+}
+
+// RetryPolicy holds the cost ceilings applied by a RetryAdvisor
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first (0 means unlimited)
+	MaxAttempts int
+
+	// MaxCallCost is the maximum estimated cost of a single attempt (0 means unlimited)
+	MaxCallCost float64
+
+	// MaxTotalCost is the maximum cost of all attempts of a request, including
+	// what failed attempts already spent (0 means unlimited)
+	MaxTotalCost float64
+
+	// Fallbacks lists, per model, cheaper models to downgrade to, in order of preference
+	Fallbacks map[string][]string
+}
+
+// RetryRequest describes a failed call to advise on
+type RetryRequest struct {
+	// Model is the model the failed call was made with
+	Model string
+
+	// Attempt is the number of attempts made so far, including the failed one
+	Attempt int
+
+	// PartialUsage is the usage reported for the failed call, if any
+	PartialUsage UsageMetrics
+
+	// SpentCost is the cost of earlier failed attempts, excluding PartialUsage
+	SpentCost float64
+
+	// InputTokens and OutputTokens are the expected tokens of the next attempt.
+	// When zero, the token counts of PartialUsage are used.
+	InputTokens  int
+	OutputTokens int
+
+	// RemainingBudget is the budget left for this request's caller (nil means no budget)
+	RemainingBudget *float64
+}
+
+// RetryAdvice is the recommendation for a failed call
+type RetryAdvice struct {
+	Action        RetryAction
+	Model         string  // model to retry with (empty when aborting)
+	EstimatedCost float64 // estimated cost of the next attempt
+	SpentCost     float64 // cost already spent on failed attempts
+	Currency      string
+	Reason        string
+}
+
+// RetryAdvisor recommends whether to retry a failed call on the same model,
+// downgrade to a cheaper model, or abort, based on cost ceilings and remaining budget
+type RetryAdvisor struct {
+	pricer PriceCalculator
+	policy RetryPolicy
+}
+
+// NewRetryAdvisor creates a retry advisor pricing attempts with the given calculator
+func NewRetryAdvisor(pricer PriceCalculator, policy RetryPolicy) *RetryAdvisor {
+	return &RetryAdvisor{
+		pricer: pricer,
+		policy: policy,
+	}
+}
+
+// NewRetryAdvisor creates a retry advisor that prices attempts with this tracker
+func (t *DefaultTokenTracker) NewRetryAdvisor(policy RetryPolicy) *RetryAdvisor {
+	return NewRetryAdvisor(t, policy)
+}
+
+// Advise recommends what to do after a failed call
+func (a *RetryAdvisor) Advise(req RetryRequest) (RetryAdvice, error) {
+	if req.Model == "" {
+		return RetryAdvice{}, NewError(ErrInvalidParams, "model is required", nil)
+	}
+
+	spent := req.SpentCost + req.PartialUsage.Price.TotalCost
+	advice := RetryAdvice{
+		Action:    RetryActionAbort,
+		SpentCost: spent,
+		Currency:  req.PartialUsage.Price.Currency,
+	}
+
+	if a.policy.MaxAttempts > 0 && req.Attempt >= a.policy.MaxAttempts {
+		advice.Reason = fmt.Sprintf("reached the maximum of %d attempts", a.policy.MaxAttempts)
+		return advice, nil
+	}
+	if a.policy.MaxTotalCost > 0 && spent >= a.policy.MaxTotalCost {
+		advice.Reason = fmt.Sprintf("failed attempts already spent %g of the %g ceiling", spent, a.policy.MaxTotalCost)
+		return advice, nil
+	}
+
+	inputTokens := req.InputTokens
+	if inputTokens == 0 {
+		inputTokens = req.PartialUsage.TokenCount.InputTokens
+	}
+	outputTokens := req.OutputTokens
+	if outputTokens == 0 {
+		outputTokens = req.PartialUsage.TokenCount.ResponseTokens
+	}
+
+	candidates := append([]string{req.Model}, a.policy.Fallbacks[req.Model]...)
+
+	var lastErr error
+	priced := false
+	for _, model := range candidates {
+		price, err := a.pricer.CalculatePrice(model, inputTokens, outputTokens)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		priced = true
+
+		if reason := a.exceeds(price.TotalCost, spent, req.RemainingBudget); reason != "" {
+			advice.Reason = fmt.Sprintf("%s: %s", model, reason)
+			continue
+		}
+
+		advice.Model = model
+		advice.EstimatedCost = price.TotalCost
+		advice.Currency = price.Currency
+		if model == req.Model {
+			advice.Action = RetryActionRetry
+			advice.Reason = "estimated cost is within the configured ceilings"
+		} else {
+			advice.Action = RetryActionDowngrade
+			advice.Reason = fmt.Sprintf("%s exceeds the configured ceilings; %s fits", req.Model, model)
+		}
+		return advice, nil
+	}
+
+	if !priced {
+		return RetryAdvice{}, NewError(ErrPricingNotFound, fmt.Sprintf("no pricing available for %s or its fallbacks", req.Model), lastErr)
+	}
+
+	advice.Reason = "no model fits the configured ceilings (last: " + advice.Reason + ")"
+	return advice, nil
+}
+
+// exceeds returns why an attempt of the given cost is not allowed, or "" when it is
+func (a *RetryAdvisor) exceeds(cost, spent float64, remaining *float64) string {
+	switch {
+	case a.policy.MaxCallCost > 0 && cost > a.policy.MaxCallCost:
+		return fmt.Sprintf("estimated cost %g exceeds the per-call ceiling %g", cost, a.policy.MaxCallCost)
+	case a.policy.MaxTotalCost > 0 && spent+cost > a.policy.MaxTotalCost:
+		return fmt.Sprintf("estimated total %g exceeds the total ceiling %g", spent+cost, a.policy.MaxTotalCost)
+	case remaining != nil && cost > *remaining:
+		return fmt.Sprintf("estimated cost %g exceeds the remaining budget %g", cost, *remaining)
+	default:
+		return ""
+	}
+}
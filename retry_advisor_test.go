@@ -0,0 +1,99 @@
+package tokentracker
+
+import (
+	"errors"
+	"testing"
+)
+
+// fixedPricer prices calls at a fixed cost per token per model
+type fixedPricer map[string]float64
+
+func (p fixedPricer) CalculatePrice(model string, inputTokens, outputTokens int) (Price, error) {
+	perToken, exists := p[model]
+	if !exists {
+		return Price{}, NewError(ErrPricingNotFound, "no pricing for "+model, nil)
+	}
+	return Price{TotalCost: perToken * float64(inputTokens+outputTokens), Currency: "USD"}, nil
+}
+
+func TestRetryAdvisor_Advise(t *testing.T) {
+	pricer := fixedPricer{"big": 0.001, "small": 0.0001}
+	partial := UsageMetrics{
+		TokenCount: TokenCount{InputTokens: 800, ResponseTokens: 200},
+		Price:      Price{TotalCost: 1.0, Currency: "USD"},
+	}
+	budget := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name       string
+		policy     RetryPolicy
+		req        RetryRequest
+		wantAction RetryAction
+		wantModel  string
+	}{
+		{
+			name:       "Retry within ceilings",
+			policy:     RetryPolicy{MaxCallCost: 2},
+			req:        RetryRequest{Model: "big", Attempt: 1, PartialUsage: partial},
+			wantAction: RetryActionRetry,
+			wantModel:  "big",
+		},
+		{
+			name:       "Downgrade when per-call ceiling exceeded",
+			policy:     RetryPolicy{MaxCallCost: 0.5, Fallbacks: map[string][]string{"big": {"small"}}},
+			req:        RetryRequest{Model: "big", Attempt: 1, PartialUsage: partial},
+			wantAction: RetryActionDowngrade,
+			wantModel:  "small",
+		},
+		{
+			name:       "Downgrade when total ceiling would be exceeded",
+			policy:     RetryPolicy{MaxTotalCost: 2.5, Fallbacks: map[string][]string{"big": {"small"}}},
+			req:        RetryRequest{Model: "big", Attempt: 2, SpentCost: 1, PartialUsage: partial},
+			wantAction: RetryActionDowngrade,
+			wantModel:  "small",
+		},
+		{
+			name:       "Abort when the budget is exhausted",
+			policy:     RetryPolicy{Fallbacks: map[string][]string{"big": {"small"}}},
+			req:        RetryRequest{Model: "big", Attempt: 1, PartialUsage: partial, RemainingBudget: budget(0.05)},
+			wantAction: RetryActionAbort,
+		},
+		{
+			name:       "Abort after max attempts",
+			policy:     RetryPolicy{MaxAttempts: 3},
+			req:        RetryRequest{Model: "big", Attempt: 3, PartialUsage: partial},
+			wantAction: RetryActionAbort,
+		},
+		{
+			name:       "Abort when failed attempts spent the ceiling",
+			policy:     RetryPolicy{MaxTotalCost: 1},
+			req:        RetryRequest{Model: "big", Attempt: 1, PartialUsage: partial},
+			wantAction: RetryActionAbort,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			advice, err := NewRetryAdvisor(pricer, tt.policy).Advise(tt.req)
+			if err != nil {
+				t.Fatalf("Advise() error = %v", err)
+			}
+			if advice.Action != tt.wantAction || advice.Model != tt.wantModel {
+				t.Errorf("Advise() = %s %q (%s), want %s %q", advice.Action, advice.Model, advice.Reason, tt.wantAction, tt.wantModel)
+			}
+			if advice.Reason == "" {
+				t.Error("Advise() returned no reason")
+			}
+		})
+	}
+}
+
+func TestRetryAdvisor_UnknownModel(t *testing.T) {
+	advisor := NewRetryAdvisor(fixedPricer{}, RetryPolicy{})
+
+	_, err := advisor.Advise(RetryRequest{Model: "unknown", Attempt: 1, InputTokens: 10})
+	var tokenErr *TokenTrackerError
+	if !errors.As(err, &tokenErr) || tokenErr.Type != ErrPricingNotFound {
+		t.Errorf("Advise() error = %v, want %s", err, ErrPricingNotFound)
+	}
+}
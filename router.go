@@ -0,0 +1,265 @@
+package tokentracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RoutingPolicy selects the model a Router routes a request to
+type RoutingPolicy string
+
+// Routing policies
+const (
+	// RouteCheapest routes to the model with the lowest predicted cost
+	RouteCheapest RoutingPolicy = "cheapest"
+
+	// RouteFastest routes to the model with the lowest observed call duration; models
+	// without tracked calls follow, cheapest first
+	RouteFastest RoutingPolicy = "fastest"
+
+	// RouteFallback routes to the first usable model in the order of RouterOptions.Models
+	RouteFallback RoutingPolicy = "fallback"
+
+	// RouteBudgetRemaining routes to the model leaving the most of its budgets after the
+	// predicted cost; models no budget limits come first, cheapest first
+	RouteBudgetRemaining RoutingPolicy = "budget_remaining"
+)
+
+// routerLatencyWeight is the weight of the latest call in the observed durations
+const routerLatencyWeight = 0.2
+
+// RouterOptions configures a Router
+type RouterOptions struct {
+	// Models are the candidate models, in fallback order (empty uses every priced model
+	// of the registered providers, sorted by name)
+	Models []string
+
+	// Policy selects among the usable candidates (empty uses RouteCheapest)
+	Policy RoutingPolicy
+
+	// Tags are the tags of routed calls, matched against budgets
+	Tags map[string]string
+
+	// MaxCost skips the candidates predicted to cost more (0 means no limit)
+	MaxCost float64
+}
+
+// RouteChoice is the model a request was routed to, with its predicted usage
+type RouteChoice struct {
+	Model    string
+	Provider string
+
+	// TokenCount is the prompt's token count with the model, including the response estimate
+	TokenCount TokenCount
+
+	// Price is the predicted cost of the call
+	Price Price
+
+	// Latency is the observed duration of the model's calls (0 when none were tracked)
+	Latency time.Duration
+
+	// Skipped are the candidates that could not be used, with the reason
+	Skipped map[string]error
+}
+
+// Router selects the model a request is sent to by counting and pricing its prompt
+// with each candidate. Candidates are usable when their provider can count and price
+// the prompt, the prompt and its response estimate fit the context window, the cost
+// stays within MaxCost and no hard budget would be exceeded. It observes the tracked
+// calls for the durations of the fastest policy until it is closed. It is safe for
+// concurrent use.
+type Router struct {
+	tracker *DefaultTokenTracker
+	opts    RouterOptions
+	latency map[string]time.Duration
+	mu      sync.RWMutex
+}
+
+// NewRouter creates a router over the tracker's providers
+func (t *DefaultTokenTracker) NewRouter(opts RouterOptions) (*Router, error) {
+	switch opts.Policy {
+	case "":
+		opts.Policy = RouteCheapest
+	case RouteCheapest, RouteFastest, RouteFallback, RouteBudgetRemaining:
+	default:
+		return nil, NewError(ErrInvalidParams, fmt.Sprintf("unknown routing policy: %s", opts.Policy), nil)
+	}
+	if opts.MaxCost < 0 {
+		return nil, NewError(ErrInvalidParams, "max cost must not be negative", nil)
+	}
+	opts.Models = append([]string(nil), opts.Models...)
+	opts.Tags = copyTags(opts.Tags)
+
+	router := &Router{
+		tracker: t,
+		opts:    opts,
+		latency: make(map[string]time.Duration),
+	}
+	t.AddUsageObserver(router)
+	return router, nil
+}
+
+// Close stops the router from observing the tracker's calls. Routers that are no
+// longer used should be closed so the tracker does not keep them alive.
+func (r *Router) Close() error {
+	r.tracker.RemoveUsageObserver(r)
+	return nil
+}
+
+// ObserveUsage records the duration of a tracked call for the fastest policy
+func (r *Router) ObserveUsage(metrics UsageMetrics) {
+	if metrics.Duration <= 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if latency, exists := r.latency[metrics.Model]; exists {
+		r.latency[metrics.Model] = latency + time.Duration(routerLatencyWeight*float64(metrics.Duration-latency))
+	} else {
+		r.latency[metrics.Model] = metrics.Duration
+	}
+}
+
+// Route selects the model for the prompt of params; its Model is ignored
+func (r *Router) Route(params TokenCountParams) (RouteChoice, error) {
+	return r.RouteCtx(context.Background(), params)
+}
+
+// RouteCtx selects the model for a prompt, propagating cancellation and deadlines to
+// the providers
+func (r *Router) RouteCtx(ctx context.Context, params TokenCountParams) (RouteChoice, error) {
+	params.CountResponseTokens = true
+
+	var candidates []routeCandidate
+	skipped := make(map[string]error)
+	for i, model := range r.models() {
+		candidate, err := r.evaluate(ctx, params, model)
+		if err := ctx.Err(); err != nil {
+			return RouteChoice{}, err
+		}
+		if err != nil {
+			skipped[model] = err
+			continue
+		}
+		candidate.order = i
+		candidates = append(candidates, candidate)
+	}
+	if len(candidates) == 0 {
+		return RouteChoice{Skipped: skipped}, NewError(ErrNoMatchingModel, "no candidate model can serve the request", errors.Join(skippedErrors(skipped)...))
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return r.before(candidates[i], candidates[j])
+	})
+	choice := candidates[0].RouteChoice
+	choice.Skipped = skipped
+	return choice, nil
+}
+
+// routeCandidate is a usable candidate with the values the policies rank by
+type routeCandidate struct {
+	RouteChoice
+	order     int
+	remaining float64
+	limited   bool
+}
+
+// models returns the candidate models
+func (r *Router) models() []string {
+	if len(r.opts.Models) > 0 {
+		return r.opts.Models
+	}
+	var models []string
+	for _, provider := range r.tracker.registry.All() {
+		models = append(models, r.tracker.config.pricedModels(provider.Name())...)
+	}
+	sort.Strings(models)
+	return models
+}
+
+// evaluate counts and prices the prompt with a model and checks that it can serve it
+func (r *Router) evaluate(ctx context.Context, params TokenCountParams, model string) (routeCandidate, error) {
+	comparison := r.tracker.comparePrice(ctx, params, model)
+	if comparison.Err != nil {
+		return routeCandidate{}, comparison.Err
+	}
+	count, price := comparison.TokenCount, comparison.Price
+
+	info, err := r.tracker.ModelInfo(model)
+	if err != nil {
+		return routeCandidate{}, err
+	}
+	if tokens := count.InputTokens + count.ResponseTokens; info.ContextWindow > 0 && tokens > info.ContextWindow {
+		return routeCandidate{}, NewError(ErrInvalidParams, fmt.Sprintf("%d tokens exceed the context window of %d", tokens, info.ContextWindow), nil)
+	}
+	if r.opts.MaxCost > 0 && price.TotalCost > r.opts.MaxCost {
+		return routeCandidate{}, NewError(ErrBudgetExceeded, fmt.Sprintf("predicted cost of %g exceeds the maximum of %g", price.TotalCost, r.opts.MaxCost), nil)
+	}
+
+	candidate := routeCandidate{RouteChoice: RouteChoice{
+		Model:      model,
+		Provider:   comparison.Provider,
+		TokenCount: count,
+		Price:      price,
+	}}
+	if budgets := r.tracker.Budgets(); budgets != nil {
+		if err := budgets.CheckCost(candidate.Provider, model, r.opts.Tags, price.TotalCost, count.TotalTokens); err != nil {
+			return routeCandidate{}, err
+		}
+		if remaining, limited := budgets.RemainingCostFor(candidate.Provider, model, r.opts.Tags); limited {
+			candidate.remaining, candidate.limited = remaining-price.TotalCost, true
+		}
+	}
+
+	r.mu.RLock()
+	candidate.Latency = r.latency[model]
+	r.mu.RUnlock()
+	return candidate, nil
+}
+
+// before reports whether candidate a is preferred over b by the router's policy
+func (r *Router) before(a, b routeCandidate) bool {
+	switch r.opts.Policy {
+	case RouteFallback:
+		return a.order < b.order
+	case RouteFastest:
+		if (a.Latency > 0) != (b.Latency > 0) {
+			return a.Latency > 0
+		}
+		if a.Latency != b.Latency {
+			return a.Latency < b.Latency
+		}
+	case RouteBudgetRemaining:
+		if a.limited != b.limited {
+			return !a.limited
+		}
+		if a.remaining != b.remaining {
+			return a.remaining > b.remaining
+		}
+	}
+	if a.Price.TotalCost != b.Price.TotalCost {
+		return a.Price.TotalCost < b.Price.TotalCost
+	}
+	return a.order < b.order
+}
+
+// skippedErrors returns the reasons candidates were skipped, ordered by model
+func skippedErrors(skipped map[string]error) []error {
+	models := make([]string, 0, len(skipped))
+	for model := range skipped {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+
+	errs := make([]error, 0, len(models))
+	for _, model := range models {
+		errs = append(errs, fmt.Errorf("%s: %w", model, skipped[model]))
+	}
+	return errs
+}
@@ -0,0 +1,145 @@
+package tokentracker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// routedProvider counts every prompt as 1000 input and 200 response tokens
+type routedProvider struct {
+	usagePricedProvider
+	contextWindows map[string]int
+}
+
+func (p *routedProvider) CountTokens(params TokenCountParams) (TokenCount, error) {
+	count := TokenCount{InputTokens: 1000, TotalTokens: 1000}
+	if params.CountResponseTokens {
+		count.ResponseTokens = 200
+		count.TotalTokens = 1200
+	}
+	return count, nil
+}
+
+func (p *routedProvider) GetModelInfo(model string) (ModelInfo, error) {
+	return ModelInfo{ContextWindow: p.contextWindows[model]}, nil
+}
+
+func newRouterTracker(t *testing.T, opts ...TrackerOption) *DefaultTokenTracker {
+	tracker := newPricedTestTracker(t, opts...)
+	config := tracker.Config()
+	config.SetModelPricing("acme", "small", ModelPricing{InputPricePerToken: 0.000001, OutputPricePerToken: 0.000002, Currency: "USD"})
+	config.SetModelPricing("acme", "large", ModelPricing{InputPricePerToken: 0.00001, OutputPricePerToken: 0.00002, Currency: "USD"})
+	config.SetModelPricing("acme", "tiny", ModelPricing{InputPricePerToken: 0.0000001, OutputPricePerToken: 0.0000002, Currency: "USD"})
+	tracker.RegisterProvider(&routedProvider{
+		usagePricedProvider: newPricedProvider(config, "acme", "small", "large", "tiny"),
+		contextWindows:      map[string]int{"small": 8000, "large": 128000, "tiny": 1024},
+	})
+	return tracker
+}
+
+func TestRouter_Cheapest(t *testing.T) {
+	router, err := newRouterTracker(t).NewRouter(RouterOptions{})
+	if err != nil {
+		t.Fatalf("NewRouter() error = %v", err)
+	}
+
+	choice, err := router.Route(TokenCountParams{Text: stringPtr("Summarize this")})
+	if err != nil {
+		t.Fatalf("Route() error = %v", err)
+	}
+	// tiny is cheaper but 1200 tokens do not fit its context window
+	if choice.Model != "small" || choice.Provider != "acme" {
+		t.Errorf("Route() = %s/%s, want acme/small", choice.Provider, choice.Model)
+	}
+	// 1000 * 0.000001 + 200 * 0.000002
+	if choice.TokenCount.ResponseTokens != 200 || choice.Price.TotalCost < 0.0013999 || choice.Price.TotalCost > 0.0014001 {
+		t.Errorf("Route() = %+v, want the predicted cost with the response estimate", choice)
+	}
+	if choice.Skipped["tiny"] == nil {
+		t.Errorf("Skipped = %v, want tiny", choice.Skipped)
+	}
+}
+
+func TestRouter_FallbackAndMaxCost(t *testing.T) {
+	tracker := newRouterTracker(t)
+	router, _ := tracker.NewRouter(RouterOptions{Models: []string{"unknown", "large", "small"}, Policy: RouteFallback})
+	if choice, err := router.Route(TokenCountParams{Text: stringPtr("Hi")}); err != nil || choice.Model != "large" {
+		t.Errorf("Route() = %v, %v, want the first usable model", choice.Model, err)
+	}
+
+	router, _ = tracker.NewRouter(RouterOptions{Models: []string{"large", "small"}, Policy: RouteFallback, MaxCost: 0.005})
+	if choice, err := router.Route(TokenCountParams{Text: stringPtr("Hi")}); err != nil || choice.Model != "small" {
+		t.Errorf("Route() = %v, %v, want large skipped for its cost", choice.Model, err)
+	}
+
+	router, _ = tracker.NewRouter(RouterOptions{Models: []string{"large"}, MaxCost: 0.001})
+	_, err := router.Route(TokenCountParams{Text: stringPtr("Hi")})
+	var trackerErr *TokenTrackerError
+	if !errors.As(err, &trackerErr) || trackerErr.Type != ErrNoMatchingModel {
+		t.Errorf("Route() error = %v, want %s", err, ErrNoMatchingModel)
+	}
+
+	if _, err := tracker.NewRouter(RouterOptions{Policy: "random"}); err == nil {
+		t.Error("NewRouter() with an unknown policy should fail")
+	}
+}
+
+func TestRouter_Fastest(t *testing.T) {
+	tracker := newRouterTracker(t)
+	router, _ := tracker.NewRouter(RouterOptions{Models: []string{"small", "large"}, Policy: RouteFastest})
+
+	// Without observed calls the cheapest model is chosen
+	if choice, _ := router.Route(TokenCountParams{Text: stringPtr("Hi")}); choice.Model != "small" {
+		t.Errorf("Route() = %s, want small without observations", choice.Model)
+	}
+
+	start := time.Now()
+	for _, call := range []struct {
+		model    string
+		duration time.Duration
+	}{{"small", 3 * time.Second}, {"large", time.Second}} {
+		if _, err := tracker.TrackReportedUsage(context.Background(), CallParams{Model: call.model, StartTime: start.Add(-call.duration)}, TokenCount{InputTokens: 10}); err != nil {
+			t.Fatalf("TrackReportedUsage() error = %v", err)
+		}
+	}
+	choice, _ := router.Route(TokenCountParams{Text: stringPtr("Hi")})
+	if choice.Model != "large" || choice.Latency <= 0 {
+		t.Errorf("Route() = %+v, want the faster large model", choice)
+	}
+}
+
+func TestRouter_Close(t *testing.T) {
+	tracker := newRouterTracker(t)
+	router, _ := tracker.NewRouter(RouterOptions{Models: []string{"small", "large"}, Policy: RouteFastest})
+	kept, _ := tracker.NewRouter(RouterOptions{Models: []string{"small", "large"}, Policy: RouteFastest})
+	if err := router.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if _, err := tracker.TrackReportedUsage(context.Background(), CallParams{Model: "large", StartTime: time.Now().Add(-time.Second)}, TokenCount{InputTokens: 10}); err != nil {
+		t.Fatalf("TrackReportedUsage() error = %v", err)
+	}
+	if len(tracker.observers) != 1 || len(router.latency) != 0 || len(kept.latency) != 1 {
+		t.Errorf("observers = %d, closed router latencies = %v, open router latencies = %v, want only the open router observing", len(tracker.observers), router.latency, kept.latency)
+	}
+}
+
+func TestRouter_BudgetRemaining(t *testing.T) {
+	budgets := NewBudgetManager(nil)
+	tracker := newRouterTracker(t, WithBudgetManager(budgets))
+	_ = budgets.SetBudget(Budget{Name: "small", Model: "small", CostLimit: 0.01})
+	_ = budgets.SetBudget(Budget{Name: "large", Model: "large", CostLimit: 1})
+	router, _ := tracker.NewRouter(RouterOptions{Models: []string{"small", "large"}, Policy: RouteBudgetRemaining})
+
+	if choice, _ := router.Route(TokenCountParams{Text: stringPtr("Hi")}); choice.Model != "large" {
+		t.Errorf("Route() = %s, want the model with the most budget left", choice.Model)
+	}
+
+	// Hard budgets the call would exceed rule the model out
+	_ = budgets.SetBudget(Budget{Name: "large", Model: "large", CostLimit: 0.001, Hard: true})
+	if choice, _ := router.Route(TokenCountParams{Text: stringPtr("Hi")}); choice.Model != "small" || choice.Skipped["large"] == nil {
+		t.Errorf("Route() = %+v, want large ruled out by its hard budget", choice)
+	}
+}
@@ -0,0 +1,52 @@
+package tokentracker
+
+import "sync/atomic"
+
+// SamplingConfig configures the sampling of stored usage
+type SamplingConfig struct {
+	// Rate stores one in Rate calls; each stored record stands for Rate calls (see
+	// UsageMetrics.SampleRate). Values below 2 store every call.
+	Rate int
+
+	// KeepCostAbove stores every call costing more, as a record standing for itself,
+	// so that rare expensive calls do not skew the estimates (0 samples all calls)
+	KeepCostAbove float64
+}
+
+// sampler selects the calls stored by a sampling tracker
+type sampler struct {
+	config SamplingConfig
+	calls  atomic.Uint64
+}
+
+// WithSampling makes the tracker store a sample of the tracked calls in its usage store
+// and usage log, for workloads too large to store every call. Budgets, tenant quotas,
+// thresholds, Totals, usage observers and sinks still see every call, and summaries,
+// statements and simulations scale stored records by their SampleRate, so costs stay
+// accurate on average while storage drops by the rate.
+func WithSampling(config SamplingConfig) TrackerOption {
+	return func(t *DefaultTokenTracker) {
+		if config.Rate < 2 {
+			t.sampler = nil
+			return
+		}
+		t.sampler = &sampler{config: config}
+	}
+}
+
+// sample returns the record to store for a call and whether it is stored. Calls are
+// sampled systematically: every Rate-th call below KeepCostAbove is stored.
+func (t *DefaultTokenTracker) sample(metrics UsageMetrics) (UsageMetrics, bool) {
+	s := t.sampler
+	if s == nil {
+		return metrics, true
+	}
+	if s.config.KeepCostAbove > 0 && metrics.Price.TotalCost > s.config.KeepCostAbove {
+		return metrics, true
+	}
+	if (s.calls.Add(1)-1)%uint64(s.config.Rate) != 0 {
+		return metrics, false
+	}
+	metrics.SampleRate = s.config.Rate
+	return metrics, true
+}
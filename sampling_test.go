@@ -0,0 +1,79 @@
+package tokentracker
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestWithSampling_StoresSampleAndScalesSummaries(t *testing.T) {
+	tracker, store := newTransportTracker()
+	WithSampling(SamplingConfig{Rate: 10})(tracker)
+
+	for i := 0; i < 100; i++ {
+		if _, err := tracker.TrackReportedUsage(context.Background(), CallParams{Model: "gpt-4o"}, TokenCount{InputTokens: 10, ResponseTokens: 5}); err != nil {
+			t.Fatalf("TrackReportedUsage() error = %v", err)
+		}
+	}
+
+	records, _ := store.Query(UsageFilter{})
+	if len(records) != 10 {
+		t.Fatalf("stored %d records, want 10", len(records))
+	}
+	if records[0].SampleRate != 10 || records[0].SampleWeight() != 10 {
+		t.Errorf("record = %+v, want a sample rate of 10", records[0])
+	}
+
+	// Totals count every call exactly
+	if total := tracker.Totals().Total(); total.Calls != 100 || math.Abs(total.TotalCost-2) > 1e-9 {
+		t.Errorf("Totals() = %+v, want 100 calls costing 2", total)
+	}
+
+	// Summaries scale the sampled records back up
+	summaries, err := tracker.Summary(UsageFilter{}, GroupByModel)
+	if err != nil {
+		t.Fatalf("Summary() error = %v", err)
+	}
+	if len(summaries) != 1 || summaries[0].Calls != 100 || summaries[0].InputTokens != 1000 || math.Abs(summaries[0].TotalCost-2) > 1e-9 {
+		t.Errorf("Summary() = %+v, want 100 calls of 1000 input tokens costing 2", summaries)
+	}
+
+	statement, err := BuildUsageStatement(records, records[0].Timestamp, records[0].Timestamp, StatementOptions{})
+	if err != nil {
+		t.Fatalf("BuildUsageStatement() error = %v", err)
+	}
+	if statement.Total.Calls != 100 || statement.Lines[0].TopModels[0].Calls != 100 {
+		t.Errorf("statement = %+v, want 100 calls", statement)
+	}
+}
+
+func TestWithSampling_KeepsExpensiveCalls(t *testing.T) {
+	tracker, store := newTransportTracker()
+	WithSampling(SamplingConfig{Rate: 100, KeepCostAbove: 0.01})(tracker)
+
+	for i := 0; i < 5; i++ {
+		if _, err := tracker.TrackReportedUsage(context.Background(), CallParams{Model: "gpt-4o"}, TokenCount{InputTokens: 10}); err != nil {
+			t.Fatalf("TrackReportedUsage() error = %v", err)
+		}
+	}
+
+	// Every call costs 0.02, above the threshold, so each is stored standing for itself
+	records, _ := store.Query(UsageFilter{})
+	if len(records) != 5 || records[0].SampleRate != 0 {
+		t.Errorf("stored %+v, want every call unsampled", records)
+	}
+}
+
+func TestWithSampling_LowRateDisables(t *testing.T) {
+	tracker, store := newTransportTracker()
+	WithSampling(SamplingConfig{Rate: 1})(tracker)
+
+	for i := 0; i < 3; i++ {
+		if _, err := tracker.TrackReportedUsage(context.Background(), CallParams{Model: "gpt-4o"}, TokenCount{InputTokens: 10}); err != nil {
+			t.Fatalf("TrackReportedUsage() error = %v", err)
+		}
+	}
+	if records, _ := store.Query(UsageFilter{}); len(records) != 3 {
+		t.Errorf("stored %d records, want every call", len(records))
+	}
+}
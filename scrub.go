@@ -0,0 +1,132 @@
+package tokentracker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+)
+
+// RedactedValue replaces redacted tag values and text
+const RedactedValue = "[REDACTED]"
+
+// EmailPattern matches e-mail addresses, for ScrubPolicy.Patterns
+var EmailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+
+// Scrubber redacts sensitive data from a usage record
+type Scrubber func(UsageMetrics) UsageMetrics
+
+// ScrubPolicy redacts or hashes personal data and prompt text in usage records before
+// they are stored, logged or sent to sinks
+type ScrubPolicy struct {
+	// RedactTags are the tags whose values are replaced with RedactedValue
+	RedactTags []string
+
+	// HashTags are the tags whose values are replaced with a salted hash, so usage can
+	// still be grouped by them without revealing them
+	HashTags []string
+
+	// HashUserID replaces user IDs with a salted hash
+	HashUserID bool
+
+	// Salt is mixed into hashes so they cannot be reversed with a dictionary
+	Salt string
+
+	// Patterns are redacted in the remaining tag values and in prompt examples, e.g.
+	// EmailPattern
+	Patterns []*regexp.Regexp
+
+	// Scrub is a custom hook run after the rules above (nil skips it)
+	Scrub Scrubber
+}
+
+// Apply returns the record with the policy applied; the record's tags are not modified.
+// Hashed tags are hashed from their original values and patterns are redacted in the
+// other tags only, so equal values still hash alike.
+func (p ScrubPolicy) Apply(metrics UsageMetrics) UsageMetrics {
+	if len(metrics.Tags) > 0 {
+		metrics.Tags = copyTags(metrics.Tags)
+		handled := make(map[string]bool, len(p.HashTags)+len(p.RedactTags))
+		for _, key := range p.HashTags {
+			if value, exists := metrics.Tags[key]; exists {
+				metrics.Tags[key] = p.hash(value)
+				handled[key] = true
+			}
+		}
+		for _, key := range p.RedactTags {
+			if _, exists := metrics.Tags[key]; exists {
+				metrics.Tags[key] = RedactedValue
+				handled[key] = true
+			}
+		}
+		for key, value := range metrics.Tags {
+			if !handled[key] {
+				metrics.Tags[key] = p.ScrubText(value)
+			}
+		}
+	}
+	if p.HashUserID && metrics.UserID != "" {
+		metrics.UserID = p.hash(metrics.UserID)
+	}
+	if p.Scrub != nil {
+		metrics = p.Scrub(metrics)
+	}
+	return metrics
+}
+
+// ScrubText redacts the matches of the policy's patterns in text
+func (p ScrubPolicy) ScrubText(text string) string {
+	for _, pattern := range p.Patterns {
+		text = pattern.ReplaceAllString(text, RedactedValue)
+	}
+	return text
+}
+
+// hash returns the salted hash of a value
+func (p ScrubPolicy) hash(value string) string {
+	return HashValue(p.Salt, value)
+}
+
+// HashValue returns the hex-encoded SHA-256 hash of a salted value. Equal values hash
+// alike, so hashed user IDs and tags can still be grouped by.
+func HashValue(salt, value string) string {
+	sum := sha256.Sum256([]byte(salt + value))
+	return hex.EncodeToString(sum[:])
+}
+
+// WithScrubbing makes the tracker apply a scrubbing policy to usage before it is
+// written into the usage store or usage log, imported or sent to sinks, and to the
+// prompt examples of WithTopPrompts. Budgets, tenant quotas and the metrics returned
+// to the caller see the original usage.
+func WithScrubbing(policy ScrubPolicy) TrackerOption {
+	return func(t *DefaultTokenTracker) {
+		t.scrubbing = &policy
+	}
+}
+
+// scrub returns a record with the tracker's scrubbing policy applied
+func (t *DefaultTokenTracker) scrub(metrics UsageMetrics) UsageMetrics {
+	if t.scrubbing == nil {
+		return metrics
+	}
+	return t.scrubbing.Apply(metrics)
+}
+
+// scrubAll returns records with the tracker's scrubbing policy applied
+func (t *DefaultTokenTracker) scrubAll(records []UsageMetrics) []UsageMetrics {
+	if t.scrubbing == nil {
+		return records
+	}
+	scrubbed := make([]UsageMetrics, len(records))
+	for i, record := range records {
+		scrubbed[i] = t.scrubbing.Apply(record)
+	}
+	return scrubbed
+}
+
+// scrubText returns prompt text with the patterns of the tracker's scrubbing policy redacted
+func (t *DefaultTokenTracker) scrubText(text string) string {
+	if t.scrubbing == nil {
+		return text
+	}
+	return t.scrubbing.ScrubText(text)
+}
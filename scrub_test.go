@@ -0,0 +1,71 @@
+package tokentracker
+
+import (
+	"context"
+	"regexp"
+	"testing"
+)
+
+func TestScrubPolicy_Apply(t *testing.T) {
+	policy := ScrubPolicy{
+		RedactTags: []string{"prompt"},
+		HashTags:   []string{"session"},
+		HashUserID: true,
+		Salt:       "pepper",
+		Patterns:   []*regexp.Regexp{EmailPattern},
+	}
+	tags := map[string]string{"prompt": "summarize this", "session": "s-1", "note": "mail jane@example.com now"}
+	metrics := UsageMetrics{UserID: "user-1", Tags: tags}
+
+	scrubbed := policy.Apply(metrics)
+	if scrubbed.Tags["prompt"] != RedactedValue {
+		t.Errorf("prompt = %q, want it redacted", scrubbed.Tags["prompt"])
+	}
+	if scrubbed.Tags["session"] != HashValue("pepper", "s-1") || scrubbed.UserID != HashValue("pepper", "user-1") {
+		t.Errorf("scrubbed = %+v, want the session and user hashed", scrubbed)
+	}
+	if scrubbed.Tags["note"] != "mail [REDACTED] now" {
+		t.Errorf("note = %q, want the e-mail address redacted", scrubbed.Tags["note"])
+	}
+	if tags["prompt"] != "summarize this" || metrics.UserID != "user-1" {
+		t.Error("Apply() modified the original record")
+	}
+
+	// Hashes are stable so scrubbed usage can still be grouped
+	if HashValue("pepper", "s-1") == HashValue("salt", "s-1") {
+		t.Error("HashValue() should depend on the salt")
+	}
+
+	// Hashed tags matching a pattern are hashed from their values, not the redaction
+	policy.HashTags = []string{"customer"}
+	jane := policy.Apply(UsageMetrics{Tags: map[string]string{"customer": "jane@example.com"}})
+	john := policy.Apply(UsageMetrics{Tags: map[string]string{"customer": "john@example.com"}})
+	if jane.Tags["customer"] != HashValue("pepper", "jane@example.com") || jane.Tags["customer"] == john.Tags["customer"] {
+		t.Errorf("customers hashed to %q and %q, want the hashes of their addresses", jane.Tags["customer"], john.Tags["customer"])
+	}
+}
+
+func TestWithScrubbing_ScrubsStoredUsage(t *testing.T) {
+	tracker, store := newTransportTracker()
+	WithScrubbing(ScrubPolicy{
+		RedactTags: []string{"prompt"},
+		Scrub: func(metrics UsageMetrics) UsageMetrics {
+			metrics.ProjectID = ""
+			return metrics
+		},
+	})(tracker)
+
+	params := CallParams{Model: "gpt-4o", ProjectID: "secret-project", Tags: map[string]string{"prompt": "hello"}}
+	metrics, err := tracker.TrackReportedUsage(context.Background(), params, TokenCount{InputTokens: 10})
+	if err != nil {
+		t.Fatalf("TrackReportedUsage() error = %v", err)
+	}
+	if metrics.Tags["prompt"] != "hello" || metrics.ProjectID != "secret-project" {
+		t.Errorf("returned metrics = %+v, want the original usage", metrics)
+	}
+
+	records, _ := store.Query(UsageFilter{})
+	if len(records) != 1 || records[0].Tags["prompt"] != RedactedValue || records[0].ProjectID != "" {
+		t.Errorf("stored %+v, want the usage scrubbed", records)
+	}
+}
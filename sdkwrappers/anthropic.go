@@ -1,9 +1,9 @@
 package sdkwrappers
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
-	"reflect"
-	"time"
 
 	"github.com/TrustSight-io/tokentracker/common"
 	"github.com/anthropics/anthropic-sdk-go"
@@ -21,6 +21,9 @@ const (
 // AnthropicSDKWrapper wraps the Anthropic SDK client
 type AnthropicSDKWrapper struct {
 	client anthropic.Client
+	clock  common.Clock
+	attribution
+	trackedCalls
 }
 
 // NewAnthropicSDKWrapper creates a new Anthropic SDK wrapper
@@ -29,7 +32,8 @@ func NewAnthropicSDKWrapper(apiKey string) *AnthropicSDKWrapper {
 	client := anthropic.NewClient(option.WithAPIKey(apiKey))
 
 	return &AnthropicSDKWrapper{
-		client: client,
+		client:      client,
+		attribution: newAttribution(apiKey),
 	}
 }
 
@@ -43,6 +47,23 @@ func (w *AnthropicSDKWrapper) GetClient() interface{} {
 	return w.client
 }
 
+// CountTokens counts the input tokens of a request with Anthropic's count_tokens
+// endpoint, see providers.AnthropicTokenCounter
+func (w *AnthropicSDKWrapper) CountTokens(ctx context.Context, req common.AnthropicCountTokensRequest) (int, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode count_tokens request: %w", err)
+	}
+
+	// The request is already in the API's format, so it is sent as is
+	resp, err := w.client.Messages.CountTokens(ctx, anthropic.MessageCountTokensParams{}, option.WithRequestBody("application/json", body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to count Anthropic tokens: %w", err)
+	}
+
+	return int(resp.InputTokens), nil
+}
+
 // GetSupportedModels returns a list of supported models
 func (w *AnthropicSDKWrapper) GetSupportedModels() ([]string, error) {
 	// Hardcoded list of Claude models
@@ -54,21 +75,38 @@ func (w *AnthropicSDKWrapper) GetSupportedModels() ([]string, error) {
 	}, nil
 }
 
+// SetClock sets the clock used for usage timestamps and durations
+func (w *AnthropicSDKWrapper) SetClock(clock common.Clock) {
+	w.clock = clock
+}
+
+// getClock returns the configured clock or the system clock
+func (w *AnthropicSDKWrapper) getClock() common.Clock {
+	if w.clock == nil {
+		return common.SystemClock
+	}
+	return w.clock
+}
+
 // ExtractTokenUsageFromResponse extracts token usage from an Anthropic API response
 func (w *AnthropicSDKWrapper) ExtractTokenUsageFromResponse(response interface{}) (common.TokenUsage, error) {
 	// The type switch needs to extract specific information from each type
 	switch resp := response.(type) {
 	// Handle real Anthropic Message responses
 	case *anthropic.Message:
+		// input_tokens excludes the tokens read from and written to the prompt cache
+		inputTokens := int(resp.Usage.InputTokens + resp.Usage.CacheReadInputTokens + resp.Usage.CacheCreationInputTokens)
 		return common.TokenUsage{
-			InputTokens:    int(resp.Usage.InputTokens),
-			OutputTokens:   int(resp.Usage.OutputTokens),
-			TotalTokens:    int(resp.Usage.InputTokens + resp.Usage.OutputTokens),
-			CompletionID:   resp.ID,
-			Model:          resp.Model,
-			Timestamp:      time.Now(),
-			PromptTokens:   int(resp.Usage.InputTokens),
-			ResponseTokens: int(resp.Usage.OutputTokens),
+			InputTokens:       inputTokens,
+			OutputTokens:      int(resp.Usage.OutputTokens),
+			TotalTokens:       inputTokens + int(resp.Usage.OutputTokens),
+			CompletionID:      resp.ID,
+			Model:             resp.Model,
+			Timestamp:         w.getClock().Now(),
+			PromptTokens:      inputTokens,
+			ResponseTokens:    int(resp.Usage.OutputTokens),
+			CachedInputTokens: int(resp.Usage.CacheReadInputTokens),
+			CacheWriteTokens:  int(resp.Usage.CacheCreationInputTokens),
 		}, nil
 
 	// Special case for maps (used in mock JSON responses)
@@ -79,15 +117,20 @@ func (w *AnthropicSDKWrapper) ExtractTokenUsageFromResponse(response interface{}
 				if usage, hasUsage := resp["usage"].(map[string]interface{}); hasUsage {
 					if inputTokens, hasInput := usage["input_tokens"].(float64); hasInput {
 						if outputTokens, hasOutput := usage["output_tokens"].(float64); hasOutput {
+							cacheRead, _ := usage["cache_read_input_tokens"].(float64)
+							cacheWrite, _ := usage["cache_creation_input_tokens"].(float64)
+							inputTokens += cacheRead + cacheWrite
 							return common.TokenUsage{
-								InputTokens:    int(inputTokens),
-								OutputTokens:   int(outputTokens),
-								TotalTokens:    int(inputTokens + outputTokens),
-								CompletionID:   id,
-								Model:          model,
-								Timestamp:      time.Now(),
-								PromptTokens:   int(inputTokens),
-								ResponseTokens: int(outputTokens),
+								InputTokens:       int(inputTokens),
+								OutputTokens:      int(outputTokens),
+								TotalTokens:       int(inputTokens + outputTokens),
+								CompletionID:      id,
+								Model:             model,
+								Timestamp:         w.getClock().Now(),
+								PromptTokens:      int(inputTokens),
+								ResponseTokens:    int(outputTokens),
+								CachedInputTokens: int(cacheRead),
+								CacheWriteTokens:  int(cacheWrite),
 							}, nil
 						}
 					}
@@ -96,49 +139,13 @@ func (w *AnthropicSDKWrapper) ExtractTokenUsageFromResponse(response interface{}
 		}
 	}
 
-	// For all test cases, we need to make a special case for MockAnthropicResponse
-	// This uses reflection to check if the type name matches, as we can't import it directly
-	respType := fmt.Sprintf("%T", response)
-	if respType == "*sdkwrappers.MockAnthropicResponse" {
-		// Use reflection to safely access fields
-		respValue := reflect.ValueOf(response).Elem()
-
-		// Get ID and Model fields
-		id := ""
-		model := ""
-		if idField := respValue.FieldByName("ID"); idField.IsValid() {
-			id = idField.String()
-		}
-		if modelField := respValue.FieldByName("Model"); modelField.IsValid() {
-			model = modelField.String()
-		}
-
-		// Get Usage struct and its fields
-		if usageField := respValue.FieldByName("Usage"); usageField.IsValid() {
-			inputTokens := 0
-			outputTokens := 0
-
-			if inputField := usageField.FieldByName("InputTokens"); inputField.IsValid() {
-				inputTokens = int(inputField.Int())
-			}
-			if outputField := usageField.FieldByName("OutputTokens"); outputField.IsValid() {
-				outputTokens = int(outputField.Int())
-			}
-
-			return common.TokenUsage{
-				InputTokens:    inputTokens,
-				OutputTokens:   outputTokens,
-				TotalTokens:    inputTokens + outputTokens,
-				CompletionID:   id,
-				Model:          model,
-				Timestamp:      time.Now(),
-				PromptTokens:   inputTokens,
-				ResponseTokens: outputTokens,
-			}, nil
-		}
+	// Responses of other types, such as an application's own wrappers, are handled by
+	// the adapters registered with RegisterResponseAdapter
+	if usage, ok := adaptResponse(w.GetProviderName(), response, w.getClock().Now()); ok {
+		return usage, nil
 	}
 
-	return common.TokenUsage{}, fmt.Errorf("response is not an *anthropic.Message or valid mock: %T", response)
+	return common.TokenUsage{}, fmt.Errorf("response is not an *anthropic.Message and no response adapter handles %T", response)
 }
 
 // FetchCurrentPricing returns the current pricing for Anthropic models
@@ -147,24 +154,32 @@ func (w *AnthropicSDKWrapper) FetchCurrentPricing() (map[string]common.ModelPric
 	// These values should be updated regularly or fetched from an API
 	pricing := map[string]common.ModelPricing{
 		ClaudeHaiku: {
-			InputPricePerToken:  0.00000025,
-			OutputPricePerToken: 0.00000125,
-			Currency:            "USD",
+			InputPricePerToken:       0.00000025,
+			OutputPricePerToken:      0.00000125,
+			CachedInputPricePerToken: 0.00000003,
+			CacheWritePricePerToken:  0.0000003,
+			Currency:                 "USD",
 		},
 		ClaudeSonnet: {
-			InputPricePerToken:  0.000003,
-			OutputPricePerToken: 0.000015,
-			Currency:            "USD",
+			InputPricePerToken:       0.000003,
+			OutputPricePerToken:      0.000015,
+			CachedInputPricePerToken: 0.0000003,
+			CacheWritePricePerToken:  0.00000375,
+			Currency:                 "USD",
 		},
 		ClaudeOpus: {
-			InputPricePerToken:  0.00001,
-			OutputPricePerToken: 0.00003,
-			Currency:            "USD",
+			InputPricePerToken:       0.00001,
+			OutputPricePerToken:      0.00003,
+			CachedInputPricePerToken: 0.000001,
+			CacheWritePricePerToken:  0.0000125,
+			Currency:                 "USD",
 		},
 		ClaudeHaiku2: {
-			InputPricePerToken:  0.00000025,
-			OutputPricePerToken: 0.00000125,
-			Currency:            "USD",
+			InputPricePerToken:       0.00000025,
+			OutputPricePerToken:      0.00000125,
+			CachedInputPricePerToken: 0.00000003,
+			CacheWritePricePerToken:  0.0000003,
+			Currency:                 "USD",
 		},
 	}
 
@@ -186,6 +201,13 @@ func (w *AnthropicSDKWrapper) TrackAPICall(model string, response interface{}) (
 		return common.UsageMetrics{}, err
 	}
 
+	return w.trackOnce(w.GetProviderName(), model, tokenUsage, func() (common.UsageMetrics, error) {
+		return w.usageMetrics(model, tokenUsage)
+	})
+}
+
+// usageMetrics prices the token usage of a call
+func (w *AnthropicSDKWrapper) usageMetrics(model string, tokenUsage common.TokenUsage) (common.UsageMetrics, error) {
 	// Get pricing information for the model
 	pricing, err := w.FetchCurrentPricing()
 	if err != nil {
@@ -198,29 +220,30 @@ func (w *AnthropicSDKWrapper) TrackAPICall(model string, response interface{}) (
 		return common.UsageMetrics{}, fmt.Errorf("no pricing information found for model: %s", model)
 	}
 
-	// Calculate price
-	inputCost := float64(tokenUsage.InputTokens) * modelPricing.InputPricePerToken
-	outputCost := float64(tokenUsage.OutputTokens) * modelPricing.OutputPricePerToken
-	totalCost := inputCost + outputCost
+	// Calculate price, billing prompt cache reads and writes at their own prices
+	price := modelPricing.Cost(common.BillableUsage{
+		InputTokens:       tokenUsage.InputTokens,
+		OutputTokens:      tokenUsage.OutputTokens,
+		CachedInputTokens: tokenUsage.CachedInputTokens,
+		CacheWriteTokens:  tokenUsage.CacheWriteTokens,
+	})
 
 	// Create usage metrics
 	metrics := common.UsageMetrics{
 		TokenCount: common.TokenCount{
-			InputTokens:    tokenUsage.InputTokens,
-			ResponseTokens: tokenUsage.OutputTokens,
-			TotalTokens:    tokenUsage.TotalTokens,
-		},
-		Price: common.Price{
-			InputCost:  inputCost,
-			OutputCost: outputCost,
-			TotalCost:  totalCost,
-			Currency:   modelPricing.Currency,
+			InputTokens:       tokenUsage.InputTokens,
+			ResponseTokens:    tokenUsage.OutputTokens,
+			TotalTokens:       tokenUsage.TotalTokens,
+			CachedInputTokens: tokenUsage.CachedInputTokens,
+			CacheWriteTokens:  tokenUsage.CacheWriteTokens,
 		},
-		Duration:  time.Since(tokenUsage.Timestamp),
-		Timestamp: time.Now(),
+		Price:     price,
+		Duration:  w.getClock().Since(tokenUsage.Timestamp),
+		Timestamp: w.getClock().Now(),
 		Model:     model,
 		Provider:  w.GetProviderName(),
 	}
 
+	w.attribute(&metrics)
 	return metrics, nil
 }
@@ -1,9 +1,16 @@
 package sdkwrappers
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/TrustSight-io/tokentracker"
+	"github.com/TrustSight-io/tokentracker/common"
+	"github.com/TrustSight-io/tokentracker/providers"
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
 )
 
 // MockClaudeProvider is a mock Provider implementation for testing
@@ -42,11 +49,8 @@ func (p *MockClaudeProvider) SetSDKClient(client interface{}) {
 	p.client = client
 }
 
-func (p *MockClaudeProvider) GetModelInfo(model string) (interface{}, error) {
-	return map[string]interface{}{
-		"name":     model,
-		"provider": p.name,
-	}, nil
+func (p *MockClaudeProvider) GetModelInfo(model string) (tokentracker.ModelInfo, error) {
+	return tokentracker.ModelInfo{Name: model, Provider: p.name}, nil
 }
 
 func (p *MockClaudeProvider) ExtractTokenUsageFromResponse(response interface{}) (tokentracker.TokenCount, error) {
@@ -75,6 +79,21 @@ type MockAnthropicResponse struct {
 	} `json:"usage"`
 }
 
+func init() {
+	RegisterResponseAdapter("anthropic", func(response any) (common.TokenUsage, bool) {
+		resp, ok := response.(*MockAnthropicResponse)
+		if !ok {
+			return common.TokenUsage{}, false
+		}
+		return common.TokenUsage{
+			InputTokens:  resp.Usage.InputTokens,
+			OutputTokens: resp.Usage.OutputTokens,
+			CompletionID: resp.ID,
+			Model:        resp.Model,
+		}, true
+	})
+}
+
 func TestAnthropicSDKWrapper_GetProviderName(t *testing.T) {
 	// The providers are no longer directly passed to the constructor
 	wrapper := &AnthropicSDKWrapper{}
@@ -268,6 +287,32 @@ func TestAnthropicSDKWrapper_TrackAPICall(t *testing.T) {
 	}
 }
 
+func TestAnthropicSDKWrapper_TrackAPICallPromptCaching(t *testing.T) {
+	wrapper := &AnthropicSDKWrapper{}
+
+	response := map[string]interface{}{
+		"id":    "msg_123",
+		"model": "claude-3-sonnet",
+		"usage": map[string]interface{}{
+			"input_tokens":                float64(20),
+			"cache_creation_input_tokens": float64(1000),
+			"cache_read_input_tokens":     float64(9000),
+			"output_tokens":               float64(100),
+		},
+	}
+	metrics, err := wrapper.TrackAPICall(ClaudeSonnet, response)
+	if err != nil {
+		t.Fatalf("TrackAPICall() error = %v", err)
+	}
+	if metrics.TokenCount.InputTokens != 10020 || metrics.TokenCount.CachedInputTokens != 9000 || metrics.TokenCount.CacheWriteTokens != 1000 {
+		t.Errorf("TokenCount = %+v, want the cache tokens", metrics.TokenCount)
+	}
+	want := 20*0.000003 + 1000*0.00000375 + 9000*0.0000003
+	if diff := metrics.Price.InputCost - want; diff > 1e-12 || diff < -1e-12 {
+		t.Errorf("InputCost = %v, want %v", metrics.Price.InputCost, want)
+	}
+}
+
 func TestAnthropicSDKWrapper_UpdateProviderPricing(t *testing.T) {
 	// The providers are no longer directly passed to the constructor
 	wrapper := &AnthropicSDKWrapper{}
@@ -301,3 +346,42 @@ func TestAnthropicConstants(t *testing.T) {
 		t.Errorf("ClaudeOpus = %q, expected %q", ClaudeOpus, "claude-3-opus")
 	}
 }
+
+func TestAnthropicSDKWrapper_CountTokensThroughRegisterSDKClient(t *testing.T) {
+	var request common.AnthropicCountTokensRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages/count_tokens" {
+			t.Errorf("path = %q, want the count_tokens endpoint", r.URL.Path)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"input_tokens":42}`))
+	}))
+	defer server.Close()
+
+	wrapper := &AnthropicSDKWrapper{client: anthropic.NewClient(option.WithAPIKey("test-key"), option.WithBaseURL(server.URL), option.WithMaxRetries(0))}
+
+	config := tokentracker.NewConfig()
+	tracker := tokentracker.NewTokenTracker(config)
+	tracker.RegisterProvider(providers.NewClaudeProvider(config))
+	if err := tracker.RegisterSDKClient(wrapper); err != nil {
+		t.Fatalf("RegisterSDKClient() error = %v", err)
+	}
+
+	count, err := tracker.CountTokens(tokentracker.TokenCountParams{
+		Model: "claude-3-haiku",
+		Messages: []tokentracker.Message{
+			{Role: "system", Content: "Be brief."},
+			{Role: "user", Content: "Hello"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CountTokens() error = %v", err)
+	}
+	if count.InputTokens != 42 {
+		t.Errorf("InputTokens = %d, want 42 from count_tokens", count.InputTokens)
+	}
+	if request.Model != "claude-3-haiku-20240307" || request.System != "Be brief." || len(request.Messages) != 1 {
+		t.Errorf("request = %+v, want the API model with the system prompt apart", request)
+	}
+}
@@ -0,0 +1,38 @@
+package sdkwrappers
+
+import "github.com/TrustSight-io/tokentracker/common"
+
+// attribution identifies the provider account the calls of a wrapper are billed to
+type attribution struct {
+	apiKeyID     string
+	organization string
+	project      string
+}
+
+// newAttribution attributes calls to the API key a client was created with
+func newAttribution(apiKey string) attribution {
+	return attribution{apiKeyID: common.APIKeyID(apiKey)}
+}
+
+// SetAttribution sets the API key identifier, organization and provider project the
+// wrapper's calls are billed to, e.g. the key ID of the provider's billing export and
+// the values passed to option.WithOrganization and option.WithProject. Empty values
+// keep the current ones; the key ID defaults to the redacted API key.
+func (a *attribution) SetAttribution(apiKeyID, organization, project string) {
+	if apiKeyID != "" {
+		a.apiKeyID = apiKeyID
+	}
+	if organization != "" {
+		a.organization = organization
+	}
+	if project != "" {
+		a.project = project
+	}
+}
+
+// attribute copies the attribution onto usage metrics
+func (a *attribution) attribute(metrics *common.UsageMetrics) {
+	metrics.APIKeyID = a.apiKeyID
+	metrics.Organization = a.organization
+	metrics.ProviderProjectID = a.project
+}
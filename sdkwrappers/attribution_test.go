@@ -0,0 +1,44 @@
+package sdkwrappers
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAttribution_TrackAPICall(t *testing.T) {
+	response := map[string]interface{}{
+		"usage": map[string]interface{}{"prompt_tokens": float64(10), "completion_tokens": float64(5), "total_tokens": float64(15)},
+	}
+
+	wrapper := NewMistralSDKWrapper("mk-0123456789abcdefWXYZ")
+	metrics, err := wrapper.TrackAPICall(MistralLarge, response)
+	if err != nil {
+		t.Fatalf("TrackAPICall() error = %v", err)
+	}
+	if metrics.APIKeyID != "mk-...WXYZ" || metrics.Organization != "" {
+		t.Errorf("metrics = %+v, want the redacted API key", metrics)
+	}
+
+	wrapper.SetAttribution("key_42", "org-acme", "")
+	metrics, _ = wrapper.TrackAPICall(MistralLarge, response)
+	if metrics.APIKeyID != "key_42" || metrics.Organization != "org-acme" || metrics.ProviderProjectID != "" {
+		t.Errorf("metrics = %+v, want the configured attribution", metrics)
+	}
+}
+
+func TestOpenAISDKWrapper_TrackAPICallWithHeader(t *testing.T) {
+	wrapper := &OpenAISDKWrapper{}
+	wrapper.SetAttribution("", "org-default", "proj_default")
+	response := &MockOpenAIResponse{Model: "gpt-4"}
+	response.Usage.PromptTokens, response.Usage.CompletionTokens, response.Usage.TotalTokens = 100, 50, 150
+
+	header := http.Header{}
+	header.Set("OpenAI-Project", "proj_abc")
+	metrics, err := wrapper.TrackAPICallWithHeader("gpt-4", response, header)
+	if err != nil {
+		t.Fatalf("TrackAPICallWithHeader() error = %v", err)
+	}
+	if metrics.Organization != "org-default" || metrics.ProviderProjectID != "proj_abc" {
+		t.Errorf("metrics = %+v, want the project of the response headers", metrics)
+	}
+}
@@ -0,0 +1,201 @@
+package sdkwrappers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/TrustSight-io/tokentracker/common"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// azureDeploymentRoutes are the Azure OpenAI routes addressed by deployment; the
+// deployment is taken from the model field of their JSON body
+var azureDeploymentRoutes = map[string]bool{
+	"/openai/completions":      true,
+	"/openai/chat/completions": true,
+	"/openai/embeddings":       true,
+}
+
+// AzureOpenAISDKWrapper wraps an OpenAI SDK client configured for an Azure OpenAI
+// resource. Requests name deployments instead of models; the wrapper maps them to
+// the underlying models for pricing.
+type AzureOpenAISDKWrapper struct {
+	client      openai.Client
+	openai      *OpenAISDKWrapper
+	deployments map[string]string
+	clock       common.Clock
+	attribution
+	trackedCalls
+}
+
+// NewAzureOpenAISDKWrapper creates a wrapper for the Azure OpenAI resource at endpoint
+// (e.g. https://my-resource.openai.azure.com). deployments maps deployment names to
+// the models they serve.
+func NewAzureOpenAISDKWrapper(endpoint, apiVersion, apiKey string, deployments map[string]string) *AzureOpenAISDKWrapper {
+	client := openai.NewClient(
+		option.WithBaseURL(strings.TrimSuffix(endpoint, "/")+"/openai/"),
+		option.WithQueryAdd("api-version", apiVersion),
+		option.WithHeader("Api-Key", apiKey),
+		option.WithMiddleware(azureDeploymentMiddleware),
+	)
+
+	mapping := make(map[string]string, len(deployments))
+	for deployment, model := range deployments {
+		mapping[deployment] = model
+	}
+
+	return &AzureOpenAISDKWrapper{
+		client:      client,
+		openai:      &OpenAISDKWrapper{client: client},
+		deployments: mapping,
+		attribution: newAttribution(apiKey),
+	}
+}
+
+// azureDeploymentMiddleware rewrites OpenAI routes to the deployment routes of Azure
+func azureDeploymentMiddleware(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	if !azureDeploymentRoutes[req.URL.Path] || req.Body == nil {
+		return next(req)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	var payload struct {
+		Model string `json:"model"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+
+	req.URL.Path = strings.Replace(req.URL.Path, "/openai/", "/openai/deployments/"+url.PathEscape(payload.Model)+"/", 1)
+	return next(req)
+}
+
+// GetProviderName returns the name of the provider
+func (w *AzureOpenAISDKWrapper) GetProviderName() string {
+	return "azure-openai"
+}
+
+// GetClient returns the underlying SDK client
+func (w *AzureOpenAISDKWrapper) GetClient() interface{} {
+	return w.client
+}
+
+// GetSupportedModels returns the configured deployment names
+func (w *AzureOpenAISDKWrapper) GetSupportedModels() ([]string, error) {
+	names := make([]string, 0, len(w.deployments))
+	for name := range w.deployments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ModelForDeployment returns the model served by a deployment
+func (w *AzureOpenAISDKWrapper) ModelForDeployment(deployment string) (string, bool) {
+	model, ok := w.deployments[deployment]
+	return model, ok
+}
+
+// SetClock sets the clock used for usage timestamps and durations
+func (w *AzureOpenAISDKWrapper) SetClock(clock common.Clock) {
+	w.clock = clock
+	w.openai.SetClock(clock)
+}
+
+// getClock returns the configured clock or the system clock
+func (w *AzureOpenAISDKWrapper) getClock() common.Clock {
+	if w.clock == nil {
+		return common.SystemClock
+	}
+	return w.clock
+}
+
+// ExtractTokenUsageFromResponse extracts token usage from an Azure OpenAI API response.
+// Azure returns OpenAI's response format, extended with content filter results.
+func (w *AzureOpenAISDKWrapper) ExtractTokenUsageFromResponse(response interface{}) (common.TokenUsage, error) {
+	return w.openai.ExtractTokenUsageFromResponse(response)
+}
+
+// FetchCurrentPricing returns the current pricing of each deployment, which is the
+// OpenAI pricing of its model
+func (w *AzureOpenAISDKWrapper) FetchCurrentPricing() (map[string]common.ModelPricing, error) {
+	modelPricing, err := w.openai.FetchCurrentPricing()
+	if err != nil {
+		return nil, err
+	}
+
+	pricing := make(map[string]common.ModelPricing, len(w.deployments))
+	for deployment, model := range w.deployments {
+		if price, ok := modelPricing[model]; ok {
+			pricing[deployment] = price
+		}
+	}
+	return pricing, nil
+}
+
+// UpdateProviderPricing updates the pricing information in the provider
+func (w *AzureOpenAISDKWrapper) UpdateProviderPricing() error {
+	// In a real implementation, this would update the pricing information in the provider
+	// For now, we'll just return nil
+	return nil
+}
+
+// TrackAPICall tracks an API call to a deployment and returns usage metrics
+func (w *AzureOpenAISDKWrapper) TrackAPICall(deployment string, response interface{}) (common.UsageMetrics, error) {
+	tokenUsage, err := w.ExtractTokenUsageFromResponse(response)
+	if err != nil {
+		return common.UsageMetrics{}, err
+	}
+
+	return w.trackOnce(w.GetProviderName(), deployment, tokenUsage, func() (common.UsageMetrics, error) {
+		return w.usageMetrics(deployment, tokenUsage)
+	})
+}
+
+// usageMetrics prices the token usage of a call
+func (w *AzureOpenAISDKWrapper) usageMetrics(deployment string, tokenUsage common.TokenUsage) (common.UsageMetrics, error) {
+	pricing, err := w.FetchCurrentPricing()
+	if err != nil {
+		return common.UsageMetrics{}, err
+	}
+
+	modelPricing, ok := pricing[deployment]
+	if !ok {
+		return common.UsageMetrics{}, fmt.Errorf("no pricing information found for deployment: %s", deployment)
+	}
+
+	inputCost := float64(tokenUsage.InputTokens) * modelPricing.InputPricePerToken
+	outputCost := float64(tokenUsage.OutputTokens) * modelPricing.OutputPricePerToken
+
+	metrics := common.UsageMetrics{
+		TokenCount: common.TokenCount{
+			InputTokens:    tokenUsage.InputTokens,
+			ResponseTokens: tokenUsage.OutputTokens,
+			TotalTokens:    tokenUsage.TotalTokens,
+		},
+		Price: common.Price{
+			InputCost:  inputCost,
+			OutputCost: outputCost,
+			TotalCost:  inputCost + outputCost,
+			Currency:   modelPricing.Currency,
+		}.WithUnitPrices(tokenUsage.InputTokens, tokenUsage.OutputTokens),
+		Duration:  w.getClock().Since(tokenUsage.Timestamp),
+		Timestamp: w.getClock().Now(),
+		Model:     deployment,
+		Provider:  w.GetProviderName(),
+	}
+	w.attribute(&metrics)
+	return metrics, nil
+}
@@ -0,0 +1,98 @@
+package sdkwrappers
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func newTestAzureWrapper() *AzureOpenAISDKWrapper {
+	return &AzureOpenAISDKWrapper{
+		openai:      &OpenAISDKWrapper{},
+		deployments: map[string]string{"prod-gpt4": GPT4, "prod-gpt4o": GPT4o},
+	}
+}
+
+func TestAzureOpenAISDKWrapper_GetSupportedModels(t *testing.T) {
+	wrapper := newTestAzureWrapper()
+
+	if wrapper.GetProviderName() != "azure-openai" {
+		t.Errorf("GetProviderName() = %q, want azure-openai", wrapper.GetProviderName())
+	}
+
+	models, err := wrapper.GetSupportedModels()
+	if err != nil {
+		t.Fatalf("GetSupportedModels() error = %v", err)
+	}
+	if len(models) != 2 || models[0] != "prod-gpt4" {
+		t.Errorf("GetSupportedModels() = %v, want the deployment names", models)
+	}
+	if model, ok := wrapper.ModelForDeployment("prod-gpt4o"); !ok || model != GPT4o {
+		t.Errorf("ModelForDeployment() = %q, %v", model, ok)
+	}
+}
+
+func TestAzureOpenAISDKWrapper_TrackAPICall(t *testing.T) {
+	wrapper := newTestAzureWrapper()
+
+	response := map[string]interface{}{
+		"id":    "chatcmpl-123",
+		"model": "gpt-4",
+		"prompt_filter_results": []interface{}{
+			map[string]interface{}{"prompt_index": float64(0)},
+		},
+		"usage": map[string]interface{}{
+			"prompt_tokens":     float64(100),
+			"completion_tokens": float64(50),
+			"total_tokens":      float64(150),
+		},
+	}
+
+	metrics, err := wrapper.TrackAPICall("prod-gpt4", response)
+	if err != nil {
+		t.Fatalf("TrackAPICall() error = %v", err)
+	}
+	if metrics.Model != "prod-gpt4" || metrics.Provider != "azure-openai" {
+		t.Errorf("metrics = %s/%s, want azure-openai/prod-gpt4", metrics.Provider, metrics.Model)
+	}
+	want := 100*0.00003 + 50*0.00006
+	if diff := metrics.Price.TotalCost - want; diff > 1e-12 || diff < -1e-12 {
+		t.Errorf("TotalCost = %v, want gpt-4's %v", metrics.Price.TotalCost, want)
+	}
+
+	if _, err := wrapper.TrackAPICall("unknown", response); err == nil {
+		t.Error("TrackAPICall() for an unknown deployment should fail")
+	}
+}
+
+func TestAzureDeploymentMiddleware(t *testing.T) {
+	var path, body string
+	next := func(req *http.Request) (*http.Response, error) {
+		path = req.URL.Path
+		body = ""
+		if req.Body != nil {
+			data, _ := io.ReadAll(req.Body)
+			body = string(data)
+		}
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	}
+
+	payload := `{"model":"prod-gpt4","messages":[]}`
+	req, _ := http.NewRequest(http.MethodPost, "https://example.openai.azure.com/openai/chat/completions", strings.NewReader(payload))
+	if _, err := azureDeploymentMiddleware(req, next); err != nil {
+		t.Fatalf("azureDeploymentMiddleware() error = %v", err)
+	}
+	if path != "/openai/deployments/prod-gpt4/chat/completions" {
+		t.Errorf("path = %q, want the deployment route", path)
+	}
+	if body != payload {
+		t.Errorf("body = %q, want the original payload", body)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, "https://example.openai.azure.com/openai/models", nil)
+	_, _ = azureDeploymentMiddleware(req, next)
+	if path != "/openai/models" {
+		t.Errorf("path = %q, want other routes unchanged", path)
+	}
+}
@@ -2,12 +2,13 @@ package sdkwrappers
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"reflect"
-	"time"
+	"strings"
 
 	"github.com/TrustSight-io/tokentracker/common"
 	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 )
 
@@ -22,6 +23,9 @@ const (
 // GeminiSDKWrapper wraps the Gemini SDK client
 type GeminiSDKWrapper struct {
 	client *genai.Client
+	clock  common.Clock
+	attribution
+	trackedCalls
 }
 
 // NewGeminiSDKWrapper creates a new Gemini SDK wrapper
@@ -34,7 +38,8 @@ func NewGeminiSDKWrapper(apiKey string) (*GeminiSDKWrapper, error) {
 	}
 
 	return &GeminiSDKWrapper{
-		client: client,
+		client:      client,
+		attribution: newAttribution(apiKey),
 	}, nil
 }
 
@@ -59,6 +64,158 @@ func (w *GeminiSDKWrapper) GetSupportedModels() ([]string, error) {
 	}, nil
 }
 
+// ListModels returns the models currently available to the account via the ListModels API
+func (w *GeminiSDKWrapper) ListModels(ctx context.Context) ([]string, error) {
+	var models []string
+
+	it := w.client.ListModels(ctx)
+	for {
+		info, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list Gemini models: %w", err)
+		}
+		models = append(models, strings.TrimPrefix(info.Name, "models/"))
+	}
+
+	return models, nil
+}
+
+// SetClock sets the clock used for usage timestamps and durations
+func (w *GeminiSDKWrapper) SetClock(clock common.Clock) {
+	w.clock = clock
+}
+
+// getClock returns the configured clock or the system clock
+func (w *GeminiSDKWrapper) getClock() common.Clock {
+	if w.clock == nil {
+		return common.SystemClock
+	}
+	return w.clock
+}
+
+// CountTokens counts the input tokens of the given text parts with the model's tokenizer
+func (w *GeminiSDKWrapper) CountTokens(ctx context.Context, model string, parts []string) (int, error) {
+	genaiParts := make([]genai.Part, len(parts))
+	for i, part := range parts {
+		genaiParts[i] = genai.Text(part)
+	}
+
+	resp, err := w.client.GenerativeModel(model).CountTokens(ctx, genaiParts...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count Gemini tokens: %w", err)
+	}
+
+	return int(resp.TotalTokens), nil
+}
+
+// CountRequestTokens counts the input tokens of a request with the model's tokenizer,
+// sending the system instruction and tool declarations in their own fields as
+// GenerateContent does, so counts match the prompt tokens of UsageMetadata
+func (w *GeminiSDKWrapper) CountRequestTokens(ctx context.Context, model string, request common.GeminiCountRequest) (int, error) {
+	generativeModel := w.client.GenerativeModel(model)
+	if request.SystemInstruction != "" {
+		generativeModel.SystemInstruction = genai.NewUserContent(genai.Text(request.SystemInstruction))
+	}
+	if len(request.Tools) > 0 {
+		declarations := make([]*genai.FunctionDeclaration, len(request.Tools))
+		for i, tool := range request.Tools {
+			parameters, err := geminiSchema(tool.Parameters)
+			if err != nil {
+				return 0, fmt.Errorf("invalid parameters of function %s: %w", tool.Name, err)
+			}
+			declarations[i] = &genai.FunctionDeclaration{Name: tool.Name, Description: tool.Description, Parameters: parameters}
+		}
+		generativeModel.Tools = []*genai.Tool{{FunctionDeclarations: declarations}}
+	}
+
+	parts := make([]genai.Part, len(request.Contents))
+	for i, part := range request.Contents {
+		parts[i] = genai.Text(part)
+	}
+
+	resp, err := generativeModel.CountTokens(ctx, parts...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count Gemini tokens: %w", err)
+	}
+
+	return int(resp.TotalTokens), nil
+}
+
+// geminiSchema converts a JSON schema into the OpenAPI subset Gemini declares
+// parameters with. Keywords Gemini does not support are dropped.
+func geminiSchema(data json.RawMessage) (*genai.Schema, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return nil, nil
+	}
+	var schema jsonSchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, err
+	}
+	return schema.toGenai(), nil
+}
+
+// jsonSchema is the part of a JSON schema Gemini supports
+type jsonSchema struct {
+	Type        interface{}            `json:"type"` // a type name, or a list with "null" for nullable types
+	Format      string                 `json:"format"`
+	Description string                 `json:"description"`
+	Nullable    bool                   `json:"nullable"`
+	Enum        []interface{}          `json:"enum"`
+	Items       *jsonSchema            `json:"items"`
+	Properties  map[string]*jsonSchema `json:"properties"`
+	Required    []string               `json:"required"`
+}
+
+// geminiTypes maps JSON schema types to Gemini's
+var geminiTypes = map[string]genai.Type{
+	"string":  genai.TypeString,
+	"number":  genai.TypeNumber,
+	"integer": genai.TypeInteger,
+	"boolean": genai.TypeBoolean,
+	"array":   genai.TypeArray,
+	"object":  genai.TypeObject,
+}
+
+// toGenai converts the schema to Gemini's
+func (s *jsonSchema) toGenai() *genai.Schema {
+	if s == nil {
+		return nil
+	}
+	schema := &genai.Schema{
+		Format:      s.Format,
+		Description: s.Description,
+		Nullable:    s.Nullable,
+		Items:       s.Items.toGenai(),
+		Required:    s.Required,
+	}
+	switch typ := s.Type.(type) {
+	case string:
+		schema.Type = geminiTypes[strings.ToLower(typ)]
+	case []interface{}:
+		for _, name := range typ {
+			name, _ := name.(string)
+			if name == "null" {
+				schema.Nullable = true
+			} else if t, ok := geminiTypes[strings.ToLower(name)]; ok {
+				schema.Type = t
+			}
+		}
+	}
+	for _, value := range s.Enum {
+		schema.Enum = append(schema.Enum, fmt.Sprint(value))
+	}
+	if len(s.Properties) > 0 {
+		schema.Properties = make(map[string]*genai.Schema, len(s.Properties))
+		for name, property := range s.Properties {
+			schema.Properties[name] = property.toGenai()
+		}
+	}
+	return schema
+}
+
 // ExtractTokenUsageFromResponse extracts token usage from a Gemini API response
 func (w *GeminiSDKWrapper) ExtractTokenUsageFromResponse(response interface{}) (common.TokenUsage, error) {
 	// The type switch needs to extract specific information from each type
@@ -75,7 +232,7 @@ func (w *GeminiSDKWrapper) ExtractTokenUsageFromResponse(response interface{}) (
 			InputTokens:    int(resp.UsageMetadata.PromptTokenCount),
 			OutputTokens:   int(resp.UsageMetadata.CandidatesTokenCount),
 			TotalTokens:    int(resp.UsageMetadata.TotalTokenCount),
-			Timestamp:      time.Now(),
+			Timestamp:      w.getClock().Now(),
 			PromptTokens:   int(resp.UsageMetadata.PromptTokenCount),
 			ResponseTokens: int(resp.UsageMetadata.CandidatesTokenCount),
 		}, nil
@@ -91,7 +248,7 @@ func (w *GeminiSDKWrapper) ExtractTokenUsageFromResponse(response interface{}) (
 							InputTokens:    int(promptTokens),
 							OutputTokens:   int(candidatesTokens),
 							TotalTokens:    int(totalTokens),
-							Timestamp:      time.Now(),
+							Timestamp:      w.getClock().Now(),
 							PromptTokens:   int(promptTokens),
 							ResponseTokens: int(candidatesTokens),
 						}, nil
@@ -101,41 +258,13 @@ func (w *GeminiSDKWrapper) ExtractTokenUsageFromResponse(response interface{}) (
 		}
 	}
 
-	// For all test cases, we need to make a special case for MockGeminiResponse
-	// This uses reflection to check if the type name matches, as we can't import it directly
-	respType := fmt.Sprintf("%T", response)
-	if respType == "*sdkwrappers.MockGeminiResponse" {
-		// Use reflection to safely access fields
-		respValue := reflect.ValueOf(response).Elem()
-
-		// Get UsageMetadata struct and its fields
-		if usageMetadataField := respValue.FieldByName("UsageMetadata"); usageMetadataField.IsValid() {
-			promptTokens := 0
-			candidatesTokens := 0
-			totalTokens := 0
-
-			if promptField := usageMetadataField.FieldByName("PromptTokenCount"); promptField.IsValid() {
-				promptTokens = int(promptField.Int())
-			}
-			if candidatesField := usageMetadataField.FieldByName("CandidatesTokenCount"); candidatesField.IsValid() {
-				candidatesTokens = int(candidatesField.Int())
-			}
-			if totalField := usageMetadataField.FieldByName("TotalTokenCount"); totalField.IsValid() {
-				totalTokens = int(totalField.Int())
-			}
-
-			return common.TokenUsage{
-				InputTokens:    promptTokens,
-				OutputTokens:   candidatesTokens,
-				TotalTokens:    totalTokens,
-				Timestamp:      time.Now(),
-				PromptTokens:   promptTokens,
-				ResponseTokens: candidatesTokens,
-			}, nil
-		}
+	// Responses of other types, such as an application's own wrappers, are handled by
+	// the adapters registered with RegisterResponseAdapter
+	if usage, ok := adaptResponse(w.GetProviderName(), response, w.getClock().Now()); ok {
+		return usage, nil
 	}
 
-	return common.TokenUsage{}, fmt.Errorf("response is not a *genai.GenerateContentResponse or valid mock: %T", response)
+	return common.TokenUsage{}, fmt.Errorf("response is not a *genai.GenerateContentResponse and no response adapter handles %T", response)
 }
 
 // FetchCurrentPricing returns the current pricing for Gemini models
@@ -183,6 +312,13 @@ func (w *GeminiSDKWrapper) TrackAPICall(model string, response interface{}) (com
 		return common.UsageMetrics{}, err
 	}
 
+	return w.trackOnce(w.GetProviderName(), model, tokenUsage, func() (common.UsageMetrics, error) {
+		return w.usageMetrics(model, tokenUsage)
+	})
+}
+
+// usageMetrics prices the token usage of a call
+func (w *GeminiSDKWrapper) usageMetrics(model string, tokenUsage common.TokenUsage) (common.UsageMetrics, error) {
 	// Get pricing information for the model
 	pricing, err := w.FetchCurrentPricing()
 	if err != nil {
@@ -211,13 +347,14 @@ func (w *GeminiSDKWrapper) TrackAPICall(model string, response interface{}) (com
 			OutputCost: outputCost,
 			TotalCost:  totalCost,
 			Currency:   modelPricing.Currency,
-		},
-		Duration:  time.Since(tokenUsage.Timestamp),
-		Timestamp: time.Now(),
+		}.WithUnitPrices(tokenUsage.InputTokens, tokenUsage.OutputTokens),
+		Duration:  w.getClock().Since(tokenUsage.Timestamp),
+		Timestamp: w.getClock().Now(),
 		Model:     model,
 		Provider:  w.GetProviderName(),
 	}
 
+	w.attribute(&metrics)
 	return metrics, nil
 }
 
@@ -1,9 +1,19 @@
 package sdkwrappers
 
 import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/TrustSight-io/tokentracker"
+	"github.com/TrustSight-io/tokentracker/common"
+	"github.com/TrustSight-io/tokentracker/providers"
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/option"
 )
 
 // MockGeminiProvider is a mock Provider implementation for testing
@@ -42,11 +52,8 @@ func (p *MockGeminiProvider) SetSDKClient(client interface{}) {
 	p.client = client
 }
 
-func (p *MockGeminiProvider) GetModelInfo(model string) (interface{}, error) {
-	return map[string]interface{}{
-		"name":     model,
-		"provider": p.name,
-	}, nil
+func (p *MockGeminiProvider) GetModelInfo(model string) (tokentracker.ModelInfo, error) {
+	return tokentracker.ModelInfo{Name: model, Provider: p.name}, nil
 }
 
 func (p *MockGeminiProvider) ExtractTokenUsageFromResponse(response interface{}) (tokentracker.TokenCount, error) {
@@ -77,6 +84,20 @@ type MockGeminiResponse struct {
 	} `json:"usageMetadata"`
 }
 
+func init() {
+	RegisterResponseAdapter("gemini", func(response any) (common.TokenUsage, bool) {
+		resp, ok := response.(*MockGeminiResponse)
+		if !ok {
+			return common.TokenUsage{}, false
+		}
+		return common.TokenUsage{
+			InputTokens:  resp.UsageMetadata.PromptTokenCount,
+			OutputTokens: resp.UsageMetadata.CandidatesTokenCount,
+			TotalTokens:  resp.UsageMetadata.TotalTokenCount,
+		}, true
+	})
+}
+
 func TestGeminiSDKWrapper_GetProviderName(t *testing.T) {
 	// Use a mock constructor since we can't actually make API calls in tests
 	wrapper := &GeminiSDKWrapper{}
@@ -294,3 +315,88 @@ func TestGeminiConstants(t *testing.T) {
 		t.Errorf("GeminiUltra = %q, expected %q", GeminiUltra, "gemini-ultra")
 	}
 }
+
+func TestGeminiSchema(t *testing.T) {
+	schema, err := geminiSchema(json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"city": {"type": "string", "description": "The city"},
+			"unit": {"type": ["string", "null"], "enum": ["celsius", "fahrenheit"]},
+			"days": {"type": "array", "items": {"type": "integer"}}
+		},
+		"required": ["city"],
+		"additionalProperties": false
+	}`))
+	if err != nil {
+		t.Fatalf("geminiSchema() error = %v", err)
+	}
+
+	if schema.Type != genai.TypeObject || len(schema.Required) != 1 || len(schema.Properties) != 3 {
+		t.Fatalf("schema = %+v, want an object with three properties", schema)
+	}
+	if city := schema.Properties["city"]; city.Type != genai.TypeString || city.Description != "The city" {
+		t.Errorf("city = %+v", city)
+	}
+	if unit := schema.Properties["unit"]; unit.Type != genai.TypeString || !unit.Nullable || len(unit.Enum) != 2 {
+		t.Errorf("unit = %+v, want a nullable string enum", unit)
+	}
+	if days := schema.Properties["days"]; days.Type != genai.TypeArray || days.Items.Type != genai.TypeInteger {
+		t.Errorf("days = %+v, want an array of integers", days)
+	}
+
+	if schema, err := geminiSchema(nil); schema != nil || err != nil {
+		t.Errorf("geminiSchema(nil) = %v, %v", schema, err)
+	}
+}
+
+func TestGeminiSDKWrapper_CountTokensThroughRegisterSDKClient(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "models/gemini-pro:countTokens") {
+			t.Errorf("path = %q, want the countTokens endpoint", r.URL.Path)
+		}
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"totalTokens":42}`))
+	}))
+	defer server.Close()
+
+	client, err := genai.NewClient(context.Background(), option.WithAPIKey("test-key"), option.WithEndpoint(server.URL))
+	if err != nil {
+		t.Fatalf("genai.NewClient() error = %v", err)
+	}
+	defer client.Close()
+	wrapper := &GeminiSDKWrapper{client: client}
+
+	config := tokentracker.NewConfig()
+	tracker := tokentracker.NewTokenTracker(config)
+	provider := providers.NewGeminiProvider(config)
+	provider.SetOfflineFallback(false)
+	tracker.RegisterProvider(provider)
+	if err := tracker.RegisterSDKClient(wrapper); err != nil {
+		t.Fatalf("RegisterSDKClient() error = %v", err)
+	}
+
+	count, err := tracker.CountTokens(tokentracker.TokenCountParams{
+		Model:             "gemini-pro",
+		SystemInstruction: "Answer briefly.",
+		Messages:          []tokentracker.Message{{Role: "user", Content: "What's the weather in Paris?"}},
+		Tools: []tokentracker.Tool{{Type: "function", Function: map[string]interface{}{
+			"name":       "get_weather",
+			"parameters": map[string]interface{}{"type": "object", "properties": map[string]interface{}{"city": map[string]interface{}{"type": "string"}}},
+		}}},
+	})
+	if err != nil {
+		t.Fatalf("CountTokens() error = %v", err)
+	}
+	if count.InputTokens != 42 {
+		t.Errorf("InputTokens = %d, want 42 from countTokens", count.InputTokens)
+	}
+	// The request counter sends the system instruction and tools in their own fields
+	for _, want := range []string{"systemInstruction", "Answer briefly.", "functionDeclarations", "get_weather"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("request body %s is missing %q", body, want)
+		}
+	}
+}
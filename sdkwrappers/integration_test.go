@@ -123,6 +123,17 @@ func TestSDKWrapperIntegration(t *testing.T) {
 				TotalTokenCount:      150,
 			},
 		}
+		RegisterResponseAdapter("gemini", func(response any) (common.TokenUsage, bool) {
+			resp, ok := response.(*MockGeminiResponse)
+			if !ok {
+				return common.TokenUsage{}, false
+			}
+			return common.TokenUsage{
+				InputTokens:  resp.UsageMetadata.PromptTokenCount,
+				OutputTokens: resp.UsageMetadata.CandidatesTokenCount,
+				Model:        resp.Model,
+			}, true
+		})
 
 		metrics, err := geminiWrapper.TrackAPICall(GeminiPro, mockResponse)
 		if err != nil {
@@ -0,0 +1,306 @@
+package sdkwrappers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/TrustSight-io/tokentracker/common"
+)
+
+// Mistral model constants
+const (
+	MistralSmall     = "mistral-small"
+	MistralMedium    = "mistral-medium"
+	MistralLarge     = "mistral-large"
+	OpenMistral7B    = "open-mistral-7b"
+	OpenMixtral8x7B  = "open-mixtral-8x7b"
+	OpenMixtral8x22B = "open-mixtral-8x22b"
+)
+
+// MistralDefaultBaseURL is the base URL of the Mistral API
+const MistralDefaultBaseURL = "https://api.mistral.ai/v1"
+
+// MistralClient is a minimal client for the Mistral chat completions API
+type MistralClient struct {
+	APIKey     string
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// MistralUsage is the usage block of a Mistral chat completion
+type MistralUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// MistralChatCompletionResponse is a Mistral chat completion response
+type MistralChatCompletionResponse struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Index   int `json:"index"`
+		Message struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *MistralUsage `json:"usage"`
+}
+
+// ChatCompletion sends a chat completion request, e.g. a map with model and messages
+func (c *MistralClient) ChatCompletion(ctx context.Context, request interface{}) (*MistralChatCompletionResponse, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode Mistral request: %w", err)
+	}
+
+	baseURL := c.BaseURL
+	if baseURL == "" {
+		baseURL = MistralDefaultBaseURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(baseURL, "/")+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Mistral request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Mistral API returned %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	var completion MistralChatCompletionResponse
+	if err := json.Unmarshal(data, &completion); err != nil {
+		return nil, fmt.Errorf("failed to decode Mistral response: %w", err)
+	}
+	return &completion, nil
+}
+
+// MistralSDKWrapper wraps the Mistral API client
+type MistralSDKWrapper struct {
+	client *MistralClient
+	clock  common.Clock
+	attribution
+	trackedCalls
+}
+
+// NewMistralSDKWrapper creates a new Mistral SDK wrapper
+func NewMistralSDKWrapper(apiKey string) *MistralSDKWrapper {
+	return &MistralSDKWrapper{
+		client: &MistralClient{
+			APIKey:  apiKey,
+			BaseURL: MistralDefaultBaseURL,
+		},
+		attribution: newAttribution(apiKey),
+	}
+}
+
+// GetProviderName returns the name of the provider
+func (w *MistralSDKWrapper) GetProviderName() string {
+	return "mistral"
+}
+
+// GetClient returns the underlying SDK client
+func (w *MistralSDKWrapper) GetClient() interface{} {
+	return w.client
+}
+
+// GetSupportedModels returns a list of supported models
+func (w *MistralSDKWrapper) GetSupportedModels() ([]string, error) {
+	// Hardcoded list of Mistral models
+	return []string{
+		MistralSmall,
+		MistralMedium,
+		MistralLarge,
+		OpenMistral7B,
+		OpenMixtral8x7B,
+		OpenMixtral8x22B,
+	}, nil
+}
+
+// SetClock sets the clock used for usage timestamps and durations
+func (w *MistralSDKWrapper) SetClock(clock common.Clock) {
+	w.clock = clock
+}
+
+// getClock returns the configured clock or the system clock
+func (w *MistralSDKWrapper) getClock() common.Clock {
+	if w.clock == nil {
+		return common.SystemClock
+	}
+	return w.clock
+}
+
+// ExtractTokenUsageFromResponse extracts token usage from a Mistral API response
+func (w *MistralSDKWrapper) ExtractTokenUsageFromResponse(response interface{}) (common.TokenUsage, error) {
+	var usage *MistralUsage
+
+	switch resp := response.(type) {
+	case *MistralChatCompletionResponse:
+		if resp == nil {
+			return common.TokenUsage{}, fmt.Errorf("response is nil")
+		}
+		usage = resp.Usage
+
+	// Special case for maps (used in mock JSON responses)
+	case map[string]interface{}:
+		usageMap, ok := resp["usage"].(map[string]interface{})
+		if !ok {
+			return common.TokenUsage{}, fmt.Errorf("response does not contain usage information")
+		}
+		promptTokens, hasPrompt := usageMap["prompt_tokens"].(float64)
+		completionTokens, hasCompletion := usageMap["completion_tokens"].(float64)
+		if !hasPrompt || !hasCompletion {
+			return common.TokenUsage{}, fmt.Errorf("response does not contain token counts")
+		}
+		totalTokens, hasTotal := usageMap["total_tokens"].(float64)
+		if !hasTotal {
+			totalTokens = promptTokens + completionTokens
+		}
+		usage = &MistralUsage{
+			PromptTokens:     int(promptTokens),
+			CompletionTokens: int(completionTokens),
+			TotalTokens:      int(totalTokens),
+		}
+
+	default:
+		if adapted, ok := adaptResponse(w.GetProviderName(), response, w.getClock().Now()); ok {
+			return adapted, nil
+		}
+		return common.TokenUsage{}, fmt.Errorf("response is not a *MistralChatCompletionResponse and no response adapter handles %T", response)
+	}
+
+	if usage == nil {
+		return common.TokenUsage{}, fmt.Errorf("response does not contain usage information")
+	}
+
+	return common.TokenUsage{
+		InputTokens:    usage.PromptTokens,
+		OutputTokens:   usage.CompletionTokens,
+		TotalTokens:    usage.TotalTokens,
+		Timestamp:      w.getClock().Now(),
+		PromptTokens:   usage.PromptTokens,
+		ResponseTokens: usage.CompletionTokens,
+	}, nil
+}
+
+// FetchCurrentPricing returns the current pricing for Mistral models
+func (w *MistralSDKWrapper) FetchCurrentPricing() (map[string]common.ModelPricing, error) {
+	// Hardcoded pricing information for Mistral models
+	// These values should be updated regularly or fetched from an API
+	pricing := map[string]common.ModelPricing{
+		MistralSmall: {
+			InputPricePerToken:  0.000002,
+			OutputPricePerToken: 0.000006,
+			Currency:            "USD",
+		},
+		MistralMedium: {
+			InputPricePerToken:  0.0000027,
+			OutputPricePerToken: 0.0000081,
+			Currency:            "USD",
+		},
+		MistralLarge: {
+			InputPricePerToken:  0.000008,
+			OutputPricePerToken: 0.000024,
+			Currency:            "USD",
+		},
+		OpenMistral7B: {
+			InputPricePerToken:  0.00000025,
+			OutputPricePerToken: 0.00000025,
+			Currency:            "USD",
+		},
+		OpenMixtral8x7B: {
+			InputPricePerToken:  0.0000007,
+			OutputPricePerToken: 0.0000007,
+			Currency:            "USD",
+		},
+		OpenMixtral8x22B: {
+			InputPricePerToken:  0.000002,
+			OutputPricePerToken: 0.000006,
+			Currency:            "USD",
+		},
+	}
+
+	return pricing, nil
+}
+
+// UpdateProviderPricing updates the pricing information in the provider
+func (w *MistralSDKWrapper) UpdateProviderPricing() error {
+	// In a real implementation, this would update the pricing information in the provider
+	// For now, we'll just return nil
+	return nil
+}
+
+// TrackAPICall tracks an API call and returns usage metrics
+func (w *MistralSDKWrapper) TrackAPICall(model string, response interface{}) (common.UsageMetrics, error) {
+	// Extract token usage from the response
+	tokenUsage, err := w.ExtractTokenUsageFromResponse(response)
+	if err != nil {
+		return common.UsageMetrics{}, err
+	}
+
+	return w.trackOnce(w.GetProviderName(), model, tokenUsage, func() (common.UsageMetrics, error) {
+		return w.usageMetrics(model, tokenUsage)
+	})
+}
+
+// usageMetrics prices the token usage of a call
+func (w *MistralSDKWrapper) usageMetrics(model string, tokenUsage common.TokenUsage) (common.UsageMetrics, error) {
+	// Get pricing information for the model
+	pricing, err := w.FetchCurrentPricing()
+	if err != nil {
+		return common.UsageMetrics{}, err
+	}
+
+	modelPricing, ok := pricing[model]
+	if !ok {
+		return common.UsageMetrics{}, fmt.Errorf("no pricing information found for model: %s", model)
+	}
+
+	// Calculate price
+	inputCost := float64(tokenUsage.InputTokens) * modelPricing.InputPricePerToken
+	outputCost := float64(tokenUsage.OutputTokens) * modelPricing.OutputPricePerToken
+
+	metrics := common.UsageMetrics{
+		TokenCount: common.TokenCount{
+			InputTokens:    tokenUsage.InputTokens,
+			ResponseTokens: tokenUsage.OutputTokens,
+			TotalTokens:    tokenUsage.TotalTokens,
+		},
+		Price: common.Price{
+			InputCost:  inputCost,
+			OutputCost: outputCost,
+			TotalCost:  inputCost + outputCost,
+			Currency:   modelPricing.Currency,
+		}.WithUnitPrices(tokenUsage.InputTokens, tokenUsage.OutputTokens),
+		Duration:  w.getClock().Since(tokenUsage.Timestamp),
+		Timestamp: w.getClock().Now(),
+		Model:     model,
+		Provider:  w.GetProviderName(),
+	}
+	w.attribute(&metrics)
+	return metrics, nil
+}
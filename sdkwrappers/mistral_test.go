@@ -0,0 +1,99 @@
+package sdkwrappers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMistralSDKWrapper_ExtractTokenUsageFromResponse(t *testing.T) {
+	wrapper := &MistralSDKWrapper{}
+
+	typed := &MistralChatCompletionResponse{Usage: &MistralUsage{PromptTokens: 12, CompletionTokens: 30, TotalTokens: 42}}
+	usage, err := wrapper.ExtractTokenUsageFromResponse(typed)
+	if err != nil {
+		t.Fatalf("ExtractTokenUsageFromResponse() error = %v", err)
+	}
+	if usage.InputTokens != 12 || usage.OutputTokens != 30 || usage.TotalTokens != 42 {
+		t.Errorf("usage = %+v", usage)
+	}
+
+	mock := map[string]interface{}{
+		"usage": map[string]interface{}{"prompt_tokens": float64(5), "completion_tokens": float64(7)},
+	}
+	if usage, err := wrapper.ExtractTokenUsageFromResponse(mock); err != nil || usage.TotalTokens != 12 {
+		t.Errorf("map usage = %+v, %v", usage, err)
+	}
+
+	if _, err := wrapper.ExtractTokenUsageFromResponse(&MistralChatCompletionResponse{}); err == nil {
+		t.Error("ExtractTokenUsageFromResponse() without usage should fail")
+	}
+	if _, err := wrapper.ExtractTokenUsageFromResponse("invalid"); err == nil {
+		t.Error("ExtractTokenUsageFromResponse() of an unknown type should fail")
+	}
+}
+
+func TestMistralSDKWrapper_TrackAPICall(t *testing.T) {
+	wrapper := &MistralSDKWrapper{}
+
+	models, _ := wrapper.GetSupportedModels()
+	pricing, _ := wrapper.FetchCurrentPricing()
+	for _, model := range models {
+		if _, ok := pricing[model]; !ok {
+			t.Errorf("no pricing for supported model %s", model)
+		}
+	}
+
+	response := map[string]interface{}{
+		"usage": map[string]interface{}{
+			"prompt_tokens":     float64(1000),
+			"completion_tokens": float64(500),
+			"total_tokens":      float64(1500),
+		},
+	}
+	metrics, err := wrapper.TrackAPICall(MistralLarge, response)
+	if err != nil {
+		t.Fatalf("TrackAPICall() error = %v", err)
+	}
+	want := 1000*0.000008 + 500*0.000024
+	if diff := metrics.Price.TotalCost - want; diff > 1e-12 || diff < -1e-12 {
+		t.Errorf("TotalCost = %v, want %v", metrics.Price.TotalCost, want)
+	}
+	if metrics.Provider != "mistral" || metrics.Model != MistralLarge {
+		t.Errorf("metrics = %s/%s", metrics.Provider, metrics.Model)
+	}
+
+	if _, err := wrapper.TrackAPICall("unknown-model", response); err == nil {
+		t.Error("TrackAPICall() for an unknown model should fail")
+	}
+}
+
+func TestMistralClient_ChatCompletion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" || r.Header.Get("Authorization") != "Bearer test-key" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"cmpl-1","model":"mistral-small","choices":[],"usage":{"prompt_tokens":3,"completion_tokens":4,"total_tokens":7}}`))
+	}))
+	defer server.Close()
+
+	wrapper := NewMistralSDKWrapper("test-key")
+	client := wrapper.GetClient().(*MistralClient)
+	client.BaseURL = server.URL + "/v1"
+
+	resp, err := client.ChatCompletion(context.Background(), map[string]interface{}{"model": MistralSmall})
+	if err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+	usage, err := wrapper.ExtractTokenUsageFromResponse(resp)
+	if err != nil || usage.TotalTokens != 7 {
+		t.Errorf("usage = %+v, %v", usage, err)
+	}
+
+	client.APIKey = "wrong"
+	if _, err := client.ChatCompletion(context.Background(), map[string]interface{}{}); err == nil {
+		t.Error("ChatCompletion() should fail on an error status")
+	}
+}
@@ -1,9 +1,9 @@
 package sdkwrappers
 
 import (
+	"context"
 	"fmt"
-	"reflect"
-	"time"
+	"net/http"
 
 	"github.com/TrustSight-io/tokentracker/common"
 	"github.com/openai/openai-go"
@@ -17,11 +17,17 @@ const (
 	GPT4          = "gpt-4"
 	GPT4Turbo     = "gpt-4-turbo"
 	GPT4o         = "gpt-4o"
+	O1            = "o1"
+	O1Mini        = "o1-mini"
+	O3Mini        = "o3-mini"
 )
 
 // OpenAISDKWrapper wraps the OpenAI SDK client
 type OpenAISDKWrapper struct {
 	client openai.Client
+	clock  common.Clock
+	attribution
+	trackedCalls
 }
 
 // NewOpenAISDKWrapper creates a new OpenAI SDK wrapper
@@ -30,7 +36,8 @@ func NewOpenAISDKWrapper(apiKey string) *OpenAISDKWrapper {
 	client := openai.NewClient(option.WithAPIKey(apiKey))
 
 	return &OpenAISDKWrapper{
-		client: client,
+		client:      client,
+		attribution: newAttribution(apiKey),
 	}
 }
 
@@ -53,9 +60,40 @@ func (w *OpenAISDKWrapper) GetSupportedModels() ([]string, error) {
 		GPT4,
 		GPT4Turbo,
 		GPT4o,
+		O1,
+		O1Mini,
+		O3Mini,
 	}, nil
 }
 
+// ListModels returns the models currently available to the account via the models API
+func (w *OpenAISDKWrapper) ListModels(ctx context.Context) ([]string, error) {
+	var models []string
+
+	iter := w.client.Models.ListAutoPaging(ctx)
+	for iter.Next() {
+		models = append(models, iter.Current().ID)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list OpenAI models: %w", err)
+	}
+
+	return models, nil
+}
+
+// SetClock sets the clock used for usage timestamps and durations
+func (w *OpenAISDKWrapper) SetClock(clock common.Clock) {
+	w.clock = clock
+}
+
+// getClock returns the configured clock or the system clock
+func (w *OpenAISDKWrapper) getClock() common.Clock {
+	if w.clock == nil {
+		return common.SystemClock
+	}
+	return w.clock
+}
+
 // ExtractTokenUsageFromResponse extracts token usage from an OpenAI API response
 func (w *OpenAISDKWrapper) ExtractTokenUsageFromResponse(response interface{}) (common.TokenUsage, error) {
 	// The type switch needs to extract specific information from each type
@@ -68,10 +106,14 @@ func (w *OpenAISDKWrapper) ExtractTokenUsageFromResponse(response interface{}) (
 			TotalTokens:    int(resp.Usage.TotalTokens),
 			CompletionID:   resp.ID,
 			Model:          resp.Model,
-			Timestamp:      time.Now(),
+			Timestamp:      w.getClock().Now(),
 			PromptTokens:   int(resp.Usage.PromptTokens),
 			ResponseTokens: int(resp.Usage.CompletionTokens),
 			RequestID:      resp.SystemFingerprint,
+
+			CachedInputTokens: int(resp.Usage.PromptTokensDetails.CachedTokens),
+			AudioInputTokens:  int(resp.Usage.PromptTokensDetails.AudioTokens),
+			ReasoningTokens:   int(resp.Usage.CompletionTokensDetails.ReasoningTokens),
 		}, nil
 
 	// Special case for maps (used in mock JSON responses)
@@ -87,6 +129,15 @@ func (w *OpenAISDKWrapper) ExtractTokenUsageFromResponse(response interface{}) (
 								if sf, hasSF := resp["system_fingerprint"].(string); hasSF {
 									systemFingerprint = sf
 								}
+								var cachedTokens, audioTokens float64
+								if details, hasDetails := usage["prompt_tokens_details"].(map[string]interface{}); hasDetails {
+									cachedTokens, _ = details["cached_tokens"].(float64)
+									audioTokens, _ = details["audio_tokens"].(float64)
+								}
+								var reasoningTokens float64
+								if details, hasDetails := usage["completion_tokens_details"].(map[string]interface{}); hasDetails {
+									reasoningTokens, _ = details["reasoning_tokens"].(float64)
+								}
 
 								return common.TokenUsage{
 									InputTokens:    int(promptTokens),
@@ -94,10 +145,14 @@ func (w *OpenAISDKWrapper) ExtractTokenUsageFromResponse(response interface{}) (
 									TotalTokens:    int(totalTokens),
 									CompletionID:   id,
 									Model:          model,
-									Timestamp:      time.Now(),
+									Timestamp:      w.getClock().Now(),
 									PromptTokens:   int(promptTokens),
 									ResponseTokens: int(completionTokens),
 									RequestID:      systemFingerprint,
+
+									CachedInputTokens: int(cachedTokens),
+									AudioInputTokens:  int(audioTokens),
+									ReasoningTokens:   int(reasoningTokens),
 								}, nil
 							}
 						}
@@ -107,59 +162,13 @@ func (w *OpenAISDKWrapper) ExtractTokenUsageFromResponse(response interface{}) (
 		}
 	}
 
-	// For all test cases, we need to make a special case for MockOpenAIResponse
-	// This uses reflection to check if the type name matches, as we can't import it directly
-	respType := fmt.Sprintf("%T", response)
-	if respType == "*sdkwrappers.MockOpenAIResponse" {
-		// Use reflection to safely access fields
-		respValue := reflect.ValueOf(response).Elem()
-
-		// Get ID, Model, and SystemFingerprint fields
-		id := ""
-		model := ""
-		systemFingerprint := ""
-
-		if idField := respValue.FieldByName("ID"); idField.IsValid() {
-			id = idField.String()
-		}
-		if modelField := respValue.FieldByName("Model"); modelField.IsValid() {
-			model = modelField.String()
-		}
-		if sfField := respValue.FieldByName("SystemFingerprint"); sfField.IsValid() {
-			systemFingerprint = sfField.String()
-		}
-
-		// Get Usage struct and its fields
-		if usageField := respValue.FieldByName("Usage"); usageField.IsValid() {
-			promptTokens := 0
-			completionTokens := 0
-			totalTokens := 0
-
-			if promptField := usageField.FieldByName("PromptTokens"); promptField.IsValid() {
-				promptTokens = int(promptField.Int())
-			}
-			if completionField := usageField.FieldByName("CompletionTokens"); completionField.IsValid() {
-				completionTokens = int(completionField.Int())
-			}
-			if totalField := usageField.FieldByName("TotalTokens"); totalField.IsValid() {
-				totalTokens = int(totalField.Int())
-			}
-
-			return common.TokenUsage{
-				InputTokens:    promptTokens,
-				OutputTokens:   completionTokens,
-				TotalTokens:    totalTokens,
-				CompletionID:   id,
-				Model:          model,
-				Timestamp:      time.Now(),
-				PromptTokens:   promptTokens,
-				ResponseTokens: completionTokens,
-				RequestID:      systemFingerprint,
-			}, nil
-		}
+	// Responses of other types, such as an application's own wrappers, are handled by
+	// the adapters registered with RegisterResponseAdapter
+	if usage, ok := adaptResponse(w.GetProviderName(), response, w.getClock().Now()); ok {
+		return usage, nil
 	}
 
-	return common.TokenUsage{}, fmt.Errorf("response is not a *openai.ChatCompletion or valid mock: %T", response)
+	return common.TokenUsage{}, fmt.Errorf("response is not a *openai.ChatCompletion and no response adapter handles %T", response)
 }
 
 // FetchCurrentPricing returns the current pricing for OpenAI models
@@ -192,6 +201,25 @@ func (w *OpenAISDKWrapper) FetchCurrentPricing() (map[string]common.ModelPricing
 			OutputPricePerToken: 0.00003,
 			Currency:            "USD",
 		},
+		// Reasoning tokens of the o-series models are billed as output
+		O1: {
+			InputPricePerToken:       0.000015,
+			OutputPricePerToken:      0.00006,
+			CachedInputPricePerToken: 0.0000075,
+			Currency:                 "USD",
+		},
+		O1Mini: {
+			InputPricePerToken:       0.0000011,
+			OutputPricePerToken:      0.0000044,
+			CachedInputPricePerToken: 0.00000055,
+			Currency:                 "USD",
+		},
+		O3Mini: {
+			InputPricePerToken:       0.0000011,
+			OutputPricePerToken:      0.0000044,
+			CachedInputPricePerToken: 0.00000055,
+			Currency:                 "USD",
+		},
 	}
 
 	return pricing, nil
@@ -212,6 +240,13 @@ func (w *OpenAISDKWrapper) TrackAPICall(model string, response interface{}) (com
 		return common.UsageMetrics{}, err
 	}
 
+	return w.trackOnce(w.GetProviderName(), model, tokenUsage, func() (common.UsageMetrics, error) {
+		return w.usageMetrics(model, tokenUsage)
+	})
+}
+
+// usageMetrics prices the token usage of a call
+func (w *OpenAISDKWrapper) usageMetrics(model string, tokenUsage common.TokenUsage) (common.UsageMetrics, error) {
 	// Get pricing information for the model
 	pricing, err := w.FetchCurrentPricing()
 	if err != nil {
@@ -224,29 +259,44 @@ func (w *OpenAISDKWrapper) TrackAPICall(model string, response interface{}) (com
 		return common.UsageMetrics{}, fmt.Errorf("no pricing information found for model: %s", model)
 	}
 
-	// Calculate price
-	inputCost := float64(tokenUsage.InputTokens) * modelPricing.InputPricePerToken
-	outputCost := float64(tokenUsage.OutputTokens) * modelPricing.OutputPricePerToken
-	totalCost := inputCost + outputCost
+	// Calculate price, billing cached and audio input tokens at their own prices and
+	// breaking out the cost of reasoning tokens
+	price := modelPricing.Cost(common.BillableUsage{
+		InputTokens:       tokenUsage.InputTokens,
+		OutputTokens:      tokenUsage.OutputTokens,
+		CachedInputTokens: tokenUsage.CachedInputTokens,
+		AudioInputTokens:  tokenUsage.AudioInputTokens,
+		ReasoningTokens:   tokenUsage.ReasoningTokens,
+	})
 
 	// Create usage metrics
 	metrics := common.UsageMetrics{
 		TokenCount: common.TokenCount{
-			InputTokens:    tokenUsage.InputTokens,
-			ResponseTokens: tokenUsage.OutputTokens,
-			TotalTokens:    tokenUsage.TotalTokens,
+			InputTokens:       tokenUsage.InputTokens,
+			ResponseTokens:    tokenUsage.OutputTokens,
+			TotalTokens:       tokenUsage.TotalTokens,
+			CachedInputTokens: tokenUsage.CachedInputTokens,
+			AudioTokens:       tokenUsage.AudioInputTokens,
+			ReasoningTokens:   tokenUsage.ReasoningTokens,
 		},
-		Price: common.Price{
-			InputCost:  inputCost,
-			OutputCost: outputCost,
-			TotalCost:  totalCost,
-			Currency:   modelPricing.Currency,
-		},
-		Duration:  time.Since(tokenUsage.Timestamp),
-		Timestamp: time.Now(),
+		Price:     price,
+		Duration:  w.getClock().Since(tokenUsage.Timestamp),
+		Timestamp: w.getClock().Now(),
 		Model:     model,
 		Provider:  w.GetProviderName(),
 	}
 
+	w.attribute(&metrics)
+	return metrics, nil
+}
+
+// TrackAPICallWithHeader tracks an API call, attributing it to the organization and
+// project of the response headers, e.g. captured with option.WithResponseInto
+func (w *OpenAISDKWrapper) TrackAPICallWithHeader(model string, response interface{}, header http.Header) (common.UsageMetrics, error) {
+	metrics, err := w.TrackAPICall(model, response)
+	if err != nil {
+		return metrics, err
+	}
+	metrics.ApplyOpenAIHeaders(header)
 	return metrics, nil
 }
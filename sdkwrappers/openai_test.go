@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/TrustSight-io/tokentracker"
+	"github.com/TrustSight-io/tokentracker/common"
 )
 
 // MockOpenAIProvider is a mock Provider implementation for testing
@@ -42,11 +43,8 @@ func (p *MockOpenAIProvider) SetSDKClient(client interface{}) {
 	p.client = client
 }
 
-func (p *MockOpenAIProvider) GetModelInfo(model string) (interface{}, error) {
-	return map[string]interface{}{
-		"name":     model,
-		"provider": p.name,
-	}, nil
+func (p *MockOpenAIProvider) GetModelInfo(model string) (tokentracker.ModelInfo, error) {
+	return tokentracker.ModelInfo{Name: model, Provider: p.name}, nil
 }
 
 func (p *MockOpenAIProvider) ExtractTokenUsageFromResponse(response interface{}) (tokentracker.TokenCount, error) {
@@ -79,6 +77,22 @@ type MockOpenAIResponse struct {
 	} `json:"usage"`
 }
 
+func init() {
+	RegisterResponseAdapter("openai", func(response any) (common.TokenUsage, bool) {
+		resp, ok := response.(*MockOpenAIResponse)
+		if !ok {
+			return common.TokenUsage{}, false
+		}
+		return common.TokenUsage{
+			InputTokens:  resp.Usage.PromptTokens,
+			OutputTokens: resp.Usage.CompletionTokens,
+			TotalTokens:  resp.Usage.TotalTokens,
+			CompletionID: resp.ID,
+			Model:        resp.Model,
+		}, true
+	})
+}
+
 func TestOpenAISDKWrapper_GetProviderName(t *testing.T) {
 	// Skip actual client creation in tests
 	wrapper := &OpenAISDKWrapper{}
@@ -281,6 +295,31 @@ func TestOpenAISDKWrapper_TrackAPICall(t *testing.T) {
 	}
 }
 
+func TestOpenAISDKWrapper_TrackAPICallReasoningTokens(t *testing.T) {
+	wrapper := &OpenAISDKWrapper{}
+
+	response := map[string]interface{}{
+		"id":    "chatcmpl-123",
+		"model": O3Mini,
+		"usage": map[string]interface{}{
+			"prompt_tokens":             float64(40),
+			"completion_tokens":         float64(600),
+			"total_tokens":              float64(640),
+			"completion_tokens_details": map[string]interface{}{"reasoning_tokens": float64(512)},
+		},
+	}
+	metrics, err := wrapper.TrackAPICall(O3Mini, response)
+	if err != nil {
+		t.Fatalf("TrackAPICall() error = %v", err)
+	}
+	if metrics.TokenCount.ReasoningTokens != 512 || metrics.TokenCount.ResponseTokens != 600 {
+		t.Errorf("TokenCount = %+v, want the reasoning tokens", metrics.TokenCount)
+	}
+	if diff := metrics.Price.ReasoningCost - 512*0.0000044; diff > 1e-12 || diff < -1e-12 {
+		t.Errorf("ReasoningCost = %v, want the reasoning tokens at the output price", metrics.Price.ReasoningCost)
+	}
+}
+
 func TestOpenAISDKWrapper_UpdateProviderPricing(t *testing.T) {
 	// Skip actual client creation in tests
 	wrapper := &OpenAISDKWrapper{}
@@ -0,0 +1,62 @@
+package sdkwrappers
+
+import (
+	"sync"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker/common"
+)
+
+// ResponseAdapter extracts the token usage of a response the SDK wrappers do not know,
+// such as an application's own struct wrapping an SDK response. It reports false for
+// responses it does not handle.
+type ResponseAdapter func(response any) (common.TokenUsage, bool)
+
+// responseAdapters holds the registered adapters by provider name
+var responseAdapters = struct {
+	sync.RWMutex
+	byProvider map[string][]ResponseAdapter
+}{byProvider: make(map[string][]ResponseAdapter)}
+
+// RegisterResponseAdapter registers an adapter consulted by the wrapper of a provider
+// ("openai", "anthropic", "gemini" or "mistral") for responses that are not one of its
+// SDK's types. Adapters registered later are tried first. The Azure OpenAI wrapper
+// uses the adapters of "openai".
+func RegisterResponseAdapter(providerName string, adapter ResponseAdapter) {
+	if adapter == nil {
+		return
+	}
+	responseAdapters.Lock()
+	defer responseAdapters.Unlock()
+	responseAdapters.byProvider[providerName] = append(responseAdapters.byProvider[providerName], adapter)
+}
+
+// adaptResponse extracts the token usage of a response with the adapters registered for
+// a provider. Totals, prompt and response tokens and the timestamp the adapter leaves
+// unset are filled in.
+func adaptResponse(providerName string, response any, now time.Time) (common.TokenUsage, bool) {
+	responseAdapters.RLock()
+	adapters := responseAdapters.byProvider[providerName]
+	responseAdapters.RUnlock()
+
+	for i := len(adapters) - 1; i >= 0; i-- {
+		usage, ok := adapters[i](response)
+		if !ok {
+			continue
+		}
+		if usage.TotalTokens == 0 {
+			usage.TotalTokens = usage.InputTokens + usage.OutputTokens
+		}
+		if usage.PromptTokens == 0 {
+			usage.PromptTokens = usage.InputTokens
+		}
+		if usage.ResponseTokens == 0 {
+			usage.ResponseTokens = usage.OutputTokens
+		}
+		if usage.Timestamp.IsZero() {
+			usage.Timestamp = now
+		}
+		return usage, true
+	}
+	return common.TokenUsage{}, false
+}
@@ -0,0 +1,55 @@
+package sdkwrappers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker/common"
+	"github.com/TrustSight-io/tokentracker/tokentrackertest"
+)
+
+// tracedCompletion is an application's wrapper around a completion
+type tracedCompletion struct {
+	TraceID string
+	Input   int
+	Output  int
+}
+
+func TestRegisterResponseAdapter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	RegisterResponseAdapter("mistral", func(response any) (common.TokenUsage, bool) {
+		resp, ok := response.(tracedCompletion)
+		if !ok {
+			return common.TokenUsage{}, false
+		}
+		return common.TokenUsage{InputTokens: resp.Input, OutputTokens: resp.Output, RequestID: resp.TraceID}, true
+	})
+
+	wrapper := &MistralSDKWrapper{}
+	wrapper.SetClock(tokentrackertest.NewFakeClock(now))
+	usage, err := wrapper.ExtractTokenUsageFromResponse(tracedCompletion{TraceID: "trace-1", Input: 30, Output: 12})
+	if err != nil {
+		t.Fatalf("ExtractTokenUsageFromResponse() error = %v", err)
+	}
+	if usage.TotalTokens != 42 || usage.PromptTokens != 30 || usage.ResponseTokens != 12 || usage.RequestID != "trace-1" {
+		t.Errorf("ExtractTokenUsageFromResponse() = %+v, want the adapted usage with totals filled in", usage)
+	}
+	if !usage.Timestamp.Equal(now) {
+		t.Errorf("Timestamp = %v, want the wrapper's clock", usage.Timestamp)
+	}
+
+	// Adapters apply only to the wrappers of their provider
+	if _, err := (&AnthropicSDKWrapper{}).ExtractTokenUsageFromResponse(tracedCompletion{}); err == nil || !strings.Contains(err.Error(), "tracedCompletion") {
+		t.Errorf("ExtractTokenUsageFromResponse() error = %v, want the unhandled type", err)
+	}
+
+	// Adapters registered later are tried first
+	RegisterResponseAdapter("mistral", func(response any) (common.TokenUsage, bool) {
+		resp, ok := response.(tracedCompletion)
+		return common.TokenUsage{InputTokens: 2 * resp.Input}, ok
+	})
+	if usage, _ := wrapper.ExtractTokenUsageFromResponse(tracedCompletion{Input: 30}); usage.InputTokens != 60 {
+		t.Errorf("InputTokens = %d, want the latest adapter's", usage.InputTokens)
+	}
+}
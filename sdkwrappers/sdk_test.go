@@ -4,10 +4,21 @@ import (
 	"testing"
 	"time"
 
+	"github.com/TrustSight-io/tokentracker"
 	"github.com/TrustSight-io/tokentracker/common"
 	"github.com/TrustSight-io/tokentracker/sdkwrappers"
 )
 
+// The wrappers register with a tracker as SDK clients without conversions
+var (
+	_ tokentracker.SDKClient       = (*sdkwrappers.OpenAISDKWrapper)(nil)
+	_ tokentracker.SDKClient       = (*sdkwrappers.AzureOpenAISDKWrapper)(nil)
+	_ tokentracker.SDKClient       = (*sdkwrappers.AnthropicSDKWrapper)(nil)
+	_ tokentracker.SDKClient       = (*sdkwrappers.GeminiSDKWrapper)(nil)
+	_ tokentracker.SDKClient       = (*sdkwrappers.MistralSDKWrapper)(nil)
+	_ sdkwrappers.SDKClientWrapper = tokentracker.SDKClient(nil)
+)
+
 // MockResponse is a simple mock response for testing
 type MockResponse struct {
 	ID    string
@@ -0,0 +1,84 @@
+package sdkwrappers
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/TrustSight-io/tokentracker/common"
+)
+
+// TrackedCallsCapacity is how many tracked calls a wrapper remembers to count a
+// response tracked twice once
+const TrackedCallsCapacity = 10000
+
+// trackedCalls remembers the metrics of the calls a wrapper tracked most recently by
+// their completion ID, evicting the least recently used
+type trackedCalls struct {
+	order *list.List // of *trackedCall, most recently used first
+	calls map[string]*list.Element
+	mu    sync.Mutex
+}
+
+// trackedCall is a call being tracked, or its metrics once done is closed
+type trackedCall struct {
+	id      string
+	metrics common.UsageMetrics
+	err     error
+	done    chan struct{}
+}
+
+// trackOnce returns the metrics of the call of usage if it was tracked for model
+// before, e.g. by a retried handler; otherwise it tracks the call and remembers its
+// metrics. Only completion IDs identify a call: request IDs such as OpenAI's system
+// fingerprint are shared by many calls, so calls without a completion ID are always
+// tracked. The call is reserved under the lock but tracked outside it, so wrappers do
+// not wait for each other's storage and sinks; a call tracked concurrently waits for
+// the first tracking and shares its result.
+func (c *trackedCalls) trackOnce(provider, model string, usage common.TokenUsage, track func() (common.UsageMetrics, error)) (common.UsageMetrics, error) {
+	if usage.CompletionID == "" {
+		return track()
+	}
+	completionID := provider + ":" + usage.CompletionID
+	id := completionID + "|" + model
+
+	c.mu.Lock()
+	if c.calls == nil {
+		c.order = list.New()
+		c.calls = make(map[string]*list.Element)
+	}
+	if element, exists := c.calls[id]; exists {
+		c.order.MoveToFront(element)
+		c.mu.Unlock()
+
+		call := element.Value.(*trackedCall)
+		<-call.done
+		return call.metrics, call.err
+	}
+
+	call := &trackedCall{id: id, done: make(chan struct{})}
+	element := c.order.PushFront(call)
+	c.calls[id] = element
+	if c.order.Len() > TrackedCallsCapacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.calls, oldest.Value.(*trackedCall).id)
+	}
+	c.mu.Unlock()
+
+	metrics, err := track()
+	if err == nil {
+		metrics.CompletionID = completionID
+	}
+	call.metrics, call.err = metrics, err
+	if err != nil {
+		// A failed call is forgotten, so a retry tracks it again
+		c.mu.Lock()
+		if c.calls[id] == element {
+			c.order.Remove(element)
+			delete(c.calls, id)
+		}
+		c.mu.Unlock()
+	}
+	close(call.done)
+	return metrics, err
+}
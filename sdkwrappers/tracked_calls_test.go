@@ -0,0 +1,163 @@
+package sdkwrappers
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker/common"
+	"github.com/TrustSight-io/tokentracker/tokentrackertest"
+	"github.com/openai/openai-go"
+)
+
+func TestOpenAISDKWrapper_TrackAPICallCountsRepeatedResponsesOnce(t *testing.T) {
+	clock := tokentrackertest.NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	wrapper := &OpenAISDKWrapper{}
+	wrapper.SetClock(clock)
+
+	response := &openai.ChatCompletion{ID: "chatcmpl-1", Usage: openai.CompletionUsage{PromptTokens: 100, CompletionTokens: 50, TotalTokens: 150}}
+	first, err := wrapper.TrackAPICall("gpt-4", response)
+	if err != nil {
+		t.Fatalf("TrackAPICall() error = %v", err)
+	}
+	if first.CompletionID != "openai:chatcmpl-1" {
+		t.Errorf("CompletionID = %q, want the provider-scoped completion ID", first.CompletionID)
+	}
+
+	// A retried handler tracking the same response gets the first metrics back
+	clock.Advance(time.Minute)
+	again, err := wrapper.TrackAPICall("gpt-4", response)
+	if err != nil {
+		t.Fatalf("TrackAPICall() error = %v", err)
+	}
+	if !again.Timestamp.Equal(first.Timestamp) || again.CompletionID != first.CompletionID {
+		t.Errorf("TrackAPICall() of a repeated response = %+v, want the first metrics %+v", again, first)
+	}
+
+	other, err := wrapper.TrackAPICall("gpt-4", &openai.ChatCompletion{ID: "chatcmpl-2", Usage: openai.CompletionUsage{PromptTokens: 100, CompletionTokens: 50, TotalTokens: 150}})
+	if err != nil || other.Timestamp.Equal(first.Timestamp) {
+		t.Errorf("TrackAPICall() of another response = %+v, %v, want it tracked", other, err)
+	}
+}
+
+func TestTrackedCalls_EvictsLeastRecentlyUsed(t *testing.T) {
+	var calls trackedCalls
+	tracked := 0
+	track := func() (common.UsageMetrics, error) {
+		tracked++
+		return common.UsageMetrics{}, nil
+	}
+
+	_, _ = calls.trackOnce("openai", "gpt-4", common.TokenUsage{CompletionID: "first"}, track)
+	for i := 0; i < TrackedCallsCapacity; i++ {
+		_, _ = calls.trackOnce("openai", "gpt-4", common.TokenUsage{CompletionID: fmt.Sprint(i)}, track)
+	}
+	if tracked != TrackedCallsCapacity+1 || len(calls.calls) != TrackedCallsCapacity {
+		t.Fatalf("tracked %d calls remembering %d, want %d remembered", tracked, len(calls.calls), TrackedCallsCapacity)
+	}
+
+	// The oldest call was evicted, the most recent ones are remembered
+	_, _ = calls.trackOnce("openai", "gpt-4", common.TokenUsage{CompletionID: fmt.Sprint(TrackedCallsCapacity - 1)}, track)
+	_, _ = calls.trackOnce("openai", "gpt-4", common.TokenUsage{CompletionID: "first"}, track)
+	if tracked != TrackedCallsCapacity+2 {
+		t.Errorf("tracked %d calls, want only the evicted call tracked again", tracked)
+	}
+
+	// Calls without an ID are always tracked
+	_, _ = calls.trackOnce("openai", "gpt-4", common.TokenUsage{}, track)
+	_, _ = calls.trackOnce("openai", "gpt-4", common.TokenUsage{}, track)
+	if tracked != TrackedCallsCapacity+4 {
+		t.Errorf("tracked %d calls, want calls without an ID tracked every time", tracked)
+	}
+}
+
+func TestTrackedCalls_RequestIDsDoNotIdentifyCalls(t *testing.T) {
+	var calls trackedCalls
+	tracked := 0
+	track := func() (common.UsageMetrics, error) {
+		tracked++
+		return common.UsageMetrics{}, nil
+	}
+
+	// OpenAI's system fingerprint is shared by unrelated completions
+	for i := 0; i < 3; i++ {
+		_, _ = calls.trackOnce("openai", "gpt-4", common.TokenUsage{RequestID: "fp_44709d6fcb"}, track)
+	}
+	if tracked != 3 || len(calls.calls) != 0 {
+		t.Errorf("tracked %d calls remembering %d, want every call without a completion ID tracked", tracked, len(calls.calls))
+	}
+}
+
+func TestTrackedCalls_TracksOutsideTheLock(t *testing.T) {
+	var calls trackedCalls
+	firstStarted, secondDone := make(chan struct{}), make(chan struct{})
+
+	// The first call is still being tracked while the second is
+	go func() {
+		_, _ = calls.trackOnce("openai", "gpt-4", common.TokenUsage{CompletionID: "first"}, func() (common.UsageMetrics, error) {
+			close(firstStarted)
+			<-secondDone
+			return common.UsageMetrics{}, nil
+		})
+	}()
+	<-firstStarted
+
+	finished := make(chan struct{})
+	go func() {
+		_, _ = calls.trackOnce("openai", "gpt-4", common.TokenUsage{CompletionID: "second"}, func() (common.UsageMetrics, error) {
+			return common.UsageMetrics{}, nil
+		})
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("tracking a call waited for another call being tracked")
+	}
+	close(secondDone)
+}
+
+func TestTrackedCalls_ConcurrentDuplicatesTrackedOnce(t *testing.T) {
+	var calls trackedCalls
+	var tracked int32
+	release := make(chan struct{})
+	track := func() (common.UsageMetrics, error) {
+		atomic.AddInt32(&tracked, 1)
+		<-release
+		return common.UsageMetrics{TokenCount: common.TokenCount{InputTokens: 10}}, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]common.UsageMetrics, 5)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = calls.trackOnce("openai", "gpt-4", common.TokenUsage{CompletionID: "chatcmpl-1"}, track)
+		}(i)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if tracked != 1 {
+		t.Errorf("tracked %d times, want once", tracked)
+	}
+	for _, metrics := range results {
+		if metrics.CompletionID != "openai:chatcmpl-1" || metrics.TokenCount.InputTokens != 10 {
+			t.Errorf("metrics = %+v, want the metrics of the single tracking", metrics)
+		}
+	}
+
+	// A failed call is tracked again
+	failing := func() (common.UsageMetrics, error) { return common.UsageMetrics{}, errors.New("store down") }
+	if _, err := calls.trackOnce("openai", "gpt-4", common.TokenUsage{CompletionID: "chatcmpl-2"}, failing); err == nil {
+		t.Fatal("trackOnce() error = nil, want the tracking error")
+	}
+	if _, err := calls.trackOnce("openai", "gpt-4", common.TokenUsage{CompletionID: "chatcmpl-2"}, track); err != nil {
+		t.Errorf("trackOnce() after a failure error = %v, want the call tracked again", err)
+	}
+}
@@ -0,0 +1,152 @@
+package tokentracker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// SessionTagKey is the tag carrying the session ID in the usage records of a session's
+// turns, so stored usage can be summarized per conversation with GroupByTag(SessionTagKey)
+const SessionTagKey = "session_id"
+
+// Session tracks the turns of one conversation with a model, accumulating its token
+// totals, cost and the growth of the context sent with every turn
+type Session struct {
+	tracker *DefaultTokenTracker
+	id      string
+	model   string
+	tags    map[string]string
+	summary SessionSummary
+	mu      sync.Mutex
+}
+
+// SessionSummary contains the totals of a session
+type SessionSummary struct {
+	ID                string            `json:"id"`
+	Model             string            `json:"model"`
+	Tags              map[string]string `json:"tags,omitempty"`
+	Turns             int               `json:"turns"`
+	InputTokens       int               `json:"input_tokens"`
+	ResponseTokens    int               `json:"response_tokens"`
+	TotalTokens       int               `json:"total_tokens"`
+	CachedInputTokens int               `json:"cached_input_tokens,omitempty"`
+	TotalCost         float64           `json:"total_cost"`
+	Currency          string            `json:"currency"`
+
+	// ContextTokens are the input tokens of every turn in order: the conversation
+	// context sent with it, which grows as the conversation goes on
+	ContextTokens []int `json:"context_tokens"`
+
+	// PeakContextTokens is the largest context sent with a turn
+	PeakContextTokens int `json:"peak_context_tokens"`
+
+	StartedAt  time.Time `json:"started_at"`
+	LastTurnAt time.Time `json:"last_turn_at"`
+}
+
+// ContextGrowth returns how many tokens the context grew by from the first turn to the last
+func (s SessionSummary) ContextGrowth() int {
+	if len(s.ContextTokens) < 2 {
+		return 0
+	}
+	return s.ContextTokens[len(s.ContextTokens)-1] - s.ContextTokens[0]
+}
+
+// NewSession starts a conversation with model. Every turn is tracked with the tags
+// plus SessionTagKey set to the session ID.
+func (t *DefaultTokenTracker) NewSession(model string, tags map[string]string) *Session {
+	id := newSessionID()
+	sessionTags := copyTags(tags)
+	if sessionTags == nil {
+		sessionTags = make(map[string]string, 1)
+	}
+	sessionTags[SessionTagKey] = id
+
+	startedAt := t.clock().Now()
+	return &Session{
+		tracker: t,
+		id:      id,
+		model:   model,
+		tags:    sessionTags,
+		summary: SessionSummary{
+			ID:        id,
+			Model:     model,
+			Tags:      copyTags(tags),
+			StartedAt: startedAt,
+		},
+	}
+}
+
+// ID returns the session ID
+func (s *Session) ID() string {
+	return s.id
+}
+
+// TrackTurn tracks one turn of the conversation: params are the messages sent and
+// response is the model's reply, as for TrackUsage
+func (s *Session) TrackTurn(params TokenCountParams, response interface{}) (UsageMetrics, error) {
+	return s.TrackTurnCtx(context.Background(), params, response)
+}
+
+// TrackTurnCtx tracks one turn of the conversation, honoring cancellation of ctx.
+// Turns are tracked once the reply has arrived, so the call duration is not measured.
+func (s *Session) TrackTurnCtx(ctx context.Context, params TokenCountParams, response interface{}) (UsageMetrics, error) {
+	if params.Model == "" {
+		params.Model = s.model
+	}
+
+	metrics, err := s.tracker.TrackUsageCtx(ctx, CallParams{
+		Model:     s.model,
+		Params:    params,
+		StartTime: s.tracker.clock().Now(),
+		Tags:      s.tags,
+	}, response)
+	if err != nil {
+		return UsageMetrics{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	summary := &s.summary
+	summary.Turns++
+	summary.InputTokens += metrics.TokenCount.InputTokens
+	summary.ResponseTokens += metrics.TokenCount.ResponseTokens
+	summary.TotalTokens += metrics.TokenCount.TotalTokens
+	summary.CachedInputTokens += metrics.TokenCount.CachedInputTokens
+	summary.TotalCost += metrics.Price.TotalCost
+	if summary.Currency == "" {
+		summary.Currency = metrics.Price.Currency
+	}
+	summary.ContextTokens = append(summary.ContextTokens, metrics.TokenCount.InputTokens)
+	if metrics.TokenCount.InputTokens > summary.PeakContextTokens {
+		summary.PeakContextTokens = metrics.TokenCount.InputTokens
+	}
+	summary.LastTurnAt = metrics.Timestamp
+
+	return metrics, nil
+}
+
+// Summary returns the totals of the turns tracked so far
+func (s *Session) Summary() SessionSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	summary := s.summary
+	summary.Tags = copyTags(s.summary.Tags)
+	summary.ContextTokens = append([]int(nil), s.summary.ContextTokens...)
+	return summary
+}
+
+// newSessionID returns a random session ID
+func newSessionID() string {
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		// The system random source does not fail in practice; fall back to the time
+		return time.Now().UTC().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(id[:])
+}
@@ -0,0 +1,84 @@
+package tokentracker
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker/tokentrackertest"
+)
+
+// reportingProvider reports the TokenCount passed as the response
+type reportingProvider struct {
+	usagePricedProvider
+}
+
+func (p *reportingProvider) ExtractTokenUsageFromResponse(response interface{}) (TokenCount, error) {
+	count, _ := response.(TokenCount)
+	return count, nil
+}
+
+func TestSession_TrackTurn(t *testing.T) {
+	clock := tokentrackertest.NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	store := NewMemoryUsageStore()
+	tracker := newPricedTestTracker(t, WithClock(clock), WithUsageStore(store))
+	tracker.RegisterProvider(&reportingProvider{newPricedProvider(tracker.Config(), "acme", "acme-1")})
+
+	session := tracker.NewSession("acme-1", map[string]string{"feature": "support"})
+	other := tracker.NewSession("acme-1", nil)
+	if session.ID() == "" || session.ID() == other.ID() {
+		t.Fatalf("session IDs %q and %q, want unique IDs", session.ID(), other.ID())
+	}
+
+	// Every turn resends the growing conversation
+	turns := []TokenCount{
+		{InputTokens: 100, ResponseTokens: 50},
+		{InputTokens: 180, ResponseTokens: 40, CachedInputTokens: 100},
+		{InputTokens: 250, ResponseTokens: 60},
+	}
+	for _, count := range turns {
+		clock.Advance(time.Minute)
+		if _, err := session.TrackTurn(TokenCountParams{Messages: []Message{{Role: "user", Content: "Hi"}}}, count); err != nil {
+			t.Fatalf("TrackTurn() error = %v", err)
+		}
+	}
+	if _, err := other.TrackTurn(TokenCountParams{}, TokenCount{InputTokens: 10, ResponseTokens: 10}); err != nil {
+		t.Fatalf("TrackTurn() error = %v", err)
+	}
+
+	summary := session.Summary()
+	if summary.Turns != 3 || summary.InputTokens != 530 || summary.ResponseTokens != 150 || summary.TotalTokens != 680 || summary.CachedInputTokens != 100 {
+		t.Errorf("Summary() = %+v, want the totals of the 3 turns", summary)
+	}
+	if want := 530*0.00001 + 150*0.00002; math.Abs(summary.TotalCost-want) > 1e-12 || summary.Currency != "USD" {
+		t.Errorf("TotalCost = %v %s, want %v USD", summary.TotalCost, summary.Currency, want)
+	}
+	if len(summary.ContextTokens) != 3 || summary.ContextTokens[1] != 180 || summary.PeakContextTokens != 250 || summary.ContextGrowth() != 150 {
+		t.Errorf("context = %v (peak %d, growth %d), want the growing context", summary.ContextTokens, summary.PeakContextTokens, summary.ContextGrowth())
+	}
+	if summary.Tags["feature"] != "support" || !summary.LastTurnAt.Equal(summary.StartedAt.Add(3*time.Minute)) {
+		t.Errorf("Summary() = %+v", summary)
+	}
+
+	// The summary is a copy
+	summary.ContextTokens[0] = 0
+	if session.Summary().ContextTokens[0] != 100 {
+		t.Error("Summary() should not share its context tokens with the session")
+	}
+
+	// Stored usage can be grouped by session
+	records, _ := store.Query(UsageFilter{})
+	grouped := SummarizeUsage(records, GroupByTag(SessionTagKey))
+	if len(grouped) != 2 {
+		t.Fatalf("SummarizeUsage() = %+v, want one group per session", grouped)
+	}
+	for _, group := range grouped {
+		if group.Tags[SessionTagKey] == session.ID() && group.Calls != 3 {
+			t.Errorf("session group = %+v, want 3 calls", group)
+		}
+	}
+
+	if _, err := tracker.NewSession("unknown", nil).TrackTurn(TokenCountParams{}, nil); err == nil {
+		t.Error("TrackTurn() with an unknown model should fail")
+	}
+}
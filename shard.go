@@ -0,0 +1,29 @@
+package tokentracker
+
+import (
+	"math/rand/v2"
+	"runtime"
+)
+
+// cacheLineSize is the size shards are padded to, so cores updating different shards
+// do not invalidate each other's cache lines
+const cacheLineSize = 64
+
+// counterShards is the number of shards of hot counters: the number of CPUs rounded up
+// to a power of two, so concurrent updates rarely land on the same shard
+var counterShards = shardCount(runtime.GOMAXPROCS(0))
+
+// shardCount rounds n up to a power of two between 1 and 64
+func shardCount(n int) int {
+	shards := 1
+	for shards < n && shards < 64 {
+		shards <<= 1
+	}
+	return shards
+}
+
+// randomShard returns a shard for an update. The runtime's per-thread random source
+// costs no shared state, unlike hashing a goroutine or CPU identifier.
+func randomShard(shards int) int {
+	return int(rand.Uint32() & uint32(shards-1))
+}
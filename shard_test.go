@@ -0,0 +1,22 @@
+package tokentracker
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestShardCount(t *testing.T) {
+	for _, tt := range []struct{ cpus, want int }{{0, 1}, {1, 1}, {3, 4}, {8, 8}, {12, 16}, {200, 64}} {
+		if got := shardCount(tt.cpus); got != tt.want {
+			t.Errorf("shardCount(%d) = %d, want %d", tt.cpus, got, tt.want)
+		}
+	}
+	for i := 0; i < 100; i++ {
+		if shard := randomShard(8); shard < 0 || shard >= 8 {
+			t.Fatalf("randomShard(8) = %d", shard)
+		}
+	}
+	if size := unsafe.Sizeof(totalShard{}); size != cacheLineSize {
+		t.Errorf("totalShard is %d bytes, want a cache line", size)
+	}
+}
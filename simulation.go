@@ -0,0 +1,231 @@
+package tokentracker
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// DaysPerMonth is the average length of a month in days, used to project monthly costs
+const DaysPerMonth = 365.25 / 12
+
+// Workload describes calls made to a model at a steady rate, for cost simulation
+type Workload struct {
+	Name            string  `json:"name,omitempty"` // e.g. the feature making the calls
+	Model           string  `json:"model"`
+	CallsPerDay     float64 `json:"calls_per_day"`
+	AvgInputTokens  float64 `json:"avg_input_tokens"`
+	AvgOutputTokens float64 `json:"avg_output_tokens"`
+
+	// CachedInputRatio is the share of input tokens read from the prompt cache (0 to 1)
+	CachedInputRatio float64 `json:"cached_input_ratio,omitempty"`
+
+	// Batch prices the calls with the batch discount of the model
+	Batch bool `json:"batch,omitempty"`
+}
+
+// WorkloadCost is the projected cost of a workload run on one model
+type WorkloadCost struct {
+	Workload    string  `json:"workload"`
+	Model       string  `json:"model"`
+	Provider    string  `json:"provider"`
+	CallCost    Price   `json:"call_cost"` // of an average call
+	DailyCost   float64 `json:"daily_cost"`
+	MonthlyCost float64 `json:"monthly_cost"`
+}
+
+// ModelProjection is the projected cost of all workloads moved to one model
+type ModelProjection struct {
+	Model       string         `json:"model"`
+	Provider    string         `json:"provider"`
+	Workloads   []WorkloadCost `json:"workloads"`
+	MonthlyCost float64        `json:"monthly_cost"`
+	Savings     float64        `json:"savings"` // compared to the workloads' own models; negative when more expensive
+}
+
+// Simulation is the projected cost of a set of workloads, with the current pricing
+type Simulation struct {
+	Workloads    []WorkloadCost     `json:"workloads"`
+	ByProvider   map[string]float64 `json:"by_provider"` // monthly cost
+	MonthlyCost  float64            `json:"monthly_cost"`
+	Currency     string             `json:"currency"`
+	Alternatives []ModelProjection  `json:"alternatives,omitempty"` // cheapest first
+}
+
+// Simulate projects the daily and monthly cost of workloads with the tracker's current
+// pricing, per workload and per provider, without tracking anything. Every alternative
+// model is priced for all workloads, to compare moving them to it. Costs must be in
+// one currency: set a default currency when models are priced in different ones.
+func (t *DefaultTokenTracker) Simulate(workloads []Workload, alternatives ...string) (Simulation, error) {
+	if len(workloads) == 0 {
+		return Simulation{}, NewError(ErrInvalidParams, "at least one workload is required", nil)
+	}
+	for i, workload := range workloads {
+		if err := workload.validate(); err != nil {
+			return Simulation{}, NewError(ErrInvalidParams, fmt.Sprintf("invalid workload %d", i), err)
+		}
+	}
+
+	simulation := Simulation{ByProvider: make(map[string]float64)}
+	for _, workload := range workloads {
+		cost, err := t.workloadCost(workload, workload.Model)
+		if err != nil {
+			return Simulation{}, err
+		}
+		if err := simulation.useCurrency(cost.CallCost.Currency); err != nil {
+			return Simulation{}, err
+		}
+		simulation.Workloads = append(simulation.Workloads, cost)
+		simulation.ByProvider[cost.Provider] += cost.MonthlyCost
+		simulation.MonthlyCost += cost.MonthlyCost
+	}
+
+	for _, model := range alternatives {
+		projection := ModelProjection{Model: model}
+		for _, workload := range workloads {
+			cost, err := t.workloadCost(workload, model)
+			if err != nil {
+				return Simulation{}, err
+			}
+			if err := simulation.useCurrency(cost.CallCost.Currency); err != nil {
+				return Simulation{}, err
+			}
+			projection.Provider = cost.Provider
+			projection.Workloads = append(projection.Workloads, cost)
+			projection.MonthlyCost += cost.MonthlyCost
+		}
+		projection.Savings = simulation.MonthlyCost - projection.MonthlyCost
+		simulation.Alternatives = append(simulation.Alternatives, projection)
+	}
+	sort.SliceStable(simulation.Alternatives, func(i, j int) bool {
+		return simulation.Alternatives[i].MonthlyCost < simulation.Alternatives[j].MonthlyCost
+	})
+	return simulation, nil
+}
+
+// SimulateUsage projects the cost of the workloads found in recorded usage, see
+// WorkloadsFromUsage and Simulate. The daily rates are those of the filter's time
+// range, or of the span of the matching records when it is open.
+func (t *DefaultTokenTracker) SimulateUsage(filter UsageFilter, alternatives ...string) (Simulation, error) {
+	records, err := t.GetUsage(filter)
+	if err != nil {
+		return Simulation{}, err
+	}
+
+	var period time.Duration
+	if !filter.Start.IsZero() && !filter.End.IsZero() {
+		period = filter.End.Sub(filter.Start)
+	}
+	return t.Simulate(WorkloadsFromUsage(records, period), alternatives...)
+}
+
+// WorkloadsFromUsage derives a workload per provider and model from a sample of recorded
+// usage made over period. A zero period uses the span from the first to the last
+// record, and at least a day.
+func WorkloadsFromUsage(records []UsageMetrics, period time.Duration) []Workload {
+	type totals struct {
+		provider, model       string
+		calls                 int
+		input, output, cached int
+	}
+
+	var first, last time.Time
+	byModel := make(map[metricLabels]*totals)
+	for _, record := range records {
+		if first.IsZero() || record.Timestamp.Before(first) {
+			first = record.Timestamp
+		}
+		if record.Timestamp.After(last) {
+			last = record.Timestamp
+		}
+
+		key := metricLabels{provider: record.Provider, model: record.Model}
+		total, exists := byModel[key]
+		if !exists {
+			total = &totals{provider: record.Provider, model: record.Model}
+			byModel[key] = total
+		}
+		weight := record.SampleWeight()
+		total.calls += weight
+		total.input += weight * record.TokenCount.InputTokens
+		total.output += weight * record.TokenCount.ResponseTokens
+		total.cached += weight * record.TokenCount.CachedInputTokens
+	}
+
+	if period <= 0 {
+		period = max(last.Sub(first), 24*time.Hour)
+	}
+	days := period.Hours() / 24
+
+	workloads := make([]Workload, 0, len(byModel))
+	for _, total := range byModel {
+		workload := Workload{
+			Name:            total.provider + "/" + total.model,
+			Model:           total.model,
+			CallsPerDay:     float64(total.calls) / days,
+			AvgInputTokens:  float64(total.input) / float64(total.calls),
+			AvgOutputTokens: float64(total.output) / float64(total.calls),
+		}
+		if total.input > 0 {
+			workload.CachedInputRatio = float64(total.cached) / float64(total.input)
+		}
+		workloads = append(workloads, workload)
+	}
+	sort.Slice(workloads, func(i, j int) bool { return workloads[i].Name < workloads[j].Name })
+	return workloads
+}
+
+// validate checks that a workload can be priced
+func (w Workload) validate() error {
+	switch {
+	case w.Model == "":
+		return fmt.Errorf("model is required")
+	case w.CallsPerDay < 0, w.AvgInputTokens < 0, w.AvgOutputTokens < 0:
+		return fmt.Errorf("calls and token counts must not be negative")
+	case w.CachedInputRatio < 0 || w.CachedInputRatio > 1:
+		return fmt.Errorf("cached input ratio must be between 0 and 1, got %g", w.CachedInputRatio)
+	}
+	return nil
+}
+
+// workloadCost prices the average call of a workload with a model and projects its cost
+func (t *DefaultTokenTracker) workloadCost(workload Workload, model string) (WorkloadCost, error) {
+	provider, exists := t.providerForModel(model)
+	if !exists {
+		return WorkloadCost{}, NewError(ErrProviderNotFound, "no provider found for model: "+model, nil)
+	}
+
+	inputTokens := int(math.Round(workload.AvgInputTokens))
+	price, err := t.CalculateUsagePrice(model, BillableUsage{
+		InputTokens:       inputTokens,
+		OutputTokens:      int(math.Round(workload.AvgOutputTokens)),
+		CachedInputTokens: int(math.Round(float64(inputTokens) * workload.CachedInputRatio)),
+		Batch:             workload.Batch,
+	})
+	if err != nil {
+		return WorkloadCost{}, err
+	}
+
+	daily := price.TotalCost * workload.CallsPerDay
+	return WorkloadCost{
+		Workload:    workload.Name,
+		Model:       model,
+		Provider:    provider.Name(),
+		CallCost:    price,
+		DailyCost:   daily,
+		MonthlyCost: daily * DaysPerMonth,
+	}, nil
+}
+
+// useCurrency checks that a cost is in the currency of the simulation
+func (s *Simulation) useCurrency(currency string) error {
+	if s.Currency == "" {
+		s.Currency = currency
+		return nil
+	}
+	if currency != s.Currency {
+		return NewError(ErrCurrencyConversion, fmt.Sprintf("costs in %s and %s cannot be added; set a default currency", s.Currency, currency), nil)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package tokentracker
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+// newSimulationTracker creates a tracker with two providers priced from the configuration
+func newSimulationTracker(t *testing.T) *DefaultTokenTracker {
+	tracker := newPricedTestTracker(t)
+	config := tracker.Config()
+	config.SetModelPricing("acme", "acme-large", ModelPricing{InputPricePerToken: 0.00001, OutputPricePerToken: 0.00003, Currency: "USD"})
+	config.SetModelPricing("acme", "acme-small", ModelPricing{InputPricePerToken: 0.000001, OutputPricePerToken: 0.000002, Currency: "USD"})
+	config.SetModelPricing("zeta", "zeta-1", ModelPricing{InputPricePerToken: 0.000002, OutputPricePerToken: 0.000004, Currency: "USD"})
+
+	acme := newPricedProvider(config, "acme", "acme-large", "acme-small")
+	zeta := newPricedProvider(config, "zeta", "zeta-1")
+	tracker.RegisterProvider(&acme)
+	tracker.RegisterProvider(&zeta)
+	return tracker
+}
+
+func TestDefaultTokenTracker_Simulate(t *testing.T) {
+	tracker := newSimulationTracker(t)
+
+	simulation, err := tracker.Simulate([]Workload{
+		{Name: "chat", Model: "acme-large", CallsPerDay: 1000, AvgInputTokens: 500, AvgOutputTokens: 200},
+		{Name: "tagging", Model: "zeta-1", CallsPerDay: 10000, AvgInputTokens: 100, AvgOutputTokens: 10},
+	}, "acme-large", "acme-small")
+	if err != nil {
+		t.Fatalf("Simulate() error = %v", err)
+	}
+
+	chat := (500*0.00001 + 200*0.00003) * 1000 * DaysPerMonth
+	tagging := (100*0.000002 + 10*0.000004) * 10000 * DaysPerMonth
+	if math.Abs(simulation.MonthlyCost-(chat+tagging)) > 1e-9 || simulation.Currency != "USD" {
+		t.Errorf("MonthlyCost = %v %s, want %v USD", simulation.MonthlyCost, simulation.Currency, chat+tagging)
+	}
+	if math.Abs(simulation.ByProvider["acme"]-chat) > 1e-9 || math.Abs(simulation.ByProvider["zeta"]-tagging) > 1e-9 {
+		t.Errorf("ByProvider = %v, want acme %v and zeta %v", simulation.ByProvider, chat, tagging)
+	}
+	if len(simulation.Workloads) != 2 || simulation.Workloads[1].Provider != "zeta" {
+		t.Errorf("Workloads = %+v, want one cost per workload", simulation.Workloads)
+	}
+
+	// Alternatives are sorted cheapest first
+	if len(simulation.Alternatives) != 2 || simulation.Alternatives[0].Model != "acme-small" {
+		t.Fatalf("Alternatives = %+v, want acme-small first", simulation.Alternatives)
+	}
+	small := ((500*0.000001+200*0.000002)*1000 + (100*0.000001+10*0.000002)*10000) * DaysPerMonth
+	if alternative := simulation.Alternatives[0]; math.Abs(alternative.MonthlyCost-small) > 1e-9 || math.Abs(alternative.Savings-(chat+tagging-small)) > 1e-9 {
+		t.Errorf("acme-small projection = %v (savings %v), want %v", alternative.MonthlyCost, alternative.Savings, small)
+	}
+	if simulation.Alternatives[1].Savings >= 0 {
+		t.Errorf("acme-large savings = %v, want a cost increase", simulation.Alternatives[1].Savings)
+	}
+}
+
+func TestDefaultTokenTracker_SimulateErrors(t *testing.T) {
+	tracker := newSimulationTracker(t)
+
+	tests := []struct {
+		name         string
+		workloads    []Workload
+		alternatives []string
+		want         string
+	}{
+		{"no workloads", nil, nil, ErrInvalidParams},
+		{"negative calls", []Workload{{Model: "acme-large", CallsPerDay: -1}}, nil, ErrInvalidParams},
+		{"invalid cached ratio", []Workload{{Model: "acme-large", CachedInputRatio: 2}}, nil, ErrInvalidParams},
+		{"unknown model", []Workload{{Model: "unknown"}}, nil, ErrProviderNotFound},
+		{"unknown alternative", []Workload{{Model: "acme-large"}}, []string{"unknown"}, ErrProviderNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tracker.Simulate(tt.workloads, tt.alternatives...)
+			var trackerErr *TokenTrackerError
+			if !errors.As(err, &trackerErr) || trackerErr.Type != tt.want {
+				t.Errorf("Simulate() error = %v, want %s", err, tt.want)
+			}
+		})
+	}
+
+	// Costs in different currencies cannot be added
+	tracker.config.SetModelPricing("zeta", "zeta-1", ModelPricing{InputPricePerToken: 0.000002, Currency: "EUR"})
+	_, err := tracker.Simulate([]Workload{{Model: "acme-large", CallsPerDay: 1}, {Model: "zeta-1", CallsPerDay: 1}})
+	var trackerErr *TokenTrackerError
+	if !errors.As(err, &trackerErr) || trackerErr.Type != ErrCurrencyConversion {
+		t.Errorf("Simulate() error = %v, want %s", err, ErrCurrencyConversion)
+	}
+}
+
+func TestDefaultTokenTracker_SimulateUsage(t *testing.T) {
+	tracker := newSimulationTracker(t)
+	store := NewMemoryUsageStore()
+	tracker.SetUsageStore(store)
+
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 20; i++ {
+		_, err := tracker.TrackReportedUsage(context.Background(), CallParams{Model: "acme-large", StartTime: start},
+			TokenCount{InputTokens: 400, ResponseTokens: 100, CachedInputTokens: 100})
+		if err != nil {
+			t.Fatalf("TrackReportedUsage() error = %v", err)
+		}
+	}
+	records, _ := store.Query(UsageFilter{})
+	for i := range records {
+		records[i].Timestamp = start.Add(time.Duration(i) * time.Hour)
+	}
+
+	workloads := WorkloadsFromUsage(records, 2*24*time.Hour)
+	want := Workload{Name: "acme/acme-large", Model: "acme-large", CallsPerDay: 10, AvgInputTokens: 400, AvgOutputTokens: 100, CachedInputRatio: 0.25}
+	if len(workloads) != 1 || workloads[0] != want {
+		t.Fatalf("WorkloadsFromUsage() = %+v, want %+v", workloads, want)
+	}
+	// Without a period, the records span less than a day
+	if workloads := WorkloadsFromUsage(records, 0); workloads[0].CallsPerDay != 20 {
+		t.Errorf("CallsPerDay = %v, want 20 calls over at least a day", workloads[0].CallsPerDay)
+	}
+
+	simulation, err := tracker.SimulateUsage(UsageFilter{Model: "acme-large"}, "acme-small")
+	if err != nil {
+		t.Fatalf("SimulateUsage() error = %v", err)
+	}
+	if len(simulation.Workloads) != 1 || len(simulation.Alternatives) != 1 || simulation.Alternatives[0].Savings <= 0 {
+		t.Errorf("SimulateUsage() = %+v, want savings with acme-small", simulation)
+	}
+}
@@ -0,0 +1,413 @@
+package tokentracker
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default webhook sink settings
+const (
+	DefaultWebhookBatchSize       = 100
+	DefaultWebhookFlushInterval   = time.Second
+	DefaultWebhookQueueSize       = 10000
+	DefaultWebhookSignatureHeader = "X-Tokentracker-Signature"
+)
+
+// WebhookSinkCircuit names the circuit breaker of a webhook sink, see Resilience
+const WebhookSinkCircuit = "webhook_sink"
+
+// UsageSink receives the usage of every tracked call for delivery to another system,
+// e.g. a billing service. Unlike a UsageObserver a sink may fail and is flushed and
+// closed with the tracker.
+type UsageSink interface {
+	// Send queues the usage of a tracked call; it should not wait for the delivery
+	Send(metrics UsageMetrics) error
+
+	// Flush delivers the queued usage
+	Flush(ctx context.Context) error
+
+	// Close delivers the queued usage and stops the sink
+	Close() error
+}
+
+// WithSink makes the tracker send every tracked call to the sink
+func WithSink(sink UsageSink) TrackerOption {
+	return func(t *DefaultTokenTracker) {
+		t.sinks = append(t.sinks, sink)
+	}
+}
+
+// AddSink registers a sink on a running tracker
+func (t *DefaultTokenTracker) AddSink(sink UsageSink) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.sinks = append(t.sinks, sink)
+}
+
+// sendToSinks sends tracked usage to the registered sinks
+func (t *DefaultTokenTracker) sendToSinks(metrics UsageMetrics) error {
+	t.mu.RLock()
+	sinks := t.sinks
+	t.mu.RUnlock()
+
+	var lastErr error
+	for _, sink := range sinks {
+		if err := sink.Send(metrics); err != nil {
+			lastErr = err
+		}
+	}
+	if lastErr != nil {
+		return NewError(ErrSinkFailed, "failed to send usage to sink", lastErr)
+	}
+	return nil
+}
+
+// flushSinks delivers the usage queued in the registered sinks
+func (t *DefaultTokenTracker) flushSinks(ctx context.Context) error {
+	t.mu.RLock()
+	sinks := t.sinks
+	t.mu.RUnlock()
+
+	var lastErr error
+	for _, sink := range sinks {
+		if err := sink.Flush(ctx); err != nil {
+			lastErr = err
+		}
+	}
+	if lastErr != nil {
+		return NewError(ErrSinkFailed, "failed to flush sink", lastErr)
+	}
+	return nil
+}
+
+// closeSinks closes the registered sinks
+func (t *DefaultTokenTracker) closeSinks() error {
+	t.mu.Lock()
+	sinks := t.sinks
+	t.sinks = nil
+	t.mu.Unlock()
+
+	var lastErr error
+	for _, sink := range sinks {
+		if err := sink.Close(); err != nil {
+			lastErr = err
+		}
+	}
+	if lastErr != nil {
+		return NewError(ErrSinkFailed, "failed to close sink", lastErr)
+	}
+	return nil
+}
+
+// WebhookSinkOptions configures a WebhookSink
+type WebhookSinkOptions struct {
+	// URL receives the batches as POST requests
+	URL string
+
+	// Secret signs every request body with HMAC-SHA256; nil sends unsigned requests
+	Secret []byte
+
+	// SignatureHeader is the header carrying the signature (empty uses DefaultWebhookSignatureHeader)
+	SignatureHeader string
+
+	// Headers are added to every request, e.g. an Authorization header
+	Headers map[string]string
+
+	// BatchSize is the number of calls sent in one request (0 uses the default)
+	BatchSize int
+
+	// FlushInterval is the longest a call waits for its batch to fill (0 uses the default)
+	FlushInterval time.Duration
+
+	// QueueSize is the number of calls queued before Send fails (0 uses the default)
+	QueueSize int
+
+	// Retry configures the retries of failed requests. Network errors, 429 and 5xx
+	// responses are retried unless Retry.Retryable is set.
+	Retry ResilienceOptions
+
+	// Client sends the requests (nil uses a client with a 30 second timeout)
+	Client *http.Client
+
+	// OnError receives the batches that could not be delivered (nil logs the error)
+	OnError func(events []UsageMetrics, err error)
+
+	// Logger logs the batches that could not be delivered when OnError is nil (nil
+	// uses slog.Default)
+	Logger *slog.Logger
+
+	// Clock times the flush interval, retries and batch timestamps (nil uses the system clock)
+	Clock Clock
+}
+
+// WebhookBatch is the JSON body of a webhook request
+type WebhookBatch struct {
+	SentAt time.Time      `json:"sent_at"`
+	Events []UsageMetrics `json:"events"`
+}
+
+// WebhookSink is a UsageSink POSTing tracked calls as JSON batches to an HTTP endpoint.
+// Calls are sent in the background once a batch is full or the flush interval has
+// passed. Failed requests are retried; batches still failing are passed to OnError
+// and dropped.
+type WebhookSink struct {
+	opts       WebhookSinkOptions
+	resilience *Resilience
+	events     chan UsageMetrics
+	flushes    chan chan error
+	done       chan struct{}
+	closed     bool
+	mu         sync.RWMutex
+}
+
+// webhookStatusError is a non-2xx response of a webhook endpoint
+type webhookStatusError struct {
+	status     string
+	statusCode int
+}
+
+func (e *webhookStatusError) Error() string {
+	return fmt.Sprintf("webhook returned %s", e.status)
+}
+
+// NewWebhookSink creates a sink for the endpoint in opts and starts its background sender
+func NewWebhookSink(opts WebhookSinkOptions) (*WebhookSink, error) {
+	if opts.URL == "" {
+		return nil, NewError(ErrInvalidParams, "webhook URL is required", nil)
+	}
+	if _, err := http.NewRequest(http.MethodPost, opts.URL, nil); err != nil {
+		return nil, NewError(ErrInvalidParams, "invalid webhook URL", err)
+	}
+
+	if opts.SignatureHeader == "" {
+		opts.SignatureHeader = DefaultWebhookSignatureHeader
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultWebhookBatchSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = DefaultWebhookFlushInterval
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = DefaultWebhookQueueSize
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 30 * time.Second}
+	}
+	if opts.Clock == nil {
+		opts.Clock = SystemClock
+	}
+
+	retry := opts.Retry
+	if retry.Retryable == nil {
+		retry.Retryable = retryableWebhookError
+	}
+	if retry.Clock == nil {
+		retry.Clock = opts.Clock
+	}
+
+	s := &WebhookSink{
+		opts:       opts,
+		resilience: NewResilience(retry),
+		events:     make(chan UsageMetrics, opts.QueueSize),
+		flushes:    make(chan chan error),
+		done:       make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+// Send queues a call for delivery. It fails instead of blocking when the queue is full.
+func (s *WebhookSink) Send(metrics UsageMetrics) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return NewError(ErrSinkFailed, "webhook sink is closed", nil)
+	}
+
+	select {
+	case s.events <- metrics:
+		return nil
+	default:
+		return NewError(ErrSinkFailed, "webhook sink queue is full", nil)
+	}
+}
+
+// Flush sends the queued calls and returns the error of the last failed request
+func (s *WebhookSink) Flush(ctx context.Context) error {
+	s.mu.RLock()
+	if s.closed {
+		s.mu.RUnlock()
+		return nil
+	}
+	result := make(chan error, 1)
+	select {
+	case s.flushes <- result:
+	case <-ctx.Done():
+		s.mu.RUnlock()
+		return ctx.Err()
+	}
+	s.mu.RUnlock()
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close sends the queued calls and stops the background sender
+func (s *WebhookSink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		<-s.done
+		return nil
+	}
+	s.closed = true
+	close(s.events)
+	s.mu.Unlock()
+
+	<-s.done
+	return nil
+}
+
+// run is the background sender loop
+func (s *WebhookSink) run() {
+	defer close(s.done)
+
+	ticker := s.opts.Clock.NewTicker(s.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]UsageMetrics, 0, s.opts.BatchSize)
+	send := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := s.deliver(batch)
+		batch = make([]UsageMetrics, 0, s.opts.BatchSize)
+		return err
+	}
+
+	for {
+		select {
+		case metrics, ok := <-s.events:
+			if !ok {
+				_ = send()
+				return
+			}
+			batch = append(batch, metrics)
+			if len(batch) >= s.opts.BatchSize {
+				_ = send()
+			}
+		case result := <-s.flushes:
+			// Send everything queued before the flush
+			var lastErr error
+			for pending := len(s.events); pending > 0; pending-- {
+				batch = append(batch, <-s.events)
+				if len(batch) >= s.opts.BatchSize {
+					if err := send(); err != nil {
+						lastErr = err
+					}
+				}
+			}
+			if err := send(); err != nil {
+				lastErr = err
+			}
+			result <- lastErr
+		case <-ticker.Chan():
+			_ = send()
+		}
+	}
+}
+
+// deliver POSTs a batch, retrying failed requests, and reports a failed delivery to OnError
+func (s *WebhookSink) deliver(events []UsageMetrics) error {
+	body, err := json.Marshal(WebhookBatch{SentAt: s.opts.Clock.Now().UTC(), Events: events})
+	if err == nil {
+		err = s.resilience.Do(context.Background(), WebhookSinkCircuit, func(ctx context.Context) error {
+			return s.post(ctx, body)
+		})
+	}
+	if err == nil {
+		return nil
+	}
+
+	err = NewError(ErrSinkFailed, fmt.Sprintf("failed to deliver %d usage events to webhook", len(events)), err)
+	if s.opts.OnError != nil {
+		s.opts.OnError(events, err)
+	} else {
+		logger := s.opts.Logger
+		if logger == nil {
+			logger = slog.Default()
+		}
+		logger.Error("webhook delivery failed", "events", len(events), "error", err)
+	}
+	return err
+}
+
+// post sends one request with a signed body
+func (s *WebhookSink) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.opts.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range s.opts.Headers {
+		req.Header.Set(name, value)
+	}
+	if s.opts.Secret != nil {
+		req.Header.Set(s.opts.SignatureHeader, SignWebhookPayload(s.opts.Secret, body))
+	}
+
+	resp, err := s.opts.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &webhookStatusError{status: resp.Status, statusCode: resp.StatusCode}
+	}
+	return nil
+}
+
+// retryableWebhookError reports whether a failed request is worth repeating
+func retryableWebhookError(err error) bool {
+	var statusErr *webhookStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.statusCode == http.StatusTooManyRequests || statusErr.statusCode >= 500
+	}
+	return true
+}
+
+// SignWebhookPayload returns the signature of a webhook request body: "sha256=" followed
+// by the hex HMAC-SHA256 of body with secret
+func SignWebhookPayload(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature reports whether signature is the signature of body with
+// secret, for receivers of a WebhookSink
+func VerifyWebhookSignature(secret, body []byte, signature string) bool {
+	expected := SignWebhookPayload(secret, body)
+	return hmac.Equal([]byte(expected), []byte(strings.TrimSpace(signature)))
+}
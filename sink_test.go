@@ -0,0 +1,302 @@
+package tokentracker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookReceiver records the batches POSTed to it, failing the first failures requests
+type webhookReceiver struct {
+	secret   []byte
+	status   int
+	failures int
+	requests int
+	batches  []WebhookBatch
+	mu       sync.Mutex
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.requests++
+	if r.failures > 0 {
+		r.failures--
+		w.WriteHeader(r.status)
+		return
+	}
+
+	body, _ := io.ReadAll(req.Body)
+	if r.secret != nil && !VerifyWebhookSignature(r.secret, body, req.Header.Get(DefaultWebhookSignatureHeader)) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var batch WebhookBatch
+	if err := json.Unmarshal(body, &batch); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	r.batches = append(r.batches, batch)
+}
+
+func (r *webhookReceiver) events() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	events := 0
+	for _, batch := range r.batches {
+		events += len(batch.Events)
+	}
+	return events
+}
+
+func TestWebhookSink_BatchesAndSigns(t *testing.T) {
+	receiver := &webhookReceiver{secret: []byte("s3cret")}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	sink, err := NewWebhookSink(WebhookSinkOptions{
+		URL:           server.URL,
+		Secret:        receiver.secret,
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("NewWebhookSink() error = %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if err := sink.Send(UsageMetrics{Model: "gpt-4", TokenCount: TokenCount{InputTokens: i + 1}}); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+	if err := sink.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	receiver.mu.Lock()
+	sizes := []int{}
+	for _, batch := range receiver.batches {
+		sizes = append(sizes, len(batch.Events))
+	}
+	receiver.mu.Unlock()
+	if len(sizes) != 3 || sizes[0] != 2 || sizes[1] != 2 || sizes[2] != 1 {
+		t.Errorf("batch sizes = %v, want [2 2 1]", sizes)
+	}
+	if got := receiver.batches[0].Events[0]; got.Model != "gpt-4" || got.TokenCount.InputTokens != 1 {
+		t.Errorf("first event = %+v", got)
+	}
+
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := sink.Send(UsageMetrics{}); err == nil {
+		t.Error("Send() after Close() should fail")
+	}
+}
+
+func TestWebhookSink_FlushInterval(t *testing.T) {
+	receiver := &webhookReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	sink, _ := NewWebhookSink(WebhookSinkOptions{URL: server.URL, FlushInterval: 10 * time.Millisecond})
+	defer sink.Close()
+
+	sink.Send(UsageMetrics{Model: "gpt-4"})
+	deadline := time.Now().Add(5 * time.Second)
+	for receiver.events() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("event was not sent after the flush interval")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWebhookSink_Retries(t *testing.T) {
+	receiver := &webhookReceiver{status: http.StatusServiceUnavailable, failures: 2}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	sink, _ := NewWebhookSink(WebhookSinkOptions{
+		URL:   server.URL,
+		Retry: ResilienceOptions{MaxAttempts: 3, Backoff: time.Millisecond},
+	})
+	defer sink.Close()
+
+	sink.Send(UsageMetrics{Model: "gpt-4"})
+	if err := sink.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if receiver.requests != 3 || receiver.events() != 1 {
+		t.Errorf("requests = %d, events = %d, want the batch delivered on the third attempt", receiver.requests, receiver.events())
+	}
+
+	// Client errors are not retried and the batch is passed to OnError
+	rejecting := &webhookReceiver{status: http.StatusBadRequest, failures: 10}
+	rejectingServer := httptest.NewServer(rejecting)
+	defer rejectingServer.Close()
+
+	var failed []UsageMetrics
+	sink, _ = NewWebhookSink(WebhookSinkOptions{
+		URL:     rejectingServer.URL,
+		Retry:   ResilienceOptions{MaxAttempts: 3, Backoff: time.Millisecond},
+		OnError: func(events []UsageMetrics, err error) { failed = events },
+	})
+	defer sink.Close()
+
+	sink.Send(UsageMetrics{Model: "gpt-4"})
+	err := sink.Flush(context.Background())
+	var trackerErr *TokenTrackerError
+	if !errors.As(err, &trackerErr) || trackerErr.Type != ErrSinkFailed {
+		t.Fatalf("Flush() error = %v, want %s", err, ErrSinkFailed)
+	}
+	if rejecting.requests != 1 || len(failed) != 1 {
+		t.Errorf("requests = %d, failed events = %d, want one attempt and the batch reported", rejecting.requests, len(failed))
+	}
+}
+
+func TestWebhookSink_QueueFull(t *testing.T) {
+	blocked := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { <-blocked }))
+	defer server.Close()
+
+	sink, _ := NewWebhookSink(WebhookSinkOptions{URL: server.URL, BatchSize: 1, QueueSize: 1})
+	defer sink.Close()
+	defer close(blocked)
+
+	// The first call is being sent, the second fills the queue
+	var err error
+	for i := 0; i < 10 && err == nil; i++ {
+		err = sink.Send(UsageMetrics{})
+	}
+	if err == nil {
+		t.Error("Send() to a full queue should fail instead of blocking")
+	}
+}
+
+func TestNewWebhookSink_InvalidURL(t *testing.T) {
+	if _, err := NewWebhookSink(WebhookSinkOptions{}); err == nil {
+		t.Error("NewWebhookSink() without URL should fail")
+	}
+	if _, err := NewWebhookSink(WebhookSinkOptions{URL: "://bad"}); err == nil {
+		t.Error("NewWebhookSink() with an invalid URL should fail")
+	}
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	secret, body := []byte("s3cret"), []byte(`{"events":[]}`)
+	signature := SignWebhookPayload(secret, body)
+
+	if !VerifyWebhookSignature(secret, body, signature) {
+		t.Error("VerifyWebhookSignature() = false for a valid signature")
+	}
+	if VerifyWebhookSignature([]byte("other"), body, signature) || VerifyWebhookSignature(secret, []byte("{}"), signature) {
+		t.Error("VerifyWebhookSignature() = true for another secret or body")
+	}
+}
+
+// recordingSink records the calls sent to it
+type recordingSink struct {
+	sent    []UsageMetrics
+	err     error
+	flushes int
+	closed  bool
+	mu      sync.Mutex
+}
+
+func (s *recordingSink) Send(metrics UsageMetrics) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, metrics)
+	return s.err
+}
+
+func (s *recordingSink) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flushes++
+	return nil
+}
+
+func (s *recordingSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+func TestDefaultTokenTracker_Sinks(t *testing.T) {
+	first := &recordingSink{}
+	tracker := newPricedTestTracker(t, WithSink(first))
+	tracker.RegisterProvider(&reportingProvider{newPricedProvider(tracker.Config(), "acme", "acme-1")})
+
+	second := &recordingSink{}
+	tracker.AddSink(second)
+
+	metrics, err := tracker.TrackUsage(CallParams{Model: "acme-1", StartTime: time.Now()}, TokenCount{InputTokens: 100, ResponseTokens: 10})
+	if err != nil {
+		t.Fatalf("TrackUsage() error = %v", err)
+	}
+	for _, sink := range []*recordingSink{first, second} {
+		if len(sink.sent) != 1 || sink.sent[0].Price.TotalCost != metrics.Price.TotalCost {
+			t.Errorf("sink received %+v, want the tracked call", sink.sent)
+		}
+	}
+
+	if err := tracker.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if err := tracker.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if first.flushes != 1 || !first.closed || !second.closed {
+		t.Errorf("flushes = %d, closed = %v, %v, want the sinks flushed and closed with the tracker", first.flushes, first.closed, second.closed)
+	}
+}
+
+func TestDefaultTokenTracker_SinkErrorKeepsBudgetError(t *testing.T) {
+	manager := NewBudgetManager(nil)
+	_ = manager.SetBudget(Budget{Name: "all", CostLimit: 0.005, Hard: true})
+	sink := &recordingSink{err: NewError(ErrSinkFailed, "queue full", nil)}
+	tracker := NewTokenTracker(NewConfig(), WithBudgetManager(manager), WithSink(sink))
+	tracker.RegisterProvider(&MockProvider{
+		name:           "mock",
+		supportedModel: "mock-model",
+		tokenCount:     TokenCount{InputTokens: 10, TotalTokens: 10},
+		price:          Price{TotalCost: 0.01, Currency: "USD"},
+	})
+
+	_, err := tracker.TrackUsage(CallParams{
+		Model:     "mock-model",
+		Params:    TokenCountParams{Model: "mock-model", Text: stringPtr("Hello")},
+		StartTime: time.Now(),
+	}, "response")
+	for _, errType := range []string{ErrBudgetExceeded, ErrSinkFailed} {
+		var trackerErr *TokenTrackerError
+		found := false
+		for _, e := range unwrapJoined(err) {
+			if errors.As(e, &trackerErr) && trackerErr.Type == errType {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("TrackUsage() error = %v, want it to report %s", err, errType)
+		}
+	}
+}
+
+// unwrapJoined returns the errors joined into err
+func unwrapJoined(err error) []error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
@@ -0,0 +1,143 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker"
+)
+
+// migrationFiles are the schema migrations, applied in the order of their numeric prefix
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migration is one versioned schema change
+type migration struct {
+	version    int
+	name       string
+	statements []string
+}
+
+// loadMigrations returns the embedded migrations sorted by version
+func loadMigrations() ([]migration, error) {
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]migration, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		prefix, _, found := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if !found || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s has no version prefix", name)
+		}
+
+		data, err := migrationFiles.ReadFile(path.Join("migrations", name))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, migration{
+			version:    version,
+			name:       strings.TrimSuffix(name, ".sql"),
+			statements: splitStatements(string(data)),
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].version == migrations[i-1].version {
+			return nil, fmt.Errorf("migrations %s and %s have the same version", migrations[i-1].name, migrations[i].name)
+		}
+	}
+	return migrations, nil
+}
+
+// splitStatements splits a migration into its statements, so each can be executed on
+// its own by drivers that do not accept several statements at once
+func splitStatements(script string) []string {
+	var statements []string
+	for _, statement := range strings.Split(script, ";") {
+		if statement = strings.TrimSpace(statement); statement != "" {
+			statements = append(statements, statement)
+		}
+	}
+	return statements
+}
+
+// migrate applies the migrations that have not been applied to db yet, each in its
+// own transaction, and returns the resulting schema version
+func migrate(ctx context.Context, db *sql.DB, clock tokentracker.Clock) (int, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return 0, tokentracker.NewError(tokentracker.ErrStorageFailed, "invalid schema migrations", err)
+	}
+
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version    INTEGER PRIMARY KEY,
+	name       TEXT    NOT NULL,
+	applied_at INTEGER NOT NULL
+)`); err != nil {
+		return 0, tokentracker.NewError(tokentracker.ErrStorageFailed, "failed to create schema_migrations table", err)
+	}
+
+	current, err := schemaVersion(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := applyMigration(ctx, db, m, clock.Now()); err != nil {
+			return current, err
+		}
+		current = m.version
+	}
+	return current, nil
+}
+
+// schemaVersion returns the version of the last applied migration, 0 for an empty database
+func schemaVersion(ctx context.Context, db *sql.DB) (int, error) {
+	var version sql.NullInt64
+	if err := db.QueryRowContext(ctx, "SELECT MAX(version) FROM schema_migrations").Scan(&version); err != nil {
+		return 0, tokentracker.NewError(tokentracker.ErrStorageFailed, "failed to read schema version", err)
+	}
+	return int(version.Int64), nil
+}
+
+// applyMigration executes the statements of a migration and records it
+func applyMigration(ctx context.Context, db *sql.DB, m migration, appliedAt time.Time) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return tokentracker.NewError(tokentracker.ErrStorageFailed, "failed to begin migration "+m.name, err)
+	}
+	defer tx.Rollback()
+
+	for _, statement := range m.statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return tokentracker.NewError(tokentracker.ErrStorageFailed, "failed to apply migration "+m.name, err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+		m.version, m.name, appliedAt.UnixNano()); err != nil {
+		return tokentracker.NewError(tokentracker.ErrStorageFailed, "failed to record migration "+m.name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return tokentracker.NewError(tokentracker.ErrStorageFailed, "failed to commit migration "+m.name, err)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/TrustSight-io/tokentracker"
+)
+
+// recordingDriver is a database/sql driver recording the executed statements. It
+// answers the schema version query with the highest recorded migration.
+type recordingDriver struct {
+	statements []string
+	version    int64
+	mu         sync.Mutex
+}
+
+type recordingConn struct{ driver *recordingDriver }
+type recordingStmt struct {
+	driver *recordingDriver
+	query  string
+}
+type versionRows struct {
+	version int64
+	read    bool
+}
+
+func (d *recordingDriver) Open(name string) (driver.Conn, error) { return recordingConn{d}, nil }
+
+func (c recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return recordingStmt{c.driver, query}, nil
+}
+func (c recordingConn) Close() error              { return nil }
+func (c recordingConn) Begin() (driver.Tx, error) { return c, nil }
+func (c recordingConn) Commit() error             { return nil }
+func (c recordingConn) Rollback() error           { return nil }
+
+func (s recordingStmt) Close() error  { return nil }
+func (s recordingStmt) NumInput() int { return -1 }
+
+func (s recordingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.driver.mu.Lock()
+	defer s.driver.mu.Unlock()
+
+	s.driver.statements = append(s.driver.statements, s.query)
+	if strings.HasPrefix(s.query, "INSERT INTO schema_migrations") {
+		s.driver.version = args[0].(int64)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s recordingStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.driver.mu.Lock()
+	defer s.driver.mu.Unlock()
+	return &versionRows{version: s.driver.version}, nil
+}
+
+func (r *versionRows) Columns() []string { return []string{"version"} }
+func (r *versionRows) Close() error      { return nil }
+
+func (r *versionRows) Next(dest []driver.Value) error {
+	if r.read {
+		return io.EOF
+	}
+	r.read = true
+	if r.version > 0 {
+		dest[0] = r.version
+	}
+	return nil
+}
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("loadMigrations() error = %v", err)
+	}
+	if len(migrations) == 0 || migrations[0].version != 1 || migrations[0].name != "0001_create_usage" {
+		t.Fatalf("migrations = %+v, want 0001_create_usage first", migrations)
+	}
+
+	var indices []string
+	for _, statement := range migrations[0].statements {
+		if strings.HasPrefix(statement, "CREATE INDEX") {
+			indices = append(indices, strings.Fields(statement)[2])
+		}
+	}
+	for _, want := range []string{"usage_timestamp", "usage_provider_timestamp", "usage_model_timestamp", "usage_tags_key_value"} {
+		if !strings.Contains(strings.Join(indices, " "), want) {
+			t.Errorf("indices = %v, want %s", indices, want)
+		}
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	got := splitStatements("CREATE TABLE a (x INTEGER);\n\nCREATE INDEX a_x ON a (x);\n")
+	if len(got) != 2 || got[0] != "CREATE TABLE a (x INTEGER)" || got[1] != "CREATE INDEX a_x ON a (x)" {
+		t.Errorf("splitStatements() = %q", got)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	recorder := &recordingDriver{}
+	sql.Register("sqlitestore-recording", recorder)
+	db, err := sql.Open("sqlitestore-recording", "")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer db.Close()
+
+	version, err := migrate(context.Background(), db, tokentracker.SystemClock)
+	if err != nil {
+		t.Fatalf("migrate() error = %v", err)
+	}
+	migrations, _ := loadMigrations()
+	if want := migrations[len(migrations)-1].version; version != want {
+		t.Errorf("migrate() = %d, want %d", version, want)
+	}
+	applied := len(recorder.statements)
+	if applied < 2 || !strings.HasPrefix(recorder.statements[1], "CREATE TABLE usage") {
+		t.Errorf("statements = %q, want the usage table created", recorder.statements)
+	}
+
+	// Applied migrations are not repeated
+	if _, err := migrate(context.Background(), db, tokentracker.SystemClock); err != nil {
+		t.Fatalf("migrate() error = %v", err)
+	}
+	if len(recorder.statements) != applied+1 {
+		t.Errorf("second migrate() executed %q, want only the schema_migrations check", recorder.statements[applied:])
+	}
+}
@@ -0,0 +1,35 @@
+CREATE TABLE usage (
+	id                INTEGER PRIMARY KEY AUTOINCREMENT,
+	timestamp_ns      INTEGER NOT NULL,
+	provider          TEXT    NOT NULL,
+	model             TEXT    NOT NULL,
+	user_id           TEXT    NOT NULL DEFAULT '',
+	project_id        TEXT    NOT NULL DEFAULT '',
+	trace_id          TEXT    NOT NULL DEFAULT '',
+	span_id           TEXT    NOT NULL DEFAULT '',
+	cost_tier         TEXT    NOT NULL DEFAULT '',
+	duration_ns       INTEGER NOT NULL DEFAULT 0,
+	input_tokens      INTEGER NOT NULL DEFAULT 0,
+	response_tokens   INTEGER NOT NULL DEFAULT 0,
+	total_tokens      INTEGER NOT NULL DEFAULT 0,
+	total_cost        REAL    NOT NULL DEFAULT 0,
+	currency          TEXT    NOT NULL DEFAULT '',
+	token_count       TEXT    NOT NULL,
+	price             TEXT    NOT NULL,
+	tags              TEXT    NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX usage_timestamp ON usage (timestamp_ns);
+CREATE INDEX usage_provider_timestamp ON usage (provider, timestamp_ns);
+CREATE INDEX usage_model_timestamp ON usage (model, timestamp_ns);
+CREATE INDEX usage_user_timestamp ON usage (user_id, timestamp_ns);
+CREATE INDEX usage_project_timestamp ON usage (project_id, timestamp_ns);
+
+CREATE TABLE usage_tags (
+	usage_id INTEGER NOT NULL REFERENCES usage (id) ON DELETE CASCADE,
+	key      TEXT    NOT NULL,
+	value    TEXT    NOT NULL,
+	PRIMARY KEY (usage_id, key)
+);
+
+CREATE INDEX usage_tags_key_value ON usage_tags (key, value, usage_id);
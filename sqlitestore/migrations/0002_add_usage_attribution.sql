@@ -0,0 +1,5 @@
+ALTER TABLE usage ADD COLUMN api_key_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE usage ADD COLUMN organization TEXT NOT NULL DEFAULT '';
+ALTER TABLE usage ADD COLUMN provider_project_id TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX usage_api_key_timestamp ON usage (api_key_id, timestamp_ns);
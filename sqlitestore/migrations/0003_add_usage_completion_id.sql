@@ -0,0 +1 @@
+ALTER TABLE usage ADD COLUMN completion_id TEXT NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+ALTER TABLE usage ADD COLUMN sample_rate INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,113 @@
+package sqlitestore
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker"
+	_ "modernc.org/sqlite"
+)
+
+// sqliteUsage returns the usage of a call for the SQLite tests
+func sqliteUsage(timestamp time.Time, model, userID string, tags map[string]string) tokentracker.UsageMetrics {
+	return tokentracker.UsageMetrics{
+		Timestamp:    timestamp,
+		Provider:     "openai",
+		Model:        model,
+		UserID:       userID,
+		ProjectID:    "project-1",
+		APIKeyID:     "sk-...abcd",
+		Organization: "org-acme",
+		CompletionID: "openai:" + model + userID + timestamp.Format(time.RFC3339),
+		Duration:     1200 * time.Millisecond,
+		TokenCount:   tokentracker.TokenCount{InputTokens: 100, ResponseTokens: 20, TotalTokens: 120, CachedInputTokens: 40},
+		Price:        tokentracker.Price{InputCost: 0.001, OutputCost: 0.0006, TotalCost: 0.0016, Currency: "USD"},
+		Tags:         tags,
+	}
+}
+
+func TestStore_SQLite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.db")
+	keys, err := tokentracker.NewStaticKeyProvider("k1", []byte(strings.Repeat("k", 32)))
+	if err != nil {
+		t.Fatalf("NewStaticKeyProvider() error = %v", err)
+	}
+	store, err := Open("sqlite", path, Options{Keys: keys})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	migrations, _ := loadMigrations()
+	if want := migrations[len(migrations)-1].version; store.SchemaVersion() != want {
+		t.Errorf("SchemaVersion() = %d, want %d", store.SchemaVersion(), want)
+	}
+
+	day := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	records := []tokentracker.UsageMetrics{
+		sqliteUsage(day.AddDate(0, 0, -30), "gpt-4o", "user-1", map[string]string{"team": "search"}),
+		sqliteUsage(day, "gpt-4o", "user-1", map[string]string{"team": "search", "prompt": "classify this ticket"}),
+		sqliteUsage(day.Add(time.Hour), "gpt-4o-mini", "user-2", map[string]string{"team": "support"}),
+	}
+	if err := store.RecordBatch(records); err != nil {
+		t.Fatalf("RecordBatch() error = %v", err)
+	}
+
+	// Records round-trip through SQLite, ordered by timestamp
+	all, err := store.Query(tokentracker.UsageFilter{})
+	if err != nil || len(all) != 3 {
+		t.Fatalf("Query() = %d records, %v, want 3", len(all), err)
+	}
+	got, want := all[1], records[1]
+	if !got.Timestamp.Equal(want.Timestamp) || got.Model != want.Model || got.UserID != want.UserID || got.ProjectID != want.ProjectID ||
+		got.APIKeyID != want.APIKeyID || got.Organization != want.Organization ||
+		got.CompletionID != want.CompletionID || got.Duration != want.Duration || got.TokenCount != want.TokenCount ||
+		got.Price.TotalCost != want.Price.TotalCost || got.Tags["prompt"] != "classify this ticket" {
+		t.Errorf("Query() = %+v, want %+v", got, want)
+	}
+
+	filtered, err := store.Query(tokentracker.UsageFilter{Start: day, Model: "gpt-4o", Tags: map[string]string{"team": "search"}})
+	if err != nil || len(filtered) != 1 || !filtered[0].Timestamp.Equal(day) {
+		t.Errorf("Query() with a filter = %+v, %v, want the call of the day", filtered, err)
+	}
+	if limited, err := store.Query(tokentracker.UsageFilter{Tags: map[string]string{"team": "search"}, Limit: 1}); err != nil || len(limited) != 1 {
+		t.Errorf("Query() with a limit = %d records, %v, want 1", len(limited), err)
+	}
+	byUser, err := store.Query(tokentracker.UsageFilter{UserID: "user-1", ProjectID: "project-1", APIKeyID: "sk-...abcd", Limit: 5})
+	if err != nil || len(byUser) != 2 || byUser[0].UserID != "user-1" {
+		t.Errorf("Query() by user = %+v, %v, want the two calls of user-1", byUser, err)
+	}
+
+	// The tags and customer identifiers are encrypted at rest
+	var tags, userID, projectID, apiKeyID, organization string
+	if err := store.db.QueryRow("SELECT tags, user_id, project_id, api_key_id, organization FROM usage WHERE model = ? AND timestamp_ns = ?", "gpt-4o", day.UnixNano()).
+		Scan(&tags, &userID, &projectID, &apiKeyID, &organization); err != nil {
+		t.Fatalf("QueryRow() error = %v", err)
+	}
+	if strings.Contains(tags, "classify") || !tokentracker.IsEncryptedRecord(tags) {
+		t.Errorf("tags column = %q, want it encrypted", tags)
+	}
+	for _, column := range []string{userID, projectID, apiKeyID, organization} {
+		if !tokentracker.IsEncryptedRecord(column) || strings.Contains(column, "user-1") || strings.Contains(column, "acme") {
+			t.Errorf("identifier column = %q, want it encrypted", column)
+		}
+	}
+
+	pruned, err := store.Prune(day.AddDate(0, 0, -1))
+	if err != nil || pruned != 1 {
+		t.Errorf("Prune() = %d, %v, want the old call pruned", pruned, err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// A reopened database keeps its usage and is not migrated again
+	store, err = Open("sqlite", path, Options{Keys: keys})
+	if err != nil {
+		t.Fatalf("Open() of an existing database error = %v", err)
+	}
+	defer store.Close()
+	if all, err := store.Query(tokentracker.UsageFilter{}); err != nil || len(all) != 2 {
+		t.Errorf("Query() after reopening = %d records, %v, want 2", len(all), err)
+	}
+}
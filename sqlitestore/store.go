@@ -0,0 +1,429 @@
+// Package sqlitestore provides a tokentracker.UsageStore backed by a SQLite database,
+// for single-binary deployments that need durable, queryable usage without a database
+// server. The schema is created and upgraded by embedded migrations when the store is
+// opened, and usage older than the retention period can be pruned in the background.
+// With encryption keys, the tags and the customer identifiers of every record (user,
+// project, API key and organization) are encrypted at rest with AES-GCM; filters on
+// them are then applied after decryption. Timestamps, providers, models, token counts
+// and prices stay in plaintext, as queries filter, order and prune by them.
+//
+// The package uses database/sql and does not import a driver. Import a pure-Go SQLite
+// driver, e.g. modernc.org/sqlite, which registers itself as "sqlite":
+//
+//	import _ "modernc.org/sqlite"
+//
+//	store, err := sqlitestore.Open("sqlite", "usage.db", sqlitestore.Options{Retention: 90 * 24 * time.Hour})
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker"
+)
+
+// DefaultPruneInterval is how often usage older than the retention period is deleted
+const DefaultPruneInterval = time.Hour
+
+// Options configures a Store
+type Options struct {
+	// Retention is how long usage is kept; older usage is pruned in the background (0 keeps all)
+	Retention time.Duration
+
+	// PruneInterval is how often usage is pruned (0 uses the default)
+	PruneInterval time.Duration
+
+	// Clock times the pruning and the migration timestamps (nil uses the system clock)
+	Clock tokentracker.Clock
+
+	// Keys encrypts the tags, user and project IDs, API key IDs and organizations of
+	// new records when set; they are decrypted with them when read. Timestamps,
+	// providers, models, token counts and prices are not encrypted.
+	Keys tokentracker.KeyProvider
+}
+
+// Store is a tokentracker.UsageStore backed by a SQLite database
+type Store struct {
+	db        *sql.DB
+	ownsDB    bool
+	opts      Options
+	encryptor *tokentracker.Encryptor
+	version   int
+	stop      chan struct{}
+	done      chan struct{}
+	closed    bool
+	mu        sync.Mutex
+}
+
+// Open opens the SQLite database at path with the named driver and migrates it
+func Open(driverName, path string, opts Options) (*Store, error) {
+	db, err := sql.Open(driverName, path)
+	if err != nil {
+		return nil, tokentracker.NewError(tokentracker.ErrStorageFailed, fmt.Sprintf("failed to open usage database: %s", path), err)
+	}
+	// SQLite allows one writer at a time; a single connection avoids "database is locked"
+	db.SetMaxOpenConns(1)
+
+	store, err := New(db, opts)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	store.ownsDB = true
+	return store, nil
+}
+
+// New creates a store on an open database and migrates it. Closing the store does
+// not close db.
+func New(db *sql.DB, opts Options) (*Store, error) {
+	if db == nil {
+		return nil, tokentracker.NewError(tokentracker.ErrInvalidParams, "database is required", nil)
+	}
+	if opts.PruneInterval <= 0 {
+		opts.PruneInterval = DefaultPruneInterval
+	}
+	if opts.Clock == nil {
+		opts.Clock = tokentracker.SystemClock
+	}
+
+	version, err := migrate(context.Background(), db, opts.Clock)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{
+		db:      db,
+		opts:    opts,
+		version: version,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if opts.Keys != nil {
+		s.encryptor = tokentracker.NewEncryptor(opts.Keys)
+	}
+	if opts.Retention > 0 {
+		go s.pruneLoop()
+	} else {
+		close(s.done)
+	}
+	return s, nil
+}
+
+// SchemaVersion returns the version of the last migration applied to the database
+func (s *Store) SchemaVersion() int {
+	return s.version
+}
+
+// Record stores the usage of a single tracked call
+func (s *Store) Record(metrics tokentracker.UsageMetrics) error {
+	return s.RecordBatch([]tokentracker.UsageMetrics{metrics})
+}
+
+// RecordBatch stores the usage of many calls in a single transaction
+func (s *Store) RecordBatch(records []tokentracker.UsageMetrics) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return tokentracker.NewError(tokentracker.ErrStorageFailed, "failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	for _, metrics := range records {
+		if err := insertUsage(tx, metrics, s.encryptor); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return tokentracker.NewError(tokentracker.ErrStorageFailed, "failed to commit usage", err)
+	}
+	return nil
+}
+
+// insertUsage inserts the usage of a call and its tags. Encrypted tags are not
+// indexed in usage_tags.
+func insertUsage(tx *sql.Tx, metrics tokentracker.UsageMetrics, encryptor *tokentracker.Encryptor) error {
+	tokenCount, err := json.Marshal(metrics.TokenCount)
+	if err != nil {
+		return tokentracker.NewError(tokentracker.ErrStorageFailed, "failed to encode token count", err)
+	}
+	price, err := json.Marshal(metrics.Price)
+	if err != nil {
+		return tokentracker.NewError(tokentracker.ErrStorageFailed, "failed to encode price", err)
+	}
+	tags, err := encodeTags(metrics.Tags, encryptor)
+	if err != nil {
+		return err
+	}
+	identifiers := []*string{&metrics.UserID, &metrics.ProjectID, &metrics.APIKeyID, &metrics.Organization}
+	for _, identifier := range identifiers {
+		if *identifier, err = sealIdentifier(*identifier, encryptor); err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.Exec(`INSERT INTO usage (timestamp_ns, provider, model, user_id, project_id, trace_id, span_id, cost_tier,
+	api_key_id, organization, provider_project_id, completion_id, sample_rate,
+	duration_ns, input_tokens, response_tokens, total_tokens, total_cost, currency, token_count, price, tags)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		metrics.Timestamp.UnixNano(), metrics.Provider, metrics.Model, metrics.UserID, metrics.ProjectID,
+		metrics.TraceID, metrics.SpanID, metrics.CostTier,
+		metrics.APIKeyID, metrics.Organization, metrics.ProviderProjectID, metrics.CompletionID, metrics.SampleRate, int64(metrics.Duration),
+		metrics.TokenCount.InputTokens, metrics.TokenCount.ResponseTokens, metrics.TokenCount.TotalTokens,
+		metrics.Price.TotalCost, metrics.Price.Currency, string(tokenCount), string(price), tags)
+	if err != nil {
+		return tokentracker.NewError(tokentracker.ErrStorageFailed, "failed to insert usage", err)
+	}
+
+	if len(metrics.Tags) > 0 && encryptor == nil {
+		id, err := result.LastInsertId()
+		if err != nil {
+			return tokentracker.NewError(tokentracker.ErrStorageFailed, "failed to read usage ID", err)
+		}
+		for key, value := range metrics.Tags {
+			if _, err := tx.Exec("INSERT INTO usage_tags (usage_id, key, value) VALUES (?, ?, ?)", id, key, value); err != nil {
+				return tokentracker.NewError(tokentracker.ErrStorageFailed, "failed to insert usage tag", err)
+			}
+		}
+	}
+	return nil
+}
+
+// encodeTags returns the tags column of a record, encrypted when encryptor is set
+func encodeTags(tags map[string]string, encryptor *tokentracker.Encryptor) (string, error) {
+	data, err := json.Marshal(tags)
+	if err != nil {
+		return "", tokentracker.NewError(tokentracker.ErrStorageFailed, "failed to encode tags", err)
+	}
+	if encryptor == nil {
+		return string(data), nil
+	}
+	return encryptor.Seal(data)
+}
+
+// sealIdentifier returns the column value of a customer identifier, encrypted when
+// encryptor is set. Empty identifiers stay empty.
+func sealIdentifier(value string, encryptor *tokentracker.Encryptor) (string, error) {
+	if encryptor == nil || value == "" {
+		return value, nil
+	}
+	return encryptor.Seal([]byte(value))
+}
+
+// openIdentifier returns a customer identifier from its column value, decrypting it
+// when necessary
+func openIdentifier(value string, encryptor *tokentracker.Encryptor) (string, error) {
+	if !tokentracker.IsEncryptedRecord(value) {
+		return value, nil
+	}
+	if encryptor == nil {
+		return "", tokentracker.NewError(tokentracker.ErrEncryptionFailed, "encrypted identifiers found but no key provider configured", nil)
+	}
+	plaintext, err := encryptor.Open(value)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// decodeTags parses the tags column of a record, decrypting it when necessary
+func decodeTags(value string, encryptor *tokentracker.Encryptor) (map[string]string, error) {
+	data := []byte(value)
+	if tokentracker.IsEncryptedRecord(value) {
+		if encryptor == nil {
+			return nil, tokentracker.NewError(tokentracker.ErrEncryptionFailed, "encrypted tags found but no key provider configured", nil)
+		}
+		plaintext, err := encryptor.Open(value)
+		if err != nil {
+			return nil, err
+		}
+		data = plaintext
+	}
+
+	var tags map[string]string
+	if err := json.Unmarshal(data, &tags); err != nil {
+		return nil, tokentracker.NewError(tokentracker.ErrStorageFailed, "failed to decode tags", err)
+	}
+	return tags, nil
+}
+
+// Query returns the stored usage matching the filter, ordered by timestamp
+func (s *Store) Query(filter tokentracker.UsageFilter) ([]tokentracker.UsageMetrics, error) {
+	// Encrypted columns cannot be compared in SQL, so their filters and the limit are
+	// applied here
+	sqlFilter := filter
+	matchHere := s.encryptor != nil && (len(filter.Tags) > 0 || filter.UserID != "" || filter.ProjectID != "" || filter.APIKeyID != "")
+	if matchHere {
+		sqlFilter.Tags, sqlFilter.UserID, sqlFilter.ProjectID, sqlFilter.APIKeyID, sqlFilter.Limit = nil, "", "", "", 0
+	}
+
+	query, args := buildQuery(sqlFilter)
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, tokentracker.NewError(tokentracker.ErrStorageFailed, "failed to query usage", err)
+	}
+	defer rows.Close()
+
+	records := make([]tokentracker.UsageMetrics, 0)
+	for rows.Next() {
+		var (
+			metrics                 tokentracker.UsageMetrics
+			timestamp, duration     int64
+			tokenCount, price, tags string
+		)
+		if err := rows.Scan(&timestamp, &metrics.Provider, &metrics.Model, &metrics.UserID, &metrics.ProjectID,
+			&metrics.TraceID, &metrics.SpanID, &metrics.CostTier,
+			&metrics.APIKeyID, &metrics.Organization, &metrics.ProviderProjectID, &metrics.CompletionID, &metrics.SampleRate, &duration, &tokenCount, &price, &tags); err != nil {
+			return nil, tokentracker.NewError(tokentracker.ErrStorageFailed, "failed to read usage", err)
+		}
+
+		metrics.Timestamp = time.Unix(0, timestamp).UTC()
+		metrics.Duration = time.Duration(duration)
+		if err := json.Unmarshal([]byte(tokenCount), &metrics.TokenCount); err != nil {
+			return nil, tokentracker.NewError(tokentracker.ErrStorageFailed, "failed to decode token count", err)
+		}
+		if err := json.Unmarshal([]byte(price), &metrics.Price); err != nil {
+			return nil, tokentracker.NewError(tokentracker.ErrStorageFailed, "failed to decode price", err)
+		}
+		if metrics.Tags, err = decodeTags(tags, s.encryptor); err != nil {
+			return nil, err
+		}
+		for _, identifier := range []*string{&metrics.UserID, &metrics.ProjectID, &metrics.APIKeyID, &metrics.Organization} {
+			if *identifier, err = openIdentifier(*identifier, s.encryptor); err != nil {
+				return nil, err
+			}
+		}
+		if matchHere && !filter.Matches(metrics) {
+			continue
+		}
+		records = append(records, metrics)
+		if matchHere && filter.Limit > 0 && len(records) == filter.Limit {
+			break
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, tokentracker.NewError(tokentracker.ErrStorageFailed, "failed to read usage", err)
+	}
+	return records, nil
+}
+
+// buildQuery returns the SELECT statement and arguments for a filter
+func buildQuery(filter tokentracker.UsageFilter) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	add := func(condition string, values ...interface{}) {
+		conditions = append(conditions, condition)
+		args = append(args, values...)
+	}
+
+	if !filter.Start.IsZero() {
+		add("timestamp_ns >= ?", filter.Start.UnixNano())
+	}
+	if !filter.End.IsZero() {
+		add("timestamp_ns < ?", filter.End.UnixNano())
+	}
+	if filter.Model != "" {
+		add("model = ?", filter.Model)
+	}
+	if filter.Provider != "" {
+		add("provider = ?", filter.Provider)
+	}
+	if filter.UserID != "" {
+		add("user_id = ?", filter.UserID)
+	}
+	if filter.ProjectID != "" {
+		add("project_id = ?", filter.ProjectID)
+	}
+	if filter.APIKeyID != "" {
+		add("api_key_id = ?", filter.APIKeyID)
+	}
+
+	keys := make([]string, 0, len(filter.Tags))
+	for key := range filter.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		add("EXISTS (SELECT 1 FROM usage_tags WHERE usage_tags.usage_id = usage.id AND usage_tags.key = ? AND usage_tags.value = ?)", key, filter.Tags[key])
+	}
+
+	query := "SELECT timestamp_ns, provider, model, user_id, project_id, trace_id, span_id, cost_tier, api_key_id, organization, provider_project_id, completion_id, sample_rate, duration_ns, token_count, price, tags FROM usage"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY timestamp_ns, id"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+	return query, args
+}
+
+// Prune deletes the usage recorded before the given time and returns the number of
+// deleted calls
+func (s *Store) Prune(before time.Time) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, tokentracker.NewError(tokentracker.ErrStorageFailed, "failed to begin transaction", err)
+	}
+	defer tx.Rollback()
+
+	cutoff := before.UnixNano()
+	if _, err := tx.Exec("DELETE FROM usage_tags WHERE usage_id IN (SELECT id FROM usage WHERE timestamp_ns < ?)", cutoff); err != nil {
+		return 0, tokentracker.NewError(tokentracker.ErrStorageFailed, "failed to prune usage tags", err)
+	}
+	result, err := tx.Exec("DELETE FROM usage WHERE timestamp_ns < ?", cutoff)
+	if err != nil {
+		return 0, tokentracker.NewError(tokentracker.ErrStorageFailed, "failed to prune usage", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, tokentracker.NewError(tokentracker.ErrStorageFailed, "failed to commit pruning", err)
+	}
+
+	deleted, _ := result.RowsAffected()
+	return deleted, nil
+}
+
+// pruneLoop deletes usage older than the retention period now and at every prune interval
+func (s *Store) pruneLoop() {
+	defer close(s.done)
+
+	ticker := s.opts.Clock.NewTicker(s.opts.PruneInterval)
+	defer ticker.Stop()
+
+	for {
+		// Failed prunes are retried at the next interval
+		_, _ = s.Prune(s.opts.Clock.Now().Add(-s.opts.Retention))
+
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.Chan():
+		}
+	}
+}
+
+// Close stops the pruning and closes the database when the store opened it
+func (s *Store) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.stop)
+	s.mu.Unlock()
+
+	<-s.done
+	if s.ownsDB {
+		if err := s.db.Close(); err != nil {
+			return tokentracker.NewError(tokentracker.ErrStorageFailed, "failed to close usage database", err)
+		}
+	}
+	return nil
+}
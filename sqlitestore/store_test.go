@@ -0,0 +1,107 @@
+package sqlitestore
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker"
+)
+
+func TestBuildQuery(t *testing.T) {
+	query, args := buildQuery(tokentracker.UsageFilter{})
+	if strings.Contains(query, "WHERE") || strings.Contains(query, "LIMIT") || len(args) != 0 {
+		t.Errorf("buildQuery() of an empty filter = %q, %v", query, args)
+	}
+	if !strings.HasSuffix(query, "ORDER BY timestamp_ns, id") {
+		t.Errorf("buildQuery() = %q, want usage ordered by timestamp", query)
+	}
+
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	query, args = buildQuery(tokentracker.UsageFilter{
+		Start:     start,
+		End:       start.AddDate(0, 1, 0),
+		Model:     "gpt-4o",
+		Provider:  "openai",
+		ProjectID: "project-1",
+		Tags:      map[string]string{"team": "search", "feature": "answers"},
+		Limit:     10,
+	})
+	for _, want := range []string{"timestamp_ns >= ?", "timestamp_ns < ?", "model = ?", "provider = ?", "project_id = ?", "usage_tags.key = ?", "LIMIT ?"} {
+		if !strings.Contains(query, want) {
+			t.Errorf("buildQuery() = %q, want %q", query, want)
+		}
+	}
+	if strings.Contains(query, "user_id = ?") {
+		t.Errorf("buildQuery() = %q, want no user condition", query)
+	}
+
+	want := []interface{}{start.UnixNano(), start.AddDate(0, 1, 0).UnixNano(), "gpt-4o", "openai", "project-1", "feature", "answers", "team", "search", 10}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %v, want %v", args, want)
+	}
+}
+
+func TestNew_RequiresDatabase(t *testing.T) {
+	if _, err := New(nil, Options{}); err == nil {
+		t.Error("New() without a database should fail")
+	}
+}
+
+func TestEncodeTags_Encrypted(t *testing.T) {
+	keys, err := tokentracker.NewStaticKeyProvider("k1", []byte(strings.Repeat("k", 32)))
+	if err != nil {
+		t.Fatalf("NewStaticKeyProvider() error = %v", err)
+	}
+	encryptor := tokentracker.NewEncryptor(keys)
+	tags := map[string]string{"prompt": "classify this ticket"}
+
+	value, err := encodeTags(tags, encryptor)
+	if err != nil {
+		t.Fatalf("encodeTags() error = %v", err)
+	}
+	if strings.Contains(value, "classify") || !tokentracker.IsEncryptedRecord(value) {
+		t.Errorf("encodeTags() = %q, want the tags encrypted", value)
+	}
+
+	decoded, err := decodeTags(value, encryptor)
+	if err != nil || !reflect.DeepEqual(decoded, tags) {
+		t.Errorf("decodeTags() = %v, %v, want %v", decoded, err, tags)
+	}
+	if _, err := decodeTags(value, nil); err == nil {
+		t.Error("decodeTags() of encrypted tags without keys should fail")
+	}
+
+	// Tags written before encryption was enabled stay readable
+	if decoded, err := decodeTags(`{"team":"search"}`, encryptor); err != nil || decoded["team"] != "search" {
+		t.Errorf("decodeTags() of plaintext tags = %v, %v", decoded, err)
+	}
+}
+
+func TestSealIdentifier(t *testing.T) {
+	keys, err := tokentracker.NewStaticKeyProvider("k1", []byte(strings.Repeat("k", 32)))
+	if err != nil {
+		t.Fatalf("NewStaticKeyProvider() error = %v", err)
+	}
+	encryptor := tokentracker.NewEncryptor(keys)
+
+	value, err := sealIdentifier("user-42", encryptor)
+	if err != nil || strings.Contains(value, "user-42") || !tokentracker.IsEncryptedRecord(value) {
+		t.Fatalf("sealIdentifier() = %q, %v, want the identifier encrypted", value, err)
+	}
+	if opened, err := openIdentifier(value, encryptor); err != nil || opened != "user-42" {
+		t.Errorf("openIdentifier() = %q, %v, want user-42", opened, err)
+	}
+	if _, err := openIdentifier(value, nil); err == nil {
+		t.Error("openIdentifier() of an encrypted identifier without keys should fail")
+	}
+
+	// Empty identifiers and identifiers written before encryption was enabled
+	if value, _ := sealIdentifier("", encryptor); value != "" {
+		t.Errorf("sealIdentifier() of an empty identifier = %q, want it empty", value)
+	}
+	if opened, err := openIdentifier("user-7", encryptor); err != nil || opened != "user-7" {
+		t.Errorf("openIdentifier() of a plaintext identifier = %q, %v", opened, err)
+	}
+}
@@ -0,0 +1,309 @@
+package tokentracker
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StatementFormat identifies the rendering of a usage statement
+type StatementFormat string
+
+// Supported statement formats
+const (
+	StatementJSON StatementFormat = "json"
+	StatementCSV  StatementFormat = "csv"
+	StatementHTML StatementFormat = "html"
+)
+
+// DefaultStatementTopModels is the number of models listed per account of a statement
+const DefaultStatementTopModels = 3
+
+// StatementCSVHeader are the columns of statements in CSV. Top models are separated by
+// semicolons, costliest first.
+var StatementCSVHeader = []string{
+	"period_start", "period_end", "account", "calls",
+	"input_tokens", "response_tokens", "total_tokens", "cost", "currency", "top_models",
+}
+
+// StatementOptions configures usage statements
+type StatementOptions struct {
+	// GroupBy is the dimension accounts are billed by, e.g. GroupByProject for tenants or
+	// GroupByTag("team") (empty uses GroupByProject; time dimensions are not allowed)
+	GroupBy GroupBy
+
+	// Filter restricts the usage billed; its Start and End are set to the period
+	Filter UsageFilter
+
+	// Location is the time zone of month boundaries (nil means UTC)
+	Location *time.Location
+
+	// TopModels is the number of models listed per account (0 uses DefaultStatementTopModels)
+	TopModels int
+}
+
+// StatementModel is the usage of a model by an account
+type StatementModel struct {
+	Provider    string  `json:"provider"`
+	Model       string  `json:"model"`
+	Calls       int     `json:"calls"`
+	TotalTokens int     `json:"total_tokens"`
+	Cost        float64 `json:"cost"`
+}
+
+// StatementLine is the usage of an account, e.g. a tenant or a team, in a period
+type StatementLine struct {
+	Account        string           `json:"account"` // "" for usage without the dimension
+	Calls          int              `json:"calls"`
+	InputTokens    int              `json:"input_tokens"`
+	ResponseTokens int              `json:"response_tokens"`
+	TotalTokens    int              `json:"total_tokens"`
+	Cost           float64          `json:"cost"`
+	Currency       string           `json:"currency"`
+	TopModels      []StatementModel `json:"top_models,omitempty"` // costliest first
+}
+
+// add adds a usage record to the line's totals, scaling sampled records
+func (l *StatementLine) add(metrics UsageMetrics) {
+	weight := metrics.SampleWeight()
+	l.Calls += weight
+	l.InputTokens += weight * metrics.TokenCount.InputTokens
+	l.ResponseTokens += weight * metrics.TokenCount.ResponseTokens
+	l.TotalTokens += weight * metrics.TokenCount.TotalTokens
+	l.Cost += float64(weight) * metrics.Price.TotalCost
+	if l.Currency == "" {
+		l.Currency = metrics.Price.Currency
+	}
+}
+
+// UsageStatement is a cost allocation statement: the usage of every account in a period
+type UsageStatement struct {
+	Start   time.Time `json:"start"` // inclusive
+	End     time.Time `json:"end"`   // exclusive
+	GroupBy GroupBy   `json:"group_by"`
+
+	// Lines are ordered by cost, highest first
+	Lines []StatementLine `json:"lines"`
+
+	// Total is the usage of all accounts; it has no account and lists no models
+	Total StatementLine `json:"total"`
+
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// BuildUsageStatement allocates the cost of usage records to accounts
+func BuildUsageStatement(records []UsageMetrics, start, end time.Time, opts StatementOptions) (UsageStatement, error) {
+	if opts.GroupBy == "" {
+		opts.GroupBy = GroupByProject
+	}
+	switch opts.GroupBy {
+	case GroupByProvider, GroupByModel, GroupByUser, GroupByProject, GroupByAPIKey:
+	default:
+		if _, ok := opts.GroupBy.TagKey(); !ok {
+			return UsageStatement{}, NewError(ErrInvalidParams, fmt.Sprintf("statements cannot be grouped by %s", opts.GroupBy), nil)
+		}
+	}
+	if opts.TopModels < 0 {
+		return UsageStatement{}, NewError(ErrInvalidParams, "top models must not be negative", nil)
+	}
+	if opts.TopModels == 0 {
+		opts.TopModels = DefaultStatementTopModels
+	}
+
+	statement := UsageStatement{Start: start, End: end, GroupBy: opts.GroupBy}
+	lines := make(map[string]*StatementLine)
+	models := make(map[string]map[metricLabels]*StatementModel)
+	for _, record := range records {
+		account := statementAccount(record, opts.GroupBy)
+		line, exists := lines[account]
+		if !exists {
+			line = &StatementLine{Account: account}
+			lines[account] = line
+			models[account] = make(map[metricLabels]*StatementModel)
+		}
+		line.add(record)
+		statement.Total.add(record)
+
+		key := metricLabels{provider: record.Provider, model: record.Model}
+		model, exists := models[account][key]
+		if !exists {
+			model = &StatementModel{Provider: record.Provider, Model: record.Model}
+			models[account][key] = model
+		}
+		weight := record.SampleWeight()
+		model.Calls += weight
+		model.TotalTokens += weight * record.TokenCount.TotalTokens
+		model.Cost += float64(weight) * record.Price.TotalCost
+	}
+
+	statement.Lines = make([]StatementLine, 0, len(lines))
+	for account, line := range lines {
+		for _, model := range models[account] {
+			line.TopModels = append(line.TopModels, *model)
+		}
+		sort.Slice(line.TopModels, func(i, j int) bool {
+			a, b := line.TopModels[i], line.TopModels[j]
+			if a.Cost != b.Cost {
+				return a.Cost > b.Cost
+			}
+			return a.Model < b.Model
+		})
+		if len(line.TopModels) > opts.TopModels {
+			line.TopModels = line.TopModels[:opts.TopModels]
+		}
+		statement.Lines = append(statement.Lines, *line)
+	}
+	sort.Slice(statement.Lines, func(i, j int) bool {
+		a, b := statement.Lines[i], statement.Lines[j]
+		if a.Cost != b.Cost {
+			return a.Cost > b.Cost
+		}
+		return a.Account < b.Account
+	})
+	return statement, nil
+}
+
+// statementAccount returns the account a record is billed to
+func statementAccount(record UsageMetrics, groupBy GroupBy) string {
+	switch groupBy {
+	case GroupByProvider:
+		return record.Provider
+	case GroupByModel:
+		return record.Model
+	case GroupByUser:
+		return record.UserID
+	case GroupByProject:
+		return record.ProjectID
+	case GroupByAPIKey:
+		return record.APIKeyID
+	}
+	key, _ := groupBy.TagKey()
+	return record.Tags[key]
+}
+
+// UsageStatement allocates the cost of the stored usage between start and end to
+// accounts. Usage tracked in other currencies is reported in the default currency.
+func (t *DefaultTokenTracker) UsageStatement(start, end time.Time, opts StatementOptions) (UsageStatement, error) {
+	filter := opts.Filter
+	filter.Start, filter.End, filter.Limit = start, end, 0
+
+	records, err := t.GetUsage(filter)
+	if err != nil {
+		return UsageStatement{}, err
+	}
+	records, err = t.config.convertUsage(records)
+	if err != nil {
+		return UsageStatement{}, err
+	}
+
+	statement, err := BuildUsageStatement(records, start, end, opts)
+	if err != nil {
+		return UsageStatement{}, err
+	}
+	statement.GeneratedAt = t.clock().Now()
+	return statement, nil
+}
+
+// MonthlyStatement allocates the cost of the stored usage of a calendar month to accounts
+func (t *DefaultTokenTracker) MonthlyStatement(year int, month time.Month, opts StatementOptions) (UsageStatement, error) {
+	loc := opts.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	start := time.Date(year, month, 1, 0, 0, 0, 0, loc)
+	return t.UsageStatement(start, start.AddDate(0, 1, 0), opts)
+}
+
+// WriteUsageStatement renders a statement in the given format
+func WriteUsageStatement(w io.Writer, format StatementFormat, statement UsageStatement) error {
+	var err error
+	switch format {
+	case StatementJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(statement)
+	case StatementCSV:
+		err = writeStatementCSV(w, statement)
+	case StatementHTML:
+		err = statementTemplate.Execute(w, statement)
+	default:
+		return NewError(ErrInvalidParams, fmt.Sprintf("unknown statement format: %s", format), nil)
+	}
+	if err != nil {
+		return NewError(ErrStorageFailed, "failed to write usage statement", err)
+	}
+	return nil
+}
+
+// writeStatementCSV writes a row per account followed by the total, whose account is "total"
+func writeStatementCSV(w io.Writer, statement UsageStatement) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(StatementCSVHeader); err != nil {
+		return err
+	}
+
+	total := statement.Total
+	total.Account = "total"
+	lines := append(append([]StatementLine(nil), statement.Lines...), total)
+
+	start, end := statement.Start.Format(time.RFC3339), statement.End.Format(time.RFC3339)
+	for _, line := range lines {
+		models := make([]string, len(line.TopModels))
+		for i, model := range line.TopModels {
+			models[i] = model.Model
+		}
+		record := []string{
+			start, end, line.Account, strconv.Itoa(line.Calls),
+			strconv.Itoa(line.InputTokens), strconv.Itoa(line.ResponseTokens), strconv.Itoa(line.TotalTokens),
+			strconv.FormatFloat(line.Cost, 'f', -1, 64), line.Currency, strings.Join(models, ";"),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// statementFormat renders the numbers of HTML statements
+var statementFormat = DefaultFormatOptions()
+
+// statementTemplate renders a statement as a standalone HTML page
+var statementTemplate = template.Must(template.New("statement").Funcs(template.FuncMap{
+	"date":   func(t time.Time) string { return t.Format("2006-01-02") },
+	"tokens": statementFormat.FormatTokens,
+	"cost":   statementFormat.FormatCost,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Usage statement {{date .Start}} to {{date .End}}</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; }
+td.number { text-align: right; }
+</style>
+</head>
+<body>
+<h1>Usage statement</h1>
+<p>Period: {{date .Start}} to {{date .End}} (exclusive), by {{.GroupBy}}</p>
+<table>
+<tr><th>Account</th><th>Calls</th><th>Input tokens</th><th>Response tokens</th><th>Total tokens</th><th>Cost</th><th>Top models</th></tr>
+{{- range .Lines}}
+<tr><td>{{if .Account}}{{.Account}}{{else}}(unattributed){{end}}</td><td class="number">{{.Calls}}</td><td class="number">{{tokens .InputTokens}}</td><td class="number">{{tokens .ResponseTokens}}</td><td class="number">{{tokens .TotalTokens}}</td><td class="number">{{cost .Cost .Currency}} {{.Currency}}</td><td>{{range $i, $model := .TopModels}}{{if $i}}, {{end}}{{$model.Model}}{{end}}</td></tr>
+{{- end}}
+{{- with .Total}}
+<tr><th>Total</th><th class="number">{{.Calls}}</th><th class="number">{{tokens .InputTokens}}</th><th class="number">{{tokens .ResponseTokens}}</th><th class="number">{{tokens .TotalTokens}}</th><th class="number">{{cost .Cost .Currency}} {{.Currency}}</th><th></th></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
@@ -0,0 +1,103 @@
+package tokentracker
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func statementUsage(day int, tenant, model string, tokens int, cost float64) UsageMetrics {
+	return UsageMetrics{
+		Timestamp:  time.Date(2024, 3, day, 12, 0, 0, 0, time.UTC),
+		Provider:   "openai",
+		Model:      model,
+		ProjectID:  tenant,
+		Tags:       map[string]string{"team": "search"},
+		TokenCount: TokenCount{InputTokens: tokens, TotalTokens: tokens},
+		Price:      Price{TotalCost: cost, Currency: "USD"},
+	}
+}
+
+func TestMonthlyStatement(t *testing.T) {
+	store := NewMemoryUsageStore()
+	tracker := NewTokenTracker(NewConfig(), WithUsageStore(store))
+	for _, record := range []UsageMetrics{
+		statementUsage(1, "acme", "gpt-4o", 1000, 1.0),
+		statementUsage(2, "acme", "gpt-4o-mini", 5000, 0.5),
+		statementUsage(3, "acme", "gpt-4o", 1000, 1.0),
+		statementUsage(4, "globex", "gpt-4o-mini", 100, 0.01),
+		statementUsage(5, "", "gpt-4o-mini", 100, 0.01),
+	} {
+		_ = store.Record(record)
+	}
+	april := statementUsage(1, "globex", "gpt-4o", 1000, 1.0)
+	april.Timestamp = time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	_ = store.Record(april)
+
+	statement, err := tracker.MonthlyStatement(2024, time.March, StatementOptions{TopModels: 1})
+	if err != nil {
+		t.Fatalf("MonthlyStatement() error = %v", err)
+	}
+	if !statement.End.Equal(time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)) || len(statement.Lines) != 3 {
+		t.Fatalf("statement = %+v, want the three accounts of March", statement)
+	}
+	acme := statement.Lines[0]
+	if acme.Account != "acme" || acme.Calls != 3 || acme.TotalTokens != 7000 || acme.Cost != 2.5 {
+		t.Errorf("first line = %+v, want acme's totals", acme)
+	}
+	if len(acme.TopModels) != 1 || acme.TopModels[0].Model != "gpt-4o" || acme.TopModels[0].Calls != 2 {
+		t.Errorf("TopModels = %+v, want the costliest model", acme.TopModels)
+	}
+	if statement.Total.Calls != 5 || statement.Total.Cost < 2.5199 || statement.Total.Cost > 2.5201 {
+		t.Errorf("Total = %+v", statement.Total)
+	}
+
+	byTeam, _ := tracker.MonthlyStatement(2024, time.March, StatementOptions{GroupBy: GroupByTag("team"), Filter: UsageFilter{ProjectID: "acme"}})
+	if len(byTeam.Lines) != 1 || byTeam.Lines[0].Account != "search" || byTeam.Lines[0].Calls != 3 {
+		t.Errorf("statement = %+v, want acme's usage allocated to its team", byTeam)
+	}
+
+	if _, err := tracker.MonthlyStatement(2024, time.March, StatementOptions{GroupBy: GroupByDay}); err == nil {
+		t.Error("MonthlyStatement() grouped by day should fail")
+	}
+}
+
+func TestWriteUsageStatement(t *testing.T) {
+	statement, _ := BuildUsageStatement([]UsageMetrics{
+		statementUsage(1, "acme", "gpt-4o", 1000, 1.0),
+		statementUsage(2, "<globex>", "gpt-4o", 10, 0.01),
+	}, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), StatementOptions{})
+
+	var buf bytes.Buffer
+	if err := WriteUsageStatement(&buf, StatementCSV, statement); err != nil {
+		t.Fatalf("WriteUsageStatement(csv) error = %v", err)
+	}
+	rows, _ := csv.NewReader(&buf).ReadAll()
+	if len(rows) != 4 || rows[1][2] != "acme" || rows[1][9] != "gpt-4o" || rows[3][2] != "total" || rows[3][3] != "2" {
+		t.Errorf("CSV = %v, want a row per account and the total", rows)
+	}
+
+	buf.Reset()
+	if err := WriteUsageStatement(&buf, StatementJSON, statement); err != nil {
+		t.Fatalf("WriteUsageStatement(json) error = %v", err)
+	}
+	var decoded UsageStatement
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || len(decoded.Lines) != 2 || decoded.GroupBy != GroupByProject {
+		t.Errorf("JSON = %s, error = %v", buf.String(), err)
+	}
+
+	buf.Reset()
+	if err := WriteUsageStatement(&buf, StatementHTML, statement); err != nil {
+		t.Fatalf("WriteUsageStatement(html) error = %v", err)
+	}
+	if html := buf.String(); !strings.Contains(html, "<td>acme</td>") || !strings.Contains(html, "&lt;globex&gt;") || !strings.Contains(html, "2024-03-01") {
+		t.Errorf("HTML = %s, want escaped accounts and the period", html)
+	}
+
+	if err := WriteUsageStatement(&buf, "pdf", statement); err == nil {
+		t.Error("WriteUsageStatement() with an unknown format should fail")
+	}
+}
@@ -0,0 +1,229 @@
+package tokentracker
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// UsageFilter selects usage records from a UsageStore.
+// Zero-valued fields do not restrict the result.
+type UsageFilter struct {
+	Start     time.Time // inclusive
+	End       time.Time // exclusive
+	Model     string
+	Provider  string
+	UserID    string
+	ProjectID string
+	APIKeyID  string
+	Tags      map[string]string // all tags must be present with the same value
+	Limit     int
+}
+
+// Matches reports whether the usage metrics satisfy the filter (ignoring Limit)
+func (f UsageFilter) Matches(metrics UsageMetrics) bool {
+	if !f.Start.IsZero() && metrics.Timestamp.Before(f.Start) {
+		return false
+	}
+	if !f.End.IsZero() && !metrics.Timestamp.Before(f.End) {
+		return false
+	}
+	if f.Model != "" && metrics.Model != f.Model {
+		return false
+	}
+	if f.Provider != "" && metrics.Provider != f.Provider {
+		return false
+	}
+	if f.UserID != "" && metrics.UserID != f.UserID {
+		return false
+	}
+	if f.ProjectID != "" && metrics.ProjectID != f.ProjectID {
+		return false
+	}
+	if f.APIKeyID != "" && metrics.APIKeyID != f.APIKeyID {
+		return false
+	}
+	for key, value := range f.Tags {
+		if tag, exists := metrics.Tags[key]; !exists || tag != value {
+			return false
+		}
+	}
+	return true
+}
+
+// copyTags returns a copy of tags so that callers reusing their map do not change tracked usage
+func copyTags(tags map[string]string) map[string]string {
+	if tags == nil {
+		return nil
+	}
+	copied := make(map[string]string, len(tags))
+	for key, value := range tags {
+		copied[key] = value
+	}
+	return copied
+}
+
+// UsageStore persists tracked usage and allows querying it later
+type UsageStore interface {
+	// Record stores the usage of a single tracked call
+	Record(metrics UsageMetrics) error
+
+	// Query returns the stored usage matching the filter, ordered by timestamp
+	Query(filter UsageFilter) ([]UsageMetrics, error)
+
+	// Close releases any resources held by the store
+	Close() error
+}
+
+// BatchUsageStore is implemented by usage stores that write many records at once,
+// e.g. in a single transaction. Imports write their records in one batch when the
+// store supports it.
+type BatchUsageStore interface {
+	// RecordBatch stores the usage of many calls, all or none of them
+	RecordBatch(records []UsageMetrics) error
+}
+
+// recordAll writes records into store, in one batch when it is a BatchUsageStore. It
+// returns the number of records written.
+func recordAll(store UsageStore, records []UsageMetrics) (int, error) {
+	if batch, ok := store.(BatchUsageStore); ok {
+		if err := batch.RecordBatch(records); err != nil {
+			return 0, NewError(ErrStorageFailed, "failed to store imported usage", err)
+		}
+		return len(records), nil
+	}
+
+	for i, record := range records {
+		if err := store.Record(record); err != nil {
+			return i, NewError(ErrStorageFailed, "failed to store imported usage", err)
+		}
+	}
+	return len(records), nil
+}
+
+// MemoryUsageStore is an in-memory UsageStore
+type MemoryUsageStore struct {
+	records []UsageMetrics
+	mu      sync.RWMutex
+}
+
+// NewMemoryUsageStore creates a new in-memory usage store
+func NewMemoryUsageStore() *MemoryUsageStore {
+	return &MemoryUsageStore{}
+}
+
+// Record stores the usage of a single tracked call
+func (s *MemoryUsageStore) Record(metrics UsageMetrics) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records = append(s.records, metrics)
+	return nil
+}
+
+// RecordBatch stores the usage of many calls
+func (s *MemoryUsageStore) RecordBatch(records []UsageMetrics) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records = append(s.records, records...)
+	return nil
+}
+
+// Query returns the stored usage matching the filter, ordered by timestamp
+func (s *MemoryUsageStore) Query(filter UsageFilter) ([]UsageMetrics, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return filterUsage(s.records, filter), nil
+}
+
+// Prune deletes the usage recorded before a time, returning the number of records deleted
+func (s *MemoryUsageStore) Prune(before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.records[:0]
+	for _, record := range s.records {
+		if !record.Timestamp.Before(before) {
+			kept = append(kept, record)
+		}
+	}
+	deleted := int64(len(s.records) - len(kept))
+	clear(s.records[len(kept):])
+	s.records = kept
+	return deleted, nil
+}
+
+// Close releases any resources held by the store
+func (s *MemoryUsageStore) Close() error {
+	return nil
+}
+
+// filterUsage returns the records matching the filter sorted by timestamp, honoring the limit
+func filterUsage(records []UsageMetrics, filter UsageFilter) []UsageMetrics {
+	result := make([]UsageMetrics, 0)
+	for _, record := range records {
+		if filter.Matches(record) {
+			result = append(result, record)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Timestamp.Before(result[j].Timestamp)
+	})
+
+	if filter.Limit > 0 && len(result) > filter.Limit {
+		result = result[:filter.Limit]
+	}
+
+	return result
+}
+
+// SetUsageStore sets the store that every tracked call is written into
+func (t *DefaultTokenTracker) SetUsageStore(store UsageStore) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.store = store
+}
+
+// UsageStore returns the store configured on the tracker, or nil
+func (t *DefaultTokenTracker) UsageStore() UsageStore {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.store
+}
+
+// GetUsage returns the tracked usage matching the filter
+func (t *DefaultTokenTracker) GetUsage(filter UsageFilter) ([]UsageMetrics, error) {
+	store := t.UsageStore()
+	if store == nil {
+		return nil, NewError(ErrStorageFailed, "no usage store configured", nil)
+	}
+
+	records, err := store.Query(filter)
+	if err != nil {
+		return nil, NewError(ErrStorageFailed, "failed to query usage store", err)
+	}
+
+	return records, nil
+}
+
+// flushStore flushes a usage store that buffers writes, i.e. one with a Flush(ctx)
+// or Flush() method
+func (t *DefaultTokenTracker) flushStore(ctx context.Context) error {
+	var err error
+	switch store := t.store.(type) {
+	case interface{ Flush(context.Context) error }:
+		err = store.Flush(ctx)
+	case interface{ Flush() error }:
+		err = store.Flush()
+	}
+	if err != nil {
+		return NewError(ErrStorageFailed, "failed to flush usage store", err)
+	}
+	return nil
+}
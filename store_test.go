@@ -0,0 +1,197 @@
+package tokentracker
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func sampleUsage(model, provider string, ts time.Time, cost float64) UsageMetrics {
+	return UsageMetrics{
+		TokenCount: TokenCount{InputTokens: 10, ResponseTokens: 5, TotalTokens: 15},
+		Price:      Price{TotalCost: cost, Currency: "USD"},
+		Duration:   250 * time.Millisecond,
+		Timestamp:  ts,
+		Model:      model,
+		Provider:   provider,
+	}
+}
+
+func TestUsageFilter_Matches(t *testing.T) {
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	metrics := sampleUsage("gpt-4", "openai", base, 1)
+	metrics.UserID = "user-1"
+	metrics.ProjectID = "proj-1"
+	metrics.Tags = map[string]string{"feature": "search", "env": "prod"}
+
+	tests := []struct {
+		name   string
+		filter UsageFilter
+		want   bool
+	}{
+		{"Empty filter", UsageFilter{}, true},
+		{"Matching model", UsageFilter{Model: "gpt-4"}, true},
+		{"Other model", UsageFilter{Model: "gpt-3.5-turbo"}, false},
+		{"Other provider", UsageFilter{Provider: "anthropic"}, false},
+		{"Start inclusive", UsageFilter{Start: base}, true},
+		{"End exclusive", UsageFilter{End: base}, false},
+		{"Inside range", UsageFilter{Start: base.Add(-time.Hour), End: base.Add(time.Hour)}, true},
+		{"Matching user and project", UsageFilter{UserID: "user-1", ProjectID: "proj-1"}, true},
+		{"Other user", UsageFilter{UserID: "user-2"}, false},
+		{"Other project", UsageFilter{ProjectID: "proj-2"}, false},
+		{"Matching tags", UsageFilter{Tags: map[string]string{"feature": "search"}}, true},
+		{"Other tag value", UsageFilter{Tags: map[string]string{"feature": "chat"}}, false},
+		{"Missing tag", UsageFilter{Tags: map[string]string{"team": ""}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Matches(metrics); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func testUsageStore(t *testing.T, store UsageStore) {
+	t.Helper()
+	base := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	records := []UsageMetrics{
+		sampleUsage("gpt-4", "openai", base.Add(2*time.Hour), 3),
+		sampleUsage("claude-3-haiku", "anthropic", base, 1),
+		sampleUsage("gpt-4", "openai", base.Add(time.Hour), 2),
+	}
+	for _, r := range records {
+		if err := store.Record(r); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+
+	all, err := store.Query(UsageFilter{})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("Query() returned %d records, want 3", len(all))
+	}
+	for i := 1; i < len(all); i++ {
+		if all[i].Timestamp.Before(all[i-1].Timestamp) {
+			t.Errorf("Query() results are not ordered by timestamp")
+		}
+	}
+
+	openai, _ := store.Query(UsageFilter{Provider: "openai", Limit: 1})
+	if len(openai) != 1 || openai[0].Price.TotalCost != 2 {
+		t.Errorf("Query(provider=openai, limit=1) = %+v, want the earliest openai record", openai)
+	}
+
+	ranged, _ := store.Query(UsageFilter{Start: base.Add(30 * time.Minute), End: base.Add(2 * time.Hour)})
+	if len(ranged) != 1 || ranged[0].Price.TotalCost != 2 {
+		t.Errorf("Query(time range) = %+v, want exactly the 01:00 record", ranged)
+	}
+}
+
+func TestMemoryUsageStore(t *testing.T) {
+	store := NewMemoryUsageStore()
+	defer store.Close()
+
+	testUsageStore(t, store)
+}
+
+func TestFileUsageStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+
+	store, err := NewFileUsageStore(path)
+	if err != nil {
+		t.Fatalf("NewFileUsageStore() error = %v", err)
+	}
+	testUsageStore(t, store)
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// Reopening the store loads the persisted history
+	reopened, err := NewFileUsageStore(path)
+	if err != nil {
+		t.Fatalf("NewFileUsageStore() reopen error = %v", err)
+	}
+	defer reopened.Close()
+
+	records, _ := reopened.Query(UsageFilter{Model: "gpt-4"})
+	if len(records) != 2 {
+		t.Fatalf("reopened Query() returned %d records, want 2", len(records))
+	}
+	if records[0].Duration != 250*time.Millisecond {
+		t.Errorf("Duration = %v, want 250ms", records[0].Duration)
+	}
+
+	if err := store.Record(UsageMetrics{}); err == nil {
+		t.Error("Record() on closed store should fail")
+	}
+}
+
+func TestFileUsageStore_RecordBatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	store, err := NewFileUsageStore(path)
+	if err != nil {
+		t.Fatalf("NewFileUsageStore() error = %v", err)
+	}
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	batch := []UsageMetrics{
+		sampleUsage("gpt-4", "openai", base, 1),
+		sampleUsage("gpt-4", "openai", base.Add(time.Hour), 2),
+	}
+	if err := store.RecordBatch(batch); err != nil {
+		t.Fatalf("RecordBatch() error = %v", err)
+	}
+	store.Close()
+
+	reopened, err := NewFileUsageStore(path)
+	if err != nil {
+		t.Fatalf("NewFileUsageStore() reopen error = %v", err)
+	}
+	defer reopened.Close()
+	if records, _ := reopened.Query(UsageFilter{}); len(records) != 2 {
+		t.Errorf("reopened Query() returned %d records, want 2", len(records))
+	}
+	if err := store.RecordBatch(batch); err == nil {
+		t.Error("RecordBatch() on closed store should fail")
+	}
+}
+
+func TestDefaultTokenTracker_UsageStore(t *testing.T) {
+	store := NewMemoryUsageStore()
+	tracker := NewTokenTracker(NewConfig(), WithUsageStore(store))
+	tracker.RegisterProvider(&MockProvider{
+		name:           "mock",
+		supportedModel: "mock-model",
+		tokenCount:     TokenCount{InputTokens: 100, ResponseTokens: 50, TotalTokens: 150},
+		price:          Price{TotalCost: 0.0003, Currency: "USD"},
+	})
+
+	callParams := CallParams{
+		Model:     "mock-model",
+		Params:    TokenCountParams{Model: "mock-model", Text: stringPtr("Test text")},
+		StartTime: time.Now(),
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := tracker.TrackUsage(callParams, "response"); err != nil {
+			t.Fatalf("TrackUsage() error = %v", err)
+		}
+	}
+
+	usage, err := tracker.GetUsage(UsageFilter{Model: "mock-model"})
+	if err != nil {
+		t.Fatalf("GetUsage() error = %v", err)
+	}
+	if len(usage) != 2 {
+		t.Errorf("GetUsage() returned %d records, want 2", len(usage))
+	}
+
+	// Without a store GetUsage reports an error
+	if _, err := NewTokenTracker(NewConfig()).GetUsage(UsageFilter{}); err == nil {
+		t.Error("GetUsage() without a store should fail")
+	}
+}
@@ -0,0 +1,105 @@
+package tokentracker
+
+import (
+	"context"
+	"sync"
+)
+
+// StreamTracker accumulates the usage reported while a response is streamed and
+// tracks the call once, when the stream closes. Provider packages wrap it with
+// the chunk formats of their streaming APIs.
+type StreamTracker struct {
+	tracker    *DefaultTokenTracker
+	ctx        context.Context
+	callParams CallParams
+
+	mu           sync.Mutex
+	model        string
+	inputTokens  int
+	outputTokens int
+	reported     bool
+	closed       bool
+	metrics      UsageMetrics
+	err          error
+}
+
+// NewStreamTracker creates a stream tracker for a call
+func NewStreamTracker(ctx context.Context, tracker *DefaultTokenTracker, callParams CallParams) *StreamTracker {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return &StreamTracker{
+		tracker:    tracker,
+		ctx:        ctx,
+		callParams: callParams,
+	}
+}
+
+// SetModel records the model reported by the stream, used when the call has none
+func (s *StreamTracker) SetModel(model string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if model != "" {
+		s.model = model
+	}
+}
+
+// ReportInputTokens records the input tokens reported by the stream
+func (s *StreamTracker) ReportInputTokens(tokens int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.inputTokens = tokens
+	s.reported = true
+}
+
+// ReportOutputTokens records the output tokens reported by the stream. Providers
+// report cumulative counts, so the latest report replaces earlier ones.
+func (s *StreamTracker) ReportOutputTokens(tokens int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.outputTokens = tokens
+	s.reported = true
+}
+
+// Usage returns the token counts reported so far
+func (s *StreamTracker) Usage() TokenCount {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return TokenCount{
+		InputTokens:    s.inputTokens,
+		ResponseTokens: s.outputTokens,
+		TotalTokens:    s.inputTokens + s.outputTokens,
+	}
+}
+
+// Close tracks the accumulated usage. It is safe to call more than once; later
+// calls return the result of the first.
+func (s *StreamTracker) Close() (UsageMetrics, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return s.metrics, s.err
+	}
+	s.closed = true
+
+	if !s.reported {
+		s.err = NewError(ErrInvalidParams, "stream reported no usage", nil)
+		return s.metrics, s.err
+	}
+
+	callParams := s.callParams
+	if callParams.Model == "" {
+		callParams.Model = s.model
+	}
+	s.metrics, s.err = s.tracker.TrackReportedUsage(s.ctx, callParams, TokenCount{
+		InputTokens:    s.inputTokens,
+		ResponseTokens: s.outputTokens,
+		TotalTokens:    s.inputTokens + s.outputTokens,
+	})
+	return s.metrics, s.err
+}
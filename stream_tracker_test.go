@@ -0,0 +1,48 @@
+package tokentracker
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStreamTracker(t *testing.T) {
+	store := NewMemoryUsageStore()
+	tracker := NewTokenTracker(NewConfig(), WithUsageStore(store))
+	tracker.RegisterProvider(&MockProvider{
+		name:           "mock",
+		supportedModel: "mock-model",
+		price:          Price{TotalCost: 0.01, Currency: "USD"},
+	})
+
+	stream := NewStreamTracker(context.Background(), tracker, CallParams{StartTime: time.Now(), Tags: map[string]string{"team": "search"}})
+	stream.SetModel("mock-model")
+	stream.ReportInputTokens(10)
+	stream.ReportOutputTokens(3)
+	stream.ReportOutputTokens(7)
+
+	if usage := stream.Usage(); usage.TotalTokens != 17 {
+		t.Errorf("Usage() = %+v, want the latest cumulative output count", usage)
+	}
+
+	metrics, err := stream.Close()
+	if err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if metrics.Model != "mock-model" || metrics.TokenCount.ResponseTokens != 7 || metrics.Tags["team"] != "search" {
+		t.Errorf("metrics = %+v", metrics)
+	}
+
+	again, err := stream.Close()
+	if err != nil || again.TokenCount != metrics.TokenCount {
+		t.Errorf("second Close() = %+v, %v; want the first result", again, err)
+	}
+	if records, _ := store.Query(UsageFilter{}); len(records) != 1 {
+		t.Errorf("tracked %d calls, want 1", len(records))
+	}
+
+	empty := NewStreamTracker(nil, tracker, CallParams{Model: "mock-model"})
+	if _, err := empty.Close(); err == nil {
+		t.Error("Close() without reported usage should fail")
+	}
+}
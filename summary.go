@@ -0,0 +1,242 @@
+package tokentracker
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// GroupBy selects a dimension used to aggregate usage
+type GroupBy string
+
+// Aggregation dimensions
+const (
+	GroupByProvider GroupBy = "provider"
+	GroupByModel    GroupBy = "model"
+	GroupByDay      GroupBy = "day"
+	GroupByHour     GroupBy = "hour"
+	GroupByUser     GroupBy = "user"
+	GroupByProject  GroupBy = "project"
+	GroupByAPIKey   GroupBy = "api_key"
+)
+
+// groupByTagPrefix prefixes tag dimensions, e.g. "tag:feature"
+const groupByTagPrefix = "tag:"
+
+// GroupByTag aggregates usage by the value of a tag
+func GroupByTag(key string) GroupBy {
+	return GroupBy(groupByTagPrefix + key)
+}
+
+// TagKey returns the tag key of a tag dimension
+func (g GroupBy) TagKey() (string, bool) {
+	key, ok := strings.CutPrefix(string(g), groupByTagPrefix)
+	return key, ok && key != ""
+}
+
+// UsageSummary contains aggregated usage for one group.
+// Dimensions that were not grouped on are left empty.
+type UsageSummary struct {
+	Provider       string            `json:"provider,omitempty"`
+	Model          string            `json:"model,omitempty"`
+	UserID         string            `json:"user_id,omitempty"`
+	ProjectID      string            `json:"project_id,omitempty"`
+	APIKeyID       string            `json:"api_key_id,omitempty"`
+	Tags           map[string]string `json:"tags,omitempty"`   // values of the grouped tags
+	Bucket         time.Time         `json:"bucket,omitempty"` // start of the day/hour bucket (UTC)
+	Calls          int               `json:"calls"`
+	InputTokens    int               `json:"input_tokens"`
+	ResponseTokens int               `json:"response_tokens"`
+	TotalTokens    int               `json:"total_tokens"`
+	TotalCost      float64           `json:"total_cost"`
+	Currency       string            `json:"currency"`
+	TotalDuration  time.Duration     `json:"total_duration"`
+	AvgDuration    time.Duration     `json:"avg_duration"`
+}
+
+// summaryKey identifies a group while aggregating
+type summaryKey struct {
+	provider string
+	model    string
+	user     string
+	project  string
+	apiKey   string
+	tags     string
+	bucket   time.Time
+}
+
+// SummarizeUsage aggregates usage records into totals grouped by the given dimensions.
+// Without dimensions a single overall summary is returned (if there are records).
+func SummarizeUsage(records []UsageMetrics, groupBy ...GroupBy) []UsageSummary {
+	groups := make(map[summaryKey]*UsageSummary)
+	var order []summaryKey
+
+	for _, record := range records {
+		key := summaryKeyFor(record, groupBy)
+
+		summary, exists := groups[key]
+		if !exists {
+			summary = &UsageSummary{
+				Provider:  key.provider,
+				Model:     key.model,
+				UserID:    key.user,
+				ProjectID: key.project,
+				APIKeyID:  key.apiKey,
+				Tags:      groupedTags(record, groupBy),
+				Bucket:    key.bucket,
+				Currency:  record.Price.Currency,
+			}
+			groups[key] = summary
+			order = append(order, key)
+		}
+
+		// Sampled records stand for several calls
+		weight := record.SampleWeight()
+		summary.Calls += weight
+		summary.InputTokens += weight * record.TokenCount.InputTokens
+		summary.ResponseTokens += weight * record.TokenCount.ResponseTokens
+		summary.TotalTokens += weight * record.TokenCount.TotalTokens
+		summary.TotalCost += float64(weight) * record.Price.TotalCost
+		summary.TotalDuration += time.Duration(weight) * record.Duration
+	}
+
+	summaries := make([]UsageSummary, 0, len(order))
+	for _, key := range order {
+		summary := groups[key]
+		if summary.Calls > 0 {
+			summary.AvgDuration = summary.TotalDuration / time.Duration(summary.Calls)
+		}
+		summaries = append(summaries, *summary)
+	}
+
+	sort.SliceStable(summaries, func(i, j int) bool {
+		a, b := summaries[i], summaries[j]
+		if !a.Bucket.Equal(b.Bucket) {
+			return a.Bucket.Before(b.Bucket)
+		}
+		if a.Provider != b.Provider {
+			return a.Provider < b.Provider
+		}
+		if a.Model != b.Model {
+			return a.Model < b.Model
+		}
+		if a.ProjectID != b.ProjectID {
+			return a.ProjectID < b.ProjectID
+		}
+		if a.UserID != b.UserID {
+			return a.UserID < b.UserID
+		}
+		if a.APIKeyID != b.APIKeyID {
+			return a.APIKeyID < b.APIKeyID
+		}
+		return encodeTags(a.Tags) < encodeTags(b.Tags)
+	})
+
+	return summaries
+}
+
+// summaryKeyFor builds the group key of a record for the requested dimensions
+func summaryKeyFor(record UsageMetrics, groupBy []GroupBy) summaryKey {
+	var key summaryKey
+	for _, dimension := range groupBy {
+		switch dimension {
+		case GroupByProvider:
+			key.provider = record.Provider
+		case GroupByModel:
+			key.model = record.Model
+		case GroupByDay:
+			key.bucket = record.Timestamp.UTC().Truncate(24 * time.Hour)
+		case GroupByHour:
+			key.bucket = record.Timestamp.UTC().Truncate(time.Hour)
+		case GroupByUser:
+			key.user = record.UserID
+		case GroupByProject:
+			key.project = record.ProjectID
+		case GroupByAPIKey:
+			key.apiKey = record.APIKeyID
+		}
+	}
+	key.tags = encodeTags(groupedTags(record, groupBy))
+	return key
+}
+
+// groupedTags returns the record's values of the grouped tags; missing tags are empty
+func groupedTags(record UsageMetrics, groupBy []GroupBy) map[string]string {
+	var tags map[string]string
+	for _, dimension := range groupBy {
+		if key, ok := dimension.TagKey(); ok {
+			if tags == nil {
+				tags = make(map[string]string)
+			}
+			tags[key] = record.Tags[key]
+		}
+	}
+	return tags
+}
+
+// encodeTags encodes tags in a stable, comparable form
+func encodeTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(tags[key])
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+// ParseGroupBy parses a comma separated list of dimensions (e.g. "provider,model,day,tag:feature")
+func ParseGroupBy(value string) ([]GroupBy, error) {
+	var dimensions []GroupBy
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if key, ok := strings.CutPrefix(part, groupByTagPrefix); ok && key != "" {
+			// Tag keys are case sensitive
+			dimensions = append(dimensions, GroupByTag(key))
+			continue
+		}
+		switch GroupBy(strings.ToLower(part)) {
+		case GroupByProvider, GroupByModel, GroupByDay, GroupByHour, GroupByUser, GroupByProject, GroupByAPIKey:
+			dimensions = append(dimensions, GroupBy(strings.ToLower(part)))
+		default:
+			return nil, NewError(ErrInvalidParams, "unknown group by dimension: "+part, nil)
+		}
+	}
+	return dimensions, nil
+}
+
+// Summary aggregates the tracked usage matching the filter (typically a time period)
+// into totals grouped by the given dimensions
+func (t *DefaultTokenTracker) Summary(filter UsageFilter, groupBy ...GroupBy) ([]UsageSummary, error) {
+	// The limit applies to the summaries, not to the underlying records
+	limit := filter.Limit
+	filter.Limit = 0
+
+	records, err := t.GetUsage(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	// Report records tracked in other currencies in the default currency
+	records, err = t.config.convertUsage(records)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := SummarizeUsage(records, groupBy...)
+	if limit > 0 && len(summaries) > limit {
+		summaries = summaries[:limit]
+	}
+
+	return summaries, nil
+}
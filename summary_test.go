@@ -0,0 +1,146 @@
+package tokentracker
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSummarizeUsage(t *testing.T) {
+	day1 := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	day2 := time.Date(2024, 3, 2, 14, 0, 0, 0, time.UTC)
+
+	records := []UsageMetrics{
+		sampleUsage("gpt-4", "openai", day1, 1),
+		sampleUsage("gpt-4", "openai", day1.Add(time.Hour), 2),
+		sampleUsage("gpt-3.5-turbo", "openai", day2, 0.5),
+		sampleUsage("claude-3-haiku", "anthropic", day2, 0.25),
+	}
+	records[1].Duration = 750 * time.Millisecond
+
+	t.Run("Overall", func(t *testing.T) {
+		summaries := SummarizeUsage(records)
+		if len(summaries) != 1 {
+			t.Fatalf("len = %d, want 1", len(summaries))
+		}
+		s := summaries[0]
+		if s.Calls != 4 || s.TotalTokens != 60 || math.Abs(s.TotalCost-3.75) > 1e-9 {
+			t.Errorf("unexpected overall summary: %+v", s)
+		}
+	})
+
+	t.Run("By provider and model", func(t *testing.T) {
+		summaries := SummarizeUsage(records, GroupByProvider, GroupByModel)
+		if len(summaries) != 3 {
+			t.Fatalf("len = %d, want 3", len(summaries))
+		}
+		if summaries[0].Provider != "anthropic" {
+			t.Errorf("first summary provider = %q, want anthropic", summaries[0].Provider)
+		}
+		gpt4 := summaries[2]
+		if gpt4.Model != "gpt-4" || gpt4.Calls != 2 || gpt4.TotalCost != 3 {
+			t.Errorf("unexpected gpt-4 summary: %+v", gpt4)
+		}
+		if gpt4.AvgDuration != 500*time.Millisecond {
+			t.Errorf("AvgDuration = %v, want 500ms", gpt4.AvgDuration)
+		}
+	})
+
+	t.Run("By day", func(t *testing.T) {
+		summaries := SummarizeUsage(records, GroupByDay)
+		if len(summaries) != 2 {
+			t.Fatalf("len = %d, want 2", len(summaries))
+		}
+		if !summaries[0].Bucket.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("first bucket = %v, want 2024-03-01", summaries[0].Bucket)
+		}
+		if summaries[1].Calls != 2 {
+			t.Errorf("second day calls = %d, want 2", summaries[1].Calls)
+		}
+	})
+
+	t.Run("By project, user and tag", func(t *testing.T) {
+		tagged := append([]UsageMetrics(nil), records...)
+		for i := range tagged {
+			tagged[i].ProjectID = "proj-1"
+			tagged[i].UserID = "user-1"
+			tagged[i].Tags = map[string]string{"feature": "search"}
+		}
+		tagged[2].UserID = "user-2"
+		tagged[3].Tags = map[string]string{"feature": "chat"}
+
+		summaries := SummarizeUsage(tagged, GroupByProject, GroupByUser, GroupByTag("feature"))
+		if len(summaries) != 3 {
+			t.Fatalf("len = %d, want 3", len(summaries))
+		}
+		first := summaries[0]
+		if first.ProjectID != "proj-1" || first.UserID != "user-1" || first.Tags["feature"] != "chat" || first.Calls != 1 {
+			t.Errorf("first summary = %+v, want user-1's chat call", first)
+		}
+		if second := summaries[1]; second.Tags["feature"] != "search" || second.Calls != 2 || second.TotalCost != 3 {
+			t.Errorf("second summary = %+v, want user-1's two search calls", second)
+		}
+		if third := summaries[2]; third.UserID != "user-2" {
+			t.Errorf("third summary = %+v, want user-2", third)
+		}
+
+		if overall := SummarizeUsage(tagged); overall[0].Tags != nil || overall[0].UserID != "" {
+			t.Errorf("overall summary = %+v, want no grouped dimensions", overall[0])
+		}
+	})
+
+	t.Run("By hour", func(t *testing.T) {
+		summaries := SummarizeUsage(records, GroupByHour, GroupByProvider)
+		if len(summaries) != 4 {
+			t.Errorf("len = %d, want 4", len(summaries))
+		}
+	})
+}
+
+func TestParseGroupBy(t *testing.T) {
+	got, err := ParseGroupBy("provider, Model,day")
+	if err != nil {
+		t.Fatalf("ParseGroupBy() error = %v", err)
+	}
+	want := []GroupBy{GroupByProvider, GroupByModel, GroupByDay}
+	if len(got) != len(want) {
+		t.Fatalf("ParseGroupBy() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("ParseGroupBy()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	got, err = ParseGroupBy("project,user,tag:Feature")
+	if err != nil {
+		t.Fatalf("ParseGroupBy() error = %v", err)
+	}
+	if len(got) != 3 || got[0] != GroupByProject || got[1] != GroupByUser || got[2] != GroupByTag("Feature") {
+		t.Errorf("ParseGroupBy() = %v, want project, user and the case-preserved tag", got)
+	}
+
+	if _, err := ParseGroupBy("tag:"); err == nil {
+		t.Error("ParseGroupBy() with an empty tag key should fail")
+	}
+	if _, err := ParseGroupBy("tenant"); err == nil {
+		t.Error("ParseGroupBy() with unknown dimension should fail")
+	}
+}
+
+func TestDefaultTokenTracker_Summary(t *testing.T) {
+	store := NewMemoryUsageStore()
+	base := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	_ = store.Record(sampleUsage("gpt-4", "openai", base, 1))
+	_ = store.Record(sampleUsage("gpt-4", "openai", base.Add(48*time.Hour), 2))
+
+	tracker := NewTokenTracker(NewConfig(), WithUsageStore(store))
+
+	summaries, err := tracker.Summary(UsageFilter{Start: base, End: base.Add(24 * time.Hour)}, GroupByModel)
+	if err != nil {
+		t.Fatalf("Summary() error = %v", err)
+	}
+	if len(summaries) != 1 || summaries[0].TotalCost != 1 {
+		t.Errorf("Summary() = %+v, want only the first day's usage", summaries)
+	}
+}
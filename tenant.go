@@ -0,0 +1,215 @@
+package tokentracker
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Tenant is a customer whose usage is attributed and limited separately. Calls are
+// attributed to the tenant whose ID is the call's ProjectID.
+type Tenant struct {
+	ID   string
+	Name string
+
+	Window   BudgetWindow   // quota period; the total window never resets
+	Location *time.Location // time zone of daily/monthly windows (nil means UTC)
+
+	CostLimit  float64 // 0 means no cost quota
+	TokenLimit int     // 0 means no token quota
+}
+
+// TenantUsage is the usage of a tenant in its current quota window
+type TenantUsage struct {
+	Tenant          Tenant
+	WindowStart     time.Time // zero for tenants without a window
+	SpentCost       float64
+	UsedTokens      int
+	RemainingCost   float64 // 0 when the tenant has no cost quota
+	RemainingTokens int     // 0 when the tenant has no token quota
+	Exceeded        bool
+}
+
+// TenantManager registers tenants and enforces their token and cost quotas on
+// tracked usage. Usage of unregistered tenants is not limited. It is safe for
+// concurrent use.
+type TenantManager struct {
+	tenants map[string]*tenantState
+	clock   Clock
+	mu      sync.Mutex
+}
+
+// tenantState tracks the quota usage of one tenant
+type tenantState struct {
+	tenant Tenant
+	quota  budgetState
+}
+
+// NewTenantManager creates a tenant manager using the given clock for quota windows (nil uses the system clock)
+func NewTenantManager(clock Clock) *TenantManager {
+	if clock == nil {
+		clock = SystemClock
+	}
+	return &TenantManager{
+		tenants: make(map[string]*tenantState),
+		clock:   clock,
+	}
+}
+
+// Register adds or replaces a tenant. Replacing a tenant keeps its current usage.
+func (m *TenantManager) Register(tenant Tenant) error {
+	if tenant.ID == "" {
+		return NewError(ErrInvalidParams, "tenant ID is required", nil)
+	}
+	if tenant.CostLimit < 0 || tenant.TokenLimit < 0 {
+		return NewError(ErrInvalidParams, fmt.Sprintf("tenant %s has a negative quota", tenant.ID), nil)
+	}
+	switch tenant.Window {
+	case BudgetWindowTotal, BudgetWindowDaily, BudgetWindowMonthly:
+	default:
+		return NewError(ErrInvalidParams, fmt.Sprintf("unknown quota window: %s", tenant.Window), nil)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, exists := m.tenants[tenant.ID]
+	if !exists {
+		state = &tenantState{}
+		m.tenants[tenant.ID] = state
+	}
+	state.tenant = tenant
+	state.quota.budget = Budget{
+		Name:       tenant.ID,
+		Window:     tenant.Window,
+		Location:   tenant.Location,
+		CostLimit:  tenant.CostLimit,
+		TokenLimit: tenant.TokenLimit,
+		Hard:       true,
+	}
+	state.quota.roll(m.clock.Now())
+	return nil
+}
+
+// Remove removes a tenant
+func (m *TenantManager) Remove(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.tenants, id)
+}
+
+// Tenant returns a registered tenant
+func (m *TenantManager) Tenant(id string) (Tenant, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, exists := m.tenants[id]
+	if !exists {
+		return Tenant{}, false
+	}
+	return state.tenant, true
+}
+
+// Tenants returns the registered tenants, ordered by ID
+func (m *TenantManager) Tenants() []Tenant {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tenants := make([]Tenant, 0, len(m.tenants))
+	for _, state := range m.tenants {
+		tenants = append(tenants, state.tenant)
+	}
+	sort.Slice(tenants, func(i, j int) bool {
+		return tenants[i].ID < tenants[j].ID
+	})
+	return tenants
+}
+
+// Usage returns the usage of a tenant in its current quota window
+func (m *TenantManager) Usage(id string) (TenantUsage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, exists := m.tenants[id]
+	if !exists {
+		return TenantUsage{}, NewError(ErrInvalidParams, fmt.Sprintf("unknown tenant: %s", id), nil)
+	}
+	state.quota.roll(m.clock.Now())
+	return state.usage(), nil
+}
+
+// Check returns an ErrQuotaExceeded error when the tenant's quota is already
+// exhausted, so callers can reject a request before making the call
+func (m *TenantManager) Check(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, exists := m.tenants[id]
+	if !exists {
+		return nil
+	}
+	state.quota.roll(m.clock.Now())
+	if state.quota.exhausted() {
+		return NewError(ErrQuotaExceeded, fmt.Sprintf("quota of tenant %s is exhausted", id), nil)
+	}
+	return nil
+}
+
+// Record charges tracked usage to the tenant of the call. An ErrQuotaExceeded error
+// is returned when the usage exceeds the tenant's quota; it is recorded either way.
+func (m *TenantManager) Record(metrics UsageMetrics) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, exists := m.tenants[metrics.ProjectID]
+	if !exists {
+		return nil
+	}
+
+	state.quota.roll(m.clock.Now())
+	state.quota.cost += metrics.Price.TotalCost
+	state.quota.tokens += metrics.TokenCount.TotalTokens
+
+	if state.usage().Exceeded {
+		return NewError(ErrQuotaExceeded, fmt.Sprintf("quota of tenant %s exceeded", metrics.ProjectID), nil)
+	}
+	return nil
+}
+
+// usage returns the tenant usage; the caller must hold the manager's lock
+func (s *tenantState) usage() TenantUsage {
+	status := s.quota.status()
+	return TenantUsage{
+		Tenant:          s.tenant,
+		WindowStart:     status.WindowStart,
+		SpentCost:       status.SpentCost,
+		UsedTokens:      status.UsedTokens,
+		RemainingCost:   status.RemainingCost,
+		RemainingTokens: status.RemainingTokens,
+		Exceeded:        status.Exceeded,
+	}
+}
+
+// WithTenantManager makes the tracker charge every tracked call to its tenant's quota
+func WithTenantManager(manager *TenantManager) TrackerOption {
+	return func(t *DefaultTokenTracker) {
+		t.tenants = manager
+	}
+}
+
+// Tenants returns the tracker's tenant manager, or nil when none is configured
+func (t *DefaultTokenTracker) Tenants() *TenantManager {
+	return t.tenants
+}
+
+// TenantSpend aggregates the stored usage of a tenant matching the filter, e.g. for
+// a billing period, grouped by the given dimensions
+func (t *DefaultTokenTracker) TenantSpend(id string, filter UsageFilter, groupBy ...GroupBy) ([]UsageSummary, error) {
+	if id == "" {
+		return nil, NewError(ErrInvalidParams, "tenant ID is required", nil)
+	}
+	filter.ProjectID = id
+	return t.Summary(filter, groupBy...)
+}
@@ -0,0 +1,121 @@
+package tokentracker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker/tokentrackertest"
+)
+
+func TestTenantManager(t *testing.T) {
+	clock := tokentrackertest.NewFakeClock(time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC))
+	manager := NewTenantManager(clock)
+
+	if err := manager.Register(Tenant{ID: "acme", Name: "Acme", Window: BudgetWindowMonthly, CostLimit: 1, TokenLimit: 100}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	_ = manager.Register(Tenant{ID: "globex"})
+
+	if got := manager.Tenants(); len(got) != 2 || got[0].ID != "acme" || got[1].ID != "globex" {
+		t.Errorf("Tenants() = %+v, want acme and globex", got)
+	}
+	if tenant, exists := manager.Tenant("acme"); !exists || tenant.Name != "Acme" {
+		t.Errorf("Tenant(acme) = %+v, %v", tenant, exists)
+	}
+
+	usage := func(tenant string, cost float64, tokens int) UsageMetrics {
+		metrics := budgetUsage("openai", "gpt-4", cost, tokens, nil)
+		metrics.ProjectID = tenant
+		return metrics
+	}
+
+	if err := manager.Record(usage("acme", 0.5, 60)); err != nil {
+		t.Fatalf("Record() within quota error = %v", err)
+	}
+	err := manager.Record(usage("acme", 0.25, 60))
+	var trackerErr *TokenTrackerError
+	if !errors.As(err, &trackerErr) || trackerErr.Type != ErrQuotaExceeded {
+		t.Fatalf("Record() over the token quota error = %v, want %s", err, ErrQuotaExceeded)
+	}
+	if err := manager.Check("acme"); err == nil {
+		t.Error("Check() of an exhausted tenant should fail")
+	}
+
+	status, _ := manager.Usage("acme")
+	if status.SpentCost != 0.75 || status.UsedTokens != 120 || status.RemainingCost != 0.25 || !status.Exceeded {
+		t.Errorf("Usage(acme) = %+v", status)
+	}
+
+	// Tenants without quotas and unregistered tenants are not limited
+	if err := manager.Record(usage("globex", 100, 1e6)); err != nil {
+		t.Errorf("Record() for a tenant without quota error = %v", err)
+	}
+	if err := manager.Record(usage("initech", 100, 1e6)); err != nil || manager.Check("initech") != nil {
+		t.Errorf("unregistered tenants should not be limited, got %v", err)
+	}
+
+	// The quota resets with the window
+	clock.Advance(24 * time.Hour)
+	if err := manager.Check("acme"); err != nil {
+		t.Errorf("Check() in a new month error = %v", err)
+	}
+
+	if err := manager.Register(Tenant{}); err == nil {
+		t.Error("Register() without ID should fail")
+	}
+	if err := manager.Register(Tenant{ID: "bad", CostLimit: -1}); err == nil {
+		t.Error("Register() with a negative quota should fail")
+	}
+	manager.Remove("globex")
+	if _, err := manager.Usage("globex"); err == nil {
+		t.Error("Usage() of a removed tenant should fail")
+	}
+}
+
+func TestDefaultTokenTracker_Tenants(t *testing.T) {
+	manager := NewTenantManager(nil)
+	_ = manager.Register(Tenant{ID: "acme", CostLimit: 0.015})
+
+	store := NewMemoryUsageStore()
+	tracker := NewTokenTracker(NewConfig(), WithTenantManager(manager), WithUsageStore(store))
+	tracker.RegisterProvider(&MockProvider{
+		name:           "mock",
+		supportedModel: "mock-model",
+		tokenCount:     TokenCount{InputTokens: 10, TotalTokens: 10},
+		price:          Price{TotalCost: 0.01, Currency: "USD"},
+	})
+
+	track := func(tenant string) (UsageMetrics, error) {
+		return tracker.TrackUsage(CallParams{
+			Model:     "mock-model",
+			Params:    TokenCountParams{Model: "mock-model", Text: stringPtr("Hello")},
+			StartTime: time.Now(),
+			ProjectID: tenant,
+		}, "response")
+	}
+
+	if _, err := track("acme"); err != nil {
+		t.Fatalf("first TrackUsage() error = %v", err)
+	}
+	if _, err := track("acme"); err == nil {
+		t.Fatal("TrackUsage() over the tenant's quota should fail")
+	}
+	if _, err := track("globex"); err != nil {
+		t.Fatalf("TrackUsage() of another tenant error = %v", err)
+	}
+	if err := tracker.Tenants().Check("acme"); err == nil {
+		t.Error("Check() after exceeding the quota should fail")
+	}
+
+	spend, err := tracker.TenantSpend("acme", UsageFilter{})
+	if err != nil {
+		t.Fatalf("TenantSpend() error = %v", err)
+	}
+	if len(spend) != 1 || spend[0].Calls != 2 || spend[0].TotalCost != 0.02 {
+		t.Errorf("TenantSpend() = %+v, want both of acme's calls", spend)
+	}
+	if _, err := tracker.TenantSpend("", UsageFilter{}); err == nil {
+		t.Error("TenantSpend() without tenant should fail")
+	}
+}
@@ -0,0 +1,240 @@
+package tokentracker
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TextTokenizer is implemented by providers that tokenize text locally, e.g. with a
+// BPE encoding. Truncation and splitting then cut text exactly at token boundaries;
+// for other providers the cuts are found by counting candidate prefixes.
+type TextTokenizer interface {
+	// TokenizeText returns the pieces of text making up its tokens, in order.
+	// Concatenated, they are the text.
+	TokenizeText(model, text string) ([]string, error)
+}
+
+// TruncateToTokens returns the longest prefix of text that the model's provider counts
+// as at most maxTokens tokens. Without a local tokenizer the prefix ends at a word
+// boundary where possible.
+func (t *DefaultTokenTracker) TruncateToTokens(model, text string, maxTokens int) (string, error) {
+	return t.TruncateToTokensCtx(context.Background(), model, text, maxTokens)
+}
+
+// TruncateToTokensCtx truncates text, propagating cancellation and deadlines to the provider
+func (t *DefaultTokenTracker) TruncateToTokensCtx(ctx context.Context, model, text string, maxTokens int) (string, error) {
+	if maxTokens < 0 {
+		return "", NewError(ErrInvalidParams, fmt.Sprintf("max tokens must not be negative, got %d", maxTokens), nil)
+	}
+
+	splitter, err := t.newTokenSplitter(ctx, model, text)
+	if err != nil || text == "" {
+		return "", err
+	}
+	end, err := splitter.longestFit(0, maxTokens)
+	if err != nil {
+		return "", err
+	}
+	return text[:end], nil
+}
+
+// SplitByTokens splits text into chunks of at most chunkSize tokens, as counted by the
+// model's provider. Consecutive chunks share about overlap tokens, so context is not
+// lost at the cuts. A single character longer than chunkSize becomes its own chunk.
+func (t *DefaultTokenTracker) SplitByTokens(model, text string, chunkSize, overlap int) ([]string, error) {
+	return t.SplitByTokensCtx(context.Background(), model, text, chunkSize, overlap)
+}
+
+// SplitByTokensCtx splits text, propagating cancellation and deadlines to the provider
+func (t *DefaultTokenTracker) SplitByTokensCtx(ctx context.Context, model, text string, chunkSize, overlap int) ([]string, error) {
+	if chunkSize <= 0 {
+		return nil, NewError(ErrInvalidParams, fmt.Sprintf("chunk size must be positive, got %d", chunkSize), nil)
+	}
+	if overlap < 0 || overlap >= chunkSize {
+		return nil, NewError(ErrInvalidParams, fmt.Sprintf("overlap must be between 0 and the chunk size, got %d", overlap), nil)
+	}
+
+	splitter, err := t.newTokenSplitter(ctx, model, text)
+	if err != nil || text == "" {
+		return nil, err
+	}
+
+	var chunks []string
+	for start := 0; ; {
+		end, err := splitter.longestFit(start, chunkSize)
+		if err != nil {
+			return nil, err
+		}
+		if end == start {
+			// Nothing fits; take one cut so the split makes progress
+			end = splitter.nextCut(start)
+		}
+		chunks = append(chunks, text[start:end])
+		if end == len(text) {
+			return chunks, nil
+		}
+
+		next, err := splitter.overlapStart(start, end, overlap)
+		if err != nil {
+			return nil, err
+		}
+		start = next
+	}
+}
+
+// tokenSplitter finds where to cut a text by token counts
+type tokenSplitter struct {
+	text string
+
+	// cuts are the byte offsets text may be cut at, ascending and ending at len(text)
+	cuts []int
+
+	// count returns the number of tokens of text[start:end]
+	count func(start, end int) (int, error)
+}
+
+// newTokenSplitter creates a splitter using the local tokenizer of the model's
+// provider, or its token counts at word boundaries
+func (t *DefaultTokenTracker) newTokenSplitter(ctx context.Context, model, text string) (*tokenSplitter, error) {
+	if model == "" {
+		return nil, NewError(ErrInvalidParams, "model is required", nil)
+	}
+	provider, exists := t.providerForModel(model)
+	if !exists {
+		return nil, NewError(ErrProviderNotFound, fmt.Sprintf("no provider found for model: %s", model), nil)
+	}
+
+	splitter := &tokenSplitter{text: text}
+
+	if tokenizer, ok := provider.(TextTokenizer); ok {
+		pieces, err := tokenizer.TokenizeText(model, text)
+		if err != nil {
+			return nil, err
+		}
+
+		// Tokens may end inside a multi-byte character; such cuts move to the
+		// end of the character
+		ends := make([]int, 0, len(pieces))
+		offset := 0
+		for _, piece := range pieces {
+			offset += len(piece)
+			for offset < len(text) && !utf8.RuneStart(text[offset]) {
+				offset++
+			}
+			ends = append(ends, offset)
+		}
+		for _, end := range ends {
+			if len(splitter.cuts) == 0 || splitter.cuts[len(splitter.cuts)-1] != end {
+				splitter.cuts = append(splitter.cuts, end)
+			}
+		}
+		if len(splitter.cuts) == 0 || splitter.cuts[len(splitter.cuts)-1] != len(text) {
+			splitter.cuts = append(splitter.cuts, len(text))
+		}
+
+		splitter.count = func(start, end int) (int, error) {
+			// Tokens ending in (start, end]
+			return sort.SearchInts(ends, end+1) - sort.SearchInts(ends, start+1), nil
+		}
+		return splitter, nil
+	}
+
+	for i, r := range text {
+		if i > 0 && !unicode.IsSpace(r) && unicode.IsSpace(lastRune(text[:i])) {
+			splitter.cuts = append(splitter.cuts, i)
+		}
+	}
+	splitter.cuts = append(splitter.cuts, len(text))
+
+	splitter.count = func(start, end int) (int, error) {
+		segment := text[start:end]
+		count, err := t.CountTokensCtx(ctx, TokenCountParams{Model: model, Text: &segment})
+		return count.InputTokens, err
+	}
+	return splitter, nil
+}
+
+// lastRune returns the last rune of s
+func lastRune(s string) rune {
+	r, _ := utf8.DecodeLastRuneInString(s)
+	return r
+}
+
+// nextCut returns the first cut after start
+func (s *tokenSplitter) nextCut(start int) int {
+	return s.cuts[sort.SearchInts(s.cuts, start+1)]
+}
+
+// longestFit returns the largest end such that text[start:end] has at most limit
+// tokens, or start when not even the first character fits
+func (s *tokenSplitter) longestFit(start, limit int) (int, error) {
+	candidates := s.cuts[sort.SearchInts(s.cuts, start+1):]
+	end, err := s.searchFit(start, candidates, limit)
+	if err != nil || end > start || len(candidates) == 0 {
+		return end, err
+	}
+
+	// The first word is already too long; cut inside it at a character boundary
+	var runes []int
+	for i := range s.text[start:candidates[0]] {
+		if i > 0 {
+			runes = append(runes, start+i)
+		}
+	}
+	return s.searchFit(start, runes, limit)
+}
+
+// searchFit returns the last candidate end whose segment from start fits limit, or start
+func (s *tokenSplitter) searchFit(start int, candidates []int, limit int) (int, error) {
+	var searchErr error
+	n := sort.Search(len(candidates), func(i int) bool {
+		if searchErr != nil {
+			return true
+		}
+		count, err := s.count(start, candidates[i])
+		if err != nil {
+			searchErr = err
+			return true
+		}
+		return count > limit
+	})
+	if searchErr != nil {
+		return start, searchErr
+	}
+	if n == 0 {
+		return start, nil
+	}
+	return candidates[n-1], nil
+}
+
+// overlapStart returns where the chunk after text[start:end] begins: the earliest cut
+// after start from which at most overlap tokens remain up to end
+func (s *tokenSplitter) overlapStart(start, end, overlap int) (int, error) {
+	if overlap == 0 {
+		return end, nil
+	}
+
+	candidates := s.cuts[sort.SearchInts(s.cuts, start+1):sort.SearchInts(s.cuts, end)]
+	var searchErr error
+	n := sort.Search(len(candidates), func(i int) bool {
+		if searchErr != nil {
+			return true
+		}
+		count, err := s.count(candidates[i], end)
+		if err != nil {
+			searchErr = err
+			return true
+		}
+		return count <= overlap
+	})
+	if searchErr != nil {
+		return end, searchErr
+	}
+	if n == len(candidates) {
+		return end, nil
+	}
+	return candidates[n], nil
+}
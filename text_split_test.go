@@ -0,0 +1,113 @@
+package tokentracker
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// runeCountingProvider counts every character as a token
+type runeCountingProvider struct {
+	MockSimpleProvider
+}
+
+func (p *runeCountingProvider) CountTokens(params TokenCountParams) (TokenCount, error) {
+	n := utf8.RuneCountInString(*params.Text)
+	return TokenCount{InputTokens: n, TotalTokens: n}, nil
+}
+
+// wordTokenizingProvider tokenizes text into words with their leading spaces
+type wordTokenizingProvider struct {
+	MockSimpleProvider
+}
+
+func (p *wordTokenizingProvider) TokenizeText(model, text string) ([]string, error) {
+	var pieces []string
+	for _, word := range strings.SplitAfter(text, " ") {
+		if word != "" {
+			pieces = append(pieces, word)
+		}
+	}
+	return pieces, nil
+}
+
+func TestDefaultTokenTracker_TruncateToTokens(t *testing.T) {
+	tracker := NewTokenTracker(NewConfig())
+	tracker.RegisterProvider(&runeCountingProvider{MockSimpleProvider{name: "chars", supportedModels: map[string]bool{"chars-1": true}}})
+	tracker.RegisterProvider(&wordTokenizingProvider{MockSimpleProvider{name: "words", supportedModels: map[string]bool{"words-1": true}}})
+
+	tests := []struct {
+		model     string
+		text      string
+		maxTokens int
+		want      string
+	}{
+		{"chars-1", "the quick brown fox", 12, "the quick "},
+		{"chars-1", "the quick brown fox", 100, "the quick brown fox"},
+		{"chars-1", "unbreakable", 4, "unbr"},
+		{"chars-1", "héllo wörld", 3, "hél"},
+		{"chars-1", "the quick brown fox", 0, ""},
+		{"words-1", "the quick brown fox", 2, "the quick "},
+		{"words-1", "the quick brown fox", 4, "the quick brown fox"},
+	}
+	for _, tt := range tests {
+		got, err := tracker.TruncateToTokens(tt.model, tt.text, tt.maxTokens)
+		if err != nil {
+			t.Fatalf("TruncateToTokens(%s, %q, %d) error = %v", tt.model, tt.text, tt.maxTokens, err)
+		}
+		if got != tt.want {
+			t.Errorf("TruncateToTokens(%s, %q, %d) = %q, want %q", tt.model, tt.text, tt.maxTokens, got, tt.want)
+		}
+	}
+
+	if _, err := tracker.TruncateToTokens("chars-1", "text", -1); err == nil {
+		t.Error("TruncateToTokens() with negative max tokens should fail")
+	}
+	if _, err := tracker.TruncateToTokens("unknown", "text", 1); err == nil {
+		t.Error("TruncateToTokens() for an unknown model should fail")
+	}
+}
+
+func TestDefaultTokenTracker_SplitByTokens(t *testing.T) {
+	tracker := NewTokenTracker(NewConfig())
+	tracker.RegisterProvider(&runeCountingProvider{MockSimpleProvider{name: "chars", supportedModels: map[string]bool{"chars-1": true}}})
+	tracker.RegisterProvider(&wordTokenizingProvider{MockSimpleProvider{name: "words", supportedModels: map[string]bool{"words-1": true}}})
+
+	text := "one two three four five six seven"
+	tests := []struct {
+		model     string
+		chunkSize int
+		overlap   int
+		want      []string
+	}{
+		{"words-1", 3, 0, []string{"one two three ", "four five six ", "seven"}},
+		{"words-1", 3, 1, []string{"one two three ", "three four five ", "five six seven"}},
+		{"chars-1", 10, 0, []string{"one two ", "three ", "four five ", "six seven"}},
+		{"chars-1", 14, 6, []string{"one two three ", "three four ", "four five six ", "six seven"}},
+	}
+	for _, tt := range tests {
+		chunks, err := tracker.SplitByTokens(tt.model, text, tt.chunkSize, tt.overlap)
+		if err != nil {
+			t.Fatalf("SplitByTokens(%s, %d, %d) error = %v", tt.model, tt.chunkSize, tt.overlap, err)
+		}
+		if strings.Join(chunks, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("SplitByTokens(%s, %d, %d) = %q, want %q", tt.model, tt.chunkSize, tt.overlap, chunks, tt.want)
+		}
+	}
+
+	// A word longer than a chunk is cut inside the word
+	chunks, err := tracker.SplitByTokens("chars-1", "abcdefgh", 3, 0)
+	if err != nil || strings.Join(chunks, "|") != "abc|def|gh" {
+		t.Errorf("SplitByTokens() of a long word = %q, %v", chunks, err)
+	}
+
+	if chunks, err := tracker.SplitByTokens("chars-1", "", 3, 0); err != nil || chunks != nil {
+		t.Errorf("SplitByTokens() of empty text = %q, %v", chunks, err)
+	}
+	if _, err := tracker.SplitByTokens("chars-1", text, 0, 0); err == nil {
+		t.Error("SplitByTokens() with a zero chunk size should fail")
+	}
+	if _, err := tracker.SplitByTokens("chars-1", text, 3, 3); err == nil {
+		t.Error("SplitByTokens() with an overlap as large as the chunk should fail")
+	}
+}
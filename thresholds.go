@@ -0,0 +1,132 @@
+package tokentracker
+
+import (
+	"sync"
+	"time"
+)
+
+// ThresholdConfig describes a cost or token threshold on tracked usage.
+// Empty Provider/Model match any call.
+type ThresholdConfig struct {
+	Provider   string
+	Model      string
+	CostLimit  float64       // 0 means no cost threshold
+	TokenLimit int           // 0 means no token threshold
+	Window     time.Duration // rolling window the usage is summed over (0 sums all usage since registration)
+}
+
+// matches reports whether the usage falls within the threshold's scope
+func (c ThresholdConfig) matches(metrics UsageMetrics) bool {
+	return (c.Provider == "" || c.Provider == metrics.Provider) &&
+		(c.Model == "" || c.Model == metrics.Model)
+}
+
+// ThresholdEvent is passed to threshold callbacks when usage crosses a threshold
+type ThresholdEvent struct {
+	Config  ThresholdConfig
+	Cost    float64      // cumulative cost within the window
+	Tokens  int          // cumulative tokens within the window
+	Time    time.Time    // when the threshold was crossed
+	Trigger UsageMetrics // the call that crossed the threshold
+}
+
+// thresholdSample is the usage of one call within a rolling window
+type thresholdSample struct {
+	at     time.Time
+	cost   float64
+	tokens int
+}
+
+// threshold tracks the usage of one registered threshold
+type threshold struct {
+	config   ThresholdConfig
+	callback func(ThresholdEvent)
+	samples  []thresholdSample
+	cost     float64
+	tokens   int
+	crossed  bool
+}
+
+// add records usage and reports whether the threshold was crossed by it
+func (th *threshold) add(now time.Time, metrics UsageMetrics) bool {
+	if th.config.Window > 0 {
+		th.samples = append(th.samples, thresholdSample{at: now, cost: metrics.Price.TotalCost, tokens: metrics.TokenCount.TotalTokens})
+		th.expire(now)
+	}
+	th.cost += metrics.Price.TotalCost
+	th.tokens += metrics.TokenCount.TotalTokens
+
+	over := (th.config.CostLimit > 0 && th.cost >= th.config.CostLimit) ||
+		(th.config.TokenLimit > 0 && th.tokens >= th.config.TokenLimit)
+
+	// Rolling thresholds re-arm once usage drops back below the limits
+	fire := over && !th.crossed
+	th.crossed = over
+	return fire
+}
+
+// expire drops samples that fell out of the rolling window
+func (th *threshold) expire(now time.Time) {
+	cutoff := now.Add(-th.config.Window)
+	i := 0
+	for ; i < len(th.samples) && !th.samples[i].at.After(cutoff); i++ {
+		th.cost -= th.samples[i].cost
+		th.tokens -= th.samples[i].tokens
+	}
+	th.samples = th.samples[i:]
+}
+
+// thresholdSet holds the thresholds registered on a tracker
+type thresholdSet struct {
+	thresholds []*threshold
+	mu         sync.Mutex
+}
+
+// OnThreshold registers a callback invoked when the cumulative tracked usage matching
+// the config crosses its cost or token limit. The callback runs synchronously on the
+// goroutine that tracked the crossing call.
+func (t *DefaultTokenTracker) OnThreshold(config ThresholdConfig, callback func(ThresholdEvent)) error {
+	if callback == nil {
+		return NewError(ErrInvalidParams, "threshold callback is required", nil)
+	}
+	if config.CostLimit <= 0 && config.TokenLimit <= 0 {
+		return NewError(ErrInvalidParams, "threshold requires a cost or token limit", nil)
+	}
+	if config.Window < 0 {
+		return NewError(ErrInvalidParams, "threshold window must not be negative", nil)
+	}
+
+	t.thresholds.mu.Lock()
+	defer t.thresholds.mu.Unlock()
+
+	t.thresholds.thresholds = append(t.thresholds.thresholds, &threshold{config: config, callback: callback})
+	return nil
+}
+
+// checkThresholds adds tracked usage to the thresholds and runs the callbacks of those crossed
+func (t *DefaultTokenTracker) checkThresholds(metrics UsageMetrics) {
+	now := t.clock().Now()
+
+	var events []ThresholdEvent
+	var callbacks []func(ThresholdEvent)
+
+	t.thresholds.mu.Lock()
+	for _, th := range t.thresholds.thresholds {
+		if !th.config.matches(metrics) || !th.add(now, metrics) {
+			continue
+		}
+		events = append(events, ThresholdEvent{
+			Config:  th.config,
+			Cost:    th.cost,
+			Tokens:  th.tokens,
+			Time:    now,
+			Trigger: metrics,
+		})
+		callbacks = append(callbacks, th.callback)
+	}
+	t.thresholds.mu.Unlock()
+
+	for i, event := range events {
+		callbacks[i](event)
+	}
+}
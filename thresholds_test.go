@@ -0,0 +1,91 @@
+package tokentracker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker/tokentrackertest"
+)
+
+func newThresholdTracker(clock Clock) *DefaultTokenTracker {
+	tracker := NewTokenTracker(NewConfig(), WithClock(clock))
+	tracker.RegisterProvider(&MockProvider{
+		name:           "mock",
+		supportedModel: "mock-model",
+		tokenCount:     TokenCount{InputTokens: 100, TotalTokens: 100},
+		price:          Price{TotalCost: 1, Currency: "USD"},
+	})
+	return tracker
+}
+
+func trackMock(t *testing.T, tracker *DefaultTokenTracker) {
+	t.Helper()
+	_, err := tracker.TrackUsage(CallParams{
+		Model:     "mock-model",
+		Params:    TokenCountParams{Model: "mock-model", Text: stringPtr("Hello")},
+		StartTime: time.Now(),
+	}, "response")
+	if err != nil {
+		t.Fatalf("TrackUsage() error = %v", err)
+	}
+}
+
+func TestDefaultTokenTracker_OnThreshold(t *testing.T) {
+	tracker := newThresholdTracker(nil)
+
+	var costEvents, tokenEvents, otherEvents []ThresholdEvent
+	_ = tracker.OnThreshold(ThresholdConfig{CostLimit: 2.5}, func(e ThresholdEvent) { costEvents = append(costEvents, e) })
+	_ = tracker.OnThreshold(ThresholdConfig{Model: "mock-model", TokenLimit: 200}, func(e ThresholdEvent) { tokenEvents = append(tokenEvents, e) })
+	_ = tracker.OnThreshold(ThresholdConfig{Provider: "openai", CostLimit: 0.1}, func(e ThresholdEvent) { otherEvents = append(otherEvents, e) })
+
+	for i := 0; i < 5; i++ {
+		trackMock(t, tracker)
+	}
+
+	if len(costEvents) != 1 || costEvents[0].Cost != 3 {
+		t.Errorf("cost events = %+v, want one event at $3", costEvents)
+	}
+	if len(tokenEvents) != 1 || tokenEvents[0].Tokens != 200 || tokenEvents[0].Trigger.Model != "mock-model" {
+		t.Errorf("token events = %+v, want one event at 200 tokens", tokenEvents)
+	}
+	if len(otherEvents) != 0 {
+		t.Errorf("thresholds of other providers fired: %+v", otherEvents)
+	}
+
+	if err := tracker.OnThreshold(ThresholdConfig{}, func(ThresholdEvent) {}); err == nil {
+		t.Error("OnThreshold() without limits should fail")
+	}
+	if err := tracker.OnThreshold(ThresholdConfig{CostLimit: 1}, nil); err == nil {
+		t.Error("OnThreshold() without a callback should fail")
+	}
+}
+
+func TestDefaultTokenTracker_OnThresholdWindow(t *testing.T) {
+	clock := tokentrackertest.NewFakeClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	tracker := newThresholdTracker(clock)
+
+	var events []ThresholdEvent
+	_ = tracker.OnThreshold(ThresholdConfig{CostLimit: 2, Window: time.Hour}, func(e ThresholdEvent) { events = append(events, e) })
+
+	trackMock(t, tracker)
+	clock.Advance(90 * time.Minute)
+	trackMock(t, tracker) // the first call has left the window
+	if len(events) != 0 {
+		t.Fatalf("threshold fired with $1 in the window: %+v", events)
+	}
+
+	clock.Advance(10 * time.Minute)
+	trackMock(t, tracker)
+	trackMock(t, tracker) // still over the limit, no second alert
+	if len(events) != 1 || !events[0].Time.Equal(clock.Now()) {
+		t.Fatalf("events = %+v, want one event", events)
+	}
+
+	// Once usage drops out of the window the threshold re-arms
+	clock.Advance(2 * time.Hour)
+	trackMock(t, tracker)
+	trackMock(t, tracker)
+	if len(events) != 2 {
+		t.Errorf("got %d events, want the re-armed threshold to fire again", len(events))
+	}
+}
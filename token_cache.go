@@ -0,0 +1,350 @@
+package tokentracker
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// DefaultTokenCacheSize is the default maximum number of entries of the token cache
+const DefaultTokenCacheSize = 10000
+
+// DefaultTokenCacheShards is the default number of shards of large token caches
+const DefaultTokenCacheShards = 16
+
+// minTokenCacheShardSize is the fewest entries a shard holds; smaller caches have
+// fewer shards
+const minTokenCacheShardSize = 256
+
+// TokenCacheOptions configures the token cache
+type TokenCacheOptions struct {
+	// MaxEntries is the number of entries kept before the least recently used are
+	// evicted (0 uses DefaultTokenCacheSize)
+	MaxEntries int
+
+	// TTL is how long entries stay valid (0 keeps them until they are evicted)
+	TTL time.Duration
+
+	// Disabled turns caching off
+	Disabled bool
+
+	// Clock times entries for the TTL (nil uses the system clock)
+	Clock Clock
+
+	// Logger logs evictions at debug level (nil logs nothing)
+	Logger *slog.Logger
+
+	// Shards is the number of shards a large cache is split into, each with its own
+	// lock (0 uses DefaultTokenCacheShards). Every shard holds at least 256 entries.
+	Shards int
+}
+
+// TokenCountCache caches token counts of texts. Providers use the cache of their
+// configuration, which defaults to the global token cache.
+type TokenCountCache interface {
+	// Get returns the cached count of a text
+	Get(provider, model, text string) (int, bool)
+
+	// Set caches the count of a text
+	Set(provider, model, text string, count int)
+
+	// Purge removes all entries
+	Purge()
+}
+
+// NoopTokenCache is a TokenCountCache that caches nothing
+type NoopTokenCache struct{}
+
+// Get always misses
+func (NoopTokenCache) Get(provider, model, text string) (int, bool) { return 0, false }
+
+// Set does nothing
+func (NoopTokenCache) Set(provider, model, text string, count int) {}
+
+// Purge does nothing
+func (NoopTokenCache) Purge() {}
+
+// TokenCacheStats are the counters of a token cache
+type TokenCacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Entries   int
+}
+
+// HitRate returns the share of lookups that were hits
+func (s TokenCacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// TokenCache is an LRU cache of token counts, keyed by a SHA-256 hash of the
+// provider, model and text. Large caches are split into shards by key, each with
+// its own lock and share of MaxEntries, so concurrent lookups rarely wait on each
+// other; entries are evicted least recently used within their shard.
+type TokenCache struct {
+	opts      TokenCacheOptions
+	shards    []*tokenCacheShard
+	evictions atomic.Uint64
+}
+
+// tokenCacheShard is the LRU list of the keys of one shard
+type tokenCacheShard struct {
+	maxEntries int
+	entries    map[[sha256.Size]byte]*list.Element
+	order      *list.List // most recently used first
+	hits       uint64
+	misses     uint64
+	mu         sync.Mutex
+}
+
+// tokenCacheEntry is a cached count
+type tokenCacheEntry struct {
+	key     [sha256.Size]byte
+	count   int
+	expires time.Time
+}
+
+// NewTokenCache creates a token cache
+func NewTokenCache(opts TokenCacheOptions) *TokenCache {
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = DefaultTokenCacheSize
+	}
+	if opts.Shards <= 0 {
+		opts.Shards = DefaultTokenCacheShards
+	}
+	if opts.Clock == nil {
+		opts.Clock = SystemClock
+	}
+
+	// Small caches keep a single exact LRU list
+	shards := min(opts.Shards, max(1, opts.MaxEntries/minTokenCacheShardSize))
+	cache := &TokenCache{opts: opts, shards: make([]*tokenCacheShard, shards)}
+	for i := range cache.shards {
+		maxEntries := opts.MaxEntries / shards
+		if i < opts.MaxEntries%shards {
+			maxEntries++
+		}
+		cache.shards[i] = &tokenCacheShard{
+			maxEntries: maxEntries,
+			entries:    make(map[[sha256.Size]byte]*list.Element),
+			order:      list.New(),
+		}
+	}
+	return cache
+}
+
+// tokenCacheKey hashes the parts of a cache key. Parts are separated by a zero byte,
+// so different providers, models and texts cannot produce the same input.
+func tokenCacheKey(provider, model, text string) [sha256.Size]byte {
+	hasher := keyHasherPool.Get().(*keyHasher)
+	hasher.hash.Reset()
+	hasher.hash.Write(stringBytes(provider))
+	hasher.hash.Write(keySeparator[:])
+	hasher.hash.Write(stringBytes(model))
+	hasher.hash.Write(keySeparator[:])
+	hasher.hash.Write(stringBytes(text))
+
+	key := [sha256.Size]byte(hasher.hash.Sum(hasher.sum[:0]))
+	keyHasherPool.Put(hasher)
+	return key
+}
+
+// keySeparator separates the parts of a cache key
+var keySeparator = [1]byte{0}
+
+// keyHasher hashes cache keys into a buffer of its own, since a digest summed into a
+// local array would move it to the heap
+type keyHasher struct {
+	hash hash.Hash
+	sum  [sha256.Size]byte
+}
+
+// keyHasherPool reuses the hashers of cache keys, so a lookup does not allocate
+var keyHasherPool = sync.Pool{New: func() any { return &keyHasher{hash: sha256.New()} }}
+
+// stringBytes returns the bytes of s without copying them. The bytes must not be
+// modified; they are only read by the hasher.
+func stringBytes(s string) []byte {
+	return unsafe.Slice(unsafe.StringData(s), len(s))
+}
+
+// shard returns the shard of a key
+func (c *TokenCache) shard(key [sha256.Size]byte) *tokenCacheShard {
+	return c.shards[int(binary.LittleEndian.Uint32(key[:4]))%len(c.shards)]
+}
+
+// Get returns the cached count of a text
+func (c *TokenCache) Get(provider, model, text string) (int, bool) {
+	if c.opts.Disabled {
+		return 0, false
+	}
+
+	key := tokenCacheKey(provider, model, text)
+	shard := c.shard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	element, exists := shard.entries[key]
+	if !exists {
+		shard.misses++
+		return 0, false
+	}
+
+	entry := element.Value.(*tokenCacheEntry)
+	if !entry.expires.IsZero() && !c.opts.Clock.Now().Before(entry.expires) {
+		shard.remove(element)
+		shard.misses++
+		return 0, false
+	}
+
+	shard.order.MoveToFront(element)
+	shard.hits++
+	return entry.count, true
+}
+
+// Set caches the count of a text, evicting the least recently used entry of its
+// shard when the shard is full
+func (c *TokenCache) Set(provider, model, text string, count int) {
+	if c.opts.Disabled {
+		return
+	}
+
+	var expires time.Time
+	if c.opts.TTL > 0 {
+		expires = c.opts.Clock.Now().Add(c.opts.TTL)
+	}
+
+	key := tokenCacheKey(provider, model, text)
+	shard := c.shard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if element, exists := shard.entries[key]; exists {
+		entry := element.Value.(*tokenCacheEntry)
+		entry.count, entry.expires = count, expires
+		shard.order.MoveToFront(element)
+		return
+	}
+
+	shard.entries[key] = shard.order.PushFront(&tokenCacheEntry{key: key, count: count, expires: expires})
+	for shard.order.Len() > shard.maxEntries {
+		shard.remove(shard.order.Back())
+		evictions := c.evictions.Add(1)
+		if c.opts.Logger != nil {
+			c.opts.Logger.Debug("token cache entry evicted", "max_entries", c.opts.MaxEntries, "evictions", evictions)
+		}
+	}
+}
+
+// Purge removes all entries; the counters are kept
+func (c *TokenCache) Purge() {
+	for _, shard := range c.shards {
+		shard.mu.Lock()
+		shard.entries = make(map[[sha256.Size]byte]*list.Element)
+		shard.order.Init()
+		shard.mu.Unlock()
+	}
+}
+
+// Stats returns the hit, miss and eviction counters and the number of entries
+func (c *TokenCache) Stats() TokenCacheStats {
+	stats := TokenCacheStats{Evictions: c.evictions.Load()}
+	for _, shard := range c.shards {
+		shard.mu.Lock()
+		stats.Hits += shard.hits
+		stats.Misses += shard.misses
+		stats.Entries += shard.order.Len()
+		shard.mu.Unlock()
+	}
+	return stats
+}
+
+// remove removes an entry; the caller holds the lock of the shard
+func (s *tokenCacheShard) remove(element *list.Element) {
+	s.order.Remove(element)
+	delete(s.entries, element.Value.(*tokenCacheEntry).key)
+}
+
+// globalTokenCache is the cache used by GetCachedTokenCount and SetCachedTokenCount
+var (
+	globalTokenCache   = NewTokenCache(TokenCacheOptions{})
+	globalTokenCacheMu sync.RWMutex
+)
+
+// ConfigureTokenCache replaces the global token cache with one using opts, discarding
+// its entries and counters
+func ConfigureTokenCache(opts TokenCacheOptions) {
+	globalTokenCacheMu.Lock()
+	defer globalTokenCacheMu.Unlock()
+
+	globalTokenCache = NewTokenCache(opts)
+}
+
+// GlobalTokenCache returns the global token cache, e.g. to read its Stats
+func GlobalTokenCache() *TokenCache {
+	globalTokenCacheMu.RLock()
+	defer globalTokenCacheMu.RUnlock()
+
+	return globalTokenCache
+}
+
+// GetCachedTokenCount gets a cached token count if available
+func GetCachedTokenCount(provider, model, text string) (int, bool) {
+	return GlobalTokenCache().Get(provider, model, text)
+}
+
+// SetCachedTokenCount sets a token count in the cache
+func SetCachedTokenCount(provider, model, text string, count int) {
+	GlobalTokenCache().Set(provider, model, text, count)
+}
+
+// CleanupCache purges the global token cache when it holds more than maxSize entries.
+//
+// Deprecated: the cache evicts its least recently used entries; configure its size
+// with ConfigureTokenCache.
+func CleanupCache(maxSize int) {
+	cache := GlobalTokenCache()
+	if cache.Stats().Entries > maxSize {
+		cache.Purge()
+	}
+}
+
+// SetTokenCache sets the cache providers using this configuration keep token counts
+// in, e.g. a NewTokenCache to isolate trackers from each other (nil uses the global cache)
+func (c *Config) SetTokenCache(cache TokenCountCache) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.tokenCache = cache
+}
+
+// GetTokenCache returns the token cache of providers using this configuration
+func (c *Config) GetTokenCache() TokenCountCache {
+	c.mu.RLock()
+	cache := c.tokenCache
+	c.mu.RUnlock()
+
+	if cache == nil {
+		return GlobalTokenCache()
+	}
+	return cache
+}
+
+// WithTokenCache makes the tracker's providers keep token counts in cache instead of
+// the global token cache
+func WithTokenCache(cache TokenCountCache) TrackerOption {
+	return func(t *DefaultTokenTracker) {
+		t.config.SetTokenCache(cache)
+	}
+}
@@ -0,0 +1,181 @@
+package tokentracker
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker/tokentrackertest"
+)
+
+func TestTokenCache_LRU(t *testing.T) {
+	cache := NewTokenCache(TokenCacheOptions{MaxEntries: 2})
+
+	cache.Set("p", "m", "a", 1)
+	cache.Set("p", "m", "b", 2)
+	if _, ok := cache.Get("p", "m", "a"); !ok {
+		t.Fatal("Get(a) missed")
+	}
+
+	// b is now the least recently used entry
+	cache.Set("p", "m", "c", 3)
+	if _, ok := cache.Get("p", "m", "b"); ok {
+		t.Error("Get(b) hit, want the least recently used entry evicted")
+	}
+	if count, ok := cache.Get("p", "m", "c"); !ok || count != 3 {
+		t.Errorf("Get(c) = %d, %v", count, ok)
+	}
+
+	stats := cache.Stats()
+	if stats.Hits != 2 || stats.Misses != 1 || stats.Evictions != 1 || stats.Entries != 2 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+	if rate := stats.HitRate(); rate < 0.66 || rate > 0.67 {
+		t.Errorf("HitRate() = %v, want 2/3", rate)
+	}
+
+	cache.Purge()
+	if stats := cache.Stats(); stats.Entries != 0 || stats.Hits != 2 {
+		t.Errorf("Stats() after Purge() = %+v, want no entries and the counters kept", stats)
+	}
+}
+
+func TestTokenCache_Keys(t *testing.T) {
+	cache := NewTokenCache(TokenCacheOptions{})
+
+	// Texts sharing their first and last 50 characters and their length
+	prefix, suffix := string(make([]byte, 50)), string(make([]byte, 50))
+	cache.Set("p", "m", prefix+"one"+suffix, 1)
+	if _, ok := cache.Get("p", "m", prefix+"two"+suffix); ok {
+		t.Error("texts with the same prefix, suffix and length share an entry")
+	}
+
+	cache.Set("a:b", "c", "text", 1)
+	if _, ok := cache.Get("a", "b:c", "text"); ok {
+		t.Error("different providers and models share an entry")
+	}
+}
+
+func TestTokenCache_TTL(t *testing.T) {
+	clock := tokentrackertest.NewFakeClock(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	cache := NewTokenCache(TokenCacheOptions{TTL: time.Minute, Clock: clock})
+
+	cache.Set("p", "m", "text", 5)
+	clock.Advance(59 * time.Second)
+	if _, ok := cache.Get("p", "m", "text"); !ok {
+		t.Error("Get() missed before the TTL")
+	}
+
+	clock.Advance(time.Second)
+	if _, ok := cache.Get("p", "m", "text"); ok {
+		t.Error("Get() hit after the TTL")
+	}
+	if stats := cache.Stats(); stats.Entries != 0 {
+		t.Errorf("Entries = %d, want the expired entry removed", stats.Entries)
+	}
+}
+
+func TestConfigureTokenCache(t *testing.T) {
+	defer ConfigureTokenCache(TokenCacheOptions{})
+
+	ConfigureTokenCache(TokenCacheOptions{Disabled: true})
+	SetCachedTokenCount("p", "m", "text", 5)
+	if _, ok := GetCachedTokenCount("p", "m", "text"); ok {
+		t.Error("GetCachedTokenCount() hit with the cache disabled")
+	}
+	if stats := GlobalTokenCache().Stats(); stats.Entries != 0 || stats.Misses != 0 {
+		t.Errorf("Stats() = %+v, want a disabled cache to count nothing", stats)
+	}
+}
+
+func TestConfig_TokenCache(t *testing.T) {
+	config := NewConfig()
+	if config.GetTokenCache() != GlobalTokenCache() {
+		t.Error("GetTokenCache() should default to the global cache")
+	}
+
+	cache := NewTokenCache(TokenCacheOptions{MaxEntries: 10})
+	tracker := NewTokenTracker(config, WithTokenCache(cache))
+	if tracker.config.GetTokenCache() != cache {
+		t.Error("WithTokenCache() should set the cache of the configuration")
+	}
+
+	config.SetTokenCache(NoopTokenCache{})
+	config.GetTokenCache().Set("p", "m", "text", 5)
+	if _, ok := config.GetTokenCache().Get("p", "m", "text"); ok {
+		t.Error("NoopTokenCache should cache nothing")
+	}
+}
+
+func TestTokenCache_Shards(t *testing.T) {
+	if shards := len(NewTokenCache(TokenCacheOptions{MaxEntries: 100}).shards); shards != 1 {
+		t.Errorf("small cache has %d shards, want 1 exact LRU list", shards)
+	}
+
+	cache := NewTokenCache(TokenCacheOptions{MaxEntries: 4099, Shards: 16})
+	if len(cache.shards) != 16 {
+		t.Fatalf("cache has %d shards, want 16", len(cache.shards))
+	}
+	capacity := 0
+	for _, shard := range cache.shards {
+		capacity += shard.maxEntries
+	}
+	if capacity != 4099 {
+		t.Errorf("shards hold %d entries, want MaxEntries", capacity)
+	}
+
+	// Concurrent use keeps every shard within its share
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				text := fmt.Sprintf("text-%d-%d", g, i)
+				cache.Set("p", "m", text, i)
+				cache.Get("p", "m", text)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	stats := cache.Stats()
+	if stats.Entries > 4099 || stats.Entries+int(stats.Evictions) != 16000 || stats.Hits+stats.Misses != 16000 {
+		t.Errorf("Stats() = %+v, want 16000 entries set within MaxEntries", stats)
+	}
+}
+
+func TestTokenCache_GetDoesNotAllocate(t *testing.T) {
+	cache := NewTokenCache(TokenCacheOptions{})
+	text := strings.Repeat("prompt ", 1000)
+	cache.Set("p", "m", text, 1000)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, exists := cache.Get("p", "m", text); !exists {
+			t.Fatal("Get() missed a cached text")
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("Get() allocations = %v, want 0", allocs)
+	}
+}
+
+func BenchmarkTokenCache_GetParallel(b *testing.B) {
+	cache := NewTokenCache(TokenCacheOptions{})
+	texts := make([]string, 1024)
+	for i := range texts {
+		texts[i] = fmt.Sprintf("prompt %d", i)
+		cache.Set("p", "m", texts[i], i)
+	}
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			cache.Get("p", "m", texts[i%len(texts)])
+			i++
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package tokentracker
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Tokenizer counts the tokens of text offline, without calling a provider's API. Providers
+// counting text approximately use the tokenizer selected for them in the configuration.
+type Tokenizer interface {
+	// Name identifies the tokenizer in configurations and cache entries
+	Name() string
+
+	// CountTokens returns the number of tokens of text
+	CountTokens(text string) int
+}
+
+// tokenizers holds the registered tokenizers by name
+var tokenizers = struct {
+	tokenizers map[string]Tokenizer
+	mu         sync.RWMutex
+}{tokenizers: make(map[string]Tokenizer)}
+
+// RegisterTokenizer registers a tokenizer under its name, replacing any tokenizer
+// registered before, so configurations can select it by name
+func RegisterTokenizer(tokenizer Tokenizer) {
+	if tokenizer == nil || tokenizer.Name() == "" {
+		panic("tokentracker: RegisterTokenizer requires a named tokenizer")
+	}
+
+	tokenizers.mu.Lock()
+	defer tokenizers.mu.Unlock()
+	tokenizers.tokenizers[tokenizer.Name()] = tokenizer
+}
+
+// Tokenizers returns the names of the registered tokenizers, sorted
+func Tokenizers() []string {
+	tokenizers.mu.RLock()
+	defer tokenizers.mu.RUnlock()
+
+	names := make([]string, 0, len(tokenizers.tokenizers))
+	for name := range tokenizers.tokenizers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupTokenizer returns the tokenizer registered under name
+func LookupTokenizer(name string) (Tokenizer, bool) {
+	tokenizers.mu.RLock()
+	defer tokenizers.mu.RUnlock()
+
+	tokenizer, exists := tokenizers.tokenizers[name]
+	return tokenizer, exists
+}
+
+// SetTokenizer selects the tokenizer a provider uses to count text offline, by the
+// name it is registered under (empty restores the provider's default)
+func (c *Config) SetTokenizer(provider, name string) error {
+	if name != "" {
+		if _, exists := LookupTokenizer(name); !exists {
+			return NewError(ErrInvalidParams, fmt.Sprintf("no tokenizer registered with name: %s", name), nil)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	providerConfig, exists := c.Providers[provider]
+	if !exists {
+		providerConfig = ProviderConfig{Models: make(map[string]ModelPricing)}
+	}
+	providerConfig.Tokenizer = name
+	c.Providers[provider] = providerConfig
+	return nil
+}
+
+// GetTokenizer returns the tokenizer selected for a provider, or false when none is
+// selected or the selected one is not registered
+func (c *Config) GetTokenizer(provider string) (Tokenizer, bool) {
+	c.mu.RLock()
+	name := c.Providers[provider].Tokenizer
+	c.mu.RUnlock()
+
+	if name == "" {
+		return nil, false
+	}
+	return LookupTokenizer(name)
+}
@@ -0,0 +1,85 @@
+package tokentracker
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// wordTokenizer counts a token per word
+type wordTokenizer struct {
+	name string
+}
+
+func (t wordTokenizer) Name() string {
+	return t.name
+}
+
+func (t wordTokenizer) CountTokens(text string) int {
+	return len(strings.Fields(text))
+}
+
+func TestRegisterTokenizer(t *testing.T) {
+	RegisterTokenizer(wordTokenizer{name: "test-words"})
+
+	tokenizer, exists := LookupTokenizer("test-words")
+	if !exists || tokenizer.CountTokens("one two three") != 3 {
+		t.Fatalf("LookupTokenizer() = %v, %v, want the registered tokenizer", tokenizer, exists)
+	}
+	if _, exists := LookupTokenizer("test-missing"); exists {
+		t.Error("LookupTokenizer() of an unregistered name should fail")
+	}
+
+	found := false
+	for _, name := range Tokenizers() {
+		found = found || name == "test-words"
+	}
+	if !found {
+		t.Errorf("Tokenizers() = %v, want test-words", Tokenizers())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("RegisterTokenizer() of an unnamed tokenizer should panic")
+		}
+	}()
+	RegisterTokenizer(wordTokenizer{})
+}
+
+func TestConfig_SetTokenizer(t *testing.T) {
+	RegisterTokenizer(wordTokenizer{name: "test-words"})
+	config := NewConfig()
+
+	if _, exists := config.GetTokenizer("acme"); exists {
+		t.Error("GetTokenizer() without a selected tokenizer should fail")
+	}
+
+	var trackerErr *TokenTrackerError
+	if err := config.SetTokenizer("acme", "test-missing"); !errors.As(err, &trackerErr) || trackerErr.Type != ErrInvalidParams {
+		t.Errorf("SetTokenizer() of an unregistered name error = %v, want %s", err, ErrInvalidParams)
+	}
+
+	if err := config.SetTokenizer("acme", "test-words"); err != nil {
+		t.Fatalf("SetTokenizer() error = %v", err)
+	}
+	if tokenizer, exists := config.GetTokenizer("acme"); !exists || tokenizer.Name() != "test-words" {
+		t.Errorf("GetTokenizer() = %v, %v, want test-words", tokenizer, exists)
+	}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	if err := config.SetTokenizer("acme", ""); err != nil {
+		t.Fatalf("SetTokenizer() error = %v", err)
+	}
+	if _, exists := config.GetTokenizer("acme"); exists {
+		t.Error("GetTokenizer() after restoring the default should fail")
+	}
+
+	// Configuration files are validated against the registered tokenizers
+	config.Providers["acme"] = ProviderConfig{Tokenizer: "test-missing"}
+	var errs ConfigErrors
+	if err := config.Validate(); !errors.As(err, &errs) || errs[0].Key != "providers.acme.tokenizer" {
+		t.Errorf("Validate() error = %v, want providers.acme.tokenizer", err)
+	}
+}
@@ -1,10 +1,12 @@
 package tokentracker
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"time"
-
-	"github.com/TrustSight-io/tokentracker/common"
+	"io"
+	"sort"
+	"sync"
 )
 
 // SDKClient defines the interface for SDK clients
@@ -19,16 +21,16 @@ type SDKClient interface {
 	GetSupportedModels() ([]string, error)
 
 	// ExtractTokenUsageFromResponse extracts token usage information from an API response
-	ExtractTokenUsageFromResponse(response interface{}) (common.TokenUsage, error)
+	ExtractTokenUsageFromResponse(response interface{}) (TokenUsage, error)
 
 	// FetchCurrentPricing fetches the current pricing information for all supported models
-	FetchCurrentPricing() (map[string]common.ModelPricing, error)
+	FetchCurrentPricing() (map[string]ModelPricing, error)
 
 	// UpdateProviderPricing updates the pricing information in the provider
 	UpdateProviderPricing() error
 
 	// TrackAPICall tracks an API call and returns usage metrics
-	TrackAPICall(model string, response interface{}) (common.UsageMetrics, error)
+	TrackAPICall(model string, response interface{}) (UsageMetrics, error)
 }
 
 // TokenTracker interface defines the main functionality
@@ -36,12 +38,19 @@ type TokenTracker interface {
 	// CountTokens counts tokens for a text string or chat messages
 	CountTokens(params TokenCountParams) (TokenCount, error)
 
+	// CountTokensCtx counts tokens, propagating cancellation and deadlines to the provider
+	CountTokensCtx(ctx context.Context, params TokenCountParams) (TokenCount, error)
+
 	// CalculatePrice calculates price based on token usage
 	CalculatePrice(model string, inputTokens, outputTokens int) (Price, error)
 
 	// TrackUsage tracks full usage for an LLM call
 	TrackUsage(callParams CallParams, response interface{}) (UsageMetrics, error)
 
+	// TrackUsageCtx tracks full usage for an LLM call, propagating cancellation and
+	// deadlines and attaching the trace context carried by ctx
+	TrackUsageCtx(ctx context.Context, callParams CallParams, response interface{}) (UsageMetrics, error)
+
 	// RegisterSDKClient registers an SDK client with the appropriate provider
 	RegisterSDKClient(client SDKClient) error
 
@@ -54,20 +63,76 @@ type TokenTracker interface {
 
 // DefaultTokenTracker implements the TokenTracker interface
 type DefaultTokenTracker struct {
-	registry *ProviderRegistry
-	config   *Config
+	registry    *ProviderRegistry
+	config      *Config
+	store       UsageStore
+	usageLog    *UsageLogger
+	topPrompts  *TopPrompts
+	budgets     *BudgetManager
+	tenants     *TenantManager
+	thresholds  thresholdSet
+	observers   []UsageObserver
+	sinks       []UsageSink
+	sdkClients  map[string]SDKClient
+	models      *modelIndex
+	ensembles   *ensembleStats
+	templates   *promptTemplates
+	pricing     *pricingFreshness
+	async       *asyncTracker
+	idempotency *idempotencyCache
+	sampler     *sampler
+	scrubbing   *ScrubPolicy
+	retention   *retention
+	calibration *calibration
+	accuracy    bool
+	totals      *UsageTotals
+	pricingLog  *pricingAudit
+	updater     *pricingUpdater
+	mu          sync.RWMutex
+}
+
+// TrackerOption configures optional behavior of a DefaultTokenTracker
+type TrackerOption func(*DefaultTokenTracker)
+
+// WithUsageStore makes the tracker write every tracked call into the given store
+func WithUsageStore(store UsageStore) TrackerOption {
+	return func(t *DefaultTokenTracker) {
+		t.store = store
+	}
 }
 
 // NewTokenTracker creates a new token tracker with the given configuration
-func NewTokenTracker(config *Config) *DefaultTokenTracker {
+func NewTokenTracker(config *Config, opts ...TrackerOption) *DefaultTokenTracker {
 	registry := NewProviderRegistry()
 
 	// Register default providers here or allow caller to register them
 
-	return &DefaultTokenTracker{
-		registry: registry,
-		config:   config,
+	tracker := &DefaultTokenTracker{
+		registry:   registry,
+		config:     config,
+		sdkClients: make(map[string]SDKClient),
+		models:     newModelIndex(),
+		ensembles:  newEnsembleStats(),
+		templates:  newPromptTemplates(),
+		pricing:    newPricingFreshness(),
+		totals:     newUsageTotals(configClock{config}),
+		pricingLog: newPricingAudit(),
+		updater:    &pricingUpdater{},
+	}
+
+	for _, opt := range opts {
+		opt(tracker)
 	}
+	if tracker.retention != nil {
+		go tracker.runRetention(tracker.retention)
+	}
+
+	return tracker
+}
+
+// Config returns the configuration the tracker was created with
+func (t *DefaultTokenTracker) Config() *Config {
+	return t.config
 }
 
 // RegisterProvider registers a provider with the token tracker
@@ -84,8 +149,18 @@ func (t *DefaultTokenTracker) RegisterSDKClient(client SDKClient) error {
 		return NewError(ErrProviderNotFound, fmt.Sprintf("no provider found with name: %s", providerName), nil)
 	}
 
-	// Set the SDK client in the provider
-	provider.SetSDKClient(client.GetClient())
+	// Pass the wrapper itself, so providers can use the counters it implements, e.g.
+	// providers.AnthropicTokenCounter; the SDK client is available from GetClient
+	provider.SetSDKClient(client)
+
+	// Remember the client so its model list can be refreshed later
+	t.mu.Lock()
+	t.sdkClients[providerName] = client
+	t.mu.Unlock()
+
+	if models, err := client.GetSupportedModels(); err == nil {
+		t.models.addStatic(providerName, models)
+	}
 
 	// Update pricing information
 	if err := client.UpdateProviderPricing(); err != nil {
@@ -95,22 +170,65 @@ func (t *DefaultTokenTracker) RegisterSDKClient(client SDKClient) error {
 	return nil
 }
 
-// UpdateAllPricing updates pricing information for all registered providers
+// UpdateAllPricing updates pricing information for all registered providers and SDK
+// clients, or from the pricing source when one is configured. After a successful
+// update the prices are fresh and the pricing snapshot, if any, is saved. Changed
+// rates are recorded in the pricing history, see GetPricingHistory.
 func (t *DefaultTokenTracker) UpdateAllPricing() error {
+	return t.UpdateAllPricingCtx(context.Background())
+}
+
+// UpdateAllPricingCtx updates pricing like UpdateAllPricing, giving up on retries
+// and pricing source fetches when ctx is done
+func (t *DefaultTokenTracker) UpdateAllPricingCtx(ctx context.Context) error {
+	t.pricing.mu.Lock()
+	feed := t.pricing.feed
+	t.pricing.mu.Unlock()
+	if feed != nil {
+		return t.auditPricing(PricingChangeSourceFeed, func() error {
+			return t.updatePricingFromSource(ctx, feed)
+		})
+	}
+	return t.auditPricing(PricingChangeSourceProvider, func() error {
+		return t.updateProviderPricing(ctx)
+	})
+}
+
+// updateProviderPricing updates the pricing of every registered provider and SDK client
+func (t *DefaultTokenTracker) updateProviderPricing(ctx context.Context) error {
 	providers := t.registry.All()
-	var lastErr error
+	t.mu.RLock()
+	clients := make([]SDKClient, 0, len(t.sdkClients))
+	for _, client := range t.sdkClients {
+		clients = append(clients, client)
+	}
+	t.mu.RUnlock()
+	sort.Slice(clients, func(i, j int) bool { return clients[i].GetProviderName() < clients[j].GetProviderName() })
 
-	for _, provider := range providers {
-		if err := provider.UpdatePricing(); err != nil {
+	resilience := t.config.GetResilience()
+	var lastErr error
+	update := func(name string, fn func() error) {
+		err := resilience.Do(ctx, name, func(context.Context) error {
+			return fn()
+		})
+		if err != nil {
+			t.config.ReportFallback(FallbackWarning{Provider: name, Operation: "update_pricing", Fallback: FallbackCachedPricing, Err: err})
 			lastErr = err
 		}
 	}
 
+	for _, provider := range providers {
+		update(provider.Name(), provider.UpdatePricing)
+	}
+	for _, client := range clients {
+		update(client.GetProviderName(), client.UpdateProviderPricing)
+	}
+
 	if lastErr != nil {
 		return NewError(ErrPricingUpdateFailed, "failed to update pricing for one or more providers", lastErr)
 	}
 
-	return nil
+	return t.pricingUpdated()
 }
 
 // TrackTokenUsage extracts token usage from a provider response
@@ -124,96 +242,389 @@ func (t *DefaultTokenTracker) TrackTokenUsage(providerName string, response inte
 	return provider.ExtractTokenUsageFromResponse(response)
 }
 
+// providerForModel resolves the provider for a model, falling back to the model
+// catalog and the models discovered from registered SDK clients when no provider
+// claims the model
+func (t *DefaultTokenTracker) providerForModel(model string) (Provider, bool) {
+	if provider, exists := t.registry.GetForModel(model); exists {
+		return provider, true
+	}
+
+	if entry, known := t.config.ModelCatalog().Lookup(model); known {
+		if provider, exists := t.registry.Get(entry.Provider); exists {
+			return provider, true
+		}
+	}
+
+	if providerName, known := t.models.providerFor(model); known {
+		return t.registry.Get(providerName)
+	}
+
+	return nil, false
+}
+
 // CountTokens counts tokens for the given parameters
 func (t *DefaultTokenTracker) CountTokens(params TokenCountParams) (TokenCount, error) {
+	return t.CountTokensCtx(context.Background(), params)
+}
+
+// CountTokensCtx counts tokens for the given parameters, honoring cancellation of ctx
+func (t *DefaultTokenTracker) CountTokensCtx(ctx context.Context, params TokenCountParams) (TokenCount, error) {
 	if params.Model == "" {
 		return TokenCount{}, NewError(ErrInvalidParams, "model is required", nil)
 	}
 
-	provider, exists := t.registry.GetForModel(params.Model)
+	provider, exists := t.providerForModel(params.Model)
 	if !exists {
 		return TokenCount{}, NewError(ErrProviderNotFound, fmt.Sprintf("no provider found for model: %s", params.Model), nil)
 	}
 
-	return provider.CountTokens(params)
+	count, err := CountTokensWithContext(ctx, provider, params)
+	if err != nil || t.calibration == nil {
+		return count, err
+	}
+	return t.calibration.apply(params.Model, count), nil
 }
 
-// CalculatePrice calculates price based on token usage
+// CalculatePrice calculates price based on token usage, in the default currency if one is set
 func (t *DefaultTokenTracker) CalculatePrice(model string, inputTokens, outputTokens int) (Price, error) {
 	if model == "" {
 		return Price{}, NewError(ErrInvalidParams, "model is required", nil)
 	}
 
-	provider, exists := t.registry.GetForModel(model)
+	provider, exists := t.providerForModel(model)
 	if !exists {
 		return Price{}, NewError(ErrProviderNotFound, fmt.Sprintf("no provider found for model: %s", model), nil)
 	}
 
-	return provider.CalculatePrice(model, inputTokens, outputTokens)
+	price, err := provider.CalculatePrice(model, inputTokens, outputTokens)
+	if err != nil {
+		return Price{}, err
+	}
+	return t.config.convertPrice(price)
 }
 
 // TrackUsage tracks full usage for an LLM call
 func (t *DefaultTokenTracker) TrackUsage(callParams CallParams, response interface{}) (UsageMetrics, error) {
-	// Get input token count
-	inputCount, err := t.CountTokens(callParams.Params)
+	return t.TrackUsageCtx(context.Background(), callParams, response)
+}
+
+// TrackUsageCtx tracks full usage for an LLM call, honoring cancellation of ctx.
+// The token counts reported in response are used when the model's provider can
+// extract them; otherwise the input is counted and the response tokens estimated.
+// The W3C trace context carried by ctx, if any, is attached to the metrics. With
+// WithIdempotency, a call already tracked returns its original metrics.
+func (t *DefaultTokenTracker) TrackUsageCtx(ctx context.Context, callParams CallParams, response interface{}) (UsageMetrics, error) {
+	if err := ctx.Err(); err != nil {
+		return UsageMetrics{}, err
+	}
+	if callParams.Model == "" {
+		return UsageMetrics{}, NewError(ErrInvalidParams, "model is required", nil)
+	}
+
+	provider, exists := t.providerForModel(callParams.Model)
+	if !exists {
+		return UsageMetrics{}, NewError(ErrProviderNotFound, fmt.Sprintf("no provider found for model: %s", callParams.Model), nil)
+	}
+
+	callParams.IdempotencyKey = t.idempotencyKey(provider.Name(), callParams, response)
+	return t.trackOnce(callParams.IdempotencyKey, func() (UsageMetrics, error) {
+		return t.trackResponse(ctx, provider, callParams, response)
+	})
+}
+
+// trackResponse tracks a call with the usage reported in response, or counted locally
+func (t *DefaultTokenTracker) trackResponse(ctx context.Context, provider Provider, callParams CallParams, response interface{}) (UsageMetrics, error) {
+	// Prefer the usage reported by the provider
+	if response != nil {
+		count, err := provider.ExtractTokenUsageFromResponse(response)
+		switch {
+		case err != nil:
+			t.logger().Warn("failed to extract usage from response, counting tokens",
+				"provider", provider.Name(), "model", callParams.Model, "response_type", fmt.Sprintf("%T", response), "error", err)
+		case count.InputTokens+count.ResponseTokens > 0:
+			if count.TotalTokens == 0 {
+				count.TotalTokens = count.InputTokens + count.ResponseTokens
+			}
+			if t.accuracy || t.calibration != nil {
+				count = t.estimateReported(ctx, provider, callParams, count)
+			}
+			t.observeResponse(callParams, count)
+			return t.trackTokens(ctx, callParams, count)
+		default:
+			t.logger().Debug("response reports no usage, counting tokens", "provider", provider.Name(), "model", callParams.Model)
+		}
+	}
+
+	// Fall back to counting the input and estimating the response
+	params := callParams.Params
+	if params.Model == "" {
+		params.Model = callParams.Model
+	}
+	inputCount, err := t.CountTokensCtx(ctx, params)
 	if err != nil {
 		return UsageMetrics{}, err
 	}
 
-	// Extract response tokens from the response object
-	// This will be provider-specific and depend on the response structure
 	var outputTokens int
-
-	// Try to extract token count from response if it's available
 	if extractor, ok := response.(interface {
 		GetTokenCount() int
 	}); ok {
 		outputTokens = extractor.GetTokenCount()
 	} else {
-		// Fallback to estimating response tokens
-		provider, exists := t.registry.GetForModel(callParams.Model)
-		if exists {
-			// Create a new params object with CountResponseTokens set to true
-			estimateParams := callParams.Params
-			estimateParams.CountResponseTokens = true
-			estimate, err := provider.CountTokens(estimateParams)
-			if err == nil {
-				outputTokens = estimate.ResponseTokens
-			}
+		params.CountResponseTokens = true
+		if estimate, err := CountTokensWithContext(ctx, provider, params); err == nil {
+			outputTokens = estimate.ResponseTokens
+			t.logger().Debug("estimated response tokens", "provider", provider.Name(), "model", callParams.Model, "response_tokens", outputTokens)
+		} else {
+			t.logger().Warn("failed to estimate response tokens, tracking none",
+				"provider", provider.Name(), "model", callParams.Model, "error", err)
 		}
 	}
 
-	// Calculate price
-	price, err := t.CalculatePrice(callParams.Model, inputCount.InputTokens, outputTokens)
-	if err != nil {
-		return UsageMetrics{}, err
+	// Keep the media, cache and estimate fields of the input count
+	count := inputCount
+	count.ResponseTokens = outputTokens
+	count.TotalTokens = count.InputTokens + outputTokens
+	return t.trackTokens(ctx, callParams, count)
+}
+
+// TrackReportedUsage tracks an LLM call whose token counts were reported by the
+// provider, e.g. in the usage block of an API response, without counting tokens locally
+func (t *DefaultTokenTracker) TrackReportedUsage(ctx context.Context, callParams CallParams, count TokenCount) (UsageMetrics, error) {
+	if callParams.Model == "" {
+		return UsageMetrics{}, NewError(ErrInvalidParams, "model is required", nil)
 	}
 
+	return t.trackOnce(callParams.IdempotencyKey, func() (UsageMetrics, error) {
+		t.observeResponse(callParams, count)
+		return t.trackTokens(ctx, callParams, count)
+	})
+}
+
+// trackTokens prices a call with known token counts, builds its metrics and records them
+func (t *DefaultTokenTracker) trackTokens(ctx context.Context, callParams CallParams, count TokenCount) (UsageMetrics, error) {
 	// Calculate duration
-	duration := time.Since(callParams.StartTime)
+	end := callEnd(ctx, t.clock())
+	duration := end.Sub(callParams.StartTime)
+
+	// Calculate price with the pricing in effect when the call ended
+	t.checkPricingFreshness()
+	price, err := t.CalculateUsagePriceAt(callParams.Model, billableUsage(count, callParams.Batch), end)
+	if err != nil {
+		return UsageMetrics{}, err
+	}
 
 	// Get provider name
-	provider, _ := t.registry.GetForModel(callParams.Model)
+	provider, exists := t.providerForModel(callParams.Model)
+	if !exists {
+		return UsageMetrics{}, NewError(ErrProviderNotFound, fmt.Sprintf("no provider found for model: %s", callParams.Model), nil)
+	}
 	providerName := provider.Name()
 
 	// Create usage metrics
+	count.TotalTokens = count.InputTokens + count.ResponseTokens
 	metrics := UsageMetrics{
-		TokenCount: TokenCount{
-			InputTokens:    inputCount.InputTokens,
-			ResponseTokens: outputTokens,
-			TotalTokens:    inputCount.InputTokens + outputTokens,
-		},
-		Price:     price,
-		Duration:  duration,
-		Timestamp: time.Now(),
-		Model:     callParams.Model,
-		Provider:  providerName,
+		TokenCount: count,
+		Price:      price,
+		Duration:   duration,
+		Timestamp:  end,
+		Model:      callParams.Model,
+		Provider:   providerName,
+		Tags:       copyTags(callParams.Tags),
+		UserID:     callParams.UserID,
+		ProjectID:  callParams.ProjectID,
+
+		APIKeyID:          callParams.APIKeyID,
+		Organization:      callParams.Organization,
+		ProviderProjectID: callParams.ProviderProjectID,
+		CompletionID:      callParams.IdempotencyKey,
+	}
+	if tier, exists := t.config.CostTier(providerName, callParams.Model); exists {
+		metrics.CostTier = string(tier)
+	}
+	metrics.ApplyTraceContext(ctx)
+
+	if t.topPrompts != nil {
+		t.topPrompts.AddFingerprint(PromptFingerprint(callParams.Params), t.scrubText(promptText(callParams.Params)), metrics)
+	}
+
+	if err := t.recordUsage(metrics); err != nil {
+		return metrics, err
 	}
 
 	return metrics, nil
 }
 
+// recordUsage hands tracked usage to the configured store, usage log, budgets and
+// tenant quotas. The metrics are still returned to the caller when recording fails.
+func (t *DefaultTokenTracker) recordUsage(metrics UsageMetrics) error {
+	var budgetErr error
+	if t.budgets != nil {
+		budgetErr = t.budgets.Record(metrics)
+	}
+	if t.tenants != nil {
+		if err := t.tenants.Record(metrics); err != nil && budgetErr == nil {
+			budgetErr = err
+		}
+	}
+	t.checkThresholds(metrics)
+	t.totals.add(metrics)
+	t.notifyObservers(metrics)
+
+	// Usage leaving the process is scrubbed, and with sampling only a sample of the
+	// calls counted above is stored
+	scrubbed := t.scrub(metrics)
+	if stored, keep := t.sample(scrubbed); keep {
+		if err := t.storeUsage(stored); err != nil {
+			return withBudgetError(err, budgetErr)
+		}
+	}
+
+	if err := t.sendToSinks(scrubbed); err != nil {
+		return withBudgetError(err, budgetErr)
+	}
+
+	return budgetErr
+}
+
+// withBudgetError joins a storage or sink error with the budget error of the call, so
+// the failure does not hide that the call went over a hard budget
+func withBudgetError(err, budgetErr error) error {
+	if budgetErr == nil {
+		return err
+	}
+	return errors.Join(budgetErr, err)
+}
+
+// storeUsage writes tracked usage into the configured store and usage log
+func (t *DefaultTokenTracker) storeUsage(metrics UsageMetrics) error {
+	if store := t.UsageStore(); store != nil {
+		if err := store.Record(metrics); err != nil {
+			return NewError(ErrStorageFailed, "failed to record usage", err)
+		}
+	}
+
+	if path, enabled := t.config.usageLogTarget(); enabled {
+		logger, err := t.usageLogger(path)
+		if err != nil {
+			return err
+		}
+		if err := logger.Log(metrics); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// usageLogger returns the usage logger for path, reopening it when the configured path changed
+func (t *DefaultTokenTracker) usageLogger(path string) (*UsageLogger, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.usageLog != nil && t.usageLog.Path() == path {
+		return t.usageLog, nil
+	}
+
+	if t.usageLog != nil {
+		t.usageLog.Close()
+		t.usageLog = nil
+	}
+
+	opts := t.config.GetUsageLogOptions()
+	if opts.Clock == nil {
+		opts.Clock = configClock{t.config}
+	}
+
+	logger, err := NewUsageLogger(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	t.usageLog = logger
+	return logger, nil
+}
+
+// Close shuts the tracker down, see CloseCtx
+func (t *DefaultTokenTracker) Close() error {
+	return t.CloseCtx(context.Background())
+}
+
+// CloseCtx shuts the tracker down: it stops the pricing updater and usage pruning,
+// tracks the calls queued by TrackUsageAsync, closes the sinks, flushes the usage store,
+// flushes and closes the usage log and closes the registered SDK clients that implement
+// io.Closer.
+// When ctx is done before the queued calls are tracked, CloseCtx returns its error and
+// they are still tracked in the background. The later steps run even if one fails;
+// the first error is returned.
+func (t *DefaultTokenTracker) CloseCtx(ctx context.Context) error {
+	t.StopPricingUpdater()
+	if t.retention != nil {
+		t.retention.close()
+	}
+	t.pricing.mu.Lock()
+	feed := t.pricing.feed
+	t.pricing.mu.Unlock()
+	if feed != nil {
+		// Automatic pricing updates of the configuration no longer update this tracker
+		t.config.setPricingUpdater(nil)
+	}
+
+	t.mu.RLock()
+	async := t.async
+	t.mu.RUnlock()
+	if async != nil {
+		if err := waitCtx(ctx, async.close); err != nil {
+			return err
+		}
+	}
+
+	var errs []error
+	errs = append(errs, t.closeSinks(), t.flushStore(ctx))
+
+	t.mu.Lock()
+	logger := t.usageLog
+	t.usageLog = nil
+	clients := t.sdkClients
+	t.sdkClients = make(map[string]SDKClient)
+	t.mu.Unlock()
+
+	if logger != nil {
+		errs = append(errs, logger.Close())
+	}
+	for _, client := range clients {
+		if closer, ok := client.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, NewError(ErrCloseFailed, fmt.Sprintf("failed to close SDK client: %s", client.GetProviderName()), err))
+			}
+		}
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitCtx runs fn and waits for it to return or ctx to be done
+func waitCtx(ctx context.Context, fn func()) error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // Error constants for SDK client operations
 const (
 	ErrPricingUpdateFailed = "pricing_update_failed"
+	ErrModelRefreshFailed  = "model_refresh_failed"
 )
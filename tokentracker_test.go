@@ -1,6 +1,8 @@
 package tokentracker
 
 import (
+	"context"
+	"errors"
 	"testing"
 	"time"
 )
@@ -41,14 +43,11 @@ func (p *MockProvider) SetSDKClient(client interface{}) {
 }
 
 // GetModelInfo returns information about a specific model
-func (p *MockProvider) GetModelInfo(model string) (interface{}, error) {
+func (p *MockProvider) GetModelInfo(model string) (ModelInfo, error) {
 	if model != p.supportedModel {
-		return nil, NewError(ErrInvalidModel, "unsupported model", nil)
+		return ModelInfo{}, NewError(ErrInvalidModel, "unsupported model", nil)
 	}
-	return map[string]interface{}{
-		"name":     model,
-		"provider": p.name,
-	}, nil
+	return ModelInfo{Name: model, Provider: p.name}, nil
 }
 
 // ExtractTokenUsageFromResponse extracts token usage from a provider response
@@ -235,6 +234,19 @@ func TestDefaultTokenTracker_CalculatePrice(t *testing.T) {
 	}
 }
 
+func TestPrice_WithUnitPrices(t *testing.T) {
+	price := Price{InputCost: 0.03, OutputCost: 0.06, TotalCost: 0.09, Currency: "USD"}.WithUnitPrices(1000, 1000)
+	if price.EffectiveInputPricePer1K != 0.03 || price.EffectiveOutputPricePer1K != 0.06 || price.BlendedPricePer1K != 0.045 {
+		t.Errorf("unit prices = %+v", price)
+	}
+
+	// Without output tokens there is no output unit price
+	price = Price{InputCost: 0.002, TotalCost: 0.002}.WithUnitPrices(500, 0)
+	if price.EffectiveInputPricePer1K != 0.004 || price.EffectiveOutputPricePer1K != 0 || price.BlendedPricePer1K != 0.004 {
+		t.Errorf("unit prices = %+v", price)
+	}
+}
+
 func TestDefaultTokenTracker_TrackUsage(t *testing.T) {
 	// Create a new configuration
 	config := NewConfig()
@@ -332,7 +344,229 @@ func TestDefaultTokenTracker_TrackUsage(t *testing.T) {
 	}
 }
 
+func TestDefaultTokenTracker_TrackUsageResponse(t *testing.T) {
+	tracker := newPricedTestTracker(t)
+	tracker.Config().SetModelPricing("acme", "acme-1", ModelPricing{InputPricePerToken: 0.001, OutputPricePerToken: 0.002, Currency: "USD"})
+	tracker.RegisterProvider(&embeddingProvider{newPricedProvider(tracker.Config(), "acme", "acme-1")})
+
+	callParams := CallParams{
+		Model:  "acme-1",
+		Params: TokenCountParams{Text: stringPtr("four words of input")},
+	}
+
+	// The usage reported in the response is used
+	metrics, err := tracker.TrackUsage(callParams, map[string]int{"tokens": 40})
+	if err != nil {
+		t.Fatalf("TrackUsage() error = %v", err)
+	}
+	if metrics.TokenCount.InputTokens != 40 || metrics.TokenCount.TotalTokens != 40 || metrics.Price.InputCost != 0.04 {
+		t.Errorf("TrackUsage() = %+v, want the reported usage", metrics)
+	}
+
+	// Responses without usage fall back to counting the input
+	metrics, err = tracker.TrackUsage(callParams, "no usage here")
+	if err != nil {
+		t.Fatalf("TrackUsage() error = %v", err)
+	}
+	if metrics.TokenCount.InputTokens != 4 {
+		t.Errorf("InputTokens = %d, want the counted input", metrics.TokenCount.InputTokens)
+	}
+
+	// Unknown models fail with a typed error instead of panicking
+	_, err = tracker.TrackUsage(CallParams{Model: "unknown"}, map[string]int{"tokens": 40})
+	var trackerErr *TokenTrackerError
+	if !errors.As(err, &trackerErr) || trackerErr.Type != ErrProviderNotFound {
+		t.Errorf("TrackUsage() of an unknown model error = %v, want %s", err, ErrProviderNotFound)
+	}
+	if _, err := tracker.TrackReportedUsage(context.Background(), CallParams{Model: "unknown"}, TokenCount{InputTokens: 1}); !errors.As(err, &trackerErr) {
+		t.Errorf("TrackReportedUsage() of an unknown model error = %v, want a TokenTrackerError", err)
+	}
+}
+
+func TestDefaultTokenTracker_TrackUsageAttribution(t *testing.T) {
+	store := NewMemoryUsageStore()
+	tracker := NewTokenTracker(NewConfig(), WithUsageStore(store))
+	tracker.RegisterProvider(&MockProvider{
+		name:           "mock",
+		supportedModel: "mock-model",
+		tokenCount:     TokenCount{InputTokens: 10, TotalTokens: 10},
+		price:          Price{TotalCost: 0.01, Currency: "USD"},
+	})
+
+	tags := map[string]string{"feature": "search"}
+	metrics, err := tracker.TrackUsage(CallParams{
+		Model:     "mock-model",
+		Params:    TokenCountParams{Model: "mock-model", Text: stringPtr("Hello")},
+		StartTime: time.Now(),
+		Tags:      tags,
+		UserID:    "user-1",
+		ProjectID: "proj-1",
+	}, "response")
+	if err != nil {
+		t.Fatalf("TrackUsage() error = %v", err)
+	}
+	tags["feature"] = "chat"
+
+	if metrics.UserID != "user-1" || metrics.ProjectID != "proj-1" || metrics.Tags["feature"] != "search" {
+		t.Errorf("metrics = %+v, want the call's attribution", metrics)
+	}
+
+	records, _ := store.Query(UsageFilter{ProjectID: "proj-1", Tags: map[string]string{"feature": "search"}})
+	if len(records) != 1 || records[0].UserID != "user-1" {
+		t.Errorf("records = %+v, want the attributed call", records)
+	}
+}
+
+func TestDefaultTokenTracker_ContextAware(t *testing.T) {
+	config := NewConfig()
+	tracker := NewTokenTracker(config)
+	tracker.RegisterProvider(&MockProvider{
+		name:           "mock-provider",
+		supportedModel: "mock-model",
+		tokenCount:     TokenCount{InputTokens: 10, TotalTokens: 10},
+		price:          Price{TotalCost: 0.001, Currency: "USD"},
+	})
+
+	callParams := CallParams{
+		Model:     "mock-model",
+		Params:    TokenCountParams{Model: "mock-model", Text: stringPtr("Test text")},
+		StartTime: time.Now(),
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := tracker.CountTokensCtx(canceled, callParams.Params); !errors.Is(err, context.Canceled) {
+		t.Errorf("CountTokensCtx() with canceled context error = %v, want context.Canceled", err)
+	}
+	if _, err := tracker.TrackUsageCtx(canceled, callParams, "response"); !errors.Is(err, context.Canceled) {
+		t.Errorf("TrackUsageCtx() with canceled context error = %v, want context.Canceled", err)
+	}
+
+	ctx := ContextWithTraceparent(context.Background(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	metrics, err := tracker.TrackUsageCtx(ctx, callParams, "response")
+	if err != nil {
+		t.Fatalf("TrackUsageCtx() error = %v", err)
+	}
+	if metrics.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || metrics.SpanID != "00f067aa0ba902b7" {
+		t.Errorf("TrackUsageCtx() trace = %s/%s, want the trace context from ctx", metrics.TraceID, metrics.SpanID)
+	}
+}
+
+// closableClient is an SDK client that records being closed
+type closableClient struct {
+	mockListerClient
+	closed int
+}
+
+func (c *closableClient) Close() error {
+	c.closed++
+	return nil
+}
+
+// flushingStore is a usage store that records being flushed
+type flushingStore struct {
+	*MemoryUsageStore
+	flushes int
+}
+
+func (s *flushingStore) Flush() error {
+	s.flushes++
+	return nil
+}
+
+func TestDefaultTokenTracker_CloseCtx(t *testing.T) {
+	store := &flushingStore{MemoryUsageStore: NewMemoryUsageStore()}
+	tracker := NewTokenTracker(NewConfig(), WithUsageStore(store))
+	tracker.RegisterProvider(&MockProvider{name: "mock", supportedModel: "mock-1"})
+	client := &closableClient{mockListerClient: mockListerClient{provider: "mock"}}
+	if err := tracker.RegisterSDKClient(client); err != nil {
+		t.Fatalf("RegisterSDKClient() error = %v", err)
+	}
+	if err := tracker.StartPricingUpdater(context.Background(), time.Hour); err != nil {
+		t.Fatalf("StartPricingUpdater() error = %v", err)
+	}
+
+	if err := tracker.CloseCtx(context.Background()); err != nil {
+		t.Fatalf("CloseCtx() error = %v", err)
+	}
+	if client.closed != 1 || store.flushes != 1 {
+		t.Errorf("client closed %d times, store flushed %d times, want once each", client.closed, store.flushes)
+	}
+	// The pricing updater was stopped, so it can be started again
+	if err := tracker.StartPricingUpdater(context.Background(), time.Hour); err != nil {
+		t.Errorf("StartPricingUpdater() after CloseCtx error = %v", err)
+	}
+
+	if err := tracker.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if client.closed != 1 {
+		t.Errorf("client closed %d times, want SDK clients closed once", client.closed)
+	}
+}
+
+func TestDefaultTokenTracker_CloseCtxDeadline(t *testing.T) {
+	tracker, provider, store := newAsyncTestTracker(AsyncOptions{Workers: 1}, SystemClock)
+	params := CallParams{Model: "mock-model", Params: TokenCountParams{Text: stringPtr("Hello")}, StartTime: time.Now()}
+	if err := tracker.TrackUsageAsync(params, nil); err != nil {
+		t.Fatalf("TrackUsageAsync() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := tracker.CloseCtx(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("CloseCtx() error = %v, want context.DeadlineExceeded while calls are queued", err)
+	}
+
+	// The queued call is still tracked
+	close(provider.release)
+	if err := tracker.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if records, _ := store.Query(UsageFilter{}); len(records) != 1 {
+		t.Errorf("tracked %d calls, want the queued call", len(records))
+	}
+}
+
 // Helper function to create a string pointer
 func stringPtr(s string) *string {
 	return &s
 }
+
+func BenchmarkDefaultTokenTracker_TrackUsageParallel(b *testing.B) {
+	tracker := newPricedTestTracker(b, WithUsageObserver(NewPrometheusExporter(PrometheusOptions{})))
+	tracker.RegisterProvider(&reportingProvider{newPricedProvider(tracker.Config(), "acme", "acme-1")})
+	response := TokenCount{InputTokens: 100, ResponseTokens: 10}
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := tracker.TrackUsage(CallParams{Model: "acme-1"}, response); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+}
+
+func TestDefaultTokenTracker_TrackUsageCountedKeepsCountFields(t *testing.T) {
+	tracker := newPricedTestTracker(t)
+	tracker.Config().SetModelPricing("acme", "acme-1", ModelPricing{InputPricePerToken: 0.00001, OutputPricePerToken: 0.00003, Currency: "USD"})
+	tracker.RegisterProvider(&estimatingProvider{
+		usagePricedProvider: newPricedProvider(tracker.Config(), "acme", "acme-1"),
+		count:               TokenCount{InputTokens: 1000, ResponseTokens: 300, AudioTokens: 200, VideoTokens: 100, CachedInputTokens: 50, Normalization: "nfc"},
+	})
+
+	// Without a response the input is counted and the response estimated
+	metrics, err := tracker.TrackUsage(CallParams{Model: "acme-1", Params: TokenCountParams{Text: stringPtr("Describe the clip")}}, nil)
+	if err != nil {
+		t.Fatalf("TrackUsage() error = %v", err)
+	}
+	count := metrics.TokenCount
+	if count.InputTokens != 1000 || count.ResponseTokens != 300 || count.TotalTokens != 1300 {
+		t.Errorf("TokenCount = %+v, want 1000 input and 300 estimated response tokens", count)
+	}
+	if count.AudioTokens != 200 || count.VideoTokens != 100 || count.CachedInputTokens != 50 || count.Normalization != "nfc" {
+		t.Errorf("TokenCount = %+v, want the media, cache and normalization fields of the input count", count)
+	}
+}
@@ -0,0 +1,193 @@
+// Package tokentrackertest provides utilities for testing code that uses tokentracker
+package tokentrackertest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker/common"
+)
+
+// FakeClock is a common.Clock whose time only moves when advanced.
+// Tickers and timers fire synchronously from Advance and Set.
+type FakeClock struct {
+	now     time.Time
+	waiters []*fakeWaiter
+	mu      sync.Mutex
+}
+
+// fakeWaiter is a pending timer or ticker
+type fakeWaiter struct {
+	clock    *FakeClock
+	deadline time.Time
+	interval time.Duration // ticker interval (0 for timers)
+	fn       func()
+	ch       chan time.Time
+	active   bool
+}
+
+// NewFakeClock creates a fake clock set to the given time
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the fake current time
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Since returns the fake time elapsed since t
+func (c *FakeClock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// NewTicker returns a ticker that ticks each time the clock passes an interval
+func (c *FakeClock) NewTicker(d time.Duration) common.Ticker {
+	if d <= 0 {
+		panic("tokentrackertest: non-positive interval for NewTicker")
+	}
+	return fakeTicker{c.schedule(d, d, nil)}
+}
+
+// AfterFunc calls f once the clock has been advanced by d
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) common.Timer {
+	return c.schedule(d, 0, f)
+}
+
+// Advance moves the clock forward by d, firing due timers and tickers in order
+func (c *FakeClock) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+// Set moves the clock to t, firing due timers and tickers in order
+func (c *FakeClock) Set(t time.Time) {
+	for {
+		c.mu.Lock()
+		w := c.nextDue(t)
+		if w == nil {
+			if t.After(c.now) {
+				c.now = t
+			}
+			c.mu.Unlock()
+			return
+		}
+
+		c.now = w.deadline
+		fn, ch, at := w.fn, w.ch, w.deadline
+		if w.interval > 0 {
+			w.deadline = w.deadline.Add(w.interval)
+		} else {
+			w.active = false
+		}
+		c.mu.Unlock()
+
+		if fn != nil {
+			fn()
+		}
+		if ch != nil {
+			// Like time.Ticker, drop ticks the receiver is not ready for
+			select {
+			case ch <- at:
+			default:
+			}
+		}
+	}
+}
+
+// Waiters returns the number of active timers and tickers,
+// useful to wait until a goroutine has scheduled its next tick
+func (c *FakeClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := 0
+	for _, w := range c.waiters {
+		if w.active {
+			n++
+		}
+	}
+	return n
+}
+
+// schedule registers a waiter firing after d
+func (c *FakeClock) schedule(d, interval time.Duration, fn func()) *fakeWaiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	w := &fakeWaiter{
+		clock:    c,
+		deadline: c.now.Add(d),
+		interval: interval,
+		fn:       fn,
+		active:   true,
+	}
+	if fn == nil {
+		w.ch = make(chan time.Time, 1)
+	}
+	c.waiters = append(c.waiters, w)
+	return w
+}
+
+// nextDue returns the active waiter with the earliest deadline not after t
+func (c *FakeClock) nextDue(t time.Time) *fakeWaiter {
+	active := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.active {
+			active = append(active, w)
+		}
+	}
+	c.waiters = active
+
+	sort.SliceStable(c.waiters, func(i, j int) bool {
+		return c.waiters[i].deadline.Before(c.waiters[j].deadline)
+	})
+	if len(c.waiters) > 0 && !c.waiters[0].deadline.After(t) {
+		return c.waiters[0]
+	}
+	return nil
+}
+
+// fakeTicker adapts a waiter to common.Ticker
+type fakeTicker struct {
+	*fakeWaiter
+}
+
+// Chan returns the channel on which ticks are delivered
+func (t fakeTicker) Chan() <-chan time.Time {
+	return t.ch
+}
+
+// Stop turns off the ticker
+func (t fakeTicker) Stop() {
+	t.fakeWaiter.Stop()
+}
+
+// Stop prevents the timer from firing
+func (w *fakeWaiter) Stop() bool {
+	w.clock.mu.Lock()
+	defer w.clock.mu.Unlock()
+
+	wasActive := w.active
+	w.active = false
+	return wasActive
+}
+
+// Reset reschedules the timer to fire after d
+func (w *fakeWaiter) Reset(d time.Duration) bool {
+	w.clock.mu.Lock()
+	defer w.clock.mu.Unlock()
+
+	wasActive := w.active
+	w.deadline = w.clock.now.Add(d)
+	w.active = true
+	for _, pending := range w.clock.waiters {
+		if pending == w {
+			return wasActive
+		}
+	}
+	w.clock.waiters = append(w.clock.waiters, w)
+	return wasActive
+}
@@ -0,0 +1,74 @@
+package tokentrackertest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClock_Advance(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	if !clock.Now().Equal(start) {
+		t.Fatalf("Now() = %v, want %v", clock.Now(), start)
+	}
+
+	fired := 0
+	timer := clock.AfterFunc(time.Minute, func() { fired++ })
+
+	clock.Advance(30 * time.Second)
+	if fired != 0 {
+		t.Error("timer fired early")
+	}
+	if got := clock.Since(start); got != 30*time.Second {
+		t.Errorf("Since() = %v, want 30s", got)
+	}
+
+	clock.Advance(30 * time.Second)
+	if fired != 1 {
+		t.Errorf("timer fired %d times, want 1", fired)
+	}
+
+	// A reset timer fires again; a stopped one does not
+	timer.Reset(time.Minute)
+	clock.Advance(time.Minute)
+	if fired != 2 {
+		t.Errorf("reset timer fired %d times, want 2", fired)
+	}
+	timer.Reset(time.Minute)
+	if !timer.Stop() {
+		t.Error("Stop() of a pending timer = false, want true")
+	}
+	clock.Advance(time.Hour)
+	if fired != 2 {
+		t.Errorf("stopped timer fired")
+	}
+}
+
+func TestFakeClock_Ticker(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	ticker := clock.NewTicker(time.Minute)
+	clock.Advance(time.Minute)
+
+	select {
+	case tick := <-ticker.Chan():
+		if !tick.Equal(start.Add(time.Minute)) {
+			t.Errorf("tick = %v, want %v", tick, start.Add(time.Minute))
+		}
+	default:
+		t.Fatal("ticker did not tick")
+	}
+
+	ticker.Stop()
+	clock.Advance(time.Hour)
+	select {
+	case <-ticker.Chan():
+		t.Error("stopped ticker ticked")
+	default:
+	}
+	if clock.Waiters() != 0 {
+		t.Errorf("Waiters() = %d, want 0", clock.Waiters())
+	}
+}
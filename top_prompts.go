@@ -0,0 +1,342 @@
+package tokentracker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Default top prompts settings
+const (
+	DefaultTopPromptsWindow     = 15 * time.Minute
+	DefaultTopPromptsBuckets    = 15
+	DefaultTopPromptsK          = 10
+	DefaultTopPromptsMaxPrompts = 1000
+	DefaultPromptExampleLength  = 200
+)
+
+// TopPromptsOptions configures the sliding-window top-K expensive prompts tracker
+type TopPromptsOptions struct {
+	// Window is the length of the sliding window
+	Window time.Duration
+
+	// Buckets is the number of time buckets the window is divided into
+	Buckets int
+
+	// K is the number of prompts returned by Top
+	K int
+
+	// MaxPrompts bounds the number of distinct prompts kept per bucket;
+	// the cheapest prompt is evicted when a bucket is full
+	MaxPrompts int
+
+	// ExampleLength is the maximum number of characters kept as an example of each prompt
+	ExampleLength int
+
+	// Clock defines the current time of the window (nil uses the system clock)
+	Clock Clock
+}
+
+// withDefaults fills unset options with their defaults
+func (o TopPromptsOptions) withDefaults() TopPromptsOptions {
+	if o.Window <= 0 {
+		o.Window = DefaultTopPromptsWindow
+	}
+	if o.Buckets <= 0 {
+		o.Buckets = DefaultTopPromptsBuckets
+	}
+	if o.K <= 0 {
+		o.K = DefaultTopPromptsK
+	}
+	if o.MaxPrompts <= 0 {
+		o.MaxPrompts = DefaultTopPromptsMaxPrompts
+	}
+	if o.ExampleLength <= 0 {
+		o.ExampleLength = DefaultPromptExampleLength
+	}
+	if o.Clock == nil {
+		o.Clock = SystemClock
+	}
+	return o
+}
+
+// PromptStats aggregates the spend of one prompt fingerprint
+type PromptStats struct {
+	Fingerprint string
+	Calls       int
+	TotalTokens int
+	TotalCost   float64
+	Currency    string
+	Model       string // model of the most recent call
+	Provider    string // provider of the most recent call
+	Example     string // truncated example of the prompt
+	FirstSeen   time.Time
+	LastSeen    time.Time
+}
+
+// promptBucket holds the prompt stats of one time slice of the window
+type promptBucket struct {
+	start   time.Time
+	prompts map[string]*PromptStats
+}
+
+// TopPrompts keeps a memory-bounded, sliding-window top-K of prompts by cost.
+// It is safe for concurrent use.
+type TopPrompts struct {
+	opts    TopPromptsOptions
+	width   time.Duration
+	buckets []*promptBucket
+	mu      sync.Mutex
+}
+
+// NewTopPrompts creates a top-K expensive prompts tracker
+func NewTopPrompts(opts TopPromptsOptions) *TopPrompts {
+	opts = opts.withDefaults()
+
+	width := opts.Window / time.Duration(opts.Buckets)
+	if width <= 0 {
+		width = opts.Window
+	}
+
+	return &TopPrompts{
+		opts:  opts,
+		width: width,
+	}
+}
+
+// Add records the usage of a call made with the given prompt
+func (p *TopPrompts) Add(params TokenCountParams, metrics UsageMetrics) {
+	p.AddFingerprint(PromptFingerprint(params), promptText(params), metrics)
+}
+
+// AddFingerprint records the usage of a call under an explicit fingerprint
+func (p *TopPrompts) AddFingerprint(fingerprint, example string, metrics UsageMetrics) {
+	now := p.opts.Clock.Now()
+	at := metrics.Timestamp
+	if at.IsZero() {
+		at = now
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.expire(now)
+	bucket := p.bucketFor(at, now)
+	if bucket == nil {
+		return
+	}
+
+	stats, exists := bucket.prompts[fingerprint]
+	if !exists {
+		if len(bucket.prompts) >= p.opts.MaxPrompts {
+			evictCheapest(bucket.prompts)
+		}
+		stats = &PromptStats{
+			Fingerprint: fingerprint,
+			Example:     truncateRunes(example, p.opts.ExampleLength),
+			FirstSeen:   at,
+		}
+		bucket.prompts[fingerprint] = stats
+	}
+
+	stats.Calls++
+	stats.TotalTokens += metrics.TokenCount.TotalTokens
+	stats.TotalCost += metrics.Price.TotalCost
+	stats.Currency = metrics.Price.Currency
+	if !at.Before(stats.LastSeen) {
+		stats.LastSeen = at
+		stats.Model = metrics.Model
+		stats.Provider = metrics.Provider
+	}
+}
+
+// Top returns the most expensive prompts within the window, most expensive first
+func (p *TopPrompts) Top() []PromptStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.expire(p.opts.Clock.Now())
+
+	merged := make(map[string]*PromptStats)
+	for _, bucket := range p.buckets {
+		for fingerprint, stats := range bucket.prompts {
+			total, exists := merged[fingerprint]
+			if !exists {
+				copied := *stats
+				merged[fingerprint] = &copied
+				continue
+			}
+			total.Calls += stats.Calls
+			total.TotalTokens += stats.TotalTokens
+			total.TotalCost += stats.TotalCost
+			if stats.FirstSeen.Before(total.FirstSeen) {
+				total.FirstSeen = stats.FirstSeen
+			}
+			if stats.LastSeen.After(total.LastSeen) {
+				total.LastSeen = stats.LastSeen
+				total.Model = stats.Model
+				total.Provider = stats.Provider
+				total.Currency = stats.Currency
+			}
+		}
+	}
+
+	top := make([]PromptStats, 0, len(merged))
+	for _, stats := range merged {
+		top = append(top, *stats)
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].TotalCost != top[j].TotalCost {
+			return top[i].TotalCost > top[j].TotalCost
+		}
+		return top[i].Fingerprint < top[j].Fingerprint
+	})
+
+	if len(top) > p.opts.K {
+		top = top[:p.opts.K]
+	}
+	return top
+}
+
+// Reset discards all tracked prompts
+func (p *TopPrompts) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.buckets = nil
+}
+
+// expire drops buckets that have left the window
+func (p *TopPrompts) expire(now time.Time) {
+	cutoff := now.Add(-p.opts.Window)
+
+	kept := p.buckets[:0]
+	for _, bucket := range p.buckets {
+		if bucket.start.Add(p.width).After(cutoff) {
+			kept = append(kept, bucket)
+		}
+	}
+	p.buckets = kept
+}
+
+// bucketFor returns the bucket covering t, creating it when needed.
+// It returns nil when t is already outside the window ending at now.
+func (p *TopPrompts) bucketFor(t, now time.Time) *promptBucket {
+	start := t.Truncate(p.width)
+	if !start.Add(p.width).After(now.Add(-p.opts.Window)) {
+		return nil
+	}
+
+	for _, bucket := range p.buckets {
+		if bucket.start.Equal(start) {
+			return bucket
+		}
+	}
+
+	bucket := &promptBucket{start: start, prompts: make(map[string]*PromptStats)}
+	p.buckets = append(p.buckets, bucket)
+	return bucket
+}
+
+// evictCheapest removes the prompt with the lowest cost from a bucket
+func evictCheapest(prompts map[string]*PromptStats) {
+	var cheapest string
+	first := true
+	for fingerprint, stats := range prompts {
+		if first || stats.TotalCost < prompts[cheapest].TotalCost {
+			cheapest = fingerprint
+			first = false
+		}
+	}
+	delete(prompts, cheapest)
+}
+
+// PromptFingerprint returns a stable fingerprint of the prompt in params.
+// Runs of digits are masked and whitespace is collapsed, so prompts rendered from the
+// same template with different numbers or spacing share a fingerprint.
+func PromptFingerprint(params TokenCountParams) string {
+	var b strings.Builder
+	var last rune
+	for _, r := range promptText(params) {
+		switch {
+		case unicode.IsDigit(r):
+			r = '#'
+		case unicode.IsSpace(r):
+			r = ' '
+		}
+		if (r == '#' || r == ' ') && r == last {
+			continue
+		}
+		last = r
+		b.WriteRune(r)
+	}
+
+	sum := sha256.Sum256([]byte(strings.TrimSpace(b.String())))
+	return hex.EncodeToString(sum[:8])
+}
+
+// promptText flattens the text and messages of params into a single string
+func promptText(params TokenCountParams) string {
+	var parts []string
+	if params.Text != nil {
+		parts = append(parts, *params.Text)
+	}
+	if params.SystemInstruction != "" {
+		parts = append(parts, "system: "+params.SystemInstruction)
+	}
+
+	for _, message := range params.Messages {
+		switch content := message.Content.(type) {
+		case string:
+			parts = append(parts, message.Role+": "+content)
+		case []ContentPart:
+			for _, part := range content {
+				if part.Type == "text" {
+					parts = append(parts, message.Role+": "+part.Text)
+				}
+			}
+		case []interface{}:
+			for _, item := range content {
+				if part, ok := item.(map[string]interface{}); ok {
+					if text, ok := part["text"].(string); ok {
+						parts = append(parts, message.Role+": "+text)
+					}
+				}
+			}
+		}
+	}
+
+	return strings.Join(parts, "\n")
+}
+
+// truncateRunes shortens s to at most n characters
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
+
+// WithTopPrompts makes the tracker maintain a sliding-window top-K of the most expensive prompts
+func WithTopPrompts(opts TopPromptsOptions) TrackerOption {
+	return func(t *DefaultTokenTracker) {
+		if opts.Clock == nil {
+			opts.Clock = configClock{t.config}
+		}
+		t.topPrompts = NewTopPrompts(opts)
+	}
+}
+
+// TopPrompts returns the most expensive prompts in the current window, or nil
+// when the tracker was created without WithTopPrompts
+func (t *DefaultTokenTracker) TopPrompts() []PromptStats {
+	if t.topPrompts == nil {
+		return nil
+	}
+	return t.topPrompts.Top()
+}
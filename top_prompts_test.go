@@ -0,0 +1,131 @@
+package tokentracker
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPromptFingerprint(t *testing.T) {
+	a := PromptFingerprint(TokenCountParams{Text: stringPtr("Summarize order 1234  for customer 42")})
+	b := PromptFingerprint(TokenCountParams{Text: stringPtr("Summarize order 98765 for customer 7")})
+	c := PromptFingerprint(TokenCountParams{Text: stringPtr("Translate order 1234 for customer 42")})
+
+	if a != b {
+		t.Errorf("prompts from the same template have different fingerprints: %s != %s", a, b)
+	}
+	if a == c {
+		t.Error("different prompts share a fingerprint")
+	}
+
+	messages := PromptFingerprint(TokenCountParams{Messages: []Message{
+		{Role: "system", Content: "You are helpful"},
+		{Role: "user", Content: []ContentPart{{Type: "text", Text: "Hi"}}},
+	}})
+	if messages == PromptFingerprint(TokenCountParams{}) {
+		t.Error("message content is not part of the fingerprint")
+	}
+}
+
+func TestTopPrompts_Top(t *testing.T) {
+	top := NewTopPrompts(TopPromptsOptions{K: 2, ExampleLength: 9})
+	now := time.Now()
+
+	add := func(prompt string, cost float64) {
+		top.Add(TokenCountParams{Text: stringPtr(prompt)}, UsageMetrics{
+			TokenCount: TokenCount{TotalTokens: 10},
+			Price:      Price{TotalCost: cost, Currency: "USD"},
+			Model:      "gpt-4",
+			Provider:   "openai",
+			Timestamp:  now,
+		})
+	}
+	add("cheap prompt", 0.01)
+	add("expensive prompt 1", 0.5)
+	add("expensive prompt 2", 0.5)
+	add("medium prompt", 0.2)
+
+	got := top.Top()
+	if len(got) != 2 {
+		t.Fatalf("len(Top()) = %d, want 2", len(got))
+	}
+	if got[0].Calls != 2 || math.Abs(got[0].TotalCost-1.0) > 1e-9 || got[0].TotalTokens != 20 {
+		t.Errorf("Top()[0] = %+v, want 2 calls costing 1.0", got[0])
+	}
+	if got[0].Example != "expensive" {
+		t.Errorf("Top()[0].Example = %q, want truncated example", got[0].Example)
+	}
+	if got[1].TotalCost != 0.2 {
+		t.Errorf("Top()[1].TotalCost = %v, want 0.2", got[1].TotalCost)
+	}
+}
+
+func TestTopPrompts_SlidingWindow(t *testing.T) {
+	top := NewTopPrompts(TopPromptsOptions{Window: time.Hour, Buckets: 4})
+
+	old := UsageMetrics{Price: Price{TotalCost: 10}, Timestamp: time.Now().Add(-2 * time.Hour)}
+	top.Add(TokenCountParams{Text: stringPtr("old prompt")}, old)
+
+	recent := UsageMetrics{Price: Price{TotalCost: 1}, Timestamp: time.Now()}
+	top.Add(TokenCountParams{Text: stringPtr("recent prompt")}, recent)
+
+	got := top.Top()
+	if len(got) != 1 || got[0].TotalCost != 1 {
+		t.Errorf("Top() = %+v, want only the recent prompt", got)
+	}
+}
+
+func TestTopPrompts_MemoryBound(t *testing.T) {
+	top := NewTopPrompts(TopPromptsOptions{MaxPrompts: 5, K: 100})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			top.AddFingerprint(fmt.Sprintf("fp-%d", i), "", UsageMetrics{
+				Price:     Price{TotalCost: float64(i)},
+				Timestamp: time.Now(),
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	got := top.Top()
+	// Prompts are bounded per bucket; allow for the calls spanning two buckets
+	if len(got) > 10 {
+		t.Errorf("len(Top()) = %d, want at most 10", len(got))
+	}
+	if len(got) == 0 || got[0].Fingerprint != "fp-49" {
+		t.Errorf("most expensive prompt was evicted: %+v", got)
+	}
+}
+
+func TestDefaultTokenTracker_TopPrompts(t *testing.T) {
+	tracker := NewTokenTracker(NewConfig(), WithTopPrompts(TopPromptsOptions{}))
+	tracker.RegisterProvider(&MockProvider{
+		name:           "mock",
+		supportedModel: "mock-model",
+		tokenCount:     TokenCount{InputTokens: 10, TotalTokens: 10},
+		price:          Price{TotalCost: 0.01, Currency: "USD"},
+	})
+
+	for i := 0; i < 3; i++ {
+		_, _ = tracker.TrackUsage(CallParams{
+			Model:     "mock-model",
+			Params:    TokenCountParams{Model: "mock-model", Text: stringPtr(fmt.Sprintf("Classify ticket %d", i))},
+			StartTime: time.Now(),
+		}, "response")
+	}
+
+	got := tracker.TopPrompts()
+	if len(got) != 1 || got[0].Calls != 3 || got[0].Provider != "mock" {
+		t.Errorf("TopPrompts() = %+v, want a single template with 3 calls", got)
+	}
+
+	if NewTokenTracker(NewConfig()).TopPrompts() != nil {
+		t.Error("TopPrompts() should be nil when not enabled")
+	}
+}
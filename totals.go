@@ -0,0 +1,161 @@
+package tokentracker
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Totals are the usage counted by UsageTotals
+type Totals struct {
+	Calls          int64   `json:"calls"`
+	InputTokens    int64   `json:"input_tokens"`
+	ResponseTokens int64   `json:"response_tokens"`
+	TotalTokens    int64   `json:"total_tokens"`
+	TotalCost      float64 `json:"total_cost"` // in the currencies of the models' pricing
+}
+
+// ModelTotals are the totals of one provider and model
+type ModelTotals struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	Totals
+}
+
+// UsageTotals counts the calls, tokens and cost tracked per provider and model since
+// the tracker was created or last reset. The counters are atomic and sharded across
+// CPUs, so tracking never waits on a lock or contends on a cache line, and reading
+// them is cheap enough for health endpoints.
+type UsageTotals struct {
+	counters sync.Map     // metricLabels -> *totalCounters
+	since    atomic.Int64 // Unix nanoseconds of the creation or last reset
+	clock    Clock
+}
+
+// totalCounters are the sharded counters of one provider and model
+type totalCounters struct {
+	shards []totalShard
+}
+
+// totalShard is one shard of the atomic counters of a provider and model, on a cache
+// line of its own
+type totalShard struct {
+	calls          atomic.Int64
+	inputTokens    atomic.Int64
+	responseTokens atomic.Int64
+	totalTokens    atomic.Int64
+	cost           atomic.Uint64 // float64 bits
+	_              [cacheLineSize - 40]byte
+}
+
+// newUsageTotals creates counters starting now
+func newUsageTotals(clock Clock) *UsageTotals {
+	totals := &UsageTotals{clock: clock}
+	totals.since.Store(clock.Now().UnixNano())
+	return totals
+}
+
+// Totals returns the in-process usage counters of the tracker
+func (t *DefaultTokenTracker) Totals() *UsageTotals {
+	return t.totals
+}
+
+// add counts a tracked call
+func (u *UsageTotals) add(metrics UsageMetrics) {
+	key := metricLabels{provider: metrics.Provider, model: metrics.Model}
+	value, exists := u.counters.Load(key)
+	if !exists {
+		value, _ = u.counters.LoadOrStore(key, &totalCounters{shards: make([]totalShard, counterShards)})
+	}
+	counters := value.(*totalCounters)
+	shard := &counters.shards[randomShard(len(counters.shards))]
+
+	shard.calls.Add(1)
+	shard.inputTokens.Add(int64(metrics.TokenCount.InputTokens))
+	shard.responseTokens.Add(int64(metrics.TokenCount.ResponseTokens))
+	shard.totalTokens.Add(int64(metrics.TokenCount.TotalTokens))
+	for {
+		old := shard.cost.Load()
+		cost := math.Float64bits(math.Float64frombits(old) + metrics.Price.TotalCost)
+		if shard.cost.CompareAndSwap(old, cost) {
+			break
+		}
+	}
+}
+
+// load returns the current values of the counters, summed over the shards
+func (c *totalCounters) load() Totals {
+	var totals Totals
+	for i := range c.shards {
+		shard := &c.shards[i]
+		totals.Calls += shard.calls.Load()
+		totals.InputTokens += shard.inputTokens.Load()
+		totals.ResponseTokens += shard.responseTokens.Load()
+		totals.TotalTokens += shard.totalTokens.Load()
+		totals.TotalCost += math.Float64frombits(shard.cost.Load())
+	}
+	return totals
+}
+
+// Total returns the totals across all providers and models
+func (u *UsageTotals) Total() Totals {
+	var total Totals
+	u.counters.Range(func(_, value interface{}) bool {
+		totals := value.(*totalCounters).load()
+		total.Calls += totals.Calls
+		total.InputTokens += totals.InputTokens
+		total.ResponseTokens += totals.ResponseTokens
+		total.TotalTokens += totals.TotalTokens
+		total.TotalCost += totals.TotalCost
+		return true
+	})
+	return total
+}
+
+// Get returns the totals of a provider and model
+func (u *UsageTotals) Get(provider, model string) Totals {
+	value, exists := u.counters.Load(metricLabels{provider: provider, model: model})
+	if !exists {
+		return Totals{}
+	}
+	return value.(*totalCounters).load()
+}
+
+// ByModel returns the totals of every provider and model, sorted by provider and model
+func (u *UsageTotals) ByModel() []ModelTotals {
+	result := make([]ModelTotals, 0)
+	u.counters.Range(func(key, value interface{}) bool {
+		labels := key.(metricLabels)
+		result = append(result, ModelTotals{
+			Provider: labels.provider,
+			Model:    labels.model,
+			Totals:   value.(*totalCounters).load(),
+		})
+		return true
+	})
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Provider != result[j].Provider {
+			return result[i].Provider < result[j].Provider
+		}
+		return result[i].Model < result[j].Model
+	})
+	return result
+}
+
+// Since returns when counting started: the tracker's creation or the last Reset
+func (u *UsageTotals) Since() time.Time {
+	return time.Unix(0, u.since.Load())
+}
+
+// Reset sets all counters back to zero, counting from now on. Calls tracked while
+// the counters are being reset may be left uncounted.
+func (u *UsageTotals) Reset() {
+	u.counters.Range(func(key, _ interface{}) bool {
+		u.counters.Delete(key)
+		return true
+	})
+	u.since.Store(u.clock.Now().UnixNano())
+}
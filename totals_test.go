@@ -0,0 +1,106 @@
+package tokentracker
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker/tokentrackertest"
+)
+
+func TestDefaultTokenTracker_Totals(t *testing.T) {
+	clock := tokentrackertest.NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	tracker, _ := newIdempotentTracker(t, clock)
+	tracker.Config().SetModelPricing("acme", "acme-2", ModelPricing{InputPricePerToken: 0.001, OutputPricePerToken: 0.002, Currency: "USD"})
+	tracker.RegisterProvider(&responseMapProvider{newPricedProvider(tracker.Config(), "acme", "acme-1", "acme-2")})
+
+	if total := tracker.Totals().Total(); total != (Totals{}) {
+		t.Fatalf("Total() = %+v, want zero before any call", total)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			model := "acme-1"
+			if i%5 == 0 {
+				model = "acme-2"
+			}
+			response := map[string]interface{}{"usage": TokenCount{InputTokens: 100, ResponseTokens: 10}}
+			if _, err := tracker.TrackUsage(CallParams{Model: model, StartTime: clock.Now()}, response); err != nil {
+				t.Errorf("TrackUsage() error = %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	totals := tracker.Totals()
+	total := totals.Total()
+	if total.Calls != 50 || total.InputTokens != 5000 || total.ResponseTokens != 500 || total.TotalTokens != 5500 {
+		t.Errorf("Total() = %+v, want the 50 calls", total)
+	}
+	wantCost := 40*(100*0.00001+10*0.00002) + 10*(100*0.001+10*0.002)
+	if math.Abs(total.TotalCost-wantCost) > 1e-9 {
+		t.Errorf("TotalCost = %v, want %v", total.TotalCost, wantCost)
+	}
+
+	if got := totals.Get("acme", "acme-2"); got.Calls != 10 || got.InputTokens != 1000 {
+		t.Errorf("Get(acme, acme-2) = %+v, want 10 calls", got)
+	}
+	if got := totals.Get("acme", "unknown"); got != (Totals{}) {
+		t.Errorf("Get() of an untracked model = %+v", got)
+	}
+	byModel := totals.ByModel()
+	if len(byModel) != 2 || byModel[0].Model != "acme-1" || byModel[0].Calls != 40 || byModel[1].Model != "acme-2" {
+		t.Errorf("ByModel() = %+v, want both models sorted", byModel)
+	}
+
+	clock.Advance(time.Hour)
+	totals.Reset()
+	if total := totals.Total(); total != (Totals{}) || len(totals.ByModel()) != 0 {
+		t.Errorf("Total() after Reset() = %+v, want zero", total)
+	}
+	if !totals.Since().Equal(clock.Now()) {
+		t.Errorf("Since() = %v, want the time of the reset", totals.Since())
+	}
+
+	tracker.TrackUsage(CallParams{Model: "acme-1", StartTime: clock.Now()}, map[string]interface{}{"usage": TokenCount{InputTokens: 1, ResponseTokens: 1}})
+	if total := totals.Total(); total.Calls != 1 {
+		t.Errorf("Total() = %+v, want counting to resume after Reset()", total)
+	}
+}
+
+func TestUsageTotals_ShardedCounters(t *testing.T) {
+	totals := newUsageTotals(SystemClock)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				totals.add(UsageMetrics{Provider: "acme", Model: "acme-1", TokenCount: TokenCount{InputTokens: 3, ResponseTokens: 1, TotalTokens: 4}, Price: Price{TotalCost: 0.5}})
+			}
+		}()
+	}
+	wg.Wait()
+
+	want := Totals{Calls: 16000, InputTokens: 48000, ResponseTokens: 16000, TotalTokens: 64000, TotalCost: 8000}
+	if got := totals.Get("acme", "acme-1"); got != want {
+		t.Errorf("Get() = %+v, want %+v", got, want)
+	}
+}
+
+func BenchmarkUsageTotals_AddParallel(b *testing.B) {
+	totals := newUsageTotals(SystemClock)
+	metrics := UsageMetrics{Provider: "acme", Model: "acme-1", TokenCount: TokenCount{InputTokens: 100, ResponseTokens: 10, TotalTokens: 110}, Price: Price{TotalCost: 0.001}}
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			totals.add(metrics)
+		}
+	})
+}
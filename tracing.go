@@ -0,0 +1,78 @@
+package tokentracker
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/TrustSight-io/tokentracker/common"
+)
+
+// TraceparentHeader is the W3C Trace Context header name
+const TraceparentHeader = "traceparent"
+
+// TraceContext contains the identifiers from a W3C traceparent header, see common.TraceContext
+type TraceContext = common.TraceContext
+
+// ParseTraceparent parses a W3C traceparent header value
+// (e.g. "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+func ParseTraceparent(header string) (TraceContext, error) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 {
+		return TraceContext{}, NewError(ErrInvalidParams, fmt.Sprintf("malformed traceparent: %q", header), nil)
+	}
+
+	version, traceID, spanID, flags := parts[0], strings.ToLower(parts[1]), strings.ToLower(parts[2]), parts[3]
+
+	// Version ff is forbidden and version 00 must have exactly four fields
+	if !isHex(version, 2) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return TraceContext{}, NewError(ErrInvalidParams, fmt.Sprintf("unsupported traceparent version: %q", version), nil)
+	}
+	if !isHex(traceID, 32) || traceID == strings.Repeat("0", 32) {
+		return TraceContext{}, NewError(ErrInvalidParams, fmt.Sprintf("invalid trace id: %q", traceID), nil)
+	}
+	if !isHex(spanID, 16) || spanID == strings.Repeat("0", 16) {
+		return TraceContext{}, NewError(ErrInvalidParams, fmt.Sprintf("invalid span id: %q", spanID), nil)
+	}
+	if !isHex(flags, 2) {
+		return TraceContext{}, NewError(ErrInvalidParams, fmt.Sprintf("invalid trace flags: %q", flags), nil)
+	}
+
+	flagBytes, _ := hex.DecodeString(flags)
+
+	return TraceContext{
+		TraceID: traceID,
+		SpanID:  spanID,
+		Sampled: flagBytes[0]&0x01 == 0x01,
+	}, nil
+}
+
+// ContextWithTraceContext returns a copy of ctx carrying the given trace context
+func ContextWithTraceContext(ctx context.Context, tc TraceContext) context.Context {
+	return common.ContextWithTraceContext(ctx, tc)
+}
+
+// ContextWithTraceparent parses a traceparent header and stores the result in ctx.
+// Invalid headers are ignored and ctx is returned unchanged.
+func ContextWithTraceparent(ctx context.Context, header string) context.Context {
+	tc, err := ParseTraceparent(header)
+	if err != nil {
+		return ctx
+	}
+	return ContextWithTraceContext(ctx, tc)
+}
+
+// TraceContextFromContext returns the trace context stored in ctx, if any
+func TraceContextFromContext(ctx context.Context) (TraceContext, bool) {
+	return common.TraceContextFromContext(ctx)
+}
+
+// isHex checks that s is a lowercase/uppercase hex string of the given length
+func isHex(s string, length int) bool {
+	if len(s) != length {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,98 @@
+package tokentracker
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		want    TraceContext
+		wantErr bool
+	}{
+		{
+			name:   "Valid sampled",
+			header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			want: TraceContext{
+				TraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+				SpanID:  "00f067aa0ba902b7",
+				Sampled: true,
+			},
+		},
+		{
+			name:   "Valid not sampled, uppercase",
+			header: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00F067AA0BA902B7-00",
+			want: TraceContext{
+				TraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+				SpanID:  "00f067aa0ba902b7",
+				Sampled: false,
+			},
+		},
+		{
+			name:    "Too few fields",
+			header:  "00-4bf92f3577b34da6a3ce929d0e0e4736",
+			wantErr: true,
+		},
+		{
+			name:    "All-zero trace id",
+			header:  "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+			wantErr: true,
+		},
+		{
+			name:    "Forbidden version",
+			header:  "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			wantErr: true,
+		},
+		{
+			name:    "Short span id",
+			header:  "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa-01",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTraceparent(tt.header)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTraceparent() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseTraceparent() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTraceContext_RoundTrip(t *testing.T) {
+	header := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	tc, err := ParseTraceparent(header)
+	if err != nil {
+		t.Fatalf("ParseTraceparent() error = %v", err)
+	}
+	if got := tc.Traceparent(); got != header {
+		t.Errorf("Traceparent() = %v, want %v", got, header)
+	}
+}
+
+func TestUsageMetrics_ApplyTraceContext(t *testing.T) {
+	ctx := ContextWithTraceparent(context.Background(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	var metrics UsageMetrics
+	metrics.ApplyTraceContext(ctx)
+
+	if metrics.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("TraceID = %v, want 4bf92f3577b34da6a3ce929d0e0e4736", metrics.TraceID)
+	}
+	if metrics.SpanID != "00f067aa0ba902b7" {
+		t.Errorf("SpanID = %v, want 00f067aa0ba902b7", metrics.SpanID)
+	}
+
+	// An invalid header leaves the context (and metrics) untouched
+	var untouched UsageMetrics
+	untouched.ApplyTraceContext(ContextWithTraceparent(context.Background(), "garbage"))
+	if untouched.TraceID != "" || untouched.SpanID != "" {
+		t.Errorf("ApplyTraceContext() with invalid header set IDs: %+v", untouched)
+	}
+}
@@ -0,0 +1,95 @@
+package tokentracker
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// ExportFormat identifies the file format of exported usage
+type ExportFormat string
+
+// Supported export formats
+const (
+	// ExportCSV writes a CSV file with the UsageCSVHeader columns, which ImportUsage reads back
+	ExportCSV ExportFormat = "csv"
+
+	// ExportParquet writes an uncompressed Parquet file with the UsageCSVHeader columns
+	ExportParquet ExportFormat = "parquet"
+)
+
+// ExportUsage writes the stored usage matching filter to w, e.g. a month of usage for
+// a finance pipeline. Costs are written in the currency they were recorded in.
+func (t *DefaultTokenTracker) ExportUsage(w io.Writer, format ExportFormat, filter UsageFilter) error {
+	records, err := t.GetUsage(filter)
+	if err != nil {
+		return err
+	}
+	return WriteUsage(w, format, records)
+}
+
+// WriteUsage writes usage records in the given format
+func WriteUsage(w io.Writer, format ExportFormat, records []UsageMetrics) error {
+	switch format {
+	case ExportCSV:
+		return writeUsageCSV(w, records)
+	case ExportParquet:
+		return writeUsageParquet(w, records)
+	default:
+		return NewError(ErrInvalidParams, fmt.Sprintf("unknown export format: %s", format), nil)
+	}
+}
+
+// usageRow returns the UsageCSVHeader values of a record as strings, int64s and float64s
+func usageRow(m UsageMetrics) []interface{} {
+	tags := ""
+	if len(m.Tags) > 0 {
+		// A map of strings always encodes
+		data, _ := json.Marshal(m.Tags)
+		tags = string(data)
+	}
+
+	return []interface{}{
+		m.Timestamp.UTC(), m.Provider, m.Model,
+		int64(m.TokenCount.InputTokens), int64(m.TokenCount.ResponseTokens), int64(m.TokenCount.TotalTokens), int64(m.TokenCount.CachedInputTokens),
+		m.Price.InputCost, m.Price.OutputCost, m.Price.TotalCost, m.Price.Currency,
+		m.Duration.Milliseconds(), m.UserID, m.ProjectID, m.CostTier, m.TraceID, m.SpanID, tags,
+		m.APIKeyID, m.Organization, m.ProviderProjectID, m.CompletionID, int64(m.SampleRate),
+	}
+}
+
+// writeUsageCSV writes records as CSV
+func writeUsageCSV(w io.Writer, records []UsageMetrics) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(UsageCSVHeader); err != nil {
+		return NewError(ErrStorageFailed, "failed to write usage export", err)
+	}
+
+	fields := make([]string, len(UsageCSVHeader))
+	for _, record := range records {
+		for i, value := range usageRow(record) {
+			switch v := value.(type) {
+			case time.Time:
+				fields[i] = v.Format(time.RFC3339Nano)
+			case int64:
+				fields[i] = strconv.FormatInt(v, 10)
+			case float64:
+				fields[i] = strconv.FormatFloat(v, 'f', -1, 64)
+			case string:
+				fields[i] = v
+			}
+		}
+		if err := writer.Write(fields); err != nil {
+			return NewError(ErrStorageFailed, "failed to write usage export", err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return NewError(ErrStorageFailed, "failed to write usage export", err)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package tokentracker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func exportSample() []UsageMetrics {
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	first := sampleUsage("gpt-4", "openai", base, 0.5)
+	first.TokenCount.CachedInputTokens = 4
+	first.UserID = "user-1"
+	first.Tags = map[string]string{"feature": "search, beta"}
+	second := sampleUsage("claude-3-haiku", "anthropic", base.Add(time.Hour), 0.25)
+	return []UsageMetrics{first, second}
+}
+
+func TestWriteUsage_CSVRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteUsage(&buf, ExportCSV, exportSample()); err != nil {
+		t.Fatalf("WriteUsage() error = %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), strings.Join(UsageCSVHeader, ",")+"\n") {
+		t.Errorf("export does not start with the header: %q", buf.String())
+	}
+
+	records, err := ReadUsage(UsageImportCSV, &buf)
+	if err != nil {
+		t.Fatalf("ReadUsage() error = %v", err)
+	}
+	want := exportSample()
+	if len(records) != len(want) {
+		t.Fatalf("read %d records, want %d", len(records), len(want))
+	}
+	for i, record := range records {
+		if !record.Timestamp.Equal(want[i].Timestamp) || record.Model != want[i].Model || record.TokenCount != want[i].TokenCount ||
+			record.Price.TotalCost != want[i].Price.TotalCost || record.Duration != want[i].Duration {
+			t.Errorf("record %d = %+v, want %+v", i, record, want[i])
+		}
+	}
+	if records[0].Tags["feature"] != "search, beta" || records[0].UserID != "user-1" {
+		t.Errorf("attribution = %+v", records[0])
+	}
+
+	if err := WriteUsage(&buf, "xlsx", nil); err == nil {
+		t.Error("WriteUsage() with an unknown format should fail")
+	}
+}
+
+// thriftValue decodes one Thrift compact value of the given type
+func thriftValue(t *testing.T, r *bytes.Reader, typ byte) interface{} {
+	switch typ {
+	case thriftI32, thriftI64:
+		v, _ := binary.ReadUvarint(r)
+		return int64(v>>1) ^ -int64(v&1)
+	case thriftBinary:
+		n, _ := binary.ReadUvarint(r)
+		data := make([]byte, n)
+		_, _ = r.Read(data)
+		return string(data)
+	case thriftList:
+		header, _ := r.ReadByte()
+		n, elem := int(header>>4), header&0x0f
+		if n == 15 {
+			size, _ := binary.ReadUvarint(r)
+			n = int(size)
+		}
+		list := make([]interface{}, n)
+		for i := range list {
+			list[i] = thriftValue(t, r, elem)
+		}
+		return list
+	case thriftStruct:
+		fields := map[int16]interface{}{}
+		var id int16
+		for {
+			header, _ := r.ReadByte()
+			if header == 0 {
+				return fields
+			}
+			if delta := int16(header >> 4); delta != 0 {
+				id += delta
+			} else {
+				v, _ := binary.ReadUvarint(r)
+				id = int16(int64(v>>1) ^ -int64(v&1))
+			}
+			fields[id] = thriftValue(t, r, header&0x0f)
+		}
+	}
+	t.Fatalf("unexpected thrift type %d", typ)
+	return nil
+}
+
+func TestWriteUsage_Parquet(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteUsage(&buf, ExportParquet, exportSample()); err != nil {
+		t.Fatalf("WriteUsage() error = %v", err)
+	}
+	data := buf.Bytes()
+	if string(data[:4]) != parquetMagic || string(data[len(data)-4:]) != parquetMagic {
+		t.Fatal("export is not framed by the Parquet magic")
+	}
+
+	size := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	meta := thriftValue(t, bytes.NewReader(data[len(data)-8-size:len(data)-8]), thriftStruct).(map[int16]interface{})
+	if meta[3] != int64(2) {
+		t.Errorf("num_rows = %v, want 2", meta[3])
+	}
+	schema := meta[2].([]interface{})
+	if len(schema) != len(UsageCSVHeader)+1 {
+		t.Fatalf("schema has %d elements, want the root and %d columns", len(schema), len(UsageCSVHeader))
+	}
+	for i, name := range UsageCSVHeader {
+		if got := schema[i+1].(map[int16]interface{})[4]; got != name {
+			t.Errorf("column %d = %v, want %s", i, got, name)
+		}
+	}
+
+	chunks := meta[4].([]interface{})[0].(map[int16]interface{})[1].([]interface{})
+	column := func(name string) *bytes.Reader {
+		for i, header := range UsageCSVHeader {
+			if header == name {
+				offset := chunks[i].(map[int16]interface{})[3].(map[int16]interface{})[9].(int64)
+				page := bytes.NewReader(data[offset:])
+				thriftValue(t, page, thriftStruct)
+				return page
+			}
+		}
+		t.Fatalf("no column %s", name)
+		return nil
+	}
+
+	var millis, tokens int64
+	var cost float64
+	_ = binary.Read(column("timestamp"), binary.LittleEndian, &millis)
+	_ = binary.Read(column("total_tokens"), binary.LittleEndian, &tokens)
+	_ = binary.Read(column("total_cost"), binary.LittleEndian, &cost)
+	if millis != exportSample()[0].Timestamp.UnixMilli() || tokens != 15 || math.Abs(cost-0.5) > 1e-12 {
+		t.Errorf("first row = %d, %d, %v", millis, tokens, cost)
+	}
+
+	models := column("model")
+	var n uint32
+	_ = binary.Read(models, binary.LittleEndian, &n)
+	model := make([]byte, n)
+	_, _ = models.Read(model)
+	if string(model) != "gpt-4" {
+		t.Errorf("first model = %q, want gpt-4", model)
+	}
+
+	buf.Reset()
+	if err := WriteUsage(&buf, ExportParquet, nil); err != nil {
+		t.Fatalf("WriteUsage() of no records error = %v", err)
+	}
+	if !bytes.HasSuffix(buf.Bytes(), []byte(parquetMagic)) {
+		t.Error("an empty export should still be a Parquet file")
+	}
+}
+
+func TestDefaultTokenTracker_ExportUsage(t *testing.T) {
+	tracker := NewTokenTracker(NewConfig())
+	var buf bytes.Buffer
+	if err := tracker.ExportUsage(&buf, ExportCSV, UsageFilter{}); err == nil {
+		t.Error("ExportUsage() without a usage store should fail")
+	}
+
+	store := NewMemoryUsageStore()
+	for _, record := range exportSample() {
+		_ = store.Record(record)
+	}
+	tracker.SetUsageStore(store)
+
+	if err := tracker.ExportUsage(&buf, ExportCSV, UsageFilter{Provider: "anthropic"}); err != nil {
+		t.Fatalf("ExportUsage() error = %v", err)
+	}
+	records, _ := ReadUsage(UsageImportCSV, &buf)
+	if len(records) != 1 || records[0].Model != "claude-3-haiku" {
+		t.Errorf("exported %+v, want the filtered usage", records)
+	}
+}
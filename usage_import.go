@@ -0,0 +1,183 @@
+package tokentracker
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// UsageImportFormat identifies the format of previously collected usage records
+type UsageImportFormat string
+
+// Supported usage import formats
+const (
+	// UsageImportJSONL is the JSON lines format written by UsageLogger and FileUsageStore
+	UsageImportJSONL UsageImportFormat = "jsonl"
+
+	// UsageImportCSV is a CSV file with the UsageCSVHeader columns
+	UsageImportCSV UsageImportFormat = "csv"
+)
+
+// UsageCSVHeader are the columns of usage records in CSV. Tags are a JSON object and
+// durations are in milliseconds.
+var UsageCSVHeader = []string{
+	"timestamp", "provider", "model",
+	"input_tokens", "response_tokens", "total_tokens", "cached_input_tokens",
+	"input_cost", "output_cost", "total_cost", "currency",
+	"duration_ms", "user_id", "project_id", "cost_tier", "trace_id", "span_id", "tags",
+	"api_key_id", "organization", "provider_project_id", "completion_id", "sample_rate",
+}
+
+// ReadUsage reads usage records in the given format
+func ReadUsage(format UsageImportFormat, r io.Reader) ([]UsageMetrics, error) {
+	return ReadEncryptedUsage(format, r, nil)
+}
+
+// ReadEncryptedUsage reads usage records in the given format, decrypting the records of
+// encrypted usage logs with keys. Plaintext records are read as they are.
+func ReadEncryptedUsage(format UsageImportFormat, r io.Reader, keys KeyProvider) ([]UsageMetrics, error) {
+	switch format {
+	case UsageImportJSONL:
+		var encryptor *Encryptor
+		if keys != nil {
+			encryptor = NewEncryptor(keys)
+		}
+		return readUsageJSONL(r, encryptor)
+	case UsageImportCSV:
+		return importCSV(r, BillingImportOptions{Location: time.UTC}, usageCSVRow)
+	default:
+		return nil, NewError(ErrInvalidParams, fmt.Sprintf("unknown usage import format: %s", format), nil)
+	}
+}
+
+// readUsageJSONL reads a JSON lines usage log, skipping blank lines
+func readUsageJSONL(r io.Reader, encryptor *Encryptor) ([]UsageMetrics, error) {
+	var records []UsageMetrics
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		metrics, err := decodeUsageLine(scanner.Bytes(), encryptor)
+		if err != nil {
+			return nil, NewError(ErrInvalidParams, fmt.Sprintf("invalid usage record on line %d", line), err)
+		}
+		records = append(records, metrics)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, NewError(ErrInvalidParams, "failed to read usage log", err)
+	}
+	return records, nil
+}
+
+// usageCSVRow converts a row with the UsageCSVHeader columns
+func usageCSVRow(row csvRow, opts BillingImportOptions) (UsageMetrics, bool, error) {
+	timestamp, err := row.time(opts.Location, "timestamp")
+	if err != nil {
+		return UsageMetrics{}, false, err
+	}
+
+	metrics := UsageMetrics{
+		Timestamp: timestamp,
+		Provider:  row.get("provider"),
+		Model:     row.get("model"),
+		UserID:    row.get("user_id"),
+		ProjectID: row.get("project_id"),
+		CostTier:  row.get("cost_tier"),
+		TraceID:   row.get("trace_id"),
+		SpanID:    row.get("span_id"),
+
+		APIKeyID:          row.get("api_key_id"),
+		Organization:      row.get("organization"),
+		ProviderProjectID: row.get("provider_project_id"),
+		CompletionID:      row.get("completion_id"),
+	}
+	if metrics.Model == "" {
+		return UsageMetrics{}, false, row.errorf("missing model")
+	}
+
+	counts := []*int{&metrics.TokenCount.InputTokens, &metrics.TokenCount.ResponseTokens, &metrics.TokenCount.TotalTokens, &metrics.TokenCount.CachedInputTokens}
+	for i, name := range []string{"input_tokens", "response_tokens", "total_tokens", "cached_input_tokens"} {
+		if *counts[i], err = row.int(name); err != nil {
+			return UsageMetrics{}, false, err
+		}
+	}
+	if metrics.TokenCount.TotalTokens == 0 {
+		metrics.TokenCount.TotalTokens = metrics.TokenCount.InputTokens + metrics.TokenCount.ResponseTokens
+	}
+
+	costs := []*float64{&metrics.Price.InputCost, &metrics.Price.OutputCost, &metrics.Price.TotalCost}
+	for i, name := range []string{"input_cost", "output_cost", "total_cost"} {
+		if *costs[i], err = row.float(name); err != nil {
+			return UsageMetrics{}, false, err
+		}
+	}
+	metrics.Price.Currency = row.get("currency")
+	metrics.Price = metrics.Price.WithUnitPrices(metrics.TokenCount.InputTokens, metrics.TokenCount.ResponseTokens)
+
+	durationMS, err := row.float("duration_ms")
+	if err != nil {
+		return UsageMetrics{}, false, err
+	}
+	metrics.Duration = time.Duration(durationMS * float64(time.Millisecond))
+	if metrics.SampleRate, err = row.int("sample_rate"); err != nil {
+		return UsageMetrics{}, false, err
+	}
+
+	if tags := row.get("tags"); tags != "" {
+		if err := json.Unmarshal([]byte(tags), &metrics.Tags); err != nil {
+			return UsageMetrics{}, false, row.errorf("invalid tags %q", tags)
+		}
+	}
+	return metrics, true, nil
+}
+
+// ImportUsage reads usage records, e.g. a usage log written before the store was
+// adopted, and writes them into the usage store so they can be queried and
+// summarized like tracked usage. Encrypted records are decrypted with the Keys of the
+// configured usage log options. It returns the number of imported records.
+func (t *DefaultTokenTracker) ImportUsage(format UsageImportFormat, r io.Reader) (int, error) {
+	store := t.UsageStore()
+	if store == nil {
+		return 0, NewError(ErrStorageFailed, "no usage store configured", nil)
+	}
+
+	records, err := ReadEncryptedUsage(format, r, t.config.GetUsageLogOptions().Keys)
+	if err != nil {
+		return 0, err
+	}
+
+	return recordAll(store, t.scrubAll(records))
+}
+
+// ImportUsageLog imports the JSONL usage log at path, including its rotated files,
+// oldest first. It returns the number of imported records.
+func (t *DefaultTokenTracker) ImportUsageLog(path string) (int, error) {
+	files, err := UsageLogFiles(path)
+	if err != nil {
+		return 0, NewError(ErrInvalidParams, fmt.Sprintf("invalid usage log path: %s", path), err)
+	}
+	if len(files) == 0 {
+		return 0, NewError(ErrInvalidParams, fmt.Sprintf("usage log not found: %s", path), nil)
+	}
+
+	imported := 0
+	for _, name := range files {
+		file, err := os.Open(name)
+		if err != nil {
+			return imported, NewError(ErrStorageFailed, fmt.Sprintf("failed to open usage log: %s", name), err)
+		}
+		n, err := t.ImportUsage(UsageImportJSONL, file)
+		file.Close()
+		imported += n
+		if err != nil {
+			return imported, err
+		}
+	}
+	return imported, nil
+}
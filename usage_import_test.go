@@ -0,0 +1,111 @@
+package tokentracker
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker/tokentrackertest"
+)
+
+func TestReadUsage_JSONL(t *testing.T) {
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	var lines []string
+	for _, model := range []string{"gpt-4", "claude-3-haiku"} {
+		line, err := encodeUsageLine(sampleUsage(model, "openai", base, 0.5), nil)
+		if err != nil {
+			t.Fatalf("encodeUsageLine() error = %v", err)
+		}
+		lines = append(lines, strings.TrimSpace(string(line)))
+	}
+
+	records, err := ReadUsage(UsageImportJSONL, strings.NewReader(strings.Join(lines, "\n")+"\n\n"))
+	if err != nil {
+		t.Fatalf("ReadUsage() error = %v", err)
+	}
+	if len(records) != 2 || records[1].Model != "claude-3-haiku" || !records[0].Timestamp.Equal(base) {
+		t.Errorf("records = %+v", records)
+	}
+
+	if _, err := ReadUsage(UsageImportJSONL, strings.NewReader(lines[0]+"\n{broken\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("ReadUsage() of a broken line error = %v, want the line number", err)
+	}
+	if _, err := ReadUsage("xml", strings.NewReader("")); err == nil {
+		t.Error("ReadUsage() with an unknown format should fail")
+	}
+}
+
+func TestReadUsage_CSV(t *testing.T) {
+	data := strings.Join(UsageCSVHeader, ",") + "\n" +
+		`2024-03-01T12:00:00Z,openai,gpt-4,1000,500,,200,0.03,0.03,0.06,USD,250,user-1,proj-1,premium,trace-1,span-1,"{""feature"":""search""}"` + "\n" +
+		"2024-03-02,anthropic,claude-3-haiku,10,5,15,,,,0.001,USD,,,,,,,\n"
+
+	records, err := ReadUsage(UsageImportCSV, strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("ReadUsage() error = %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+
+	first := records[0]
+	if first.TokenCount.TotalTokens != 1500 || first.TokenCount.CachedInputTokens != 200 {
+		t.Errorf("TokenCount = %+v, want the total derived from input and response", first.TokenCount)
+	}
+	if first.Price.TotalCost != 0.06 || first.Duration != 250*time.Millisecond || first.CostTier != "premium" {
+		t.Errorf("record = %+v", first)
+	}
+	if first.UserID != "user-1" || first.SpanID != "span-1" || first.Tags["feature"] != "search" {
+		t.Errorf("attribution = %+v", first)
+	}
+	if records[1].Provider != "anthropic" || records[1].Price.TotalCost != 0.001 {
+		t.Errorf("second record = %+v", records[1])
+	}
+
+	if _, err := ReadUsage(UsageImportCSV, strings.NewReader("timestamp,model\n2024-03-01,\n")); err == nil {
+		t.Error("ReadUsage() of a row without a model should fail")
+	}
+}
+
+func TestDefaultTokenTracker_ImportUsageLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	clock := tokentrackertest.NewFakeClock(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	logger, err := NewUsageLogger(path, UsageLogOptions{MaxAge: time.Hour, Clock: clock})
+	if err != nil {
+		t.Fatalf("NewUsageLogger() error = %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := logger.Log(sampleUsage("gpt-4", "openai", clock.Now(), 0.25)); err != nil {
+			t.Fatalf("Log() error = %v", err)
+		}
+		if err := logger.Flush(); err != nil {
+			t.Fatalf("Flush() error = %v", err)
+		}
+		clock.Advance(2 * time.Hour)
+	}
+	logger.Close()
+
+	tracker := NewTokenTracker(NewConfig())
+	if _, err := tracker.ImportUsageLog(path); err == nil {
+		t.Error("ImportUsageLog() without a usage store should fail")
+	}
+
+	tracker.SetUsageStore(NewMemoryUsageStore())
+	n, err := tracker.ImportUsageLog(path)
+	if err != nil {
+		t.Fatalf("ImportUsageLog() error = %v", err)
+	}
+	if n != 3 {
+		t.Errorf("imported %d records, want 3 across the rotated files", n)
+	}
+
+	summaries, _ := tracker.Summary(UsageFilter{}, GroupByModel)
+	if len(summaries) != 1 || summaries[0].Calls != 3 || summaries[0].TotalCost != 0.75 {
+		t.Errorf("Summary() = %+v, want the imported history", summaries)
+	}
+
+	if _, err := tracker.ImportUsageLog(filepath.Join(t.TempDir(), "missing.jsonl")); err == nil {
+		t.Error("ImportUsageLog() of a missing log should fail")
+	}
+}
@@ -0,0 +1,341 @@
+package tokentracker
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default usage log settings
+const (
+	DefaultUsageLogBufferSize = 1024
+	usageLogTimeFormat        = "20060102T150405.000"
+)
+
+// UsageLogOptions controls buffering and rotation of the JSONL usage log
+type UsageLogOptions struct {
+	// MaxSizeBytes rotates the log once the active file would grow beyond this size (0 disables)
+	MaxSizeBytes int64 `json:"max_size_bytes,omitempty"`
+
+	// MaxAge rotates the log once the active file has been written for longer than this (0 disables)
+	MaxAge time.Duration `json:"max_age,omitempty"`
+
+	// MaxBackups is the number of rotated files to keep (0 keeps all)
+	MaxBackups int `json:"max_backups,omitempty"`
+
+	// BufferSize is the number of records queued before Log blocks (0 uses the default)
+	BufferSize int `json:"buffer_size,omitempty"`
+
+	// Clock is used for rotation by age and backup names (nil uses the system clock)
+	Clock Clock `json:"-"`
+
+	// Keys encrypts the records with AES-GCM when set, see FileUsageStore. ReadUsage
+	// and ImportUsageLog decrypt them with the same keys.
+	Keys KeyProvider `json:"-"`
+}
+
+// UsageLogger asynchronously writes usage records as JSON lines to a file,
+// rotating the file by size and age
+type UsageLogger struct {
+	path    string
+	opts    UsageLogOptions
+	file    *os.File
+	writer  *bufio.Writer
+	size    int64
+	opened  time.Time
+	records chan UsageMetrics
+	encrypt *Encryptor
+	flushes chan chan error
+	done    chan struct{}
+	err     error
+	closed  bool
+	mu      sync.RWMutex
+}
+
+// NewUsageLogger opens (or creates) the usage log at path and starts the background writer
+func NewUsageLogger(path string, opts UsageLogOptions) (*UsageLogger, error) {
+	if path == "" {
+		return nil, NewError(ErrInvalidParams, "usage log path is required", nil)
+	}
+
+	if opts.Clock == nil {
+		opts.Clock = SystemClock
+	}
+
+	bufferSize := opts.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = DefaultUsageLogBufferSize
+	}
+
+	l := &UsageLogger{
+		path:    path,
+		opts:    opts,
+		records: make(chan UsageMetrics, bufferSize),
+		flushes: make(chan chan error),
+		done:    make(chan struct{}),
+	}
+	if opts.Keys != nil {
+		l.encrypt = NewEncryptor(opts.Keys)
+	}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+
+	go l.run()
+	return l, nil
+}
+
+// Path returns the path of the active log file
+func (l *UsageLogger) Path() string {
+	return l.path
+}
+
+// Log queues a usage record for writing. It blocks only when the buffer is full.
+func (l *UsageLogger) Log(metrics UsageMetrics) error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if l.closed {
+		return NewError(ErrStorageFailed, "usage log is closed", nil)
+	}
+
+	l.records <- metrics
+	return nil
+}
+
+// Flush waits until all queued records have been written to the file
+func (l *UsageLogger) Flush() error {
+	l.mu.RLock()
+	if l.closed {
+		l.mu.RUnlock()
+		return nil
+	}
+	result := make(chan error, 1)
+	l.flushes <- result
+	l.mu.RUnlock()
+
+	return <-result
+}
+
+// Close writes all queued records, flushes and closes the log file
+func (l *UsageLogger) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		<-l.done
+		return nil
+	}
+	l.closed = true
+	close(l.records)
+	l.mu.Unlock()
+
+	<-l.done
+	return l.err
+}
+
+// run is the background writer loop
+func (l *UsageLogger) run() {
+	defer close(l.done)
+
+	for {
+		select {
+		case metrics, ok := <-l.records:
+			if !ok {
+				l.finish()
+				return
+			}
+			l.write(metrics)
+
+			// Write whatever else is queued before flushing to the file
+			for drained := false; !drained; {
+				select {
+				case metrics, ok := <-l.records:
+					if !ok {
+						l.finish()
+						return
+					}
+					l.write(metrics)
+				default:
+					drained = true
+				}
+			}
+			l.setErr(l.writer.Flush())
+
+		case result := <-l.flushes:
+			for drained := false; !drained; {
+				select {
+				case metrics := <-l.records:
+					l.write(metrics)
+				default:
+					drained = true
+				}
+			}
+			l.setErr(l.writer.Flush())
+			result <- l.err
+		}
+	}
+}
+
+// write serializes one record, rotating the file first when needed
+func (l *UsageLogger) write(metrics UsageMetrics) {
+	line, err := encodeUsageLine(metrics, l.encrypt)
+	if err != nil {
+		l.setErr(err)
+		return
+	}
+
+	if l.shouldRotate(int64(len(line))) {
+		if err := l.rotate(); err != nil {
+			l.setErr(err)
+			return
+		}
+	}
+
+	n, err := l.writer.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		l.setErr(NewError(ErrStorageFailed, "failed to write usage log", err))
+	}
+}
+
+// finish flushes and closes the file once the record channel is closed
+func (l *UsageLogger) finish() {
+	l.setErr(l.writer.Flush())
+	if err := l.file.Sync(); err != nil {
+		l.setErr(NewError(ErrStorageFailed, "failed to sync usage log", err))
+	}
+	if err := l.file.Close(); err != nil {
+		l.setErr(NewError(ErrStorageFailed, "failed to close usage log", err))
+	}
+}
+
+// setErr remembers the first write error so Flush and Close can report it
+func (l *UsageLogger) setErr(err error) {
+	if err != nil && l.err == nil {
+		l.err = err
+	}
+}
+
+// shouldRotate reports whether writing n more bytes requires a new file
+func (l *UsageLogger) shouldRotate(n int64) bool {
+	if l.size == 0 {
+		return false
+	}
+	if l.opts.MaxSizeBytes > 0 && l.size+n > l.opts.MaxSizeBytes {
+		return true
+	}
+	return l.opts.MaxAge > 0 && l.opts.Clock.Since(l.opened) >= l.opts.MaxAge
+}
+
+// open opens the active log file for appending
+func (l *UsageLogger) open() error {
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return NewError(ErrStorageFailed, fmt.Sprintf("failed to open usage log: %s", l.path), err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return NewError(ErrStorageFailed, fmt.Sprintf("failed to stat usage log: %s", l.path), err)
+	}
+
+	l.file = file
+	l.writer = bufio.NewWriter(file)
+	l.size = info.Size()
+	l.opened = l.opts.Clock.Now()
+	return nil
+}
+
+// rotate moves the active file aside and opens a fresh one
+func (l *UsageLogger) rotate() error {
+	if err := l.writer.Flush(); err != nil {
+		return NewError(ErrStorageFailed, "failed to flush usage log", err)
+	}
+	if err := l.file.Close(); err != nil {
+		return NewError(ErrStorageFailed, "failed to close usage log", err)
+	}
+
+	if err := os.Rename(l.path, l.backupName(l.opts.Clock.Now())); err != nil {
+		return NewError(ErrStorageFailed, "failed to rotate usage log", err)
+	}
+
+	if err := l.open(); err != nil {
+		return err
+	}
+
+	l.pruneBackups()
+	return nil
+}
+
+// backupName returns the name of a rotated file, e.g. usage-20240301T120000.000.jsonl
+func (l *UsageLogger) backupName(t time.Time) string {
+	ext := filepath.Ext(l.path)
+	return strings.TrimSuffix(l.path, ext) + "-" + t.UTC().Format(usageLogTimeFormat) + ext
+}
+
+// UsageLogFiles returns the rotated files of the log at path, oldest first, followed by the active file
+func UsageLogFiles(path string) ([]string, error) {
+	ext := filepath.Ext(path)
+	backups, err := filepath.Glob(strings.TrimSuffix(path, ext) + "-*" + ext)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(backups)
+
+	if _, err := os.Stat(path); err == nil {
+		backups = append(backups, path)
+	}
+	return backups, nil
+}
+
+// PruneUsageLogFiles removes the rotated files of the log at path that were rotated
+// before a time, i.e. that hold only older usage. It returns the number of files removed.
+func PruneUsageLogFiles(path string, before time.Time) (int, error) {
+	files, err := UsageLogFiles(path)
+	if err != nil {
+		return 0, NewError(ErrStorageFailed, "failed to list usage log files", err)
+	}
+
+	ext := filepath.Ext(path)
+	prefix := strings.TrimSuffix(path, ext) + "-"
+	removed := 0
+	for _, file := range files {
+		name, isBackup := strings.CutPrefix(file, prefix)
+		if !isBackup {
+			continue
+		}
+		rotated, err := time.Parse(usageLogTimeFormat, strings.TrimSuffix(name, ext))
+		if err != nil || !rotated.Before(before) {
+			continue
+		}
+		if err := os.Remove(file); err != nil {
+			return removed, NewError(ErrStorageFailed, fmt.Sprintf("failed to remove usage log file: %s", file), err)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// pruneBackups removes the oldest rotated files beyond MaxBackups
+func (l *UsageLogger) pruneBackups() {
+	if l.opts.MaxBackups <= 0 {
+		return
+	}
+
+	files, err := UsageLogFiles(l.path)
+	if err != nil {
+		return
+	}
+	backups := files[:len(files)-1]
+
+	for len(backups) > l.opts.MaxBackups {
+		os.Remove(backups[0])
+		backups = backups[1:]
+	}
+}
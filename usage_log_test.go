@@ -0,0 +1,150 @@
+package tokentracker
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TrustSight-io/tokentracker/tokentrackertest"
+)
+
+func TestUsageLogger_WritesJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+
+	logger, err := NewUsageLogger(path, UsageLogOptions{})
+	if err != nil {
+		t.Fatalf("NewUsageLogger() error = %v", err)
+	}
+
+	base := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		if err := logger.Log(sampleUsage("gpt-4", "openai", base.Add(time.Duration(i)*time.Minute), 1)); err != nil {
+			t.Fatalf("Log() error = %v", err)
+		}
+	}
+
+	if err := logger.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	records, _ := readUsageFile(path, nil)
+	if len(records) != 10 {
+		t.Errorf("after Flush() file has %d records, want 10", len(records))
+	}
+
+	_ = logger.Log(sampleUsage("gpt-4", "openai", base.Add(time.Hour), 1))
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	records, err = readUsageFile(path, nil)
+	if err != nil {
+		t.Fatalf("readUsageFile() error = %v", err)
+	}
+	if len(records) != 11 || records[10].Model != "gpt-4" {
+		t.Errorf("after Close() file has %d records, want 11", len(records))
+	}
+
+	if err := logger.Log(sampleUsage("gpt-4", "openai", base, 1)); err == nil {
+		t.Error("Log() after Close() should fail")
+	}
+}
+
+func TestUsageLogger_RotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+
+	logger, err := NewUsageLogger(path, UsageLogOptions{MaxSizeBytes: 600, MaxBackups: 2})
+	if err != nil {
+		t.Fatalf("NewUsageLogger() error = %v", err)
+	}
+
+	for i := 0; i < 20; i++ {
+		_ = logger.Log(sampleUsage("gpt-4", "openai", time.Now(), 1))
+		// Wait for each write so rotated files get distinct timestamps
+		_ = logger.Flush()
+		time.Sleep(2 * time.Millisecond)
+	}
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	files, err := UsageLogFiles(path)
+	if err != nil {
+		t.Fatalf("UsageLogFiles() error = %v", err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("UsageLogFiles() = %v, want 2 backups and the active file", files)
+	}
+	if files[2] != path {
+		t.Errorf("last file = %s, want active file %s", files[2], path)
+	}
+
+	for _, file := range files {
+		records, err := readUsageFile(file, nil)
+		if err != nil {
+			t.Fatalf("readUsageFile(%s) error = %v", file, err)
+		}
+		if len(records) == 0 {
+			t.Errorf("%s is empty", file)
+		}
+	}
+}
+
+func TestUsageLogger_RotatesByAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	clock := tokentrackertest.NewFakeClock(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+
+	logger, _ := NewUsageLogger(path, UsageLogOptions{MaxAge: time.Hour, Clock: clock})
+	_ = logger.Log(sampleUsage("gpt-4", "openai", clock.Now(), 1))
+	_ = logger.Flush()
+	clock.Advance(2 * time.Hour)
+	_ = logger.Log(sampleUsage("gpt-4", "openai", clock.Now(), 1))
+	_ = logger.Close()
+
+	files, _ := UsageLogFiles(path)
+	if len(files) != 2 {
+		t.Fatalf("UsageLogFiles() = %v, want one backup and the active file", files)
+	}
+	if filepath.Base(files[0]) != "usage-20240301T020000.000.jsonl" {
+		t.Errorf("backup name = %s, want the fake clock's time", filepath.Base(files[0]))
+	}
+}
+
+func TestDefaultTokenTracker_UsageLogging(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+
+	config := NewConfig()
+	if err := config.EnableUsageLogging(path); err != nil {
+		t.Fatalf("EnableUsageLogging() error = %v", err)
+	}
+
+	tracker := NewTokenTracker(config)
+	tracker.RegisterProvider(&MockProvider{
+		name:           "mock",
+		supportedModel: "mock-model",
+		tokenCount:     TokenCount{InputTokens: 10, TotalTokens: 10},
+		price:          Price{TotalCost: 0.01, Currency: "USD"},
+	})
+
+	for i := 0; i < 3; i++ {
+		_, err := tracker.TrackUsage(CallParams{
+			Model:     "mock-model",
+			Params:    TokenCountParams{Model: "mock-model", Text: stringPtr("Hello")},
+			StartTime: time.Now(),
+		}, "response")
+		if err != nil {
+			t.Fatalf("TrackUsage() error = %v", err)
+		}
+	}
+
+	if err := tracker.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	records, err := readUsageFile(path, nil)
+	if err != nil {
+		t.Fatalf("readUsageFile() error = %v", err)
+	}
+	if len(records) != 3 || records[0].Provider != "mock" || records[0].Price.TotalCost != 0.01 {
+		t.Errorf("usage log = %+v, want 3 mock records", records)
+	}
+}
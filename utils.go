@@ -2,54 +2,13 @@ package tokentracker
 
 import (
 	"encoding/json"
-	"fmt"
 	"strings"
-	"sync"
 )
 
-// Cache for token counting to improve performance
-type tokenCache struct {
-	cache map[string]int
-	mu    sync.RWMutex
-}
-
-// Global token cache
-var globalTokenCache = &tokenCache{
-	cache: make(map[string]int),
-}
-
-// GetCachedTokenCount gets a cached token count if available
-func GetCachedTokenCount(provider, model, text string) (int, bool) {
-	globalTokenCache.mu.RLock()
-	defer globalTokenCache.mu.RUnlock()
-
-	key := fmt.Sprintf("%s:%s:%s", provider, model, hashString(text))
-	count, exists := globalTokenCache.cache[key]
-	return count, exists
-}
-
-// SetCachedTokenCount sets a token count in the cache
-func SetCachedTokenCount(provider, model, text string, count int) {
-	globalTokenCache.mu.Lock()
-	defer globalTokenCache.mu.Unlock()
-
-	key := fmt.Sprintf("%s:%s:%s", provider, model, hashString(text))
-	globalTokenCache.cache[key] = count
-}
-
-// hashString creates a simple hash of a string for cache keys
-// This is a simple implementation and could be improved for production use
-func hashString(s string) string {
-	if len(s) > 100 {
-		// For long strings, just use a prefix and suffix with length
-		return fmt.Sprintf("%s...%s:%d", s[:50], s[len(s)-50:], len(s))
-	}
-	return s
-}
-
 // ExtractTextFromMessages extracts all text content from messages
 func ExtractTextFromMessages(messages []Message) string {
 	var builder strings.Builder
+	builder.Grow(messageTextSize(messages))
 
 	for _, message := range messages {
 		switch content := message.Content.(type) {
@@ -81,6 +40,49 @@ func ExtractTextFromMessages(messages []Message) string {
 	return builder.String()
 }
 
+// messageTextSize returns the size of the plain text of messages, so their text is
+// extracted with a single allocation
+func messageTextSize(messages []Message) int {
+	size := 0
+	for _, message := range messages {
+		switch content := message.Content.(type) {
+		case string:
+			size += len(content) + 1
+		case []ContentPart:
+			for _, part := range content {
+				if part.Type == "text" {
+					size += len(part.Text) + 1
+				}
+			}
+		}
+	}
+	return size
+}
+
+// ExtractToolCallText extracts the tool calls of messages for token counting: the name
+// and arguments of every call and the call ID of every tool result. Tool results
+// themselves are message content, see ExtractTextFromMessages.
+func ExtractToolCallText(messages []Message) string {
+	var builder strings.Builder
+
+	for _, message := range messages {
+		for _, call := range message.ToolCalls {
+			builder.WriteString(ToolCallText(call))
+		}
+		if message.ToolCallID != "" {
+			builder.WriteString(message.ToolCallID)
+			builder.WriteString("\n")
+		}
+	}
+
+	return builder.String()
+}
+
+// ToolCallText returns the text of a tool call the model generates: its name and arguments
+func ToolCallText(call ToolCall) string {
+	return call.Name + "\n" + call.Arguments + "\n"
+}
+
 // FormatToolsAsJSON formats tools as JSON for token counting
 func FormatToolsAsJSON(tools []Tool) string {
 	if len(tools) == 0 {
@@ -95,8 +97,8 @@ func FormatToolsAsJSON(tools []Tool) string {
 	return string(data)
 }
 
-// EstimateResponseTokens provides a simple estimation of response tokens based on input tokens
-// This is a very basic implementation and should be replaced with provider-specific logic
+// EstimateResponseTokens provides a simple estimation of response tokens based on input tokens.
+// HistoricalEstimator falls back to it for models without enough tracked calls.
 func EstimateResponseTokens(model string, inputTokens int) int {
 	// Different models have different response patterns
 	// These are very rough estimates and should be refined based on actual usage patterns
@@ -123,18 +125,3 @@ func EstimateResponseTokens(model string, inputTokens int) int {
 	// Default fallback
 	return inputTokens / 2
 }
-
-// CleanupCache cleans up the token cache to prevent memory leaks
-func CleanupCache(maxSize int) {
-	globalTokenCache.mu.Lock()
-	defer globalTokenCache.mu.Unlock()
-
-	// If cache is smaller than maxSize, do nothing
-	if len(globalTokenCache.cache) <= maxSize {
-		return
-	}
-
-	// Simple strategy: just clear the cache completely
-	// A more sophisticated approach would be to use an LRU cache
-	globalTokenCache.cache = make(map[string]int)
-}
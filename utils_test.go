@@ -9,9 +9,7 @@ import (
 
 func TestTokenCache(t *testing.T) {
 	// Clear the cache before testing
-	globalTokenCache.mu.Lock()
-	globalTokenCache.cache = make(map[string]int)
-	globalTokenCache.mu.Unlock()
+	GlobalTokenCache().Purge()
 
 	// Test GetCachedTokenCount with empty cache
 	_, exists := GetCachedTokenCount("test-provider", "test-model", "test text")
@@ -53,49 +51,6 @@ func TestTokenCache(t *testing.T) {
 	}
 }
 
-func TestHashString(t *testing.T) {
-	// Test with short string
-	shortStr := "short string"
-	shortHash := hashString(shortStr)
-	if shortHash != shortStr {
-		t.Errorf("Expected hashString to return the same string for short strings, got %q", shortHash)
-	}
-
-	// Test with long string
-	longStr := strings.Repeat("a", 200)
-	longHash := hashString(longStr)
-	if longHash == longStr {
-		t.Errorf("Expected hashString to modify long strings")
-	}
-
-	// Check that the hash includes the length
-	if !strings.Contains(longHash, "200") {
-		t.Errorf("Expected hash of long string to include the string length")
-	}
-
-	// Check that the hash includes both prefix and suffix
-	if !strings.Contains(longHash, "a...a") {
-		t.Errorf("Expected hash of long string to include prefix and suffix")
-	}
-
-	// Test with exactly 100 characters
-	str100 := strings.Repeat("b", 100)
-	hash100 := hashString(str100)
-	if hash100 != str100 {
-		t.Errorf("Expected hashString to return the same string for strings of exactly 100 chars")
-	}
-
-	// Test with 101 characters
-	str101 := strings.Repeat("c", 101)
-	hash101 := hashString(str101)
-	if hash101 == str101 {
-		t.Errorf("Expected hashString to modify strings longer than 100 chars")
-	}
-	if !strings.Contains(hash101, "101") {
-		t.Errorf("Expected hash of 101-char string to include the string length")
-	}
-}
-
 func TestExtractTextFromMessages(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -346,35 +301,47 @@ func TestEstimateResponseTokens(t *testing.T) {
 
 func TestCleanupCache(t *testing.T) {
 	// Populate the cache with some entries
-	globalTokenCache.mu.Lock()
-	globalTokenCache.cache = make(map[string]int)
+	GlobalTokenCache().Purge()
 	for i := 0; i < 10; i++ {
-		key := fmt.Sprintf("key-%d", i)
-		globalTokenCache.cache[key] = i
+		SetCachedTokenCount("test-provider", "test-model", fmt.Sprintf("key-%d", i), i)
 	}
-	size := len(globalTokenCache.cache)
-	globalTokenCache.mu.Unlock()
 
 	// Verify initial size
-	if size != 10 {
+	if size := GlobalTokenCache().Stats().Entries; size != 10 {
 		t.Errorf("Expected initial cache size to be 10, got %d", size)
 	}
 
 	// Test cleanup with larger max size (should not clean up)
 	CleanupCache(20)
-	globalTokenCache.mu.RLock()
-	size = len(globalTokenCache.cache)
-	globalTokenCache.mu.RUnlock()
-	if size != 10 {
+	if size := GlobalTokenCache().Stats().Entries; size != 10 {
 		t.Errorf("Expected cache size to remain 10 after CleanupCache(20), got %d", size)
 	}
 
 	// Test cleanup with smaller max size (should clean up)
 	CleanupCache(5)
-	globalTokenCache.mu.RLock()
-	size = len(globalTokenCache.cache)
-	globalTokenCache.mu.RUnlock()
-	if size != 0 {
+	if size := GlobalTokenCache().Stats().Entries; size != 0 {
 		t.Errorf("Expected cache to be emptied after CleanupCache(5), got size %d", size)
 	}
 }
+
+func TestExtractToolCallText(t *testing.T) {
+	messages := []Message{
+		{Role: "user", Content: "Weather in Paris?"},
+		{Role: "assistant", ToolCalls: []ToolCall{{ID: "call_1", Name: "get_forecast", Arguments: `{"location":"Paris"}`}}},
+		{Role: "tool", ToolCallID: "call_1", Content: "sunny"},
+	}
+
+	if got, want := ExtractToolCallText(messages), "get_forecast\n{\"location\":\"Paris\"}\ncall_1\n"; got != want {
+		t.Errorf("ExtractToolCallText() = %q, want %q", got, want)
+	}
+	if got := ExtractToolCallText(messages[:1]); got != "" {
+		t.Errorf("ExtractToolCallText() without tool calls = %q", got)
+	}
+
+	// Tool calls round-trip through OpenAI's message format
+	data, _ := json.Marshal(messages[1])
+	var decoded Message
+	if err := json.Unmarshal(data, &decoded); err != nil || len(decoded.ToolCalls) != 1 || decoded.ToolCalls[0] != messages[1].ToolCalls[0] {
+		t.Errorf("decoded %s = %+v, %v", data, decoded, err)
+	}
+}